@@ -0,0 +1,162 @@
+// Package apierror is the error body every endpoint responds with:
+//
+//	{"error": {"code": "NOT_FOUND", "message": "Menu not found", "details": {...}}}
+//
+// Code is stable and is what clients should branch on. Message is meant
+// for people and may change. Details, when present, carry what a client
+// needs to act on the error, such as the ids of the records blocking a
+// delete.
+package apierror
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Code says what kind of error a response is.
+type Code string
+
+const (
+	CodeInvalidRequest       Code = "INVALID_REQUEST"
+	CodeValidationFailed     Code = "VALIDATION_FAILED"
+	CodeUnauthorized         Code = "UNAUTHORIZED"
+	CodePaymentRequired      Code = "PAYMENT_REQUIRED"
+	CodeForbidden            Code = "FORBIDDEN"
+	CodeNotFound             Code = "NOT_FOUND"
+	CodeConflict             Code = "CONFLICT"
+	CodePayloadTooLarge      Code = "PAYLOAD_TOO_LARGE"
+	CodeUnsupportedMediaType Code = "UNSUPPORTED_MEDIA_TYPE"
+	CodeUnprocessable        Code = "UNPROCESSABLE"
+	CodeInternal             Code = "INTERNAL"
+	CodeUpstreamFailed       Code = "UPSTREAM_FAILED"
+	CodeUnavailable          Code = "UNAVAILABLE"
+)
+
+// statusCodes is the code of each status an Error can have, for errors
+// made from a status alone.
+var statusCodes = map[int]Code{
+	http.StatusBadRequest:            CodeInvalidRequest,
+	http.StatusUnauthorized:          CodeUnauthorized,
+	http.StatusPaymentRequired:       CodePaymentRequired,
+	http.StatusForbidden:             CodeForbidden,
+	http.StatusNotFound:              CodeNotFound,
+	http.StatusConflict:              CodeConflict,
+	http.StatusRequestEntityTooLarge: CodePayloadTooLarge,
+	http.StatusUnsupportedMediaType:  CodeUnsupportedMediaType,
+	http.StatusUnprocessableEntity:   CodeUnprocessable,
+	http.StatusInternalServerError:   CodeInternal,
+	http.StatusBadGateway:            CodeUpstreamFailed,
+	http.StatusServiceUnavailable:    CodeUnavailable,
+}
+
+// Error is an error response: its HTTP status and the body sent with it.
+type Error struct {
+	Status  int                    `json:"-"`
+	Code    Code                   `json:"code"`
+	Message string                 `json:"message"`
+	Details map[string]interface{} `json:"details,omitempty"`
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+func New(status int, code Code, message string) *Error {
+	return &Error{Status: status, Code: code, Message: message}
+}
+
+// FromStatus makes an error with the usual code for status.
+func FromStatus(status int, message string) *Error {
+	code, ok := statusCodes[status]
+	if !ok {
+		code = CodeInternal
+	}
+	return New(status, code, message)
+}
+
+// With adds a detail to the error and returns it.
+func (e *Error) With(key string, value interface{}) *Error {
+	if e.Details == nil {
+		e.Details = map[string]interface{}{}
+	}
+	e.Details[key] = value
+	return e
+}
+
+// BadRequest is for requests that cannot be read, such as malformed JSON
+// or query parameters.
+func BadRequest(message string) *Error {
+	return New(http.StatusBadRequest, CodeInvalidRequest, message)
+}
+
+// Validation is for requests that were read but break a rule on their
+// fields.
+func Validation(message string) *Error {
+	return New(http.StatusBadRequest, CodeValidationFailed, message)
+}
+
+func Unauthorized(message string) *Error {
+	return New(http.StatusUnauthorized, CodeUnauthorized, message)
+}
+
+func PaymentRequired(message string) *Error {
+	return New(http.StatusPaymentRequired, CodePaymentRequired, message)
+}
+
+func Forbidden(message string) *Error {
+	return New(http.StatusForbidden, CodeForbidden, message)
+}
+
+func NotFound(message string) *Error {
+	return New(http.StatusNotFound, CodeNotFound, message)
+}
+
+func Conflict(message string) *Error {
+	return New(http.StatusConflict, CodeConflict, message)
+}
+
+func PayloadTooLarge(message string) *Error {
+	return New(http.StatusRequestEntityTooLarge, CodePayloadTooLarge, message)
+}
+
+func UnsupportedMediaType(message string) *Error {
+	return New(http.StatusUnsupportedMediaType, CodeUnsupportedMediaType, message)
+}
+
+// Unprocessable is for well-formed requests that business rules turn
+// down.
+func Unprocessable(message string) *Error {
+	return New(http.StatusUnprocessableEntity, CodeUnprocessable, message)
+}
+
+func Internal(message string) *Error {
+	return New(http.StatusInternalServerError, CodeInternal, message)
+}
+
+// UpstreamFailed is for failures of a service this one relies on, such
+// as a payment gateway.
+func UpstreamFailed(message string) *Error {
+	return New(http.StatusBadGateway, CodeUpstreamFailed, message)
+}
+
+func Unavailable(message string) *Error {
+	return New(http.StatusServiceUnavailable, CodeUnavailable, message)
+}
+
+// Render writes err as the response. Errors that are not an *Error are
+// sent as INTERNAL.
+func Render(c *gin.Context, err error) {
+	apiErr, ok := err.(*Error)
+	if !ok {
+		apiErr = Internal(err.Error())
+	}
+	c.JSON(apiErr.Status, gin.H{"error": apiErr})
+}
+
+// Abort renders err and stops the handlers after the current one, for
+// middleware.
+func Abort(c *gin.Context, err error) {
+	Render(c, err)
+	c.Abort()
+}
@@ -0,0 +1,135 @@
+package apierror
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+)
+
+// FieldError is one rule a request field broke. Field is the path to it
+// by JSON name, such as "substitutions[0].sku", and Rule the validate tag
+// that failed, such as "required" or "max", or "oneof" for a choice of
+// values.
+type FieldError struct {
+	Field   string `json:"field"`
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+// FromValidation turns a failed validation into a VALIDATION_FAILED error.
+// The validator's errors are listed field by field in the "fields"
+// detail; other errors, from checks made by hand, become the message.
+func FromValidation(err error) *Error {
+	var fieldErrs validator.ValidationErrors
+	if !errors.As(err, &fieldErrs) || len(fieldErrs) == 0 {
+		return Validation("Validation failed: " + err.Error())
+	}
+
+	fields := make([]FieldError, 0, len(fieldErrs))
+	for _, fe := range fieldErrs {
+		field := fe.Namespace()
+		// The namespace starts with the struct's name, which means
+		// nothing to clients
+		if i := strings.IndexByte(field, '.'); i >= 0 {
+			field = field[i+1:]
+		}
+		rule := fe.Tag()
+		if strings.Contains(rule, "|") {
+			rule = "oneof"
+		}
+		fields = append(fields, FieldError{Field: field, Rule: rule, Message: fieldMessage(fe)})
+	}
+
+	message := "Validation failed: " + fields[0].Field + " " + fields[0].Message
+	if len(fields) > 1 {
+		message = fmt.Sprintf("%s, and %d more", message, len(fields)-1)
+	}
+	return Validation(message).With("fields", fields)
+}
+
+// JSONFieldName names struct fields by their JSON tag in validation
+// errors; register it with the validator's RegisterTagNameFunc.
+func JSONFieldName(field reflect.StructField) string {
+	name := strings.SplitN(field.Tag.Get("json"), ",", 2)[0]
+	if name == "-" {
+		return ""
+	}
+	if name == "" {
+		return field.Name
+	}
+	return name
+}
+
+// fieldMessage says in words what a field must be, after the field name.
+func fieldMessage(fe validator.FieldError) string {
+	param := fe.Param()
+	kind := fe.Kind()
+	if kind == reflect.Ptr {
+		kind = fe.Type().Elem().Kind()
+	}
+	units := ""
+	switch kind {
+	case reflect.String:
+		units = " characters"
+	case reflect.Slice, reflect.Array, reflect.Map:
+		units = " items"
+	}
+
+	switch tag := fe.Tag(); tag {
+	case "required":
+		return "is required"
+	case "required_if", "required_unless", "required_without":
+		return "is required here"
+	case "excluded_with":
+		return "must be left out when " + strings.ToLower(param) + " is given"
+	case "min", "gte":
+		return "must be at least " + param + units
+	case "max", "lte":
+		return "must be at most " + param + units
+	case "gt":
+		return "must be greater than " + param + units
+	case "lt":
+		return "must be less than " + param + units
+	case "len":
+		return "must be exactly " + param + units
+	case "oneof":
+		return "must be one of " + strings.Join(strings.Fields(param), ", ")
+	case "eq":
+		return "must be " + param
+	case "ne":
+		return "must not be " + param
+	case "nefield":
+		return "must differ from " + strings.ToLower(param)
+	case "gtfield":
+		return "must be after " + strings.ToLower(param)
+	case "unique":
+		return "must not contain duplicates"
+	case "email":
+		return "must be an email address"
+	case "url":
+		return "must be a URL"
+	case "uuid":
+		return "must be a UUID"
+	case "datetime":
+		return "must be a time in the layout " + param
+	case "uppercase":
+		return "must be in upper case"
+	case "startswith":
+		return "must start with " + param
+	case "e164":
+		return "must be a phone number in E.164 format, such as +14155552671"
+	default:
+		// Alternatives such as eq=DINE_IN|eq=TAKEOUT
+		if strings.Contains(tag, "|") {
+			values := []string{}
+			for _, alternative := range strings.Split(tag, "|") {
+				values = append(values, strings.TrimPrefix(alternative, "eq="))
+			}
+			return "must be one of " + strings.Join(values, ", ")
+		}
+		return "failed the " + tag + " rule"
+	}
+}
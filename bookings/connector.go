@@ -0,0 +1,126 @@
+// Package bookings connects the reservations module to external booking
+// platforms such as OpenTable or Resy.
+package bookings
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Booking is a reservation as held by an external platform.
+type Booking struct {
+	External_id      string    `json:"id"`
+	Customer_name    string    `json:"customer_name"`
+	Customer_phone   string    `json:"customer_phone"`
+	Customer_email   string    `json:"customer_email"`
+	Party_size       int       `json:"party_size"`
+	Reserved_at      time.Time `json:"reserved_at"`
+	Duration_minutes int       `json:"duration_minutes"`
+	Notes            string    `json:"notes"`
+	// Status is BOOKED or CANCELLED.
+	Status     string    `json:"status"`
+	Created_at time.Time `json:"created_at"`
+	Updated_at time.Time `json:"updated_at"`
+}
+
+// Slot is the number of covers still bookable in one time slot.
+type Slot struct {
+	Start            time.Time `json:"start"`
+	End              time.Time `json:"end"`
+	Available_covers int       `json:"available_covers"`
+}
+
+// Connector syncs one venue on one platform.
+type Connector interface {
+	Name() string
+	// Bookings returns bookings created or changed since the given time.
+	Bookings(ctx context.Context, since time.Time) ([]Booking, error)
+	// PushAvailability replaces the platform's availability for the slots.
+	PushAvailability(ctx context.Context, slots []Slot) error
+	// Cancel cancels a booking on the platform, telling the guest why.
+	Cancel(ctx context.Context, externalId, reason string) error
+}
+
+// Partner is a Connector for platforms reached through a partner REST API,
+// either directly or through the integration middleware the platform
+// provides. It expects:
+//
+//	GET  {api_url}/venues/{venue}/bookings?updated_since=RFC3339 -> {"bookings": [Booking]}
+//	PUT  {api_url}/venues/{venue}/availability  {"slots": [Slot]}
+//	POST {api_url}/venues/{venue}/bookings/{id}/cancel  {"reason": "..."}
+type Partner struct {
+	Platform string
+	Api_url  string
+	Api_key  string
+	Venue_id string
+}
+
+var httpClient = &http.Client{Timeout: 30 * time.Second}
+
+func (p *Partner) Name() string {
+	return p.Platform
+}
+
+func (p *Partner) Bookings(ctx context.Context, since time.Time) ([]Booking, error) {
+	var body struct {
+		Bookings []Booking `json:"bookings"`
+	}
+	query := url.Values{}
+	if !since.IsZero() {
+		query.Set("updated_since", since.UTC().Format(time.RFC3339))
+	}
+	if err := p.do(ctx, http.MethodGet, "/bookings?"+query.Encode(), nil, &body); err != nil {
+		return nil, err
+	}
+	return body.Bookings, nil
+}
+
+func (p *Partner) PushAvailability(ctx context.Context, slots []Slot) error {
+	return p.do(ctx, http.MethodPut, "/availability", map[string]interface{}{"slots": slots}, nil)
+}
+
+func (p *Partner) Cancel(ctx context.Context, externalId, reason string) error {
+	return p.do(ctx, http.MethodPost, "/bookings/"+url.PathEscape(externalId)+"/cancel", map[string]string{"reason": reason}, nil)
+}
+
+func (p *Partner) do(ctx context.Context, method, path string, in, out interface{}) error {
+	var body io.Reader
+	if in != nil {
+		payload, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(payload)
+	}
+	endpoint := strings.TrimRight(p.Api_url, "/") + "/venues/" + url.PathEscape(p.Venue_id) + path
+	req, err := http.NewRequestWithContext(ctx, method, endpoint, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+p.Api_key)
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		detail, _ := io.ReadAll(io.LimitReader(resp.Body, 2048))
+		return fmt.Errorf("bookings: %s %s returned %d: %s", method, p.Platform, resp.StatusCode, detail)
+	}
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
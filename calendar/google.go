@@ -0,0 +1,146 @@
+package calendar
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// ErrNotConfigured is returned when the Google OAuth client is not set.
+var ErrNotConfigured = errors.New("calendar: GOOGLE_CLIENT_ID and GOOGLE_CLIENT_SECRET are not configured")
+
+var httpClient = &http.Client{Timeout: 30 * time.Second}
+
+const googleCalendarURL = "https://www.googleapis.com/calendar/v3/calendars/"
+
+// Google pushes events to one Google Calendar on behalf of the account that
+// granted Refresh_token, using the OAuth client in GOOGLE_CLIENT_ID and
+// GOOGLE_CLIENT_SECRET.
+type Google struct {
+	Calendar_id   string
+	Refresh_token string
+
+	accessToken string
+	expiresAt   time.Time
+}
+
+// Push creates the event or updates it if it was pushed before. Cancelled
+// events are kept on the calendar with a cancelled status.
+func (g *Google) Push(ctx context.Context, event Event) error {
+	status := "confirmed"
+	if event.Cancelled {
+		status = "cancelled"
+	}
+	body := map[string]interface{}{
+		"id":          googleEventId(event.UID),
+		"summary":     event.Summary,
+		"description": event.Description,
+		"location":    event.Location,
+		"start":       map[string]string{"dateTime": event.Start.UTC().Format(time.RFC3339)},
+		"end":         map[string]string{"dateTime": event.End.UTC().Format(time.RFC3339)},
+		"status":      status,
+	}
+
+	events := googleCalendarURL + url.PathEscape(g.Calendar_id) + "/events"
+	statusCode, err := g.send(ctx, http.MethodPut, events+"/"+googleEventId(event.UID), body)
+	if err != nil || statusCode != http.StatusNotFound {
+		return err
+	}
+	_, err = g.send(ctx, http.MethodPost, events, body)
+	return err
+}
+
+// send makes an authorized request. A 404 is returned to the caller rather
+// than treated as an error so Push can fall back to inserting.
+func (g *Google) send(ctx context.Context, method, endpoint string, body interface{}) (int, error) {
+	token, err := g.token(ctx)
+	if err != nil {
+		return 0, err
+	}
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return 0, err
+	}
+	req, err := http.NewRequestWithContext(ctx, method, endpoint, bytes.NewReader(payload))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 && resp.StatusCode != http.StatusNotFound {
+		detail, _ := io.ReadAll(io.LimitReader(resp.Body, 2048))
+		return resp.StatusCode, fmt.Errorf("calendar: google returned %d: %s", resp.StatusCode, detail)
+	}
+	return resp.StatusCode, nil
+}
+
+// token exchanges the refresh token for an access token, reusing it until
+// shortly before it expires.
+func (g *Google) token(ctx context.Context) (string, error) {
+	if g.accessToken != "" && time.Now().Before(g.expiresAt) {
+		return g.accessToken, nil
+	}
+	clientId := os.Getenv("GOOGLE_CLIENT_ID")
+	clientSecret := os.Getenv("GOOGLE_CLIENT_SECRET")
+	if clientId == "" || clientSecret == "" {
+		return "", ErrNotConfigured
+	}
+
+	form := url.Values{}
+	form.Set("client_id", clientId)
+	form.Set("client_secret", clientSecret)
+	form.Set("refresh_token", g.Refresh_token)
+	form.Set("grant_type", "refresh_token")
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, "https://oauth2.googleapis.com/token", strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		detail, _ := io.ReadAll(io.LimitReader(resp.Body, 2048))
+		return "", fmt.Errorf("calendar: google token exchange returned %d: %s", resp.StatusCode, detail)
+	}
+
+	var granted struct {
+		Access_token string `json:"access_token"`
+		Expires_in   int    `json:"expires_in"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&granted); err != nil {
+		return "", err
+	}
+	g.accessToken = granted.Access_token
+	g.expiresAt = time.Now().Add(time.Duration(granted.Expires_in)*time.Second - time.Minute)
+	return g.accessToken, nil
+}
+
+// googleEventId maps a UID to the base32hex alphabet Google requires for
+// client-chosen event ids.
+func googleEventId(uid string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= '0' && r <= '9' || r >= 'a' && r <= 'v' {
+			return r
+		}
+		return -1
+	}, strings.ToLower(uid))
+}
@@ -0,0 +1,92 @@
+// Package calendar renders iCalendar feeds and pushes events to Google
+// Calendar.
+package calendar
+
+import (
+	"bytes"
+	"strings"
+	"time"
+)
+
+// Event is one calendar entry. UID must stay the same for the life of the
+// entry so subscribers update it in place instead of duplicating it.
+type Event struct {
+	UID         string
+	Summary     string
+	Description string
+	Location    string
+	Start       time.Time
+	End         time.Time
+	Cancelled   bool
+	Updated     time.Time
+}
+
+const icsTime = "20060102T150405Z"
+
+// ICS renders events as an RFC 5545 calendar named name.
+func ICS(name string, events []Event) []byte {
+	var b bytes.Buffer
+	line := func(s string) {
+		b.WriteString(fold(s))
+		b.WriteString("\r\n")
+	}
+
+	line("BEGIN:VCALENDAR")
+	line("VERSION:2.0")
+	line("PRODID:-//restaurant-management//calendar//EN")
+	line("CALSCALE:GREGORIAN")
+	line("METHOD:PUBLISH")
+	line("X-WR-CALNAME:" + escape(name))
+	line("X-PUBLISHED-TTL:PT15M")
+	for _, event := range events {
+		stamp := event.Updated
+		if stamp.IsZero() {
+			stamp = time.Now()
+		}
+		line("BEGIN:VEVENT")
+		line("UID:" + event.UID)
+		line("DTSTAMP:" + stamp.UTC().Format(icsTime))
+		line("DTSTART:" + event.Start.UTC().Format(icsTime))
+		line("DTEND:" + event.End.UTC().Format(icsTime))
+		line("SUMMARY:" + escape(event.Summary))
+		if event.Description != "" {
+			line("DESCRIPTION:" + escape(event.Description))
+		}
+		if event.Location != "" {
+			line("LOCATION:" + escape(event.Location))
+		}
+		if event.Cancelled {
+			line("STATUS:CANCELLED")
+		} else {
+			line("STATUS:CONFIRMED")
+		}
+		line("END:VEVENT")
+	}
+	line("END:VCALENDAR")
+	return b.Bytes()
+}
+
+// escape quotes text values as RFC 5545 section 3.3.11 requires.
+func escape(s string) string {
+	return strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`).Replace(s)
+}
+
+// fold breaks content lines longer than 75 octets, continuing them on lines
+// that start with a space. Multi-byte characters are never split.
+func fold(s string) string {
+	if len(s) <= 75 {
+		return s
+	}
+	var b strings.Builder
+	width := 0
+	for _, r := range s {
+		size := len(string(r))
+		if width+size > 75 {
+			b.WriteString("\r\n ")
+			width = 1
+		}
+		b.WriteRune(r)
+		width += size
+	}
+	return b.String()
+}
@@ -0,0 +1,64 @@
+package main
+
+// seedUser is a staff account to create, signing in with the seed
+// password.
+type seedUser struct {
+	first, last, email, phone, role string
+}
+
+var seedUsers = []seedUser{
+	{"Ada", "Admin", "admin@example.com", "+15550100001", "ADMIN"},
+	{"Morgan", "Manager", "manager@example.com", "+15550100002", "MANAGER"},
+	{"Wes", "Waiter", "waiter1@example.com", "+15550100003", "WAITER"},
+	{"Willa", "Waiter", "waiter2@example.com", "+15550100004", "WAITER"},
+	{"Kit", "Cook", "kitchen@example.com", "+15550100005", "KITCHEN"},
+}
+
+// seedTable is a table to create.
+type seedTable struct {
+	number, guests int
+	section        string
+}
+
+var seedTables = []seedTable{
+	{1, 2, "Main hall"}, {2, 2, "Main hall"}, {3, 4, "Main hall"}, {4, 4, "Main hall"},
+	{5, 6, "Main hall"}, {6, 4, "Patio"}, {7, 4, "Patio"}, {8, 2, "Patio"},
+	{9, 8, "Private room"}, {10, 2, "Bar"},
+}
+
+// seedFood is a dish to put on a seed menu.
+type seedFood struct {
+	name, description string
+	price             float64
+	allergens, diets  []string
+	tags              []string
+}
+
+// seedMenu is a menu to create and publish with its foods.
+type seedMenu struct {
+	name, category string
+	foods          []seedFood
+}
+
+var seedMenus = []seedMenu{
+	{"Breakfast", "Breakfast", []seedFood{
+		{"Buttermilk Pancakes", "Three pancakes with maple syrup and whipped butter", 9.50, []string{"gluten", "milk", "egg"}, []string{"vegetarian"}, []string{"sweet"}},
+		{"Eggs Benedict", "Poached eggs and ham on an English muffin with hollandaise", 12.00, []string{"gluten", "milk", "egg"}, nil, []string{"signature"}},
+		{"Avocado Toast", "Sourdough, smashed avocado, chilli flakes and lime", 10.50, []string{"gluten"}, []string{"vegan"}, []string{"healthy"}},
+		{"Greek Yogurt Bowl", "Yogurt, honey, granola and seasonal berries", 8.00, []string{"milk", "tree_nut"}, []string{"vegetarian"}, []string{"healthy"}},
+	}},
+	{"All Day", "Mains", []seedFood{
+		{"Classic Burger", "Beef patty, cheddar, pickles and house sauce, with fries", 15.00, []string{"gluten", "milk", "mustard"}, nil, []string{"bestseller"}},
+		{"Margherita Pizza", "San Marzano tomato, mozzarella and basil", 13.50, []string{"gluten", "milk"}, []string{"vegetarian"}, nil},
+		{"Grilled Salmon", "Salmon fillet with lemon butter, greens and new potatoes", 21.00, []string{"fish", "milk"}, []string{"gluten-free"}, []string{"signature"}},
+		{"Chickpea Curry", "Chickpeas and spinach in a coconut curry, with rice", 14.00, nil, []string{"vegan", "gluten-free"}, []string{"spicy"}},
+		{"Caesar Salad", "Romaine, parmesan, croutons and anchovy dressing", 11.00, []string{"gluten", "milk", "egg", "fish"}, nil, nil},
+		{"Shrimp Tacos", "Three tacos with chipotle slaw and pickled onion", 16.50, []string{"crustacean", "egg"}, nil, []string{"spicy"}},
+	}},
+	{"Drinks", "Beverages", []seedFood{
+		{"Espresso", "A double shot of our house blend", 3.00, nil, []string{"vegan", "gluten-free"}, nil},
+		{"Flat White", "Double espresso with steamed milk", 4.50, []string{"milk"}, []string{"vegetarian", "gluten-free"}, nil},
+		{"Fresh Lemonade", "Lemons, cane sugar and mint", 4.00, nil, []string{"vegan", "gluten-free"}, nil},
+		{"Craft IPA", "Local IPA on draught, pint", 7.00, []string{"gluten"}, []string{"vegan"}, []string{"alcohol"}},
+	}},
+}
@@ -0,0 +1,286 @@
+// Command seed fills an empty database with a working sample restaurant:
+// staff accounts, tables, published menus with their foods and a day of
+// paid orders. It is meant for development and demo environments and
+// refuses to touch a database that already has menus unless -force is
+// given.
+//
+//	JWT_SECRET=dev go run ./cmd/seed -orders 60 -date 2024-05-01
+//
+// It reads the same environment as the server, applies the migrations
+// first and signs every account in with -password.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"math/rand"
+	"time"
+
+	"restaurant-management/config"
+	controller "restaurant-management/controllers"
+	"restaurant-management/database"
+	"restaurant-management/database/migrations"
+	"restaurant-management/models"
+	"restaurant-management/services"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func main() {
+	force := flag.Bool("force", false, "seed even if the database already has menus")
+	password := flag.String("password", "password123", "password of every seeded account")
+	orderCount := flag.Int("orders", 40, "number of orders to create")
+	date := flag.String("date", time.Now().AddDate(0, 0, -1).Format("2006-01-02"), "day the orders are placed on, YYYY-MM-DD")
+	randomSeed := flag.Int64("seed", 1, "seed for the random order mix, for repeatable data")
+	flag.Parse()
+
+	day, err := time.ParseInLocation("2006-01-02", *date, time.Local)
+	if err != nil {
+		log.Fatal("Invalid -date: ", err)
+	}
+
+	cfg := config.Get()
+	client, err := database.Connect(cfg)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer client.Disconnect(context.Background())
+	services.Bind(client)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+	db := client.Database(cfg.DBName)
+
+	if _, err := migrations.Run(ctx, db); err != nil {
+		log.Fatal("Error migrating database: ", err)
+	}
+	if !*force {
+		count, err := db.Collection("menu").CountDocuments(ctx, bson.M{})
+		if err != nil {
+			log.Fatal("Error checking for existing data: ", err)
+		}
+		if count > 0 {
+			log.Fatal("The database already has menus; pass -force to seed it anyway")
+		}
+	}
+
+	users, err := seedStaff(ctx, db, *password)
+	if err != nil {
+		log.Fatal("Error seeding users: ", err)
+	}
+	tables, err := seedTableLayout(ctx, db)
+	if err != nil {
+		log.Fatal("Error seeding tables: ", err)
+	}
+	foods, err := seedMenuCatalogue(ctx, users["ADMIN"][0])
+	if err != nil {
+		log.Fatal("Error seeding menus: ", err)
+	}
+	orders, err := seedOrders(ctx, db, rand.New(rand.NewSource(*randomSeed)), day, *orderCount, tables, foods, users["WAITER"])
+	if err != nil {
+		log.Fatal("Error seeding orders: ", err)
+	}
+
+	fmt.Printf("Seeded %d users, %d tables, %d menus with %d foods and %d orders on %s\n",
+		len(seedUsers), len(tables), len(seedMenus), len(foods), orders, day.Format("2006-01-02"))
+	for _, user := range seedUsers {
+		fmt.Printf("  %-8s %s / %s\n", user.role, user.email, *password)
+	}
+}
+
+// seedStaff creates the seed accounts and returns their ids by role.
+func seedStaff(ctx context.Context, db *mongo.Database, password string) (map[string][]string, error) {
+	hashed, err := controller.HashPassword(password)
+	if err != nil {
+		return nil, err
+	}
+	now := services.Now()
+	ids := map[string][]string{}
+	for _, seed := range seedUsers {
+		seed := seed
+		user := models.User{
+			ID:         primitive.NewObjectID(),
+			First_name: &seed.first,
+			Last_name:  &seed.last,
+			Password:   &hashed,
+			Email:      &seed.email,
+			Phone:      &seed.phone,
+			Role:       &seed.role,
+			Created_at: now,
+			Updated_at: now,
+		}
+		user.User_id = user.ID.Hex()
+		if _, err := db.Collection("user").InsertOne(ctx, user); err != nil {
+			return nil, fmt.Errorf("%s: %w", seed.email, err)
+		}
+		ids[seed.role] = append(ids[seed.role], user.User_id)
+	}
+	return ids, nil
+}
+
+// seedTableLayout creates the seed tables, free, and returns them.
+func seedTableLayout(ctx context.Context, db *mongo.Database) ([]models.Table, error) {
+	now := services.Now()
+	location := "default"
+	tables := []models.Table{}
+	for _, seed := range seedTables {
+		seed := seed
+		table := models.Table{
+			ID:               primitive.NewObjectID(),
+			Number_of_guests: &seed.guests,
+			Table_number:     &seed.number,
+			Location:         &location,
+			Section:          &seed.section,
+			Status:           "FREE",
+			Created_at:       now,
+			Updated_at:       now,
+		}
+		table.Table_id = table.ID.Hex()
+		if _, err := db.Collection("table").InsertOne(ctx, table); err != nil {
+			return nil, fmt.Errorf("table %d: %w", seed.number, err)
+		}
+		tables = append(tables, table)
+	}
+	return tables, nil
+}
+
+// seedMenuCatalogue creates the seed menus and foods through the services,
+// as the API would, publishes each menu and returns the foods.
+func seedMenuCatalogue(ctx context.Context, publishedBy string) ([]models.Food, error) {
+	available := true
+	foods := []models.Food{}
+	for _, seed := range seedMenus {
+		menu := models.Menu{Name: seed.name, Category: seed.category}
+		if _, err := services.CreateMenu(ctx, &menu); err != nil {
+			return nil, fmt.Errorf("menu %s: %w", seed.name, err)
+		}
+
+		menuFoods := []models.Food{}
+		for _, item := range seed.foods {
+			item := item
+			image := "https://images.example.com/foods/" + slug(item.name) + ".jpg"
+			food := models.Food{
+				Name:          &item.name,
+				Description:   &item.description,
+				Price:         &item.price,
+				Food_image:    &image,
+				Menu_id:       &menu.Menu_id,
+				Tags:          item.tags,
+				Available:     &available,
+				Allergens:     item.allergens,
+				Dietary_flags: item.diets,
+			}
+			if _, err := services.CreateFood(ctx, &food); err != nil {
+				return nil, fmt.Errorf("food %s: %w", item.name, err)
+			}
+			menuFoods = append(menuFoods, food)
+		}
+
+		if _, err := services.PublishMenuVersion(ctx, menu, menuFoods, nil, publishedBy); err != nil {
+			return nil, fmt.Errorf("publishing menu %s: %w", seed.name, err)
+		}
+		foods = append(foods, menuFoods...)
+	}
+	return foods, nil
+}
+
+// seedOrders places count dine-in orders between 11:00 and 22:00 on day,
+// each of one to five foods, and settles them with paid invoices.
+func seedOrders(ctx context.Context, db *mongo.Database, r *rand.Rand, day time.Time, count int, tables []models.Table, foods []models.Food, servers []string) (int, error) {
+	opening := day.Add(11 * time.Hour)
+	service := 11 * time.Hour
+	channel := "DINE_IN"
+	paid := "PAID"
+	sizes := []string{"S", "M", "M", "M", "L"}
+	methods := []string{"CARD", "CARD", "CASH"}
+
+	for i := 0; i < count; i++ {
+		placed := opening.Add(time.Duration(r.Int63n(int64(service)))).Truncate(time.Second)
+		table := tables[r.Intn(len(tables))]
+		server := servers[r.Intn(len(servers))]
+		partySize := 1 + r.Intn(*table.Number_of_guests)
+
+		order := models.Order{
+			ID:         primitive.NewObjectID(),
+			Order_Date: placed,
+			Created_at: placed,
+			Updated_at: placed,
+			Table_id:   &table.Table_id,
+			Channel:    &channel,
+			Fees:       []models.PriceAdjustment{},
+			Server_id:  &server,
+			Opened_by:  &server,
+			Party_size: &partySize,
+			Status:     "OPEN",
+		}
+		order.Order_id = order.ID.Hex()
+
+		total := 0.0
+		items := []interface{}{}
+		for n := 1 + r.Intn(5); n > 0; n-- {
+			food := foods[r.Intn(len(foods))]
+			size := sizes[r.Intn(len(sizes))]
+			price := *food.Price
+			item := models.OrderItem{
+				ID:         primitive.NewObjectID(),
+				Quantity:   &size,
+				Unit_price: &price,
+				Created_at: placed,
+				Updated_at: placed,
+				Food_id:    &food.Food_id,
+				Order_id:   order.Order_id,
+				Server_id:  &server,
+			}
+			item.Order_item_id = item.ID.Hex()
+			items = append(items, item)
+			total += price
+		}
+		total = services.ToFixed(total, 2)
+
+		settled := placed.Add(time.Duration(30+r.Intn(60)) * time.Minute)
+		method := methods[r.Intn(len(methods))]
+		invoice := models.Invoice{
+			ID:               primitive.NewObjectID(),
+			Order_id:         order.Order_id,
+			Payment_method:   &method,
+			Payment_status:   &paid,
+			Payment_due:      &total,
+			Payment_due_date: settled,
+			Location:         table.Location,
+			Created_at:       settled,
+			Updated_at:       settled,
+		}
+		invoice.Invoice_id = invoice.ID.Hex()
+
+		if _, err := db.Collection("order").InsertOne(ctx, order); err != nil {
+			return i, err
+		}
+		if _, err := db.Collection("orderItem").InsertMany(ctx, items); err != nil {
+			return i, err
+		}
+		if _, err := db.Collection("invoice").InsertOne(ctx, invoice); err != nil {
+			return i, err
+		}
+	}
+	return count, nil
+}
+
+// slug makes a food name into a file name, such as "eggs-benedict".
+func slug(name string) string {
+	out := []rune{}
+	for _, r := range name {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9':
+			out = append(out, r)
+		case r >= 'A' && r <= 'Z':
+			out = append(out, r-'A'+'a')
+		case len(out) > 0 && out[len(out)-1] != '-':
+			out = append(out, '-')
+		}
+	}
+	return string(out)
+}
@@ -0,0 +1,197 @@
+// Package config holds the settings the server needs before it can start,
+// read from the environment:
+//
+//	MONGODB_URI         connection string (mongodb://localhost:27017)
+//	DB_NAME             database name (restaurant)
+//	PORT                port to listen on (8000)
+//	JWT_SECRET          key signing access and refresh tokens; required.
+//	                    SECRET_KEY is read when it is not set.
+//	DB_CONNECT_TIMEOUT  how long to wait for MongoDB at startup (10s)
+//	REQUEST_TIMEOUT     how long a request may spend on the database (100s)
+//	SHUTDOWN_TIMEOUT    how long to wait for requests and jobs to finish
+//	                    when stopping (30s)
+//	MIGRATE_ON_START    whether to apply database migrations when
+//	                    starting (true)
+//	APP_ENV             development or production; development allows
+//	                    stand-ins such as the sandbox payment gateway
+//	                    (production)
+//	CORS_ALLOWED_ORIGINS  origins browsers may call the API from, such as
+//	                      https://waiter.example.com, or * for any (none)
+//	CORS_ALLOWED_METHODS  methods they may use (GET,POST,PUT,PATCH,DELETE)
+//	CORS_ALLOWED_HEADERS  request headers they may send
+//	                      (Authorization,Content-Type,Idempotency-Key,token)
+//	PUBLIC_BASE_URL     address guests reach the API at, such as
+//	                    https://api.example.com, for links sent to them and
+//	                    printed on table QR codes (none)
+//
+// Timeouts are Go durations such as "30s" or "2m", switches are true or
+// false, and lists are comma separated. Feature-specific
+// settings, such as the payment gateway's keys, stay with the code that
+// uses them.
+package config
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+type Config struct {
+	MongoURI        string
+	DBName          string
+	Port            string
+	JWTSecret       string
+	ConnectTimeout  time.Duration
+	RequestTimeout  time.Duration
+	ShutdownTimeout time.Duration
+	MigrateOnStart  bool
+	Env             string
+	PublicBaseURL   string
+	CORS            CORS
+}
+
+// CORS is which cross-origin browser requests the API accepts.
+type CORS struct {
+	AllowedOrigins []string
+	AllowedMethods []string
+	AllowedHeaders []string
+}
+
+var (
+	loaded  sync.Once
+	current *Config
+)
+
+// Get returns the configuration, loading it on first use. An invalid
+// configuration stops the server.
+func Get() *Config {
+	loaded.Do(func() {
+		cfg, err := Load()
+		if err != nil {
+			log.Fatal("Invalid configuration: ", err)
+		}
+		current = cfg
+	})
+	return current
+}
+
+// Load reads the configuration from the environment and checks it,
+// reporting every problem at once.
+func Load() (*Config, error) {
+	var problems []string
+	cfg := &Config{
+		MongoURI:  envOr("MONGODB_URI", "mongodb://localhost:27017"),
+		DBName:    envOr("DB_NAME", "restaurant"),
+		Port:      envOr("PORT", "8000"),
+		JWTSecret: envOr("JWT_SECRET", os.Getenv("SECRET_KEY")),
+		Env:       strings.ToLower(envOr("APP_ENV", "production")),
+	}
+	cfg.PublicBaseURL = strings.TrimRight(os.Getenv("PUBLIC_BASE_URL"), "/")
+
+	if !strings.HasPrefix(cfg.MongoURI, "mongodb://") && !strings.HasPrefix(cfg.MongoURI, "mongodb+srv://") {
+		problems = append(problems, "MONGODB_URI must start with mongodb:// or mongodb+srv://")
+	}
+	if strings.ContainsAny(cfg.DBName, `/\. "$`) || len(cfg.DBName) > 63 {
+		problems = append(problems, "DB_NAME must be at most 63 characters, without /\\. \"$ or spaces")
+	}
+	if port, err := strconv.Atoi(cfg.Port); err != nil || port < 1 || port > 65535 {
+		problems = append(problems, "PORT must be a number between 1 and 65535")
+	}
+	if cfg.JWTSecret == "" {
+		problems = append(problems, "JWT_SECRET is not set")
+	}
+	if cfg.Env != "development" && cfg.Env != "production" {
+		problems = append(problems, "APP_ENV must be development or production")
+	}
+	if cfg.PublicBaseURL != "" && !strings.HasPrefix(cfg.PublicBaseURL, "http://") && !strings.HasPrefix(cfg.PublicBaseURL, "https://") {
+		problems = append(problems, "PUBLIC_BASE_URL must start with http:// or https://")
+	}
+
+	cfg.CORS = CORS{
+		AllowedOrigins: listEnv("CORS_ALLOWED_ORIGINS", ""),
+		AllowedMethods: listEnv("CORS_ALLOWED_METHODS", "GET,POST,PUT,PATCH,DELETE"),
+		AllowedHeaders: listEnv("CORS_ALLOWED_HEADERS", "Authorization,Content-Type,Idempotency-Key,token"),
+	}
+	for _, origin := range cfg.CORS.AllowedOrigins {
+		if origin != "*" && (!strings.HasPrefix(origin, "http://") && !strings.HasPrefix(origin, "https://") || strings.HasSuffix(origin, "/")) {
+			problems = append(problems, "CORS_ALLOWED_ORIGINS must be * or origins such as https://waiter.example.com, without a trailing slash")
+			break
+		}
+	}
+	for i, method := range cfg.CORS.AllowedMethods {
+		cfg.CORS.AllowedMethods[i] = strings.ToUpper(method)
+	}
+
+	var err error
+	if cfg.ConnectTimeout, err = durationEnv("DB_CONNECT_TIMEOUT", 10*time.Second); err != nil {
+		problems = append(problems, err.Error())
+	}
+	if cfg.RequestTimeout, err = durationEnv("REQUEST_TIMEOUT", 100*time.Second); err != nil {
+		problems = append(problems, err.Error())
+	}
+	if cfg.ShutdownTimeout, err = durationEnv("SHUTDOWN_TIMEOUT", 30*time.Second); err != nil {
+		problems = append(problems, err.Error())
+	}
+
+	if cfg.MigrateOnStart, err = boolEnv("MIGRATE_ON_START", true); err != nil {
+		problems = append(problems, err.Error())
+	}
+
+	if len(problems) > 0 {
+		return nil, errors.New(strings.Join(problems, "; "))
+	}
+	return cfg, nil
+}
+
+// Development reports whether the server runs in a development
+// environment.
+func (c *Config) Development() bool {
+	return c.Env == "development"
+}
+
+func envOr(name, fallback string) string {
+	if value := os.Getenv(name); value != "" {
+		return value
+	}
+	return fallback
+}
+
+// listEnv splits a comma separated variable, dropping empty entries.
+func listEnv(name, fallback string) []string {
+	values := []string{}
+	for _, value := range strings.Split(envOr(name, fallback), ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
+func durationEnv(name string, fallback time.Duration) (time.Duration, error) {
+	value := os.Getenv(name)
+	if value == "" {
+		return fallback, nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("%s must be a positive duration such as 30s", name)
+	}
+	return d, nil
+}
+
+func boolEnv(name string, fallback bool) (bool, error) {
+	value := os.Getenv(name)
+	if value == "" {
+		return fallback, nil
+	}
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, fmt.Errorf("%s must be true or false", name)
+	}
+	return b, nil
+}
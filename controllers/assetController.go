@@ -0,0 +1,300 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"restaurant-management/apierror"
+	"restaurant-management/config"
+	"restaurant-management/models"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var assetCollection = db.Collection("asset")
+var assetReminderCollection = db.Collection("assetReminder")
+
+func GetAssets() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		filter := bson.M{}
+		for _, key := range []string{"type", "location", "status"} {
+			if value := c.Query(key); value != "" {
+				filter[key] = value
+			}
+		}
+
+		result, err := assetCollection().Find(ctx, filter)
+		if err != nil {
+			apierror.Render(c, apierror.Internal("error occurred while listing assets: "+err.Error()))
+			return
+		}
+
+		var allAssets []bson.M
+		if err = result.All(ctx, &allAssets); err != nil {
+			apierror.Render(c, apierror.Internal("error decoding assets: "+err.Error()))
+			return
+		}
+
+		c.JSON(http.StatusOK, allAssets)
+	}
+}
+
+func GetAsset() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		var asset models.Asset
+		if err := assetCollection().FindOne(ctx, bson.M{"asset_id": c.Param("asset_id")}).Decode(&asset); err != nil {
+			apierror.Render(c, apierror.NotFound("Asset not found"))
+			return
+		}
+
+		c.JSON(http.StatusOK, asset)
+	}
+}
+
+func CreateAsset() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		var asset models.Asset
+		if err := c.BindJSON(&asset); err != nil {
+			apierror.Render(c, apierror.BadRequest("Invalid request data: "+err.Error()))
+			return
+		}
+		if err := validate.Struct(asset); err != nil {
+			apierror.Render(c, apierror.FromValidation(err))
+			return
+		}
+
+		count, err := assetCollection().CountDocuments(ctx, bson.M{"serial_number": asset.Serial_number, "manufacturer": asset.Manufacturer})
+		if err != nil {
+			apierror.Render(c, apierror.Internal("error checking serial number: "+err.Error()))
+			return
+		}
+		if count > 0 {
+			apierror.Render(c, apierror.Conflict("An asset with this serial number is already registered"))
+			return
+		}
+
+		if asset.Location == nil {
+			location := defaultLocation
+			asset.Location = &location
+		}
+		asset.Status = "ACTIVE"
+		asset.Created_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		asset.Updated_at = asset.Created_at
+		asset.ID = primitive.NewObjectID()
+		asset.Asset_id = asset.ID.Hex()
+
+		result, err := assetCollection().InsertOne(ctx, asset)
+		if err != nil {
+			apierror.Render(c, apierror.Internal("Asset was not created: "+err.Error()))
+			return
+		}
+
+		c.JSON(http.StatusCreated, gin.H{"message": "Asset created", "data": result, "asset_id": asset.Asset_id})
+	}
+}
+
+func UpdateAsset() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		var body struct {
+			Name             *string    `json:"name" validate:"omitempty,min=2,max=100"`
+			Location         *string    `json:"location"`
+			Model            *string    `json:"model"`
+			Warranty_expires *time.Time `json:"warranty_expires"`
+			Status           *string    `json:"status" validate:"omitempty,eq=ACTIVE|eq=RETIRED"`
+		}
+		if err := c.BindJSON(&body); err != nil {
+			apierror.Render(c, apierror.BadRequest("Invalid request data: "+err.Error()))
+			return
+		}
+		if err := validate.Struct(body); err != nil {
+			apierror.Render(c, apierror.FromValidation(err))
+			return
+		}
+
+		var updateObj primitive.D
+		if body.Name != nil {
+			updateObj = append(updateObj, bson.E{Key: "name", Value: body.Name})
+		}
+		if body.Location != nil {
+			updateObj = append(updateObj, bson.E{Key: "location", Value: body.Location})
+		}
+		if body.Model != nil {
+			updateObj = append(updateObj, bson.E{Key: "model", Value: body.Model})
+		}
+		if body.Warranty_expires != nil {
+			updateObj = append(updateObj, bson.E{Key: "warranty_expires", Value: body.Warranty_expires})
+		}
+		if body.Status != nil {
+			updateObj = append(updateObj, bson.E{Key: "status", Value: body.Status})
+		}
+
+		updatedAt, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		updateObj = append(updateObj, bson.E{Key: "updated_at", Value: updatedAt})
+
+		result, err := assetCollection().UpdateOne(ctx, bson.M{"asset_id": c.Param("asset_id")}, bson.D{{Key: "$set", Value: updateObj}})
+		if err != nil {
+			apierror.Render(c, apierror.Internal("Update failed: "+err.Error()))
+			return
+		}
+		if result.MatchedCount == 0 {
+			apierror.Render(c, apierror.NotFound("Asset not found"))
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Asset updated successfully", "result": result})
+	}
+}
+
+// GetAssetHistory returns the maintenance tickets raised against an asset
+// with its lifetime downtime.
+func GetAssetHistory() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		var asset models.Asset
+		if err := assetCollection().FindOne(ctx, bson.M{"asset_id": c.Param("asset_id")}).Decode(&asset); err != nil {
+			apierror.Render(c, apierror.NotFound("Asset not found"))
+			return
+		}
+
+		opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}})
+		cursor, err := ticketCollection().Find(ctx, bson.M{"equipment_id": asset.Asset_id}, opts)
+		if err != nil {
+			apierror.Render(c, apierror.Internal("error occurred while listing tickets: "+err.Error()))
+			return
+		}
+		var tickets []bson.M
+		if err = cursor.All(ctx, &tickets); err != nil {
+			apierror.Render(c, apierror.Internal("error decoding tickets: "+err.Error()))
+			return
+		}
+
+		downtime, err := equipmentDowntime(ctx, bson.M{"equipment_id": asset.Asset_id}, asset.Created_at, time.Now())
+		if err != nil {
+			apierror.Render(c, apierror.Internal("error computing downtime: "+err.Error()))
+			return
+		}
+		downtimeHours := 0.0
+		if len(downtime) > 0 {
+			downtimeHours = downtime[0].Downtime_hours
+		}
+
+		c.JSON(http.StatusOK, gin.H{"asset": asset, "tickets": tickets, "downtime_hours": downtimeHours})
+	}
+}
+
+func GetAssetReminders() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		opts := options.Find().SetSort(bson.D{{Key: "warranty_expires", Value: 1}})
+		result, err := assetReminderCollection().Find(ctx, bson.M{"acknowledged": c.Query("acknowledged") == "true"}, opts)
+		if err != nil {
+			apierror.Render(c, apierror.Internal("error occurred while listing reminders: "+err.Error()))
+			return
+		}
+
+		var allReminders []bson.M
+		if err = result.All(ctx, &allReminders); err != nil {
+			apierror.Render(c, apierror.Internal("error decoding reminders: "+err.Error()))
+			return
+		}
+
+		c.JSON(http.StatusOK, allReminders)
+	}
+}
+
+func AcknowledgeAssetReminder() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		result, err := assetReminderCollection().UpdateOne(
+			ctx,
+			bson.M{"reminder_id": c.Param("reminder_id")},
+			bson.D{{Key: "$set", Value: bson.D{{Key: "acknowledged", Value: true}}}},
+		)
+		if err != nil {
+			apierror.Render(c, apierror.Internal("Update failed: "+err.Error()))
+			return
+		}
+		if result.MatchedCount == 0 {
+			apierror.Render(c, apierror.NotFound("Reminder not found"))
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Reminder acknowledged"})
+	}
+}
+
+// SendWarrantyReminders raises one reminder per active asset whose warranty
+// ends within WARRANTY_REMINDER_DAYS (default 30). It runs daily on the
+// scheduler.
+func SendWarrantyReminders(ctx context.Context) error {
+	days := 30
+	if value := os.Getenv("WARRANTY_REMINDER_DAYS"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("invalid WARRANTY_REMINDER_DAYS: %s", err.Error())
+		}
+		days = parsed
+	}
+
+	now := time.Now()
+	cursor, err := assetCollection().Find(ctx, bson.M{
+		"status":           "ACTIVE",
+		"warranty_expires": bson.M{"$gte": now, "$lte": now.AddDate(0, 0, days)},
+	})
+	if err != nil {
+		return err
+	}
+	var assets []models.Asset
+	if err = cursor.All(ctx, &assets); err != nil {
+		return err
+	}
+
+	for _, asset := range assets {
+		count, err := assetReminderCollection().CountDocuments(ctx, bson.M{"asset_id": asset.Asset_id, "warranty_expires": asset.Warranty_expires})
+		if err != nil {
+			return err
+		}
+		if count > 0 {
+			continue
+		}
+
+		reminder := models.AssetReminder{
+			ID:               primitive.NewObjectID(),
+			Asset_id:         asset.Asset_id,
+			Warranty_expires: *asset.Warranty_expires,
+			Message:          fmt.Sprintf("Warranty for %s (serial %s) expires on %s", *asset.Name, *asset.Serial_number, asset.Warranty_expires.Format("2006-01-02")),
+		}
+		reminder.Created_at, _ = time.Parse(time.RFC3339, now.Format(time.RFC3339))
+		reminder.Reminder_id = reminder.ID.Hex()
+		if _, err := assetReminderCollection().InsertOne(ctx, reminder); err != nil {
+			return err
+		}
+		log.Printf("warranty reminder: %s", reminder.Message)
+	}
+	return nil
+}
@@ -0,0 +1,209 @@
+package controllers
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"reflect"
+	"restaurant-management/apierror"
+	"restaurant-management/config"
+	"restaurant-management/models"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var auditLogCollection = db.Collection("auditLog")
+
+// auditCreatedKey is where create handlers leave the id of the document
+// they stored, for Audit to find it.
+const auditCreatedKey = "audit_resource_id"
+
+type auditedResource struct {
+	collection func() *mongo.Collection
+	idField    string
+}
+
+// auditedResources are the resources Audit can be put in front of, by
+// name.
+var auditedResources = map[string]auditedResource{
+	"food":           {foodCollection, "food_id"},
+	"menu":           {menuCollection, "menu_id"},
+	"order":          {orderCollection, "order_id"},
+	"invoice":        {invoiceCollection, "invoice_id"},
+	"table":          {tableCollection, "table_id"},
+	"category":       {categoryCollection, "category_id"},
+	"combo":          {comboCollection, "combo_id"},
+	"modifier_group": {modifierGroupCollection, "modifier_group_id"},
+}
+
+// auditIgnoredFields change on every write and say nothing about it.
+var auditIgnoredFields = map[string]bool{"_id": true, "updated_at": true}
+
+// Audit records the writes made by the handlers after it to a resource in
+// the audit log. The resource is read before and after the handler runs,
+// and only the fields that differ are kept. Failed requests and writes
+// that changed nothing are not recorded.
+func Audit(resource string) gin.HandlerFunc {
+	audited, ok := auditedResources[resource]
+	if !ok {
+		panic("controllers: no audited resource named " + resource)
+	}
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		resourceId := c.Param(audited.idField)
+		var before bson.M
+		if resourceId != "" {
+			before = auditSnapshot(ctx, audited, resourceId)
+		}
+
+		c.Next()
+
+		if c.Writer.Status() >= http.StatusMultipleChoices {
+			return
+		}
+		if resourceId == "" {
+			resourceId = c.GetString(auditCreatedKey)
+		}
+		if resourceId == "" {
+			return
+		}
+		after := auditSnapshot(ctx, audited, resourceId)
+		changes := auditDiff(before, after)
+		if len(changes) == 0 {
+			return
+		}
+
+		entry := models.AuditLog{
+			ID:          primitive.NewObjectID(),
+			Actor_id:    c.GetString("uid"),
+			Actor_email: c.GetString("email"),
+			Actor_role:  c.GetString("role"),
+			Method:      c.Request.Method,
+			Endpoint:    c.FullPath(),
+			Resource:    resource,
+			Resource_id: resourceId,
+			Action:      auditAction(before, after),
+			Changes:     changes,
+		}
+		entry.Created_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		entry.Audit_log_id = entry.ID.Hex()
+		if _, err := auditLogCollection().InsertOne(ctx, entry); err != nil {
+			log.Println("Error recording audit log:", err)
+		}
+	}
+}
+
+// auditCreated tells Audit the id of the document a create handler stored.
+func auditCreated(c *gin.Context, resourceId string) {
+	c.Set(auditCreatedKey, resourceId)
+}
+
+func auditSnapshot(ctx context.Context, audited auditedResource, resourceId string) bson.M {
+	var doc bson.M
+	if err := audited.collection().FindOne(ctx, bson.M{audited.idField: resourceId}).Decode(&doc); err != nil {
+		return nil
+	}
+	return doc
+}
+
+// auditDiff lists the fields whose values differ between two snapshots,
+// either of which may be nil.
+func auditDiff(before, after bson.M) map[string]models.AuditChange {
+	changes := map[string]models.AuditChange{}
+	for field, value := range after {
+		if !auditIgnoredFields[field] && !reflect.DeepEqual(before[field], value) {
+			changes[field] = models.AuditChange{Before: before[field], After: value}
+		}
+	}
+	for field, value := range before {
+		if _, ok := after[field]; !ok && !auditIgnoredFields[field] {
+			changes[field] = models.AuditChange{Before: value}
+		}
+	}
+	return changes
+}
+
+func auditAction(before, after bson.M) string {
+	switch {
+	case before == nil:
+		return "CREATE"
+	case after == nil:
+		return "DELETE"
+	case before["deleted_at"] == nil && after["deleted_at"] != nil:
+		return "DELETE"
+	case before["deleted_at"] != nil && after["deleted_at"] == nil:
+		return "RESTORE"
+	}
+	return "UPDATE"
+}
+
+// GetAuditLogs pages through the audit log, newest first, optionally of
+// one ?actor_id=, ?resource=, ?resource_id= or ?action=, between ?from=
+// and ?to= (RFC 3339).
+func GetAuditLogs() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		recordsPerPage, err := strconv.Atoi(c.Query("recordsPerPage"))
+		if err != nil || recordsPerPage < 1 {
+			recordsPerPage = 50
+		}
+		page, err := strconv.Atoi(c.Query("page"))
+		if err != nil || page < 1 {
+			page = 1
+		}
+
+		filter := bson.M{}
+		for _, param := range []string{"actor_id", "resource", "resource_id", "action"} {
+			if value := c.Query(param); value != "" {
+				filter[param] = value
+			}
+		}
+		createdAt := bson.M{}
+		for param, operator := range map[string]string{"from": "$gte", "to": "$lte"} {
+			if value := c.Query(param); value != "" {
+				t, err := time.Parse(time.RFC3339, value)
+				if err != nil {
+					apierror.Render(c, apierror.BadRequest(param+" must be an RFC 3339 time"))
+					return
+				}
+				createdAt[operator] = t
+			}
+		}
+		if len(createdAt) > 0 {
+			filter["created_at"] = createdAt
+		}
+
+		opts := options.Find().
+			SetSort(bson.D{{Key: "created_at", Value: -1}, {Key: "_id", Value: -1}}).
+			SetSkip(int64((page - 1) * recordsPerPage)).
+			SetLimit(int64(recordsPerPage))
+		result, err := auditLogCollection().Find(ctx, filter, opts)
+		if err != nil {
+			apierror.Render(c, apierror.Internal("error occurred while listing audit logs: "+err.Error()))
+			return
+		}
+
+		allLogs := []bson.M{}
+		if err = result.All(ctx, &allLogs); err != nil {
+			apierror.Render(c, apierror.Internal("error decoding audit logs: "+err.Error()))
+			return
+		}
+		totalCount, err := auditLogCollection().CountDocuments(ctx, filter)
+		if err != nil {
+			apierror.Render(c, apierror.Internal("error counting audit logs: "+err.Error()))
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"total_count": totalCount, "page": page, "records_per_page": recordsPerPage, "data": allLogs})
+	}
+}
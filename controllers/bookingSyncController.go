@@ -0,0 +1,511 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"restaurant-management/apierror"
+	"restaurant-management/bookings"
+	"restaurant-management/config"
+	"restaurant-management/models"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var bookingConnectorCollection = db.Collection("bookingConnector")
+var reservationConflictCollection = db.Collection("reservationConflict")
+
+const defaultSyncDays = 30
+
+// BookingSyncResult summarizes one sync run of a connector.
+type BookingSyncResult struct {
+	Fetched      int `json:"fetched"`
+	Imported     int `json:"imported"`
+	Updated      int `json:"updated"`
+	Cancelled    int `json:"cancelled"`
+	Conflicts    int `json:"conflicts"`
+	Slots_pushed int `json:"slots_pushed"`
+}
+
+func GetBookingConnectors() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		// Never echo stored API keys back to clients
+		opts := options.Find().SetProjection(bson.M{"api_key": 0})
+		result, err := bookingConnectorCollection().Find(ctx, bson.M{}, opts)
+		if err != nil {
+			apierror.Render(c, apierror.Internal("error occurred while listing booking connectors: "+err.Error()))
+			return
+		}
+
+		var allConnectors []bson.M
+		if err = result.All(ctx, &allConnectors); err != nil {
+			apierror.Render(c, apierror.Internal("error decoding booking connectors: "+err.Error()))
+			return
+		}
+
+		c.JSON(http.StatusOK, allConnectors)
+	}
+}
+
+func CreateBookingConnector() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		var connector models.BookingConnector
+		if err := c.BindJSON(&connector); err != nil {
+			apierror.Render(c, apierror.BadRequest("Invalid request data: "+err.Error()))
+			return
+		}
+		if err := validate.Struct(connector); err != nil {
+			apierror.Render(c, apierror.FromValidation(err))
+			return
+		}
+		if connector.Api_key == "" {
+			apierror.Render(c, apierror.Validation("Validation failed: api_key is required"))
+			return
+		}
+
+		if connector.Location == nil {
+			location := defaultLocation
+			connector.Location = &location
+		}
+		if connector.Conflict_policy == nil {
+			policy := "REJECT"
+			connector.Conflict_policy = &policy
+		}
+		if connector.Active == nil {
+			active := true
+			connector.Active = &active
+		}
+		connector.Last_synced_at = nil
+		connector.Last_error = ""
+		connector.Created_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		connector.Updated_at = connector.Created_at
+		connector.ID = primitive.NewObjectID()
+		connector.Connector_id = connector.ID.Hex()
+
+		if _, err := bookingConnectorCollection().InsertOne(ctx, connector); err != nil {
+			apierror.Render(c, apierror.Internal("Could not create booking connector"))
+			return
+		}
+
+		c.JSON(http.StatusCreated, gin.H{"message": "Booking connector created", "connector_id": connector.Connector_id})
+	}
+}
+
+func UpdateBookingConnector() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		var connector models.BookingConnector
+		if err := c.BindJSON(&connector); err != nil {
+			apierror.Render(c, apierror.BadRequest("Invalid request data: "+err.Error()))
+			return
+		}
+
+		var updateObj primitive.D
+		if connector.Name != nil {
+			updateObj = append(updateObj, bson.E{Key: "name", Value: connector.Name})
+		}
+		if connector.Api_url != nil {
+			if err := validate.Var(*connector.Api_url, "url"); err != nil {
+				apierror.Render(c, apierror.Validation("Validation failed: api_url must be a URL"))
+				return
+			}
+			updateObj = append(updateObj, bson.E{Key: "api_url", Value: connector.Api_url})
+		}
+		if connector.Api_key != "" {
+			updateObj = append(updateObj, bson.E{Key: "api_key", Value: connector.Api_key})
+		}
+		if connector.Venue_id != nil {
+			updateObj = append(updateObj, bson.E{Key: "venue_id", Value: connector.Venue_id})
+		}
+		if connector.Conflict_policy != nil {
+			if err := validate.Var(*connector.Conflict_policy, "oneof=REJECT OVERBOOK"); err != nil {
+				apierror.Render(c, apierror.Validation("Validation failed: conflict_policy must be REJECT or OVERBOOK"))
+				return
+			}
+			updateObj = append(updateObj, bson.E{Key: "conflict_policy", Value: connector.Conflict_policy})
+		}
+		if connector.Sync_days != nil {
+			if err := validate.Var(*connector.Sync_days, "gt=0,lte=180"); err != nil {
+				apierror.Render(c, apierror.Validation("Validation failed: sync_days must be between 1 and 180"))
+				return
+			}
+			updateObj = append(updateObj, bson.E{Key: "sync_days", Value: connector.Sync_days})
+		}
+		if connector.Active != nil {
+			updateObj = append(updateObj, bson.E{Key: "active", Value: connector.Active})
+		}
+
+		updatedAt, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		updateObj = append(updateObj, bson.E{Key: "updated_at", Value: updatedAt})
+
+		result, err := bookingConnectorCollection().UpdateOne(ctx, bson.M{"connector_id": c.Param("connector_id")}, bson.D{{Key: "$set", Value: updateObj}})
+		if err != nil {
+			apierror.Render(c, apierror.Internal("Update failed: "+err.Error()))
+			return
+		}
+		if result.MatchedCount == 0 {
+			apierror.Render(c, apierror.NotFound("Booking connector not found"))
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Booking connector updated successfully"})
+	}
+}
+
+// SyncBookingConnector runs a sync of one connector now instead of waiting
+// for the scheduler.
+func SyncBookingConnector() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		var connector models.BookingConnector
+		if err := bookingConnectorCollection().FindOne(ctx, bson.M{"connector_id": c.Param("connector_id")}).Decode(&connector); err != nil {
+			apierror.Render(c, apierror.NotFound("Booking connector not found"))
+			return
+		}
+
+		result, err := syncBookingConnector(ctx, connector)
+		if err != nil {
+			apierror.Render(c, apierror.UpstreamFailed("Sync failed: "+err.Error()).With("result", result))
+			return
+		}
+
+		c.JSON(http.StatusOK, result)
+	}
+}
+
+// GetReservationConflicts lists imported bookings that collided with
+// bookings already held. ?open=true leaves out reviewed ones.
+func GetReservationConflicts() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		filter := bson.M{}
+		for _, key := range []string{"location", "connector_id", "resolution"} {
+			if value := c.Query(key); value != "" {
+				filter[key] = value
+			}
+		}
+		if c.Query("open") == "true" {
+			filter["reviewed_at"] = nil
+		}
+
+		opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}})
+		result, err := reservationConflictCollection().Find(ctx, filter, opts)
+		if err != nil {
+			apierror.Render(c, apierror.Internal("error occurred while listing reservation conflicts: "+err.Error()))
+			return
+		}
+
+		var allConflicts []bson.M
+		if err = result.All(ctx, &allConflicts); err != nil {
+			apierror.Render(c, apierror.Internal("error decoding reservation conflicts: "+err.Error()))
+			return
+		}
+
+		c.JSON(http.StatusOK, allConflicts)
+	}
+}
+
+// ReviewReservationConflict marks a conflict as handled by staff.
+func ReviewReservationConflict() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		var body struct {
+			Reviewed_by *string `json:"reviewed_by" validate:"required"`
+		}
+		if err := c.BindJSON(&body); err != nil {
+			apierror.Render(c, apierror.BadRequest("Invalid request data: "+err.Error()))
+			return
+		}
+		if err := validate.Struct(body); err != nil {
+			apierror.Render(c, apierror.FromValidation(err))
+			return
+		}
+
+		reviewedAt, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		result, err := reservationConflictCollection().UpdateOne(
+			ctx,
+			bson.M{"conflict_id": c.Param("conflict_id")},
+			bson.D{{Key: "$set", Value: bson.D{{Key: "reviewed_by", Value: body.Reviewed_by}, {Key: "reviewed_at", Value: reviewedAt}}}},
+		)
+		if err != nil {
+			apierror.Render(c, apierror.Internal("Update failed: "+err.Error()))
+			return
+		}
+		if result.MatchedCount == 0 {
+			apierror.Render(c, apierror.NotFound("Reservation conflict not found"))
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Reservation conflict reviewed"})
+	}
+}
+
+// SyncBookingConnectors is the scheduled job syncing every active
+// connector. A failing connector does not hold up the others.
+func SyncBookingConnectors(ctx context.Context) error {
+	cursor, err := bookingConnectorCollection().Find(ctx, bson.M{"active": true})
+	if err != nil {
+		return err
+	}
+	var connectors []models.BookingConnector
+	if err = cursor.All(ctx, &connectors); err != nil {
+		return err
+	}
+	for _, connector := range connectors {
+		if _, err := syncBookingConnector(ctx, connector); err != nil {
+			log.Println("Error syncing booking connector", connector.Connector_id, ":", err)
+		}
+	}
+	return nil
+}
+
+// syncBookingConnector imports bookings changed on the platform since the
+// last run, then pushes the location's availability back. The sync point
+// only moves forward when both steps succeed.
+func syncBookingConnector(ctx context.Context, connector models.BookingConnector) (BookingSyncResult, error) {
+	startedAt := time.Now()
+	result, err := runBookingSync(ctx, connector)
+
+	updateObj := bson.D{{Key: "last_error", Value: ""}, {Key: "last_synced_at", Value: startedAt}}
+	if err != nil {
+		updateObj = bson.D{{Key: "last_error", Value: err.Error()}}
+	}
+	bookingConnectorCollection().UpdateOne(ctx, bson.M{"connector_id": connector.Connector_id}, bson.D{{Key: "$set", Value: updateObj}})
+	return result, err
+}
+
+func runBookingSync(ctx context.Context, connector models.BookingConnector) (BookingSyncResult, error) {
+	var result BookingSyncResult
+	client := bookingClient(connector)
+	location := stringValue(connector.Location)
+
+	var since time.Time
+	if connector.Last_synced_at != nil {
+		// Overlap runs slightly so clock skew with the platform loses nothing
+		since = connector.Last_synced_at.Add(-time.Minute)
+	}
+	externalBookings, err := client.Bookings(ctx, since)
+	if err != nil {
+		return result, err
+	}
+	result.Fetched = len(externalBookings)
+
+	capacity, err := reservationCapacityFor(ctx, location)
+	if err != nil {
+		return result, err
+	}
+	for _, booking := range externalBookings {
+		if err := importBooking(ctx, connector, client, capacity, booking, &result); err != nil {
+			return result, fmt.Errorf("booking %s: %w", booking.External_id, err)
+		}
+	}
+
+	if capacity == nil {
+		return result, nil
+	}
+	days := defaultSyncDays
+	if connector.Sync_days != nil {
+		days = *connector.Sync_days
+	}
+	slots, err := reservationSlots(ctx, capacity, location, time.Now(), days)
+	if err != nil {
+		return result, err
+	}
+	if err := client.PushAvailability(ctx, slots); err != nil {
+		return result, err
+	}
+	result.Slots_pushed = len(slots)
+	return result, nil
+}
+
+// importBooking applies one platform booking to the reservations. A new
+// booking that no longer fits its slot, because a booking was taken here
+// after availability was last pushed, is a conflict: it is cancelled on the
+// platform under the REJECT policy and kept under OVERBOOK. Changes to
+// bookings already imported are always applied, and recorded as conflicts
+// when they overbook.
+func importBooking(ctx context.Context, connector models.BookingConnector, client bookings.Connector, capacity *models.ReservationCapacity, booking bookings.Booking, result *BookingSyncResult) error {
+	platform := *connector.Platform
+	var existing models.Reservation
+	err := reservationCollection().FindOne(ctx, bson.M{"connector_id": connector.Connector_id, "external_id": booking.External_id}).Decode(&existing)
+	if err != nil && err != mongo.ErrNoDocuments {
+		return err
+	}
+	found := err == nil
+
+	if booking.Status == "CANCELLED" {
+		if !found {
+			return nil
+		}
+		_, _, err := cancelReservation(ctx, existing.Reservation_id, platform)
+		if err == mongo.ErrNoDocuments {
+			return nil
+		}
+		if err == nil {
+			result.Cancelled++
+		}
+		return err
+	}
+	if found && existing.Status != "BOOKED" && existing.Status != "CONFIRMED" {
+		return nil
+	}
+
+	duration := booking.Duration_minutes
+	if duration <= 0 {
+		duration = defaultReservationMinutes
+	}
+	end := booking.Reserved_at.Add(time.Duration(duration) * time.Minute)
+	if found && existing.Reserved_at.Equal(booking.Reserved_at) && reservationEnd(existing).Equal(end) &&
+		*existing.Party_size == booking.Party_size && *existing.Customer_name == booking.Customer_name && stringValue(existing.Notes) == booking.Notes {
+		return nil
+	}
+
+	location := stringValue(connector.Location)
+	available := -1
+	if capacity != nil {
+		available, err = availableCovers(ctx, capacity, location, booking.Reserved_at, end, existing.Reservation_id)
+		if err != nil {
+			return err
+		}
+	}
+	conflict := available >= 0 && booking.Party_size > available
+
+	now, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+	reservationId := existing.Reservation_id
+	if conflict && !found && stringValue(connector.Conflict_policy) != "OVERBOOK" {
+		cancelErr := client.Cancel(ctx, booking.External_id, "The requested time is no longer available")
+		result.Conflicts++
+		return recordReservationConflict(ctx, connector, booking, "", available, "REJECTED", cancelErr)
+	}
+
+	if found {
+		_, err = reservationCollection().UpdateOne(
+			ctx,
+			bson.M{"reservation_id": existing.Reservation_id},
+			bson.D{{Key: "$set", Value: bson.D{
+				{Key: "customer_name", Value: booking.Customer_name},
+				{Key: "party_size", Value: booking.Party_size},
+				{Key: "reserved_at", Value: booking.Reserved_at},
+				{Key: "duration_minutes", Value: duration},
+				{Key: "notes", Value: booking.Notes},
+				{Key: "updated_at", Value: now},
+			}}},
+		)
+		if err != nil {
+			return err
+		}
+		result.Updated++
+	} else {
+		reservation, err := importedReservation(connector, booking, duration, now)
+		if err != nil {
+			return err
+		}
+		if _, err := reservationCollection().InsertOne(ctx, reservation); err != nil {
+			return err
+		}
+		reservationId = reservation.Reservation_id
+		result.Imported++
+	}
+
+	if !conflict {
+		return nil
+	}
+	result.Conflicts++
+	return recordReservationConflict(ctx, connector, booking, reservationId, available, "OVERBOOKED", nil)
+}
+
+func importedReservation(connector models.BookingConnector, booking bookings.Booking, duration int, now time.Time) (models.Reservation, error) {
+	token, err := newToken()
+	if err != nil {
+		return models.Reservation{}, err
+	}
+	reservation := models.Reservation{
+		Location:         connector.Location,
+		Customer_name:    &booking.Customer_name,
+		Party_size:       &booking.Party_size,
+		Reserved_at:      &booking.Reserved_at,
+		Duration_minutes: &duration,
+		Status:           "BOOKED",
+		Source:           *connector.Platform,
+		Connector_id:     connector.Connector_id,
+		External_id:      booking.External_id,
+		Token:            token,
+		Reminders_sent:   []string{},
+		Created_at:       now,
+		Updated_at:       now,
+	}
+	if booking.Customer_phone != "" {
+		reservation.Customer_phone = &booking.Customer_phone
+	}
+	if booking.Customer_email != "" {
+		reservation.Customer_email = &booking.Customer_email
+	}
+	if booking.Notes != "" {
+		reservation.Notes = &booking.Notes
+	}
+	reservation.ID = primitive.NewObjectID()
+	reservation.Reservation_id = reservation.ID.Hex()
+	return reservation, nil
+}
+
+func recordReservationConflict(ctx context.Context, connector models.BookingConnector, booking bookings.Booking, reservationId string, available int, resolution string, resolutionErr error) error {
+	conflict := models.ReservationConflict{
+		Connector_id:   connector.Connector_id,
+		Platform:       *connector.Platform,
+		Location:       stringValue(connector.Location),
+		External_id:    booking.External_id,
+		Reservation_id: reservationId,
+		Customer_name:  booking.Customer_name,
+		Party_size:     booking.Party_size,
+		Reserved_at:    booking.Reserved_at,
+		Available:      available,
+		Resolution:     resolution,
+	}
+	if resolutionErr != nil {
+		conflict.Error = resolutionErr.Error()
+	}
+	conflict.Created_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+	conflict.ID = primitive.NewObjectID()
+	conflict.Conflict_id = conflict.ID.Hex()
+	_, err := reservationConflictCollection().InsertOne(ctx, conflict)
+	return err
+}
+
+// cancelExternalBooking tells the platform a booking imported from it was
+// cancelled here.
+func cancelExternalBooking(ctx context.Context, reservation models.Reservation) error {
+	var connector models.BookingConnector
+	if err := bookingConnectorCollection().FindOne(ctx, bson.M{"connector_id": reservation.Connector_id}).Decode(&connector); err != nil {
+		return err
+	}
+	return bookingClient(connector).Cancel(ctx, reservation.External_id, "Cancelled by the restaurant")
+}
+
+func bookingClient(connector models.BookingConnector) bookings.Connector {
+	return &bookings.Partner{
+		Platform: *connector.Platform,
+		Api_url:  *connector.Api_url,
+		Api_key:  connector.Api_key,
+		Venue_id: *connector.Venue_id,
+	}
+}
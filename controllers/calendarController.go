@@ -0,0 +1,329 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"restaurant-management/apierror"
+	"restaurant-management/calendar"
+	"restaurant-management/config"
+	"restaurant-management/models"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var calendarFeedCollection = db.Collection("calendarFeed")
+
+// Feeds cover a window around today; calendar apps keep older events they
+// already fetched.
+const (
+	calendarFeedPastDays   = 30
+	calendarFeedFutureDays = 180
+)
+
+func GetCalendarFeeds() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		filter := bson.M{}
+		for _, key := range []string{"kind", "location", "employee_id"} {
+			if value := c.Query(key); value != "" {
+				filter[key] = value
+			}
+		}
+
+		// Never echo Google refresh tokens back to clients
+		opts := options.Find().SetProjection(bson.M{"google.refresh_token": 0})
+		result, err := calendarFeedCollection().Find(ctx, filter, opts)
+		if err != nil {
+			apierror.Render(c, apierror.Internal("error occurred while listing calendar feeds: "+err.Error()))
+			return
+		}
+
+		var allFeeds []bson.M
+		if err = result.All(ctx, &allFeeds); err != nil {
+			apierror.Render(c, apierror.Internal("error decoding calendar feeds: "+err.Error()))
+			return
+		}
+		for _, feed := range allFeeds {
+			if token, ok := feed["token"].(string); ok {
+				feed["url"] = calendarFeedURL(token)
+			}
+		}
+
+		c.JSON(http.StatusOK, allFeeds)
+	}
+}
+
+// CreateCalendarFeed returns the subscription URL of a new feed. With a
+// google block the feed's events are also pushed to that Google Calendar.
+func CreateCalendarFeed() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		var feed models.CalendarFeed
+		if err := c.BindJSON(&feed); err != nil {
+			apierror.Render(c, apierror.BadRequest("Invalid request data: "+err.Error()))
+			return
+		}
+		if err := validate.Struct(feed); err != nil {
+			apierror.Render(c, apierror.FromValidation(err))
+			return
+		}
+
+		if feed.Location == nil {
+			location := defaultLocation
+			feed.Location = &location
+		}
+		if *feed.Kind == "RESERVATIONS" {
+			feed.Employee_id = nil
+		}
+		if feed.Active == nil {
+			active := true
+			feed.Active = &active
+		}
+		if feed.Google != nil {
+			feed.Google.Last_pushed_at = nil
+			feed.Google.Last_error = ""
+		}
+
+		token, err := newToken()
+		if err != nil {
+			apierror.Render(c, apierror.Internal("Could not create calendar feed"))
+			return
+		}
+		feed.Token = token
+		feed.Created_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		feed.Updated_at = feed.Created_at
+		feed.ID = primitive.NewObjectID()
+		feed.Feed_id = feed.ID.Hex()
+
+		if _, err := calendarFeedCollection().InsertOne(ctx, feed); err != nil {
+			apierror.Render(c, apierror.Internal("Could not create calendar feed"))
+			return
+		}
+
+		c.JSON(http.StatusCreated, gin.H{"message": "Calendar feed created", "feed_id": feed.Feed_id, "url": calendarFeedURL(feed.Token)})
+	}
+}
+
+// UpdateCalendarFeed renames, pauses or reconnects a feed. ?rotate=true
+// issues a new URL, cutting off everyone subscribed to the old one.
+func UpdateCalendarFeed() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		var feed models.CalendarFeed
+		if err := c.BindJSON(&feed); err != nil {
+			apierror.Render(c, apierror.BadRequest("Invalid request data: "+err.Error()))
+			return
+		}
+
+		var updateObj primitive.D
+		if feed.Name != nil {
+			updateObj = append(updateObj, bson.E{Key: "name", Value: feed.Name})
+		}
+		if feed.Active != nil {
+			updateObj = append(updateObj, bson.E{Key: "active", Value: feed.Active})
+		}
+		if feed.Google != nil {
+			if err := validate.Struct(feed.Google); err != nil {
+				apierror.Render(c, apierror.FromValidation(err))
+				return
+			}
+			feed.Google.Last_pushed_at = nil
+			feed.Google.Last_error = ""
+			updateObj = append(updateObj, bson.E{Key: "google", Value: feed.Google})
+		}
+		token := ""
+		if c.Query("rotate") == "true" {
+			var err error
+			if token, err = newToken(); err != nil {
+				apierror.Render(c, apierror.Internal("Update failed: "+err.Error()))
+				return
+			}
+			updateObj = append(updateObj, bson.E{Key: "token", Value: token})
+		}
+
+		updatedAt, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		updateObj = append(updateObj, bson.E{Key: "updated_at", Value: updatedAt})
+
+		result, err := calendarFeedCollection().UpdateOne(ctx, bson.M{"feed_id": c.Param("feed_id")}, bson.D{{Key: "$set", Value: updateObj}})
+		if err != nil {
+			apierror.Render(c, apierror.Internal("Update failed: "+err.Error()))
+			return
+		}
+		if result.MatchedCount == 0 {
+			apierror.Render(c, apierror.NotFound("Calendar feed not found"))
+			return
+		}
+
+		response := gin.H{"message": "Calendar feed updated successfully"}
+		if token != "" {
+			response["url"] = calendarFeedURL(token)
+		}
+		c.JSON(http.StatusOK, response)
+	}
+}
+
+// GetCalendarFeedIcs serves a feed to calendar apps. It is public: the
+// token in the URL is the credential.
+func GetCalendarFeedIcs() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		var feed models.CalendarFeed
+		token := strings.TrimSuffix(c.Param("token"), ".ics")
+		err := calendarFeedCollection().FindOne(ctx, bson.M{"token": token, "active": true}).Decode(&feed)
+		if err != nil {
+			apierror.Render(c, apierror.NotFound("Calendar not found"))
+			return
+		}
+
+		now := time.Now()
+		events, err := calendarFeedEvents(ctx, feed, bson.M{"$gte": now.AddDate(0, 0, -calendarFeedPastDays), "$lt": now.AddDate(0, 0, calendarFeedFutureDays)}, nil)
+		if err != nil {
+			apierror.Render(c, apierror.Internal("error occurred while building calendar: "+err.Error()))
+			return
+		}
+
+		c.Header("Content-Disposition", "inline; filename=calendar-"+feed.Feed_id+".ics")
+		c.Data(http.StatusOK, "text/calendar; charset=utf-8", calendar.ICS(*feed.Name, events))
+	}
+}
+
+// PushCalendarFeeds sends events changed since the last run to the Google
+// Calendars connected to feeds. A feed that fails keeps its last push time,
+// so the next run retries the same changes.
+func PushCalendarFeeds(ctx context.Context) error {
+	cursor, err := calendarFeedCollection().Find(ctx, bson.M{"active": true, "google": bson.M{"$ne": nil}})
+	if err != nil {
+		return err
+	}
+	var feeds []models.CalendarFeed
+	if err = cursor.All(ctx, &feeds); err != nil {
+		return err
+	}
+
+	for _, feed := range feeds {
+		// Timestamps are stored to the second; events updated in the second
+		// the run starts are pushed again next time rather than missed
+		startedAt := time.Now().Truncate(time.Second)
+		window := bson.M{"$gte": startedAt.AddDate(0, 0, -calendarFeedPastDays), "$lt": startedAt.AddDate(0, 0, calendarFeedFutureDays)}
+
+		events, err := calendarFeedEvents(ctx, feed, window, feed.Google.Last_pushed_at)
+		if err == nil {
+			google := &calendar.Google{Calendar_id: feed.Google.Calendar_id, Refresh_token: feed.Google.Refresh_token}
+			for _, event := range events {
+				if err = google.Push(ctx, event); err != nil {
+					break
+				}
+			}
+		}
+
+		updateObj := bson.D{{Key: "google.last_error", Value: ""}, {Key: "google.last_pushed_at", Value: startedAt}}
+		if err != nil {
+			log.Println("Error pushing calendar feed", feed.Feed_id, ":", err)
+			updateObj = bson.D{{Key: "google.last_error", Value: err.Error()}}
+		}
+		calendarFeedCollection().UpdateOne(ctx, bson.M{"feed_id": feed.Feed_id}, bson.D{{Key: "$set", Value: updateObj}})
+	}
+	return nil
+}
+
+// calendarFeedEvents loads the feed's events starting in window, only those
+// updated at or after changedSince when it is set.
+func calendarFeedEvents(ctx context.Context, feed models.CalendarFeed, window bson.M, changedSince *time.Time) ([]calendar.Event, error) {
+	if *feed.Kind == "SHIFTS" {
+		return shiftEvents(ctx, feed, window, changedSince)
+	}
+	return reservationEvents(ctx, feed, window, changedSince)
+}
+
+func reservationEvents(ctx context.Context, feed models.CalendarFeed, window bson.M, changedSince *time.Time) ([]calendar.Event, error) {
+	filter := bson.M{"location": stringValue(feed.Location), "reserved_at": window}
+	if changedSince != nil {
+		filter["updated_at"] = bson.M{"$gte": changedSince}
+	}
+	cursor, err := reservationCollection().Find(ctx, filter, options.Find().SetSort(bson.D{{Key: "reserved_at", Value: 1}}))
+	if err != nil {
+		return nil, err
+	}
+	var reservations []models.Reservation
+	if err = cursor.All(ctx, &reservations); err != nil {
+		return nil, err
+	}
+
+	events := []calendar.Event{}
+	for _, reservation := range reservations {
+		details := []string{"Status: " + reservation.Status}
+		if reservation.Table_id != nil {
+			details = append(details, "Table: "+*reservation.Table_id)
+		}
+		if phone := stringValue(reservation.Customer_phone); phone != "" {
+			details = append(details, "Phone: "+phone)
+		}
+		if notes := stringValue(reservation.Notes); notes != "" {
+			details = append(details, notes)
+		}
+		events = append(events, calendar.Event{
+			UID:         "reservation" + reservation.Reservation_id,
+			Summary:     fmt.Sprintf("%s (%d)", *reservation.Customer_name, *reservation.Party_size),
+			Description: strings.Join(details, "\n"),
+			Location:    stringValue(reservation.Location),
+			Start:       *reservation.Reserved_at,
+			End:         reservationEnd(reservation),
+			Cancelled:   reservation.Status == "CANCELLED",
+			Updated:     reservation.Updated_at,
+		})
+	}
+	return events, nil
+}
+
+func shiftEvents(ctx context.Context, feed models.CalendarFeed, window bson.M, changedSince *time.Time) ([]calendar.Event, error) {
+	filter := bson.M{"employee_id": stringValue(feed.Employee_id), "start": window}
+	if changedSince != nil {
+		filter["updated_at"] = bson.M{"$gte": changedSince}
+	}
+	cursor, err := shiftCollection().Find(ctx, filter, options.Find().SetSort(bson.D{{Key: "start", Value: 1}}))
+	if err != nil {
+		return nil, err
+	}
+	var shifts []models.Shift
+	if err = cursor.All(ctx, &shifts); err != nil {
+		return nil, err
+	}
+
+	events := []calendar.Event{}
+	for _, shift := range shifts {
+		summary := "Shift"
+		if role := stringValue(shift.Role); role != "" {
+			summary = "Shift: " + role
+		}
+		events = append(events, calendar.Event{
+			UID:         "shift" + shift.Shift_id,
+			Summary:     summary,
+			Description: stringValue(shift.Notes),
+			Location:    stringValue(shift.Location),
+			Start:       *shift.Start,
+			End:         *shift.End,
+			Cancelled:   shift.Status == "CANCELLED",
+			Updated:     shift.Updated_at,
+		})
+	}
+	return events, nil
+}
+
+func calendarFeedURL(token string) string {
+	return config.Get().PublicBaseURL + "/calendars/" + token + ".ics"
+}
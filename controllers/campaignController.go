@@ -0,0 +1,331 @@
+package controllers
+
+import (
+	"context"
+	"net/http"
+	"restaurant-management/apierror"
+	"restaurant-management/config"
+	"restaurant-management/marketing"
+	"restaurant-management/models"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var campaignCollection = db.Collection("campaign")
+var campaignRedemptionCollection = db.Collection("campaignRedemption")
+
+func GetCampaigns() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		result, err := campaignCollection().Find(ctx, bson.M{})
+		if err != nil {
+			apierror.Render(c, apierror.Internal("error occurred while listing campaigns: "+err.Error()))
+			return
+		}
+
+		var allCampaigns []bson.M
+		if err = result.All(ctx, &allCampaigns); err != nil {
+			apierror.Render(c, apierror.Internal("error decoding campaigns: "+err.Error()))
+			return
+		}
+
+		c.JSON(http.StatusOK, allCampaigns)
+	}
+}
+
+func GetCampaign() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		var campaign models.Campaign
+		err := campaignCollection().FindOne(ctx, bson.M{"campaign_id": c.Param("campaign_id")}).Decode(&campaign)
+		if err != nil {
+			apierror.Render(c, apierror.NotFound("Campaign not found"))
+			return
+		}
+
+		c.JSON(http.StatusOK, campaign)
+	}
+}
+
+func CreateCampaign() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		var campaign models.Campaign
+		if err := c.BindJSON(&campaign); err != nil {
+			apierror.Render(c, apierror.BadRequest("Invalid request data: "+err.Error()))
+			return
+		}
+
+		if err := validate.Struct(campaign); err != nil {
+			apierror.Render(c, apierror.FromValidation(err))
+			return
+		}
+
+		var segment models.Segment
+		if err := segmentCollection().FindOne(ctx, bson.M{"segment_id": campaign.Segment_id}).Decode(&segment); err != nil {
+			apierror.Render(c, apierror.NotFound("Segment not found"))
+			return
+		}
+
+		if campaign.Coupon_code != nil {
+			count, err := campaignCollection().CountDocuments(ctx, bson.M{"coupon_code": campaign.Coupon_code})
+			if err != nil {
+				apierror.Render(c, apierror.Internal("error occurred while checking coupon code: "+err.Error()))
+				return
+			}
+			if count > 0 {
+				apierror.Render(c, apierror.Conflict("Coupon code is already attributed to another campaign"))
+				return
+			}
+		}
+
+		now := time.Now().Format(time.RFC3339)
+		campaign.Created_at, _ = time.Parse(time.RFC3339, now)
+		campaign.Updated_at, _ = time.Parse(time.RFC3339, now)
+		campaign.ID = primitive.NewObjectID()
+		campaign.Campaign_id = campaign.ID.Hex()
+		campaign.Status = "DRAFT"
+		campaign.Recipients = 0
+		campaign.Redemptions = 0
+
+		result, insertErr := campaignCollection().InsertOne(ctx, campaign)
+		if insertErr != nil {
+			apierror.Render(c, apierror.Internal("Could not create campaign"))
+			return
+		}
+
+		c.JSON(http.StatusCreated, gin.H{"message": "Campaign created", "data": result})
+	}
+}
+
+// SyncCampaignAudience pushes the campaign segment's current members to the
+// provider audience.
+func SyncCampaignAudience() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		var campaign models.Campaign
+		if err := campaignCollection().FindOne(ctx, bson.M{"campaign_id": c.Param("campaign_id")}).Decode(&campaign); err != nil {
+			apierror.Render(c, apierror.NotFound("Campaign not found"))
+			return
+		}
+
+		campaign, err := syncCampaignAudience(ctx, campaign)
+		if err != nil {
+			apierror.Render(c, apierror.UpstreamFailed("Audience sync failed: "+err.Error()))
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Audience synced", "audience_id": campaign.Audience_id, "recipients": campaign.Recipients})
+	}
+}
+
+// SendCampaign syncs the audience and triggers the provider send.
+func SendCampaign() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		var campaign models.Campaign
+		if err := campaignCollection().FindOne(ctx, bson.M{"campaign_id": c.Param("campaign_id")}).Decode(&campaign); err != nil {
+			apierror.Render(c, apierror.NotFound("Campaign not found"))
+			return
+		}
+		if campaign.Status == "SENT" {
+			apierror.Render(c, apierror.Conflict("Campaign was already sent"))
+			return
+		}
+
+		campaign, err := syncCampaignAudience(ctx, campaign)
+		if err != nil {
+			apierror.Render(c, apierror.UpstreamFailed("Audience sync failed: "+err.Error()))
+			return
+		}
+
+		provider, err := marketing.NewProvider(*campaign.Provider)
+		if err != nil {
+			apierror.Render(c, apierror.Internal(err.Error()))
+			return
+		}
+
+		message := marketing.Message{
+			Name:         *campaign.Name,
+			Subject:      *campaign.Subject,
+			Html_content: *campaign.Html_content,
+		}
+		if campaign.From_email != nil {
+			message.From_email = *campaign.From_email
+		}
+		if campaign.From_name != nil {
+			message.From_name = *campaign.From_name
+		}
+
+		externalId, err := provider.SendCampaign(ctx, campaign.Audience_id, message)
+		if err != nil {
+			setCampaignStatus(ctx, campaign.Campaign_id, bson.D{{Key: "status", Value: "FAILED"}, {Key: "last_error", Value: err.Error()}})
+			apierror.Render(c, apierror.UpstreamFailed("Campaign send failed: "+err.Error()))
+			return
+		}
+
+		sentAt, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		setCampaignStatus(ctx, campaign.Campaign_id, bson.D{
+			{Key: "status", Value: "SENT"},
+			{Key: "external_id", Value: externalId},
+			{Key: "sent_at", Value: sentAt},
+			{Key: "last_error", Value: ""},
+		})
+
+		c.JSON(http.StatusOK, gin.H{"message": "Campaign sent", "external_id": externalId, "recipients": campaign.Recipients})
+	}
+}
+
+// CreateCampaignRedemption attributes an order that redeemed a campaign
+// coupon back to the campaign.
+func CreateCampaignRedemption() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		var redemption models.CampaignRedemption
+		if err := c.BindJSON(&redemption); err != nil {
+			apierror.Render(c, apierror.BadRequest("Invalid request data: "+err.Error()))
+			return
+		}
+		if err := validate.Struct(redemption); err != nil {
+			apierror.Render(c, apierror.FromValidation(err))
+			return
+		}
+
+		campaignId, err := recordCampaignRedemption(ctx, redemption.Coupon_code, *redemption.Order_id, redemption.Customer_id)
+		if err == mongo.ErrNoDocuments {
+			apierror.Render(c, apierror.NotFound("No campaign uses this coupon code"))
+			return
+		}
+		if err != nil {
+			apierror.Render(c, apierror.Internal("Could not record redemption: "+err.Error()))
+			return
+		}
+
+		c.JSON(http.StatusCreated, gin.H{"message": "Redemption recorded", "campaign_id": campaignId})
+	}
+}
+
+// recordCampaignRedemption stores the attribution of a coupon redemption and
+// bumps the campaign's redemption counter. It returns mongo.ErrNoDocuments
+// when the coupon does not belong to a campaign.
+func recordCampaignRedemption(ctx context.Context, couponCode string, orderId string, customerId *string) (string, error) {
+	var campaign models.Campaign
+	if err := campaignCollection().FindOne(ctx, bson.M{"coupon_code": couponCode}).Decode(&campaign); err != nil {
+		return "", err
+	}
+
+	redemption := models.CampaignRedemption{
+		ID:          primitive.NewObjectID(),
+		Campaign_id: campaign.Campaign_id,
+		Coupon_code: couponCode,
+		Order_id:    &orderId,
+		Customer_id: customerId,
+	}
+	redemption.Created_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+	if _, err := campaignRedemptionCollection().InsertOne(ctx, redemption); err != nil {
+		return "", err
+	}
+
+	_, err := campaignCollection().UpdateOne(ctx, bson.M{"campaign_id": campaign.Campaign_id}, bson.D{{Key: "$inc", Value: bson.D{{Key: "redemptions", Value: 1}}}})
+	return campaign.Campaign_id, err
+}
+
+func syncCampaignAudience(ctx context.Context, campaign models.Campaign) (models.Campaign, error) {
+	var segment models.Segment
+	if err := segmentCollection().FindOne(ctx, bson.M{"segment_id": campaign.Segment_id}).Decode(&segment); err != nil {
+		return campaign, err
+	}
+
+	members, err := SegmentMembers(ctx, *segment.Rules, time.Now())
+	if err != nil {
+		return campaign, err
+	}
+
+	contacts, err := campaignContacts(ctx, members)
+	if err != nil {
+		return campaign, err
+	}
+
+	provider, err := marketing.NewProvider(*campaign.Provider)
+	if err != nil {
+		return campaign, err
+	}
+
+	audienceId, err := provider.SyncAudience(ctx, "segment-"+*segment.Name, contacts)
+	if err != nil {
+		setCampaignStatus(ctx, campaign.Campaign_id, bson.D{{Key: "last_error", Value: err.Error()}})
+		return campaign, err
+	}
+
+	syncedAt, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+	campaign.Audience_id = audienceId
+	campaign.Recipients = len(contacts)
+	campaign.Synced_at = &syncedAt
+	update := bson.D{
+		{Key: "audience_id", Value: audienceId},
+		{Key: "recipients", Value: len(contacts)},
+		{Key: "synced_at", Value: syncedAt},
+	}
+	if campaign.Status == "DRAFT" {
+		campaign.Status = "SYNCED"
+		update = append(update, bson.E{Key: "status", Value: "SYNCED"})
+	}
+	setCampaignStatus(ctx, campaign.Campaign_id, update)
+	return campaign, nil
+}
+
+// campaignContacts resolves segment members to emailable contacts, skipping
+// customers without an email address.
+func campaignContacts(ctx context.Context, members []models.SegmentMember) ([]marketing.Contact, error) {
+	ids := make([]string, 0, len(members))
+	for _, member := range members {
+		ids = append(ids, member.Customer_id)
+	}
+
+	cursor, err := userCollection().Find(ctx, bson.M{"user_id": bson.M{"$in": ids}})
+	if err != nil {
+		return nil, err
+	}
+	var users []models.User
+	if err = cursor.All(ctx, &users); err != nil {
+		return nil, err
+	}
+
+	contacts := []marketing.Contact{}
+	for _, user := range users {
+		if user.Email == nil || *user.Email == "" {
+			continue
+		}
+		contact := marketing.Contact{Customer_id: user.User_id, Email: *user.Email}
+		if user.First_name != nil {
+			contact.First_name = *user.First_name
+		}
+		if user.Last_name != nil {
+			contact.Last_name = *user.Last_name
+		}
+		contacts = append(contacts, contact)
+	}
+	return contacts, nil
+}
+
+func setCampaignStatus(ctx context.Context, campaignId string, fields bson.D) {
+	updatedAt, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+	fields = append(fields, bson.E{Key: "updated_at", Value: updatedAt})
+	campaignCollection().UpdateOne(ctx, bson.M{"campaign_id": campaignId}, bson.D{{Key: "$set", Value: fields}})
+}
@@ -0,0 +1,395 @@
+package controllers
+
+import (
+	"context"
+	"net/http"
+	"restaurant-management/apierror"
+	"restaurant-management/config"
+	"restaurant-management/models"
+	"sort"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var drawerSessionCollection = db.Collection("drawerSession")
+var cashMovementCollection = db.Collection("cashMovement")
+
+func GetDrawerSessions() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		filter := bson.M{}
+		if status := c.Query("status"); status != "" {
+			filter["status"] = status
+		}
+		if employeeId := c.Query("employee_id"); employeeId != "" {
+			filter["employee_id"] = employeeId
+		}
+
+		opts := options.Find().SetSort(bson.D{{Key: "opened_at", Value: -1}})
+		result, err := drawerSessionCollection().Find(ctx, filter, opts)
+		if err != nil {
+			apierror.Render(c, apierror.Internal("error occurred while listing drawer sessions: "+err.Error()))
+			return
+		}
+
+		var allSessions []bson.M
+		if err = result.All(ctx, &allSessions); err != nil {
+			apierror.Render(c, apierror.Internal("error decoding drawer sessions: "+err.Error()))
+			return
+		}
+
+		c.JSON(http.StatusOK, allSessions)
+	}
+}
+
+// GetDrawerSession returns the session with its running reconciliation.
+func GetDrawerSession() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		var session models.DrawerSession
+		err := drawerSessionCollection().FindOne(ctx, bson.M{"drawer_session_id": c.Param("drawer_session_id")}).Decode(&session)
+		if err != nil {
+			apierror.Render(c, apierror.NotFound("Drawer session not found"))
+			return
+		}
+
+		reconciliation, err := reconcileDrawer(ctx, session)
+		if err != nil {
+			apierror.Render(c, apierror.Internal("error reconciling drawer: "+err.Error()))
+			return
+		}
+
+		c.JSON(http.StatusOK, reconciliation)
+	}
+}
+
+func OpenDrawerSession() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		var session models.DrawerSession
+		if err := c.BindJSON(&session); err != nil {
+			apierror.Render(c, apierror.BadRequest("Invalid request data: "+err.Error()))
+			return
+		}
+		if err := validate.Struct(session); err != nil {
+			apierror.Render(c, apierror.FromValidation(err))
+			return
+		}
+
+		count, err := drawerSessionCollection().CountDocuments(ctx, bson.M{"drawer_id": session.Drawer_id, "status": "OPEN"})
+		if err != nil {
+			apierror.Render(c, apierror.Internal("error checking open sessions: "+err.Error()))
+			return
+		}
+		if count > 0 {
+			apierror.Render(c, apierror.Conflict("Drawer already has an open session"))
+			return
+		}
+
+		session.Status = "OPEN"
+		session.Counted_cash = nil
+		session.Closed_at = nil
+		session.Opened_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		session.Created_at = session.Opened_at
+		session.Updated_at = session.Opened_at
+		session.ID = primitive.NewObjectID()
+		session.Drawer_session_id = session.ID.Hex()
+
+		result, err := drawerSessionCollection().InsertOne(ctx, session)
+		if err != nil {
+			apierror.Render(c, apierror.Internal("Drawer session was not opened: "+err.Error()))
+			return
+		}
+
+		c.JSON(http.StatusCreated, gin.H{"message": "Drawer session opened", "data": result, "drawer_session_id": session.Drawer_session_id})
+	}
+}
+
+// CloseDrawerSession records the closing count and returns the over/short.
+func CloseDrawerSession() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		sessionId := c.Param("drawer_session_id")
+
+		var body struct {
+			Counted_cash *float64 `json:"counted_cash" validate:"required,min=0"`
+		}
+		if err := c.BindJSON(&body); err != nil {
+			apierror.Render(c, apierror.BadRequest("Invalid request data: "+err.Error()))
+			return
+		}
+		if err := validate.Struct(body); err != nil {
+			apierror.Render(c, apierror.FromValidation(err))
+			return
+		}
+
+		closedAt, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		counted := toFixed(*body.Counted_cash, 2)
+		var session models.DrawerSession
+		err := drawerSessionCollection().FindOneAndUpdate(
+			ctx,
+			bson.M{"drawer_session_id": sessionId, "status": "OPEN"},
+			bson.D{{Key: "$set", Value: bson.D{
+				{Key: "status", Value: "CLOSED"},
+				{Key: "counted_cash", Value: counted},
+				{Key: "closed_at", Value: closedAt},
+				{Key: "updated_at", Value: closedAt},
+			}}},
+			options.FindOneAndUpdate().SetReturnDocument(options.After),
+		).Decode(&session)
+		if err == mongo.ErrNoDocuments {
+			apierror.Render(c, apierror.NotFound("No open drawer session found"))
+			return
+		}
+		if err != nil {
+			apierror.Render(c, apierror.Internal("Drawer session was not closed: "+err.Error()))
+			return
+		}
+
+		reconciliation, err := reconcileDrawer(ctx, session)
+		if err != nil {
+			apierror.Render(c, apierror.Internal("error reconciling drawer: "+err.Error()))
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Drawer session closed", "data": reconciliation})
+	}
+}
+
+func GetCashMovements() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: 1}})
+		result, err := cashMovementCollection().Find(ctx, bson.M{"drawer_session_id": c.Param("drawer_session_id")}, opts)
+		if err != nil {
+			apierror.Render(c, apierror.Internal("error occurred while listing cash movements: "+err.Error()))
+			return
+		}
+
+		var allMovements []bson.M
+		if err = result.All(ctx, &allMovements); err != nil {
+			apierror.Render(c, apierror.Internal("error decoding cash movements: "+err.Error()))
+			return
+		}
+
+		c.JSON(http.StatusOK, allMovements)
+	}
+}
+
+// CreateCashMovement records a paid-in, paid-out or safe drop against an
+// open drawer session.
+func CreateCashMovement() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		var movement models.CashMovement
+		if err := c.BindJSON(&movement); err != nil {
+			apierror.Render(c, apierror.BadRequest("Invalid request data: "+err.Error()))
+			return
+		}
+		if err := validate.Struct(movement); err != nil {
+			apierror.Render(c, apierror.FromValidation(err))
+			return
+		}
+
+		var session models.DrawerSession
+		err := drawerSessionCollection().FindOne(ctx, bson.M{"drawer_session_id": c.Param("drawer_session_id")}).Decode(&session)
+		if err != nil {
+			apierror.Render(c, apierror.NotFound("Drawer session not found"))
+			return
+		}
+		if session.Status != "OPEN" {
+			apierror.Render(c, apierror.Conflict("Drawer session is closed"))
+			return
+		}
+
+		amount := toFixed(*movement.Amount, 2)
+		movement.Amount = &amount
+		movement.Drawer_session_id = session.Drawer_session_id
+		movement.Created_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		movement.ID = primitive.NewObjectID()
+		movement.Cash_movement_id = movement.ID.Hex()
+
+		result, err := cashMovementCollection().InsertOne(ctx, movement)
+		if err != nil {
+			apierror.Render(c, apierror.Internal("Cash movement was not recorded: "+err.Error()))
+			return
+		}
+
+		c.JSON(http.StatusCreated, gin.H{"message": "Cash movement recorded", "data": result})
+	}
+}
+
+// GetOverShortReport reconciles the drawer sessions closed in ?from=&to= and
+// totals over/short per session and per employee.
+func GetOverShortReport() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		from, to, err := parseReportRange(c)
+		if err != nil {
+			apierror.Render(c, apierror.BadRequest(err.Error()))
+			return
+		}
+
+		filter := bson.M{"status": "CLOSED", "closed_at": bson.M{"$gte": from, "$lt": to}}
+		if drawerId := c.Query("drawer_id"); drawerId != "" {
+			filter["drawer_id"] = drawerId
+		}
+		result, err := drawerSessionCollection().Find(ctx, filter, options.Find().SetSort(bson.D{{Key: "closed_at", Value: 1}}))
+		if err != nil {
+			apierror.Render(c, apierror.Internal("error occurred while listing drawer sessions: "+err.Error()))
+			return
+		}
+		var sessions []models.DrawerSession
+		if err = result.All(ctx, &sessions); err != nil {
+			apierror.Render(c, apierror.Internal("error decoding drawer sessions: "+err.Error()))
+			return
+		}
+
+		type employeeTotal struct {
+			Employee_id string  `json:"employee_id"`
+			Sessions    int     `json:"sessions"`
+			Over_short  float64 `json:"over_short"`
+		}
+		bySession := []models.DrawerReconciliation{}
+		totals := map[string]*employeeTotal{}
+		for _, session := range sessions {
+			reconciliation, err := reconcileDrawer(ctx, session)
+			if err != nil {
+				apierror.Render(c, apierror.Internal("error reconciling drawer: "+err.Error()))
+				return
+			}
+			bySession = append(bySession, reconciliation)
+
+			total, ok := totals[reconciliation.Employee_id]
+			if !ok {
+				total = &employeeTotal{Employee_id: reconciliation.Employee_id}
+				totals[reconciliation.Employee_id] = total
+			}
+			total.Sessions++
+			if reconciliation.Over_short != nil {
+				total.Over_short = toFixed(total.Over_short+*reconciliation.Over_short, 2)
+			}
+		}
+
+		byEmployee := []employeeTotal{}
+		for _, total := range totals {
+			byEmployee = append(byEmployee, *total)
+		}
+		sort.Slice(byEmployee, func(i, j int) bool { return byEmployee[i].Over_short < byEmployee[j].Over_short })
+
+		c.JSON(http.StatusOK, gin.H{"from": from, "to": to, "by_session": bySession, "by_employee": byEmployee})
+	}
+}
+
+// reconcileDrawer works out the cash a session should hold: opening float
+// plus cash sales and paid-ins, minus paid-outs, safe drops and expenses paid
+// from the drawer. Once counted, over/short is the counted cash minus that
+// expectation.
+func reconcileDrawer(ctx context.Context, session models.DrawerSession) (models.DrawerReconciliation, error) {
+	reconciliation := models.DrawerReconciliation{
+		Drawer_session_id: session.Drawer_session_id,
+		Drawer_id:         *session.Drawer_id,
+		Employee_id:       *session.Employee_id,
+		Status:            session.Status,
+		Opened_at:         session.Opened_at,
+		Closed_at:         session.Closed_at,
+		Opening_float:     *session.Opening_float,
+		Counted_cash:      session.Counted_cash,
+	}
+
+	movementCursor, err := cashMovementCollection().Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$match", Value: bson.D{{Key: "drawer_session_id", Value: session.Drawer_session_id}}}},
+		{{Key: "$group", Value: bson.D{
+			{Key: "_id", Value: "$type"},
+			{Key: "total", Value: bson.D{{Key: "$sum", Value: "$amount"}}},
+		}}},
+	})
+	if err != nil {
+		return reconciliation, err
+	}
+	var movements []struct {
+		Type  string  `bson:"_id"`
+		Total float64 `bson:"total"`
+	}
+	if err = movementCursor.All(ctx, &movements); err != nil {
+		return reconciliation, err
+	}
+	for _, movement := range movements {
+		switch movement.Type {
+		case "PAID_IN":
+			reconciliation.Paid_ins = toFixed(movement.Total, 2)
+		case "PAID_OUT":
+			reconciliation.Paid_outs = toFixed(movement.Total, 2)
+		case "SAFE_DROP":
+			reconciliation.Safe_drops = toFixed(movement.Total, 2)
+		}
+	}
+
+	salesCursor, err := invoiceCollection().Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$match", Value: bson.D{
+			{Key: "drawer_session_id", Value: session.Drawer_session_id},
+			{Key: "payment_method", Value: "CASH"},
+			{Key: "payment_status", Value: "PAID"},
+		}}},
+		{{Key: "$lookup", Value: bson.D{
+			{Key: "from", Value: "orderItem"},
+			{Key: "localField", Value: "order_id"},
+			{Key: "foreignField", Value: "order_id"},
+			{Key: "as", Value: "items"},
+		}}},
+		{{Key: "$group", Value: bson.D{
+			{Key: "_id", Value: nil},
+			{Key: "total", Value: bson.D{{Key: "$sum", Value: itemsTotal("$items")}}},
+			{Key: "rounding", Value: bson.D{{Key: "$sum", Value: "$cash_rounding"}}},
+			{Key: "deposits", Value: bson.D{{Key: "$sum", Value: "$deposit_credit"}}},
+		}}},
+	})
+	if err != nil {
+		return reconciliation, err
+	}
+	var sales []struct {
+		Total    float64 `bson:"total"`
+		Rounding float64 `bson:"rounding"`
+		Deposits float64 `bson:"deposits"`
+	}
+	if err = salesCursor.All(ctx, &sales); err != nil {
+		return reconciliation, err
+	}
+	if len(sales) > 0 {
+		// Prepaid deposits were not paid into the drawer
+		reconciliation.Cash_sales = toFixed(sales[0].Total-sales[0].Deposits, 2)
+		reconciliation.Cash_rounding = toFixed(sales[0].Rounding, 2)
+	}
+
+	expenses, err := drawerExpenses(ctx, session.Drawer_session_id)
+	if err != nil {
+		return reconciliation, err
+	}
+	reconciliation.Expenses = expenses
+
+	reconciliation.Expected_cash = toFixed(reconciliation.Opening_float+reconciliation.Cash_sales+reconciliation.Cash_rounding+reconciliation.Paid_ins-reconciliation.Paid_outs-reconciliation.Safe_drops-reconciliation.Expenses, 2)
+	if session.Counted_cash != nil {
+		overShort := toFixed(*session.Counted_cash-reconciliation.Expected_cash, 2)
+		reconciliation.Over_short = &overShort
+	}
+	return reconciliation, nil
+}
@@ -0,0 +1,221 @@
+package controllers
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"restaurant-management/apierror"
+	"restaurant-management/config"
+	"restaurant-management/i18n"
+	"restaurant-management/models"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var cashRoundingRuleCollection = db.Collection("cashRoundingRule")
+
+type CashRoundingTotal struct {
+	Location string  `json:"location" bson:"_id"`
+	Invoices int     `json:"invoices" bson:"invoices"`
+	Gains    float64 `json:"gains" bson:"gains"`
+	Losses   float64 `json:"losses" bson:"losses"`
+	Net      float64 `json:"net" bson:"net"`
+}
+
+func GetCashRoundingRules() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		result, err := cashRoundingRuleCollection().Find(ctx, bson.M{})
+		if err != nil {
+			apierror.Render(c, apierror.Internal("error occurred while listing cash rounding rules: "+err.Error()))
+			return
+		}
+
+		var allRules []bson.M
+		if err = result.All(ctx, &allRules); err != nil {
+			apierror.Render(c, apierror.Internal("error decoding cash rounding rules: "+err.Error()))
+			return
+		}
+
+		c.JSON(http.StatusOK, allRules)
+	}
+}
+
+// UpdateCashRoundingRule creates or updates the cash rounding of a currency.
+func UpdateCashRoundingRule() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		currency := c.Param("currency")
+		if err := validate.Var(currency, "len=3,uppercase"); err != nil {
+			apierror.Render(c, apierror.Validation("Currency must be an ISO 4217 code such as CHF"))
+			return
+		}
+
+		var rule models.CashRoundingRule
+		if err := c.BindJSON(&rule); err != nil {
+			apierror.Render(c, apierror.BadRequest("Invalid request data: "+err.Error()))
+			return
+		}
+		if err := validate.Struct(rule); err != nil {
+			apierror.Render(c, apierror.FromValidation(err))
+			return
+		}
+
+		mode := "NEAREST"
+		if rule.Mode != nil {
+			mode = *rule.Mode
+		}
+
+		now, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		upsert := true
+		opt := options.UpdateOptions{Upsert: &upsert}
+
+		result, err := cashRoundingRuleCollection().UpdateOne(
+			ctx,
+			bson.M{"currency": currency},
+			bson.D{
+				{Key: "$set", Value: bson.D{{Key: "increment", Value: rule.Increment}, {Key: "mode", Value: mode}, {Key: "updated_at", Value: now}}},
+				{Key: "$setOnInsert", Value: bson.D{{Key: "_id", Value: primitive.NewObjectID()}, {Key: "created_at", Value: now}}},
+			},
+			&opt,
+		)
+		if err != nil {
+			apierror.Render(c, apierror.Internal("Update failed: "+err.Error()))
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Cash rounding rule updated successfully", "result": result})
+	}
+}
+
+// GetCashRoundingReport is the rounding account: what cash rounding gained
+// and lost per location over ?from= and ?to=.
+func GetCashRoundingReport() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		from, to, err := parseReportRange(c)
+		if err != nil {
+			apierror.Render(c, apierror.BadRequest(err.Error()))
+			return
+		}
+
+		cursor, err := invoiceCollection().Aggregate(ctx, mongo.Pipeline{
+			{{Key: "$match", Value: bson.D{
+				{Key: "finalized_at", Value: bson.M{"$gte": from, "$lt": to}},
+				{Key: "cash_rounding", Value: bson.M{"$ne": nil}},
+			}}},
+			{{Key: "$group", Value: bson.D{
+				{Key: "_id", Value: bson.D{{Key: "$ifNull", Value: bson.A{"$location", defaultLocation}}}},
+				{Key: "invoices", Value: bson.D{{Key: "$sum", Value: 1}}},
+				{Key: "gains", Value: bson.D{{Key: "$sum", Value: bson.D{{Key: "$max", Value: bson.A{"$cash_rounding", 0}}}}}},
+				{Key: "losses", Value: bson.D{{Key: "$sum", Value: bson.D{{Key: "$min", Value: bson.A{"$cash_rounding", 0}}}}}},
+				{Key: "net", Value: bson.D{{Key: "$sum", Value: "$cash_rounding"}}},
+			}}},
+			{{Key: "$sort", Value: bson.D{{Key: "_id", Value: 1}}}},
+		})
+		if err != nil {
+			apierror.Render(c, apierror.Internal("error occurred while totalling cash rounding: "+err.Error()))
+			return
+		}
+		totals := []CashRoundingTotal{}
+		if err = cursor.All(ctx, &totals); err != nil {
+			apierror.Render(c, apierror.Internal("error decoding cash rounding totals: "+err.Error()))
+			return
+		}
+		for i := range totals {
+			totals[i].Gains = toFixed(totals[i].Gains, 2)
+			totals[i].Losses = toFixed(totals[i].Losses, 2)
+			totals[i].Net = toFixed(totals[i].Net, 2)
+		}
+
+		c.JSON(http.StatusOK, gin.H{"from": from.Format("2006-01-02"), "to": to.AddDate(0, 0, -1).Format("2006-01-02"), "locations": totals})
+	}
+}
+
+// applyCashRounding stores the rounding difference of a paid invoice. Only
+// cash tenders are rounded; other methods have it cleared. Finalized
+// invoices are left alone.
+func applyCashRounding(ctx context.Context, invoiceId string) error {
+	var invoice models.Invoice
+	if err := invoiceCollection().FindOne(ctx, bson.M{"invoice_id": invoiceId}).Decode(&invoice); err != nil {
+		return err
+	}
+	if invoice.Invoice_number != nil {
+		return nil
+	}
+	if stringValue(invoice.Payment_method) != "CASH" {
+		_, err := invoiceCollection().UpdateOne(ctx, bson.M{"invoice_id": invoiceId}, bson.M{"$unset": bson.M{"cash_rounding": ""}})
+		return err
+	}
+
+	location := stringValue(invoice.Location)
+	if location == "" {
+		location = defaultLocation
+	}
+	currency, err := locationCurrency(ctx, location)
+	if err != nil {
+		return err
+	}
+
+	rounding := 0.0
+	var rule models.CashRoundingRule
+	err = cashRoundingRuleCollection().FindOne(ctx, bson.M{"currency": currency}).Decode(&rule)
+	if err != nil && err != mongo.ErrNoDocuments {
+		return err
+	}
+	if err == nil {
+		total, err := invoiceTotal(ctx, invoice)
+		if err != nil {
+			return err
+		}
+		// Only the balance left after the deposit is tendered in cash
+		if invoice.Deposit_credit != nil {
+			total = toFixed(math.Max(total-*invoice.Deposit_credit, 0), 2)
+		}
+		rounding = toFixed(roundCash(total, *rule.Increment, stringValue(rule.Mode))-total, 2)
+	}
+
+	_, err = invoiceCollection().UpdateOne(ctx, bson.M{"invoice_id": invoiceId}, bson.M{"$set": bson.M{"cash_rounding": rounding}})
+	return err
+}
+
+// roundCash rounds amount to a multiple of increment. NEAREST rounds halves
+// up, as Swiss 5-rappen rounding does.
+func roundCash(amount, increment float64, mode string) float64 {
+	// The epsilon keeps binary noise such as 10.025/0.05 = 200.4999...
+	// from deciding the direction
+	const epsilon = 1e-9
+	steps := amount / increment
+	switch mode {
+	case "UP":
+		steps = math.Ceil(steps - epsilon)
+	case "DOWN":
+		steps = math.Floor(steps + epsilon)
+	default:
+		steps = math.Floor(steps + 0.5 + epsilon)
+	}
+	return toFixed(steps*increment, 2)
+}
+
+// locationCurrency is the currency a location's receipts are printed in.
+func locationCurrency(ctx context.Context, location string) (string, error) {
+	template, err := effectiveReceiptTemplate(ctx, location)
+	if err != nil {
+		return "", err
+	}
+	if template.Currency != nil && *template.Currency != "" {
+		return *template.Currency, nil
+	}
+	return i18n.Get(stringValue(template.Locale)).Currency, nil
+}
@@ -0,0 +1,31 @@
+package controllers
+
+import "testing"
+
+func TestRoundCash(t *testing.T) {
+	tests := []struct {
+		name      string
+		amount    float64
+		increment float64
+		mode      string
+		want      float64
+	}{
+		{"nearest rounds down", 10.02, 0.05, "NEAREST", 10},
+		{"nearest rounds up", 10.03, 0.05, "NEAREST", 10.05},
+		{"nearest rounds halves up", 10.025, 0.05, "NEAREST", 10.05},
+		{"empty mode rounds to nearest", 10.07, 0.05, "", 10.05},
+		{"already a multiple", 10.05, 0.05, "UP", 10.05},
+		{"up", 10.01, 0.05, "UP", 10.05},
+		{"down", 10.04, 0.05, "DOWN", 10},
+		{"ten cent increment", 4.96, 0.1, "NEAREST", 5},
+		{"whole units", 1234.5, 1, "NEAREST", 1235},
+		{"negative amounts", -10.03, 0.05, "NEAREST", -10.05},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := roundCash(tt.amount, tt.increment, tt.mode); got != tt.want {
+				t.Errorf("roundCash(%v, %v, %q) = %v, want %v", tt.amount, tt.increment, tt.mode, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,196 @@
+package controllers
+
+import (
+	"context"
+	"net/http"
+	"regexp"
+	"restaurant-management/apierror"
+	"restaurant-management/config"
+	"restaurant-management/models"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var categoryCollection = db.Collection("category")
+
+// GetCategories lists the categories in display order.
+func GetCategories() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		opts := options.Find().SetSort(bson.D{{Key: "position", Value: 1}, {Key: "name", Value: 1}})
+		result, err := categoryCollection().Find(ctx, bson.M{}, opts)
+		if err != nil {
+			apierror.Render(c, apierror.Internal("error occurred while listing categories: "+err.Error()))
+			return
+		}
+
+		var allCategories []bson.M
+		if err = result.All(ctx, &allCategories); err != nil {
+			apierror.Render(c, apierror.Internal("error decoding categories: "+err.Error()))
+			return
+		}
+
+		c.JSON(http.StatusOK, allCategories)
+	}
+}
+
+func GetCategory() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		var category models.Category
+		if err := categoryCollection().FindOne(ctx, bson.M{"category_id": c.Param("category_id")}).Decode(&category); err != nil {
+			apierror.Render(c, apierror.NotFound("Category not found"))
+			return
+		}
+
+		c.JSON(http.StatusOK, category)
+	}
+}
+
+func CreateCategory() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		var category models.Category
+		if err := c.BindJSON(&category); err != nil {
+			apierror.Render(c, apierror.BadRequest("Invalid request data: "+err.Error()))
+			return
+		}
+		if err := validate.Struct(category); err != nil {
+			apierror.Render(c, apierror.FromValidation(err))
+			return
+		}
+
+		name := strings.TrimSpace(*category.Name)
+		category.Name = &name
+		if taken, err := categoryNameTaken(ctx, name, ""); err != nil {
+			apierror.Render(c, apierror.Internal("error occurred while checking the category name"))
+			return
+		} else if taken {
+			apierror.Render(c, apierror.Conflict("A category with this name already exists"))
+			return
+		}
+
+		now := time.Now().Format(time.RFC3339)
+		category.Created_at, _ = time.Parse(time.RFC3339, now)
+		category.Updated_at, _ = time.Parse(time.RFC3339, now)
+		category.ID = primitive.NewObjectID()
+		category.Category_id = category.ID.Hex()
+
+		if _, err := categoryCollection().InsertOne(ctx, category); err != nil {
+			apierror.Render(c, apierror.Internal("Could not create category"))
+			return
+		}
+		auditCreated(c, category.Category_id)
+
+		c.JSON(http.StatusCreated, gin.H{"message": "Category created", "data": category})
+	}
+}
+
+func UpdateCategory() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		var category models.Category
+		if err := c.BindJSON(&category); err != nil {
+			apierror.Render(c, apierror.BadRequest("Invalid request data: "+err.Error()))
+			return
+		}
+		if err := validate.StructPartial(category, "Description", "Position"); err != nil {
+			apierror.Render(c, apierror.FromValidation(err))
+			return
+		}
+
+		categoryId := c.Param("category_id")
+		var updateObj primitive.D
+		if category.Name != nil {
+			if err := validate.StructPartial(category, "Name"); err != nil {
+				apierror.Render(c, apierror.FromValidation(err))
+				return
+			}
+			name := strings.TrimSpace(*category.Name)
+			if taken, err := categoryNameTaken(ctx, name, categoryId); err != nil {
+				apierror.Render(c, apierror.Internal("error occurred while checking the category name"))
+				return
+			} else if taken {
+				apierror.Render(c, apierror.Conflict("A category with this name already exists"))
+				return
+			}
+			updateObj = append(updateObj, bson.E{Key: "name", Value: name})
+		}
+		if category.Description != nil {
+			updateObj = append(updateObj, bson.E{Key: "description", Value: category.Description})
+		}
+		if category.Position != nil {
+			updateObj = append(updateObj, bson.E{Key: "position", Value: category.Position})
+		}
+
+		updatedAt, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		updateObj = append(updateObj, bson.E{Key: "updated_at", Value: updatedAt})
+
+		result, err := categoryCollection().UpdateOne(ctx, bson.M{"category_id": categoryId}, bson.D{{Key: "$set", Value: updateObj}})
+		if err != nil {
+			apierror.Render(c, apierror.Internal("Update failed: "+err.Error()))
+			return
+		}
+		if result.MatchedCount == 0 {
+			apierror.Render(c, apierror.NotFound("Category not found"))
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Category updated successfully"})
+	}
+}
+
+// DeleteCategory removes a category no food belongs to any more.
+func DeleteCategory() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		categoryId := c.Param("category_id")
+		inUse, err := foodCollection().CountDocuments(ctx, bson.M{"category_id": categoryId})
+		if err != nil {
+			apierror.Render(c, apierror.Internal("error occurred while checking the category's foods"))
+			return
+		}
+		if inUse > 0 {
+			apierror.Render(c, apierror.Conflict("Move the category's foods to another category first").With("foods", inUse))
+			return
+		}
+
+		result, err := categoryCollection().DeleteOne(ctx, bson.M{"category_id": categoryId})
+		if err != nil {
+			apierror.Render(c, apierror.Internal("Delete failed: "+err.Error()))
+			return
+		}
+		if result.DeletedCount == 0 {
+			apierror.Render(c, apierror.NotFound("Category not found"))
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Category deleted"})
+	}
+}
+
+// categoryNameTaken reports whether another category than exceptId already
+// has name, ignoring case.
+func categoryNameTaken(ctx context.Context, name, exceptId string) (bool, error) {
+	filter := bson.M{"name": primitive.Regex{Pattern: "^" + regexp.QuoteMeta(name) + "$", Options: "i"}}
+	if exceptId != "" {
+		filter["category_id"] = bson.M{"$ne": exceptId}
+	}
+	count, err := categoryCollection().CountDocuments(ctx, filter)
+	return count > 0, err
+}
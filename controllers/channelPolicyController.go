@@ -0,0 +1,209 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"restaurant-management/apierror"
+	"restaurant-management/config"
+	"restaurant-management/models"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var channelPolicyCollection = db.Collection("channelPolicy")
+
+type ChannelPolicyViolation struct {
+	Rule    string `json:"rule"`
+	Food_id string `json:"food_id,omitempty"`
+	Message string `json:"message"`
+}
+
+func GetChannelPolicies() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		result, err := channelPolicyCollection().Find(ctx, bson.M{})
+		if err != nil {
+			apierror.Render(c, apierror.Internal("error occurred while listing channel policies: "+err.Error()))
+			return
+		}
+
+		var allPolicies []bson.M
+		if err = result.All(ctx, &allPolicies); err != nil {
+			apierror.Render(c, apierror.Internal("error decoding channel policies: "+err.Error()))
+			return
+		}
+
+		c.JSON(http.StatusOK, allPolicies)
+	}
+}
+
+func GetChannelPolicy() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		var policy models.ChannelPolicy
+		err := channelPolicyCollection().FindOne(ctx, bson.M{"channel": c.Param("channel")}).Decode(&policy)
+		if err != nil {
+			apierror.Render(c, apierror.NotFound("Channel policy not found"))
+			return
+		}
+
+		c.JSON(http.StatusOK, policy)
+	}
+}
+
+// UpdateChannelPolicy creates or updates the ordering limits of a channel.
+func UpdateChannelPolicy() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		channel := c.Param("channel")
+		if err := validate.Var(channel, "eq=DINE_IN|eq=TAKEOUT|eq=DELIVERY"); err != nil {
+			apierror.Render(c, apierror.Validation("Channel must be DINE_IN, TAKEOUT or DELIVERY"))
+			return
+		}
+
+		var policy models.ChannelPolicy
+		if err := c.BindJSON(&policy); err != nil {
+			apierror.Render(c, apierror.BadRequest("Invalid request data: "+err.Error()))
+			return
+		}
+
+		if err := validate.Struct(policy); err != nil {
+			apierror.Render(c, apierror.FromValidation(err))
+			return
+		}
+
+		var updateObj primitive.D
+		if policy.Min_order_value != nil {
+			updateObj = append(updateObj, bson.E{Key: "min_order_value", Value: policy.Min_order_value})
+		}
+		if policy.Max_quantity_per_item != nil {
+			updateObj = append(updateObj, bson.E{Key: "max_quantity_per_item", Value: policy.Max_quantity_per_item})
+		}
+		if policy.Excluded_food_ids != nil {
+			updateObj = append(updateObj, bson.E{Key: "excluded_food_ids", Value: policy.Excluded_food_ids})
+		}
+
+		now, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		updateObj = append(updateObj, bson.E{Key: "updated_at", Value: now})
+
+		upsert := true
+		opt := options.UpdateOptions{Upsert: &upsert}
+
+		result, err := channelPolicyCollection().UpdateOne(
+			ctx,
+			bson.M{"channel": channel},
+			bson.D{
+				{Key: "$set", Value: updateObj},
+				{Key: "$setOnInsert", Value: bson.D{{Key: "_id", Value: primitive.NewObjectID()}, {Key: "created_at", Value: now}}},
+			},
+			&opt,
+		)
+		if err != nil {
+			apierror.Render(c, apierror.Internal("Update failed: "+err.Error()))
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Channel policy updated successfully", "result": result})
+	}
+}
+
+// CheckChannelPolicy validates a prospective order against its channel's
+// limits so clients can surface problems at checkout before submitting.
+func CheckChannelPolicy() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		var orderItemPack OrderItemPack
+		if err := c.BindJSON(&orderItemPack); err != nil {
+			apierror.Render(c, apierror.BadRequest(err.Error()))
+			return
+		}
+
+		channel := defaultChannel
+		if orderItemPack.Channel != nil {
+			channel = *orderItemPack.Channel
+		}
+
+		violations, err := channelPolicyViolations(ctx, channel, orderItemPack.Order_items)
+		if err != nil {
+			apierror.Render(c, apierror.Internal("error occurred while checking channel policy: "+err.Error()))
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"channel": channel, "valid": len(violations) == 0, "violations": violations})
+	}
+}
+
+// channelPolicyViolations checks order items against the channel's minimum
+// order value, per-item quantity cap and excluded foods. Channels without a
+// policy accept everything.
+func channelPolicyViolations(ctx context.Context, channel string, orderItems []models.OrderItem) ([]ChannelPolicyViolation, error) {
+	violations := []ChannelPolicyViolation{}
+
+	var policy models.ChannelPolicy
+	err := channelPolicyCollection().FindOne(ctx, bson.M{"channel": channel}).Decode(&policy)
+	if err == mongo.ErrNoDocuments {
+		return violations, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	excluded := map[string]bool{}
+	for _, foodId := range policy.Excluded_food_ids {
+		excluded[foodId] = true
+	}
+
+	total := 0.0
+	quantities := map[string]int{}
+	for _, orderItem := range orderItems {
+		if orderItem.Unit_price != nil {
+			total += *orderItem.Unit_price
+		}
+		if orderItem.Food_id == nil {
+			continue
+		}
+		quantities[*orderItem.Food_id]++
+		if excluded[*orderItem.Food_id] && quantities[*orderItem.Food_id] == 1 {
+			violations = append(violations, ChannelPolicyViolation{
+				Rule:    "EXCLUDED_ITEM",
+				Food_id: *orderItem.Food_id,
+				Message: fmt.Sprintf("food is not available for %s orders", channel),
+			})
+		}
+	}
+
+	if policy.Max_quantity_per_item != nil {
+		for foodId, quantity := range quantities {
+			if quantity > *policy.Max_quantity_per_item {
+				violations = append(violations, ChannelPolicyViolation{
+					Rule:    "MAX_QUANTITY",
+					Food_id: foodId,
+					Message: fmt.Sprintf("at most %d of this food per %s order", *policy.Max_quantity_per_item, channel),
+				})
+			}
+		}
+	}
+
+	if policy.Min_order_value != nil && toFixed(total, 2) < *policy.Min_order_value {
+		violations = append(violations, ChannelPolicyViolation{
+			Rule:    "MIN_ORDER_VALUE",
+			Message: fmt.Sprintf("%s orders must total at least %.2f", channel, *policy.Min_order_value),
+		})
+	}
+
+	return violations, nil
+}
@@ -0,0 +1,549 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"restaurant-management/apierror"
+	"restaurant-management/config"
+	"restaurant-management/helpers"
+	"restaurant-management/models"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var checklistCollection = db.Collection("checklist")
+var checklistCompletionCollection = db.Collection("checklistCompletion")
+var checklistAlertCollection = db.Collection("checklistAlert")
+
+// readinessTarget is the completion rate a location needs, together with no
+// open alerts or failed critical items, to be reported inspection-ready.
+const readinessTarget = 0.95
+
+func GetChecklists() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		filter := bson.M{}
+		for _, key := range []string{"type", "location"} {
+			if value := c.Query(key); value != "" {
+				filter[key] = value
+			}
+		}
+
+		result, err := checklistCollection().Find(ctx, filter)
+		if err != nil {
+			apierror.Render(c, apierror.Internal("error occurred while listing checklists: "+err.Error()))
+			return
+		}
+
+		var allChecklists []bson.M
+		if err = result.All(ctx, &allChecklists); err != nil {
+			apierror.Render(c, apierror.Internal("error decoding checklists: "+err.Error()))
+			return
+		}
+
+		c.JSON(http.StatusOK, allChecklists)
+	}
+}
+
+func GetChecklist() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		var checklist models.Checklist
+		if err := checklistCollection().FindOne(ctx, bson.M{"checklist_id": c.Param("checklist_id")}).Decode(&checklist); err != nil {
+			apierror.Render(c, apierror.NotFound("Checklist not found"))
+			return
+		}
+
+		c.JSON(http.StatusOK, checklist)
+	}
+}
+
+func CreateChecklist() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		var checklist models.Checklist
+		if err := c.BindJSON(&checklist); err != nil {
+			apierror.Render(c, apierror.BadRequest("Invalid request data: "+err.Error()))
+			return
+		}
+		if err := validate.Struct(checklist); err != nil {
+			apierror.Render(c, apierror.FromValidation(err))
+			return
+		}
+
+		if checklist.Location == nil {
+			location := defaultLocation
+			checklist.Location = &location
+		}
+		if checklist.Active == nil {
+			active := true
+			checklist.Active = &active
+		}
+		for i := range checklist.Items {
+			checklist.Items[i].Item_id = primitive.NewObjectID().Hex()
+		}
+		checklist.Created_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		checklist.Updated_at = checklist.Created_at
+		checklist.ID = primitive.NewObjectID()
+		checklist.Checklist_id = checklist.ID.Hex()
+
+		result, err := checklistCollection().InsertOne(ctx, checklist)
+		if err != nil {
+			apierror.Render(c, apierror.Internal("Checklist was not created: "+err.Error()))
+			return
+		}
+
+		c.JSON(http.StatusCreated, gin.H{"message": "Checklist created", "data": result})
+	}
+}
+
+// UpdateChecklist edits a checklist. Items sent with an item_id keep it so
+// past completions still line up; new items get a fresh id.
+func UpdateChecklist() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		checklistId := c.Param("checklist_id")
+
+		var existing models.Checklist
+		if err := checklistCollection().FindOne(ctx, bson.M{"checklist_id": checklistId}).Decode(&existing); err != nil {
+			apierror.Render(c, apierror.NotFound("Checklist not found"))
+			return
+		}
+
+		var checklist models.Checklist
+		if err := c.BindJSON(&checklist); err != nil {
+			apierror.Render(c, apierror.BadRequest("Invalid request data: "+err.Error()))
+			return
+		}
+
+		var updateObj primitive.D
+		if checklist.Name != nil {
+			existing.Name = checklist.Name
+			updateObj = append(updateObj, bson.E{Key: "name", Value: checklist.Name})
+		}
+		if checklist.Items != nil {
+			for i := range checklist.Items {
+				if checklist.Items[i].Item_id == "" {
+					checklist.Items[i].Item_id = primitive.NewObjectID().Hex()
+				}
+			}
+			existing.Items = checklist.Items
+			updateObj = append(updateObj, bson.E{Key: "items", Value: checklist.Items})
+		}
+		if checklist.Due_time != nil {
+			existing.Due_time = checklist.Due_time
+			updateObj = append(updateObj, bson.E{Key: "due_time", Value: checklist.Due_time})
+		}
+		if checklist.Due_day != nil {
+			existing.Due_day = checklist.Due_day
+			updateObj = append(updateObj, bson.E{Key: "due_day", Value: checklist.Due_day})
+		}
+		if checklist.Active != nil {
+			updateObj = append(updateObj, bson.E{Key: "active", Value: checklist.Active})
+		}
+		if err := validate.Struct(existing); err != nil {
+			apierror.Render(c, apierror.FromValidation(err))
+			return
+		}
+
+		updatedAt, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		updateObj = append(updateObj, bson.E{Key: "updated_at", Value: updatedAt})
+
+		result, err := checklistCollection().UpdateOne(ctx, bson.M{"checklist_id": checklistId}, bson.D{{Key: "$set", Value: updateObj}})
+		if err != nil {
+			apierror.Render(c, apierror.Internal("Update failed: "+err.Error()))
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Checklist updated successfully", "result": result})
+	}
+}
+
+// UploadChecklistPhoto stores a photo (multipart field "photo") to be
+// referenced from a completion item.
+func UploadChecklistPhoto() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		checklistId := c.Param("checklist_id")
+		count, err := checklistCollection().CountDocuments(ctx, bson.M{"checklist_id": checklistId})
+		if err != nil || count == 0 {
+			apierror.Render(c, apierror.NotFound("Checklist not found"))
+			return
+		}
+
+		photoUrl, err := helpers.SaveUpload(c, "photo", "checklists", checklistId+"-"+primitive.NewObjectID().Hex(), helpers.ImageExtensions)
+		if err != nil {
+			apierror.Render(c, apierror.BadRequest(err.Error()))
+			return
+		}
+
+		c.JSON(http.StatusCreated, gin.H{"photo_url": photoUrl})
+	}
+}
+
+// CompleteChecklist signs off a checklist. Every item must be answered and
+// photo items need a photo. Without ?due_date= the completion is filed
+// against the earliest outstanding due date.
+func CompleteChecklist() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		var checklist models.Checklist
+		if err := checklistCollection().FindOne(ctx, bson.M{"checklist_id": c.Param("checklist_id")}).Decode(&checklist); err != nil {
+			apierror.Render(c, apierror.NotFound("Checklist not found"))
+			return
+		}
+
+		var completion models.ChecklistCompletion
+		if err := c.BindJSON(&completion); err != nil {
+			apierror.Render(c, apierror.BadRequest("Invalid request data: "+err.Error()))
+			return
+		}
+		if err := validate.Struct(completion); err != nil {
+			apierror.Render(c, apierror.FromValidation(err))
+			return
+		}
+
+		answers := map[string]models.CompletedItem{}
+		for _, item := range completion.Items {
+			answers[*item.Item_id] = item
+		}
+		completion.Failed_items = 0
+		completion.Critical_fail = false
+		for _, item := range checklist.Items {
+			answer, ok := answers[item.Item_id]
+			if !ok {
+				apierror.Render(c, apierror.BadRequest(fmt.Sprintf("item %q has not been answered", *item.Text)))
+				return
+			}
+			if item.Requires_photo && (answer.Photo_url == nil || *answer.Photo_url == "") {
+				apierror.Render(c, apierror.BadRequest(fmt.Sprintf("item %q requires a photo", *item.Text)))
+				return
+			}
+			if !*answer.Passed {
+				completion.Failed_items++
+				completion.Critical_fail = completion.Critical_fail || item.Critical
+			}
+		}
+
+		now := time.Now()
+		dueDate := c.Query("due_date")
+		if dueDate == "" {
+			due, err := outstandingDueDate(ctx, checklist, now)
+			if err != nil {
+				apierror.Render(c, apierror.Internal("error finding due date: "+err.Error()))
+				return
+			}
+			dueDate = due.Format("2006-01-02")
+		} else if _, err := time.Parse("2006-01-02", dueDate); err != nil {
+			apierror.Render(c, apierror.BadRequest("due_date must be formatted as YYYY-MM-DD"))
+			return
+		}
+
+		count, err := checklistCompletionCollection().CountDocuments(ctx, bson.M{"checklist_id": checklist.Checklist_id, "due_date": dueDate})
+		if err != nil {
+			apierror.Render(c, apierror.Internal("error checking completions: "+err.Error()))
+			return
+		}
+		if count > 0 {
+			apierror.Render(c, apierror.Conflict("Checklist has already been completed for "+dueDate))
+			return
+		}
+
+		completion.Checklist_id = checklist.Checklist_id
+		completion.Due_date = dueDate
+		completion.Completed_at, _ = time.Parse(time.RFC3339, now.Format(time.RFC3339))
+		completion.ID = primitive.NewObjectID()
+		completion.Completion_id = completion.ID.Hex()
+
+		result, err := checklistCompletionCollection().InsertOne(ctx, completion)
+		if err != nil {
+			apierror.Render(c, apierror.Internal("Completion was not recorded: "+err.Error()))
+			return
+		}
+
+		_, err = checklistAlertCollection().UpdateMany(
+			ctx,
+			bson.M{"checklist_id": checklist.Checklist_id, "due_date": dueDate, "resolved": false},
+			bson.D{{Key: "$set", Value: bson.D{{Key: "resolved", Value: true}}}},
+		)
+		if err != nil {
+			log.Println("Error resolving overdue alerts:", err)
+		}
+
+		c.JSON(http.StatusCreated, gin.H{"message": "Checklist completed", "data": result, "due_date": dueDate, "failed_items": completion.Failed_items, "critical_fail": completion.Critical_fail})
+	}
+}
+
+func GetChecklistCompletions() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		opts := options.Find().SetSort(bson.D{{Key: "due_date", Value: -1}}).SetLimit(100)
+		result, err := checklistCompletionCollection().Find(ctx, bson.M{"checklist_id": c.Param("checklist_id")}, opts)
+		if err != nil {
+			apierror.Render(c, apierror.Internal("error occurred while listing completions: "+err.Error()))
+			return
+		}
+
+		var allCompletions []bson.M
+		if err = result.All(ctx, &allCompletions); err != nil {
+			apierror.Render(c, apierror.Internal("error decoding completions: "+err.Error()))
+			return
+		}
+
+		c.JSON(http.StatusOK, allCompletions)
+	}
+}
+
+func GetChecklistAlerts() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}})
+		result, err := checklistAlertCollection().Find(ctx, bson.M{"resolved": c.Query("resolved") == "true"}, opts)
+		if err != nil {
+			apierror.Render(c, apierror.Internal("error occurred while listing alerts: "+err.Error()))
+			return
+		}
+
+		var allAlerts []bson.M
+		if err = result.All(ctx, &allAlerts); err != nil {
+			apierror.Render(c, apierror.Internal("error decoding alerts: "+err.Error()))
+			return
+		}
+
+		c.JSON(http.StatusOK, allAlerts)
+	}
+}
+
+// GetInspectionReadiness scores a location on the last ?days= (default 30)
+// of checklist completions, failed critical items and open food-safety
+// alerts.
+func GetInspectionReadiness() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		days, err := strconv.Atoi(c.DefaultQuery("days", "30"))
+		if err != nil || days < 1 || days > 365 {
+			apierror.Render(c, apierror.BadRequest("days must be between 1 and 365"))
+			return
+		}
+		location := c.DefaultQuery("location", defaultLocation)
+
+		cursor, err := checklistCollection().Find(ctx, bson.M{"location": location, "active": true})
+		if err != nil {
+			apierror.Render(c, apierror.Internal("error occurred while listing checklists: "+err.Error()))
+			return
+		}
+		var checklists []models.Checklist
+		if err = cursor.All(ctx, &checklists); err != nil {
+			apierror.Render(c, apierror.Internal("error decoding checklists: "+err.Error()))
+			return
+		}
+
+		now := time.Now()
+		since := now.AddDate(0, 0, -days)
+		type checklistScore struct {
+			Checklist_id    string   `json:"checklist_id"`
+			Name            string   `json:"name"`
+			Expected        int      `json:"expected"`
+			Completed       int      `json:"completed"`
+			Missed_dates    []string `json:"missed_dates"`
+			Failed_items    int      `json:"failed_items"`
+			Open_critical   bool     `json:"open_critical_failure"`
+			Last_completed  *string  `json:"last_completed"`
+			Completion_rate float64  `json:"completion_rate"`
+		}
+		scores := []checklistScore{}
+		expected, completed := 0, 0
+		openCritical := false
+		checklistIds := []string{}
+		for _, checklist := range checklists {
+			checklistIds = append(checklistIds, checklist.Checklist_id)
+			score := checklistScore{Checklist_id: checklist.Checklist_id, Name: *checklist.Name, Missed_dates: []string{}}
+
+			completionCursor, err := checklistCompletionCollection().Find(ctx, bson.M{"checklist_id": checklist.Checklist_id, "due_date": bson.M{"$gte": since.Format("2006-01-02")}}, options.Find().SetSort(bson.D{{Key: "due_date", Value: 1}}))
+			if err != nil {
+				apierror.Render(c, apierror.Internal("error occurred while listing completions: "+err.Error()))
+				return
+			}
+			var completions []models.ChecklistCompletion
+			if err = completionCursor.All(ctx, &completions); err != nil {
+				apierror.Render(c, apierror.Internal("error decoding completions: "+err.Error()))
+				return
+			}
+			done := map[string]bool{}
+			for _, completion := range completions {
+				done[completion.Due_date] = true
+				score.Failed_items += completion.Failed_items
+			}
+			if len(completions) > 0 {
+				latest := completions[len(completions)-1]
+				score.Last_completed = &latest.Due_date
+				score.Open_critical = latest.Critical_fail
+			}
+
+			for _, due := range checklistDueDates(checklist, since, now) {
+				score.Expected++
+				if done[due.Format("2006-01-02")] {
+					score.Completed++
+				} else {
+					score.Missed_dates = append(score.Missed_dates, due.Format("2006-01-02"))
+				}
+			}
+			score.Completion_rate = 1
+			if score.Expected > 0 {
+				score.Completion_rate = toFixed(float64(score.Completed)/float64(score.Expected), 2)
+			}
+			expected += score.Expected
+			completed += score.Completed
+			openCritical = openCritical || score.Open_critical
+			scores = append(scores, score)
+		}
+
+		openChecklistAlerts, err := checklistAlertCollection().CountDocuments(ctx, bson.M{"checklist_id": bson.M{"$in": checklistIds}, "resolved": false})
+		if err != nil {
+			apierror.Render(c, apierror.Internal("error counting checklist alerts: "+err.Error()))
+			return
+		}
+		openTemperatureAlerts, err := openTemperatureAlertCount(ctx, location)
+		if err != nil {
+			apierror.Render(c, apierror.Internal("error counting temperature alerts: "+err.Error()))
+			return
+		}
+
+		rate := 1.0
+		if expected > 0 {
+			rate = toFixed(float64(completed)/float64(expected), 2)
+		}
+		ready := rate >= readinessTarget && !openCritical && openChecklistAlerts == 0 && openTemperatureAlerts == 0
+
+		c.JSON(http.StatusOK, gin.H{
+			"location":                location,
+			"days":                    days,
+			"ready":                   ready,
+			"completion_rate":         rate,
+			"open_critical_failures":  openCritical,
+			"open_checklist_alerts":   openChecklistAlerts,
+			"open_temperature_alerts": openTemperatureAlerts,
+			"checklists":              scores,
+		})
+	}
+}
+
+// CheckOverdueChecklists raises one alert per checklist and due date once
+// the deadline has passed without a completion. It runs on the scheduler.
+func CheckOverdueChecklists(ctx context.Context) error {
+	cursor, err := checklistCollection().Find(ctx, bson.M{"active": true})
+	if err != nil {
+		return err
+	}
+	var checklists []models.Checklist
+	if err = cursor.All(ctx, &checklists); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	for _, checklist := range checklists {
+		due, err := outstandingDueDate(ctx, checklist, now)
+		if err != nil {
+			return err
+		}
+		if now.Before(checklistDeadline(checklist, due)) {
+			continue
+		}
+		dueDate := due.Format("2006-01-02")
+
+		open, err := checklistAlertCollection().CountDocuments(ctx, bson.M{"checklist_id": checklist.Checklist_id, "due_date": dueDate})
+		if err != nil {
+			return err
+		}
+		if open > 0 {
+			continue
+		}
+
+		alert := models.ChecklistAlert{
+			ID:           primitive.NewObjectID(),
+			Checklist_id: checklist.Checklist_id,
+			Due_date:     dueDate,
+			Message:      fmt.Sprintf("%s was due at %s on %s and has not been completed", *checklist.Name, *checklist.Due_time, dueDate),
+		}
+		alert.Created_at, _ = time.Parse(time.RFC3339, now.Format(time.RFC3339))
+		alert.Alert_id = alert.ID.Hex()
+		if _, err := checklistAlertCollection().InsertOne(ctx, alert); err != nil {
+			return err
+		}
+		log.Printf("checklist alert: %s", alert.Message)
+	}
+	return nil
+}
+
+// checklistDeadline is the moment a checklist is due on the given day.
+func checklistDeadline(checklist models.Checklist, day time.Time) time.Time {
+	clock, _ := time.Parse("15:04", *checklist.Due_time)
+	return time.Date(day.Year(), day.Month(), day.Day(), clock.Hour(), clock.Minute(), 0, 0, day.Location())
+}
+
+// checklistDueDates lists the days whose deadline falls in (since, until]
+// and after the checklist was created.
+func checklistDueDates(checklist models.Checklist, since, until time.Time) []time.Time {
+	dates := []time.Time{}
+	for day := since; !day.After(until); day = day.AddDate(0, 0, 1) {
+		if *checklist.Type == "WEEKLY" && weekdayCodes[day.Weekday()] != *checklist.Due_day {
+			continue
+		}
+		deadline := checklistDeadline(checklist, day)
+		if deadline.After(since) && !deadline.After(until) && deadline.After(checklist.Created_at) {
+			dates = append(dates, day)
+		}
+	}
+	return dates
+}
+
+// outstandingDueDate returns the most recent due date if it has not been
+// completed yet, otherwise the next one.
+func outstandingDueDate(ctx context.Context, checklist models.Checklist, now time.Time) (time.Time, error) {
+	step := 1
+	previous := now
+	if *checklist.Type == "WEEKLY" {
+		step = 7
+		for weekdayCodes[previous.Weekday()] != *checklist.Due_day {
+			previous = previous.AddDate(0, 0, -1)
+		}
+	}
+	next := previous.AddDate(0, 0, step)
+	if !checklistDeadline(checklist, previous).After(checklist.Created_at) {
+		return next, nil
+	}
+
+	count, err := checklistCompletionCollection().CountDocuments(ctx, bson.M{"checklist_id": checklist.Checklist_id, "due_date": previous.Format("2006-01-02")})
+	if err != nil {
+		return previous, err
+	}
+	if count > 0 {
+		return next, nil
+	}
+	return previous, nil
+}
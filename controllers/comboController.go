@@ -0,0 +1,390 @@
+package controllers
+
+import (
+	"context"
+	"net/http"
+	"restaurant-management/apierror"
+	"restaurant-management/config"
+	"restaurant-management/models"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var comboCollection = db.Collection("combo")
+
+// defaultComboSize is the size of a combo's foods when the combo does not
+// give one.
+const defaultComboSize = "M"
+
+// GetCombos lists the combos, only the ones on sale with ?active=true.
+func GetCombos() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		filter := bson.M{}
+		if c.Query("active") == "true" {
+			filter["active"] = true
+		}
+		result, err := comboCollection().Find(ctx, filter)
+		if err != nil {
+			apierror.Render(c, apierror.Internal("error occurred while listing combos: "+err.Error()))
+			return
+		}
+
+		var allCombos []bson.M
+		if err = result.All(ctx, &allCombos); err != nil {
+			apierror.Render(c, apierror.Internal("error decoding combos: "+err.Error()))
+			return
+		}
+
+		c.JSON(http.StatusOK, allCombos)
+	}
+}
+
+func GetCombo() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		var combo models.Combo
+		if err := comboCollection().FindOne(ctx, bson.M{"combo_id": c.Param("combo_id")}).Decode(&combo); err != nil {
+			apierror.Render(c, apierror.NotFound("Combo not found"))
+			return
+		}
+
+		c.JSON(http.StatusOK, combo)
+	}
+}
+
+func CreateCombo() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		var combo models.Combo
+		if err := c.BindJSON(&combo); err != nil {
+			apierror.Render(c, apierror.BadRequest("Invalid request data: "+err.Error()))
+			return
+		}
+		if err := validate.Struct(combo); err != nil {
+			apierror.Render(c, apierror.FromValidation(err))
+			return
+		}
+		if unknown, err := unknownComboFoods(ctx, combo.Items); err != nil {
+			apierror.Render(c, apierror.Internal("error occurred while loading foods: "+err.Error()))
+			return
+		} else if len(unknown) > 0 {
+			apierror.Render(c, apierror.BadRequest("Some foods do not exist").With("food_ids", unknown))
+			return
+		}
+
+		price := toFixed(*combo.Price, 2)
+		combo.Price = &price
+		if combo.Active == nil {
+			active := true
+			combo.Active = &active
+		}
+
+		now := time.Now().Format(time.RFC3339)
+		combo.Created_at, _ = time.Parse(time.RFC3339, now)
+		combo.Updated_at, _ = time.Parse(time.RFC3339, now)
+		combo.ID = primitive.NewObjectID()
+		combo.Combo_id = combo.ID.Hex()
+
+		if _, err := comboCollection().InsertOne(ctx, combo); err != nil {
+			apierror.Render(c, apierror.Internal("Could not create combo"))
+			return
+		}
+		auditCreated(c, combo.Combo_id)
+
+		c.JSON(http.StatusCreated, gin.H{"message": "Combo created", "data": combo})
+	}
+}
+
+// UpdateCombo changes a combo for the orders placed from now on; items
+// already ordered keep the foods and price they were rung up with.
+func UpdateCombo() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		var combo models.Combo
+		if err := c.BindJSON(&combo); err != nil {
+			apierror.Render(c, apierror.BadRequest("Invalid request data: "+err.Error()))
+			return
+		}
+
+		var updateObj primitive.D
+		if combo.Name != nil {
+			if err := validate.StructPartial(combo, "Name"); err != nil {
+				apierror.Render(c, apierror.FromValidation(err))
+				return
+			}
+			updateObj = append(updateObj, bson.E{Key: "name", Value: combo.Name})
+		}
+		if combo.Items != nil {
+			if err := validate.StructPartial(combo, "Items"); err != nil {
+				apierror.Render(c, apierror.FromValidation(err))
+				return
+			}
+			if unknown, err := unknownComboFoods(ctx, combo.Items); err != nil {
+				apierror.Render(c, apierror.Internal("error occurred while loading foods: "+err.Error()))
+				return
+			} else if len(unknown) > 0 {
+				apierror.Render(c, apierror.BadRequest("Some foods do not exist").With("food_ids", unknown))
+				return
+			}
+			updateObj = append(updateObj, bson.E{Key: "items", Value: combo.Items})
+		}
+		if combo.Price != nil {
+			if err := validate.StructPartial(combo, "Price"); err != nil {
+				apierror.Render(c, apierror.FromValidation(err))
+				return
+			}
+			updateObj = append(updateObj, bson.E{Key: "price", Value: toFixed(*combo.Price, 2)})
+		}
+		if combo.Active != nil {
+			updateObj = append(updateObj, bson.E{Key: "active", Value: combo.Active})
+		}
+
+		updatedAt, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		updateObj = append(updateObj, bson.E{Key: "updated_at", Value: updatedAt})
+
+		result, err := comboCollection().UpdateOne(ctx, bson.M{"combo_id": c.Param("combo_id")}, bson.D{{Key: "$set", Value: updateObj}})
+		if err != nil {
+			apierror.Render(c, apierror.Internal("Update failed: "+err.Error()))
+			return
+		}
+		if result.MatchedCount == 0 {
+			apierror.Render(c, apierror.NotFound("Combo not found"))
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Combo updated successfully"})
+	}
+}
+
+// DeleteCombo removes a combo that was never ordered. Combos on past orders
+// are kept so their bills still show them; set active to false instead.
+func DeleteCombo() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		comboId := c.Param("combo_id")
+		ordered, err := orderItemCollection().CountDocuments(ctx, bson.M{"combo_id": comboId})
+		if err != nil {
+			apierror.Render(c, apierror.Internal("error occurred while checking the combo's orders"))
+			return
+		}
+		if ordered > 0 {
+			apierror.Render(c, apierror.Conflict("The combo was ordered; deactivate it instead"))
+			return
+		}
+
+		result, err := comboCollection().DeleteOne(ctx, bson.M{"combo_id": comboId})
+		if err != nil {
+			apierror.Render(c, apierror.Internal("Delete failed: "+err.Error()))
+			return
+		}
+		if result.DeletedCount == 0 {
+			apierror.Render(c, apierror.NotFound("Combo not found"))
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Combo deleted"})
+	}
+}
+
+func unknownComboFoods(ctx context.Context, items []models.ComboItem) ([]string, error) {
+	foodIds := []string{}
+	for _, item := range items {
+		foodIds = append(foodIds, *item.Food_id)
+	}
+	foods, err := foodsById(ctx, foodIds)
+	if err != nil {
+		return nil, err
+	}
+	unknown := []string{}
+	for _, foodId := range foodIds {
+		if _, ok := foods[foodId]; !ok {
+			unknown = append(unknown, foodId)
+		}
+	}
+	return unknown, nil
+}
+
+func combosById(ctx context.Context, comboIds []string) (map[string]models.Combo, error) {
+	byId := map[string]models.Combo{}
+	if len(comboIds) == 0 {
+		return byId, nil
+	}
+	cursor, err := comboCollection().Find(ctx, bson.M{"combo_id": bson.M{"$in": comboIds}})
+	if err != nil {
+		return nil, err
+	}
+	var combos []models.Combo
+	if err = cursor.All(ctx, &combos); err != nil {
+		return nil, err
+	}
+	for _, combo := range combos {
+		byId[combo.Combo_id] = combo
+	}
+	return byId, nil
+}
+
+// expandCombos replaces every item ordering a combo by one item per food of
+// the combo. The combo's price is split over its foods in proportion to
+// their menu prices, so sales and tax reports stay right per food, and the
+// items share a Combo_line_id so bills show them as one line. Only the
+// allergy note is carried over to the foods. Combos that do not exist or are
+// inactive are returned as unavailable.
+func expandCombos(ctx context.Context, orderItems []models.OrderItem) ([]models.OrderItem, []string, error) {
+	comboIds := []string{}
+	for _, orderItem := range orderItems {
+		if orderItem.Combo_id != nil {
+			comboIds = append(comboIds, *orderItem.Combo_id)
+		}
+	}
+	if len(comboIds) == 0 {
+		return orderItems, nil, nil
+	}
+
+	combos, err := combosById(ctx, comboIds)
+	if err != nil {
+		return nil, nil, err
+	}
+	foodIds := []string{}
+	for _, combo := range combos {
+		for _, item := range combo.Items {
+			foodIds = append(foodIds, *item.Food_id)
+		}
+	}
+	foods, err := foodsById(ctx, foodIds)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	expanded := []models.OrderItem{}
+	unavailable := []string{}
+	for _, orderItem := range orderItems {
+		if orderItem.Combo_id == nil {
+			expanded = append(expanded, orderItem)
+			continue
+		}
+		combo, ok := combos[*orderItem.Combo_id]
+		if !ok || combo.Active == nil || !*combo.Active {
+			unavailable = append(unavailable, *orderItem.Combo_id)
+			continue
+		}
+
+		weights := make([]float64, len(combo.Items))
+		for i, item := range combo.Items {
+			if food, ok := foods[*item.Food_id]; ok && food.Price != nil {
+				weights[i] = *food.Price
+			}
+		}
+		shares := splitAmount(*combo.Price, weights)
+
+		lineId := primitive.NewObjectID().Hex()
+		for i, item := range combo.Items {
+			foodId, size, share := *item.Food_id, stringOr(item.Quantity, defaultComboSize), shares[i]
+			expanded = append(expanded, models.OrderItem{
+				Food_id:       &foodId,
+				Quantity:      &size,
+				Unit_price:    &share,
+				Allergy_note:  orderItem.Allergy_note,
+				Combo_id:      orderItem.Combo_id,
+				Combo_line_id: lineId,
+			})
+		}
+	}
+	return expanded, unavailable, nil
+}
+
+// splitAmount splits amount in proportion to weights, rounded to cents, with
+// the rounding left on the last share. Without weights it splits evenly.
+func splitAmount(amount float64, weights []float64) []float64 {
+	total := 0.0
+	for _, weight := range weights {
+		total += weight
+	}
+	shares := make([]float64, len(weights))
+	left := amount
+	for i, weight := range weights {
+		if i == len(weights)-1 {
+			shares[i] = toFixed(left, 2)
+			break
+		}
+		if total > 0 {
+			shares[i] = toFixed(amount*weight/total, 2)
+		} else {
+			shares[i] = toFixed(amount/float64(len(weights)), 2)
+		}
+		left -= shares[i]
+	}
+	return shares
+}
+
+// collapseComboLines folds the items of each ordered combo, as listed by
+// ItemsByOrder, into a single line named after the combo and priced at the
+// sum of its items, listing the foods as components.
+func collapseComboLines(orderItems interface{}) interface{} {
+	items, ok := orderItems.(primitive.A)
+	if !ok {
+		return orderItems
+	}
+
+	lines := primitive.A{}
+	comboLines := map[string]primitive.M{}
+	for _, entry := range items {
+		item, ok := entry.(primitive.M)
+		lineId, _ := item["combo_line_id"].(string)
+		if !ok || lineId == "" {
+			lines = append(lines, entry)
+			continue
+		}
+
+		price, _ := item["unit_price"].(float64)
+		adjustments, _ := item["adjustments"].(primitive.A)
+		component := primitive.M{"order_item_id": item["order_item_id"], "food_id": item["food_id"], "food_name": item["food_name"], "quantity": item["quantity"]}
+		line, seen := comboLines[lineId]
+		if !seen {
+			line = primitive.M{
+				"combo_id":      item["combo_id"],
+				"combo_line_id": lineId,
+				"food_name":     item["combo_name"],
+				"unit_price":    0.0,
+				"adjustments":   primitive.A{},
+				"allergy_note":  item["allergy_note"],
+				"server_id":     item["server_id"],
+				"created_at":    item["created_at"],
+				"components":    primitive.A{},
+			}
+			comboLines[lineId] = line
+			lines = append(lines, line)
+		}
+		line["unit_price"] = toFixed(line["unit_price"].(float64)+price, 2)
+		line["adjustments"] = append(line["adjustments"].(primitive.A), adjustments...)
+		line["components"] = append(line["components"].(primitive.A), component)
+	}
+	return lines
+}
+
+// activeCombosWith matches the active combos that include any of foodIds.
+func activeCombosWith(foodIds []string) bson.M {
+	return bson.M{"items.food_id": bson.M{"$in": foodIds}, "active": bson.M{"$ne": false}}
+}
+
+func deactivateCombos(ctx context.Context, comboIds []string) error {
+	if len(comboIds) == 0 {
+		return nil
+	}
+	now, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+	_, err := comboCollection().UpdateMany(ctx, bson.M{"combo_id": bson.M{"$in": comboIds}}, bson.M{"$set": bson.M{"active": false, "updated_at": now}})
+	return err
+}
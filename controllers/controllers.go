@@ -0,0 +1,16 @@
+package controllers
+
+import (
+	"restaurant-management/database"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// db is the client the controllers' collections are looked up on.
+var db database.Handle
+
+// Bind gives the handlers and background jobs the client to work with. It
+// must be called before any of them run.
+func Bind(client *mongo.Client) {
+	db.Bind(client)
+}
@@ -0,0 +1,213 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"restaurant-management/apierror"
+	"restaurant-management/config"
+	"restaurant-management/database"
+	"restaurant-management/models"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var creditNoteCollection = db.Collection("creditNote")
+var creditNoteSequenceCollection = db.Collection("creditNoteSequence")
+
+var errNothingToCredit = errors.New("nothing left to credit on this invoice")
+
+func GetCreditNotes() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		filter := bson.M{}
+		if invoiceId := c.Query("invoice_id"); invoiceId != "" {
+			filter["invoice_id"] = invoiceId
+		}
+
+		opts := options.Find().SetSort(bson.D{{Key: "issued_at", Value: -1}})
+		result, err := creditNoteCollection().Find(ctx, filter, opts)
+		if err != nil {
+			apierror.Render(c, apierror.Internal("error occurred while listing credit notes: "+err.Error()))
+			return
+		}
+
+		var allCreditNotes []bson.M
+		if err = result.All(ctx, &allCreditNotes); err != nil {
+			apierror.Render(c, apierror.Internal("error decoding credit notes: "+err.Error()))
+			return
+		}
+
+		c.JSON(http.StatusOK, allCreditNotes)
+	}
+}
+
+// CreateCreditNote refunds order items of a finalized invoice, or everything
+// not yet refunded when no order_item_ids are given. The credit note reverses
+// tax at the rates the invoice was issued with and is numbered like invoices.
+func CreateCreditNote() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		var creditNote models.CreditNote
+		if err := c.BindJSON(&creditNote); err != nil {
+			apierror.Render(c, apierror.BadRequest("Invalid request data: "+err.Error()))
+			return
+		}
+		if err := validate.Struct(creditNote); err != nil {
+			apierror.Render(c, apierror.FromValidation(err))
+			return
+		}
+
+		var invoice models.Invoice
+		if err := invoiceCollection().FindOne(ctx, bson.M{"invoice_id": c.Param("invoice_id")}).Decode(&invoice); err != nil {
+			apierror.Render(c, apierror.NotFound("invoice not found"))
+			return
+		}
+		if invoice.Invoice_number == nil {
+			apierror.Render(c, apierror.Conflict("Only finalized invoices can be credited"))
+			return
+		}
+
+		var badItemId string
+		err := database.WithTransaction(ctx, db.Client(), func(txCtx context.Context) error {
+			badItemId = ""
+			credited, feesCredited, err := creditedItems(txCtx, invoice.Invoice_id)
+			if err != nil {
+				return err
+			}
+
+			orderItems, err := orderItemsOf(txCtx, invoice.Order_id)
+			if err != nil {
+				return err
+			}
+			byId := map[string]models.OrderItem{}
+			for _, orderItem := range orderItems {
+				byId[orderItem.Order_item_id] = orderItem
+			}
+
+			refunded := []models.OrderItem{}
+			var fees []models.PriceAdjustment
+			if len(creditNote.Order_item_ids) == 0 {
+				for _, orderItem := range orderItems {
+					if !credited[orderItem.Order_item_id] {
+						refunded = append(refunded, orderItem)
+					}
+				}
+				if !feesCredited {
+					var order models.Order
+					if err := orderCollection().FindOne(txCtx, bson.M{"order_id": invoice.Order_id}).Decode(&order); err != nil {
+						return err
+					}
+					fees = order.Fees
+					creditNote.Includes_fees = len(fees) > 0
+				}
+			} else {
+				creditNote.Includes_fees = false
+				for _, orderItemId := range creditNote.Order_item_ids {
+					orderItem, ok := byId[orderItemId]
+					if !ok || credited[orderItemId] {
+						badItemId = orderItemId
+						return errNothingToCredit
+					}
+					credited[orderItemId] = true
+					refunded = append(refunded, orderItem)
+				}
+			}
+			if len(refunded) == 0 && len(fees) == 0 {
+				return errNothingToCredit
+			}
+
+			lines, err := taxLines(txCtx, refunded, fees, invoiceTaxRates(invoice))
+			if err != nil {
+				return err
+			}
+
+			location := stringValue(invoice.Location)
+			if location == "" {
+				location = defaultLocation
+			}
+			now, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+			fiscalYear := fiscalYearOf(now)
+			sequence, err := nextSequenceNumber(txCtx, creditNoteSequenceCollection(), location, fiscalYear)
+			if err != nil {
+				return err
+			}
+
+			creditNote.ID = primitive.NewObjectID()
+			creditNote.Credit_note_id = creditNote.ID.Hex()
+			creditNote.Invoice_id = invoice.Invoice_id
+			creditNote.Invoice_number = *invoice.Invoice_number
+			creditNote.Location = location
+			creditNote.Fiscal_year = fiscalYear
+			creditNote.Credit_note_number = "CN-" + invoiceNumber(location, fiscalYear, sequence)
+			creditNote.Order_item_ids = []string{}
+			for _, orderItem := range refunded {
+				creditNote.Order_item_ids = append(creditNote.Order_item_ids, orderItem.Order_item_id)
+			}
+			creditNote.Tax_lines = negateTaxLines(lines)
+			creditNote.Total = taxLinesGross(creditNote.Tax_lines)
+			creditNote.Issued_at = now
+
+			_, err = creditNoteCollection().InsertOne(txCtx, creditNote)
+			return err
+		})
+		if err == errNothingToCredit {
+			if badItemId != "" {
+				apierror.Render(c, apierror.Conflict("Order item "+badItemId+" is not on this invoice or was already credited"))
+				return
+			}
+			apierror.Render(c, apierror.Conflict("Invoice is already fully credited"))
+			return
+		}
+		if err != nil {
+			apierror.Render(c, apierror.Internal("Credit note was not created: "+err.Error()))
+			return
+		}
+		if err := creditRefundRequests(ctx, creditNote); err != nil {
+			log.Println("Error updating refund requests:", err)
+		}
+
+		c.JSON(http.StatusCreated, gin.H{"message": "Credit note created", "data": creditNote})
+	}
+}
+
+// creditedItems returns the order items already refunded on an invoice and
+// whether its fees were.
+func creditedItems(ctx context.Context, invoiceId string) (map[string]bool, bool, error) {
+	cursor, err := creditNoteCollection().Find(ctx, bson.M{"invoice_id": invoiceId})
+	if err != nil {
+		return nil, false, err
+	}
+	var creditNotes []models.CreditNote
+	if err = cursor.All(ctx, &creditNotes); err != nil {
+		return nil, false, err
+	}
+
+	credited := map[string]bool{}
+	feesCredited := false
+	for _, creditNote := range creditNotes {
+		for _, orderItemId := range creditNote.Order_item_ids {
+			credited[orderItemId] = true
+		}
+		feesCredited = feesCredited || creditNote.Includes_fees
+	}
+	return credited, feesCredited, nil
+}
+
+// invoiceTaxRates looks up the rate each category was invoiced at.
+func invoiceTaxRates(invoice models.Invoice) func(category string) taxRule {
+	rates := map[string]taxRule{}
+	for _, line := range invoice.Tax_lines {
+		rates[line.Category] = taxRule{rate: line.Rate, exclusive: line.Exclusive}
+	}
+	return func(category string) taxRule { return rates[category] }
+}
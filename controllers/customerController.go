@@ -0,0 +1,342 @@
+package controllers
+
+import (
+	"context"
+	"net/http"
+	"restaurant-management/apierror"
+	"restaurant-management/config"
+	"restaurant-management/models"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var customerCollection = db.Collection("customer")
+
+// GetCustomers looks guests up by ?phone= or ?email=, or pages through all
+// of them by name.
+func GetCustomers() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		filter := bson.M{}
+		if phone := c.Query("phone"); phone != "" {
+			filter["phone"] = normalizePhone(phone)
+		}
+		if email := c.Query("email"); email != "" {
+			filter["email"] = strings.ToLower(strings.TrimSpace(email))
+		}
+
+		recordsPerPage, err := strconv.Atoi(c.Query("recordsPerPage"))
+		if err != nil || recordsPerPage < 1 {
+			recordsPerPage = 10
+		}
+		page, err := strconv.Atoi(c.Query("page"))
+		if err != nil || page < 1 {
+			page = 1
+		}
+		opts := options.Find().
+			SetSort(bson.D{{Key: "name", Value: 1}}).
+			SetSkip(int64((page - 1) * recordsPerPage)).
+			SetLimit(int64(recordsPerPage))
+
+		cursor, err := customerCollection().Find(ctx, filter, opts)
+		if err != nil {
+			apierror.Render(c, apierror.Internal("error occurred while listing customers: "+err.Error()))
+			return
+		}
+		customers := []models.Customer{}
+		if err := cursor.All(ctx, &customers); err != nil {
+			apierror.Render(c, apierror.Internal(err.Error()))
+			return
+		}
+		totalCount, err := customerCollection().CountDocuments(ctx, filter)
+		if err != nil {
+			apierror.Render(c, apierror.Internal("error counting customers: "+err.Error()))
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"total_count": totalCount, "customers": customers})
+	}
+}
+
+func GetCustomer() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		var customer models.Customer
+		if err := customerCollection().FindOne(ctx, bson.M{"customer_id": c.Param("customer_id")}).Decode(&customer); err != nil {
+			apierror.Render(c, apierror.NotFound("Customer not found"))
+			return
+		}
+		c.JSON(http.StatusOK, customer)
+	}
+}
+
+// CreateCustomer adds a guest's profile. With user_id the profile is for
+// that customer account and shares its id, so the account's orders are
+// already the profile's.
+func CreateCustomer() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		var customer models.Customer
+		if err := c.BindJSON(&customer); err != nil {
+			apierror.Render(c, apierror.BadRequest("Invalid request data: "+err.Error()))
+			return
+		}
+		normalizeCustomer(&customer)
+		if err := validate.Struct(customer); err != nil {
+			apierror.Render(c, apierror.FromValidation(err))
+			return
+		}
+
+		now, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		customer.ID = primitive.NewObjectID()
+		customer.Customer_id = customer.ID.Hex()
+		if customer.User_id != nil {
+			count, err := userCollection().CountDocuments(ctx, bson.M{"user_id": *customer.User_id, "role": "CUSTOMER"})
+			if err != nil {
+				apierror.Render(c, apierror.Internal("error occurred while checking the account: "+err.Error()))
+				return
+			}
+			if count == 0 {
+				apierror.Render(c, apierror.NotFound("No customer account with that user_id"))
+				return
+			}
+			customer.Customer_id = *customer.User_id
+		}
+		customer.Loyalty_points = 0
+		customer.Created_at = now
+		customer.Updated_at = now
+
+		if _, err := customerCollection().InsertOne(ctx, customer); err != nil {
+			if mongo.IsDuplicateKeyError(err) {
+				apierror.Render(c, apierror.Conflict("A customer with this phone number or account already exists"))
+				return
+			}
+			apierror.Render(c, apierror.Internal("Customer was not created: "+err.Error()))
+			return
+		}
+		c.JSON(http.StatusCreated, gin.H{"message": "Customer created", "data": customer})
+	}
+}
+
+func UpdateCustomer() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		var customer models.Customer
+		if err := c.BindJSON(&customer); err != nil {
+			apierror.Render(c, apierror.BadRequest("Invalid request data: "+err.Error()))
+			return
+		}
+		normalizeCustomer(&customer)
+
+		var updateObj primitive.D
+		if customer.Name != nil {
+			if err := validate.Var(*customer.Name, "min=2,max=100"); err != nil {
+				apierror.Render(c, apierror.Validation("Validation failed: name must be 2 to 100 characters"))
+				return
+			}
+			updateObj = append(updateObj, bson.E{Key: "name", Value: customer.Name})
+		}
+		if customer.Phone != nil {
+			if err := validate.Var(*customer.Phone, "min=7,max=20"); err != nil {
+				apierror.Render(c, apierror.Validation("Validation failed: phone must be 7 to 20 characters"))
+				return
+			}
+			updateObj = append(updateObj, bson.E{Key: "phone", Value: customer.Phone})
+		}
+		if customer.Email != nil {
+			if err := validate.Var(*customer.Email, "email"); err != nil {
+				apierror.Render(c, apierror.Validation("Validation failed: email is not valid"))
+				return
+			}
+			updateObj = append(updateObj, bson.E{Key: "email", Value: customer.Email})
+		}
+		if customer.Notes != nil {
+			updateObj = append(updateObj, bson.E{Key: "notes", Value: customer.Notes})
+		}
+		updated, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		updateObj = append(updateObj, bson.E{Key: "updated_at", Value: updated})
+
+		result, err := customerCollection().UpdateOne(ctx, bson.M{"customer_id": c.Param("customer_id")}, bson.D{{Key: "$set", Value: updateObj}})
+		if mongo.IsDuplicateKeyError(err) {
+			apierror.Render(c, apierror.Conflict("A customer with this phone number already exists"))
+			return
+		}
+		if err != nil {
+			apierror.Render(c, apierror.Internal("Customer update failed: "+err.Error()))
+			return
+		}
+		if result.MatchedCount == 0 {
+			apierror.Render(c, apierror.NotFound("Customer not found"))
+			return
+		}
+		c.JSON(http.StatusOK, result)
+	}
+}
+
+// GetCustomerOrders lists a guest's orders, newest first, each with its
+// invoice and number of items, and what they have spent in total. Guests
+// can read their own history.
+func GetCustomerOrders() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		customerId := c.Param("customer_id")
+		if c.GetString("role") == "CUSTOMER" && customerId != c.GetString("uid") {
+			apierror.Render(c, apierror.NotFound("Customer not found"))
+			return
+		}
+
+		limit, err := strconv.Atoi(c.DefaultQuery("limit", "20"))
+		if err != nil || limit < 1 || limit > 100 {
+			apierror.Render(c, apierror.BadRequest("limit must be between 1 and 100"))
+			return
+		}
+
+		match := bson.M{"customer_id": customerId, "deleted_at": notDeleted}
+		cursor, err := orderCollection().Aggregate(ctx, mongo.Pipeline{
+			{{Key: "$match", Value: match}},
+			{{Key: "$sort", Value: bson.D{{Key: "created_at", Value: -1}}}},
+			{{Key: "$limit", Value: limit}},
+			{{Key: "$lookup", Value: bson.D{
+				{Key: "from", Value: "invoice"},
+				{Key: "localField", Value: "order_id"},
+				{Key: "foreignField", Value: "order_id"},
+				{Key: "as", Value: "invoice"},
+			}}},
+			{{Key: "$lookup", Value: bson.D{
+				{Key: "from", Value: "orderItem"},
+				{Key: "localField", Value: "order_id"},
+				{Key: "foreignField", Value: "order_id"},
+				{Key: "as", Value: "items"},
+			}}},
+			{{Key: "$project", Value: bson.D{
+				{Key: "_id", Value: 0},
+				{Key: "order_id", Value: 1},
+				{Key: "order_date", Value: 1},
+				{Key: "created_at", Value: 1},
+				{Key: "table_id", Value: 1},
+				{Key: "channel", Value: 1},
+				{Key: "status", Value: 1},
+				{Key: "items", Value: bson.D{{Key: "$size", Value: "$items"}}},
+				{Key: "invoice_id", Value: bson.D{{Key: "$first", Value: "$invoice.invoice_id"}}},
+				{Key: "invoice_number", Value: bson.D{{Key: "$first", Value: "$invoice.invoice_number"}}},
+				{Key: "payment_status", Value: bson.D{{Key: "$first", Value: "$invoice.payment_status"}}},
+				{Key: "payment_due", Value: bson.D{{Key: "$first", Value: "$invoice.payment_due"}}},
+			}}},
+		})
+		if err != nil {
+			apierror.Render(c, apierror.Internal("error occurred while listing orders: "+err.Error()))
+			return
+		}
+		orders := []bson.M{}
+		if err := cursor.All(ctx, &orders); err != nil {
+			apierror.Render(c, apierror.Internal(err.Error()))
+			return
+		}
+
+		totalCount, err := orderCollection().CountDocuments(ctx, match)
+		if err != nil {
+			apierror.Render(c, apierror.Internal("error counting orders: "+err.Error()))
+			return
+		}
+		spent, err := customerSpend(ctx, customerId)
+		if err != nil {
+			apierror.Render(c, apierror.Internal("error occurred while totalling spend: "+err.Error()))
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"customer_id": customerId, "total_count": totalCount, "total_spent": spent, "orders": orders})
+	}
+}
+
+// customerSpend totals the paid invoices of a guest's orders.
+func customerSpend(ctx context.Context, customerId string) (float64, error) {
+	cursor, err := orderCollection().Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"customer_id": customerId, "deleted_at": notDeleted}}},
+		{{Key: "$lookup", Value: bson.D{
+			{Key: "from", Value: "invoice"},
+			{Key: "localField", Value: "order_id"},
+			{Key: "foreignField", Value: "order_id"},
+			{Key: "as", Value: "invoice"},
+		}}},
+		{{Key: "$unwind", Value: "$invoice"}},
+		{{Key: "$match", Value: bson.M{"invoice.payment_status": "PAID"}}},
+		{{Key: "$group", Value: bson.D{
+			{Key: "_id", Value: nil},
+			{Key: "spent", Value: bson.D{{Key: "$sum", Value: bson.D{{Key: "$ifNull", Value: bson.A{"$invoice.payment_due", 0}}}}}},
+		}}},
+	})
+	if err != nil {
+		return 0, err
+	}
+	var totals []struct {
+		Spent float64 `bson:"spent"`
+	}
+	if err := cursor.All(ctx, &totals); err != nil || len(totals) == 0 {
+		return 0, err
+	}
+	return toFixed(totals[0].Spent, 2), nil
+}
+
+// customerContact returns how to reach the guest behind a customer id: its
+// profile when there is one, otherwise the customer account of that id.
+// Unknown ids give empty values.
+func customerContact(ctx context.Context, customerId string) (name, email, phone string, err error) {
+	var customer models.Customer
+	err = customerCollection().FindOne(ctx, bson.M{"customer_id": customerId}).Decode(&customer)
+	if err == nil {
+		return stringValue(customer.Name), stringValue(customer.Email), stringValue(customer.Phone), nil
+	}
+	if err != mongo.ErrNoDocuments {
+		return "", "", "", err
+	}
+
+	var user models.User
+	err = userCollection().FindOne(ctx, bson.M{"user_id": customerId}).Decode(&user)
+	if err == mongo.ErrNoDocuments {
+		return "", "", "", nil
+	}
+	if err != nil {
+		return "", "", "", err
+	}
+	return stringValue(user.First_name), stringValue(user.Email), stringValue(user.Phone), nil
+}
+
+func normalizeCustomer(customer *models.Customer) {
+	if customer.Phone != nil {
+		phone := normalizePhone(*customer.Phone)
+		customer.Phone = &phone
+	}
+	if customer.Email != nil {
+		email := strings.ToLower(strings.TrimSpace(*customer.Email))
+		customer.Email = &email
+	}
+}
+
+// normalizePhone drops the spaces, dashes and brackets people write phone
+// numbers with, keeping a leading + and the digits.
+func normalizePhone(phone string) string {
+	var b strings.Builder
+	for i, r := range strings.TrimSpace(phone) {
+		if (r >= '0' && r <= '9') || (r == '+' && i == 0) {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
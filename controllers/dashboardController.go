@@ -0,0 +1,148 @@
+package controllers
+
+import (
+	"context"
+	"net/http"
+	"restaurant-management/apierror"
+	"restaurant-management/config"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// dashboardCacheTTL is how long a summary is served before it is worked
+// out again. The home screen polls, so this caps the load it puts on the
+// database however many managers have it open.
+const dashboardCacheTTL = 30 * time.Second
+
+// DashboardSummary is the state of a location right now, for the manager
+// home screen. Orders counts today's orders, Open_orders those of any day
+// whose invoice is not paid yet, and Pending_reservations the bookings
+// still to arrive today. As_of is when it was worked out.
+type DashboardSummary struct {
+	Location             string    `json:"location"`
+	Date                 string    `json:"date"`
+	Orders               int64     `json:"orders"`
+	Open_orders          int64     `json:"open_orders"`
+	Occupied_tables      int64     `json:"occupied_tables"`
+	Revenue              float64   `json:"revenue"`
+	Pending_reservations int64     `json:"pending_reservations"`
+	As_of                time.Time `json:"as_of"`
+}
+
+var (
+	dashboardCacheMu sync.Mutex
+	dashboardCache   = map[string]DashboardSummary{}
+)
+
+// GetDashboardSummary returns the summary of ?location=, with today taken
+// in the IANA time zone ?tz= (UTC by default).
+func GetDashboardSummary() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		timezone := c.DefaultQuery("tz", "UTC")
+		zone, err := time.LoadLocation(timezone)
+		if err != nil {
+			apierror.Render(c, apierror.BadRequest("tz must be an IANA time zone such as Europe/Berlin"))
+			return
+		}
+		location := c.DefaultQuery("location", defaultLocation)
+
+		key := location + "|" + zone.String()
+		dashboardCacheMu.Lock()
+		cached, ok := dashboardCache[key]
+		dashboardCacheMu.Unlock()
+		if ok && time.Since(cached.As_of) < dashboardCacheTTL {
+			c.JSON(http.StatusOK, cached)
+			return
+		}
+
+		summary, err := dashboardSummary(ctx, location, time.Now().In(zone))
+		if err != nil {
+			apierror.Render(c, apierror.Internal("error occurred while building summary: "+err.Error()))
+			return
+		}
+		dashboardCacheMu.Lock()
+		dashboardCache[key] = summary
+		dashboardCacheMu.Unlock()
+
+		c.JSON(http.StatusOK, summary)
+	}
+}
+
+func dashboardSummary(ctx context.Context, location string, now time.Time) (DashboardSummary, error) {
+	start := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	end := start.AddDate(0, 0, 1)
+	summary := DashboardSummary{Location: location, Date: start.Format("2006-01-02"), As_of: now}
+
+	var err error
+	if summary.Orders, err = orderCollection().CountDocuments(ctx, bson.M{"created_at": bson.M{"$gte": start, "$lt": end}, "deleted_at": notDeleted}); err != nil {
+		return summary, err
+	}
+	if summary.Occupied_tables, err = tableCollection().CountDocuments(ctx, bson.M{"location": location, "status": "OCCUPIED", "deleted_at": notDeleted}); err != nil {
+		return summary, err
+	}
+	summary.Pending_reservations, err = reservationCollection().CountDocuments(ctx, bson.M{
+		"location":    location,
+		"status":      bson.M{"$in": upcomingReservationStatuses},
+		"reserved_at": bson.M{"$gte": now, "$lt": end},
+	})
+	if err != nil {
+		return summary, err
+	}
+
+	openCursor, err := orderCollection().Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"status": bson.M{"$in": openOrderStatuses}, "deleted_at": notDeleted}}},
+		{{Key: "$lookup", Value: bson.D{
+			{Key: "from", Value: "invoice"},
+			{Key: "let", Value: bson.D{{Key: "order_id", Value: "$order_id"}}},
+			{Key: "pipeline", Value: mongo.Pipeline{
+				{{Key: "$match", Value: bson.D{
+					{Key: "$expr", Value: bson.D{{Key: "$eq", Value: bson.A{"$order_id", "$$order_id"}}}},
+					{Key: "payment_status", Value: "PAID"},
+				}}},
+				{{Key: "$limit", Value: 1}},
+			}},
+			{Key: "as", Value: "paid"},
+		}}},
+		{{Key: "$match", Value: bson.D{{Key: "paid", Value: bson.D{{Key: "$size", Value: 0}}}}}},
+		{{Key: "$count", Value: "open"}},
+	})
+	if err != nil {
+		return summary, err
+	}
+	var open []struct {
+		Open int64 `bson:"open"`
+	}
+	if err = openCursor.All(ctx, &open); err != nil {
+		return summary, err
+	}
+	if len(open) > 0 {
+		summary.Open_orders = open[0].Open
+	}
+
+	revenueCursor, err := invoiceCollection().Aggregate(ctx, append(paidInvoiceStages(location, start, end),
+		bson.D{{Key: "$group", Value: bson.D{
+			{Key: "_id", Value: nil},
+			{Key: "revenue", Value: bson.D{{Key: "$sum", Value: bson.D{{Key: "$ifNull", Value: bson.A{"$payment_due", 0}}}}}},
+		}}},
+	))
+	if err != nil {
+		return summary, err
+	}
+	var revenue []struct {
+		Revenue float64 `bson:"revenue"`
+	}
+	if err = revenueCursor.All(ctx, &revenue); err != nil {
+		return summary, err
+	}
+	if len(revenue) > 0 {
+		summary.Revenue = toFixed(revenue[0].Revenue, 2)
+	}
+	return summary, nil
+}
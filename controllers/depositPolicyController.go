@@ -0,0 +1,278 @@
+package controllers
+
+import (
+	"context"
+	"net/http"
+	"restaurant-management/apierror"
+	"restaurant-management/config"
+	"restaurant-management/models"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var depositPolicyCollection = db.Collection("depositPolicy")
+var guestHistoryCollection = db.Collection("guestHistory")
+
+func GetDepositPolicy() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		var policy models.DepositPolicy
+		err := depositPolicyCollection().FindOne(ctx, bson.M{"location": c.Param("location")}).Decode(&policy)
+		if err != nil {
+			apierror.Render(c, apierror.NotFound("Deposit policy not found"))
+			return
+		}
+
+		c.JSON(http.StatusOK, policy)
+	}
+}
+
+// UpdateDepositPolicy creates or updates the deposit rules of a location.
+func UpdateDepositPolicy() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		var policy models.DepositPolicy
+		if err := c.BindJSON(&policy); err != nil {
+			apierror.Render(c, apierror.BadRequest("Invalid request data: "+err.Error()))
+			return
+		}
+		if err := validate.Struct(policy); err != nil {
+			apierror.Render(c, apierror.FromValidation(err))
+			return
+		}
+		for _, slot := range policy.Peak_slots {
+			start, startErr := time.Parse("15:04", slot.Start_time)
+			end, endErr := time.Parse("15:04", slot.End_time)
+			if startErr != nil || endErr != nil || !end.After(start) {
+				apierror.Render(c, apierror.BadRequest("Peak slots need HH:MM start_time and end_time with end after start"))
+				return
+			}
+		}
+
+		var updateObj primitive.D
+		if policy.Min_party_size != nil {
+			updateObj = append(updateObj, bson.E{Key: "min_party_size", Value: policy.Min_party_size})
+		}
+		if policy.Peak_slots != nil {
+			updateObj = append(updateObj, bson.E{Key: "peak_slots", Value: policy.Peak_slots})
+		}
+		if policy.Amount_per_guest != nil {
+			updateObj = append(updateObj, bson.E{Key: "amount_per_guest", Value: policy.Amount_per_guest})
+		}
+		if policy.Full_refund_hours != nil {
+			updateObj = append(updateObj, bson.E{Key: "full_refund_hours", Value: policy.Full_refund_hours})
+		}
+		if policy.Partial_refund_percent != nil {
+			updateObj = append(updateObj, bson.E{Key: "partial_refund_percent", Value: policy.Partial_refund_percent})
+		}
+		if policy.No_show_grace_minutes != nil {
+			updateObj = append(updateObj, bson.E{Key: "no_show_grace_minutes", Value: policy.No_show_grace_minutes})
+		}
+		if policy.No_show_fee_per_guest != nil {
+			updateObj = append(updateObj, bson.E{Key: "no_show_fee_per_guest", Value: policy.No_show_fee_per_guest})
+		}
+		if policy.Card_guarantee != nil {
+			updateObj = append(updateObj, bson.E{Key: "card_guarantee", Value: policy.Card_guarantee})
+		}
+		if policy.No_show_threshold != nil {
+			updateObj = append(updateObj, bson.E{Key: "no_show_threshold", Value: policy.No_show_threshold})
+		}
+
+		now, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		updateObj = append(updateObj, bson.E{Key: "updated_at", Value: now})
+
+		upsert := true
+		opt := options.UpdateOptions{Upsert: &upsert}
+
+		result, err := depositPolicyCollection().UpdateOne(
+			ctx,
+			bson.M{"location": c.Param("location")},
+			bson.D{
+				{Key: "$set", Value: updateObj},
+				{Key: "$setOnInsert", Value: bson.D{{Key: "_id", Value: primitive.NewObjectID()}, {Key: "created_at", Value: now}}},
+			},
+			&opt,
+		)
+		if err != nil {
+			apierror.Render(c, apierror.Internal("Update failed: "+err.Error()))
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Deposit policy updated successfully", "result": result})
+	}
+}
+
+// GetDepositQuote tells the booking flow whether ?party_size= guests at
+// ?reserved_at= (RFC 3339) need a deposit or a card guarantee before a card
+// is asked for. ?customer_email= or ?customer_phone= account for the guest's
+// past no-shows.
+func GetDepositQuote() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		partySize, err := strconv.Atoi(c.Query("party_size"))
+		if err != nil || partySize <= 0 {
+			apierror.Render(c, apierror.BadRequest("party_size must be a positive number"))
+			return
+		}
+		reservedAt, err := time.Parse(time.RFC3339, c.Query("reserved_at"))
+		if err != nil {
+			apierror.Render(c, apierror.BadRequest("reserved_at must be an RFC 3339 timestamp"))
+			return
+		}
+		location := c.DefaultQuery("location", defaultLocation)
+
+		policy, err := depositPolicyFor(ctx, location)
+		if err != nil {
+			apierror.Render(c, apierror.Internal("error occurred while loading deposit policy: "+err.Error()))
+			return
+		}
+		currency, err := locationCurrency(ctx, location)
+		if err != nil {
+			apierror.Render(c, apierror.Internal("error occurred while loading currency: "+err.Error()))
+			return
+		}
+
+		email, phone := c.Query("customer_email"), c.Query("customer_phone")
+		noShows, err := guestNoShows(ctx, guestKey(&email, &phone))
+		if err != nil {
+			apierror.Render(c, apierror.Internal("error occurred while loading guest history: "+err.Error()))
+			return
+		}
+
+		amount := requiredDeposit(policy, partySize, reservedAt, noShows)
+		guarantee := 0.0
+		if amount == 0 {
+			guarantee = requiredGuarantee(policy, partySize)
+		}
+		c.JSON(http.StatusOK, gin.H{"required": amount > 0, "amount": amount, "guarantee": guarantee, "currency": currency})
+	}
+}
+
+// depositPolicyFor returns the policy of a location, falling back to the
+// default location's. It returns nil when neither has one.
+func depositPolicyFor(ctx context.Context, location string) (*models.DepositPolicy, error) {
+	for _, candidate := range []string{location, defaultLocation} {
+		var policy models.DepositPolicy
+		err := depositPolicyCollection().FindOne(ctx, bson.M{"location": candidate}).Decode(&policy)
+		if err == nil {
+			return &policy, nil
+		}
+		if err != mongo.ErrNoDocuments {
+			return nil, err
+		}
+	}
+	return nil, nil
+}
+
+// requiredDeposit is the deposit for a booking: a per-guest amount when the
+// party is large enough, the slot is a peak slot or the guest has reached the
+// no-show threshold, otherwise nothing.
+func requiredDeposit(policy *models.DepositPolicy, partySize int, reservedAt time.Time, noShows int) float64 {
+	if policy == nil || policy.Amount_per_guest == nil {
+		return 0
+	}
+	required := policy.Min_party_size != nil && partySize >= *policy.Min_party_size
+	if policy.No_show_threshold != nil && noShows >= *policy.No_show_threshold {
+		required = true
+	}
+	local := reservedAt.Local()
+	clock := local.Format("15:04")
+	for _, slot := range policy.Peak_slots {
+		for _, day := range slot.Days {
+			if day == weekdayCodes[local.Weekday()] && clock >= slot.Start_time && clock < slot.End_time {
+				required = true
+			}
+		}
+	}
+	if !required {
+		return 0
+	}
+	return toFixed(*policy.Amount_per_guest*float64(partySize), 2)
+}
+
+// depositRefund is how much of a deposit is returned when a booking is
+// cancelled at now: all of it up to full_refund_hours before the slot,
+// partial_refund_percent of it afterwards. Cancellations by the restaurant
+// are always refunded in full.
+func depositRefund(policy *models.DepositPolicy, deposit models.ReservationDeposit, reservedAt, now time.Time, byRestaurant bool) float64 {
+	if byRestaurant || policy == nil {
+		return deposit.Amount
+	}
+	if policy.Full_refund_hours != nil && reservedAt.Sub(now) >= time.Duration(*policy.Full_refund_hours)*time.Hour {
+		return deposit.Amount
+	}
+	if policy.Partial_refund_percent != nil {
+		return toFixed(deposit.Amount**policy.Partial_refund_percent/100, 2)
+	}
+	return 0
+}
+
+// noShowFee is what a party that does not turn up is charged.
+func noShowFee(policy *models.DepositPolicy, partySize int) float64 {
+	if policy == nil || policy.No_show_fee_per_guest == nil {
+		return 0
+	}
+	return toFixed(*policy.No_show_fee_per_guest*float64(partySize), 2)
+}
+
+// requiredGuarantee is the no-show fee held on the guest's card when the
+// policy asks for a card guarantee on bookings without a deposit.
+func requiredGuarantee(policy *models.DepositPolicy, partySize int) float64 {
+	if policy == nil || policy.Card_guarantee == nil || !*policy.Card_guarantee {
+		return 0
+	}
+	return noShowFee(policy, partySize)
+}
+
+// noShowGrace is how late a party may be before it counts as a no-show.
+func noShowGrace(policy *models.DepositPolicy) time.Duration {
+	if policy == nil || policy.No_show_grace_minutes == nil {
+		return 15 * time.Minute
+	}
+	return time.Duration(*policy.No_show_grace_minutes) * time.Minute
+}
+
+// guestKey identifies a guest across bookings by lowercased email, or by the
+// digits of their phone number.
+func guestKey(email, phone *string) string {
+	if email != nil && strings.TrimSpace(*email) != "" {
+		return "email:" + strings.ToLower(strings.TrimSpace(*email))
+	}
+	if phone != nil {
+		digits := strings.Map(func(r rune) rune {
+			if r >= '0' && r <= '9' {
+				return r
+			}
+			return -1
+		}, *phone)
+		if digits != "" {
+			return "phone:" + digits
+		}
+	}
+	return ""
+}
+
+func guestNoShows(ctx context.Context, key string) (int, error) {
+	if key == "" {
+		return 0, nil
+	}
+	var history models.GuestHistory
+	err := guestHistoryCollection().FindOne(ctx, bson.M{"guest_key": key}).Decode(&history)
+	if err == mongo.ErrNoDocuments {
+		return 0, nil
+	}
+	return history.No_shows, err
+}
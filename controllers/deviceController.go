@@ -0,0 +1,132 @@
+package controllers
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"restaurant-management/apierror"
+	"restaurant-management/config"
+	"restaurant-management/models"
+	"restaurant-management/notifications/push"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var deviceCollection = db.Collection("device")
+
+// RegisterDevice stores the caller's push token. Registering a token again
+// refreshes it, moving it to the caller if it was someone else's.
+func RegisterDevice() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		var device models.Device
+		if err := c.BindJSON(&device); err != nil {
+			apierror.Render(c, apierror.BadRequest("Invalid request data: "+err.Error()))
+			return
+		}
+		if err := validate.Struct(device); err != nil {
+			apierror.Render(c, apierror.FromValidation(err))
+			return
+		}
+
+		now, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		id := primitive.NewObjectID()
+		err := deviceCollection().FindOneAndUpdate(
+			ctx,
+			bson.M{"token": device.Token},
+			bson.D{
+				{Key: "$set", Value: bson.D{
+					{Key: "user_id", Value: c.GetString("uid")},
+					{Key: "provider", Value: device.Provider},
+					{Key: "platform", Value: device.Platform},
+					{Key: "updated_at", Value: now},
+				}},
+				{Key: "$setOnInsert", Value: bson.D{
+					{Key: "_id", Value: id},
+					{Key: "device_id", Value: id.Hex()},
+					{Key: "created_at", Value: now},
+				}},
+			},
+			options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After),
+		).Decode(&device)
+		if err != nil {
+			apierror.Render(c, apierror.Internal("Device was not registered: "+err.Error()))
+			return
+		}
+
+		c.JSON(http.StatusCreated, gin.H{"message": "Device registered", "data": device})
+	}
+}
+
+// DeleteDevice stops pushes to one of the caller's devices, as when they
+// sign out of the app.
+func DeleteDevice() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		result, err := deviceCollection().DeleteOne(ctx, bson.M{"device_id": c.Param("device_id"), "user_id": c.GetString("uid")})
+		if err != nil {
+			apierror.Render(c, apierror.Internal("Device was not deleted: "+err.Error()))
+			return
+		}
+		if result.DeletedCount == 0 {
+			apierror.Render(c, apierror.NotFound("Device not found"))
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "Device deleted"})
+	}
+}
+
+// ForgetDevice drops a token the push provider no longer accepts. The push
+// workers call it, outside any request.
+func ForgetDevice(token string) {
+	ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+	defer cancel()
+	if _, err := deviceCollection().DeleteOne(ctx, bson.M{"token": token}); err != nil {
+		log.Println("Error forgetting device:", err)
+	}
+}
+
+// pushToUsers queues notification to every device of the given users.
+func pushToUsers(ctx context.Context, userIds []string, notification push.Notification) error {
+	if len(userIds) == 0 {
+		return nil
+	}
+	cursor, err := deviceCollection().Find(ctx, bson.M{"user_id": bson.M{"$in": userIds}})
+	if err != nil {
+		return err
+	}
+	var devices []models.Device
+	if err := cursor.All(ctx, &devices); err != nil {
+		return err
+	}
+	targets := make([]push.Target, 0, len(devices))
+	for _, device := range devices {
+		targets = append(targets, push.Target{Provider: *device.Provider, Token: *device.Token})
+	}
+	push.Queue(targets, notification)
+	return nil
+}
+
+// pushToRole queues notification to the devices of every user holding
+// role.
+func pushToRole(ctx context.Context, role string, notification push.Notification) error {
+	userIds, err := userCollection().Distinct(ctx, "user_id", bson.M{"role": role})
+	if err != nil {
+		return err
+	}
+	ids := make([]string, 0, len(userIds))
+	for _, id := range userIds {
+		if id, ok := id.(string); ok {
+			ids = append(ids, id)
+		}
+	}
+	return pushToUsers(ctx, ids, notification)
+}
@@ -0,0 +1,186 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"restaurant-management/apierror"
+	"restaurant-management/config"
+	"restaurant-management/database"
+	"restaurant-management/models"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// errTableNotFree is returned when a party is seated at a table that is
+// occupied or still being cleaned.
+var errTableNotFree = errors.New("table is not free")
+
+// SeatParty seats a party in one call: the table is marked OCCUPIED, an
+// order is opened for the server on shift in the table's section and, when
+// reservation_id is given, the reservation is seated on that order. The
+// response carries the table, order, server and reservation for the POS.
+func SeatParty() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		var body struct {
+			Table_id       *string `json:"table_id" validate:"required"`
+			Party_size     *int    `json:"party_size" validate:"required,gt=0,lte=100"`
+			Reservation_id *string `json:"reservation_id"`
+			Server_id      *string `json:"server_id"`
+			Customer_id    *string `json:"customer_id"`
+		}
+		if err := c.BindJSON(&body); err != nil {
+			apierror.Render(c, apierror.BadRequest("Invalid request data: "+err.Error()))
+			return
+		}
+		if err := validate.Struct(body); err != nil {
+			apierror.Render(c, apierror.FromValidation(err))
+			return
+		}
+
+		var table models.Table
+		if err := tableCollection().FindOne(ctx, bson.M{"table_id": body.Table_id}).Decode(&table); err != nil {
+			apierror.Render(c, apierror.NotFound("Table not found"))
+			return
+		}
+		location := stringOr(table.Location, defaultLocation)
+
+		// Check the reservation up front; it is seated once the table is ours
+		if body.Reservation_id != nil {
+			count, err := reservationCollection().CountDocuments(ctx, bson.M{"reservation_id": body.Reservation_id, "status": bson.M{"$in": upcomingReservationStatuses}})
+			if err != nil {
+				apierror.Render(c, apierror.Internal("error occurred while loading reservation: "+err.Error()))
+				return
+			}
+			if count == 0 {
+				apierror.Render(c, apierror.Conflict("Only upcoming reservations can be seated"))
+				return
+			}
+		}
+
+		now, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		serverId := body.Server_id
+		if serverId == nil {
+			var err error
+			serverId, err = sectionServer(ctx, location, stringValue(table.Section), now)
+			if err != nil {
+				apierror.Render(c, apierror.Internal("error occurred while finding a server: "+err.Error()))
+				return
+			}
+		}
+
+		channel := "DINE_IN"
+		fees, err := demandFees(ctx, channel, now)
+		if err != nil {
+			apierror.Render(c, apierror.Internal("error occurred while pricing order: "+err.Error()))
+			return
+		}
+
+		var order models.Order
+		err = database.WithTransaction(ctx, db.Client(), func(txCtx context.Context) error {
+			result, err := tableCollection().UpdateOne(
+				txCtx,
+				bson.M{"table_id": body.Table_id, "status": bson.M{"$nin": bson.A{"OCCUPIED", "CLEANING"}}},
+				bson.D{{Key: "$set", Value: bson.D{{Key: "status", Value: "OCCUPIED"}, {Key: "updated_at", Value: now}}}},
+			)
+			if err != nil {
+				return err
+			}
+			if result.MatchedCount == 0 {
+				return errTableNotFree
+			}
+
+			order = models.Order{
+				Order_Date:  now,
+				Table_id:    body.Table_id,
+				Channel:     &channel,
+				Fees:        fees,
+				Customer_id: body.Customer_id,
+				Server_id:   serverId,
+				Opened_by:   staffId(c),
+				Party_size:  body.Party_size,
+				Status:      "OPEN",
+				Created_at:  now,
+				Updated_at:  now,
+			}
+			order.ID = primitive.NewObjectID()
+			order.Order_id = order.ID.Hex()
+			_, err = orderCollection().InsertOne(txCtx, order)
+			return err
+		})
+		if err == errTableNotFree {
+			apierror.Render(c, apierror.Conflict("Table is not free").With("status", table.Status))
+			return
+		}
+		if err != nil {
+			apierror.Render(c, apierror.Internal("Seating failed: "+err.Error()))
+			return
+		}
+		table.Status = "OCCUPIED"
+		table.Updated_at = now
+
+		response := gin.H{"message": "Party seated", "table": table, "order": order, "server": nil, "reservation": nil}
+		if serverId != nil {
+			users, err := usersById(ctx, []string{*serverId})
+			if err == nil {
+				if user, ok := users[*serverId]; ok {
+					response["server"] = gin.H{"user_id": user.User_id, "first_name": user.First_name, "last_name": user.Last_name}
+				}
+			}
+		}
+		if body.Reservation_id != nil {
+			seated, err := seatReservation(ctx, *body.Reservation_id, order.Order_id, body.Table_id)
+			if err != nil {
+				// The party is seated either way; the host can link it later
+				response["reservation_error"] = "Reservation could not be seated: " + err.Error()
+			} else {
+				response["reservation"] = seated
+			}
+		}
+
+		c.JSON(http.StatusCreated, response)
+	}
+}
+
+// sectionServer picks the server on shift at a location and time for a
+// section, or any server on shift when the table has no section. With
+// several candidates the one with the fewest open checks is chosen. It
+// returns nil when nobody is scheduled.
+func sectionServer(ctx context.Context, location, section string, at time.Time) (*string, error) {
+	filter := bson.M{
+		"location": location,
+		"status":   "SCHEDULED",
+		"start":    bson.M{"$lte": at},
+		"end":      bson.M{"$gt": at},
+	}
+	if section != "" {
+		filter["section"] = section
+	}
+	cursor, err := shiftCollection().Find(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	var shifts []models.Shift
+	if err = cursor.All(ctx, &shifts); err != nil {
+		return nil, err
+	}
+
+	var chosen *string
+	fewest := int64(-1)
+	for _, shift := range shifts {
+		open, err := orderCollection().CountDocuments(ctx, bson.M{"server_id": shift.Employee_id, "status": "OPEN", "created_at": bson.M{"$gte": *shift.Start}})
+		if err != nil {
+			return nil, err
+		}
+		if fewest < 0 || open < fewest {
+			chosen, fewest = shift.Employee_id, open
+		}
+	}
+	return chosen, nil
+}
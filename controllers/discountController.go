@@ -0,0 +1,1379 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"restaurant-management/apierror"
+	"restaurant-management/config"
+	"restaurant-management/extensions"
+	"restaurant-management/models"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var discountCollection = db.Collection("discount")
+var discountPolicyCollection = db.Collection("discountPolicy")
+
+// discountKinds are the adjustment types that take money off, in the order
+// they apply when a location has no policy. Other adjustments, such as
+// surge pricing, are part of the price discounts are taken from.
+var discountKinds = []string{"HAPPY_HOUR", "PROMOTION", "UPSELL", "COUPON", "MANUAL", "LOYALTY"}
+
+func GetDiscounts() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		filter := bson.M{}
+		if kind := c.Query("kind"); kind != "" {
+			filter["kind"] = kind
+		}
+
+		result, err := discountCollection().Find(ctx, filter)
+		if err != nil {
+			apierror.Render(c, apierror.Internal("error occurred while listing discounts: "+err.Error()))
+			return
+		}
+
+		var allDiscounts []bson.M
+		if err = result.All(ctx, &allDiscounts); err != nil {
+			apierror.Render(c, apierror.Internal("error decoding discounts: "+err.Error()))
+			return
+		}
+
+		c.JSON(http.StatusOK, allDiscounts)
+	}
+}
+
+func GetDiscount() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		var discount models.Discount
+		err := discountCollection().FindOne(ctx, bson.M{"discount_id": c.Param("discount_id")}).Decode(&discount)
+		if err != nil {
+			apierror.Render(c, apierror.NotFound("Discount not found"))
+			return
+		}
+
+		c.JSON(http.StatusOK, discount)
+	}
+}
+
+func CreateDiscount() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		var discount models.Discount
+		if err := c.BindJSON(&discount); err != nil {
+			apierror.Render(c, apierror.BadRequest("Invalid request data: "+err.Error()))
+			return
+		}
+
+		if err := validate.Struct(discount); err != nil {
+			apierror.Render(c, apierror.FromValidation(err))
+			return
+		}
+		if discount.Effective_from != nil && discount.Effective_to != nil && discount.Effective_to.Before(*discount.Effective_from) {
+			apierror.Render(c, apierror.Validation("Validation failed: effective_to must be after effective_from"))
+			return
+		}
+		if *discount.Kind != "COUPON" && (discount.Max_uses != nil || discount.Max_uses_per_customer != nil) {
+			apierror.Render(c, apierror.Validation("Validation failed: only coupons have usage limits"))
+			return
+		}
+
+		// Codes are matched case-insensitively at the till
+		if discount.Code != nil {
+			code := strings.ToUpper(strings.TrimSpace(*discount.Code))
+			discount.Code = &code
+			count, err := discountCollection().CountDocuments(ctx, bson.M{"code": code})
+			if err != nil {
+				apierror.Render(c, apierror.Internal("error occurred while checking coupon code: "+err.Error()))
+				return
+			}
+			if count > 0 {
+				apierror.Render(c, apierror.Conflict("Coupon code is already in use"))
+				return
+			}
+		}
+
+		if discount.Active == nil {
+			active := true
+			discount.Active = &active
+		}
+		if discount.Stackable == nil {
+			stackable := false
+			discount.Stackable = &stackable
+		}
+
+		now := time.Now().Format(time.RFC3339)
+		discount.Created_at, _ = time.Parse(time.RFC3339, now)
+		discount.Updated_at, _ = time.Parse(time.RFC3339, now)
+		discount.ID = primitive.NewObjectID()
+		discount.Discount_id = discount.ID.Hex()
+		discount.Times_used = 0
+
+		result, insertErr := discountCollection().InsertOne(ctx, discount)
+		if insertErr != nil {
+			apierror.Render(c, apierror.Internal("Could not create discount"))
+			return
+		}
+
+		c.JSON(http.StatusCreated, gin.H{"message": "Discount created", "data": result})
+	}
+}
+
+// UpdateDiscount changes the terms of a discount. Kind and code are fixed
+// once created, since they may already be printed on coupons.
+func UpdateDiscount() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		discountId := c.Param("discount_id")
+
+		var existing models.Discount
+		if err := discountCollection().FindOne(ctx, bson.M{"discount_id": discountId}).Decode(&existing); err != nil {
+			apierror.Render(c, apierror.NotFound("Discount not found"))
+			return
+		}
+
+		var discount models.Discount
+		if err := c.BindJSON(&discount); err != nil {
+			apierror.Render(c, apierror.BadRequest("Invalid request data: "+err.Error()))
+			return
+		}
+
+		var updateObj primitive.D
+		if discount.Name != nil {
+			existing.Name = discount.Name
+			updateObj = append(updateObj, bson.E{Key: "name", Value: discount.Name})
+		}
+		if discount.Scope != nil {
+			existing.Scope = discount.Scope
+			updateObj = append(updateObj, bson.E{Key: "scope", Value: discount.Scope})
+		}
+		if discount.Food_ids != nil {
+			existing.Food_ids = discount.Food_ids
+			updateObj = append(updateObj, bson.E{Key: "food_ids", Value: discount.Food_ids})
+		}
+		if discount.Category != nil {
+			existing.Category = discount.Category
+			updateObj = append(updateObj, bson.E{Key: "category", Value: discount.Category})
+		}
+		if discount.Menu_ids != nil {
+			existing.Menu_ids = discount.Menu_ids
+			updateObj = append(updateObj, bson.E{Key: "menu_ids", Value: discount.Menu_ids})
+		}
+		// Percent and amount off replace each other
+		if discount.Percent_off != nil {
+			existing.Percent_off, existing.Amount_off = discount.Percent_off, nil
+			updateObj = append(updateObj, bson.E{Key: "percent_off", Value: discount.Percent_off}, bson.E{Key: "amount_off", Value: nil})
+		}
+		if discount.Amount_off != nil {
+			existing.Percent_off, existing.Amount_off = nil, discount.Amount_off
+			updateObj = append(updateObj, bson.E{Key: "percent_off", Value: nil}, bson.E{Key: "amount_off", Value: discount.Amount_off})
+		}
+		if discount.Max_amount != nil {
+			existing.Max_amount = discount.Max_amount
+			updateObj = append(updateObj, bson.E{Key: "max_amount", Value: discount.Max_amount})
+		}
+		if discount.Min_subtotal != nil {
+			existing.Min_subtotal = discount.Min_subtotal
+			updateObj = append(updateObj, bson.E{Key: "min_subtotal", Value: discount.Min_subtotal})
+		}
+		if discount.Stackable != nil {
+			updateObj = append(updateObj, bson.E{Key: "stackable", Value: discount.Stackable})
+		}
+		if discount.Priority != nil {
+			updateObj = append(updateObj, bson.E{Key: "priority", Value: discount.Priority})
+		}
+		// Lowering a limit below the uses so far only stops further uses
+		if discount.Max_uses != nil {
+			existing.Max_uses = discount.Max_uses
+			updateObj = append(updateObj, bson.E{Key: "max_uses", Value: discount.Max_uses})
+		}
+		if discount.Max_uses_per_customer != nil {
+			existing.Max_uses_per_customer = discount.Max_uses_per_customer
+			updateObj = append(updateObj, bson.E{Key: "max_uses_per_customer", Value: discount.Max_uses_per_customer})
+		}
+		if discount.Effective_from != nil {
+			existing.Effective_from = discount.Effective_from
+			updateObj = append(updateObj, bson.E{Key: "effective_from", Value: discount.Effective_from})
+		}
+		if discount.Effective_to != nil {
+			existing.Effective_to = discount.Effective_to
+			updateObj = append(updateObj, bson.E{Key: "effective_to", Value: discount.Effective_to})
+		}
+		if discount.Active != nil {
+			updateObj = append(updateObj, bson.E{Key: "active", Value: discount.Active})
+		}
+
+		// Validate the merged discount so partial updates cannot leave it inconsistent
+		if err := validate.Struct(existing); err != nil {
+			apierror.Render(c, apierror.FromValidation(err))
+			return
+		}
+		if existing.Effective_from != nil && existing.Effective_to != nil && existing.Effective_to.Before(*existing.Effective_from) {
+			apierror.Render(c, apierror.Validation("Validation failed: effective_to must be after effective_from"))
+			return
+		}
+		if *existing.Kind != "COUPON" && (existing.Max_uses != nil || existing.Max_uses_per_customer != nil) {
+			apierror.Render(c, apierror.Validation("Validation failed: only coupons have usage limits"))
+			return
+		}
+
+		updatedAt, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		updateObj = append(updateObj, bson.E{Key: "updated_at", Value: updatedAt})
+
+		result, err := discountCollection().UpdateOne(
+			ctx,
+			bson.M{"discount_id": discountId},
+			bson.D{{Key: "$set", Value: updateObj}},
+		)
+		if err != nil {
+			apierror.Render(c, apierror.Internal("Update failed: "+err.Error()))
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Discount updated successfully", "result": result})
+	}
+}
+
+// DeleteDiscount removes a discount that was never used. Coupons already on
+// orders stay, so those orders price the same; deactivate them instead.
+func DeleteDiscount() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		var discount models.Discount
+		if err := discountCollection().FindOne(ctx, bson.M{"discount_id": c.Param("discount_id")}).Decode(&discount); err != nil {
+			apierror.Render(c, apierror.NotFound("Discount not found"))
+			return
+		}
+		if discount.Times_used > 0 {
+			apierror.Render(c, apierror.Conflict("Coupon is on orders already; deactivate it instead"))
+			return
+		}
+
+		result, err := discountCollection().DeleteOne(ctx, bson.M{"discount_id": discount.Discount_id, "times_used": bson.M{"$not": bson.M{"$gt": 0}}})
+		if err != nil {
+			apierror.Render(c, apierror.Internal("error occurred while deleting discount: "+err.Error()))
+			return
+		}
+		if result.DeletedCount == 0 {
+			apierror.Render(c, apierror.Conflict("Coupon is on orders already; deactivate it instead"))
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Discount deleted"})
+	}
+}
+
+// CheckCoupon tells the till or a guest at checkout whether a code can be
+// used on an order and what it would take off, without adding it.
+func CheckCoupon() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		var check models.CouponCheck
+		if err := c.BindJSON(&check); err != nil {
+			apierror.Render(c, apierror.BadRequest("Invalid request data: "+err.Error()))
+			return
+		}
+		if err := validate.Struct(check); err != nil {
+			apierror.Render(c, apierror.FromValidation(err))
+			return
+		}
+
+		var order models.Order
+		if err := orderCollection().FindOne(ctx, bson.M{"order_id": *check.Order_id}).Decode(&order); err != nil {
+			apierror.Render(c, apierror.NotFound("Order not found"))
+			return
+		}
+		if c.GetString("role") == "CUSTOMER" && stringValue(order.Customer_id) != c.GetString("uid") {
+			apierror.Render(c, apierror.NotFound("Order not found"))
+			return
+		}
+		if order.Priced_at != nil {
+			apierror.Render(c, apierror.Conflict("Order is already invoiced"))
+			return
+		}
+
+		coupon, err := couponFor(ctx, *check.Code)
+		if err != nil {
+			apierror.Render(c, apierror.NotFound("Coupon code is not valid"))
+			return
+		}
+		if apiErr := couponUsable(ctx, coupon, order); apiErr != nil {
+			apierror.Render(c, apiErr)
+			return
+		}
+
+		code := *coupon.Code
+		for _, added := range order.Discounts {
+			if stringValue(added.Kind) == "COUPON" && stringValue(added.Code) == code {
+				apierror.Render(c, apierror.Conflict("Coupon is already on this order"))
+				return
+			}
+		}
+		kind := "COUPON"
+		order.Discounts = append(order.Discounts, models.OrderDiscount{Kind: &kind, Code: &code})
+		pricing, err := orderPricing(ctx, order)
+		if err != nil {
+			apierror.Render(c, apierror.Internal("error occurred while pricing order: "+err.Error()))
+			return
+		}
+		if coupon.Min_subtotal != nil && pricing.Subtotal < *coupon.Min_subtotal {
+			apierror.Render(c, apierror.Validation(fmt.Sprintf("Coupon needs an order of at least %.2f", *coupon.Min_subtotal)))
+			return
+		}
+		amount := 0.0
+		for _, adjustment := range pricing.Adjustments {
+			if adjustment.Source_id == coupon.Discount_id {
+				amount += adjustment.Amount
+			}
+		}
+
+		c.JSON(http.StatusOK, gin.H{"discount_id": coupon.Discount_id, "code": code, "name": coupon.Name, "amount": toFixed(amount, 2), "pricing": pricing})
+	}
+}
+
+func GetDiscountPolicy() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		var policy models.DiscountPolicy
+		err := discountPolicyCollection().FindOne(ctx, bson.M{"location": c.Param("location")}).Decode(&policy)
+		if err != nil {
+			apierror.Render(c, apierror.NotFound("Discount policy not found"))
+			return
+		}
+
+		c.JSON(http.StatusOK, policy)
+	}
+}
+
+// UpdateDiscountPolicy creates or updates how discounts combine at a
+// location.
+func UpdateDiscountPolicy() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		var policy models.DiscountPolicy
+		if err := c.BindJSON(&policy); err != nil {
+			apierror.Render(c, apierror.BadRequest("Invalid request data: "+err.Error()))
+			return
+		}
+		if err := validate.Struct(policy); err != nil {
+			apierror.Render(c, apierror.FromValidation(err))
+			return
+		}
+		if policy.Application_order != nil && len(policy.Application_order) != len(discountKinds) {
+			apierror.Render(c, apierror.Validation("Validation failed: application_order must list "+strings.Join(discountKinds, ", ")))
+			return
+		}
+
+		var updateObj primitive.D
+		if policy.Application_order != nil {
+			updateObj = append(updateObj, bson.E{Key: "application_order", Value: policy.Application_order})
+		}
+		if policy.Exclusive_kinds != nil {
+			updateObj = append(updateObj, bson.E{Key: "exclusive_kinds", Value: policy.Exclusive_kinds})
+		}
+		if policy.Max_coupons != nil {
+			updateObj = append(updateObj, bson.E{Key: "max_coupons", Value: policy.Max_coupons})
+		}
+		if policy.Max_discount_percent != nil {
+			updateObj = append(updateObj, bson.E{Key: "max_discount_percent", Value: policy.Max_discount_percent})
+		}
+		if policy.Max_manual_percent != nil {
+			updateObj = append(updateObj, bson.E{Key: "max_manual_percent", Value: policy.Max_manual_percent})
+		}
+
+		now, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		updateObj = append(updateObj, bson.E{Key: "updated_at", Value: now})
+
+		upsert := true
+		opt := options.UpdateOptions{Upsert: &upsert}
+
+		result, err := discountPolicyCollection().UpdateOne(
+			ctx,
+			bson.M{"location": c.Param("location")},
+			bson.D{
+				{Key: "$set", Value: updateObj},
+				{Key: "$setOnInsert", Value: bson.D{{Key: "_id", Value: primitive.NewObjectID()}, {Key: "created_at", Value: now}}},
+			},
+			&opt,
+		)
+		if err != nil {
+			apierror.Render(c, apierror.Internal("Update failed: "+err.Error()))
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Discount policy updated successfully", "result": result})
+	}
+}
+
+// AddOrderDiscount adds a coupon or a manual discount to an open order.
+func AddOrderDiscount() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		var discount models.OrderDiscount
+		if err := c.BindJSON(&discount); err != nil {
+			apierror.Render(c, apierror.BadRequest("Invalid request data: "+err.Error()))
+			return
+		}
+		if err := validate.Struct(discount); err != nil {
+			apierror.Render(c, apierror.FromValidation(err))
+			return
+		}
+
+		var order models.Order
+		if err := orderCollection().FindOne(ctx, bson.M{"order_id": c.Param("order_id")}).Decode(&order); err != nil {
+			apierror.Render(c, apierror.NotFound("Order not found"))
+			return
+		}
+
+		pricing, apiErr := addOrderDiscount(ctx, c, order, &discount)
+		if apiErr != nil {
+			apierror.Render(c, apiErr)
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Discount added", "data": discount, "pricing": pricing})
+	}
+}
+
+// AddInvoiceDiscount takes a manual discount off an open invoice, either off
+// one line (ITEM) or off the whole invoice (ORDER). The discount is stored
+// on the invoice's order and the payment due is priced again.
+func AddInvoiceDiscount() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		var discount models.OrderDiscount
+		if err := c.BindJSON(&discount); err != nil {
+			apierror.Render(c, apierror.BadRequest("Invalid request data: "+err.Error()))
+			return
+		}
+		if err := validate.Struct(discount); err != nil {
+			apierror.Render(c, apierror.FromValidation(err))
+			return
+		}
+		if *discount.Kind != "MANUAL" {
+			apierror.Render(c, apierror.Validation("Validation failed: only MANUAL discounts are added to invoices; add coupons to the order"))
+			return
+		}
+
+		var invoice models.Invoice
+		if err := invoiceCollection().FindOne(ctx, bson.M{"invoice_id": c.Param("invoice_id")}).Decode(&invoice); err != nil {
+			apierror.Render(c, apierror.NotFound("Invoice not found"))
+			return
+		}
+		if stringValue(invoice.Payment_status) != "PENDING" || invoice.Finalized_at != nil {
+			apierror.Render(c, apierror.Conflict("Invoice is already settled"))
+			return
+		}
+		var order models.Order
+		if err := orderCollection().FindOne(ctx, bson.M{"order_id": invoice.Order_id}).Decode(&order); err != nil {
+			apierror.Render(c, apierror.NotFound("Order not found"))
+			return
+		}
+
+		pricing, apiErr := addOrderDiscount(ctx, c, order, &discount)
+		if apiErr != nil {
+			apierror.Render(c, apiErr)
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Discount added", "data": discount, "pricing": pricing})
+	}
+}
+
+// addOrderDiscount checks a discount staff are adding to an order and
+// stores it with who added it. Manual discounts may not take off more than
+// the policy allows the caller's role. The order's open invoice, if any, is
+// priced again, and the new pricing returned.
+func addOrderDiscount(ctx context.Context, c *gin.Context, order models.Order, discount *models.OrderDiscount) (models.OrderPricing, *apierror.Error) {
+	if order.Status == "MERGED" {
+		return models.OrderPricing{}, apierror.Conflict("Order was merged into " + stringValue(order.Merged_into))
+	}
+	if order.Priced_at != nil {
+		return models.OrderPricing{}, apierror.Conflict("Order is already invoiced")
+	}
+
+	if *discount.Kind == "COUPON" {
+		code := strings.ToUpper(strings.TrimSpace(*discount.Code))
+		discount.Code = &code
+		discount.Scope, discount.Order_item_id, discount.Percent_off, discount.Amount_off = nil, nil, nil, nil
+		discount.Reason_code = nil
+
+		coupon, err := couponFor(ctx, code)
+		if err != nil {
+			return models.OrderPricing{}, apierror.NotFound("Coupon code is not valid")
+		}
+
+		coupons := 0
+		for _, added := range order.Discounts {
+			if stringValue(added.Kind) != "COUPON" {
+				continue
+			}
+			if stringValue(added.Code) == code {
+				return models.OrderPricing{}, apierror.Conflict("Coupon is already on this order")
+			}
+			coupons++
+		}
+		policy, err := discountPolicyFor(ctx, orderLocation(ctx, order))
+		if err != nil {
+			return models.OrderPricing{}, apierror.Internal("error occurred while loading discount policy: " + err.Error())
+		}
+		if policy.Max_coupons != nil && coupons >= *policy.Max_coupons {
+			return models.OrderPricing{}, apierror.Conflict("Order already has the most coupons allowed")
+		}
+		if apiErr := claimCoupon(ctx, coupon, order); apiErr != nil {
+			return models.OrderPricing{}, apiErr
+		}
+	} else {
+		if (discount.Percent_off == nil) == (discount.Amount_off == nil) {
+			return models.OrderPricing{}, apierror.Validation("Validation failed: give either percent_off or amount_off")
+		}
+		if *discount.Scope != "ITEM" {
+			discount.Order_item_id = nil
+		}
+		if apiErr := checkManualDiscount(ctx, c.GetString("role"), order, *discount); apiErr != nil {
+			return models.OrderPricing{}, apiErr
+		}
+	}
+
+	discount.Applied_by = staffId(c)
+	discount.Added_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+	discount.Order_discount_id = primitive.NewObjectID().Hex()
+
+	_, err := orderCollection().UpdateOne(
+		ctx,
+		bson.M{"order_id": order.Order_id},
+		bson.D{
+			{Key: "$push", Value: bson.D{{Key: "discounts", Value: discount}}},
+			{Key: "$set", Value: bson.D{{Key: "updated_at", Value: discount.Added_at}}},
+		},
+	)
+	if err != nil {
+		if *discount.Kind == "COUPON" {
+			releaseCoupon(ctx, *discount.Code)
+		}
+		return models.OrderPricing{}, apierror.Internal("Update failed: " + err.Error())
+	}
+
+	order.Discounts = append(order.Discounts, *discount)
+	pricing, err := refreshOrderInvoice(ctx, order)
+	if err != nil {
+		return pricing, apierror.Internal("error occurred while pricing order: " + err.Error())
+	}
+	return pricing, nil
+}
+
+// checkManualDiscount checks a manual discount is for an item on the order
+// and within the most the policy lets role take off: a share of the item
+// for ITEM discounts, of the order's subtotal for ORDER discounts.
+func checkManualDiscount(ctx context.Context, role string, order models.Order, discount models.OrderDiscount) *apierror.Error {
+	base := 0.0
+	if *discount.Scope == "ITEM" {
+		var orderItem models.OrderItem
+		if err := orderItemCollection().FindOne(ctx, bson.M{"order_id": order.Order_id, "order_item_id": discount.Order_item_id}).Decode(&orderItem); err != nil {
+			return apierror.NotFound("Order item not found on this order")
+		}
+		if orderItem.Unit_price != nil {
+			base = *orderItem.Unit_price
+		}
+	}
+
+	policy, err := discountPolicyFor(ctx, orderLocation(ctx, order))
+	if err != nil {
+		return apierror.Internal("error occurred while loading discount policy: " + err.Error())
+	}
+	limit, ok := policy.Max_manual_percent[role]
+	if !ok {
+		return nil
+	}
+
+	percent := 0.0
+	if discount.Percent_off != nil {
+		percent = *discount.Percent_off
+	} else {
+		if *discount.Scope == "ORDER" {
+			pricing, err := orderPricing(ctx, order)
+			if err != nil {
+				return apierror.Internal("error occurred while pricing order: " + err.Error())
+			}
+			base = pricing.Subtotal
+		}
+		percent = 100
+		if base > 0 {
+			percent = *discount.Amount_off / base * 100
+		}
+	}
+	if percent > limit {
+		return apierror.Forbidden(fmt.Sprintf("%s staff may take at most %g%% off; ask a manager", strings.ToLower(role), limit))
+	}
+	return nil
+}
+
+// refreshOrderInvoice prices an order as it stands and, when it has a
+// pending invoice, stores the new total as its payment due.
+func refreshOrderInvoice(ctx context.Context, order models.Order) (models.OrderPricing, error) {
+	var invoice models.Invoice
+	err := invoiceCollection().FindOne(ctx, bson.M{"order_id": order.Order_id}).Decode(&invoice)
+	if err == mongo.ErrNoDocuments {
+		return orderPricing(ctx, order)
+	}
+	if err != nil {
+		return models.OrderPricing{}, err
+	}
+	return refreshInvoiceDue(ctx, order, invoice.Invoice_id)
+}
+
+func RemoveOrderDiscount() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		var order models.Order
+		if err := orderCollection().FindOne(ctx, bson.M{"order_id": c.Param("order_id")}).Decode(&order); err != nil {
+			apierror.Render(c, apierror.NotFound("Order not found"))
+			return
+		}
+		if order.Priced_at != nil {
+			apierror.Render(c, apierror.Conflict("Order is already invoiced"))
+			return
+		}
+
+		var removed models.OrderDiscount
+		for _, added := range order.Discounts {
+			if added.Order_discount_id == c.Param("order_discount_id") {
+				removed = added
+			}
+		}
+
+		result, err := orderCollection().UpdateOne(
+			ctx,
+			bson.M{"order_id": order.Order_id, "discounts.order_discount_id": c.Param("order_discount_id")},
+			bson.D{{Key: "$pull", Value: bson.D{{Key: "discounts", Value: bson.D{{Key: "order_discount_id", Value: c.Param("order_discount_id")}}}}}},
+		)
+		if err != nil {
+			apierror.Render(c, apierror.Internal("Update failed: "+err.Error()))
+			return
+		}
+		if result.MatchedCount == 0 {
+			apierror.Render(c, apierror.NotFound("Discount not found on this order"))
+			return
+		}
+		if stringValue(removed.Kind) == "COUPON" {
+			releaseCoupon(ctx, stringValue(removed.Code))
+		}
+		if stringValue(removed.Kind) == "LOYALTY" {
+			if err := returnLoyaltyPoints(ctx, removed); err != nil {
+				log.Println("Error returning loyalty points:", err)
+			}
+		}
+		remaining := []models.OrderDiscount{}
+		for _, added := range order.Discounts {
+			if added.Order_discount_id != removed.Order_discount_id {
+				remaining = append(remaining, added)
+			}
+		}
+		order.Discounts = remaining
+		if _, err := refreshOrderInvoice(ctx, order); err != nil {
+			log.Println("Error pricing invoice:", err)
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Discount removed"})
+	}
+}
+
+// GetOrderPricing itemizes an order's subtotal, discounts in the order they
+// were applied, fees and total.
+func GetOrderPricing() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		var order models.Order
+		if err := orderCollection().FindOne(ctx, bson.M{"order_id": c.Param("order_id")}).Decode(&order); err != nil {
+			apierror.Render(c, apierror.NotFound("Order not found"))
+			return
+		}
+
+		pricing, err := orderPricing(ctx, order)
+		if err != nil {
+			apierror.Render(c, apierror.Internal("error occurred while pricing order: "+err.Error()))
+			return
+		}
+
+		c.JSON(http.StatusOK, pricing)
+	}
+}
+
+// discountPolicyFor returns the discount policy of a location, falling back
+// to the default location's and then to applying every kind in the default
+// order without caps.
+func discountPolicyFor(ctx context.Context, location string) (models.DiscountPolicy, error) {
+	for _, candidate := range []string{location, defaultLocation} {
+		var policy models.DiscountPolicy
+		err := discountPolicyCollection().FindOne(ctx, bson.M{"location": candidate}).Decode(&policy)
+		if err == nil {
+			if len(policy.Application_order) == 0 {
+				policy.Application_order = discountKinds
+			}
+			// Kinds added since the policy was saved apply after the others
+			listed := map[string]bool{}
+			for _, kind := range policy.Application_order {
+				listed[kind] = true
+			}
+			for _, kind := range discountKinds {
+				if !listed[kind] {
+					policy.Application_order = append(policy.Application_order, kind)
+				}
+			}
+			return policy, nil
+		}
+		if err != mongo.ErrNoDocuments {
+			return policy, err
+		}
+	}
+	return models.DiscountPolicy{Location: location, Application_order: discountKinds}, nil
+}
+
+// orderLocation is the location of the table an order is for.
+func orderLocation(ctx context.Context, order models.Order) string {
+	var table models.Table
+	if order.Table_id != nil {
+		if err := tableCollection().FindOne(ctx, bson.M{"table_id": order.Table_id}).Decode(&table); err == nil {
+			return stringOr(table.Location, defaultLocation)
+		}
+	}
+	return defaultLocation
+}
+
+// discountLiveAt reports whether a discount can be used at t.
+func discountLiveAt(discount models.Discount, t time.Time) bool {
+	if discount.Active == nil || !*discount.Active {
+		return false
+	}
+	if discount.Effective_from != nil && t.Before(*discount.Effective_from) {
+		return false
+	}
+	return discount.Effective_to == nil || !t.After(*discount.Effective_to)
+}
+
+// couponFor looks up a coupon by its code, as typed at the till or by the
+// guest, and checks it can be used now.
+func couponFor(ctx context.Context, code string) (models.Discount, error) {
+	var coupon models.Discount
+	err := discountCollection().FindOne(ctx, bson.M{"kind": "COUPON", "code": strings.ToUpper(strings.TrimSpace(code))}).Decode(&coupon)
+	if err == nil && !discountLiveAt(coupon, time.Now()) {
+		err = mongo.ErrNoDocuments
+	}
+	return coupon, err
+}
+
+// couponUsable checks the usage limits of a coupon for an order. It only
+// reads them; claimCoupon takes a use.
+func couponUsable(ctx context.Context, coupon models.Discount, order models.Order) *apierror.Error {
+	if coupon.Max_uses != nil && coupon.Times_used >= *coupon.Max_uses {
+		return apierror.Conflict("Coupon has been used up")
+	}
+	if coupon.Max_uses_per_customer != nil && order.Customer_id != nil {
+		used, err := orderCollection().CountDocuments(ctx, bson.M{
+			"customer_id":    *order.Customer_id,
+			"order_id":       bson.M{"$ne": order.Order_id},
+			"discounts.code": coupon.Code,
+			"deleted_at":     notDeleted,
+		})
+		if err != nil {
+			return apierror.Internal("error occurred while counting coupon uses: " + err.Error())
+		}
+		if used >= int64(*coupon.Max_uses_per_customer) {
+			return apierror.Conflict("Customer has used this coupon as often as allowed")
+		}
+	}
+	return nil
+}
+
+// claimCoupon takes one use of a coupon for an order. The counter only goes
+// up while it is below Max_uses, in the same update, so a limited code
+// cannot be added to more orders than it allows however many tills try at
+// once.
+func claimCoupon(ctx context.Context, coupon models.Discount, order models.Order) *apierror.Error {
+	if apiErr := couponUsable(ctx, coupon, order); apiErr != nil {
+		return apiErr
+	}
+	filter := bson.M{"discount_id": coupon.Discount_id}
+	if coupon.Max_uses != nil {
+		filter["$expr"] = bson.M{"$lt": bson.A{bson.M{"$ifNull": bson.A{"$times_used", 0}}, "$max_uses"}}
+	}
+	result, err := discountCollection().UpdateOne(ctx, filter, bson.M{"$inc": bson.M{"times_used": 1}})
+	if err != nil {
+		return apierror.Internal("error occurred while using coupon: " + err.Error())
+	}
+	if result.MatchedCount == 0 {
+		return apierror.Conflict("Coupon has been used up")
+	}
+	return nil
+}
+
+// releaseCoupon gives back the use of a coupon taken off an order.
+func releaseCoupon(ctx context.Context, code string) {
+	_, err := discountCollection().UpdateOne(
+		ctx,
+		bson.M{"kind": "COUPON", "code": code, "times_used": bson.M{"$gt": 0}},
+		bson.M{"$inc": bson.M{"times_used": -1}},
+	)
+	if err != nil {
+		log.Println("Error releasing coupon use:", err)
+	}
+}
+
+// orderPricing prices an order as it stands.
+func orderPricing(ctx context.Context, order models.Order) (models.OrderPricing, error) {
+	orderItems, err := orderItemsOf(ctx, order.Order_id)
+	if err != nil {
+		return models.OrderPricing{}, err
+	}
+	_, pricing, err := priceOrder(ctx, order, orderItems)
+	return pricing, err
+}
+
+// storeOrderPricing keeps the discounts priceOrder worked out on the order
+// items, so the finalized invoice and sales reports no longer change when
+// promotions or the policy do.
+func storeOrderPricing(ctx context.Context, order models.Order, priced []models.OrderItem) error {
+	if order.Priced_at != nil {
+		return nil
+	}
+	for _, orderItem := range priced {
+		_, err := orderItemCollection().UpdateOne(ctx, bson.M{"order_item_id": orderItem.Order_item_id}, bson.M{"$set": bson.M{"adjustments": orderItem.Adjustments}})
+		if err != nil {
+			return err
+		}
+	}
+	now, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+	_, err := orderCollection().UpdateOne(ctx, bson.M{"order_id": order.Order_id}, bson.M{"$set": bson.M{"priced_at": now}})
+	return err
+}
+
+// discountCandidate is a discount that may apply to an order, with items
+// the indexes of the order items it may apply to.
+type discountCandidate struct {
+	kind        string
+	sourceId    string
+	description string
+	scope       string
+	percent     float64
+	amount      float64
+	maxAmount   float64
+	stackable   bool
+	priority    int
+	items       []int
+}
+
+// priceOrder applies the discounts of an order to its items and returns the
+// items with their adjustments rewritten, together with the itemized
+// pricing. Order discounts and the policy cap are spread over the items they
+// come off, so tax is worked out on what was actually paid. The result only
+// depends on the order, its items and the discounts and policy in force, so
+// pricing an order twice gives the same breakdown. Once an order's invoice
+// is finalized its discounts are stored on the items and no longer change.
+func priceOrder(ctx context.Context, order models.Order, orderItems []models.OrderItem) ([]models.OrderItem, models.OrderPricing, error) {
+	pricing := models.OrderPricing{Order_id: order.Order_id, Adjustments: []models.PriceAdjustment{}, Fees: order.Fees}
+	if pricing.Fees == nil {
+		pricing.Fees = []models.PriceAdjustment{}
+	}
+
+	location := orderLocation(ctx, order)
+	policy, err := discountPolicyFor(ctx, location)
+	if err != nil {
+		return nil, pricing, err
+	}
+
+	isDiscount := map[string]bool{"DISCOUNT_CAP": true}
+	for _, kind := range discountKinds {
+		isDiscount[kind] = true
+	}
+
+	// Happy hour is stored on the item when it is rung up; everything else
+	// that takes money off is worked out here
+	priced := make([]models.OrderItem, len(orderItems))
+	nets := make([]float64, len(orderItems))
+	taken := make([][]models.PriceAdjustment, len(orderItems))
+	happyHours := []discountCandidate{}
+	for i, orderItem := range orderItems {
+		priced[i] = orderItem
+		priced[i].Adjustments = []models.PriceAdjustment{}
+		if orderItem.Unit_price != nil {
+			nets[i] = *orderItem.Unit_price
+		}
+		for _, adjustment := range orderItem.Adjustments {
+			switch {
+			case !isDiscount[adjustment.Type]:
+				priced[i].Adjustments = append(priced[i].Adjustments, adjustment)
+				nets[i] += adjustment.Amount
+			case order.Priced_at != nil:
+				taken[i] = append(taken[i], adjustment)
+			case adjustment.Type == "HAPPY_HOUR":
+				happyHours = append(happyHours, discountCandidate{
+					kind:        "HAPPY_HOUR",
+					sourceId:    adjustment.Source_id,
+					description: adjustment.Description,
+					scope:       "ITEM",
+					amount:      -adjustment.Amount,
+					stackable:   true,
+					items:       []int{i},
+				})
+			}
+		}
+		nets[i] = toFixed(nets[i], 2)
+		pricing.Subtotal += nets[i]
+	}
+	pricing.Subtotal = toFixed(pricing.Subtotal, 2)
+
+	if order.Priced_at == nil {
+		candidates, err := discountCandidates(ctx, order, orderItems, policy, pricing.Subtotal)
+		if err != nil {
+			return nil, pricing, err
+		}
+		taken = applyDiscounts(append(happyHours, candidates...), policy, nets, pricing.Subtotal)
+	}
+
+	totals := map[string]int{}
+	for i := range priced {
+		priced[i].Adjustments = append(priced[i].Adjustments, taken[i]...)
+		for _, adjustment := range taken[i] {
+			key := adjustment.Type + "/" + adjustment.Source_id
+			if index, ok := totals[key]; ok {
+				pricing.Adjustments[index].Amount = toFixed(pricing.Adjustments[index].Amount+adjustment.Amount, 2)
+			} else {
+				totals[key] = len(pricing.Adjustments)
+				pricing.Adjustments = append(pricing.Adjustments, adjustment)
+			}
+			pricing.Discount_total += adjustment.Amount
+		}
+	}
+	pricing.Discount_total = toFixed(pricing.Discount_total, 2)
+
+	// Adjustments list in application order whatever item they came off first
+	position := map[string]int{"DISCOUNT_CAP": len(policy.Application_order)}
+	for i, kind := range policy.Application_order {
+		position[kind] = i
+	}
+	sort.SliceStable(pricing.Adjustments, func(i, j int) bool {
+		return position[pricing.Adjustments[i].Type] < position[pricing.Adjustments[j].Type]
+	})
+
+	if err := extensions.Pricing(ctx, order, priced, &pricing); err != nil {
+		return nil, pricing, err
+	}
+
+	// Tax is worked out on what is left after discounts
+	rateFor, err := currentTaxRates(ctx, location)
+	if err != nil {
+		return nil, pricing, err
+	}
+	if pricing.Tax_lines, err = taxLines(ctx, priced, pricing.Fees, rateFor); err != nil {
+		return nil, pricing, err
+	}
+
+	pricing.Total = pricing.Subtotal + pricing.Discount_total
+	for _, fee := range pricing.Fees {
+		pricing.Total += fee.Amount
+	}
+	for _, line := range pricing.Tax_lines {
+		pricing.Tax_total += line.Tax
+		if line.Exclusive {
+			pricing.Total += line.Tax
+		}
+	}
+	pricing.Tax_total = toFixed(pricing.Tax_total, 2)
+	pricing.Total = toFixed(pricing.Total, 2)
+	return priced, pricing, nil
+}
+
+// discountCandidates gathers the promotions running when the order was
+// placed, its coupons and its manual discounts, each with the items it may
+// come off.
+func discountCandidates(ctx context.Context, order models.Order, orderItems []models.OrderItem, policy models.DiscountPolicy, subtotal float64) ([]discountCandidate, error) {
+	foodIds := []string{}
+	for _, orderItem := range orderItems {
+		foodIds = append(foodIds, stringValue(orderItem.Food_id))
+	}
+	foodDocs, categories, err := foodCategories(ctx, foodIds)
+	if err != nil {
+		return nil, err
+	}
+
+	eligible := func(discount models.Discount) []int {
+		foods := map[string]bool{}
+		for _, foodId := range discount.Food_ids {
+			foods[foodId] = true
+		}
+		menus := map[string]bool{}
+		for _, menuId := range discount.Menu_ids {
+			menus[menuId] = true
+		}
+		category := stringValue(discount.Category)
+		items := []int{}
+		for i, orderItem := range orderItems {
+			foodId := stringValue(orderItem.Food_id)
+			if len(menus) > 0 && !menus[stringValue(foodDocs[foodId].Menu_id)] {
+				continue
+			}
+			if (len(foods) == 0 && category == "") || foods[foodId] || (category != "" && categories[foodId] == category) {
+				items = append(items, i)
+			}
+		}
+		return items
+	}
+	usable := func(discount models.Discount) bool {
+		return discountLiveAt(discount, order.Order_Date) && (discount.Min_subtotal == nil || subtotal >= *discount.Min_subtotal)
+	}
+	candidates := []discountCandidate{}
+	add := func(discount models.Discount) {
+		candidate := discountCandidate{
+			kind:        *discount.Kind,
+			sourceId:    discount.Discount_id,
+			description: *discount.Name,
+			scope:       *discount.Scope,
+			stackable:   discount.Stackable != nil && *discount.Stackable,
+			items:       eligible(discount),
+		}
+		if discount.Percent_off != nil {
+			candidate.percent = *discount.Percent_off
+		}
+		if discount.Amount_off != nil {
+			candidate.amount = *discount.Amount_off
+		}
+		if discount.Max_amount != nil {
+			candidate.maxAmount = *discount.Max_amount
+		}
+		if discount.Priority != nil {
+			candidate.priority = *discount.Priority
+		}
+		candidates = append(candidates, candidate)
+	}
+
+	cursor, err := discountCollection().Find(ctx, bson.M{"kind": "PROMOTION", "active": true})
+	if err != nil {
+		return nil, err
+	}
+	var promotions []models.Discount
+	if err = cursor.All(ctx, &promotions); err != nil {
+		return nil, err
+	}
+	for _, promotion := range promotions {
+		if usable(promotion) {
+			add(promotion)
+		}
+	}
+
+	codes := []string{}
+	for _, added := range order.Discounts {
+		if stringValue(added.Kind) == "COUPON" && (policy.Max_coupons == nil || len(codes) < *policy.Max_coupons) {
+			codes = append(codes, stringValue(added.Code))
+		}
+	}
+	if len(codes) > 0 {
+		cursor, err := discountCollection().Find(ctx, bson.M{"kind": "COUPON", "code": bson.M{"$in": codes}})
+		if err != nil {
+			return nil, err
+		}
+		var coupons []models.Discount
+		if err = cursor.All(ctx, &coupons); err != nil {
+			return nil, err
+		}
+		// Coupons are honoured if they were valid when the order was placed
+		for _, coupon := range coupons {
+			if usable(coupon) {
+				add(coupon)
+			}
+		}
+	}
+
+	// Upsell offers the guest accepted and redeemed loyalty points price
+	// like manual discounts
+	for _, added := range order.Discounts {
+		kind := stringValue(added.Kind)
+		if kind != "MANUAL" && kind != "UPSELL" && kind != "LOYALTY" {
+			continue
+		}
+		description := "Manual: " + stringOr(added.Reason, stringValue(added.Reason_code))
+		switch kind {
+		case "UPSELL":
+			description = "Upsell: " + stringValue(added.Reason)
+		case "LOYALTY":
+			description = "Loyalty: " + stringValue(added.Reason)
+		}
+		candidate := discountCandidate{
+			kind:        kind,
+			sourceId:    added.Order_discount_id,
+			description: description,
+			scope:       stringValue(added.Scope),
+			stackable:   true,
+		}
+		if added.Percent_off != nil {
+			candidate.percent = *added.Percent_off
+		}
+		if added.Amount_off != nil {
+			candidate.amount = *added.Amount_off
+		}
+		for i, orderItem := range orderItems {
+			if candidate.scope == "ORDER" || orderItem.Order_item_id == stringValue(added.Order_item_id) {
+				candidate.items = append(candidate.items, i)
+			}
+		}
+		candidates = append(candidates, candidate)
+	}
+	return candidates, nil
+}
+
+// applyDiscounts takes the candidates off the item amounts in nets following
+// the policy: item discounts item by item, then order discounts, then the
+// cap on all discounts together. It returns the adjustments of each item.
+func applyDiscounts(candidates []discountCandidate, policy models.DiscountPolicy, nets []float64, subtotal float64) [][]models.PriceAdjustment {
+	rank := map[string]int{}
+	for i, kind := range policy.Application_order {
+		rank[kind] = i
+	}
+	exclusive := map[string]bool{}
+	for _, kind := range policy.Exclusive_kinds {
+		exclusive[kind] = true
+	}
+	for i := range candidates {
+		if exclusive[candidates[i].kind] {
+			candidates[i].stackable = false
+		}
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		a, b := candidates[i], candidates[j]
+		if rank[a.kind] != rank[b.kind] {
+			return rank[a.kind] < rank[b.kind]
+		}
+		if a.priority != b.priority {
+			return a.priority > b.priority
+		}
+		return a.sourceId < b.sourceId
+	})
+
+	// The stackable discounts together are one choice, each exclusive
+	// discount on its own another
+	choicesFor := func(include func(discountCandidate) bool) [][]int {
+		choices := [][]int{}
+		stack := []int{}
+		for j, candidate := range candidates {
+			if !include(candidate) {
+				continue
+			}
+			if candidate.stackable {
+				stack = append(stack, j)
+			} else {
+				choices = append(choices, []int{j})
+			}
+		}
+		if len(stack) > 0 {
+			choices = append([][]int{stack}, choices...)
+		}
+		return choices
+	}
+
+	// Caps are shared by every item a discount comes off
+	remaining := make([]float64, len(candidates))
+	for i, candidate := range candidates {
+		remaining[i] = math.Inf(1)
+		if candidate.maxAmount > 0 {
+			remaining[i] = candidate.maxAmount
+		}
+	}
+	taken := make([][]models.PriceAdjustment, len(nets))
+
+	for i := range nets {
+		choices := choicesFor(func(candidate discountCandidate) bool {
+			return candidate.scope == "ITEM" && containsIndex(candidate.items, i)
+		})
+		takeBestDiscounts(candidates, choices, nets, remaining, taken, func(int) []int { return []int{i} })
+	}
+	choices := choicesFor(func(candidate discountCandidate) bool { return candidate.scope == "ORDER" })
+	takeBestDiscounts(candidates, choices, nets, remaining, taken, func(j int) []int { return candidates[j].items })
+
+	if policy.Max_discount_percent != nil {
+		discounted := make([]float64, len(nets))
+		total := 0.0
+		for i := range taken {
+			for _, adjustment := range taken[i] {
+				discounted[i] -= adjustment.Amount
+				total -= adjustment.Amount
+			}
+		}
+		excess := toFixed(total-subtotal**policy.Max_discount_percent/100, 2)
+		if excess > 0 {
+			for i, amount := range allocateCents(excess, discounted) {
+				if amount > 0 {
+					taken[i] = append(taken[i], models.PriceAdjustment{
+						Type:        "DISCOUNT_CAP",
+						Description: fmt.Sprintf("Discounts capped at %g%% of subtotal", *policy.Max_discount_percent),
+						Amount:      amount,
+					})
+				}
+			}
+		}
+	}
+	return taken
+}
+
+// takeBestDiscounts tries each choice, a list of candidates applied in
+// turn, on a copy of the item amounts and keeps the one taking off the most.
+// Ties go to the earlier choice, so the stackable discounts, listed first,
+// win over an exclusive one worth the same.
+func takeBestDiscounts(candidates []discountCandidate, choices [][]int, nets, remaining []float64, taken [][]models.PriceAdjustment, itemsOf func(int) []int) {
+	var best [][]models.PriceAdjustment
+	var bestNets, bestRemaining []float64
+	bestTotal := 0.0
+	for _, choice := range choices {
+		trialNets := append([]float64{}, nets...)
+		trialRemaining := append([]float64{}, remaining...)
+		trial := make([][]models.PriceAdjustment, len(nets))
+		total := 0.0
+		for _, j := range choice {
+			candidate := candidates[j]
+			items := itemsOf(j)
+			for i, amount := range discountAmounts(candidate, items, trialNets, trialRemaining[j]) {
+				if amount <= 0 {
+					continue
+				}
+				item := items[i]
+				trialNets[item] = toFixed(trialNets[item]-amount, 2)
+				trialRemaining[j] -= amount
+				total += amount
+				trial[item] = append(trial[item], models.PriceAdjustment{
+					Type:        candidate.kind,
+					Source_id:   candidate.sourceId,
+					Description: candidate.description,
+					Amount:      -amount,
+				})
+			}
+		}
+		if total > bestTotal+0.001 {
+			best, bestNets, bestRemaining, bestTotal = trial, trialNets, trialRemaining, total
+		}
+	}
+	if best == nil {
+		return
+	}
+	copy(nets, bestNets)
+	copy(remaining, bestRemaining)
+	for i := range taken {
+		taken[i] = append(taken[i], best[i]...)
+	}
+}
+
+// discountAmounts is what a discount takes off each of items, never more
+// than an item's amount or the discount's remaining cap. Item discounts
+// apply to each item on its own; an order discount is worked out on the
+// items together and spread over them.
+func discountAmounts(candidate discountCandidate, items []int, nets []float64, remaining float64) []float64 {
+	amounts := make([]float64, len(items))
+	if candidate.scope == "ITEM" {
+		for i, item := range items {
+			amount := candidate.amount
+			if candidate.percent > 0 {
+				amount = nets[item] * candidate.percent / 100
+			}
+			amount = toFixed(math.Min(math.Min(amount, nets[item]), remaining), 2)
+			if amount > 0 {
+				amounts[i] = amount
+				remaining -= amount
+			}
+		}
+		return amounts
+	}
+
+	weights := make([]float64, len(items))
+	base := 0.0
+	for i, item := range items {
+		weights[i] = math.Max(nets[item], 0)
+		base += weights[i]
+	}
+	amount := candidate.amount
+	if candidate.percent > 0 {
+		amount = base * candidate.percent / 100
+	}
+	amount = toFixed(math.Min(math.Min(amount, base), remaining), 2)
+	if amount <= 0 {
+		return amounts
+	}
+	return allocateCents(amount, weights)
+}
+
+// allocateCents splits amount over weights in whole cents. Cents left over
+// from rounding down go to the largest remainders, earlier entries first.
+func allocateCents(amount float64, weights []float64) []float64 {
+	shares := make([]float64, len(weights))
+	total := 0.0
+	for _, weight := range weights {
+		total += weight
+	}
+	if total <= 0 {
+		return shares
+	}
+
+	cents := int(math.Round(amount * 100))
+	given := 0
+	remainders := make([]float64, len(weights))
+	for i, weight := range weights {
+		exact := float64(cents) * weight / total
+		whole := int(math.Floor(exact + 1e-9))
+		shares[i] = float64(whole)
+		remainders[i] = exact - float64(whole)
+		given += whole
+	}
+	byRemainder := make([]int, len(weights))
+	for i := range byRemainder {
+		byRemainder[i] = i
+	}
+	sort.SliceStable(byRemainder, func(a, b int) bool { return remainders[byRemainder[a]] > remainders[byRemainder[b]] })
+	for k := 0; given < cents; k++ {
+		shares[byRemainder[k%len(byRemainder)]]++
+		given++
+	}
+	for i := range shares {
+		shares[i] /= 100
+	}
+	return shares
+}
+
+func containsIndex(indexes []int, index int) bool {
+	for _, i := range indexes {
+		if i == index {
+			return true
+		}
+	}
+	return false
+}
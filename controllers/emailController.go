@@ -0,0 +1,91 @@
+package controllers
+
+import (
+	"context"
+	"restaurant-management/config"
+	"restaurant-management/i18n"
+	"restaurant-management/models"
+	"restaurant-management/notifications/email"
+	"restaurant-management/receipt"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// emailReceipt queues the receipt of a paid invoice to the guest who
+// placed its order. Orders of guests without an email address are skipped.
+func emailReceipt(ctx context.Context, invoiceId string) error {
+	var invoice models.Invoice
+	if err := invoiceCollection().FindOne(ctx, bson.M{"invoice_id": invoiceId}).Decode(&invoice); err != nil {
+		return err
+	}
+	var order models.Order
+	if err := orderCollection().FindOne(ctx, bson.M{"order_id": invoice.Order_id}).Decode(&order); err != nil {
+		return err
+	}
+	if order.Customer_id == nil {
+		return nil
+	}
+	name, address, _, err := customerContact(ctx, *order.Customer_id)
+	if err != nil || address == "" {
+		return err
+	}
+
+	r, err := invoiceReceipt(ctx, invoice)
+	if err != nil {
+		return err
+	}
+	template, err := effectiveReceiptTemplate(ctx, stringValue(invoice.Location))
+	if err != nil {
+		return err
+	}
+
+	message, err := email.Render("receipt", []string{address}, email.ReceiptData{
+		Venue:   venueName(template),
+		Name:    name,
+		Receipt: receipt.Text(receiptLayout(template), r),
+	})
+	if err != nil {
+		return err
+	}
+	email.Queue(message)
+	return nil
+}
+
+// emailReservationConfirmation queues the confirmation of a new booking to
+// the guest, when they gave an email address.
+func emailReservationConfirmation(ctx context.Context, reservation models.Reservation) error {
+	address := stringValue(reservation.Customer_email)
+	if address == "" {
+		return nil
+	}
+	template, err := effectiveReceiptTemplate(ctx, stringValue(reservation.Location))
+	if err != nil {
+		return err
+	}
+	locale := i18n.Get(stringValue(template.Locale))
+
+	data := email.ReservationData{
+		Venue:      venueName(template),
+		Name:       stringValue(reservation.Customer_name),
+		Party_size: *reservation.Party_size,
+		When:       locale.Datetime(reservation.Reserved_at.Local()),
+		Link:       config.Get().PublicBaseURL + "/reservations/respond/" + reservation.Token,
+	}
+	if reservation.Deposit != nil {
+		data.Deposit = locale.Money(reservation.Deposit.Amount, reservation.Deposit.Currency)
+	}
+	message, err := email.Render("reservation_confirmation", []string{address}, data)
+	if err != nil {
+		return err
+	}
+	email.Queue(message)
+	return nil
+}
+
+// venueName is how guest messages name the restaurant.
+func venueName(template models.ReceiptTemplate) string {
+	if venue := stringValue(template.Business_name); venue != "" {
+		return venue
+	}
+	return "us"
+}
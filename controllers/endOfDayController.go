@@ -0,0 +1,186 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"restaurant-management/apierror"
+	"restaurant-management/config"
+	"restaurant-management/models"
+	"restaurant-management/notifications/email"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type EndOfDayReport struct {
+	Date         string                        `json:"date"`
+	Location     string                        `json:"location"`
+	Orders       int64                         `json:"orders"`
+	Items        int                           `json:"items"`
+	Sales        float64                       `json:"sales"`
+	Expenses     float64                       `json:"expenses"`
+	Drawers      []models.DrawerReconciliation `json:"drawers"`
+	Missed_tasks int64                         `json:"missed_tasks"`
+	Notes        []models.ManagerNote          `json:"notes"`
+}
+
+// GetEndOfDayReport previews the end-of-day report for ?date= (default
+// today) without emailing it.
+func GetEndOfDayReport() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		day := time.Now()
+		if value := c.Query("date"); value != "" {
+			parsed, err := time.ParseInLocation("2006-01-02", value, time.Local)
+			if err != nil {
+				apierror.Render(c, apierror.BadRequest("date must be formatted as YYYY-MM-DD"))
+				return
+			}
+			day = parsed
+		}
+
+		report, err := buildEndOfDayReport(ctx, day, c.DefaultQuery("location", defaultLocation))
+		if err != nil {
+			apierror.Render(c, apierror.Internal("error building end-of-day report: "+err.Error()))
+			return
+		}
+
+		if c.Query("format") == "text" {
+			c.String(http.StatusOK, renderEndOfDayReport(report))
+			return
+		}
+		c.JSON(http.StatusOK, report)
+	}
+}
+
+// SendEndOfDayReport emails the day's report to EOD_REPORT_RECIPIENTS. It
+// runs daily on the scheduler.
+func SendEndOfDayReport(ctx context.Context) error {
+	recipients := email.Recipients("EOD_REPORT_RECIPIENTS")
+	if len(recipients) == 0 {
+		log.Println("end-of-day report: EOD_REPORT_RECIPIENTS is empty, skipping")
+		return nil
+	}
+
+	report, err := buildEndOfDayReport(ctx, time.Now(), defaultLocation)
+	if err != nil {
+		return err
+	}
+	return email.Send(ctx, email.Message{
+		To:      recipients,
+		Subject: fmt.Sprintf("End of day report – %s", report.Date),
+		Body:    renderEndOfDayReport(report),
+	})
+}
+
+func buildEndOfDayReport(ctx context.Context, day time.Time, location string) (EndOfDayReport, error) {
+	start := time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, day.Location())
+	end := start.AddDate(0, 0, 1)
+	inDay := bson.M{"$gte": start, "$lt": end}
+	report := EndOfDayReport{Date: start.Format("2006-01-02"), Location: location, Drawers: []models.DrawerReconciliation{}}
+
+	var err error
+	if report.Orders, err = orderCollection().CountDocuments(ctx, bson.M{"created_at": inDay}); err != nil {
+		return report, err
+	}
+
+	salesCursor, err := orderItemCollection().Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$match", Value: bson.D{{Key: "created_at", Value: inDay}}}},
+		{{Key: "$group", Value: bson.D{
+			{Key: "_id", Value: nil},
+			{Key: "items", Value: bson.D{{Key: "$sum", Value: 1}}},
+			{Key: "sales", Value: bson.D{{Key: "$sum", Value: bson.D{{Key: "$add", Value: bson.A{
+				"$unit_price",
+				bson.D{{Key: "$sum", Value: "$adjustments.amount"}},
+			}}}}}},
+		}}},
+	})
+	if err != nil {
+		return report, err
+	}
+	var sales []struct {
+		Items int     `bson:"items"`
+		Sales float64 `bson:"sales"`
+	}
+	if err = salesCursor.All(ctx, &sales); err != nil {
+		return report, err
+	}
+	if len(sales) > 0 {
+		report.Items = sales[0].Items
+		report.Sales = toFixed(sales[0].Sales, 2)
+	}
+
+	sessionCursor, err := drawerSessionCollection().Find(ctx, bson.M{"status": "CLOSED", "closed_at": inDay})
+	if err != nil {
+		return report, err
+	}
+	var sessions []models.DrawerSession
+	if err = sessionCursor.All(ctx, &sessions); err != nil {
+		return report, err
+	}
+	for _, session := range sessions {
+		reconciliation, err := reconcileDrawer(ctx, session)
+		if err != nil {
+			return report, err
+		}
+		report.Drawers = append(report.Drawers, reconciliation)
+	}
+
+	expenseCursor, err := expenseCollection().Find(ctx, bson.M{"expense_date": inDay, "status": bson.M{"$ne": "REJECTED"}})
+	if err != nil {
+		return report, err
+	}
+	var expenses []models.Expense
+	if err = expenseCursor.All(ctx, &expenses); err != nil {
+		return report, err
+	}
+	for _, expense := range expenses {
+		report.Expenses = toFixed(report.Expenses+*expense.Amount, 2)
+	}
+
+	if report.Missed_tasks, err = taskCollection().CountDocuments(ctx, bson.M{"location": location, "task_date": report.Date, "status": "MISSED"}); err != nil {
+		return report, err
+	}
+
+	if report.Notes, err = managerNotesFor(ctx, location, report.Date); err != nil {
+		return report, err
+	}
+	return report, nil
+}
+
+func renderEndOfDayReport(report EndOfDayReport) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "End of day report for %s (%s)\n\n", report.Date, report.Location)
+	fmt.Fprintf(&b, "Orders: %d\nItems sold: %d\nSales: %.2f\nCash expenses: %.2f\nMissed tasks: %d\n", report.Orders, report.Items, report.Sales, report.Expenses, report.Missed_tasks)
+
+	if len(report.Drawers) > 0 {
+		b.WriteString("\nDrawers\n")
+		for _, drawer := range report.Drawers {
+			overShort := "not counted"
+			if drawer.Over_short != nil {
+				overShort = fmt.Sprintf("%+.2f", *drawer.Over_short)
+			}
+			fmt.Fprintf(&b, "- %s (%s): expected %.2f, over/short %s\n", drawer.Drawer_id, drawer.Employee_id, drawer.Expected_cash, overShort)
+		}
+	}
+
+	b.WriteString("\nManager log\n")
+	if len(report.Notes) == 0 {
+		b.WriteString("- No notes today\n")
+	}
+	for _, note := range report.Notes {
+		tags := ""
+		if len(note.Tags) > 0 {
+			tags = " [" + strings.Join(note.Tags, ", ") + "]"
+		}
+		fmt.Fprintf(&b, "- %s %s%s: %s\n", note.Created_at.Local().Format("15:04"), *note.Shift, tags, *note.Body)
+	}
+	return b.String()
+}
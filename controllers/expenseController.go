@@ -0,0 +1,347 @@
+package controllers
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"net/http"
+	"restaurant-management/apierror"
+	"restaurant-management/config"
+	"restaurant-management/helpers"
+	"restaurant-management/models"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var expenseCollection = db.Collection("expense")
+
+func GetExpenses() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		filter := bson.M{}
+		for _, key := range []string{"status", "category", "paid_from", "drawer_session_id"} {
+			if value := c.Query(key); value != "" {
+				filter[key] = value
+			}
+		}
+
+		opts := options.Find().SetSort(bson.D{{Key: "expense_date", Value: -1}})
+		result, err := expenseCollection().Find(ctx, filter, opts)
+		if err != nil {
+			apierror.Render(c, apierror.Internal("error occurred while listing expenses: "+err.Error()))
+			return
+		}
+
+		var allExpenses []bson.M
+		if err = result.All(ctx, &allExpenses); err != nil {
+			apierror.Render(c, apierror.Internal("error decoding expenses: "+err.Error()))
+			return
+		}
+
+		c.JSON(http.StatusOK, allExpenses)
+	}
+}
+
+func GetExpense() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		var expense models.Expense
+		if err := expenseCollection().FindOne(ctx, bson.M{"expense_id": c.Param("expense_id")}).Decode(&expense); err != nil {
+			apierror.Render(c, apierror.NotFound("Expense not found"))
+			return
+		}
+
+		c.JSON(http.StatusOK, expense)
+	}
+}
+
+// CreateExpense records a cash expense. Expenses paid from a drawer must name
+// an open drawer session so they come off that drawer's expected cash.
+func CreateExpense() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		var expense models.Expense
+		if err := c.BindJSON(&expense); err != nil {
+			apierror.Render(c, apierror.BadRequest("Invalid request data: "+err.Error()))
+			return
+		}
+		if err := validate.Struct(expense); err != nil {
+			apierror.Render(c, apierror.FromValidation(err))
+			return
+		}
+
+		if *expense.Paid_from == "DRAWER" {
+			var session models.DrawerSession
+			err := drawerSessionCollection().FindOne(ctx, bson.M{"drawer_session_id": expense.Drawer_session_id}).Decode(&session)
+			if err != nil {
+				apierror.Render(c, apierror.NotFound("Drawer session not found"))
+				return
+			}
+			if session.Status != "OPEN" {
+				apierror.Render(c, apierror.Conflict("Drawer session is closed"))
+				return
+			}
+		} else {
+			expense.Drawer_session_id = nil
+		}
+
+		amount := toFixed(*expense.Amount, 2)
+		expense.Amount = &amount
+		expense.Status = "PENDING"
+		expense.Approver_id = nil
+		expense.Approved_at = nil
+		expense.Receipt_url = nil
+		expense.Created_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		expense.Updated_at = expense.Created_at
+		if expense.Expense_date.IsZero() {
+			expense.Expense_date = expense.Created_at
+		}
+		expense.ID = primitive.NewObjectID()
+		expense.Expense_id = expense.ID.Hex()
+
+		result, err := expenseCollection().InsertOne(ctx, expense)
+		if err != nil {
+			apierror.Render(c, apierror.Internal("Expense was not created: "+err.Error()))
+			return
+		}
+
+		c.JSON(http.StatusCreated, gin.H{"message": "Expense created", "data": result, "expense_id": expense.Expense_id})
+	}
+}
+
+// UploadExpenseReceipt attaches a receipt photo or PDF (multipart field
+// "receipt").
+func UploadExpenseReceipt() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		expenseId := c.Param("expense_id")
+
+		count, err := expenseCollection().CountDocuments(ctx, bson.M{"expense_id": expenseId})
+		if err != nil || count == 0 {
+			apierror.Render(c, apierror.NotFound("Expense not found"))
+			return
+		}
+
+		receiptUrl, err := helpers.SaveUpload(c, "receipt", "receipts", expenseId, helpers.DocumentExtensions)
+		if err != nil {
+			apierror.Render(c, apierror.BadRequest(err.Error()))
+			return
+		}
+
+		updatedAt, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		_, err = expenseCollection().UpdateOne(
+			ctx,
+			bson.M{"expense_id": expenseId},
+			bson.D{{Key: "$set", Value: bson.D{{Key: "receipt_url", Value: receiptUrl}, {Key: "updated_at", Value: updatedAt}}}},
+		)
+		if err != nil {
+			apierror.Render(c, apierror.Internal("Update failed: "+err.Error()))
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Receipt uploaded", "receipt_url": receiptUrl})
+	}
+}
+
+// ReviewExpense approves or rejects a pending expense.
+func ReviewExpense() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		var body struct {
+			Status      *string `json:"status" validate:"required,eq=APPROVED|eq=REJECTED"`
+			Approver_id *string `json:"approver_id" validate:"required"`
+		}
+		if err := c.BindJSON(&body); err != nil {
+			apierror.Render(c, apierror.BadRequest("Invalid request data: "+err.Error()))
+			return
+		}
+		if err := validate.Struct(body); err != nil {
+			apierror.Render(c, apierror.FromValidation(err))
+			return
+		}
+
+		reviewedAt, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		result, err := expenseCollection().UpdateOne(
+			ctx,
+			bson.M{"expense_id": c.Param("expense_id"), "status": "PENDING"},
+			bson.D{{Key: "$set", Value: bson.D{
+				{Key: "status", Value: body.Status},
+				{Key: "approver_id", Value: body.Approver_id},
+				{Key: "approved_at", Value: reviewedAt},
+				{Key: "updated_at", Value: reviewedAt},
+			}}},
+		)
+		if err != nil {
+			apierror.Render(c, apierror.Internal("Update failed: "+err.Error()))
+			return
+		}
+		if result.MatchedCount == 0 {
+			apierror.Render(c, apierror.NotFound("No pending expense found"))
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Expense " + strings.ToLower(*body.Status)})
+	}
+}
+
+// GetDailyClose summarizes a day's cash expenses by category and source
+// alongside the drawers closed that day, so the close can be signed off in
+// one place.
+func GetDailyClose() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		day := time.Now().UTC().Truncate(24 * time.Hour)
+		if value := c.Query("date"); value != "" {
+			parsed, err := time.Parse("2006-01-02", value)
+			if err != nil {
+				apierror.Render(c, apierror.BadRequest("date must be formatted as YYYY-MM-DD"))
+				return
+			}
+			day = parsed
+		}
+		next := day.AddDate(0, 0, 1)
+
+		expenseCursor, err := expenseCollection().Aggregate(ctx, mongo.Pipeline{
+			{{Key: "$match", Value: bson.D{{Key: "expense_date", Value: bson.D{{Key: "$gte", Value: day}, {Key: "$lt", Value: next}}}}}},
+			{{Key: "$group", Value: bson.D{
+				{Key: "_id", Value: bson.D{{Key: "category", Value: "$category"}, {Key: "paid_from", Value: "$paid_from"}, {Key: "status", Value: "$status"}}},
+				{Key: "count", Value: bson.D{{Key: "$sum", Value: 1}}},
+				{Key: "total", Value: bson.D{{Key: "$sum", Value: "$amount"}}},
+			}}},
+			{{Key: "$sort", Value: bson.D{{Key: "_id.category", Value: 1}}}},
+		})
+		if err != nil {
+			apierror.Render(c, apierror.Internal("error occurred while aggregating expenses: "+err.Error()))
+			return
+		}
+		var expenses []bson.M
+		if err = expenseCursor.All(ctx, &expenses); err != nil {
+			apierror.Render(c, apierror.Internal("error decoding expenses: "+err.Error()))
+			return
+		}
+
+		sessionCursor, err := drawerSessionCollection().Find(ctx, bson.M{"status": "CLOSED", "closed_at": bson.M{"$gte": day, "$lt": next}})
+		if err != nil {
+			apierror.Render(c, apierror.Internal("error occurred while listing drawer sessions: "+err.Error()))
+			return
+		}
+		var sessions []models.DrawerSession
+		if err = sessionCursor.All(ctx, &sessions); err != nil {
+			apierror.Render(c, apierror.Internal("error decoding drawer sessions: "+err.Error()))
+			return
+		}
+		drawers := []models.DrawerReconciliation{}
+		for _, session := range sessions {
+			reconciliation, err := reconcileDrawer(ctx, session)
+			if err != nil {
+				apierror.Render(c, apierror.Internal("error reconciling drawer: "+err.Error()))
+				return
+			}
+			drawers = append(drawers, reconciliation)
+		}
+
+		pending, err := expenseCollection().CountDocuments(ctx, bson.M{"status": "PENDING", "expense_date": bson.M{"$gte": day, "$lt": next}})
+		if err != nil {
+			apierror.Render(c, apierror.Internal("error counting pending expenses: "+err.Error()))
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"date": day.Format("2006-01-02"), "expenses": expenses, "pending_approvals": pending, "drawers": drawers})
+	}
+}
+
+// ExportExpenses writes approved expenses in ?from=&to= as CSV for the
+// accounting package.
+func ExportExpenses() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		from, to, err := parseReportRange(c)
+		if err != nil {
+			apierror.Render(c, apierror.BadRequest(err.Error()))
+			return
+		}
+
+		opts := options.Find().SetSort(bson.D{{Key: "expense_date", Value: 1}})
+		cursor, err := expenseCollection().Find(ctx, bson.M{"status": "APPROVED", "expense_date": bson.M{"$gte": from, "$lt": to}}, opts)
+		if err != nil {
+			apierror.Render(c, apierror.Internal("error occurred while listing expenses: "+err.Error()))
+			return
+		}
+		var expenses []models.Expense
+		if err = cursor.All(ctx, &expenses); err != nil {
+			apierror.Render(c, apierror.Internal("error decoding expenses: "+err.Error()))
+			return
+		}
+
+		c.Header("Content-Type", "text/csv")
+		c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=expenses-%s-%s.csv", from.Format("20060102"), to.AddDate(0, 0, -1).Format("20060102")))
+
+		writer := csv.NewWriter(c.Writer)
+		writer.Write([]string{"expense_id", "date", "category", "description", "amount", "paid_from", "drawer_session_id", "submitted_by", "approver_id", "receipt_url"})
+		for _, expense := range expenses {
+			writer.Write([]string{
+				expense.Expense_id,
+				expense.Expense_date.Format("2006-01-02"),
+				*expense.Category,
+				*expense.Description,
+				strconv.FormatFloat(*expense.Amount, 'f', 2, 64),
+				*expense.Paid_from,
+				stringValue(expense.Drawer_session_id),
+				*expense.Submitted_by,
+				stringValue(expense.Approver_id),
+				stringValue(expense.Receipt_url),
+			})
+		}
+		writer.Flush()
+	}
+}
+
+// drawerExpenses totals the expenses paid out of a drawer session. Rejected
+// expenses still count: the cash has left the drawer either way.
+func drawerExpenses(ctx context.Context, drawerSessionId string) (float64, error) {
+	cursor, err := expenseCollection().Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$match", Value: bson.D{{Key: "drawer_session_id", Value: drawerSessionId}, {Key: "paid_from", Value: "DRAWER"}}}},
+		{{Key: "$group", Value: bson.D{{Key: "_id", Value: nil}, {Key: "total", Value: bson.D{{Key: "$sum", Value: "$amount"}}}}}},
+	})
+	if err != nil {
+		return 0, err
+	}
+	var totals []struct {
+		Total float64 `bson:"total"`
+	}
+	if err = cursor.All(ctx, &totals); err != nil {
+		return 0, err
+	}
+	if len(totals) == 0 {
+		return 0, nil
+	}
+	return toFixed(totals[0].Total, 2), nil
+}
+
+func stringValue(value *string) string {
+	if value == nil {
+		return ""
+	}
+	return *value
+}
@@ -2,28 +2,38 @@ package controllers
 
 import (
 	"context"
-	"fmt"
+	"log"
 	"math"
 	"net/http"
-	"restaurant-management/database"
+	"restaurant-management/apierror"
+	"restaurant-management/config"
 	"restaurant-management/models"
+	"restaurant-management/services"
+	"sort"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
-	"github.com/go-playground/validator/v10"
 	"go.mongodb.org/mongo-driver/bson"
-	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
-var foodCollection *mongo.Collection = database.OpenCollection(database.Client, "food")
-var validate = validator.New()
+var foodCollection = db.Collection("food")
+var validate = services.Validate
 
+// foodSortFields are the fields GetFoods can sort by with ?sort=.
+var foodSortFields = map[string]bool{"price": true, "name": true, "created_at": true}
+
+// GetFoods pages through the foods on sale, or all of them with
+// ?include_unavailable=true, optionally of one ?menu_id= or
+// ?category=, carrying every ?tag= given, free of every ?exclude_allergen=,
+// suiting every ?diet= and priced between ?min_price= and ?max_price=, sorted by ?sort=price|name|created_at (default created_at) in
+// ?order=asc|desc. Admins see deleted foods too with ?include_deleted=true.
 func GetFoods() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
 		defer cancel()
 
 		// Get recordsPerPage with default value
@@ -43,57 +53,249 @@ func GetFoods() gin.HandlerFunc {
 
 		// If startIndex is provided in the query params, override it
 		if queryStartIndex := c.Query("startIndex"); queryStartIndex != "" {
-			if parsedStartIndex, err := strconv.Atoi(queryStartIndex); err == nil {
+			if parsedStartIndex, err := strconv.Atoi(queryStartIndex); err == nil && parsedStartIndex >= 0 {
 				startIndex = parsedStartIndex
 			}
 		}
 
+		match := bson.D{}
+		if !includeDeleted(c) {
+			match = append(match, bson.E{Key: "deleted_at", Value: notDeleted})
+		}
+		if c.Query("include_unavailable") != "true" {
+			match = append(match, bson.E{Key: "available", Value: bson.M{"$ne": false}})
+		}
+		if menuId := c.Query("menu_id"); menuId != "" {
+			match = append(match, bson.E{Key: "menu_id", Value: menuId})
+		}
+		if categoryId := c.Query("category"); categoryId != "" {
+			match = append(match, bson.E{Key: "category_id", Value: categoryId})
+		}
+		if tags := services.NormalizeTags(c.QueryArray("tag")); len(tags) > 0 {
+			match = append(match, bson.E{Key: "tags", Value: bson.M{"$all": tags}})
+		}
+		if allergens := services.NormalizeTags(c.QueryArray("exclude_allergen")); len(allergens) > 0 {
+			match = append(match, bson.E{Key: "allergens", Value: bson.M{"$nin": allergens}})
+		}
+		if diets := services.NormalizeTags(c.QueryArray("diet")); len(diets) > 0 {
+			match = append(match, bson.E{Key: "dietary_flags", Value: bson.M{"$all": diets}})
+		}
+		priceRange := bson.D{}
+		for param, operator := range map[string]string{"min_price": "$gte", "max_price": "$lte"} {
+			if value := c.Query(param); value != "" {
+				price, err := strconv.ParseFloat(value, 64)
+				if err != nil {
+					apierror.Render(c, apierror.BadRequest(param+" must be a number"))
+					return
+				}
+				priceRange = append(priceRange, bson.E{Key: operator, Value: price})
+			}
+		}
+		if len(priceRange) > 0 {
+			match = append(match, bson.E{Key: "price", Value: priceRange})
+		}
+
 		// MongoDB Aggregation Pipeline
-		matchStage := bson.D{{Key: "$match", Value: bson.D{}}}
-		groupStage := bson.D{
-			{Key: "$group", Value: bson.D{
-				{Key: "_id", Value: nil},
-				{Key: "total_count", Value: bson.D{{Key: "$sum", Value: 1}}},
-				{Key: "data", Value: bson.D{{Key: "$push", Value: "$$ROOT"}}},
-			}},
+		pipeline := mongo.Pipeline{{{Key: "$match", Value: match}}}
+		sortField := c.DefaultQuery("sort", "created_at")
+		if !foodSortFields[sortField] {
+			apierror.Render(c, apierror.BadRequest("sort must be one of price, name or created_at"))
+			return
+		}
+		direction := 1
+		switch c.DefaultQuery("order", "asc") {
+		case "asc":
+		case "desc":
+			direction = -1
+		default:
+			apierror.Render(c, apierror.BadRequest("order must be asc or desc"))
+			return
 		}
-		projectStage := bson.D{
-			{Key: "$project", Value: bson.D{
-				{Key: "_id", Value: 0},
-				{Key: "total_count", Value: 1},
-				{Key: "food_items", Value: bson.D{{Key: "$slice", Value: []interface{}{"$data", startIndex, recordsPerPage}}}},
+		// food_id breaks ties so that pages do not overlap
+		pipeline = append(pipeline, bson.D{{Key: "$sort", Value: bson.D{{Key: sortField, Value: direction}, {Key: "food_id", Value: 1}}}})
+		// The count and the page come from one pass, without loading
+		// every matching food into a single document
+		facetStage := bson.D{
+			{Key: "$facet", Value: bson.D{
+				{Key: "metadata", Value: bson.A{bson.D{{Key: "$count", Value: "total_count"}}}},
+				{Key: "data", Value: bson.A{
+					bson.D{{Key: "$skip", Value: startIndex}},
+					bson.D{{Key: "$limit", Value: recordsPerPage}},
+				}},
 			}},
 		}
 
 		// Execute Aggregation
-		result, err := foodCollection.Aggregate(ctx, mongo.Pipeline{matchStage, groupStage, projectStage})
+		result, err := foodCollection().Aggregate(ctx, append(pipeline, facetStage))
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occurred while listing food items: " + err.Error()})
+			apierror.Render(c, apierror.Internal("error occurred while listing food items: "+err.Error()))
 			return
 		}
 
 		// Decode results
-		var allFoods []bson.M
-		if err = result.All(ctx, &allFoods); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "error decoding food items: " + err.Error()})
+		var facets []struct {
+			Metadata []struct {
+				Total_count int64 `bson:"total_count"`
+			} `bson:"metadata"`
+			Data []bson.M `bson:"data"`
+		}
+		if err = result.All(ctx, &facets); err != nil {
+			apierror.Render(c, apierror.Internal("error decoding food items: "+err.Error()))
+			return
+		}
+
+		var totalCount int64
+		allFoods := []bson.M{}
+		if len(facets) > 0 {
+			if len(facets[0].Metadata) > 0 {
+				totalCount = facets[0].Metadata[0].Total_count
+			}
+			if facets[0].Data != nil {
+				allFoods = facets[0].Data
+			}
+		}
+
+		c.JSON(http.StatusOK, foodPage(totalCount, page, recordsPerPage, allFoods))
+	}
+}
+
+// foodPage is the envelope GetFoods and SearchFoods return a page in.
+func foodPage(totalCount int64, page, recordsPerPage int, foods []bson.M) gin.H {
+	return gin.H{"total_count": totalCount, "page": page, "records_per_page": recordsPerPage, "data": foods}
+}
+
+// UpdateFoodAvailability 86es a food or puts it back on sale. A food taken
+// off with sold_out_until comes back at that time through
+// RestockSoldOutFoods.
+func UpdateFoodAvailability() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		var body struct {
+			Available      *bool      `json:"available" validate:"required"`
+			Sold_out_until *time.Time `json:"sold_out_until"`
+		}
+		if err := c.BindJSON(&body); err != nil {
+			apierror.Render(c, apierror.BadRequest("Invalid request data: "+err.Error()))
+			return
+		}
+		if err := validate.Struct(body); err != nil {
+			apierror.Render(c, apierror.FromValidation(err))
+			return
+		}
+		if body.Sold_out_until != nil {
+			if *body.Available {
+				apierror.Render(c, apierror.BadRequest("sold_out_until only applies when available is false"))
+				return
+			}
+			if !body.Sold_out_until.After(time.Now()) {
+				apierror.Render(c, apierror.BadRequest("sold_out_until must be in the future"))
+				return
+			}
+		}
+
+		updatedAt, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		result, err := foodCollection().UpdateOne(
+			ctx,
+			bson.M{"food_id": c.Param("food_id")},
+			bson.D{{Key: "$set", Value: bson.D{
+				{Key: "available", Value: body.Available},
+				{Key: "sold_out_until", Value: body.Sold_out_until},
+				{Key: "updated_at", Value: updatedAt},
+			}}},
+		)
+		if err != nil {
+			apierror.Render(c, apierror.Internal("Update failed: "+err.Error()))
+			return
+		}
+		if result.MatchedCount == 0 {
+			apierror.Render(c, apierror.NotFound("Food item not found"))
 			return
 		}
 
-		// Check if there are any results
-		if len(allFoods) == 0 {
-			c.JSON(http.StatusOK, gin.H{"message": "No food items found"})
+		var food models.Food
+		if err := foodCollection().FindOne(ctx, bson.M{"food_id": c.Param("food_id")}).Decode(&food); err == nil {
+			if err := queueWebhookEvent(ctx, "food.updated", food); err != nil {
+				log.Println("Error queueing webhooks:", err)
+			}
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Food availability updated", "available": body.Available, "sold_out_until": body.Sold_out_until})
+	}
+}
+
+// RestockSoldOutFoods puts back on sale the foods whose sold_out_until has
+// passed.
+func RestockSoldOutFoods(ctx context.Context) error {
+	now, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+	_, err := foodCollection().UpdateMany(
+		ctx,
+		bson.M{"available": false, "sold_out_until": bson.M{"$lte": now}},
+		bson.D{{Key: "$set", Value: bson.D{
+			{Key: "available", Value: true},
+			{Key: "sold_out_until", Value: nil},
+			{Key: "updated_at", Value: now},
+		}}},
+	)
+	return err
+}
+
+// SearchFoods finds foods whose name or description match ?q=, best
+// matches first, paginated like GetFoods. It relies on the text index made
+// by EnsureFoodIndexes.
+func SearchFoods() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		query := strings.TrimSpace(c.Query("q"))
+		if query == "" {
+			apierror.Render(c, apierror.BadRequest("q is required"))
 			return
 		}
 
-		// Return the first result
-		c.JSON(http.StatusOK, allFoods[0])
+		recordsPerPage, err := strconv.Atoi(c.Query("recordsPerPage"))
+		if err != nil || recordsPerPage < 1 {
+			recordsPerPage = 10
+		}
+		page, err := strconv.Atoi(c.Query("page"))
+		if err != nil || page < 1 {
+			page = 1
+		}
+
+		filter := liveFilter(c, bson.M{"$text": bson.M{"$search": query}})
+		score := bson.M{"$meta": "textScore"}
+		opts := options.Find().
+			SetProjection(bson.M{"score": score}).
+			SetSort(bson.D{{Key: "score", Value: score}, {Key: "name", Value: 1}}).
+			SetSkip(int64((page - 1) * recordsPerPage)).
+			SetLimit(int64(recordsPerPage))
+		result, err := foodCollection().Find(ctx, filter, opts)
+		if err != nil {
+			apierror.Render(c, apierror.Internal("error occurred while searching food items: "+err.Error()))
+			return
+		}
+
+		allFoods := []bson.M{}
+		if err = result.All(ctx, &allFoods); err != nil {
+			apierror.Render(c, apierror.Internal("error decoding food items: "+err.Error()))
+			return
+		}
+		totalCount, err := foodCollection().CountDocuments(ctx, filter)
+		if err != nil {
+			apierror.Render(c, apierror.Internal("error counting food items: "+err.Error()))
+			return
+		}
+
+		c.JSON(http.StatusOK, foodPage(totalCount, page, recordsPerPage, allFoods))
 	}
 }
 
 func GetFood() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		// Create a context with a timeout of 100 seconds
-		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
 		defer cancel() // Ensures context is canceled after function execution
 
 		// Extract the "food_id" parameter from the request URL
@@ -103,10 +305,10 @@ func GetFood() gin.HandlerFunc {
 		var food models.Food
 
 		//Query the MongoDB collection to find the food item by its ID
-		err := foodCollection.FindOne(ctx, bson.M{"food_id": foodId}).Decode(&food)
+		err := foodCollection().FindOne(ctx, liveFilter(c, bson.M{"food_id": foodId})).Decode(&food)
 		if err != nil {
 			//Handle the error if the food item is not found
-			c.JSON(http.StatusNotFound, gin.H{"error": "Food item not found"})
+			apierror.Render(c, apierror.NotFound("Food item not found"))
 			return
 		}
 
@@ -117,49 +319,24 @@ func GetFood() gin.HandlerFunc {
 
 func CreateFood() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
 		defer cancel() // Ensures cleanup after function execution
 
 		var food models.Food
-		var menu models.Menu
-
 		// Bind JSON request body to food struct
 		if err := c.BindJSON(&food); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data: " + err.Error()})
+			apierror.Render(c, apierror.BadRequest("Invalid request data: "+err.Error()))
 			return
 		}
 
-		// Validate struct fields
-		if err := validate.Struct(food); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Validation failed: " + err.Error()})
-			return
-		}
-
-		// Check if the associated menu exists
-		err := menuCollection.FindOne(ctx, bson.M{"menu_id": food.Menu_id}).Decode(&menu)
+		result, err := services.CreateFood(ctx, &food)
 		if err != nil {
-			c.JSON(http.StatusNotFound, gin.H{"error": "Menu not found"})
+			apierror.Render(c, serviceError(err))
 			return
 		}
-
-		// Assign metadata to the food item
-		now := time.Now().Format(time.RFC3339)
-		food.Created_at, _ = time.Parse(time.RFC3339, now)
-		food.Updated_at, _ = time.Parse(time.RFC3339, now)
-		food.ID = primitive.NewObjectID()
-		food.Food_id = food.ID.Hex()
-
-		// Ensure price is rounded to 2 decimal places
-		if food.Price != nil {
-			roundedPrice := toFixed(*food.Price, 2)
-			food.Price = &roundedPrice
-		}
-
-		// Insert food item into MongoDB
-		result, insertErr := foodCollection.InsertOne(ctx, food)
-		if insertErr != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not create food item"})
-			return
+		auditCreated(c, food.Food_id)
+		if err := queueWebhookEvent(ctx, "food.created", food); err != nil {
+			log.Println("Error queueing webhooks:", err)
 		}
 
 		// Return success response
@@ -167,74 +344,241 @@ func CreateFood() gin.HandlerFunc {
 	}
 }
 
-func round(num float64) int {
-	return int(num + math.Copysign(0.5, num))
-}
-
+// toFixed rounds amounts the way the services round prices.
 func toFixed(num float64, precision int) float64 {
-	output := math.Pow(10, float64(precision))
-	return float64(round(num*output)) / output
+	return services.ToFixed(num, precision)
 }
 
 func UpdateFood() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
 		defer cancel()
-		var menu models.Menu
 		var food models.Food
 		foodId := c.Param("food_id")
 
 		if err := c.BindJSON(&food); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data: " + err.Error()})
+			apierror.Render(c, apierror.BadRequest("Invalid request data: "+err.Error()))
 			return
 		}
 
-		var updateObj primitive.D
-		if food.Name != nil {
-			updateObj = append(updateObj, bson.E{Key: "name", Value: food.Name})
+		result, err := services.UpdateFood(ctx, foodId, food)
+		if err != nil {
+			apierror.Render(c, serviceError(err))
+			return
+		}
+		var updatedFood models.Food
+		if err := foodCollection().FindOne(ctx, bson.M{"food_id": foodId}).Decode(&updatedFood); err == nil {
+			if err := queueWebhookEvent(ctx, "food.updated", updatedFood); err != nil {
+				log.Println("Error queueing webhooks:", err)
+			}
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Food item updated successfully", "result": result})
+
+	}
+}
+
+// similarPriceRange is how far, as a fraction of its price, an alternative
+// may be priced from an 86ed food.
+const similarPriceRange = 0.3
 
+type FoodAlternative struct {
+	Food_id          string  `json:"food_id"`
+	Name             string  `json:"name"`
+	Price            float64 `json:"price"`
+	Price_difference float64 `json:"price_difference"`
+}
+
+type UnavailableFood struct {
+	Food_id        string            `json:"food_id"`
+	Name           string            `json:"name"`
+	Sold_out_until *time.Time        `json:"sold_out_until"`
+	Alternatives   []FoodAlternative `json:"alternatives"`
+}
+
+// GetFoodAlternatives suggests replacements for a food at ?location=, for the
+// POS to offer when it is 86ed.
+func GetFoodAlternatives() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		var food models.Food
+		if err := foodCollection().FindOne(ctx, bson.M{"food_id": c.Param("food_id")}).Decode(&food); err != nil {
+			apierror.Render(c, apierror.NotFound("Food item not found"))
+			return
 		}
 
-		if food.Price != nil {
-			updateObj = append(updateObj, bson.E{Key: "price", Value: food.Price})
+		alternatives, err := foodAlternatives(ctx, food, c.DefaultQuery("location", defaultLocation), 3)
+		if err != nil {
+			apierror.Render(c, apierror.Internal("error occurred while finding alternatives: "+err.Error()))
+			return
 		}
 
-		if food.Food_image != nil {
-			updateObj = append(updateObj, bson.E{Key: "food_image", Value: food.Food_image})
+		c.JSON(http.StatusOK, alternatives)
+	}
+}
+
+// unavailableFoods lists the 86ed foods among the order items, each with up
+// to three alternatives.
+func unavailableFoods(ctx context.Context, orderItems []models.OrderItem, foods map[string]models.Food, location string) ([]UnavailableFood, error) {
+	unavailable := []UnavailableFood{}
+	seen := map[string]bool{}
+	for _, orderItem := range orderItems {
+		food, ok := foods[stringValue(orderItem.Food_id)]
+		if !ok || seen[food.Food_id] || food.Available == nil || *food.Available {
+			continue
 		}
+		seen[food.Food_id] = true
 
-		if food.Menu_id != nil {
-			err := menuCollection.FindOne(ctx, bson.M{"menu_id": food.Menu_id}).Decode(&menu)
-			if err != nil {
-				msg := fmt.Sprintf("message : Menu not found")
-				c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
-				return
-			}
-			updateObj = append(updateObj, bson.E{Key: "menu_id", Value: food.Menu_id})
+		alternatives, err := foodAlternatives(ctx, food, location, 3)
+		if err != nil {
+			return nil, err
+		}
+		unavailable = append(unavailable, UnavailableFood{Food_id: food.Food_id, Name: stringValue(food.Name), Sold_out_until: food.Sold_out_until, Alternatives: alternatives})
+	}
+	return unavailable, nil
+}
 
+// foodAlternatives finds available foods of the same category priced within
+// similarPriceRange of food, closest in price first. Foods whose recipe needs
+// an ingredient that ran out at the location are left out.
+func foodAlternatives(ctx context.Context, food models.Food, location string, limit int) ([]FoodAlternative, error) {
+	menuIds := []string{stringValue(food.Menu_id)}
+	var menu models.Menu
+	if err := menuCollection().FindOne(ctx, bson.M{"menu_id": food.Menu_id}).Decode(&menu); err == nil && menu.Category != "" {
+		ids, err := menuIdsInCategory(ctx, menu.Category)
+		if err != nil {
+			return nil, err
 		}
+		menuIds = ids
+	}
+
+	price := 0.0
+	if food.Price != nil {
+		price = *food.Price
+	}
+	filter := bson.M{
+		"menu_id":    bson.M{"$in": menuIds},
+		"food_id":    bson.M{"$ne": food.Food_id},
+		"available":  bson.M{"$ne": false},
+		"deleted_at": notDeleted,
+		"price":      bson.M{"$gte": price * (1 - similarPriceRange), "$lte": price * (1 + similarPriceRange)},
+	}
+	cursor, err := foodCollection().Find(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	var candidates []models.Food
+	if err = cursor.All(ctx, &candidates); err != nil {
+		return nil, err
+	}
 
-		food.Updated_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
-		updateObj = append(updateObj, bson.E{Key: "updated_at", Value: food.Updated_at})
+	outOfStock, err := outOfStockFoods(ctx, candidates, location)
+	if err != nil {
+		return nil, err
+	}
 
-		upsert := true
-		filter := bson.M{"food_id": foodId}
+	alternatives := []FoodAlternative{}
+	for _, candidate := range candidates {
+		if outOfStock[candidate.Food_id] || candidate.Price == nil {
+			continue
+		}
+		alternatives = append(alternatives, FoodAlternative{
+			Food_id:          candidate.Food_id,
+			Name:             stringValue(candidate.Name),
+			Price:            *candidate.Price,
+			Price_difference: toFixed(*candidate.Price-price, 2),
+		})
+	}
+	sort.Slice(alternatives, func(i, j int) bool {
+		a, b := math.Abs(alternatives[i].Price_difference), math.Abs(alternatives[j].Price_difference)
+		if a != b {
+			return a < b
+		}
+		return alternatives[i].Name < alternatives[j].Name
+	})
+	if len(alternatives) > limit {
+		alternatives = alternatives[:limit]
+	}
+	return alternatives, nil
+}
 
-		opt := options.UpdateOptions{Upsert: &upsert}
+// outOfStockFoods reports the foods with a recipe ingredient whose counted
+// stock at the location is used up.
+func outOfStockFoods(ctx context.Context, foods []models.Food, location string) (map[string]bool, error) {
+	foodIds := make([]string, 0, len(foods))
+	for _, food := range foods {
+		foodIds = append(foodIds, food.Food_id)
+	}
+	outOfStock := map[string]bool{}
+	cursor, err := recipeCollection().Find(ctx, bson.M{"food_id": bson.M{"$in": foodIds}})
+	if err != nil {
+		return nil, err
+	}
+	var recipes []models.Recipe
+	if err = cursor.All(ctx, &recipes); err != nil {
+		return nil, err
+	}
+	if len(recipes) == 0 {
+		return outOfStock, nil
+	}
 
-		result, err := foodCollection.UpdateOne(
-			ctx,
-			filter,
-			bson.D{{Key: "$set", Value: updateObj}},
-			&opt,
-		)
+	cursor, err = inventoryCollection().Find(ctx, bson.M{"location": location, "on_hand": bson.M{"$lte": 0}}, options.Find().SetProjection(bson.M{"sku": 1}))
+	if err != nil {
+		return nil, err
+	}
+	var empty []models.InventoryItem
+	if err = cursor.All(ctx, &empty); err != nil {
+		return nil, err
+	}
+	emptySkus := map[string]bool{}
+	for _, item := range empty {
+		emptySkus[item.Sku] = true
+	}
+	for _, recipe := range recipes {
+		for _, ingredient := range recipe.Ingredients {
+			if emptySkus[*ingredient.Sku] {
+				outOfStock[recipe.Food_id] = true
+			}
+		}
+	}
+	return outOfStock, nil
+}
 
+// DeleteFood soft deletes a food, taking it off every menu. Past orders of
+// it keep their items. Active combos that include the food block the
+// delete, unless ?cascade=true, which deactivates them.
+func DeleteFood() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		foodId := c.Param("food_id")
+		combos, err := idsOf(ctx, comboCollection(), activeCombosWith([]string{foodId}), "combo_id")
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Update failed: " + err.Error()})
+			apierror.Render(c, apierror.Internal("error occurred while checking the food's combos"))
+			return
+		}
+		if len(combos) > 0 && c.Query("cascade") != "true" {
+			apierror.Render(c, apierror.Conflict("The food is part of active combos; deactivate them or pass cascade=true").With("combo_ids", combos))
 			return
 		}
 
-		c.JSON(http.StatusOK, gin.H{"message": "Food item updated successfully", "result": result})
+		found, err := softDelete(ctx, foodCollection(), "food_id", foodId)
+		if err != nil {
+			apierror.Render(c, apierror.Internal("Delete failed: "+err.Error()))
+			return
+		}
+		if !found {
+			apierror.Render(c, apierror.NotFound("Food item not found"))
+			return
+		}
+		if err := deactivateCombos(ctx, combos); err != nil {
+			apierror.Render(c, apierror.Internal("error occurred while deactivating combos: "+err.Error()))
+			return
+		}
 
+		c.JSON(http.StatusOK, gin.H{"message": "Food deleted", "deactivated_combo_ids": combos})
 	}
 }
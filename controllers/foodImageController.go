@@ -0,0 +1,271 @@
+package controllers
+
+import (
+	"context"
+	"io"
+	"log"
+	"net/http"
+	"restaurant-management/apierror"
+	"restaurant-management/config"
+	"restaurant-management/helpers"
+	"restaurant-management/imaging"
+	"restaurant-management/models"
+	"restaurant-management/storage"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var (
+	imageStoreOnce  sync.Once
+	imageStoreValue storage.Store
+)
+
+// imageStore is where food photos are kept, chosen from the environment
+// on first use.
+func imageStore() storage.Store {
+	imageStoreOnce.Do(func() {
+		imageStoreValue = storage.FromEnv(db.Client().Database("restaurant"))
+	})
+	return imageStoreValue
+}
+
+// imageTypes are the image formats accepted for food photos, by the content
+// type sniffed from the upload, with the extension they are stored under.
+var imageTypes = map[string]string{"image/jpeg": ".jpg", "image/png": ".png", "image/webp": ".webp"}
+
+// UploadFoodImage stores the photo in the multipart field "image" and makes
+// it the food's image. The type is taken from the file's content, not its
+// name. Its image_variants are cleared and filled in once the thumbnail
+// workers have made them.
+func UploadFoodImage() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		foodId := c.Param("food_id")
+		if err := foodCollection().FindOne(ctx, bson.M{"food_id": foodId}).Err(); err != nil {
+			apierror.Render(c, apierror.NotFound("Food item not found"))
+			return
+		}
+
+		file, err := c.FormFile("image")
+		if err != nil {
+			apierror.Render(c, apierror.BadRequest("image file is required"))
+			return
+		}
+		if file.Size > helpers.MaxUploadSize {
+			apierror.Render(c, apierror.PayloadTooLarge("image must be 10MB or smaller"))
+			return
+		}
+		reader, err := file.Open()
+		if err != nil {
+			apierror.Render(c, apierror.BadRequest("error opening upload: "+err.Error()))
+			return
+		}
+		defer reader.Close()
+		body, err := io.ReadAll(io.LimitReader(reader, helpers.MaxUploadSize+1))
+		if err != nil {
+			apierror.Render(c, apierror.BadRequest("error reading upload: "+err.Error()))
+			return
+		}
+		if len(body) > helpers.MaxUploadSize {
+			apierror.Render(c, apierror.PayloadTooLarge("image must be 10MB or smaller"))
+			return
+		}
+		contentType := http.DetectContentType(body)
+		ext, ok := imageTypes[contentType]
+		if !ok {
+			apierror.Render(c, apierror.UnsupportedMediaType("image must be a JPEG, PNG or WebP file"))
+			return
+		}
+
+		// Every upload gets its own key, so caches never serve an old photo
+		name := "foods/" + foodId + "-" + primitive.NewObjectID().Hex()
+		key := name + ext
+		url, err := imageStore().Put(ctx, key, contentType, body)
+		if err != nil {
+			apierror.Render(c, apierror.UpstreamFailed("Could not store image: "+err.Error()))
+			return
+		}
+
+		updatedAt, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		_, err = foodCollection().UpdateOne(
+			ctx,
+			bson.M{"food_id": foodId},
+			bson.D{{Key: "$set", Value: bson.D{
+				{Key: "food_image", Value: url},
+				{Key: "image_variants", Value: nil},
+				{Key: "updated_at", Value: updatedAt},
+			}}},
+		)
+		if err != nil {
+			apierror.Render(c, apierror.Internal("Update failed: "+err.Error()))
+			return
+		}
+
+		var food models.Food
+		if err := foodCollection().FindOne(ctx, bson.M{"food_id": foodId}).Decode(&food); err == nil {
+			if err := queueWebhookEvent(ctx, "food.updated", food); err != nil {
+				log.Println("Error queueing webhooks:", err)
+			}
+		}
+
+		queueThumbnails(thumbnailJob{foodId: foodId, name: name, image: url, body: body})
+
+		c.JSON(http.StatusOK, gin.H{"message": "Food image uploaded", "food_image": url})
+	}
+}
+
+// GetImage serves an image kept in GridFS. Images stored in S3 are served
+// by S3 itself.
+func GetImage() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		store, ok := imageStore().(*storage.GridFS)
+		if !ok {
+			apierror.Render(c, apierror.NotFound("Image not found"))
+			return
+		}
+		image, contentType, err := store.Open(ctx, strings.TrimPrefix(c.Param("key"), "/"))
+		if err == storage.ErrNotFound {
+			apierror.Render(c, apierror.NotFound("Image not found"))
+			return
+		}
+		if err != nil {
+			apierror.Render(c, apierror.Internal("error occurred while loading image: "+err.Error()))
+			return
+		}
+		defer image.Close()
+
+		// Keys are never reused, so images can be cached for good
+		c.Header("Cache-Control", "public, max-age=31536000, immutable")
+		c.DataFromReader(http.StatusOK, -1, contentType, image, nil)
+	}
+}
+
+// thumbnailSizes are the longest side, in pixels, of each image variant.
+var thumbnailSizes = []struct {
+	variant string
+	size    int
+}{{"thumb", 160}, {"medium", 480}, {"full", 1280}}
+
+// thumbnailQueue holds the uploads waiting for their variants.
+const thumbnailQueue = 32
+
+var (
+	thumbnailJobs    = make(chan thumbnailJob, thumbnailQueue)
+	thumbnailWorkers sync.WaitGroup
+	// thumbnailWorkersLive counts the running workers and
+	// thumbnailWorkersStarted those started, for readiness checks
+	thumbnailWorkersLive    atomic.Int32
+	thumbnailWorkersStarted atomic.Int32
+)
+
+// thumbnailJob is an uploaded food photo to make variants of. Variants are
+// stored as name plus the variant, and only recorded while image is still
+// the food's photo.
+type thumbnailJob struct {
+	foodId string
+	name   string
+	image  string
+	body   []byte
+}
+
+// StartThumbnailWorkers starts workers that make the variants of uploaded
+// food photos, until ctx is cancelled. A worker finishes the photo it is
+// on before stopping; queued photos are dropped.
+func StartThumbnailWorkers(ctx context.Context, workers int) {
+	thumbnailWorkersStarted.Add(int32(workers))
+	for i := 0; i < workers; i++ {
+		thumbnailWorkers.Add(1)
+		thumbnailWorkersLive.Add(1)
+		go func() {
+			defer thumbnailWorkers.Done()
+			defer thumbnailWorkersLive.Add(-1)
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case job := <-thumbnailJobs:
+					if err := makeThumbnails(job); err != nil {
+						log.Printf("Error making thumbnails of food %s: %v", job.foodId, err)
+					}
+				}
+			}
+		}()
+	}
+}
+
+// WaitThumbnailWorkers blocks until the workers have stopped, or until
+// ctx is done.
+func WaitThumbnailWorkers(ctx context.Context) error {
+	stopped := make(chan struct{})
+	go func() {
+		thumbnailWorkers.Wait()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// queueThumbnails hands an upload to the workers. When they are too far
+// behind the photo is served without variants, and uploading it again
+// retries.
+func queueThumbnails(job thumbnailJob) {
+	select {
+	case thumbnailJobs <- job:
+	default:
+		log.Printf("Thumbnail queue is full, food %s has no image variants", job.foodId)
+	}
+}
+
+func makeThumbnails(job thumbnailJob) error {
+	ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+	defer cancel()
+
+	// WebP cannot be decoded without extra codecs; such photos keep only
+	// the original
+	img, err := imaging.Decode(job.body)
+	if err != nil {
+		return err
+	}
+
+	variants := models.ImageVariants{}
+	for _, size := range thumbnailSizes {
+		data, err := imaging.JPEG(img, size.size)
+		if err != nil {
+			return err
+		}
+		url, err := imageStore().Put(ctx, job.name+"-"+size.variant+".jpg", "image/jpeg", data)
+		if err != nil {
+			return err
+		}
+		switch size.variant {
+		case "thumb":
+			variants.Thumb = url
+		case "medium":
+			variants.Medium = url
+		case "full":
+			variants.Full = url
+		}
+	}
+
+	_, err = foodCollection().UpdateOne(
+		ctx,
+		bson.M{"food_id": job.foodId, "food_image": job.image},
+		bson.D{{Key: "$set", Value: bson.D{{Key: "image_variants", Value: variants}}}},
+	)
+	return err
+}
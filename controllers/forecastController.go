@@ -0,0 +1,231 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"math"
+	"net/http"
+	"restaurant-management/apierror"
+	"restaurant-management/config"
+	"restaurant-management/helpers"
+	"restaurant-management/models"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+type FoodForecast struct {
+	Food_id        string  `json:"food_id"`
+	Name           string  `json:"name"`
+	Menu_id        string  `json:"menu_id"`
+	Expected_units float64 `json:"expected_units"`
+}
+
+type PrepListItem struct {
+	Food_id       string `json:"food_id"`
+	Name          string `json:"name"`
+	Menu_id       string `json:"menu_id"`
+	Units_to_prep int    `json:"units_to_prep"`
+}
+
+// GetForecast predicts the units sold per food for a day (tomorrow by default),
+// optionally narrowed to a single daypart.
+func GetForecast() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		target, daypart, forecaster, err := parseForecastQuery(c)
+		if err != nil {
+			apierror.Render(c, apierror.BadRequest(err.Error()))
+			return
+		}
+
+		forecasts, err := forecastDemand(ctx, target, daypart, forecaster)
+		if err != nil {
+			apierror.Render(c, apierror.Internal("error occurred while forecasting demand: "+err.Error()))
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"date":      target.Format("2006-01-02"),
+			"daypart":   daypart,
+			"model":     c.DefaultQuery("model", "moving_average"),
+			"forecasts": forecasts,
+		})
+	}
+}
+
+// GetPrepList turns the demand forecast into whole units the kitchen should prep.
+func GetPrepList() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		target, daypart, forecaster, err := parseForecastQuery(c)
+		if err != nil {
+			apierror.Render(c, apierror.BadRequest(err.Error()))
+			return
+		}
+
+		forecasts, err := forecastDemand(ctx, target, daypart, forecaster)
+		if err != nil {
+			apierror.Render(c, apierror.Internal("error occurred while forecasting demand: "+err.Error()))
+			return
+		}
+
+		prepList := []PrepListItem{}
+		for _, forecast := range forecasts {
+			units := int(math.Ceil(forecast.Expected_units))
+			if units == 0 {
+				continue
+			}
+			prepList = append(prepList, PrepListItem{
+				Food_id:       forecast.Food_id,
+				Name:          forecast.Name,
+				Menu_id:       forecast.Menu_id,
+				Units_to_prep: units,
+			})
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"date":      target.Format("2006-01-02"),
+			"daypart":   daypart,
+			"prep_list": prepList,
+		})
+	}
+}
+
+func parseForecastQuery(c *gin.Context) (time.Time, string, helpers.Forecaster, error) {
+	target := time.Now().UTC().AddDate(0, 0, 1)
+	if date := c.Query("date"); date != "" {
+		parsed, err := time.Parse("2006-01-02", date)
+		if err != nil {
+			return time.Time{}, "", nil, err
+		}
+		target = parsed
+	}
+	target = time.Date(target.Year(), target.Month(), target.Day(), 0, 0, 0, 0, time.UTC)
+
+	daypart := c.Query("daypart")
+	if _, ok := helpers.Dayparts[daypart]; daypart != "" && !ok {
+		return time.Time{}, "", nil, errInvalidDaypart
+	}
+
+	model := c.DefaultQuery("model", "moving_average")
+	defaultWindow := "7"
+	if model == "seasonal" {
+		defaultWindow = "4"
+	}
+	window, err := strconv.Atoi(c.DefaultQuery("window", defaultWindow))
+	if err != nil {
+		return time.Time{}, "", nil, err
+	}
+
+	forecaster, err := helpers.NewForecaster(model, window)
+	if err != nil {
+		return time.Time{}, "", nil, err
+	}
+	return target, daypart, forecaster, nil
+}
+
+var errInvalidDaypart = errors.New("daypart must be one of BREAKFAST, LUNCH, DINNER, LATE")
+
+// forecastDemand loads the daily order item counts per food over the
+// forecaster's lookback window and runs the model for every food seen.
+func forecastDemand(ctx context.Context, target time.Time, daypart string, forecaster helpers.Forecaster) ([]FoodForecast, error) {
+	start := target.AddDate(0, 0, -forecaster.Lookback())
+
+	matchStage := bson.D{{Key: "$match", Value: bson.D{
+		{Key: "created_at", Value: bson.D{{Key: "$gte", Value: start}, {Key: "$lt", Value: target}}},
+	}}}
+	groupStage := bson.D{{Key: "$group", Value: bson.D{
+		{Key: "_id", Value: bson.D{
+			{Key: "food_id", Value: "$food_id"},
+			{Key: "day", Value: bson.D{{Key: "$dateToString", Value: bson.D{{Key: "format", Value: "%Y-%m-%d"}, {Key: "date", Value: "$created_at"}}}}},
+			{Key: "hour", Value: bson.D{{Key: "$hour", Value: "$created_at"}}},
+		}},
+		{Key: "units", Value: bson.D{{Key: "$sum", Value: 1}}},
+	}}}
+
+	cursor, err := orderItemCollection().Aggregate(ctx, mongo.Pipeline{matchStage, groupStage})
+	if err != nil {
+		return nil, err
+	}
+
+	var rows []struct {
+		ID struct {
+			Food_id string `bson:"food_id"`
+			Day     string `bson:"day"`
+			Hour    int    `bson:"hour"`
+		} `bson:"_id"`
+		Units float64 `bson:"units"`
+	}
+	if err = cursor.All(ctx, &rows); err != nil {
+		return nil, err
+	}
+
+	history := map[string][]helpers.SalesPoint{}
+	for _, row := range rows {
+		day, err := time.Parse("2006-01-02", row.ID.Day)
+		if err != nil {
+			continue
+		}
+		if daypart != "" && helpers.DaypartOf(day.Add(time.Duration(row.ID.Hour)*time.Hour)) != daypart {
+			continue
+		}
+		history[row.ID.Food_id] = append(history[row.ID.Food_id], helpers.SalesPoint{Date: day, Units: row.Units})
+	}
+
+	foodIds := make([]string, 0, len(history))
+	for foodId := range history {
+		foodIds = append(foodIds, foodId)
+	}
+	foods, err := foodsById(ctx, foodIds)
+	if err != nil {
+		return nil, err
+	}
+
+	forecasts := []FoodForecast{}
+	for foodId, points := range history {
+		forecast := FoodForecast{
+			Food_id:        foodId,
+			Expected_units: toFixed(forecaster.Forecast(points, target), 2),
+		}
+		if food, ok := foods[foodId]; ok {
+			if food.Name != nil {
+				forecast.Name = *food.Name
+			}
+			if food.Menu_id != nil {
+				forecast.Menu_id = *food.Menu_id
+			}
+		}
+		forecasts = append(forecasts, forecast)
+	}
+	sort.Slice(forecasts, func(i, j int) bool {
+		return forecasts[i].Expected_units > forecasts[j].Expected_units
+	})
+	return forecasts, nil
+}
+
+// foodsById loads the given foods keyed by food_id.
+func foodsById(ctx context.Context, foodIds []string) (map[string]models.Food, error) {
+	cursor, err := foodCollection().Find(ctx, bson.M{"food_id": bson.M{"$in": foodIds}})
+	if err != nil {
+		return nil, err
+	}
+	var foods []models.Food
+	if err = cursor.All(ctx, &foods); err != nil {
+		return nil, err
+	}
+
+	byId := make(map[string]models.Food, len(foods))
+	for _, food := range foods {
+		byId[food.Food_id] = food
+	}
+	return byId, nil
+}
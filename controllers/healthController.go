@@ -0,0 +1,80 @@
+package controllers
+
+import (
+	"context"
+	"net/http"
+	"restaurant-management/scheduler"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// readinessTimeout bounds the MongoDB ping, so a hung database fails the
+// check instead of hanging the probe.
+const readinessTimeout = 2 * time.Second
+
+// DependencyCheck is the state of one thing the server needs to serve
+// traffic. Latency_ms is how long checking it took.
+type DependencyCheck struct {
+	Status     string  `json:"status"`
+	Latency_ms float64 `json:"latency_ms,omitempty"`
+	Running    *int    `json:"running,omitempty"`
+	Expected   *int    `json:"expected,omitempty"`
+	Error      string  `json:"error,omitempty"`
+}
+
+// Healthz answers as long as the process is serving requests, for liveness
+// probes. It checks nothing else, so a database outage does not get the
+// process restarted.
+func Healthz() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"status": "ok"})
+	}
+}
+
+// Readyz reports whether the server can take traffic: MongoDB answers a
+// ping and the background jobs and thumbnail workers are running. It
+// responds 503 when anything is down, with the state of each dependency.
+func Readyz() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(c.Request.Context(), readinessTimeout)
+		defer cancel()
+
+		checks := map[string]DependencyCheck{}
+		ready := true
+
+		started := time.Now()
+		mongoCheck := DependencyCheck{Status: "up"}
+		if err := db.Client().Ping(ctx, nil); err != nil {
+			mongoCheck.Status = "down"
+			mongoCheck.Error = err.Error()
+		}
+		mongoCheck.Latency_ms = float64(time.Since(started).Microseconds()) / 1000
+		checks["mongodb"] = mongoCheck
+
+		running, registered := scheduler.Status()
+		checks["scheduler"] = workerCheck(running, registered)
+
+		thumbnails := int(thumbnailWorkersLive.Load())
+		checks["thumbnail_workers"] = workerCheck(thumbnails, int(thumbnailWorkersStarted.Load()))
+
+		for _, check := range checks {
+			if check.Status != "up" {
+				ready = false
+			}
+		}
+		if !ready {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"status": "not_ready", "checks": checks})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"status": "ready", "checks": checks})
+	}
+}
+
+func workerCheck(running, expected int) DependencyCheck {
+	check := DependencyCheck{Status: "up", Running: &running, Expected: &expected}
+	if running < expected || expected == 0 {
+		check.Status = "down"
+	}
+	return check
+}
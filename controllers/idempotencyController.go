@@ -0,0 +1,197 @@
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"log"
+	"net/http"
+	"restaurant-management/apierror"
+	"restaurant-management/config"
+	"restaurant-management/models"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var idempotencyCollection = db.Collection("idempotencyRecord")
+
+// idempotencyTTL is how long a response is kept for replays.
+const idempotencyTTL = 24 * time.Hour
+
+// idempotencyLease is how long past the request timeout a key stays
+// IN_PROGRESS. A request that never finished, e.g. because the server
+// stopped, frees its key for a retry once the lease has run out.
+const idempotencyLease = time.Minute
+
+// idempotencyResponse keeps a copy of what the handler writes.
+type idempotencyResponse struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *idempotencyResponse) Write(b []byte) (int, error) {
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *idempotencyResponse) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
+
+// Idempotent lets clients retry a create safely by sending an
+// Idempotency-Key header. The first request with a key runs as usual and
+// its response is stored; later ones with the same key and body get that
+// response back, marked with Idempotent-Replayed, without running the
+// handler again. Reusing a key for a different body is refused, as is a
+// retry while the first request is still running. Server errors and
+// panics are not stored, so the request can be retried, and the key of a
+// request that never finished is freed when its lease runs out. Requests
+// without the header are not affected.
+func Idempotent() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader("Idempotency-Key")
+		if key == "" {
+			c.Next()
+			return
+		}
+		if len(key) > 255 {
+			apierror.Abort(c, apierror.BadRequest("Idempotency-Key must be at most 255 characters"))
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			apierror.Abort(c, apierror.BadRequest("Invalid request data: "+err.Error()))
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+		sum := sha256.Sum256(append([]byte(c.Request.Method+" "+c.FullPath()+"\n"), body...))
+
+		now, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		record := models.IdempotencyRecord{
+			ID:           primitive.NewObjectID(),
+			Key:          key,
+			User_id:      c.GetString("uid"),
+			Endpoint:     c.FullPath(),
+			Request_hash: hex.EncodeToString(sum[:]),
+			Status:       "IN_PROGRESS",
+			Created_at:   now,
+			Expires_at:   now.Add(config.Get().RequestTimeout + idempotencyLease),
+		}
+
+		// The unique index on user and key makes the first request the
+		// only one to get here
+		if _, err := idempotencyCollection().InsertOne(ctx, record); err != nil {
+			if !mongo.IsDuplicateKeyError(err) {
+				apierror.Abort(c, apierror.Internal("error occurred while checking the Idempotency-Key: "+err.Error()))
+				return
+			}
+			if !takeOverIdempotencyKey(ctx, &record) {
+				replayIdempotent(ctx, c, record)
+				return
+			}
+		}
+
+		// A panic is answered further up by gin's Recovery; the key must not
+		// stay IN_PROGRESS behind it
+		defer func() {
+			if recovered := recover(); recovered != nil {
+				releaseIdempotencyKey(record.ID)
+				panic(recovered)
+			}
+		}()
+
+		writer := &idempotencyResponse{ResponseWriter: c.Writer}
+		c.Writer = writer
+		c.Next()
+
+		if writer.Status() >= http.StatusInternalServerError {
+			releaseIdempotencyKey(record.ID)
+			return
+		}
+		storeCtx, storeCancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer storeCancel()
+		if _, err := idempotencyCollection().UpdateOne(
+			storeCtx,
+			bson.M{"_id": record.ID},
+			bson.D{{Key: "$set", Value: bson.D{
+				{Key: "status", Value: "DONE"},
+				{Key: "response_status", Value: writer.Status()},
+				{Key: "response_body", Value: writer.body.Bytes()},
+				{Key: "expires_at", Value: time.Now().Add(idempotencyTTL)},
+			}}},
+		); err != nil {
+			log.Println("Error storing idempotent response:", err)
+		}
+	}
+}
+
+// takeOverIdempotencyKey gives record the key of an identical request that
+// is still IN_PROGRESS after its lease ran out, reporting whether it did.
+// The stored record is claimed in one update, so one retry wins; record
+// takes its id.
+func takeOverIdempotencyKey(ctx context.Context, record *models.IdempotencyRecord) bool {
+	var stored models.IdempotencyRecord
+	err := idempotencyCollection().FindOneAndUpdate(
+		ctx,
+		bson.M{
+			"user_id":      record.User_id,
+			"key":          record.Key,
+			"request_hash": record.Request_hash,
+			"status":       "IN_PROGRESS",
+			"expires_at":   bson.M{"$lte": record.Created_at},
+		},
+		bson.D{{Key: "$set", Value: bson.D{{Key: "created_at", Value: record.Created_at}, {Key: "expires_at", Value: record.Expires_at}}}},
+	).Decode(&stored)
+	if err == mongo.ErrNoDocuments {
+		return false
+	}
+	if err != nil {
+		log.Println("Error taking over Idempotency-Key:", err)
+		return false
+	}
+	record.ID = stored.ID
+	return true
+}
+
+// releaseIdempotencyKey deletes the record of a request that failed, so
+// the request can be retried with the same key. It has its own timeout as
+// the request's may have run out.
+func releaseIdempotencyKey(id primitive.ObjectID) {
+	ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+	defer cancel()
+	if _, err := idempotencyCollection().DeleteOne(ctx, bson.M{"_id": id, "status": "IN_PROGRESS"}); err != nil {
+		log.Println("Error releasing Idempotency-Key:", err)
+	}
+}
+
+// replayIdempotent answers a request whose key was already used.
+func replayIdempotent(ctx context.Context, c *gin.Context, request models.IdempotencyRecord) {
+	var stored models.IdempotencyRecord
+	if err := idempotencyCollection().FindOne(ctx, bson.M{"user_id": request.User_id, "key": request.Key}).Decode(&stored); err != nil {
+		apierror.Abort(c, apierror.Internal("error occurred while loading the stored response: "+err.Error()))
+		return
+	}
+	if stored.Request_hash != request.Request_hash {
+		apierror.Abort(c, apierror.Unprocessable("The Idempotency-Key was already used for a different request"))
+		return
+	}
+	if stored.Status != "DONE" {
+		apierror.Abort(c, apierror.Conflict("A request with this Idempotency-Key is still in progress"))
+		return
+	}
+
+	c.Header("Idempotent-Replayed", "true")
+	c.Data(stored.Response_status, "application/json; charset=utf-8", stored.Response_body)
+	c.Abort()
+}
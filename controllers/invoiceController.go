@@ -3,9 +3,16 @@ package controllers
 import (
 	"context"
 	"fmt"
+	"log"
 	"net/http"
+	"os"
+	"restaurant-management/apierror"
+	"restaurant-management/config"
 	"restaurant-management/database"
 	"restaurant-management/models"
+	"restaurant-management/realtime"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -24,24 +31,32 @@ type InvoiceViewFormat struct {
 	Table_number     interface{}
 	Payment_due_date time.Time
 	Order_details    interface{}
+	Fees             interface{}
+	Pricing          *models.OrderPricing
+	Tax_lines        []models.TaxLine
+	Cash_rounding    *float64
+	Deposit_credit   *float64
+	Split            *models.InvoiceSplit
 }
 
-var invoiceCollection *mongo.Collection = database.OpenCollection(database.Client, "invoice")
+var invoiceCollection = db.Collection("invoice")
+
+var invoiceSequenceCollection = db.Collection("invoiceSequence")
 
 func GetInvoices() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
 		defer cancel()
 
-		result, err := invoiceCollection.Find(ctx, bson.M{})
+		result, err := invoiceCollection().Find(ctx, bson.M{})
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occurred while listing invoices: " + err.Error()})
+			apierror.Render(c, apierror.Internal("error occurred while listing invoices: "+err.Error()))
 			return
 		}
 
 		var allInvoices []bson.M
 		if err = result.All(ctx, &allInvoices); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "error decoding invoices: " + err.Error()})
+			apierror.Render(c, apierror.Internal("error decoding invoices: "+err.Error()))
 			return
 		}
 
@@ -52,22 +67,26 @@ func GetInvoices() gin.HandlerFunc {
 
 func GetInvoice() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
 		defer cancel()
 
 		invoiceId := c.Param("invoice_id")
 
 		var invoice models.Invoice
 
-		err := invoiceCollection.FindOne(ctx, bson.M{"invoice_id": invoiceId}).Decode(&invoice)
+		err := invoiceCollection().FindOne(ctx, bson.M{"invoice_id": invoiceId}).Decode(&invoice)
 		if err != nil {
 			//Handle the error if the invoice item is not found
-			c.JSON(http.StatusNotFound, gin.H{"error": "invoice item not found"})
+			apierror.Render(c, apierror.NotFound("invoice item not found"))
 			return
 		}
 
 		var invoiceView InvoiceViewFormat
 		allOrderItems, err := ItemsByOrder(invoice.Order_id)
+		if err != nil {
+			apierror.Render(c, apierror.Internal("error occurred while listing order items: "+err.Error()))
+			return
+		}
 		invoiceView.Order_id = invoice.Order_id
 		invoiceView.Payment_due_date = invoice.Payment_due_date
 		invoiceView.Payment_method = "null"
@@ -77,72 +96,159 @@ func GetInvoice() gin.HandlerFunc {
 
 		invoiceView.Invoice_id = invoice.Invoice_id
 		invoiceView.Payment_status = *&invoice.Payment_status
-		invoiceView.Payment_due = allOrderItems[0]["payment_due"]
-		invoiceView.Table_number = allOrderItems[0]["table_number"]
-		invoiceView.Order_details = allOrderItems[0]["order_items"]
+		invoiceView.Payment_due = invoice.Payment_due
+		if len(allOrderItems) > 0 {
+			invoiceView.Table_number = allOrderItems[0]["table_number"]
+			invoiceView.Order_details = collapseComboLines(splitOrderLines(allOrderItems[0]["order_items"], invoice.Split))
+		}
+
+		// Order-level charges such as delivery demand fees are itemized
+		// separately, as are discounts in the order they were applied
+		var order models.Order
+		if err := orderCollection().FindOne(ctx, bson.M{"order_id": invoice.Order_id}).Decode(&order); err == nil {
+			invoiceView.Fees = order.Fees
+			// Until the invoice is finalized the amount due follows the order
+			if pricing, err := orderPricing(ctx, order); err == nil {
+				invoiceView.Pricing = &pricing
+				if invoice.Finalized_at == nil && invoice.Split == nil {
+					invoiceView.Payment_due = pricing.Total
+					invoiceView.Tax_lines = pricing.Tax_lines
+				}
+			}
+		}
+		if invoice.Finalized_at != nil || invoice.Split != nil {
+			invoiceView.Tax_lines = invoice.Tax_lines
+		}
+		invoiceView.Split = invoice.Split
+		invoiceView.Cash_rounding = invoice.Cash_rounding
+		invoiceView.Deposit_credit = invoice.Deposit_credit
 
 		c.JSON(http.StatusOK, invoiceView)
 
 	}
 }
 
+// CreateInvoice bills an order that has items and no invoice yet. The
+// amount due is priced by the server from the order's items; the payment is
+// due the next day unless payment_due_date says otherwise.
 func CreateInvoice() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		var ctx, cancel = context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
 		var invoice models.Invoice
 
 		if err := c.BindJSON(&invoice); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			apierror.Render(c, apierror.BadRequest("Invalid request data: "+err.Error()))
 			return
 		}
 
 		var order models.Order
 
-		err := orderCollection.FindOne(ctx, bson.M{"order_id": invoice.Order_id}).Decode(&order)
-		defer cancel()
+		err := orderCollection().FindOne(ctx, bson.M{"order_id": invoice.Order_id}).Decode(&order)
+		if err != nil {
+			apierror.Render(c, apierror.NotFound("Order was not found"))
+			return
+		}
+
+		existing, err := invoiceCollection().CountDocuments(ctx, bson.M{"order_id": invoice.Order_id})
 		if err != nil {
-			msg := fmt.Sprintf("message: Order was not found")
-			c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+			apierror.Render(c, apierror.Internal("error occurred while checking for an invoice: "+err.Error()))
 			return
 		}
+		if existing > 0 {
+			apierror.Render(c, apierror.Conflict("The order already has an invoice"))
+			return
+		}
+
+		orderItems, err := orderItemsOf(ctx, order.Order_id)
+		if err != nil {
+			apierror.Render(c, apierror.Internal("error occurred while listing order items: "+err.Error()))
+			return
+		}
+		if len(orderItems) == 0 {
+			apierror.Render(c, apierror.BadRequest("The order has no items to bill"))
+			return
+		}
+		_, pricing, err := priceOrder(ctx, order, orderItems)
+		if err != nil {
+			apierror.Render(c, apierror.Internal("error occurred while pricing order: "+err.Error()))
+			return
+		}
+		invoice.Payment_due = &pricing.Total
+		if invoice.Tip != nil && invoice.Tip_percent != nil {
+			apierror.Render(c, apierror.Validation("Validation failed: give either tip or tip_percent"))
+			return
+		}
+		if invoice.Tip_percent != nil {
+			tip := tipFor(nil, invoice.Tip_percent, pricing.Total)
+			invoice.Tip = &tip
+		}
+
+		// Invoices are only marked PAID by a payment or an update, which
+		// run what a paid invoice sets off
 		status := "PENDING"
-		if invoice.Payment_status == nil {
-			invoice.Payment_status = &status
+		if invoice.Payment_status != nil && *invoice.Payment_status != status {
+			apierror.Render(c, apierror.Validation("Validation failed: invoices are created PENDING"))
+			return
 		}
+		invoice.Payment_status = &status
 
-		invoice.Payment_due_date, _ = time.Parse(time.RFC3339, time.Now().AddDate(0, 0, 1).Format(time.RFC3339))
+		if invoice.Payment_due_date.IsZero() {
+			invoice.Payment_due_date, _ = time.Parse(time.RFC3339, time.Now().AddDate(0, 0, 1).Format(time.RFC3339))
+		}
 		invoice.Created_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
 		invoice.Updated_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
 		invoice.ID = primitive.NewObjectID()
 		invoice.Invoice_id = invoice.ID.Hex()
 
+		// Numbers, tax and credits are only set by the server
+		invoice.Invoice_number = nil
+		invoice.Fiscal_year = nil
+		invoice.Finalized_at = nil
+		invoice.Tax_lines = nil
+		invoice.Cash_rounding = nil
+		invoice.Deposit_credit = nil
+		invoice.Split = nil
+		if invoice.Location == nil {
+			location := defaultLocation
+			invoice.Location = &location
+		}
+
 		validationErr := validate.Struct(invoice)
 		if validationErr != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": validationErr.Error()})
+			apierror.Render(c, apierror.FromValidation(validationErr))
 			return
 		}
 
-		result, insertErr := invoiceCollection.InsertOne(ctx, invoice)
+		result, insertErr := invoiceCollection().InsertOne(ctx, invoice)
+		if mongo.IsDuplicateKeyError(insertErr) {
+			apierror.Render(c, apierror.Conflict("The order already has an invoice"))
+			return
+		}
 		if insertErr != nil {
 			msg := fmt.Sprintf("invoice item was not created")
-			c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+			apierror.Render(c, apierror.Internal(msg))
 			return
 		}
-		defer cancel()
 
-		c.JSON(http.StatusOK, result)
+		if err := applyReservationDeposit(ctx, invoice); err != nil {
+			log.Println("Error applying reservation deposit:", err)
+		}
+
+		c.JSON(http.StatusOK, gin.H{"InsertedID": result.InsertedID, "invoice_id": invoice.Invoice_id, "payment_due": invoice.Payment_due, "payment_due_date": invoice.Payment_due_date})
 	}
 }
 
 func UpdateInvoice() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		var ctx, cancel = context.WithTimeout(context.Background(), 100*time.Second)
+		var ctx, cancel = context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
 
 		var invoice models.Invoice
 		invoiceId := c.Param("invoice_id")
 
 		if err := c.BindJSON(&invoice); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			apierror.Render(c, apierror.BadRequest("Invalid request data: "+err.Error()))
 			return
 		}
 
@@ -151,42 +257,325 @@ func UpdateInvoice() gin.HandlerFunc {
 		var updateObj primitive.D
 
 		if invoice.Payment_method != nil {
+			if err := validate.Var(*invoice.Payment_method, "eq=CARD|eq=CASH|eq="); err != nil {
+				apierror.Render(c, apierror.Validation("Validation failed: payment_method must be CARD or CASH"))
+				return
+			}
 			updateObj = append(updateObj, bson.E{Key: "payment_method", Value: invoice.Payment_method})
 		}
 
+		// Marking the invoice PAID is done on its own below, so that only
+		// the request that settles it runs what a payment sets off
 		if invoice.Payment_status != nil {
-			updateObj = append(updateObj, bson.E{Key: "payment_status", Value: invoice.Payment_status})
+			if err := validate.Var(*invoice.Payment_status, "eq=PENDING|eq=PAID"); err != nil {
+				apierror.Render(c, apierror.Validation("Validation failed: payment_status must be PENDING or PAID"))
+				return
+			}
+			if *invoice.Payment_status != "PAID" {
+				updateObj = append(updateObj, bson.E{Key: "payment_status", Value: invoice.Payment_status})
+			}
 		}
 
-		invoice.Updated_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
-		updateObj = append(updateObj, bson.E{Key: "updated_at", Value: invoice.Updated_at})
+		if invoice.Tip != nil || invoice.Tip_percent != nil {
+			if invoice.Tip != nil && invoice.Tip_percent != nil {
+				apierror.Render(c, apierror.Validation("Validation failed: give either tip or tip_percent"))
+				return
+			}
+			if err := validate.Var(invoice.Tip, "omitempty,gte=0"); err != nil {
+				apierror.Render(c, apierror.Validation("Validation failed: tip must not be negative"))
+				return
+			}
+			if err := validate.Var(invoice.Tip_percent, "omitempty,gte=0,lte=100"); err != nil {
+				apierror.Render(c, apierror.Validation("Validation failed: tip_percent must be between 0 and 100"))
+				return
+			}
+			due := 0.0
+			if invoice.Tip_percent != nil {
+				var existing models.Invoice
+				if err := invoiceCollection().FindOne(ctx, filter).Decode(&existing); err != nil {
+					apierror.Render(c, apierror.NotFound("invoice item not found"))
+					return
+				}
+				var err error
+				if due, err = invoiceTotal(ctx, existing); err != nil {
+					apierror.Render(c, apierror.Internal("error occurred while pricing order: "+err.Error()))
+					return
+				}
+			}
+			updateObj = append(updateObj, bson.E{Key: "tip", Value: tipFor(invoice.Tip, invoice.Tip_percent, due)})
+		}
+
+		// Cash payments are attributed to the drawer session that took them
+		if invoice.Drawer_session_id != nil {
+			updateObj = append(updateObj, bson.E{Key: "drawer_session_id", Value: invoice.Drawer_session_id})
+		}
 
-		upsert := true
-		opt := options.UpdateOptions{
-			Upsert: &upsert,
+		if !invoice.Payment_due_date.IsZero() {
+			updateObj = append(updateObj, bson.E{Key: "payment_due_date", Value: invoice.Payment_due_date})
 		}
 
+		invoice.Updated_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		updateObj = append(updateObj, bson.E{Key: "updated_at", Value: invoice.Updated_at})
+
 		status := "PENDING"
 		if invoice.Payment_status == nil {
 			invoice.Payment_status = &status
 		}
 
-		result, err := invoiceCollection.UpdateOne(
+		result, err := invoiceCollection().UpdateOne(
 			ctx,
 			filter,
 			bson.D{
 
 				{Key: "$set", Value: updateObj},
 			},
-			&opt,
 		)
 		if err != nil {
 			msg := fmt.Sprintf("invoice item update failed")
-			c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+			apierror.Render(c, apierror.Internal(msg))
+			return
+		}
+		if result.MatchedCount == 0 {
+			apierror.Render(c, apierror.NotFound("invoice item not found"))
 			return
 		}
 
-		defer cancel()
+		paid := false
+		if *invoice.Payment_status == "PAID" {
+			settled, err := invoiceCollection().UpdateOne(
+				ctx,
+				bson.M{"invoice_id": invoiceId, "payment_status": bson.M{"$ne": "PAID"}},
+				bson.D{{Key: "$set", Value: bson.D{{Key: "payment_status", Value: "PAID"}, {Key: "updated_at", Value: invoice.Updated_at}}}},
+			)
+			if err != nil {
+				apierror.Render(c, apierror.Internal("invoice item update failed"))
+				return
+			}
+			paid = settled.ModifiedCount == 1
+			result.ModifiedCount += settled.ModifiedCount
+		}
+
+		if paid {
+			invoicePaid(ctx, invoiceId)
+		}
+
 		c.JSON(http.StatusOK, result)
 	}
 }
+
+// FinalizeInvoice assigns the invoice its number. Numbers run sequentially
+// without gaps per location and fiscal year and never change once assigned.
+func FinalizeInvoice() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		invoice, assigned, err := finalizeInvoice(ctx, c.Param("invoice_id"))
+		if err == mongo.ErrNoDocuments {
+			apierror.Render(c, apierror.NotFound("invoice not found"))
+			return
+		}
+		if err != nil {
+			apierror.Render(c, apierror.Internal("error occurred while finalizing invoice: "+err.Error()))
+			return
+		}
+		if !assigned {
+			apierror.Render(c, apierror.Conflict("Invoice is already finalized").With("data", invoice))
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Invoice finalized", "data": invoice})
+	}
+}
+
+// finalizeInvoice takes the next number of the invoice's location and fiscal
+// year and stores it on the invoice in one transaction, so a failed
+// finalization never burns a number. Only a replica set has transactions;
+// on a standalone development server a failure can leave a gap. It reports
+// false if the invoice was already numbered.
+func finalizeInvoice(ctx context.Context, invoiceId string) (models.Invoice, bool, error) {
+	var invoice models.Invoice
+	assigned := false
+	err := database.WithTransaction(ctx, db.Client(), func(txCtx context.Context) error {
+		assigned = false
+		if err := invoiceCollection().FindOne(txCtx, bson.M{"invoice_id": invoiceId}).Decode(&invoice); err != nil {
+			return err
+		}
+		if invoice.Invoice_number != nil {
+			return nil
+		}
+
+		location := defaultLocation
+		if invoice.Location != nil {
+			location = *invoice.Location
+		}
+		now, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		fiscalYear := fiscalYearOf(now)
+
+		sequence, err := nextSequenceNumber(txCtx, invoiceSequenceCollection(), location, fiscalYear)
+		if err != nil {
+			return err
+		}
+
+		// Tax is fixed at finalization so later rate changes don't rewrite
+		// filed returns. The invoices of a split check were fixed when it
+		// was split.
+		lines, total := invoice.Tax_lines, 0.0
+		if invoice.Payment_due != nil {
+			total = *invoice.Payment_due
+		}
+		if invoice.Split == nil {
+			var order models.Order
+			if err := orderCollection().FindOne(txCtx, bson.M{"order_id": invoice.Order_id}).Decode(&order); err != nil {
+				return err
+			}
+			orderItems, err := orderItemsOf(txCtx, invoice.Order_id)
+			if err != nil {
+				return err
+			}
+			orderItems, pricing, err := priceOrder(txCtx, order, orderItems)
+			if err != nil {
+				return err
+			}
+			if err := storeOrderPricing(txCtx, order, orderItems); err != nil {
+				return err
+			}
+			lines, total = pricing.Tax_lines, pricing.Total
+		}
+
+		number := invoiceNumber(location, fiscalYear, sequence)
+		_, err = invoiceCollection().UpdateOne(
+			txCtx,
+			bson.M{"invoice_id": invoiceId, "invoice_number": nil},
+			bson.D{{Key: "$set", Value: bson.D{
+				{Key: "invoice_number", Value: number},
+				{Key: "fiscal_year", Value: fiscalYear},
+				{Key: "finalized_at", Value: now},
+				{Key: "tax_lines", Value: lines},
+				{Key: "payment_due", Value: total},
+				{Key: "updated_at", Value: now},
+			}}},
+		)
+		if err != nil {
+			return err
+		}
+
+		invoice.Invoice_number = &number
+		invoice.Fiscal_year = &fiscalYear
+		invoice.Finalized_at = &now
+		invoice.Tax_lines = lines
+		invoice.Payment_due = &total
+		assigned = true
+		return nil
+	})
+	return invoice, assigned, err
+}
+
+// orderTotal is what an order costs: its items with their adjustments and
+// discounts plus order-level fees.
+func orderTotal(ctx context.Context, orderId string) (float64, error) {
+	var order models.Order
+	if err := orderCollection().FindOne(ctx, bson.M{"order_id": orderId}).Decode(&order); err != nil {
+		return 0, err
+	}
+	pricing, err := orderPricing(ctx, order)
+	if err != nil {
+		return 0, err
+	}
+	return pricing.Total, nil
+}
+
+// invoiceTotal is what an invoice bills: the share fixed when its check was
+// split, or else the order's total.
+func invoiceTotal(ctx context.Context, invoice models.Invoice) (float64, error) {
+	if invoice.Split != nil && invoice.Payment_due != nil {
+		return *invoice.Payment_due, nil
+	}
+	return orderTotal(ctx, invoice.Order_id)
+}
+
+// tipFor is a tip given as a fixed amount or as a percentage of due.
+func tipFor(amount, percent *float64, due float64) float64 {
+	switch {
+	case amount != nil:
+		return toFixed(*amount, 2)
+	case percent != nil:
+		return toFixed(due**percent/100, 2)
+	}
+	return 0
+}
+
+// nextSequenceNumber increments and returns the counter of a location and
+// fiscal year. It must run inside a transaction to stay gapless.
+func nextSequenceNumber(ctx context.Context, collection *mongo.Collection, location string, fiscalYear int) (int, error) {
+	var sequence struct{ Last_number int }
+	err := collection.FindOneAndUpdate(
+		ctx,
+		bson.M{"location": location, "fiscal_year": fiscalYear},
+		bson.D{
+			{Key: "$inc", Value: bson.D{{Key: "last_number", Value: 1}}},
+			{Key: "$setOnInsert", Value: bson.D{{Key: "_id", Value: primitive.NewObjectID()}}},
+		},
+		options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After),
+	).Decode(&sequence)
+	return sequence.Last_number, err
+}
+
+// fiscalYearOf returns the year in which t's fiscal year starts.
+// FISCAL_YEAR_START_MONTH (1-12, default 1) sets the first month.
+func fiscalYearOf(t time.Time) int {
+	startMonth := 1
+	if month, err := strconv.Atoi(os.Getenv("FISCAL_YEAR_START_MONTH")); err == nil && month >= 1 && month <= 12 {
+		startMonth = month
+	}
+	t = t.Local()
+	if int(t.Month()) < startMonth {
+		return t.Year() - 1
+	}
+	return t.Year()
+}
+
+// invoiceNumber formats a sequence number, e.g. 2026-000042, prefixed with
+// the location for locations other than the default one.
+func invoiceNumber(location string, fiscalYear, sequence int) string {
+	number := fmt.Sprintf("%d-%06d", fiscalYear, sequence)
+	if location != defaultLocation {
+		number = strings.ToUpper(location) + "-" + number
+	}
+	return number
+}
+
+// invoicePaid runs what settling an invoice sets off, for the request
+// that moved it to PAID only: cash rounding and the invoice number, since
+// a settled invoice is final, the survey invitation, as it completes the
+// visit, freeing the table, the invoice.paid event and webhooks, the
+// receipt and loyalty points. Failures are logged; the payment stands.
+func invoicePaid(ctx context.Context, invoiceId string) {
+	if err := applyCashRounding(ctx, invoiceId); err != nil {
+		log.Println("Error applying cash rounding:", err)
+	}
+	if _, _, err := finalizeInvoice(ctx, invoiceId); err != nil {
+		log.Println("Error finalizing invoice:", err)
+	}
+	var invoice models.Invoice
+	if err := invoiceCollection().FindOne(ctx, bson.M{"invoice_id": invoiceId}).Decode(&invoice); err != nil {
+		log.Println("Error loading paid invoice:", err)
+		return
+	}
+	if err := sendSurveyInvitations(ctx, invoice.Order_id); err != nil {
+		log.Println("Error sending survey invitations:", err)
+	}
+	if err := clearTable(ctx, invoice.Order_id); err != nil {
+		log.Println("Error clearing table:", err)
+	}
+	realtime.Publish(realtime.Event{Type: "invoice.paid", Order_id: invoice.Order_id, Data: gin.H{"invoice_id": invoice.Invoice_id}})
+	if err := queueWebhookEvent(ctx, "invoice.paid", invoice); err != nil {
+		log.Println("Error queueing webhooks:", err)
+	}
+	if err := emailReceipt(ctx, invoice.Invoice_id); err != nil {
+		log.Println("Error emailing receipt:", err)
+	}
+	if err := earnLoyaltyPoints(ctx, invoice.Invoice_id); err != nil {
+		log.Println("Error crediting loyalty points:", err)
+	}
+}
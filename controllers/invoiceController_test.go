@@ -0,0 +1,52 @@
+package controllers
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFiscalYearOf(t *testing.T) {
+	tests := []struct {
+		name       string
+		startMonth string
+		at         time.Time
+		want       int
+	}{
+		{"calendar year by default", "", time.Date(2026, time.January, 1, 0, 0, 0, 0, time.Local), 2026},
+		{"last day of a calendar year", "", time.Date(2026, time.December, 31, 23, 59, 0, 0, time.Local), 2026},
+		{"before an April start", "4", time.Date(2026, time.March, 31, 23, 59, 0, 0, time.Local), 2025},
+		{"on an April start", "4", time.Date(2026, time.April, 1, 0, 0, 0, 0, time.Local), 2026},
+		{"after an April start", "4", time.Date(2026, time.December, 15, 12, 0, 0, 0, time.Local), 2026},
+		{"out of range month falls back to January", "13", time.Date(2026, time.February, 1, 0, 0, 0, 0, time.Local), 2026},
+		{"unparsable month falls back to January", "april", time.Date(2026, time.February, 1, 0, 0, 0, 0, time.Local), 2026},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("FISCAL_YEAR_START_MONTH", tt.startMonth)
+			if got := fiscalYearOf(tt.at); got != tt.want {
+				t.Errorf("fiscalYearOf(%v) = %d, want %d", tt.at, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestInvoiceNumber(t *testing.T) {
+	tests := []struct {
+		name       string
+		location   string
+		fiscalYear int
+		sequence   int
+		want       string
+	}{
+		{"default location has no prefix", defaultLocation, 2026, 42, "2026-000042"},
+		{"other locations are prefixed", "downtown", 2026, 1, "DOWNTOWN-2026-000001"},
+		{"sequence wider than the padding", defaultLocation, 2025, 1234567, "2025-1234567"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := invoiceNumber(tt.location, tt.fiscalYear, tt.sequence); got != tt.want {
+				t.Errorf("invoiceNumber() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
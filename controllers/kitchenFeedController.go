@@ -0,0 +1,99 @@
+package controllers
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"restaurant-management/config"
+	"restaurant-management/realtime"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"golang.org/x/net/websocket"
+)
+
+// kitchenFeedHeartbeat is how often an idle kitchen feed sends a ping
+// event, so that proxies keep the connection open.
+const kitchenFeedHeartbeat = 30 * time.Second
+
+// KitchenFeed streams order and kitchen ticket events to a kitchen display
+// over a WebSocket. The first message is a snapshot event holding the open
+// tickets; ticket events of other stations are left out when ?station= is
+// given. Clients only listen; anything they send is ignored.
+func KitchenFeed() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		station := c.Query("station")
+		server := websocket.Server{
+			// Browsers send their page's origin; the token already
+			// authenticated the request
+			Handshake: func(config *websocket.Config, req *http.Request) error { return nil },
+			Handler: func(ws *websocket.Conn) {
+				defer ws.Close()
+				streamKitchenFeed(ws, station)
+			},
+		}
+		server.ServeHTTP(c.Writer, c.Request)
+	}
+}
+
+func streamKitchenFeed(ws *websocket.Conn, station string) {
+	// Subscribe before loading the snapshot so nothing falls in between
+	events, unsubscribe := realtime.Subscribe()
+	defer unsubscribe()
+
+	ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+	filter := bson.M{"status": "OPEN"}
+	if station != "" {
+		filter["station"] = station
+	}
+	var tickets []bson.M
+	cursor, err := kitchenTicketCollection().Find(ctx, filter, options.Find().SetSort(bson.D{{Key: "created_at", Value: 1}}))
+	if err == nil {
+		err = cursor.All(ctx, &tickets)
+	}
+	cancel()
+	if err != nil {
+		log.Println("Error loading kitchen feed snapshot:", err)
+		return
+	}
+	if tickets == nil {
+		tickets = []bson.M{}
+	}
+	if err := websocket.JSON.Send(ws, realtime.Event{Type: "snapshot", Station: station, Data: tickets, At: time.Now()}); err != nil {
+		return
+	}
+
+	// The connection is closed once the client goes away
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		var message string
+		for websocket.Message.Receive(ws, &message) == nil {
+		}
+	}()
+
+	heartbeat := time.NewTicker(kitchenFeedHeartbeat)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case event, ok := <-events:
+			if !ok {
+				return
+			}
+			if station != "" && event.Station != "" && event.Station != station {
+				continue
+			}
+			if err := websocket.JSON.Send(ws, event); err != nil {
+				return
+			}
+		case <-heartbeat.C:
+			if err := websocket.JSON.Send(ws, realtime.Event{Type: "ping", At: time.Now()}); err != nil {
+				return
+			}
+		case <-closed:
+			return
+		}
+	}
+}
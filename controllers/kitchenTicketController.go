@@ -0,0 +1,325 @@
+package controllers
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"restaurant-management/apierror"
+	"restaurant-management/config"
+	"restaurant-management/models"
+	"restaurant-management/realtime"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var kitchenTicketCollection = db.Collection("kitchenTicket")
+var allergyAcknowledgmentCollection = db.Collection("allergyAcknowledgment")
+
+// defaultStation prepares the foods without a recipe naming a station.
+const defaultStation = "KITCHEN"
+
+// GetKitchenTickets lists the tickets of ?station= with ?status= (default
+// OPEN), oldest first, as a kitchen display shows them.
+func GetKitchenTickets() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		filter := bson.M{"status": c.DefaultQuery("status", "OPEN")}
+		if station := c.Query("station"); station != "" {
+			filter["station"] = station
+		}
+
+		opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: 1}})
+		result, err := kitchenTicketCollection().Find(ctx, filter, opts)
+		if err != nil {
+			apierror.Render(c, apierror.Internal("error occurred while listing kitchen tickets: "+err.Error()))
+			return
+		}
+
+		var allTickets []bson.M
+		if err = result.All(ctx, &allTickets); err != nil {
+			apierror.Render(c, apierror.Internal("error decoding kitchen tickets: "+err.Error()))
+			return
+		}
+
+		c.JSON(http.StatusOK, allTickets)
+	}
+}
+
+// AcknowledgeAllergyAlert records that a chef has read a ticket's allergy
+// alert, which unlocks bumping it.
+func AcknowledgeAllergyAlert() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		var body struct {
+			Acknowledged_by *string `json:"acknowledged_by" validate:"required"`
+		}
+		if err := c.BindJSON(&body); err != nil {
+			apierror.Render(c, apierror.BadRequest("Invalid request data: "+err.Error()))
+			return
+		}
+		if err := validate.Struct(body); err != nil {
+			apierror.Render(c, apierror.FromValidation(err))
+			return
+		}
+
+		var ticket models.KitchenTicket
+		if err := kitchenTicketCollection().FindOne(ctx, bson.M{"kitchen_ticket_id": c.Param("kitchen_ticket_id")}).Decode(&ticket); err != nil {
+			apierror.Render(c, apierror.NotFound("Kitchen ticket not found"))
+			return
+		}
+		if !ticket.Allergy_alert {
+			apierror.Render(c, apierror.BadRequest("Ticket has no allergy alert"))
+			return
+		}
+		if ticket.Acknowledged_at != nil {
+			apierror.Render(c, apierror.Conflict("Allergy alert was already acknowledged").With("data", ticket))
+			return
+		}
+
+		now, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		notes := []string{}
+		for _, item := range ticket.Items {
+			if item.Allergy_note != "" {
+				notes = append(notes, item.Name+": "+item.Allergy_note)
+			}
+		}
+		acknowledgment := models.AllergyAcknowledgment{
+			ID:                primitive.NewObjectID(),
+			Kitchen_ticket_id: ticket.Kitchen_ticket_id,
+			Order_id:          ticket.Order_id,
+			Station:           ticket.Station,
+			Allergies:         ticket.Allergies,
+			Allergy_notes:     notes,
+			Acknowledged_by:   *body.Acknowledged_by,
+			Acknowledged_at:   now,
+		}
+		if _, err := allergyAcknowledgmentCollection().InsertOne(ctx, acknowledgment); err != nil {
+			apierror.Render(c, apierror.Internal("Could not log acknowledgment"))
+			return
+		}
+
+		_, err := kitchenTicketCollection().UpdateOne(
+			ctx,
+			bson.M{"kitchen_ticket_id": ticket.Kitchen_ticket_id},
+			bson.D{{Key: "$set", Value: bson.D{
+				{Key: "acknowledged_by", Value: body.Acknowledged_by},
+				{Key: "acknowledged_at", Value: now},
+				{Key: "updated_at", Value: now},
+			}}},
+		)
+		if err != nil {
+			apierror.Render(c, apierror.Internal("Update failed: "+err.Error()))
+			return
+		}
+
+		realtime.Publish(realtime.Event{Type: "kitchenTicket.acknowledged", Order_id: ticket.Order_id, Station: ticket.Station, Data: acknowledgment})
+
+		c.JSON(http.StatusOK, gin.H{"message": "Allergy alert acknowledged", "data": acknowledgment})
+	}
+}
+
+// BumpKitchenTicket clears a ticket from the display once its items are up.
+func BumpKitchenTicket() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		var body struct {
+			Bumped_by *string `json:"bumped_by"`
+		}
+		if err := c.BindJSON(&body); err != nil {
+			apierror.Render(c, apierror.BadRequest("Invalid request data: "+err.Error()))
+			return
+		}
+
+		var ticket models.KitchenTicket
+		if err := kitchenTicketCollection().FindOne(ctx, bson.M{"kitchen_ticket_id": c.Param("kitchen_ticket_id")}).Decode(&ticket); err != nil {
+			apierror.Render(c, apierror.NotFound("Kitchen ticket not found"))
+			return
+		}
+		if ticket.Status != "OPEN" {
+			apierror.Render(c, apierror.Conflict("Ticket was already bumped"))
+			return
+		}
+		if ticket.Allergy_alert && ticket.Acknowledged_at == nil {
+			apierror.Render(c, apierror.Conflict("Allergy alert must be acknowledged before bumping").With("allergies", ticket.Allergies))
+			return
+		}
+
+		now, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		// Only an open ticket is bumped, in case another screen got there first
+		result, err := kitchenTicketCollection().UpdateOne(
+			ctx,
+			bson.M{"kitchen_ticket_id": ticket.Kitchen_ticket_id, "status": "OPEN"},
+			bson.D{{Key: "$set", Value: bson.D{
+				{Key: "status", Value: "BUMPED"},
+				{Key: "bumped_by", Value: body.Bumped_by},
+				{Key: "bumped_at", Value: now},
+				{Key: "updated_at", Value: now},
+			}}},
+		)
+		if err != nil {
+			apierror.Render(c, apierror.Internal("Update failed: "+err.Error()))
+			return
+		}
+		if result.ModifiedCount == 0 {
+			apierror.Render(c, apierror.Conflict("Ticket was already bumped"))
+			return
+		}
+
+		realtime.Publish(realtime.Event{Type: "kitchenTicket.bumped", Order_id: ticket.Order_id, Station: ticket.Station, Data: gin.H{"kitchen_ticket_id": ticket.Kitchen_ticket_id, "bumped_by": body.Bumped_by, "bumped_at": now}})
+		if err := notifyOrderReady(ctx, ticket.Order_id); err != nil {
+			log.Println("Error sending order ready notifications:", err)
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Ticket bumped"})
+	}
+}
+
+// GetAllergyAcknowledgments lists the acknowledgment log, newest first,
+// optionally for one ?order_id=.
+func GetAllergyAcknowledgments() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		filter := bson.M{}
+		if orderId := c.Query("order_id"); orderId != "" {
+			filter["order_id"] = orderId
+		}
+
+		opts := options.Find().SetSort(bson.D{{Key: "acknowledged_at", Value: -1}})
+		result, err := allergyAcknowledgmentCollection().Find(ctx, filter, opts)
+		if err != nil {
+			apierror.Render(c, apierror.Internal("error occurred while listing acknowledgments: "+err.Error()))
+			return
+		}
+
+		var allAcknowledgments []bson.M
+		if err = result.All(ctx, &allAcknowledgments); err != nil {
+			apierror.Render(c, apierror.Internal("error decoding acknowledgments: "+err.Error()))
+			return
+		}
+
+		c.JSON(http.StatusOK, allAcknowledgments)
+	}
+}
+
+// fireKitchenTickets sends newly ordered items to the kitchen, one ticket per
+// station. Tickets are flagged when an item has an allergy note or the
+// customer's profile lists allergies.
+func fireKitchenTickets(ctx context.Context, order models.Order, orderItems []models.OrderItem) error {
+	if len(orderItems) == 0 {
+		return nil
+	}
+
+	var allergies []string
+	if order.Customer_id != nil && *order.Customer_id != "" {
+		var customer models.User
+		err := userCollection().FindOne(ctx, bson.M{"user_id": order.Customer_id}, options.FindOne().SetProjection(bson.M{"allergies": 1})).Decode(&customer)
+		if err != nil && err != mongo.ErrNoDocuments {
+			return err
+		}
+		allergies = customer.Allergies
+	}
+
+	foodIds := []string{}
+	for _, orderItem := range orderItems {
+		foodIds = append(foodIds, stringValue(orderItem.Food_id))
+	}
+	foods, err := foodsById(ctx, foodIds)
+	if err != nil {
+		return err
+	}
+	cursor, err := recipeCollection().Find(ctx, bson.M{"food_id": bson.M{"$in": foodIds}}, options.Find().SetProjection(bson.M{"food_id": 1, "station": 1}))
+	if err != nil {
+		return err
+	}
+	var recipes []models.Recipe
+	if err = cursor.All(ctx, &recipes); err != nil {
+		return err
+	}
+	stations := map[string]string{}
+	for _, recipe := range recipes {
+		stations[recipe.Food_id] = stringOr(recipe.Station, defaultStation)
+	}
+
+	now, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+	byStation := map[string]*models.KitchenTicket{}
+	for _, orderItem := range orderItems {
+		foodId := stringValue(orderItem.Food_id)
+		station, ok := stations[foodId]
+		if !ok {
+			station = defaultStation
+		}
+		ticket, ok := byStation[station]
+		if !ok {
+			ticket = &models.KitchenTicket{
+				ID:         primitive.NewObjectID(),
+				Order_id:   order.Order_id,
+				Table_id:   order.Table_id,
+				Station:    station,
+				Items:      []models.KitchenTicketItem{},
+				Status:     "OPEN",
+				Allergies:  allergies,
+				Created_at: now,
+				Updated_at: now,
+			}
+			ticket.Kitchen_ticket_id = ticket.ID.Hex()
+			ticket.Allergy_alert = len(allergies) > 0
+			byStation[station] = ticket
+		}
+
+		item := models.KitchenTicketItem{
+			Order_item_id: orderItem.Order_item_id,
+			Food_id:       foodId,
+			Name:          stringOr(foods[foodId].Name, foodId),
+			Quantity:      stringValue(orderItem.Quantity),
+		}
+		for _, substitution := range foods[foodId].Substitutions {
+			for _, id := range orderItem.Substitutions {
+				if substitution.Substitution_id == id {
+					item.Substitutions = append(item.Substitutions, *substitution.Name)
+				}
+			}
+		}
+		for _, adjustment := range orderItem.Adjustments {
+			if adjustment.Type == "MODIFIER" {
+				item.Modifiers = append(item.Modifiers, adjustment.Description)
+			}
+		}
+		if note := strings.TrimSpace(stringValue(orderItem.Allergy_note)); note != "" {
+			item.Allergy_note = note
+			ticket.Allergy_alert = true
+		}
+		ticket.Items = append(ticket.Items, item)
+	}
+
+	stationNames := make([]string, 0, len(byStation))
+	for station := range byStation {
+		stationNames = append(stationNames, station)
+	}
+	sort.Strings(stationNames)
+	tickets := make([]interface{}, 0, len(byStation))
+	for _, station := range stationNames {
+		tickets = append(tickets, *byStation[station])
+	}
+	if _, err = kitchenTicketCollection().InsertMany(ctx, tickets); err != nil {
+		return err
+	}
+	for _, station := range stationNames {
+		realtime.Publish(realtime.Event{Type: "kitchenTicket.created", Order_id: order.Order_id, Station: station, Data: byStation[station]})
+	}
+	return nil
+}
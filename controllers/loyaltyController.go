@@ -0,0 +1,310 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"os"
+	"restaurant-management/apierror"
+	"restaurant-management/config"
+	"restaurant-management/models"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var loyaltyLedgerCollection = db.Collection("loyaltyLedger")
+
+// GetLoyaltyLedger returns a customer's points balance and the ledger
+// entries behind it, newest first. Guests can read their own.
+func GetLoyaltyLedger() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		customerId := c.Param("customer_id")
+		if c.GetString("role") == "CUSTOMER" && customerId != c.GetString("uid") {
+			apierror.Render(c, apierror.NotFound("Customer not found"))
+			return
+		}
+
+		limit, err := strconv.Atoi(c.DefaultQuery("limit", "50"))
+		if err != nil || limit < 1 || limit > 200 {
+			apierror.Render(c, apierror.BadRequest("limit must be between 1 and 200"))
+			return
+		}
+
+		var customer models.Customer
+		if err := customerCollection().FindOne(ctx, bson.M{"customer_id": customerId}).Decode(&customer); err != nil {
+			apierror.Render(c, apierror.NotFound("Customer not found"))
+			return
+		}
+
+		opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}}).SetLimit(int64(limit))
+		cursor, err := loyaltyLedgerCollection().Find(ctx, bson.M{"customer_id": customerId}, opts)
+		if err != nil {
+			apierror.Render(c, apierror.Internal("error occurred while listing loyalty entries: "+err.Error()))
+			return
+		}
+		entries := []models.LoyaltyEntry{}
+		if err = cursor.All(ctx, &entries); err != nil {
+			apierror.Render(c, apierror.Internal("error occurred while listing loyalty entries: "+err.Error()))
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"customer_id": customerId, "loyalty_points": customer.Loyalty_points, "entries": entries})
+	}
+}
+
+// RedeemLoyaltyPoints spends a customer's points on an open invoice. The
+// points become a LOYALTY discount on the invoice's order, worth
+// LOYALTY_POINT_VALUE each, and the invoice's payment due is priced again.
+func RedeemLoyaltyPoints() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		var redemption models.LoyaltyRedemption
+		if err := c.BindJSON(&redemption); err != nil {
+			apierror.Render(c, apierror.BadRequest("Invalid request data: "+err.Error()))
+			return
+		}
+		if err := validate.Struct(redemption); err != nil {
+			apierror.Render(c, apierror.FromValidation(err))
+			return
+		}
+
+		var customer models.Customer
+		if err := customerCollection().FindOne(ctx, bson.M{"customer_id": c.Param("customer_id")}).Decode(&customer); err != nil {
+			apierror.Render(c, apierror.NotFound("Customer not found"))
+			return
+		}
+		var invoice models.Invoice
+		if err := invoiceCollection().FindOne(ctx, bson.M{"invoice_id": *redemption.Invoice_id}).Decode(&invoice); err != nil {
+			apierror.Render(c, apierror.NotFound("Invoice not found"))
+			return
+		}
+		if stringValue(invoice.Payment_status) != "PENDING" || invoice.Finalized_at != nil {
+			apierror.Render(c, apierror.Conflict("Invoice is already settled"))
+			return
+		}
+		var order models.Order
+		if err := orderCollection().FindOne(ctx, bson.M{"order_id": invoice.Order_id}).Decode(&order); err != nil {
+			apierror.Render(c, apierror.NotFound("Order not found"))
+			return
+		}
+		if order.Priced_at != nil {
+			apierror.Render(c, apierror.Conflict("Order is already invoiced"))
+			return
+		}
+		if order.Customer_id != nil && *order.Customer_id != customer.Customer_id {
+			apierror.Render(c, apierror.Conflict("Invoice is for another customer's order"))
+			return
+		}
+
+		pricing, err := orderPricing(ctx, order)
+		if err != nil {
+			apierror.Render(c, apierror.Internal("error occurred while pricing order: "+err.Error()))
+			return
+		}
+		_, pointValue := loyaltyRates()
+		points := *redemption.Points
+		amount := toFixed(float64(points)*pointValue, 2)
+		if amount <= 0 {
+			apierror.Render(c, apierror.BadRequest("Too few points to take anything off the bill"))
+			return
+		}
+		if amount > pricing.Total {
+			apierror.Render(c, apierror.BadRequest(fmt.Sprintf("%d points are worth %.2f, more than the %.2f due", points, amount, pricing.Total)))
+			return
+		}
+
+		// The balance is only taken down if it still covers the points, so
+		// two redemptions at once cannot overdraw it
+		result, err := customerCollection().UpdateOne(
+			ctx,
+			bson.M{"customer_id": customer.Customer_id, "loyalty_points": bson.M{"$gte": points}},
+			bson.D{{Key: "$inc", Value: bson.D{{Key: "loyalty_points", Value: -points}}}},
+		)
+		if err != nil {
+			apierror.Render(c, apierror.Internal("Update failed: "+err.Error()))
+			return
+		}
+		if result.MatchedCount == 0 {
+			apierror.Render(c, apierror.Conflict(fmt.Sprintf("Customer has %d points", customer.Loyalty_points)))
+			return
+		}
+
+		now, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		entry := models.LoyaltyEntry{
+			ID:          primitive.NewObjectID(),
+			Customer_id: customer.Customer_id,
+			Kind:        "REDEEM",
+			Points:      -points,
+			Amount:      &amount,
+			Invoice_id:  invoice.Invoice_id,
+			Order_id:    order.Order_id,
+			Staff_id:    staffId(c),
+			Created_at:  now,
+		}
+		entry.Loyalty_entry_id = entry.ID.Hex()
+
+		kind, scope, reason := "LOYALTY", "ORDER", fmt.Sprintf("%d points", points)
+		discount := models.OrderDiscount{
+			Kind:              &kind,
+			Scope:             &scope,
+			Amount_off:        &amount,
+			Reason:            &reason,
+			Added_at:          now,
+			Order_discount_id: entry.Loyalty_entry_id,
+		}
+		set := bson.D{{Key: "updated_at", Value: now}}
+		if order.Customer_id == nil {
+			set = append(set, bson.E{Key: "customer_id", Value: customer.Customer_id})
+		}
+		pushed, err := orderCollection().UpdateOne(
+			ctx,
+			bson.M{"order_id": order.Order_id, "priced_at": nil},
+			bson.D{
+				{Key: "$push", Value: bson.D{{Key: "discounts", Value: discount}}},
+				{Key: "$set", Value: set},
+			},
+		)
+		if err != nil || pushed.MatchedCount == 0 {
+			if _, err := customerCollection().UpdateOne(ctx, bson.M{"customer_id": customer.Customer_id}, bson.D{{Key: "$inc", Value: bson.D{{Key: "loyalty_points", Value: points}}}}); err != nil {
+				log.Println("Error giving back loyalty points:", err)
+			}
+			if err == nil {
+				apierror.Render(c, apierror.Conflict("Order is already invoiced"))
+				return
+			}
+			apierror.Render(c, apierror.Internal("Update failed: "+err.Error()))
+			return
+		}
+		if _, err := loyaltyLedgerCollection().InsertOne(ctx, entry); err != nil {
+			log.Println("Error recording loyalty redemption:", err)
+		}
+
+		order.Discounts = append(order.Discounts, discount)
+		pricing, err = refreshInvoiceDue(ctx, order, invoice.Invoice_id)
+		if err != nil {
+			apierror.Render(c, apierror.Internal("error occurred while pricing order: "+err.Error()))
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Points redeemed", "data": entry, "pricing": pricing})
+	}
+}
+
+// earnLoyaltyPoints credits the customer of a paid invoice's order with
+// LOYALTY_POINTS_PER_UNIT points per unit paid. Orders without a customer
+// profile earn nothing, and an invoice earns points only once.
+func earnLoyaltyPoints(ctx context.Context, invoiceId string) error {
+	var invoice models.Invoice
+	if err := invoiceCollection().FindOne(ctx, bson.M{"invoice_id": invoiceId}).Decode(&invoice); err != nil {
+		return err
+	}
+	var order models.Order
+	if err := orderCollection().FindOne(ctx, bson.M{"order_id": invoice.Order_id}).Decode(&order); err != nil {
+		return err
+	}
+	if order.Customer_id == nil || invoice.Payment_due == nil {
+		return nil
+	}
+	count, err := customerCollection().CountDocuments(ctx, bson.M{"customer_id": *order.Customer_id})
+	if err != nil || count == 0 {
+		return err
+	}
+
+	rate, _ := loyaltyRates()
+	points := int(math.Floor(*invoice.Payment_due * rate))
+	if points <= 0 {
+		return nil
+	}
+
+	now, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+	entry := models.LoyaltyEntry{
+		ID:          primitive.NewObjectID(),
+		Customer_id: *order.Customer_id,
+		Kind:        "EARN",
+		Points:      points,
+		Invoice_id:  invoice.Invoice_id,
+		Order_id:    order.Order_id,
+		Created_at:  now,
+	}
+	entry.Loyalty_entry_id = entry.ID.Hex()
+	// The ledger allows one EARN entry per invoice, so paying an invoice
+	// twice does not credit it twice
+	if _, err := loyaltyLedgerCollection().InsertOne(ctx, entry); err != nil {
+		if mongo.IsDuplicateKeyError(err) {
+			return nil
+		}
+		return err
+	}
+	_, err = customerCollection().UpdateOne(ctx, bson.M{"customer_id": entry.Customer_id}, bson.D{{Key: "$inc", Value: bson.D{{Key: "loyalty_points", Value: points}}}})
+	return err
+}
+
+// returnLoyaltyPoints gives back the points of a LOYALTY discount taken off
+// an order.
+func returnLoyaltyPoints(ctx context.Context, discount models.OrderDiscount) error {
+	var redeemed models.LoyaltyEntry
+	if err := loyaltyLedgerCollection().FindOne(ctx, bson.M{"loyalty_entry_id": discount.Order_discount_id}).Decode(&redeemed); err != nil {
+		return err
+	}
+
+	now, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+	entry := models.LoyaltyEntry{
+		ID:          primitive.NewObjectID(),
+		Customer_id: redeemed.Customer_id,
+		Kind:        "RETURN",
+		Points:      -redeemed.Points,
+		Amount:      redeemed.Amount,
+		Invoice_id:  redeemed.Invoice_id,
+		Order_id:    redeemed.Order_id,
+		Created_at:  now,
+	}
+	entry.Loyalty_entry_id = entry.ID.Hex()
+	if _, err := loyaltyLedgerCollection().InsertOne(ctx, entry); err != nil {
+		return err
+	}
+	_, err := customerCollection().UpdateOne(ctx, bson.M{"customer_id": entry.Customer_id}, bson.D{{Key: "$inc", Value: bson.D{{Key: "loyalty_points", Value: entry.Points}}}})
+	return err
+}
+
+// refreshInvoiceDue prices an order as it stands and stores the total as
+// the payment due of its invoice, as long as the invoice is still pending.
+func refreshInvoiceDue(ctx context.Context, order models.Order, invoiceId string) (models.OrderPricing, error) {
+	pricing, err := orderPricing(ctx, order)
+	if err != nil {
+		return pricing, err
+	}
+	now, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+	_, err = invoiceCollection().UpdateOne(
+		ctx,
+		bson.M{"invoice_id": invoiceId, "payment_status": "PENDING", "finalized_at": nil},
+		bson.D{{Key: "$set", Value: bson.D{{Key: "payment_due", Value: pricing.Total}, {Key: "updated_at", Value: now}}}},
+	)
+	return pricing, err
+}
+
+// loyaltyRates reads LOYALTY_POINTS_PER_UNIT, the points earned per unit of
+// currency paid (1 by default), and LOYALTY_POINT_VALUE, what a redeemed
+// point takes off the bill (0.01 by default).
+func loyaltyRates() (earn, value float64) {
+	earn, value = 1, 0.01
+	if rate, err := strconv.ParseFloat(os.Getenv("LOYALTY_POINTS_PER_UNIT"), 64); err == nil && rate >= 0 {
+		earn = rate
+	}
+	if rate, err := strconv.ParseFloat(os.Getenv("LOYALTY_POINT_VALUE"), 64); err == nil && rate > 0 {
+		value = rate
+	}
+	return earn, value
+}
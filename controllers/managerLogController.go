@@ -0,0 +1,190 @@
+package controllers
+
+import (
+	"context"
+	"net/http"
+	"regexp"
+	"restaurant-management/apierror"
+	"restaurant-management/config"
+	"restaurant-management/models"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var managerNoteCollection = db.Collection("managerNote")
+
+// GetManagerNotes searches the manager log. ?q= matches the note text,
+// ?tag= and ?author_id= narrow it down and ?from=&to= bound the business
+// date.
+func GetManagerNotes() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		filter := bson.M{"location": c.DefaultQuery("location", defaultLocation)}
+		if q := c.Query("q"); q != "" {
+			filter["body"] = bson.M{"$regex": regexp.QuoteMeta(q), "$options": "i"}
+		}
+		if tag := c.Query("tag"); tag != "" {
+			filter["tags"] = tag
+		}
+		if authorId := c.Query("author_id"); authorId != "" {
+			filter["author_id"] = authorId
+		}
+		if c.Query("from") != "" || c.Query("to") != "" {
+			from, to, err := parseReportRange(c)
+			if err != nil {
+				apierror.Render(c, apierror.BadRequest(err.Error()))
+				return
+			}
+			filter["business_date"] = bson.M{"$gte": from.Format("2006-01-02"), "$lt": to.Format("2006-01-02")}
+		}
+
+		opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}}).SetLimit(200)
+		result, err := managerNoteCollection().Find(ctx, filter, opts)
+		if err != nil {
+			apierror.Render(c, apierror.Internal("error occurred while searching the manager log: "+err.Error()))
+			return
+		}
+
+		var allNotes []bson.M
+		if err = result.All(ctx, &allNotes); err != nil {
+			apierror.Render(c, apierror.Internal("error decoding manager notes: "+err.Error()))
+			return
+		}
+
+		c.JSON(http.StatusOK, allNotes)
+	}
+}
+
+func CreateManagerNote() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		var note models.ManagerNote
+		if err := c.BindJSON(&note); err != nil {
+			apierror.Render(c, apierror.BadRequest("Invalid request data: "+err.Error()))
+			return
+		}
+		if err := validate.Struct(note); err != nil {
+			apierror.Render(c, apierror.FromValidation(err))
+			return
+		}
+
+		if hasTag(note.Tags, "EIGHTY_SIXED") {
+			if len(note.Food_ids) == 0 {
+				apierror.Render(c, apierror.BadRequest("food_ids are required for EIGHTY_SIXED notes"))
+				return
+			}
+			foods, err := foodsById(ctx, note.Food_ids)
+			if err != nil {
+				apierror.Render(c, apierror.Internal("error loading foods: "+err.Error()))
+				return
+			}
+			if len(foods) != len(note.Food_ids) {
+				apierror.Render(c, apierror.BadRequest("food_ids contains an unknown food"))
+				return
+			}
+		}
+
+		if note.Location == nil {
+			location := defaultLocation
+			note.Location = &location
+		}
+		if note.Tags == nil {
+			note.Tags = []string{}
+		}
+		now := time.Now()
+		note.Business_date = now.Format("2006-01-02")
+		note.Created_at, _ = time.Parse(time.RFC3339, now.Format(time.RFC3339))
+		note.Updated_at = note.Created_at
+		note.ID = primitive.NewObjectID()
+		note.Manager_note_id = note.ID.Hex()
+
+		result, err := managerNoteCollection().InsertOne(ctx, note)
+		if err != nil {
+			apierror.Render(c, apierror.Internal("Manager note was not created: "+err.Error()))
+			return
+		}
+
+		c.JSON(http.StatusCreated, gin.H{"message": "Manager note created", "data": result})
+	}
+}
+
+// UpdateManagerNote lets the author correct a note on the same business day.
+// Earlier entries are part of the record and stay as written.
+func UpdateManagerNote() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		var body struct {
+			Author_id *string  `json:"author_id" validate:"required"`
+			Body      *string  `json:"body" validate:"omitempty,min=2,max=5000"`
+			Tags      []string `json:"tags" validate:"dive,eq=EIGHTY_SIXED|eq=CALL_OUT|eq=VIP_VISIT|eq=INCIDENT|eq=MAINTENANCE|eq=GUEST_COMPLAINT|eq=GENERAL"`
+		}
+		if err := c.BindJSON(&body); err != nil {
+			apierror.Render(c, apierror.BadRequest("Invalid request data: "+err.Error()))
+			return
+		}
+		if err := validate.Struct(body); err != nil {
+			apierror.Render(c, apierror.FromValidation(err))
+			return
+		}
+
+		var updateObj primitive.D
+		if body.Body != nil {
+			updateObj = append(updateObj, bson.E{Key: "body", Value: body.Body})
+		}
+		if body.Tags != nil {
+			updateObj = append(updateObj, bson.E{Key: "tags", Value: body.Tags})
+		}
+		updatedAt, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		updateObj = append(updateObj, bson.E{Key: "updated_at", Value: updatedAt})
+
+		result, err := managerNoteCollection().UpdateOne(
+			ctx,
+			bson.M{"manager_note_id": c.Param("manager_note_id"), "author_id": body.Author_id, "business_date": time.Now().Format("2006-01-02")},
+			bson.D{{Key: "$set", Value: updateObj}},
+		)
+		if err != nil {
+			apierror.Render(c, apierror.Internal("Update failed: "+err.Error()))
+			return
+		}
+		if result.MatchedCount == 0 {
+			apierror.Render(c, apierror.NotFound("No editable note found; notes can only be edited by their author on the day they were written"))
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Manager note updated successfully"})
+	}
+}
+
+// managerNotesFor returns a location's notes for a business day in the order
+// they were written.
+func managerNotesFor(ctx context.Context, location, businessDate string) ([]models.ManagerNote, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: 1}})
+	cursor, err := managerNoteCollection().Find(ctx, bson.M{"location": location, "business_date": businessDate}, opts)
+	if err != nil {
+		return nil, err
+	}
+	notes := []models.ManagerNote{}
+	if err = cursor.All(ctx, &notes); err != nil {
+		return nil, err
+	}
+	return notes, nil
+}
+
+func hasTag(tags []string, tag string) bool {
+	for _, t := range tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,339 @@
+package controllers
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"restaurant-management/apierror"
+	"restaurant-management/config"
+	"restaurant-management/models"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var menuBoardCollection = db.Collection("menuBoard")
+
+const maxItemsPerSlide = 12
+
+type BoardItem struct {
+	Food_id       string `json:"food_id"`
+	Name          string `json:"name"`
+	Price         string `json:"price"`
+	Special_price string `json:"special_price,omitempty"`
+	Image         string `json:"image,omitempty"`
+}
+
+type BoardSlideContent struct {
+	Type             string      `json:"type"`
+	Title            string      `json:"title"`
+	Duration_seconds int         `json:"duration_seconds"`
+	Items            []BoardItem `json:"items"`
+}
+
+type BoardContent struct {
+	Board_id     string              `json:"board_id"`
+	Name         string              `json:"name"`
+	Poll_seconds int                 `json:"poll_seconds"`
+	Version      string              `json:"version"`
+	Slides       []BoardSlideContent `json:"slides"`
+}
+
+func GetMenuBoards() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		result, err := menuBoardCollection().Find(ctx, bson.M{})
+		if err != nil {
+			apierror.Render(c, apierror.Internal("error occurred while listing menu boards: "+err.Error()))
+			return
+		}
+
+		var allBoards []bson.M
+		if err = result.All(ctx, &allBoards); err != nil {
+			apierror.Render(c, apierror.Internal("error decoding menu boards: "+err.Error()))
+			return
+		}
+
+		c.JSON(http.StatusOK, allBoards)
+	}
+}
+
+func CreateMenuBoard() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		var board models.MenuBoard
+		if err := c.BindJSON(&board); err != nil {
+			apierror.Render(c, apierror.BadRequest("Invalid request data: "+err.Error()))
+			return
+		}
+
+		if err := validate.Struct(board); err != nil {
+			apierror.Render(c, apierror.FromValidation(err))
+			return
+		}
+		if err := validateBoardSlides(board.Slides); err != nil {
+			apierror.Render(c, apierror.FromValidation(err))
+			return
+		}
+
+		now := time.Now().Format(time.RFC3339)
+		board.Created_at, _ = time.Parse(time.RFC3339, now)
+		board.Updated_at, _ = time.Parse(time.RFC3339, now)
+		board.ID = primitive.NewObjectID()
+		board.Board_id = board.ID.Hex()
+
+		result, insertErr := menuBoardCollection().InsertOne(ctx, board)
+		if insertErr != nil {
+			apierror.Render(c, apierror.Internal("Could not create menu board"))
+			return
+		}
+
+		c.JSON(http.StatusCreated, gin.H{"message": "Menu board created", "data": result})
+	}
+}
+
+func UpdateMenuBoard() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		var board models.MenuBoard
+		if err := c.BindJSON(&board); err != nil {
+			apierror.Render(c, apierror.BadRequest("Invalid request data: "+err.Error()))
+			return
+		}
+
+		var updateObj primitive.D
+		if board.Name != nil {
+			updateObj = append(updateObj, bson.E{Key: "name", Value: board.Name})
+		}
+		if board.Slides != nil {
+			if err := validate.Var(board.Slides, "min=1,dive"); err != nil {
+				apierror.Render(c, apierror.FromValidation(err))
+				return
+			}
+			if err := validateBoardSlides(board.Slides); err != nil {
+				apierror.Render(c, apierror.FromValidation(err))
+				return
+			}
+			updateObj = append(updateObj, bson.E{Key: "slides", Value: board.Slides})
+		}
+		if board.Currency_symbol != nil {
+			updateObj = append(updateObj, bson.E{Key: "currency_symbol", Value: board.Currency_symbol})
+		}
+		if board.Poll_seconds != nil {
+			updateObj = append(updateObj, bson.E{Key: "poll_seconds", Value: board.Poll_seconds})
+		}
+
+		updatedAt, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		updateObj = append(updateObj, bson.E{Key: "updated_at", Value: updatedAt})
+
+		result, err := menuBoardCollection().UpdateOne(ctx, bson.M{"board_id": c.Param("board_id")}, bson.D{{Key: "$set", Value: updateObj}})
+		if err != nil {
+			apierror.Render(c, apierror.Internal("Update failed: "+err.Error()))
+			return
+		}
+		if result.MatchedCount == 0 {
+			apierror.Render(c, apierror.NotFound("Menu board not found"))
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Menu board updated successfully"})
+	}
+}
+
+// GetMenuBoardContent renders the board playlist. Boards poll it with
+// If-None-Match and receive 304 until the content version changes.
+func GetMenuBoardContent() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		content, err := renderBoardContent(ctx, c.Param("board_id"), time.Now())
+		if err == mongo.ErrNoDocuments {
+			apierror.Render(c, apierror.NotFound("Menu board not found"))
+			return
+		}
+		if err != nil {
+			apierror.Render(c, apierror.Internal("error occurred while rendering menu board: "+err.Error()))
+			return
+		}
+
+		etag := `"` + content.Version + `"`
+		c.Header("ETag", etag)
+		c.Header("Cache-Control", "no-cache")
+		if c.GetHeader("If-None-Match") == etag {
+			c.Status(http.StatusNotModified)
+			return
+		}
+
+		c.JSON(http.StatusOK, content)
+	}
+}
+
+// StreamMenuBoardContent pushes the rendered board over Server-Sent Events,
+// sending a "content" event whenever the version changes.
+func StreamMenuBoardContent() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		boardId := c.Param("board_id")
+
+		ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+		content, err := renderBoardContent(ctx, boardId, time.Now())
+		cancel()
+		if err == mongo.ErrNoDocuments {
+			apierror.Render(c, apierror.NotFound("Menu board not found"))
+			return
+		}
+		if err != nil {
+			apierror.Render(c, apierror.Internal("error occurred while rendering menu board: "+err.Error()))
+			return
+		}
+
+		c.Header("Cache-Control", "no-cache")
+		c.Header("X-Accel-Buffering", "no")
+
+		ticker := time.NewTicker(time.Duration(content.Poll_seconds) * time.Second)
+		defer ticker.Stop()
+
+		version := ""
+		c.Stream(func(w io.Writer) bool {
+			if content != nil && content.Version != version {
+				version = content.Version
+				c.SSEvent("content", content)
+			} else {
+				c.SSEvent("heartbeat", time.Now().Unix())
+			}
+
+			select {
+			case <-c.Request.Context().Done():
+				return false
+			case <-ticker.C:
+			}
+
+			ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+			defer cancel()
+			next, err := renderBoardContent(ctx, boardId, time.Now())
+			if err != nil {
+				// Keep showing the last good content; the board retries on reconnect
+				return err != mongo.ErrNoDocuments
+			}
+			content = next
+			return true
+		})
+	}
+}
+
+func validateBoardSlides(slides []models.BoardSlide) error {
+	for i, slide := range slides {
+		if slide.Type == "CATEGORY" && slide.Category == "" {
+			return fmt.Errorf("slide %d: category is required for CATEGORY slides", i)
+		}
+		if slide.Type == "MENU" && slide.Menu_id == "" {
+			return fmt.Errorf("slide %d: menu_id is required for MENU slides", i)
+		}
+	}
+	return nil
+}
+
+// renderBoardContent resolves each slide to the foods it shows. Unavailable
+// (86'd) foods are left out and specials reflect schedules live at `at`.
+func renderBoardContent(ctx context.Context, boardId string, at time.Time) (*BoardContent, error) {
+	var board models.MenuBoard
+	if err := menuBoardCollection().FindOne(ctx, bson.M{"board_id": boardId}).Decode(&board); err != nil {
+		return nil, err
+	}
+
+	symbol := "$"
+	if board.Currency_symbol != nil {
+		symbol = *board.Currency_symbol
+	}
+	pollSeconds := 30
+	if board.Poll_seconds != nil {
+		pollSeconds = *board.Poll_seconds
+	}
+	formatPrice := func(price *float64) string {
+		if price == nil {
+			return ""
+		}
+		return fmt.Sprintf("%s%.2f", symbol, *price)
+	}
+	toItem := func(food models.Food) BoardItem {
+		item := BoardItem{Food_id: food.Food_id, Price: formatPrice(food.Price)}
+		if food.Name != nil {
+			item.Name = *food.Name
+		}
+		if food.Food_image != nil {
+			item.Image = *food.Food_image
+		}
+		return item
+	}
+
+	content := &BoardContent{Board_id: board.Board_id, Poll_seconds: pollSeconds, Slides: []BoardSlideContent{}}
+	if board.Name != nil {
+		content.Name = *board.Name
+	}
+
+	for _, slide := range board.Slides {
+		slideContent := BoardSlideContent{Type: slide.Type, Title: slide.Title, Duration_seconds: slide.Duration_seconds, Items: []BoardItem{}}
+
+		if slide.Type == "SPECIALS" {
+			specials, err := findSpecials(ctx, func(schedule models.PriceSchedule) bool {
+				return scheduleAppliesAt(schedule, at)
+			}, maxItemsPerSlide)
+			if err != nil {
+				return nil, err
+			}
+			for _, s := range specials {
+				item := toItem(s.Food)
+				item.Special_price = formatPrice(&s.Special_price)
+				slideContent.Items = append(slideContent.Items, item)
+			}
+		} else {
+			menuIds := []string{slide.Menu_id}
+			if slide.Type == "CATEGORY" {
+				var err error
+				if menuIds, err = menuIdsInCategory(ctx, slide.Category); err != nil {
+					return nil, err
+				}
+			}
+			filter := bson.M{"menu_id": bson.M{"$in": menuIds}, "available": bson.M{"$ne": false}}
+			opts := options.Find().SetSort(bson.D{{Key: "name", Value: 1}}).SetLimit(maxItemsPerSlide)
+			cursor, err := foodCollection().Find(ctx, filter, opts)
+			if err != nil {
+				return nil, err
+			}
+			var foods []models.Food
+			if err = cursor.All(ctx, &foods); err != nil {
+				return nil, err
+			}
+			for _, food := range foods {
+				slideContent.Items = append(slideContent.Items, toItem(food))
+			}
+		}
+
+		// Skip empty slides so the board never shows a blank screen
+		if len(slideContent.Items) > 0 {
+			content.Slides = append(content.Slides, slideContent)
+		}
+	}
+
+	payload, err := json.Marshal(content)
+	if err != nil {
+		return nil, err
+	}
+	sum := sha1.Sum(payload)
+	content.Version = hex.EncodeToString(sum[:8])
+	return content, nil
+}
@@ -4,29 +4,33 @@ import (
 	"context"
 	"log"
 	"net/http"
-	"restaurant-management/database"
+	"restaurant-management/apierror"
+	"restaurant-management/config"
 	"restaurant-management/models"
+	"restaurant-management/services"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"go.mongodb.org/mongo-driver/bson"
-	"go.mongodb.org/mongo-driver/bson/primitive"
-	"go.mongodb.org/mongo-driver/mongo"
-	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
-var menuCollection *mongo.Collection = database.OpenCollection(database.Client, "menu")
+var menuCollection = db.Collection("menu")
 
 func GetMenus() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		// Create a context with a timeout of 100 seconds
-		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
 		defer cancel() // Ensure cleanup of context
 
-		// Query the MongoDB collection to fetch all menu items
-		result, err := menuCollection.Find(ctx, bson.M{})
+		// Query the MongoDB collection to fetch all menu items, or only
+		// those running now with ?active=true
+		filter := bson.M{}
+		if c.Query("active") == "true" {
+			filter = menusRunningAt(time.Now())
+		}
+		result, err := menuCollection().Find(ctx, liveFilter(c, filter))
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Error while fetching the menu items"})
+			apierror.Render(c, apierror.Internal("Error while fetching the menu items"))
 			return
 		}
 
@@ -35,7 +39,7 @@ func GetMenus() gin.HandlerFunc {
 		if err = result.All(ctx, &allMenus); err != nil {
 			// Log the error but avoid using Fatal, which stops the entire application
 			log.Println("Error decoding menu items:", err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to decode menu items"})
+			apierror.Render(c, apierror.Internal("Failed to decode menu items"))
 			return
 		}
 
@@ -47,16 +51,16 @@ func GetMenus() gin.HandlerFunc {
 func GetMenu() gin.HandlerFunc {
 	return func(c *gin.Context) {
 
-		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
 		defer cancel()
 		menuId := c.Param("menu_id")
 
 		var menu models.Menu
 
-		err := menuCollection.FindOne(ctx, bson.M{"menu_id": menuId}).Decode(&menu)
+		err := menuCollection().FindOne(ctx, liveFilter(c, bson.M{"menu_id": menuId})).Decode(&menu)
 		if err != nil {
 
-			c.JSON(http.StatusNotFound, gin.H{"error": "Menu item not found"})
+			apierror.Render(c, apierror.NotFound("Menu item not found"))
 			return
 		}
 
@@ -66,32 +70,20 @@ func GetMenu() gin.HandlerFunc {
 
 func CreateMenu() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
 		defer cancel()
 		var menu models.Menu
 		if err := c.BindJSON(&menu); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data: " + err.Error()})
-			return
-		}
-		// Validate struct fields
-		if err := validate.Struct(menu); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Validation failed: " + err.Error()})
+			apierror.Render(c, apierror.BadRequest("Invalid request data: "+err.Error()))
 			return
 		}
 
-		// Assign metadata to the food item
-		now := time.Now().Format(time.RFC3339)
-		menu.Created_at, _ = time.Parse(time.RFC3339, now)
-		menu.Updated_at, _ = time.Parse(time.RFC3339, now)
-
-		menu.ID = primitive.NewObjectID()
-		menu.Menu_id = menu.ID.Hex()
-
-		result, insertErr := menuCollection.InsertOne(ctx, menu)
-		if insertErr != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not create menu"})
+		result, err := services.CreateMenu(ctx, &menu)
+		if err != nil {
+			apierror.Render(c, serviceError(err))
 			return
 		}
+		auditCreated(c, menu.Menu_id)
 
 		// Return success response
 		c.JSON(http.StatusCreated, gin.H{"message": "Menu created", "data": result})
@@ -99,64 +91,117 @@ func CreateMenu() gin.HandlerFunc {
 
 }
 
-func inTimeSpan(start, end, check time.Time) bool {
-	return start.After(time.Now()) && end.After(start)
+// menusRunningAt matches the live published menus whose window is open at t.
+// Menus from before publishing existed have no status and count as
+// published.
+func menusRunningAt(t time.Time) bson.M {
+	return bson.M{
+		"status":     bson.M{"$nin": bson.A{"DRAFT", "ARCHIVED"}},
+		"deleted_at": notDeleted,
+		"$and": bson.A{
+			bson.M{"$or": bson.A{bson.M{"start_date": nil}, bson.M{"start_date": bson.M{"$lte": t}}}},
+			bson.M{"$or": bson.A{bson.M{"end_date": nil}, bson.M{"end_date": bson.M{"$gte": t}}}},
+		},
+	}
+}
+
+// ActivateMenus flips menus active as their window opens and inactive as
+// it closes.
+func ActivateMenus(ctx context.Context) error {
+	now := time.Now()
+	updatedAt, _ := time.Parse(time.RFC3339, now.Format(time.RFC3339))
+	opened := menusRunningAt(now)
+	opened["active"] = bson.M{"$ne": true}
+	if _, err := menuCollection().UpdateMany(ctx, opened, bson.M{"$set": bson.M{"active": true, "updated_at": updatedAt}}); err != nil {
+		return err
+	}
+	closed := bson.M{
+		"active": bson.M{"$ne": false},
+		"$or": bson.A{
+			bson.M{"status": bson.M{"$in": bson.A{"DRAFT", "ARCHIVED"}}},
+			bson.M{"deleted_at": bson.M{"$ne": nil}},
+			bson.M{"start_date": bson.M{"$gt": now}},
+			bson.M{"end_date": bson.M{"$lt": now}},
+		},
+	}
+	_, err := menuCollection().UpdateMany(ctx, closed, bson.M{"$set": bson.M{"active": false, "updated_at": updatedAt}})
+	return err
 }
 
 func UpdateMenu() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
 		defer cancel()
 		var menu models.Menu
 
 		if err := c.BindJSON(&menu); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data: " + err.Error()})
+			apierror.Render(c, apierror.BadRequest("Invalid request data: "+err.Error()))
 			return
 		}
 
-		menuId := c.Param("menu_id")
-		filter := bson.M{"menu_id": menuId}
+		result, err := services.UpdateMenu(ctx, c.Param("menu_id"), menu)
+		if err != nil {
+			apierror.Render(c, serviceError(err))
+			return
+		}
 
-		var updateObj primitive.D
+		c.JSON(http.StatusOK, gin.H{"message": "Menu updated successfully", "result": result})
+	}
+}
 
-		if menu.Start_Date != nil && menu.End_Date != nil {
-			if !inTimeSpan(*menu.Start_Date, *menu.End_Date, time.Now()) {
-				msg := "Kindly correct the time"
-				c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
-				return
-			}
+// DeleteMenu soft deletes a menu, which stops running straight away. Live
+// foods on the menu block the delete, unless ?cascade=true, which deletes
+// them with it and deactivates the combos they were part of.
+func DeleteMenu() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
 
-			updateObj = append(updateObj, bson.E{Key: "start_date", Value: menu.Start_Date})
-			updateObj = append(updateObj, bson.E{Key: "end_date", Value: menu.End_Date})
+		menuId := c.Param("menu_id")
+		if err := menuCollection().FindOne(ctx, bson.M{"menu_id": menuId, "deleted_at": notDeleted}).Err(); err != nil {
+			apierror.Render(c, apierror.NotFound("Menu not found"))
+			return
 		}
-
-		if menu.Name != "" {
-			updateObj = append(updateObj, bson.E{Key: "name", Value: menu.Name})
+		foods, err := idsOf(ctx, foodCollection(), bson.M{"menu_id": menuId, "deleted_at": notDeleted}, "food_id")
+		if err != nil {
+			apierror.Render(c, apierror.Internal("error occurred while checking the menu's foods"))
+			return
 		}
-
-		if menu.Category != "" {
-			updateObj = append(updateObj, bson.E{Key: "category", Value: menu.Category})
+		cascade := c.Query("cascade") == "true"
+		if len(foods) > 0 && !cascade {
+			apierror.Render(c, apierror.Conflict("The menu still has foods; move or delete them or pass cascade=true").With("food_ids", foods))
+			return
 		}
 
-		menu.Updated_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
-
-		updateObj = append(updateObj, bson.E{Key: "updated_at", Value: menu.Updated_at})
-
-		upsert := true
-		opt := options.UpdateOptions{Upsert: &upsert}
-
-		result, err := menuCollection.UpdateOne(
-			ctx,
-			filter,
-			bson.D{{Key: "$set", Value: updateObj}},
-			&opt,
-		)
+		combos := []string{}
+		if len(foods) > 0 {
+			now, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+			if _, err := foodCollection().UpdateMany(
+				ctx,
+				bson.M{"food_id": bson.M{"$in": foods}, "deleted_at": notDeleted},
+				bson.M{"$set": bson.M{"deleted_at": now, "updated_at": now}},
+			); err != nil {
+				apierror.Render(c, apierror.Internal("Delete failed: "+err.Error()))
+				return
+			}
+			if combos, err = idsOf(ctx, comboCollection(), activeCombosWith(foods), "combo_id"); err != nil {
+				apierror.Render(c, apierror.Internal("error occurred while checking the foods' combos"))
+				return
+			}
+			if err := deactivateCombos(ctx, combos); err != nil {
+				apierror.Render(c, apierror.Internal("error occurred while deactivating combos: "+err.Error()))
+				return
+			}
+		}
 
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Update failed: " + err.Error()})
+		if _, err := softDelete(ctx, menuCollection(), "menu_id", menuId); err != nil {
+			apierror.Render(c, apierror.Internal("Delete failed: "+err.Error()))
 			return
 		}
+		if _, err := menuCollection().UpdateOne(ctx, bson.M{"menu_id": menuId}, bson.M{"$set": bson.M{"active": false}}); err != nil {
+			log.Println("Error deactivating deleted menu:", err)
+		}
 
-		c.JSON(http.StatusOK, gin.H{"message": "Menu updated successfully", "result": result})
+		c.JSON(http.StatusOK, gin.H{"message": "Menu deleted", "deleted_food_ids": foods, "deactivated_combo_ids": combos})
 	}
 }
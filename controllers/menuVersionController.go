@@ -0,0 +1,208 @@
+package controllers
+
+import (
+	"context"
+	"net/http"
+	"restaurant-management/apierror"
+	"restaurant-management/config"
+	"restaurant-management/database"
+	"restaurant-management/models"
+	"restaurant-management/services"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var menuVersionCollection = db.Collection("menuVersion")
+
+// PublishMenu puts a menu live and records it with its foods as a new
+// version, which can later be rolled back to.
+func PublishMenu() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		var menu models.Menu
+		if err := menuCollection().FindOne(ctx, bson.M{"menu_id": c.Param("menu_id")}).Decode(&menu); err != nil {
+			apierror.Render(c, apierror.NotFound("Menu not found"))
+			return
+		}
+		if menu.Status == "ARCHIVED" {
+			apierror.Render(c, apierror.Conflict("Archived menus cannot be published; roll back to a version instead"))
+			return
+		}
+
+		foods, err := menuFoods(ctx, menu.Menu_id)
+		if err != nil {
+			apierror.Render(c, apierror.Internal("error occurred while loading the menu's foods: "+err.Error()))
+			return
+		}
+		version, err := services.PublishMenuVersion(ctx, menu, foods, nil, c.GetString("uid"))
+		if err != nil {
+			apierror.Render(c, apierror.Internal("Publish failed: "+err.Error()))
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Menu published", "version": version.Version, "menu_version_id": version.Menu_version_id})
+	}
+}
+
+// ArchiveMenu takes a menu off sale for good. Its versions are kept.
+func ArchiveMenu() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		updatedAt, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		result, err := menuCollection().UpdateOne(
+			ctx,
+			bson.M{"menu_id": c.Param("menu_id")},
+			bson.D{{Key: "$set", Value: bson.D{
+				{Key: "status", Value: "ARCHIVED"},
+				{Key: "active", Value: false},
+				{Key: "updated_at", Value: updatedAt},
+			}}},
+		)
+		if err != nil {
+			apierror.Render(c, apierror.Internal("Update failed: "+err.Error()))
+			return
+		}
+		if result.MatchedCount == 0 {
+			apierror.Render(c, apierror.NotFound("Menu not found"))
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Menu archived"})
+	}
+}
+
+// GetMenuVersions lists a menu's published versions, newest first, without
+// their foods.
+func GetMenuVersions() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		opts := options.Find().SetSort(bson.D{{Key: "version", Value: -1}}).SetProjection(bson.M{"foods": 0})
+		result, err := menuVersionCollection().Find(ctx, bson.M{"menu_id": c.Param("menu_id")}, opts)
+		if err != nil {
+			apierror.Render(c, apierror.Internal("error occurred while listing menu versions: "+err.Error()))
+			return
+		}
+
+		var allVersions []bson.M
+		if err = result.All(ctx, &allVersions); err != nil {
+			apierror.Render(c, apierror.Internal("error decoding menu versions: "+err.Error()))
+			return
+		}
+
+		c.JSON(http.StatusOK, allVersions)
+	}
+}
+
+func GetMenuVersion() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		version, err := strconv.Atoi(c.Param("version"))
+		if err != nil {
+			apierror.Render(c, apierror.BadRequest("version must be a number"))
+			return
+		}
+		var menuVersion models.MenuVersion
+		if err := menuVersionCollection().FindOne(ctx, bson.M{"menu_id": c.Param("menu_id"), "version": version}).Decode(&menuVersion); err != nil {
+			apierror.Render(c, apierror.NotFound("Menu version not found"))
+			return
+		}
+
+		c.JSON(http.StatusOK, menuVersion)
+	}
+}
+
+// RollbackMenu restores a menu and its foods as they were in an earlier
+// version and publishes the result as a new version. Foods added since
+// that version are left as they are and listed in the response.
+func RollbackMenu() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		versionNumber, err := strconv.Atoi(c.Param("version"))
+		if err != nil {
+			apierror.Render(c, apierror.BadRequest("version must be a number"))
+			return
+		}
+		var target models.MenuVersion
+		if err := menuVersionCollection().FindOne(ctx, bson.M{"menu_id": c.Param("menu_id"), "version": versionNumber}).Decode(&target); err != nil {
+			apierror.Render(c, apierror.NotFound("Menu version not found"))
+			return
+		}
+		var current models.Menu
+		if err := menuCollection().FindOne(ctx, bson.M{"menu_id": target.Menu_id}).Decode(&current); err != nil {
+			apierror.Render(c, apierror.NotFound("Menu not found"))
+			return
+		}
+
+		now, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		restored := target.Menu
+		restored.ID = current.ID
+		restored.Version = current.Version
+		restored.Created_at = current.Created_at
+		restored.Deleted_at = current.Deleted_at
+		restored.Updated_at = now
+
+		kept := map[string]bool{}
+		err = database.WithTransaction(ctx, db.Client(), func(txCtx context.Context) error {
+			if _, err := menuCollection().ReplaceOne(txCtx, bson.M{"menu_id": restored.Menu_id}, restored); err != nil {
+				return err
+			}
+			for _, food := range target.Foods {
+				food.Updated_at = now
+				kept[food.Food_id] = true
+				if _, err := foodCollection().ReplaceOne(txCtx, bson.M{"food_id": food.Food_id}, food, options.Replace().SetUpsert(true)); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			apierror.Render(c, apierror.Internal("Rollback failed: "+err.Error()))
+			return
+		}
+
+		foods, err := menuFoods(ctx, restored.Menu_id)
+		if err != nil {
+			apierror.Render(c, apierror.Internal("error occurred while loading the menu's foods: "+err.Error()))
+			return
+		}
+		addedSince := []string{}
+		for _, food := range foods {
+			if !kept[food.Food_id] {
+				addedSince = append(addedSince, food.Food_id)
+			}
+		}
+		version, err := services.PublishMenuVersion(ctx, restored, foods, &target.Version, c.GetString("uid"))
+		if err != nil {
+			apierror.Render(c, apierror.Internal("Publish failed: "+err.Error()))
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Menu rolled back", "version": version.Version, "rolled_back_from": target.Version, "foods_added_since": addedSince})
+	}
+}
+
+func menuFoods(ctx context.Context, menuId string) ([]models.Food, error) {
+	cursor, err := foodCollection().Find(ctx, bson.M{"menu_id": menuId})
+	if err != nil {
+		return nil, err
+	}
+	foods := []models.Food{}
+	if err = cursor.All(ctx, &foods); err != nil {
+		return nil, err
+	}
+	return foods, nil
+}
@@ -0,0 +1,299 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"restaurant-management/apierror"
+	"restaurant-management/config"
+	"restaurant-management/models"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+var modifierGroupCollection = db.Collection("modifierGroup")
+
+func GetModifierGroups() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		result, err := modifierGroupCollection().Find(ctx, bson.M{})
+		if err != nil {
+			apierror.Render(c, apierror.Internal("error occurred while listing modifier groups: "+err.Error()))
+			return
+		}
+
+		var allGroups []bson.M
+		if err = result.All(ctx, &allGroups); err != nil {
+			apierror.Render(c, apierror.Internal("error decoding modifier groups: "+err.Error()))
+			return
+		}
+
+		c.JSON(http.StatusOK, allGroups)
+	}
+}
+
+func GetModifierGroup() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		var group models.ModifierGroup
+		if err := modifierGroupCollection().FindOne(ctx, bson.M{"modifier_group_id": c.Param("modifier_group_id")}).Decode(&group); err != nil {
+			apierror.Render(c, apierror.NotFound("Modifier group not found"))
+			return
+		}
+
+		c.JSON(http.StatusOK, group)
+	}
+}
+
+func CreateModifierGroup() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		var group models.ModifierGroup
+		if err := c.BindJSON(&group); err != nil {
+			apierror.Render(c, apierror.BadRequest("Invalid request data: "+err.Error()))
+			return
+		}
+		if err := validate.Struct(group); err != nil {
+			apierror.Render(c, apierror.FromValidation(err))
+			return
+		}
+		if err := prepareModifierGroup(&group); err != nil {
+			apierror.Render(c, apierror.FromValidation(err))
+			return
+		}
+
+		now := time.Now().Format(time.RFC3339)
+		group.Created_at, _ = time.Parse(time.RFC3339, now)
+		group.Updated_at, _ = time.Parse(time.RFC3339, now)
+		group.ID = primitive.NewObjectID()
+		group.Modifier_group_id = group.ID.Hex()
+
+		if _, err := modifierGroupCollection().InsertOne(ctx, group); err != nil {
+			apierror.Render(c, apierror.Internal("Could not create modifier group"))
+			return
+		}
+		auditCreated(c, group.Modifier_group_id)
+
+		c.JSON(http.StatusCreated, gin.H{"message": "Modifier group created", "data": group})
+	}
+}
+
+// UpdateModifierGroup changes a group's name, limits or options. Options
+// keeping their modifier_option_id stay valid for items already ordered.
+func UpdateModifierGroup() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		var body models.ModifierGroup
+		if err := c.BindJSON(&body); err != nil {
+			apierror.Render(c, apierror.BadRequest("Invalid request data: "+err.Error()))
+			return
+		}
+
+		var group models.ModifierGroup
+		if err := modifierGroupCollection().FindOne(ctx, bson.M{"modifier_group_id": c.Param("modifier_group_id")}).Decode(&group); err != nil {
+			apierror.Render(c, apierror.NotFound("Modifier group not found"))
+			return
+		}
+		if body.Name != nil {
+			group.Name = body.Name
+		}
+		if body.Min_select != nil {
+			group.Min_select = body.Min_select
+		}
+		if body.Max_select != nil {
+			group.Max_select = body.Max_select
+		}
+		if body.Options != nil {
+			group.Options = body.Options
+		}
+		if err := validate.Struct(group); err != nil {
+			apierror.Render(c, apierror.FromValidation(err))
+			return
+		}
+		if err := prepareModifierGroup(&group); err != nil {
+			apierror.Render(c, apierror.FromValidation(err))
+			return
+		}
+
+		group.Updated_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		_, err := modifierGroupCollection().UpdateOne(
+			ctx,
+			bson.M{"modifier_group_id": group.Modifier_group_id},
+			bson.D{{Key: "$set", Value: bson.D{
+				{Key: "name", Value: group.Name},
+				{Key: "min_select", Value: group.Min_select},
+				{Key: "max_select", Value: group.Max_select},
+				{Key: "options", Value: group.Options},
+				{Key: "updated_at", Value: group.Updated_at},
+			}}},
+		)
+		if err != nil {
+			apierror.Render(c, apierror.Internal("Update failed: "+err.Error()))
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Modifier group updated", "data": group})
+	}
+}
+
+// DeleteModifierGroup removes a group no food offers any more.
+func DeleteModifierGroup() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		groupId := c.Param("modifier_group_id")
+		inUse, err := foodCollection().CountDocuments(ctx, bson.M{"modifier_groups": groupId})
+		if err != nil {
+			apierror.Render(c, apierror.Internal("error occurred while checking the group's foods"))
+			return
+		}
+		if inUse > 0 {
+			apierror.Render(c, apierror.Conflict("Detach the modifier group from its foods first").With("foods", inUse))
+			return
+		}
+
+		result, err := modifierGroupCollection().DeleteOne(ctx, bson.M{"modifier_group_id": groupId})
+		if err != nil {
+			apierror.Render(c, apierror.Internal("Delete failed: "+err.Error()))
+			return
+		}
+		if result.DeletedCount == 0 {
+			apierror.Render(c, apierror.NotFound("Modifier group not found"))
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Modifier group deleted"})
+	}
+}
+
+// prepareModifierGroup gives new options their ids, rounds their prices and
+// checks the selection limits against the options offered.
+func prepareModifierGroup(group *models.ModifierGroup) error {
+	names := map[string]bool{}
+	for i := range group.Options {
+		name := strings.ToLower(strings.TrimSpace(*group.Options[i].Name))
+		if names[name] {
+			return fmt.Errorf("option %s is listed twice", *group.Options[i].Name)
+		}
+		names[name] = true
+		if group.Options[i].Modifier_option_id == "" {
+			group.Options[i].Modifier_option_id = primitive.NewObjectID().Hex()
+		}
+		if group.Options[i].Price_delta != nil {
+			delta := toFixed(*group.Options[i].Price_delta, 2)
+			group.Options[i].Price_delta = &delta
+		}
+	}
+
+	minSelect, maxSelect := modifierLimits(*group)
+	if maxSelect > len(group.Options) {
+		return fmt.Errorf("max_select cannot exceed the %d options offered", len(group.Options))
+	}
+	if minSelect > maxSelect {
+		return fmt.Errorf("min_select cannot exceed max_select")
+	}
+	return nil
+}
+
+// modifierLimits returns how few and how many options of a group may be
+// picked. Without limits a group is optional and allows every option.
+func modifierLimits(group models.ModifierGroup) (int, int) {
+	minSelect, maxSelect := 0, len(group.Options)
+	if group.Min_select != nil {
+		minSelect = *group.Min_select
+	}
+	if group.Max_select != nil {
+		maxSelect = *group.Max_select
+	}
+	return minSelect, maxSelect
+}
+
+func modifierGroupsById(ctx context.Context, groupIds []string) (map[string]models.ModifierGroup, error) {
+	byId := map[string]models.ModifierGroup{}
+	if len(groupIds) == 0 {
+		return byId, nil
+	}
+	cursor, err := modifierGroupCollection().Find(ctx, bson.M{"modifier_group_id": bson.M{"$in": groupIds}})
+	if err != nil {
+		return nil, err
+	}
+	var groups []models.ModifierGroup
+	if err = cursor.All(ctx, &groups); err != nil {
+		return nil, err
+	}
+	for _, group := range groups {
+		byId[group.Modifier_group_id] = group
+	}
+	return byId, nil
+}
+
+// modifierGroupsOf loads the modifier groups offered on foods.
+func modifierGroupsOf(ctx context.Context, foods map[string]models.Food) (map[string]models.ModifierGroup, error) {
+	groupIds := []string{}
+	for _, food := range foods {
+		groupIds = append(groupIds, food.Modifier_groups...)
+	}
+	return modifierGroupsById(ctx, groupIds)
+}
+
+// modifierAdjustments checks the modifiers picked for an order item against
+// the groups its food offers and prices them. Every group must get between
+// its min_select and max_select options.
+func modifierAdjustments(groups map[string]models.ModifierGroup, food models.Food, orderItem models.OrderItem) ([]models.PriceAdjustment, error) {
+	picked := map[string]bool{}
+	for _, id := range orderItem.Modifiers {
+		picked[id] = true
+	}
+
+	adjustments := []models.PriceAdjustment{}
+	for _, groupId := range food.Modifier_groups {
+		group, ok := groups[groupId]
+		if !ok {
+			continue
+		}
+		count := 0
+		for _, option := range group.Options {
+			if !picked[option.Modifier_option_id] {
+				continue
+			}
+			delete(picked, option.Modifier_option_id)
+			count++
+
+			delta := 0.0
+			if option.Price_delta != nil {
+				delta = toFixed(*option.Price_delta, 2)
+			}
+			adjustments = append(adjustments, models.PriceAdjustment{
+				Type:        "MODIFIER",
+				Source_id:   option.Modifier_option_id,
+				Description: *group.Name + ": " + *option.Name,
+				Amount:      delta,
+			})
+		}
+
+		minSelect, maxSelect := modifierLimits(group)
+		if count < minSelect {
+			return nil, fmt.Errorf("pick at least %d of %s for %s", minSelect, *group.Name, stringOr(food.Name, food.Food_id))
+		}
+		if count > maxSelect {
+			return nil, fmt.Errorf("pick at most %d of %s for %s", maxSelect, *group.Name, stringOr(food.Name, food.Food_id))
+		}
+	}
+	for id := range picked {
+		return nil, fmt.Errorf("modifier %s is not offered for %s", id, stringOr(food.Name, food.Food_id))
+	}
+	return adjustments, nil
+}
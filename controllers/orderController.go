@@ -3,34 +3,37 @@ package controllers
 import (
 	"context"
 	"fmt"
+	"log"
 	"net/http"
-	"restaurant-management/database"
+	"restaurant-management/apierror"
+	"restaurant-management/config"
+	"restaurant-management/extensions"
 	"restaurant-management/models"
+	"restaurant-management/realtime"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
-	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
-var orderCollection *mongo.Collection = database.OpenCollection(database.Client, "order")
+var orderCollection = db.Collection("order")
 
 func GetOrders() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
 		defer cancel()
 
-		result, err := orderCollection.Find(ctx, bson.M{})
+		result, err := orderCollection().Find(ctx, liveFilter(c, bson.M{}))
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occurred while listing orders: " + err.Error()})
+			apierror.Render(c, apierror.Internal("error occurred while listing orders: "+err.Error()))
 			return
 		}
 
 		var allOrders []bson.M
 		if err = result.All(ctx, &allOrders); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "error decoding orders: " + err.Error()})
+			apierror.Render(c, apierror.Internal("error decoding orders: "+err.Error()))
 			return
 		}
 
@@ -42,7 +45,7 @@ func GetOrders() gin.HandlerFunc {
 func GetOrder() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		// Create a context with a timeout of 100 seconds
-		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
 		defer cancel() // Ensures context is canceled after function execution
 
 		// Extract the "order_id" parameter from the request URL
@@ -52,10 +55,10 @@ func GetOrder() gin.HandlerFunc {
 		var order models.Order
 
 		//Query the MongoDB collection to find the order item by its ID
-		err := orderCollection.FindOne(ctx, bson.M{"order_id": orderId}).Decode(&order)
+		err := orderCollection().FindOne(ctx, liveFilter(c, bson.M{"order_id": orderId})).Decode(&order)
 		if err != nil {
 			//Handle the error if the order item is not found
-			c.JSON(http.StatusNotFound, gin.H{"error": "order item not found"})
+			apierror.Render(c, apierror.NotFound("order item not found"))
 			return
 		}
 
@@ -67,27 +70,50 @@ func GetOrder() gin.HandlerFunc {
 
 func CreateOrder() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
 		defer cancel()
 
 		var table models.Table
 		var order models.Order
 
 		if err := c.BindJSON(&order); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data: " + err.Error()})
+			apierror.Render(c, apierror.BadRequest("Invalid request data: "+err.Error()))
 			return
 		}
 
 		validationErr := validate.Struct(order)
 
 		if validationErr != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": validationErr.Error()})
+			apierror.Render(c, apierror.FromValidation(validationErr))
 			return
 		}
 
-		err := tableCollection.FindOne(ctx, bson.M{"table_id": order.Table_id}).Decode(&table)
+		err := tableCollection().FindOne(ctx, bson.M{"table_id": order.Table_id, "deleted_at": notDeleted}).Decode(&table)
 		if err != nil {
-			c.JSON(http.StatusNotFound, gin.H{"error": "Table not found"})
+			apierror.Render(c, apierror.NotFound("Table not found"))
+			return
+		}
+
+		if order.Channel == nil {
+			channel := defaultChannel
+			order.Channel = &channel
+		}
+
+		fees, err := demandFees(ctx, *order.Channel, time.Now())
+		if err != nil {
+			apierror.Render(c, apierror.Internal("error occurred while pricing order: "+err.Error()))
+			return
+		}
+		order.Fees = fees
+		order.Status = "OPEN"
+		order.Opened_by = staffId(c)
+		order.Merged_into = nil
+		// Discounts are added through their own endpoint so they get checked
+		order.Discounts = nil
+		order.Priced_at = nil
+
+		if err := extensions.BeforeOrder(ctx, &order, nil); err != nil {
+			apierror.Render(c, apierror.Unprocessable(err.Error()))
 			return
 		}
 
@@ -97,11 +123,20 @@ func CreateOrder() gin.HandlerFunc {
 		order.ID = primitive.NewObjectID()
 		order.Order_id = order.ID.Hex()
 
-		result, insertErr := orderCollection.InsertOne(ctx, order)
+		result, insertErr := orderCollection().InsertOne(ctx, order)
 		if insertErr != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Could not create order item"})
+			apierror.Render(c, apierror.Internal("Could not create order item"))
 			return
 		}
+		auditCreated(c, order.Order_id)
+		if err := occupyTable(ctx, order); err != nil {
+			log.Println("Error occupying table:", err)
+		}
+		extensions.AfterOrder(ctx, order, nil)
+		realtime.Publish(realtime.Event{Type: "order.created", Order_id: order.Order_id, Data: order})
+		if err := queueWebhookEvent(ctx, "order.created", order); err != nil {
+			log.Println("Error queueing webhooks:", err)
+		}
 
 		// Return success response
 		c.JSON(http.StatusCreated, gin.H{"message": "order item created", "data": result})
@@ -111,7 +146,7 @@ func CreateOrder() gin.HandlerFunc {
 
 func UpdateOrder() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
 		defer cancel()
 
 		var table models.Table
@@ -120,21 +155,43 @@ func UpdateOrder() gin.HandlerFunc {
 		orderId := c.Param("order_id")
 
 		if err := c.BindJSON(&order); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data: " + err.Error()})
+			apierror.Render(c, apierror.BadRequest("Invalid request data: "+err.Error()))
 			return
 		}
 
 		var updateObj primitive.D
 		if order.Table_id != nil {
-			err := tableCollection.FindOne(ctx, bson.M{"table_id": order.Table_id}).Decode(&table)
+			err := tableCollection().FindOne(ctx, bson.M{"table_id": order.Table_id}).Decode(&table)
 			if err != nil {
 				msg := fmt.Sprintf("message : Order not found")
-				c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+				apierror.Render(c, apierror.Internal(msg))
 				return
 			}
 			updateObj = append(updateObj, bson.E{Key: "table_id", Value: order.Table_id})
 
 		}
+		if order.Server_id != nil {
+			updateObj = append(updateObj, bson.E{Key: "server_id", Value: order.Server_id})
+		}
+		if order.Customer_id != nil {
+			name, email, phone, err := customerContact(ctx, *order.Customer_id)
+			if err != nil {
+				apierror.Render(c, apierror.Internal("error occurred while loading customer: "+err.Error()))
+				return
+			}
+			if name == "" && email == "" && phone == "" {
+				apierror.Render(c, apierror.NotFound("Customer not found"))
+				return
+			}
+			updateObj = append(updateObj, bson.E{Key: "customer_id", Value: order.Customer_id})
+		}
+		if order.Customer_phone != nil {
+			if err := validate.Var(*order.Customer_phone, "min=7,max=20"); err != nil {
+				apierror.Render(c, apierror.Validation("Validation failed: customer_phone must be 7 to 20 characters"))
+				return
+			}
+			updateObj = append(updateObj, bson.E{Key: "customer_phone", Value: order.Customer_phone})
+		}
 
 		order.Updated_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
 		updateObj = append(updateObj, bson.E{Key: "updated_at", Value: order.Updated_at})
@@ -144,7 +201,7 @@ func UpdateOrder() gin.HandlerFunc {
 
 		opt := options.UpdateOptions{Upsert: &upsert}
 
-		result, err := orderCollection.UpdateOne(
+		result, err := orderCollection().UpdateOne(
 			ctx,
 			filter,
 			bson.D{{Key: "$set", Value: updateObj}},
@@ -152,25 +209,95 @@ func UpdateOrder() gin.HandlerFunc {
 		)
 
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Update failed: " + err.Error()})
+			apierror.Render(c, apierror.Internal("Update failed: "+err.Error()))
 			return
 		}
+		changes := gin.H{}
+		for _, field := range updateObj {
+			changes[field.Key] = field.Value
+		}
+		realtime.Publish(realtime.Event{Type: "order.updated", Order_id: orderId, Data: changes})
+		if err := queueWebhookEvent(ctx, "order.updated", gin.H{"order_id": orderId, "changes": changes}); err != nil {
+			log.Println("Error queueing webhooks:", err)
+		}
 
 		c.JSON(http.StatusOK, gin.H{"message": "order item updated successfully", "result": result})
 
 	}
 }
 
-func OrderItemOrderCreator(order models.Order) string {
-	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+// staffId is the caller's user id when they are staff, for attributing what
+// they do; guests get nil.
+func staffId(c *gin.Context) *string {
+	if c.GetString("role") == "CUSTOMER" || c.GetString("uid") == "" {
+		return nil
+	}
+	uid := c.GetString("uid")
+	return &uid
+}
 
+// OrderItemOrderCreator stores a new open order and returns its id. ctx may
+// be a session context, so the order is created in the caller's transaction.
+func OrderItemOrderCreator(ctx context.Context, order models.Order) (string, error) {
 	now := time.Now().Format(time.RFC3339)
 	order.Created_at, _ = time.Parse(time.RFC3339, now)
 	order.Updated_at, _ = time.Parse(time.RFC3339, now)
 	order.ID = primitive.NewObjectID()
 	order.Order_id = order.ID.Hex()
+	order.Status = "OPEN"
+
+	if _, err := orderCollection().InsertOne(ctx, order); err != nil {
+		return "", err
+	}
+	return order.Order_id, nil
+}
+
+// DeleteOrder soft deletes an order, freeing its table when it was the
+// last open check there. Paid orders cannot be deleted. Pending invoices
+// block the delete, unless ?cascade=true, which removes them.
+func DeleteOrder() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		orderId := c.Param("order_id")
+		if err := orderCollection().FindOne(ctx, bson.M{"order_id": orderId, "deleted_at": notDeleted}).Err(); err != nil {
+			apierror.Render(c, apierror.NotFound("order item not found"))
+			return
+		}
+		paid, err := idsOf(ctx, invoiceCollection(), bson.M{"order_id": orderId, "payment_status": "PAID"}, "invoice_id")
+		if err != nil {
+			apierror.Render(c, apierror.Internal("error occurred while checking the order's invoices"))
+			return
+		}
+		if len(paid) > 0 {
+			apierror.Render(c, apierror.Conflict("Paid orders cannot be deleted").With("invoice_ids", paid))
+			return
+		}
+		pending, err := idsOf(ctx, invoiceCollection(), bson.M{"order_id": orderId}, "invoice_id")
+		if err != nil {
+			apierror.Render(c, apierror.Internal("error occurred while checking the order's invoices"))
+			return
+		}
+		if len(pending) > 0 && c.Query("cascade") != "true" {
+			apierror.Render(c, apierror.Conflict("The order has pending invoices; delete them or pass cascade=true").With("invoice_ids", pending))
+			return
+		}
+		if len(pending) > 0 {
+			if _, err := invoiceCollection().DeleteMany(ctx, bson.M{"invoice_id": bson.M{"$in": pending}, "payment_status": bson.M{"$ne": "PAID"}}); err != nil {
+				apierror.Render(c, apierror.Internal("Delete failed: "+err.Error()))
+				return
+			}
+		}
+
+		if _, err := softDelete(ctx, orderCollection(), "order_id", orderId); err != nil {
+			apierror.Render(c, apierror.Internal("Delete failed: "+err.Error()))
+			return
+		}
+		if err := clearTable(ctx, orderId); err != nil {
+			log.Println("Error clearing table of deleted order:", err)
+		}
 
-	orderCollection.InsertOne(ctx, order)
-	defer cancel()
-	return order.Order_id
+		c.JSON(http.StatusOK, gin.H{"message": "Order deleted", "deleted_invoice_ids": pending})
+	}
 }
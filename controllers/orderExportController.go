@@ -0,0 +1,158 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"restaurant-management/apierror"
+	"restaurant-management/config"
+	"restaurant-management/models"
+	"restaurant-management/services"
+	"restaurant-management/spreadsheet"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// orderExportColumns head the export, one row per order item. The order's
+// columns repeat on each of its items; orders without items get one row.
+var orderExportColumns = []interface{}{
+	"order_id", "ordered_at", "status", "channel", "table_number", "server_id",
+	"order_item_id", "food_id", "food", "size", "unit_price",
+	"order_total", "invoice_id", "payment_status", "payment_method",
+}
+
+// exportedOrder is an order with what the export looks up about it.
+type exportedOrder struct {
+	models.Order `bson:",inline"`
+	Table        []models.Table     `bson:"table"`
+	Items        []models.OrderItem `bson:"items"`
+	Invoices     []models.Invoice   `bson:"invoices"`
+	Foods        []models.Food      `bson:"foods"`
+}
+
+// ExportOrders streams the orders placed from ?from= up to and including
+// ?to= (YYYY-MM-DD, the last 7 days by default) with their items, table
+// and invoice, as ?format=csv (the default) or xlsx. Rows are written as
+// they are read from the database, so the export is never held in memory.
+func ExportOrders() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		from, to, err := parseReportRange(c)
+		if err != nil {
+			apierror.Render(c, apierror.BadRequest(err.Error()))
+			return
+		}
+		format := c.DefaultQuery("format", "csv")
+		contentType, ok := spreadsheet.Formats[format]
+		if !ok {
+			apierror.Render(c, apierror.BadRequest("format must be csv or xlsx"))
+			return
+		}
+
+		cursor, err := orderCollection().Aggregate(ctx, mongo.Pipeline{
+			{{Key: "$match", Value: bson.M{"created_at": bson.M{"$gte": from, "$lt": to}, "deleted_at": notDeleted}}},
+			{{Key: "$sort", Value: bson.D{{Key: "created_at", Value: 1}}}},
+			{{Key: "$lookup", Value: bson.M{"from": "table", "localField": "table_id", "foreignField": "table_id", "as": "table"}}},
+			{{Key: "$lookup", Value: bson.M{"from": "orderItem", "localField": "order_id", "foreignField": "order_id", "as": "items"}}},
+			{{Key: "$lookup", Value: bson.M{"from": "invoice", "localField": "order_id", "foreignField": "order_id", "as": "invoices"}}},
+			{{Key: "$lookup", Value: bson.M{"from": "food", "localField": "items.food_id", "foreignField": "food_id", "as": "foods"}}},
+		})
+		if err != nil {
+			apierror.Render(c, apierror.Internal("error occurred while exporting orders: "+err.Error()))
+			return
+		}
+		defer cursor.Close(ctx)
+
+		c.Header("Content-Type", contentType)
+		c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=orders-%s-%s.%s", from.Format("20060102"), to.AddDate(0, 0, -1).Format("20060102"), format))
+		writer, err := spreadsheet.New(format, c.Writer, "Orders")
+		if err != nil {
+			apierror.Render(c, apierror.Internal(err.Error()))
+			return
+		}
+
+		// The status is sent with the first row, so a failure from here on
+		// can only cut the file short
+		if err := writer.WriteRow(orderExportColumns...); err != nil {
+			log.Println("Error writing order export:", err)
+			return
+		}
+		for cursor.Next(ctx) {
+			var order exportedOrder
+			if err := cursor.Decode(&order); err != nil {
+				log.Println("Error decoding exported order:", err)
+				return
+			}
+			for _, row := range orderExportRows(order) {
+				if err := writer.WriteRow(row...); err != nil {
+					log.Println("Error writing order export:", err)
+					return
+				}
+			}
+		}
+		if err := cursor.Err(); err != nil {
+			log.Println("Error reading orders to export:", err)
+			return
+		}
+		if err := writer.Close(); err != nil {
+			log.Println("Error finishing order export:", err)
+		}
+	}
+}
+
+// orderExportRows lays an order out as orderExportColumns. Its total is
+// what its invoice asks for, or the sum of its items before it has one.
+func orderExportRows(order exportedOrder) [][]interface{} {
+	var tableNumber interface{}
+	if len(order.Table) > 0 && order.Table[0].Table_number != nil {
+		tableNumber = *order.Table[0].Table_number
+	}
+	var invoiceId, paymentStatus, paymentMethod, total interface{}
+	if len(order.Invoices) > 0 {
+		invoice := order.Invoices[len(order.Invoices)-1]
+		invoiceId = invoice.Invoice_id
+		paymentStatus = stringValue(invoice.Payment_status)
+		paymentMethod = stringValue(invoice.Payment_method)
+		if invoice.Payment_due != nil {
+			total = *invoice.Payment_due
+		}
+	}
+	if total == nil {
+		sum := 0.0
+		for _, item := range order.Items {
+			if item.Unit_price != nil {
+				sum += *item.Unit_price
+			}
+		}
+		total = services.ToFixed(sum, 2)
+	}
+	foodNames := map[string]string{}
+	for _, food := range order.Foods {
+		foodNames[food.Food_id] = stringValue(food.Name)
+	}
+
+	orderCells := func(item []interface{}) []interface{} {
+		row := []interface{}{
+			order.Order_id, order.Created_at.UTC(), order.Status, stringValue(order.Channel), tableNumber, stringValue(order.Server_id),
+		}
+		row = append(row, item...)
+		return append(row, total, invoiceId, paymentStatus, paymentMethod)
+	}
+	if len(order.Items) == 0 {
+		return [][]interface{}{orderCells(make([]interface{}, 5))}
+	}
+	rows := make([][]interface{}, 0, len(order.Items))
+	for _, item := range order.Items {
+		foodId := stringValue(item.Food_id)
+		var unitPrice interface{}
+		if item.Unit_price != nil {
+			unitPrice = *item.Unit_price
+		}
+		rows = append(rows, orderCells([]interface{}{item.Order_item_id, foodId, foodNames[foodId], stringValue(item.Quantity), unitPrice}))
+	}
+	return rows
+}
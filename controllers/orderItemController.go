@@ -4,8 +4,12 @@ import (
 	"context"
 	"log"
 	"net/http"
+	"restaurant-management/apierror"
+	"restaurant-management/config"
 	"restaurant-management/database"
+	"restaurant-management/extensions"
 	"restaurant-management/models"
+	"restaurant-management/realtime"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -15,21 +19,30 @@ import (
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
+// OrderItemPack is a batch of items for one order. Items are added to
+// Order_id when it is set; otherwise a new order is opened for Table_id.
+// OrderItemPack is a round of items for the order Order_id names, or for
+// a new order. With Join_table_check, a pack without Order_id goes on the
+// table's open check instead if one was opened in the meantime.
 type OrderItemPack struct {
-	Table_id    *string
-	Order_items []models.OrderItem
+	Order_id         *string
+	Table_id         *string
+	Channel          *string
+	Customer_id      *string
+	Order_items      []models.OrderItem
+	Join_table_check bool
 }
 
-var orderItemCollection *mongo.Collection = database.OpenCollection(database.Client, "orderItem")
+var orderItemCollection = db.Collection("orderItem")
 
 func GetOrderItems() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
 		defer cancel()
-		result, err := orderItemCollection.Find(ctx, bson.M{})
+		result, err := orderItemCollection().Find(ctx, bson.M{})
 
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occured while listing ordered items"})
+			apierror.Render(c, apierror.Internal("error occured while listing ordered items"))
 			return
 		}
 		var allOrderItems []bson.M
@@ -48,28 +61,124 @@ func GetOrderItemsByOrder() gin.HandlerFunc {
 		allOrderItems, err := ItemsByOrder(orderId)
 
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occured while listing order items by order ID"})
+			apierror.Render(c, apierror.Internal("error occured while listing order items by order ID"))
 			return
 		}
 		c.JSON(http.StatusOK, allOrderItems)
 	}
 }
 
+// ItemsByOrder returns an order with its table and its items, in the order
+// they were added, each with its food's name and image. The result holds
+// one document, or none when the order has no items.
 func ItemsByOrder(id string) (OrderItem []primitive.M, err error) {
-
+	ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+	defer cancel()
+
+	matchStage := bson.D{{Key: "$match", Value: bson.D{{Key: "order_id", Value: id}}}}
+	sortStage := bson.D{{Key: "$sort", Value: bson.D{{Key: "created_at", Value: 1}}}}
+	lookupFoodStage := bson.D{{Key: "$lookup", Value: bson.D{
+		{Key: "from", Value: "food"},
+		{Key: "localField", Value: "food_id"},
+		{Key: "foreignField", Value: "food_id"},
+		{Key: "as", Value: "food"},
+	}}}
+	unwindFoodStage := bson.D{{Key: "$unwind", Value: bson.D{
+		{Key: "path", Value: "$food"},
+		{Key: "preserveNullAndEmptyArrays", Value: true},
+	}}}
+	lookupOrderStage := bson.D{{Key: "$lookup", Value: bson.D{
+		{Key: "from", Value: "order"},
+		{Key: "localField", Value: "order_id"},
+		{Key: "foreignField", Value: "order_id"},
+		{Key: "as", Value: "order"},
+	}}}
+	unwindOrderStage := bson.D{{Key: "$unwind", Value: bson.D{
+		{Key: "path", Value: "$order"},
+		{Key: "preserveNullAndEmptyArrays", Value: true},
+	}}}
+	lookupTableStage := bson.D{{Key: "$lookup", Value: bson.D{
+		{Key: "from", Value: "table"},
+		{Key: "localField", Value: "order.table_id"},
+		{Key: "foreignField", Value: "table_id"},
+		{Key: "as", Value: "table"},
+	}}}
+	unwindTableStage := bson.D{{Key: "$unwind", Value: bson.D{
+		{Key: "path", Value: "$table"},
+		{Key: "preserveNullAndEmptyArrays", Value: true},
+	}}}
+	lookupComboStage := bson.D{{Key: "$lookup", Value: bson.D{
+		{Key: "from", Value: "combo"},
+		{Key: "localField", Value: "combo_id"},
+		{Key: "foreignField", Value: "combo_id"},
+		{Key: "as", Value: "combo"},
+	}}}
+	unwindComboStage := bson.D{{Key: "$unwind", Value: bson.D{
+		{Key: "path", Value: "$combo"},
+		{Key: "preserveNullAndEmptyArrays", Value: true},
+	}}}
+	groupStage := bson.D{{Key: "$group", Value: bson.D{
+		{Key: "_id", Value: bson.D{
+			{Key: "order_id", Value: "$order_id"},
+			{Key: "table_id", Value: "$order.table_id"},
+			{Key: "table_number", Value: "$table.table_number"},
+		}},
+		{Key: "total_count", Value: bson.D{{Key: "$sum", Value: 1}}},
+		{Key: "order_items", Value: bson.D{{Key: "$push", Value: bson.D{
+			{Key: "order_item_id", Value: "$order_item_id"},
+			{Key: "food_id", Value: "$food_id"},
+			{Key: "food_name", Value: "$food.name"},
+			{Key: "food_image", Value: "$food.food_image"},
+			{Key: "quantity", Value: "$quantity"},
+			{Key: "unit_price", Value: "$unit_price"},
+			{Key: "adjustments", Value: "$adjustments"},
+			{Key: "allergy_note", Value: "$allergy_note"},
+			{Key: "substitutions", Value: "$substitutions"},
+			{Key: "server_id", Value: "$server_id"},
+			{Key: "combo_id", Value: "$combo_id"},
+			{Key: "combo_line_id", Value: "$combo_line_id"},
+			{Key: "combo_name", Value: "$combo.name"},
+			{Key: "created_at", Value: "$created_at"},
+		}}}},
+	}}}
+	projectStage := bson.D{{Key: "$project", Value: bson.D{
+		{Key: "_id", Value: 0},
+		{Key: "order_id", Value: "$_id.order_id"},
+		{Key: "table_id", Value: "$_id.table_id"},
+		{Key: "table_number", Value: "$_id.table_number"},
+		{Key: "total_count", Value: 1},
+		{Key: "order_items", Value: 1},
+	}}}
+
+	result, err := orderItemCollection().Aggregate(ctx, mongo.Pipeline{
+		matchStage, sortStage,
+		lookupFoodStage, unwindFoodStage,
+		lookupOrderStage, unwindOrderStage,
+		lookupTableStage, unwindTableStage,
+		lookupComboStage, unwindComboStage,
+		groupStage, projectStage,
+	})
+	if err != nil {
+		return nil, err
+	}
+	OrderItem = []primitive.M{}
+	if err = result.All(ctx, &OrderItem); err != nil {
+		return nil, err
+	}
+	return OrderItem, nil
 }
 
 func GetOrderItem() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
 		defer cancel()
 
-		orderItemId := c.Param("order_item_id")
+		orderItemId := c.Param("orderItem_id")
 		var orderItem models.OrderItem
 
-		err := orderItemCollection.FindOne(ctx, bson.M{"orderItem_id": orderItemId}).Decode(&orderItem)
+		err := orderItemCollection().FindOne(ctx, bson.M{"order_item_id": orderItemId}).Decode(&orderItem)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "error occured while listing ordered item"})
+			apierror.Render(c, apierror.NotFound("Order item not found"))
 			return
 		}
 		c.JSON(http.StatusOK, orderItem)
@@ -79,98 +188,335 @@ func GetOrderItem() gin.HandlerFunc {
 
 func CreateOrderItem() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
 		defer cancel()
 		var orderItemPack OrderItemPack
-		var order models.Order
 
 		if err := c.BindJSON(&orderItemPack); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			apierror.Render(c, apierror.BadRequest("Invalid request data: "+err.Error()))
 			return
 		}
 
-		order.Order_Date, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		order, orderItems, err := placeOrder(ctx, orderItemPack, staffId(c))
+		if rejection, ok := err.(*apierror.Error); ok {
+			apierror.Render(c, rejection)
+			return
+		}
+		if err != nil {
+			apierror.Render(c, apierror.Internal("Order items were not created: "+err.Error()))
+			return
+		}
 
-		orderItemsToBeInserted := []interface{}{}
+		insertedIds := []primitive.ObjectID{}
+		for _, orderItem := range orderItems {
+			insertedIds = append(insertedIds, orderItem.ID)
+		}
+		c.JSON(http.StatusCreated, gin.H{"order_id": order.Order_id, "InsertedIDs": insertedIds, "order_items": orderItems})
+	}
+}
+
+// placeOrder rings up the pack's items on the order it names, or on a new
+// order opened by openedBy when it names none, pricing them and firing
+// them to the kitchen.
+// Every item is checked before anything is written, and the new order and
+// its items are stored in one transaction, or on a standalone server
+// removed again if storing them fails. Orders the client has to change
+// are rejected with an *apierror.Error; other errors come from storing the
+// items.
+func placeOrder(ctx context.Context, orderItemPack OrderItemPack, openedBy *string) (models.Order, []models.OrderItem, error) {
+	var order models.Order
+	if len(orderItemPack.Order_items) == 0 {
+		return order, nil, apierror.BadRequest("At least one order item is required")
+	}
+
+	expanded, unavailableCombos, err := expandCombos(ctx, orderItemPack.Order_items)
+	if err != nil {
+		return order, nil, apierror.Internal("error occurred while loading combos: " + err.Error())
+	}
+	if len(unavailableCombos) > 0 {
+		return order, nil, apierror.BadRequest("Some combos do not exist or are not on sale").With("combo_ids", unavailableCombos)
+	}
+	orderItemPack.Order_items = expanded
+
+	existing := orderItemPack.Order_id != nil && *orderItemPack.Order_id != ""
+	if existing {
+		order, err = openOrder(ctx, *orderItemPack.Order_id)
+		switch {
+		case err == mongo.ErrNoDocuments:
+			return order, nil, apierror.NotFound("Order not found")
+		case err == errOrderClosed:
+			return order, nil, apierror.Conflict("The order is closed: it was merged or its invoice is paid or finalized")
+		case err != nil:
+			return order, nil, apierror.Internal("error occurred while loading order: " + err.Error())
+		}
+	} else {
+		order.Order_Date, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
 		order.Table_id = orderItemPack.Table_id
-		order_id := OrderItemOrderCreator(order)
+		order.Customer_id = orderItemPack.Customer_id
+		order.Opened_by = openedBy
 
-		for _, orderItem := range orderItemPack.Order_items {
-			orderItem.Order_id = order_id
+		channel := defaultChannel
+		if orderItemPack.Channel != nil {
+			channel = *orderItemPack.Channel
+		}
+		order.Channel = &channel
+		if validationErr := validate.Var(channel, "eq=DINE_IN|eq=TAKEOUT|eq=DELIVERY"); validationErr != nil {
+			return order, nil, apierror.Validation("Channel must be DINE_IN, TAKEOUT or DELIVERY")
+		}
 
-			validationErr := validate.Struct(orderItem)
+		fees, err := demandFees(ctx, channel, time.Now())
+		if err != nil {
+			return order, nil, apierror.Internal("error occurred while pricing order: " + err.Error())
+		}
+		order.Fees = fees
+	}
+	channel := stringOr(order.Channel, defaultChannel)
 
-			if validationErr != nil {
-				c.JSON(http.StatusBadRequest, gin.H{"error": validationErr.Error()})
-				return
-			}
-			orderItem.ID = primitive.NewObjectID()
-			orderItem.Created_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
-			orderItem.Updated_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
-			orderItem.Order_item_id = orderItem.ID.Hex()
-			var num = toFixed(*orderItem.Unit_price, 2)
-			orderItem.Unit_price = &num
-			orderItemsToBeInserted = append(orderItemsToBeInserted, orderItem)
+	violations, err := channelPolicyViolations(ctx, channel, orderItemPack.Order_items)
+	if err != nil {
+		return order, nil, apierror.Internal("error occurred while checking channel policy: " + err.Error())
+	}
+	if len(violations) > 0 {
+		return order, nil, apierror.Unprocessable("Order violates the channel policy").With("violations", violations)
+	}
+
+	foodIds := []string{}
+	for _, orderItem := range orderItemPack.Order_items {
+		foodIds = append(foodIds, stringValue(orderItem.Food_id))
+	}
+	foods, err := foodsById(ctx, foodIds)
+	if err != nil {
+		return order, nil, apierror.Internal("error occurred while loading foods: " + err.Error())
+	}
+	unknown := []string{}
+	for _, foodId := range foodIds {
+		if food, ok := foods[foodId]; !ok || food.Deleted_at != nil {
+			unknown = append(unknown, foodId)
 		}
+	}
+	if len(unknown) > 0 {
+		return order, nil, apierror.BadRequest("Some foods do not exist").With("food_ids", unknown)
+	}
+	modifierGroups, err := modifierGroupsOf(ctx, foods)
+	if err != nil {
+		return order, nil, apierror.Internal("error occurred while loading modifier groups: " + err.Error())
+	}
+
+	// 86ed foods come back with replacements the POS can offer instead
+	unavailable, err := unavailableFoods(ctx, orderItemPack.Order_items, foods, orderLocation(ctx, order))
+	if err != nil {
+		return order, nil, apierror.Internal("error occurred while checking availability: " + err.Error())
+	}
+	if len(unavailable) > 0 {
+		return order, nil, apierror.Conflict("Some foods are sold out and cannot be ordered").With("unavailable", unavailable)
+	}
+
+	if err := extensions.BeforeOrder(ctx, &order, orderItemPack.Order_items); err != nil {
+		return order, nil, apierror.Unprocessable(err.Error())
+	}
 
-		insertedOrderItems, err := orderItemCollection.InsertMany(ctx, orderItemsToBeInserted)
+	fired := []models.OrderItem{}
+	for _, orderItem := range orderItemPack.Order_items {
+		// The order id is only known once the order is stored
+		validationErr := validate.StructExcept(orderItem, "Order_id")
 
+		if validationErr != nil {
+			return order, nil, apierror.FromValidation(validationErr)
+		}
+		orderItem.ID = primitive.NewObjectID()
+		orderItem.Created_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		orderItem.Updated_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		orderItem.Order_item_id = orderItem.ID.Hex()
+		var num = toFixed(*orderItem.Unit_price, 2)
+		orderItem.Unit_price = &num
+
+		// Apply any happy-hour schedule live at order time as a line adjustment
+		adjustments, err := priceScheduleAdjustments(ctx, orderItem, time.Now())
 		if err != nil {
-			log.Fatal(err)
+			return order, nil, apierror.Internal("error occurred while pricing order item: " + err.Error())
+		}
+		surge, err := surgeAdjustments(ctx, orderItem, channel, time.Now())
+		if err != nil {
+			return order, nil, apierror.Internal("error occurred while pricing order item: " + err.Error())
+		}
+		substitutions, err := substitutionAdjustments(foods[*orderItem.Food_id], orderItem)
+		if err != nil {
+			return order, nil, apierror.BadRequest(err.Error())
+		}
+		// Combos come as they are, without the choices of their foods
+		modifiers := []models.PriceAdjustment{}
+		if orderItem.Combo_line_id == "" {
+			modifiers, err = modifierAdjustments(modifierGroups, foods[*orderItem.Food_id], orderItem)
+			if err != nil {
+				return order, nil, apierror.BadRequest(err.Error())
+			}
+		}
+		orderItem.Adjustments = append(append(append(modifiers, substitutions...), adjustments...), surge...)
+		fired = append(fired, orderItem)
+	}
+
+	newOrder := order
+	joined := false
+	err = database.WithTransaction(ctx, db.Client(), func(txCtx context.Context) error {
+		order, joined = newOrder, false
+		if !existing && orderItemPack.Join_table_check && order.Table_id != nil {
+			// Writing the table makes concurrent first rounds at it conflict,
+			// so the one that is retried finds the check the other opened.
+			// A standalone server has no transactions to conflict.
+			if _, err := tableCollection().UpdateOne(txCtx, bson.M{"table_id": *order.Table_id}, bson.D{{Key: "$set", Value: bson.D{{Key: "updated_at", Value: time.Now()}}}}); err != nil {
+				return err
+			}
+			open, err := tableOpenOrder(txCtx, *order.Table_id)
+			switch {
+			case err == nil:
+				order, joined = open, true
+			case err != mongo.ErrNoDocuments:
+				return err
+			}
+		}
+		if !existing && !joined {
+			orderId, err := OrderItemOrderCreator(txCtx, order)
+			if err != nil {
+				return err
+			}
+			order.Order_id = orderId
 		}
 
-		c.JSON(http.StatusOK, insertedOrderItems)
+		orderItemsToBeInserted := []interface{}{}
+		for i := range fired {
+			fired[i].Order_id = order.Order_id
+			orderItemsToBeInserted = append(orderItemsToBeInserted, fired[i])
+		}
+		_, err := orderItemCollection().InsertMany(txCtx, orderItemsToBeInserted)
+		return err
+	})
+	existing = existing || joined
+	if err != nil {
+		// Without a transaction the order and some of its items may have
+		// been stored before the failure
+		if !database.SupportsTransactions(ctx, db.Client()) {
+			undoPlacedOrder(ctx, order, existing, fired)
+		}
+		return order, nil, err
+	}
+
+	if !existing {
+		if err := occupyTable(ctx, order); err != nil {
+			log.Println("Error occupying table:", err)
+		}
+	}
+	if err := fireKitchenTickets(ctx, order, fired); err != nil {
+		log.Println("Error firing kitchen tickets:", err)
+	}
+	if err := depleteInventory(ctx, order, fired); err != nil {
+		log.Println("Error depleting inventory:", err)
+	}
+	extensions.AfterOrder(ctx, order, fired)
+	if !existing {
+		realtime.Publish(realtime.Event{Type: "order.created", Order_id: order.Order_id, Data: order})
+		if err := queueWebhookEvent(ctx, "order.created", order); err != nil {
+			log.Println("Error queueing webhooks:", err)
+		}
+	}
+	realtime.Publish(realtime.Event{Type: "orderItems.created", Order_id: order.Order_id, Data: fired})
+	return order, fired, nil
+}
+
+// undoPlacedOrder removes what placeOrder stored before failing on a
+// server without transactions: the items it fired and, unless it added
+// to an existing order, the order.
+func undoPlacedOrder(ctx context.Context, order models.Order, existing bool, fired []models.OrderItem) {
+	orderItemIds := []string{}
+	for _, orderItem := range fired {
+		orderItemIds = append(orderItemIds, orderItem.Order_item_id)
+	}
+	if _, err := orderItemCollection().DeleteMany(ctx, bson.M{"order_item_id": bson.M{"$in": orderItemIds}}); err != nil {
+		log.Println("Error removing order items of failed order:", err)
+	}
+	if !existing && order.Order_id != "" {
+		if _, err := orderCollection().DeleteOne(ctx, bson.M{"order_id": order.Order_id}); err != nil {
+			log.Println("Error removing failed order:", err)
+		}
 	}
 }
 
 func UpdateOrderItem() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Second)
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
 		defer cancel()
 
 		var orderItem models.OrderItem
 
-		orderItemId := c.Param("order_item_id")
+		if err := c.BindJSON(&orderItem); err != nil {
+			apierror.Render(c, apierror.BadRequest("Invalid request data: "+err.Error()))
+			return
+		}
+
+		orderItemId := c.Param("orderItem_id")
 
 		filter := bson.M{"order_item_id": orderItemId}
 
 		var updateObj primitive.D
 
 		if orderItem.Unit_price != nil {
-			updateObj = append(updateObj, bson.E{Key: "unit_price", Value: *&orderItem.Unit_price})
+			updateObj = append(updateObj, bson.E{Key: "unit_price", Value: toFixed(*orderItem.Unit_price, 2)})
 		}
 
 		if orderItem.Quantity != nil {
+			if err := validate.Var(*orderItem.Quantity, "eq=S|eq=M|eq=L"); err != nil {
+				apierror.Render(c, apierror.Validation("Validation failed: quantity must be S, M or L"))
+				return
+			}
 			updateObj = append(updateObj, bson.E{Key: "quantity", Value: *orderItem.Quantity})
 		}
 
 		if orderItem.Food_id != nil {
+			count, err := foodCollection().CountDocuments(ctx, bson.M{"food_id": *orderItem.Food_id})
+			if err != nil {
+				apierror.Render(c, apierror.Internal("error occurred while checking food: "+err.Error()))
+				return
+			}
+			if count == 0 {
+				apierror.Render(c, apierror.BadRequest("Food item not found"))
+				return
+			}
 			updateObj = append(updateObj, bson.E{Key: "food_id", Value: *orderItem.Food_id})
 		}
 
 		orderItem.Updated_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
 		updateObj = append(updateObj, bson.E{Key: "updated_at", Value: orderItem.Updated_at})
 
-		upsert := true
-		opt := options.UpdateOptions{
-			Upsert: &upsert,
-		}
-
-		result, err := orderItemCollection.UpdateOne(
+		result, err := orderItemCollection().UpdateOne(
 			ctx,
 			filter,
 			bson.D{
 				{Key: "$set", Value: updateObj},
 			},
-			&opt,
 		)
 
 		if err != nil {
 			msg := "Order item update failed"
-			c.JSON(http.StatusInternalServerError, gin.H{"error": msg})
+			apierror.Render(c, apierror.Internal(msg))
+			return
+		}
+		if result.MatchedCount == 0 {
+			apierror.Render(c, apierror.NotFound("Order item not found"))
 			return
 		}
 
 		c.JSON(http.StatusOK, result)
 	}
 }
+
+// orderItemsOf returns the items of an order in the order they were added.
+func orderItemsOf(ctx context.Context, orderId string) ([]models.OrderItem, error) {
+	cursor, err := orderItemCollection().Find(ctx, bson.M{"order_id": orderId}, options.Find().SetSort(bson.D{{Key: "created_at", Value: 1}}))
+	if err != nil {
+		return nil, err
+	}
+	var orderItems []models.OrderItem
+	if err = cursor.All(ctx, &orderItems); err != nil {
+		return nil, err
+	}
+	return orderItems, nil
+}
@@ -0,0 +1,308 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"restaurant-management/apierror"
+	"restaurant-management/config"
+	"restaurant-management/database"
+	"restaurant-management/models"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// errSplitUnbalanced is returned when the parts of a split do not add up to
+// the order's total.
+var errSplitUnbalanced = errors.New("split invoices do not add up to the order total")
+
+// SplitOrder bills a check on several invoices, one per group of order items
+// or parts equal shares. Every item must be billed exactly once, and the
+// invoices add up to the order's total: fees are shared in proportion to
+// each group's items and tax is worked out per invoice. The discounts are
+// stored on the items, so the shares no longer change, and the order takes
+// no more items.
+func SplitOrder() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		var body models.OrderSplit
+		if err := c.BindJSON(&body); err != nil {
+			apierror.Render(c, apierror.BadRequest("Invalid request data: "+err.Error()))
+			return
+		}
+		if err := validate.Struct(body); err != nil {
+			apierror.Render(c, apierror.FromValidation(err))
+			return
+		}
+
+		order, err := openOrder(ctx, c.Param("order_id"))
+		if !respondOrderError(c, err) {
+			return
+		}
+		existing, err := invoiceCollection().CountDocuments(ctx, bson.M{"order_id": order.Order_id})
+		if err != nil {
+			apierror.Render(c, apierror.Internal("error occurred while checking for an invoice: "+err.Error()))
+			return
+		}
+		if existing > 0 {
+			apierror.Render(c, apierror.Conflict("The order already has an invoice"))
+			return
+		}
+
+		orderItems, err := orderItemsOf(ctx, order.Order_id)
+		if err != nil {
+			apierror.Render(c, apierror.Internal("error occurred while listing order items: "+err.Error()))
+			return
+		}
+		if len(orderItems) == 0 {
+			apierror.Render(c, apierror.BadRequest("The order has no items to bill"))
+			return
+		}
+		priced, pricing, err := priceOrder(ctx, order, orderItems)
+		if err != nil {
+			apierror.Render(c, apierror.Internal("error occurred while pricing order: "+err.Error()))
+			return
+		}
+		if pricing.Total <= 0 {
+			apierror.Render(c, apierror.BadRequest("Order has nothing to pay"))
+			return
+		}
+
+		var parts []models.InvoiceSplit
+		var lines [][]models.TaxLine
+		if body.Parts != nil {
+			parts, lines = evenSplit(pricing, *body.Parts)
+		} else {
+			var apiErr *apierror.Error
+			if parts, lines, apiErr = itemSplit(ctx, order, priced, pricing, body.Groups); apiErr != nil {
+				apierror.Render(c, apiErr)
+				return
+			}
+		}
+		dues, err := splitDues(pricing.Total, lines)
+		if err != nil {
+			apierror.Render(c, apierror.Internal(err.Error()))
+			return
+		}
+
+		now, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		dueDate, _ := time.Parse(time.RFC3339, time.Now().AddDate(0, 0, 1).Format(time.RFC3339))
+		location := orderLocation(ctx, order)
+		status := "PENDING"
+		invoices := make([]models.Invoice, len(parts))
+		for i := range parts {
+			invoices[i] = models.Invoice{
+				ID:               primitive.NewObjectID(),
+				Order_id:         order.Order_id,
+				Payment_status:   &status,
+				Payment_due:      &dues[i],
+				Payment_due_date: dueDate,
+				Location:         &location,
+				Tax_lines:        lines[i],
+				Split:            &parts[i],
+				Created_at:       now,
+				Updated_at:       now,
+			}
+			invoices[i].Invoice_id = invoices[i].ID.Hex()
+		}
+
+		claimed := false
+		err = database.WithTransaction(ctx, db.Client(), func(txCtx context.Context) error {
+			// Claiming the order makes a concurrent split or finalization
+			// of the same order conflict with this one
+			result, err := orderCollection().UpdateOne(txCtx, bson.M{"order_id": order.Order_id, "priced_at": nil}, bson.D{{Key: "$set", Value: bson.D{{Key: "updated_at", Value: now}}}})
+			if err != nil {
+				return err
+			}
+			if result.MatchedCount == 0 {
+				return errOrderClosed
+			}
+			claimed = true
+			if err := storeOrderPricing(txCtx, order, priced); err != nil {
+				return err
+			}
+			for _, invoice := range invoices {
+				if _, err := invoiceCollection().InsertOne(txCtx, invoice); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err == errOrderClosed {
+			respondOrderError(c, err)
+			return
+		}
+		if err != nil {
+			// Without a transaction the order may have been priced and some
+			// of its invoices stored before the failure
+			if claimed && !database.SupportsTransactions(ctx, db.Client()) {
+				undoSplit(ctx, order, invoices)
+			}
+			apierror.Render(c, apierror.Internal("Split failed: "+err.Error()))
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Order split", "order_id": order.Order_id, "total": pricing.Total, "data": invoices})
+	}
+}
+
+// undoSplit removes what SplitOrder stored before failing on a server
+// without transactions: its invoices and the order's pricing date. The
+// discounts stored on the items are what pricing gives anyway.
+func undoSplit(ctx context.Context, order models.Order, invoices []models.Invoice) {
+	invoiceIds := []string{}
+	for _, invoice := range invoices {
+		invoiceIds = append(invoiceIds, invoice.Invoice_id)
+	}
+	if _, err := invoiceCollection().DeleteMany(ctx, bson.M{"invoice_id": bson.M{"$in": invoiceIds}}); err != nil {
+		log.Println("Error removing invoices of failed split:", err)
+	}
+	if _, err := orderCollection().UpdateOne(ctx, bson.M{"order_id": order.Order_id}, bson.M{"$set": bson.M{"priced_at": nil}}); err != nil {
+		log.Println("Error reopening order of failed split:", err)
+	}
+}
+
+// evenSplit shares every tax line of an order equally over parts invoices.
+func evenSplit(pricing models.OrderPricing, parts int) ([]models.InvoiceSplit, [][]models.TaxLine) {
+	splits := make([]models.InvoiceSplit, parts)
+	lines := make([][]models.TaxLine, parts)
+	for i := range splits {
+		splits[i] = models.InvoiceSplit{Part: i + 1, Parts: parts, Order_item_ids: []string{}}
+		lines[i] = []models.TaxLine{}
+	}
+	even := make([]float64, parts)
+	for _, line := range pricing.Tax_lines {
+		nets := splitAmount(line.Net, even)
+		taxes := splitAmount(line.Tax, even)
+		grosses := splitAmount(line.Gross, even)
+		for i := range lines {
+			share := line
+			share.Net, share.Tax, share.Gross = nets[i], taxes[i], grosses[i]
+			lines[i] = append(lines[i], share)
+		}
+	}
+	return splits, lines
+}
+
+// itemSplit checks that groups hold every priced item of the order exactly
+// once, keeping the items of a combo together, and works out the tax of
+// each group with its share of the order's fees.
+func itemSplit(ctx context.Context, order models.Order, priced []models.OrderItem, pricing models.OrderPricing, groups [][]string) ([]models.InvoiceSplit, [][]models.TaxLine, *apierror.Error) {
+	byId := map[string]models.OrderItem{}
+	for _, orderItem := range priced {
+		byId[orderItem.Order_item_id] = orderItem
+	}
+
+	splits := make([]models.InvoiceSplit, len(groups))
+	items := make([][]models.OrderItem, len(groups))
+	weights := make([]float64, len(groups))
+	partOf := map[string]int{}
+	comboPart := map[string]int{}
+	for g, ids := range groups {
+		splits[g] = models.InvoiceSplit{Part: g + 1, Parts: len(groups), Order_item_ids: ids}
+		for _, id := range ids {
+			orderItem, ok := byId[id]
+			if !ok {
+				return nil, nil, apierror.BadRequest("Order item " + id + " is not on the order")
+			}
+			if _, ok := partOf[id]; ok {
+				return nil, nil, apierror.BadRequest("Order item " + id + " is in more than one group")
+			}
+			partOf[id] = g
+			if orderItem.Combo_line_id != "" {
+				if part, ok := comboPart[orderItem.Combo_line_id]; ok && part != g {
+					return nil, nil, apierror.BadRequest("The items of a combo must be in the same group")
+				}
+				comboPart[orderItem.Combo_line_id] = g
+			}
+			items[g] = append(items[g], orderItem)
+			if orderItem.Unit_price != nil {
+				weights[g] += *orderItem.Unit_price
+			}
+			for _, adjustment := range orderItem.Adjustments {
+				weights[g] += adjustment.Amount
+			}
+		}
+	}
+	missing := []string{}
+	for _, orderItem := range priced {
+		if _, ok := partOf[orderItem.Order_item_id]; !ok {
+			missing = append(missing, orderItem.Order_item_id)
+		}
+	}
+	if len(missing) > 0 {
+		return nil, nil, apierror.BadRequest("Every order item must be in a group; missing " + strings.Join(missing, ", "))
+	}
+
+	fees := make([][]models.PriceAdjustment, len(groups))
+	for _, fee := range pricing.Fees {
+		for g, amount := range splitAmount(fee.Amount, weights) {
+			share := fee
+			share.Amount = amount
+			fees[g] = append(fees[g], share)
+		}
+	}
+
+	rateFor, err := currentTaxRates(ctx, orderLocation(ctx, order))
+	if err != nil {
+		return nil, nil, apierror.Internal("error occurred while loading tax rates: " + err.Error())
+	}
+	lines := make([][]models.TaxLine, len(groups))
+	for g := range groups {
+		if lines[g], err = taxLines(ctx, items[g], fees[g], rateFor); err != nil {
+			return nil, nil, apierror.Internal("error occurred while working out tax: " + err.Error())
+		}
+	}
+	return splits, lines, nil
+}
+
+// splitDues shares total over the invoices of a split in proportion to the
+// gross of their tax lines, so they add up to the cent whatever the
+// rounding of each invoice's tax.
+func splitDues(total float64, lines [][]models.TaxLine) ([]float64, error) {
+	weights := make([]float64, len(lines))
+	for i := range lines {
+		weights[i] = taxLinesGross(lines[i])
+	}
+	dues := splitAmount(total, weights)
+	sum := 0.0
+	for _, due := range dues {
+		if due < 0 {
+			return nil, errSplitUnbalanced
+		}
+		sum += due
+	}
+	if toFixed(sum, 2) != toFixed(total, 2) {
+		return nil, errSplitUnbalanced
+	}
+	return dues, nil
+}
+
+// splitOrderLines keeps the items, as listed by ItemsByOrder, that the part
+// of a split by items bills. Other invoices list every item.
+func splitOrderLines(orderItems interface{}, split *models.InvoiceSplit) interface{} {
+	items, ok := orderItems.(primitive.A)
+	if !ok || split == nil || len(split.Order_item_ids) == 0 {
+		return orderItems
+	}
+	billed := map[string]bool{}
+	for _, id := range split.Order_item_ids {
+		billed[id] = true
+	}
+	lines := primitive.A{}
+	for _, entry := range items {
+		if item, ok := entry.(primitive.M); ok {
+			if id, _ := item["order_item_id"].(string); billed[id] {
+				lines = append(lines, entry)
+			}
+		}
+	}
+	return lines
+}
@@ -0,0 +1,141 @@
+package controllers
+
+import (
+	"math"
+	"testing"
+
+	"restaurant-management/models"
+)
+
+// cents sums amounts in whole cents, so totals compare exactly.
+func cents(amounts []float64) int {
+	sum := 0
+	for _, amount := range amounts {
+		sum += int(math.Round(amount * 100))
+	}
+	return sum
+}
+
+func TestSplitAmount(t *testing.T) {
+	tests := []struct {
+		name    string
+		amount  float64
+		weights []float64
+		want    []float64
+	}{
+		{"even thirds leave the rounding on the last share", 10, []float64{0, 0, 0}, []float64{3.33, 3.33, 3.34}},
+		{"proportional", 30, []float64{1, 2}, []float64{10, 20}},
+		{"proportional with rounding", 10, []float64{1, 1, 1}, []float64{3.33, 3.33, 3.34}},
+		{"single share takes everything", 12.34, []float64{5}, []float64{12.34}},
+		{"negative amount", -9.99, []float64{0, 0}, []float64{-5, -4.99}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := splitAmount(tt.amount, tt.weights)
+			if len(got) != len(tt.want) {
+				t.Fatalf("splitAmount() = %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Fatalf("splitAmount() = %v, want %v", got, tt.want)
+				}
+			}
+			if cents(got) != int(math.Round(tt.amount*100)) {
+				t.Errorf("shares %v do not add up to %v", got, tt.amount)
+			}
+		})
+	}
+}
+
+func TestAllocateCents(t *testing.T) {
+	tests := []struct {
+		name    string
+		amount  float64
+		weights []float64
+		want    []float64
+	}{
+		{"leftover cents go to the largest remainders", 10, []float64{1, 1, 1}, []float64{3.34, 3.33, 3.33}},
+		{"proportional", 7.5, []float64{2, 1}, []float64{5, 2.5}},
+		{"largest remainder wins over earlier entries", 1, []float64{1, 2}, []float64{0.33, 0.67}},
+		{"zero weights allocate nothing", 5, []float64{0, 0}, []float64{0, 0}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := allocateCents(tt.amount, tt.weights)
+			if len(got) != len(tt.want) {
+				t.Fatalf("allocateCents() = %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Fatalf("allocateCents() = %v, want %v", got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestEvenSplit(t *testing.T) {
+	pricing := models.OrderPricing{
+		Tax_lines: []models.TaxLine{
+			{Category: "FOOD", Rate: 0.1, Net: 100, Tax: 10, Gross: 110},
+			{Category: "ALCOHOL", Rate: 0.2, Net: 20.01, Tax: 4, Gross: 24.01},
+		},
+		Total: 134.01,
+	}
+	splits, lines := evenSplit(pricing, 3)
+	if len(splits) != 3 || len(lines) != 3 {
+		t.Fatalf("got %d splits and %d line sets, want 3", len(splits), len(lines))
+	}
+	for i, split := range splits {
+		if split.Part != i+1 || split.Parts != 3 {
+			t.Errorf("split %d is part %d of %d", i, split.Part, split.Parts)
+		}
+	}
+	for l, line := range pricing.Tax_lines {
+		var nets, taxes, grosses []float64
+		for i := range lines {
+			share := lines[i][l]
+			if share.Category != line.Category || share.Rate != line.Rate {
+				t.Errorf("part %d line %d is %s at %v, want %s at %v", i+1, l, share.Category, share.Rate, line.Category, line.Rate)
+			}
+			nets = append(nets, share.Net)
+			taxes = append(taxes, share.Tax)
+			grosses = append(grosses, share.Gross)
+		}
+		if cents(nets) != int(math.Round(line.Net*100)) || cents(taxes) != int(math.Round(line.Tax*100)) || cents(grosses) != int(math.Round(line.Gross*100)) {
+			t.Errorf("shares of %s do not add up: net %v, tax %v, gross %v", line.Category, nets, taxes, grosses)
+		}
+	}
+
+	dues, err := splitDues(pricing.Total, lines)
+	if err != nil {
+		t.Fatalf("splitDues() error = %v", err)
+	}
+	if cents(dues) != 13401 {
+		t.Errorf("dues %v do not add up to %v", dues, pricing.Total)
+	}
+}
+
+func TestSplitDues(t *testing.T) {
+	lines := [][]models.TaxLine{
+		{{Gross: 33.33}},
+		{{Gross: 33.33}, {Gross: 10}},
+		{{Gross: 23.35}},
+	}
+	dues, err := splitDues(100, lines)
+	if err != nil {
+		t.Fatalf("splitDues() error = %v", err)
+	}
+	if cents(dues) != 10000 {
+		t.Errorf("dues %v do not add up to 100", dues)
+	}
+	if dues[1] != 43.33 {
+		t.Errorf("second due = %v, want 43.33", dues[1])
+	}
+
+	// A credit line worth more than its invoice's share would leave that
+	// invoice with a negative due
+	if _, err := splitDues(10, [][]models.TaxLine{{{Gross: 20}}, {{Gross: -15}}}); err != errSplitUnbalanced {
+		t.Errorf("splitDues() with a negative share: error = %v, want errSplitUnbalanced", err)
+	}
+}
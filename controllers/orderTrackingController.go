@@ -0,0 +1,193 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"restaurant-management/apierror"
+	"restaurant-management/config"
+	"restaurant-management/models"
+	"restaurant-management/notifications/push"
+	"restaurant-management/notifications/sms"
+	"restaurant-management/realtime"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// orderEventsHeartbeat is how often an idle order stream sends a comment,
+// so that proxies keep the connection open.
+const orderEventsHeartbeat = 20 * time.Second
+
+// OrderProgress is how far along an order is, as shown to the customer.
+// Status is RECEIVED before the kitchen has the order, PREPARING while any
+// of its kitchen tickets is open, READY once all are bumped, PAID once its
+// invoice is settled and MERGED if it was merged into another check.
+type OrderProgress struct {
+	Order_id     string    `json:"order_id"`
+	Status       string    `json:"status"`
+	Tickets      int       `json:"tickets"`
+	Open_tickets int       `json:"open_tickets"`
+	At           time.Time `json:"at"`
+}
+
+// GetOrderEvents streams an order's progress as Server-Sent Events. The
+// current status is sent on connect and again whenever it changes, as a
+// "status" event holding an OrderProgress; the stream ends after PAID or
+// MERGED. Customers can only follow their own orders.
+func GetOrderEvents() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		var order models.Order
+		err := orderCollection().FindOne(ctx, bson.M{"order_id": c.Param("order_id")}).Decode(&order)
+		cancel()
+		if err != nil || (c.GetString("role") == "CUSTOMER" && stringValue(order.Customer_id) != c.GetString("uid")) {
+			apierror.Render(c, apierror.NotFound("Order not found"))
+			return
+		}
+
+		// Subscribe before the first status so no change falls in between
+		events, unsubscribe := realtime.Subscribe()
+		defer unsubscribe()
+
+		progress, err := orderProgress(c.Request.Context(), order.Order_id)
+		if err != nil {
+			apierror.Render(c, apierror.Internal("error occurred while loading order status: "+err.Error()))
+			return
+		}
+
+		c.Header("Cache-Control", "no-cache")
+		c.Header("X-Accel-Buffering", "no")
+		c.SSEvent("status", progress)
+		c.Writer.Flush()
+
+		heartbeat := time.NewTicker(orderEventsHeartbeat)
+		defer heartbeat.Stop()
+		c.Stream(func(w io.Writer) bool {
+			if progress.Status == "PAID" || progress.Status == "MERGED" {
+				return false
+			}
+			select {
+			case event, ok := <-events:
+				if !ok {
+					return false
+				}
+				if event.Order_id != order.Order_id {
+					return true
+				}
+				latest, err := orderProgress(c.Request.Context(), order.Order_id)
+				if err != nil {
+					c.SSEvent("error", gin.H{"error": apierror.Internal(err.Error())})
+					return false
+				}
+				if latest.Status != progress.Status {
+					progress = latest
+					c.SSEvent("status", progress)
+				}
+				return true
+			case <-heartbeat.C:
+				io.WriteString(w, ": ping\n\n")
+				return true
+			case <-c.Request.Context().Done():
+				return false
+			}
+		})
+	}
+}
+
+// orderProgress works out an order's status from the order, its kitchen
+// tickets and its invoice.
+func orderProgress(ctx context.Context, orderId string) (OrderProgress, error) {
+	progress := OrderProgress{Order_id: orderId, Status: "RECEIVED", At: time.Now()}
+
+	var order models.Order
+	if err := orderCollection().FindOne(ctx, bson.M{"order_id": orderId}).Decode(&order); err != nil {
+		return progress, err
+	}
+	if order.Status == "MERGED" {
+		progress.Status = "MERGED"
+		return progress, nil
+	}
+
+	paid, err := invoiceCollection().CountDocuments(ctx, bson.M{"order_id": orderId, "payment_status": "PAID"})
+	if err != nil {
+		return progress, err
+	}
+	tickets, err := kitchenTicketCollection().CountDocuments(ctx, bson.M{"order_id": orderId})
+	if err != nil {
+		return progress, err
+	}
+	open, err := kitchenTicketCollection().CountDocuments(ctx, bson.M{"order_id": orderId, "status": "OPEN"})
+	if err != nil {
+		return progress, err
+	}
+	progress.Tickets, progress.Open_tickets = int(tickets), int(open)
+
+	switch {
+	case paid > 0:
+		progress.Status = "PAID"
+	case open > 0:
+		progress.Status = "PREPARING"
+	case tickets > 0:
+		progress.Status = "READY"
+	}
+	return progress, nil
+}
+
+// notifyOrderReady tells the order's server that it is ready to be taken
+// out, and texts the guest of a takeout order that it can be picked up,
+// once the order has reached READY. It is called after each bump, so only
+// the bump that clears the last open ticket sends anything.
+func notifyOrderReady(ctx context.Context, orderId string) error {
+	progress, err := orderProgress(ctx, orderId)
+	if err != nil || progress.Status != "READY" {
+		return err
+	}
+	var order models.Order
+	if err := orderCollection().FindOne(ctx, bson.M{"order_id": orderId}).Decode(&order); err != nil {
+		return err
+	}
+
+	if order.Server_id != nil {
+		title := "Order ready"
+		var table models.Table
+		if err := tableCollection().FindOne(ctx, bson.M{"table_id": order.Table_id}).Decode(&table); err == nil && table.Table_number != nil {
+			title = fmt.Sprintf("Table %d is ready", *table.Table_number)
+		}
+		err := pushToUsers(ctx, []string{*order.Server_id}, push.Notification{
+			Title: title,
+			Body:  "The kitchen has finished the order.",
+			Data:  map[string]string{"type": "order_ready", "order_id": order.Order_id, "table_id": stringValue(order.Table_id)},
+		})
+		if err != nil {
+			return err
+		}
+	}
+	if stringValue(order.Channel) != "TAKEOUT" {
+		return nil
+	}
+	return textOrderReady(ctx, order)
+}
+
+// textOrderReady texts the guest of a takeout order that it can be picked
+// up.
+func textOrderReady(ctx context.Context, order models.Order) error {
+	phone := stringValue(order.Customer_phone)
+	if phone == "" && order.Customer_id != nil {
+		var err error
+		if _, _, phone, err = customerContact(ctx, *order.Customer_id); err != nil {
+			return err
+		}
+	}
+	if phone == "" {
+		return nil
+	}
+
+	template, err := effectiveReceiptTemplate(ctx, defaultLocation)
+	if err != nil {
+		return err
+	}
+	sms.Queue(sms.Message{To: phone, Body: fmt.Sprintf("Your order from %s is ready for pickup.", venueName(template))})
+	return nil
+}
@@ -0,0 +1,433 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"restaurant-management/apierror"
+	"restaurant-management/config"
+	"restaurant-management/database"
+	"restaurant-management/models"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// errOrderClosed is returned for checks that were merged away, split or have
+// a paid or finalized invoice, whose items must no longer change.
+var errOrderClosed = errors.New("order is closed")
+
+// errOrderInvoiced is returned when an order to merge away already has an
+// invoice.
+var errOrderInvoiced = errors.New("order already has an invoice")
+
+// openOrderStatuses match open orders, including those created before
+// orders had a status.
+var openOrderStatuses = bson.A{"OPEN", "", nil}
+
+// TransferOrder moves a whole check to another table. Items, server and
+// invoice stay as they are; a reservation seated on the order follows it.
+func TransferOrder() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		var body struct {
+			Table_id *string `json:"table_id" validate:"required"`
+		}
+		if err := c.BindJSON(&body); err != nil {
+			apierror.Render(c, apierror.BadRequest("Invalid request data: "+err.Error()))
+			return
+		}
+		if err := validate.Struct(body); err != nil {
+			apierror.Render(c, apierror.FromValidation(err))
+			return
+		}
+
+		var table models.Table
+		if err := tableCollection().FindOne(ctx, bson.M{"table_id": body.Table_id}).Decode(&table); err != nil {
+			apierror.Render(c, apierror.NotFound("Table not found"))
+			return
+		}
+		order, err := openOrder(ctx, c.Param("order_id"))
+		if !respondOrderError(c, err) {
+			return
+		}
+
+		now, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		if _, err := orderCollection().UpdateOne(ctx, bson.M{"order_id": order.Order_id}, bson.D{{Key: "$set", Value: bson.D{{Key: "table_id", Value: body.Table_id}, {Key: "updated_at", Value: now}}}}); err != nil {
+			apierror.Render(c, apierror.Internal("Update failed: "+err.Error()))
+			return
+		}
+		if _, err := reservationCollection().UpdateMany(ctx, bson.M{"order_id": order.Order_id}, bson.D{{Key: "$set", Value: bson.D{{Key: "table_id", Value: body.Table_id}, {Key: "updated_at", Value: now}}}}); err != nil {
+			apierror.Render(c, apierror.Internal("Update failed: "+err.Error()))
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Order moved", "order_id": order.Order_id, "from_table_id": order.Table_id, "table_id": body.Table_id})
+	}
+}
+
+// MoveOrderItems moves some items of a check to another check, given by
+// target_order_id or by table_id, in which case the open check on that table
+// is used or a new one opened. Moved items keep their ids and fire times, so
+// kitchen tickets follow them, and remember the server who rang them up.
+func MoveOrderItems() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		var body struct {
+			Order_item_ids  []string `json:"order_item_ids" validate:"required,min=1,dive,required"`
+			Target_order_id *string  `json:"target_order_id" validate:"required_without=Table_id"`
+			Table_id        *string  `json:"table_id" validate:"required_without=Target_order_id"`
+		}
+		if err := c.BindJSON(&body); err != nil {
+			apierror.Render(c, apierror.BadRequest("Invalid request data: "+err.Error()))
+			return
+		}
+		if err := validate.Struct(body); err != nil {
+			apierror.Render(c, apierror.FromValidation(err))
+			return
+		}
+
+		source, err := openOrder(ctx, c.Param("order_id"))
+		if !respondOrderError(c, err) {
+			return
+		}
+
+		var target models.Order
+		if body.Target_order_id != nil {
+			target, err = openOrder(ctx, *body.Target_order_id)
+		} else {
+			target, err = openOrderForTable(ctx, source, *body.Table_id)
+		}
+		if !respondOrderError(c, err) {
+			return
+		}
+		if target.Order_id == source.Order_id {
+			apierror.Render(c, apierror.BadRequest("Items are already on this order"))
+			return
+		}
+
+		err = database.WithTransaction(ctx, db.Client(), func(txCtx context.Context) error {
+			return moveOrderItems(txCtx, source, target, body.Order_item_ids)
+		})
+		if err == mongo.ErrNoDocuments {
+			apierror.Render(c, apierror.BadRequest("Every order_item_id must be an item of this order"))
+			return
+		}
+		if err != nil {
+			apierror.Render(c, apierror.Internal("Move failed: "+err.Error()))
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Items moved", "order_id": source.Order_id, "target_order_id": target.Order_id, "table_id": target.Table_id})
+	}
+}
+
+// MergeOrder merges another open check into this one, for tables joined
+// after ordering. Its items and fees move over, it is closed as MERGED and a
+// reservation seated on it follows so its deposit is still credited.
+func MergeOrder() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		var body struct {
+			Order_id *string `json:"order_id" validate:"required"`
+		}
+		if err := c.BindJSON(&body); err != nil {
+			apierror.Render(c, apierror.BadRequest("Invalid request data: "+err.Error()))
+			return
+		}
+		if err := validate.Struct(body); err != nil {
+			apierror.Render(c, apierror.FromValidation(err))
+			return
+		}
+		if *body.Order_id == c.Param("order_id") {
+			apierror.Render(c, apierror.BadRequest("An order cannot be merged into itself"))
+			return
+		}
+
+		target, err := openOrder(ctx, c.Param("order_id"))
+		if !respondOrderError(c, err) {
+			return
+		}
+		source, err := openOrder(ctx, *body.Order_id)
+		if !respondOrderError(c, err) {
+			return
+		}
+
+		err = database.WithTransaction(ctx, db.Client(), func(txCtx context.Context) error {
+			return mergeOrder(txCtx, source, target)
+		})
+		if err == errOrderInvoiced {
+			apierror.Render(c, apierror.Conflict("The order to merge already has an invoice"))
+			return
+		}
+		if err == errOrderClosed {
+			respondOrderError(c, err)
+			return
+		}
+		if err != nil {
+			apierror.Render(c, apierror.Internal("Merge failed: "+err.Error()))
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Orders merged", "order_id": target.Order_id, "merged_order_id": source.Order_id})
+	}
+}
+
+// MergeOrders merges the open checks of tables joined together into one.
+// The check named by into_order_id survives, or else the oldest; the others
+// are merged into it as MergeOrder does, all or none of them.
+func MergeOrders() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		var body struct {
+			Order_ids     []string `json:"order_ids" validate:"required,min=2,max=20,unique,dive,required"`
+			Into_order_id *string  `json:"into_order_id"`
+		}
+		if err := c.BindJSON(&body); err != nil {
+			apierror.Render(c, apierror.BadRequest("Invalid request data: "+err.Error()))
+			return
+		}
+		if err := validate.Struct(body); err != nil {
+			apierror.Render(c, apierror.FromValidation(err))
+			return
+		}
+
+		orders := make([]models.Order, len(body.Order_ids))
+		target := -1
+		for i, orderId := range body.Order_ids {
+			order, err := openOrder(ctx, orderId)
+			if !respondOrderError(c, err) {
+				return
+			}
+			orders[i] = order
+			if body.Into_order_id != nil {
+				if orderId == *body.Into_order_id {
+					target = i
+				}
+			} else if target < 0 || order.Created_at.Before(orders[target].Created_at) {
+				target = i
+			}
+		}
+		if target < 0 {
+			apierror.Render(c, apierror.BadRequest("into_order_id must be one of order_ids"))
+			return
+		}
+
+		merged := []string{}
+		for i, order := range orders {
+			if i != target {
+				merged = append(merged, order.Order_id)
+			}
+		}
+
+		// Without transactions a failure leaves the checks merged so far
+		// merged; their items are on the target either way
+		failed := ""
+		err := database.WithTransaction(ctx, db.Client(), func(txCtx context.Context) error {
+			for i, order := range orders {
+				if i == target {
+					continue
+				}
+				if err := mergeOrder(txCtx, order, orders[target]); err != nil {
+					failed = order.Order_id
+					return err
+				}
+			}
+			return nil
+		})
+		if err == errOrderInvoiced {
+			apierror.Render(c, apierror.Conflict("Order "+failed+" already has an invoice"))
+			return
+		}
+		if err == errOrderClosed {
+			respondOrderError(c, err)
+			return
+		}
+		if err != nil {
+			apierror.Render(c, apierror.Internal("Merge failed: "+err.Error()))
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Orders merged", "order_id": orders[target].Order_id, "merged_order_ids": merged})
+	}
+}
+
+// moveOrderItems moves items from source to target. It returns
+// mongo.ErrNoDocuments if any item is not on source.
+func moveOrderItems(ctx context.Context, source, target models.Order, orderItemIds []string) error {
+	filter := bson.M{"order_id": source.Order_id, "order_item_id": bson.M{"$in": orderItemIds}}
+	count, err := orderItemCollection().CountDocuments(ctx, filter)
+	if err != nil {
+		return err
+	}
+	if int(count) != len(orderItemIds) {
+		return mongo.ErrNoDocuments
+	}
+	return reassignOrderItems(ctx, source, target, filter)
+}
+
+// mergeOrder moves the items, fees and reservations of source onto target
+// and marks source MERGED. It returns errOrderInvoiced when source has an
+// invoice and errOrderClosed when it was merged away meanwhile.
+func mergeOrder(ctx context.Context, source, target models.Order) error {
+	// A pending invoice would be left billing an empty check. Checked here,
+	// inside the merge's transaction, so an invoice created since the
+	// handler loaded the order is seen
+	count, err := invoiceCollection().CountDocuments(ctx, bson.M{"order_id": source.Order_id})
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		return errOrderInvoiced
+	}
+
+	if err := reassignOrderItems(ctx, source, target, bson.M{"order_id": source.Order_id}); err != nil {
+		return err
+	}
+
+	// Order-level fees would otherwise never be invoiced
+	if len(source.Fees) > 0 {
+		if _, err := orderCollection().UpdateOne(ctx, bson.M{"order_id": target.Order_id}, bson.D{{Key: "$push", Value: bson.D{{Key: "fees", Value: bson.D{{Key: "$each", Value: source.Fees}}}}}}); err != nil {
+			return err
+		}
+	}
+
+	// A check merged away concurrently must not be merged twice
+	now, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+	result, err := orderCollection().UpdateOne(ctx, bson.M{"order_id": source.Order_id, "status": bson.M{"$ne": "MERGED"}}, bson.D{{Key: "$set", Value: bson.D{
+		{Key: "status", Value: "MERGED"},
+		{Key: "merged_into", Value: target.Order_id},
+		{Key: "updated_at", Value: now},
+	}}})
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return errOrderClosed
+	}
+	_, err = reservationCollection().UpdateMany(ctx, bson.M{"order_id": source.Order_id}, bson.D{{Key: "$set", Value: bson.D{
+		{Key: "order_id", Value: target.Order_id},
+		{Key: "table_id", Value: target.Table_id},
+		{Key: "updated_at", Value: now},
+	}}})
+	return err
+}
+
+// reassignOrderItems moves the source items matching filter to target,
+// crediting the source's server on items that don't name one yet.
+func reassignOrderItems(ctx context.Context, source, target models.Order, filter bson.M) error {
+	now, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+	if source.Server_id != nil {
+		attribution := bson.M{}
+		for key, value := range filter {
+			attribution[key] = value
+		}
+		attribution["server_id"] = nil
+		if _, err := orderItemCollection().UpdateMany(ctx, attribution, bson.D{{Key: "$set", Value: bson.D{{Key: "server_id", Value: source.Server_id}}}}); err != nil {
+			return err
+		}
+	}
+	if _, err := orderItemCollection().UpdateMany(ctx, filter, bson.D{{Key: "$set", Value: bson.D{{Key: "order_id", Value: target.Order_id}, {Key: "updated_at", Value: now}}}}); err != nil {
+		return err
+	}
+	_, err := orderCollection().UpdateMany(ctx, bson.M{"order_id": bson.M{"$in": bson.A{source.Order_id, target.Order_id}}}, bson.D{{Key: "$set", Value: bson.D{{Key: "updated_at", Value: now}}}})
+	return err
+}
+
+// openOrder loads an order that can still take items. It returns
+// mongo.ErrNoDocuments for unknown orders and errOrderClosed for closed ones.
+func openOrder(ctx context.Context, orderId string) (models.Order, error) {
+	var order models.Order
+	if err := orderCollection().FindOne(ctx, bson.M{"order_id": orderId}).Decode(&order); err != nil {
+		return order, err
+	}
+	if order.Status == "MERGED" || order.Priced_at != nil {
+		return order, errOrderClosed
+	}
+	settled, err := invoiceCollection().CountDocuments(ctx, bson.M{
+		"order_id": orderId,
+		"$or":      bson.A{bson.M{"payment_status": "PAID"}, bson.M{"finalized_at": bson.M{"$ne": nil}}},
+	})
+	if err != nil {
+		return order, err
+	}
+	if settled > 0 {
+		return order, errOrderClosed
+	}
+	return order, nil
+}
+
+// openOrderForTable returns the latest open check on a table, opening one
+// for source's server and channel when there is none.
+func openOrderForTable(ctx context.Context, source models.Order, tableId string) (models.Order, error) {
+	var table models.Table
+	if err := tableCollection().FindOne(ctx, bson.M{"table_id": tableId}).Decode(&table); err != nil {
+		return models.Order{}, err
+	}
+
+	if order, err := tableOpenOrder(ctx, tableId); err != mongo.ErrNoDocuments {
+		return order, err
+	}
+
+	now, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+	order := models.Order{
+		Order_Date: now,
+		Table_id:   &tableId,
+		Channel:    source.Channel,
+		Server_id:  source.Server_id,
+		Opened_by:  source.Opened_by,
+		Status:     "OPEN",
+		Created_at: now,
+		Updated_at: now,
+	}
+	order.ID = primitive.NewObjectID()
+	order.Order_id = order.ID.Hex()
+	_, err := orderCollection().InsertOne(ctx, order)
+	return order, err
+}
+
+// tableOpenOrder returns the latest open check on a table, or
+// mongo.ErrNoDocuments when there is none. Checks that were billed are
+// priced, so only the latest unpriced one is looked at, whatever the
+// table's history.
+func tableOpenOrder(ctx context.Context, tableId string) (models.Order, error) {
+	var candidate models.Order
+	opts := options.FindOne().SetSort(bson.D{{Key: "created_at", Value: -1}})
+	err := orderCollection().FindOne(ctx, bson.M{"table_id": tableId, "priced_at": nil, "status": bson.M{"$in": openOrderStatuses}, "deleted_at": notDeleted}, opts).Decode(&candidate)
+	if err != nil {
+		return candidate, err
+	}
+	order, err := openOrder(ctx, candidate.Order_id)
+	if err == errOrderClosed {
+		return order, mongo.ErrNoDocuments
+	}
+	return order, err
+}
+
+// respondOrderError writes the response for an openOrder error and reports
+// whether the handler may go on.
+func respondOrderError(c *gin.Context, err error) bool {
+	switch {
+	case err == nil:
+		return true
+	case err == mongo.ErrNoDocuments:
+		apierror.Render(c, apierror.NotFound("Order or table not found"))
+	case err == errOrderClosed:
+		apierror.Render(c, apierror.Conflict("The order is closed: it was merged or split or its invoice is paid or finalized"))
+	default:
+		apierror.Render(c, apierror.Internal("error occurred while loading order: "+err.Error()))
+	}
+	return false
+}
@@ -0,0 +1,630 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"restaurant-management/apierror"
+	"restaurant-management/config"
+	"restaurant-management/models"
+	"restaurant-management/payments"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var paymentCollection = db.Collection("payment")
+
+// errInvoiceClaimed is returned when an invoice is paid, or held by another
+// payment, by the time a payment tries to claim it.
+var errInvoiceClaimed = errors.New("invoice is already paid or being paid")
+
+// GetWalletConfig tells the online checkout which wallets it may offer and
+// how to set up their buttons for the order's currency at ?location=.
+func GetWalletConfig() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		currency, err := locationCurrency(ctx, c.DefaultQuery("location", defaultLocation))
+		if err != nil {
+			apierror.Render(c, apierror.Internal("error occurred while loading currency: "+err.Error()))
+			return
+		}
+
+		response := gin.H{"currency": currency}
+		if applePay, ok := payments.ApplePay(); ok {
+			response["apple_pay"] = applePay
+		}
+		if googlePay, ok := payments.GooglePay(); ok {
+			response["google_pay"] = googlePay
+		}
+		c.JSON(http.StatusOK, response)
+	}
+}
+
+// CreateApplePaySession validates the merchant for an Apple Pay sheet. The
+// checkout page passes the validation_url from Apple's onvalidatemerchant
+// event and its own domain.
+func CreateApplePaySession() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		var body struct {
+			Validation_url *string `json:"validation_url" validate:"required,url"`
+			Domain         *string `json:"domain" validate:"required,hostname"`
+		}
+		if err := c.BindJSON(&body); err != nil {
+			apierror.Render(c, apierror.BadRequest("Invalid request data: "+err.Error()))
+			return
+		}
+		if err := validate.Struct(body); err != nil {
+			apierror.Render(c, apierror.FromValidation(err))
+			return
+		}
+
+		session, err := payments.ValidateApplePayMerchant(ctx, *body.Validation_url, *body.Domain)
+		switch err {
+		case nil:
+		case payments.ErrWalletNotConfigured:
+			apierror.Render(c, apierror.Unavailable("Apple Pay is not configured"))
+			return
+		case payments.ErrInvalidValidationURL:
+			apierror.Render(c, apierror.BadRequest(err.Error()))
+			return
+		default:
+			apierror.Render(c, apierror.UpstreamFailed("Merchant validation failed: "+err.Error()))
+			return
+		}
+
+		c.Data(http.StatusOK, "application/json", session)
+	}
+}
+
+// PayOrderWithWallet charges an order's balance to an Apple Pay or Google
+// Pay token and settles its invoice. payment_data is the token exactly as
+// the wallet returned it; the gateway decrypts it. The invoice is held
+// PROCESSING while the wallet is charged, so the order is charged once.
+func PayOrderWithWallet() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		var body struct {
+			Wallet       *string `json:"wallet" validate:"required,eq=APPLE_PAY|eq=GOOGLE_PAY"`
+			Payment_data *string `json:"payment_data" validate:"required"`
+			models.TipRequest
+		}
+		if err := c.BindJSON(&body); err != nil {
+			apierror.Render(c, apierror.BadRequest("Invalid request data: "+err.Error()))
+			return
+		}
+		if err := validate.Struct(body); err != nil {
+			apierror.Render(c, apierror.FromValidation(err))
+			return
+		}
+
+		var order models.Order
+		if err := orderCollection().FindOne(ctx, bson.M{"order_id": c.Param("order_id")}).Decode(&order); err != nil {
+			apierror.Render(c, apierror.NotFound("Order not found"))
+			return
+		}
+		if order.Status == "MERGED" {
+			apierror.Render(c, apierror.Conflict("Order was merged into "+stringValue(order.Merged_into)))
+			return
+		}
+		split, err := invoiceCollection().CountDocuments(ctx, bson.M{"order_id": order.Order_id, "split": bson.M{"$ne": nil}})
+		if err != nil {
+			apierror.Render(c, apierror.Internal("error occurred while checking for invoices: "+err.Error()))
+			return
+		}
+		if split > 0 {
+			apierror.Render(c, apierror.Conflict("The order is split; pay its invoices one by one"))
+			return
+		}
+
+		// Claiming the invoice before charging keeps a second request for
+		// the same order from charging the wallet again
+		location := orderLocation(ctx, order)
+		invoice, err := claimOrderInvoice(ctx, order, location)
+		if err == errInvoiceClaimed {
+			apierror.Render(c, apierror.Conflict("Order is already paid or being paid"))
+			return
+		}
+		if err != nil {
+			apierror.Render(c, apierror.Internal("error occurred while claiming the invoice: "+err.Error()))
+			return
+		}
+		total, err := orderTotal(ctx, order.Order_id)
+		if err != nil {
+			releaseInvoice(ctx, invoice.Invoice_id)
+			apierror.Render(c, apierror.Internal("error occurred while pricing order: "+err.Error()))
+			return
+		}
+		amount := invoiceBalance(invoice, total)
+		if amount <= 0 {
+			releaseInvoice(ctx, invoice.Invoice_id)
+			apierror.Render(c, apierror.BadRequest("Order has nothing to pay"))
+			return
+		}
+		tip := tipFor(body.Tip_amount, body.Tip_percent, amount)
+		amount = toFixed(amount+tip, 2)
+		currency, err := locationCurrency(ctx, location)
+		if err != nil {
+			releaseInvoice(ctx, invoice.Invoice_id)
+			apierror.Render(c, apierror.Internal("error occurred while loading currency: "+err.Error()))
+			return
+		}
+
+		gateway, err := payments.NewGateway()
+		if err != nil {
+			releaseInvoice(ctx, invoice.Invoice_id)
+			apierror.Render(c, apierror.Internal(err.Error()))
+			return
+		}
+		token, err := gateway.TokenizeWallet(ctx, *body.Wallet, *body.Payment_data)
+		if err != nil {
+			releaseInvoice(ctx, invoice.Invoice_id)
+			apierror.Render(c, apierror.UpstreamFailed("Wallet payment could not be read: "+err.Error()))
+			return
+		}
+		chargeId, err := gateway.Charge(ctx, amount, currency, token, fmt.Sprintf("Order %s", order.Order_id))
+		if err == payments.ErrDeclined {
+			releaseInvoice(ctx, invoice.Invoice_id)
+			apierror.Render(c, apierror.PaymentRequired("The payment was declined").With("amount", amount).With("currency", currency))
+			return
+		}
+		if err != nil {
+			releaseInvoice(ctx, invoice.Invoice_id)
+			apierror.Render(c, apierror.UpstreamFailed("Payment could not be charged: "+err.Error()))
+			return
+		}
+
+		payment := models.Payment{
+			ID:        primitive.NewObjectID(),
+			Order_id:  order.Order_id,
+			Method:    "WALLET",
+			Wallet:    body.Wallet,
+			Amount:    amount,
+			Tip:       tip,
+			Currency:  currency,
+			Gateway:   gateway.Name(),
+			Charge_id: chargeId,
+			Status:    "PAID",
+		}
+		payment.Created_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		payment.Updated_at = payment.Created_at
+		payment.Payment_id = payment.ID.Hex()
+		if _, err := paymentCollection().InsertOne(ctx, payment); err != nil {
+			// The money was taken but not recorded, so give it back
+			if refundErr := gateway.Refund(ctx, chargeId, amount); refundErr != nil {
+				log.Println("Error refunding unrecorded wallet payment:", refundErr)
+			}
+			releaseInvoice(ctx, invoice.Invoice_id)
+			apierror.Render(c, apierror.Internal("Payment was not recorded: "+err.Error()))
+			return
+		}
+
+		invoiceId, err := settleOrderInvoice(ctx, order, invoice.Invoice_id, location, "CARD", tip)
+		if err != nil {
+			log.Println("Error settling invoice of paid order:", err)
+		} else {
+			payment.Invoice_id = &invoiceId
+			if _, err := paymentCollection().UpdateOne(ctx, bson.M{"payment_id": payment.Payment_id}, bson.M{"$set": bson.M{"invoice_id": invoiceId}}); err != nil {
+				log.Println("Error linking payment to invoice:", err)
+			}
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Payment accepted", "data": payment})
+	}
+}
+
+// PayInvoice starts a card payment of an invoice's balance, plus the tip the
+// guest adds, through the payment gateway and returns the client secret the
+// checkout confirms it with. The invoice is marked PAID when the gateway's webhook confirms the
+// charge. Asking again while a payment of the same amount is pending
+// returns that payment, so the customer is never charged twice; pending
+// payments of another amount, started before the order changed, are
+// cancelled at the gateway first.
+func PayInvoice() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		var invoice models.Invoice
+		if err := invoiceCollection().FindOne(ctx, bson.M{"invoice_id": c.Param("invoice_id")}).Decode(&invoice); err != nil {
+			apierror.Render(c, apierror.NotFound("invoice item not found"))
+			return
+		}
+		if stringValue(invoice.Payment_status) == "PAID" {
+			apierror.Render(c, apierror.Conflict("Invoice is already paid"))
+			return
+		}
+		if stringValue(invoice.Payment_status) == "PROCESSING" {
+			apierror.Render(c, apierror.Conflict("Invoice is being paid"))
+			return
+		}
+
+		var body models.TipRequest
+		if c.Request.ContentLength > 0 {
+			if err := c.BindJSON(&body); err != nil {
+				apierror.Render(c, apierror.BadRequest("Invalid request data: "+err.Error()))
+				return
+			}
+			if err := validate.Struct(body); err != nil {
+				apierror.Render(c, apierror.FromValidation(err))
+				return
+			}
+		}
+
+		total, err := invoiceTotal(ctx, invoice)
+		if err != nil {
+			apierror.Render(c, apierror.Internal("error occurred while pricing order: "+err.Error()))
+			return
+		}
+		tip := tipFor(body.Tip_amount, body.Tip_percent, total)
+		amount := invoiceBalance(invoice, total)
+		if amount <= 0 {
+			apierror.Render(c, apierror.BadRequest("Invoice has nothing to pay"))
+			return
+		}
+		amount = toFixed(amount+tip, 2)
+		currency, err := locationCurrency(ctx, stringOr(invoice.Location, defaultLocation))
+		if err != nil {
+			apierror.Render(c, apierror.Internal("error occurred while loading currency: "+err.Error()))
+			return
+		}
+
+		cursor, err := paymentCollection().Find(ctx, bson.M{"invoice_id": invoice.Invoice_id, "status": "PENDING"})
+		if err != nil {
+			apierror.Render(c, apierror.Internal("error occurred while checking payments: "+err.Error()))
+			return
+		}
+		var pending []models.Payment
+		if err := cursor.All(ctx, &pending); err != nil {
+			apierror.Render(c, apierror.Internal("error occurred while checking payments: "+err.Error()))
+			return
+		}
+		var current *models.Payment
+		for i := range pending {
+			if current == nil && pending[i].Amount == amount && pending[i].Currency == currency {
+				current = &pending[i]
+				continue
+			}
+			if err := cancelPayment(ctx, pending[i]); err != nil {
+				apierror.Render(c, apierror.UpstreamFailed("An earlier payment of the invoice could not be cancelled: "+err.Error()))
+				return
+			}
+		}
+		if current != nil {
+			c.JSON(http.StatusOK, gin.H{"message": "Payment pending", "data": current})
+			return
+		}
+
+		gateway, err := payments.NewGateway()
+		if err != nil {
+			apierror.Render(c, apierror.Internal(err.Error()))
+			return
+		}
+		intent, err := gateway.CreatePaymentIntent(ctx, amount, currency, fmt.Sprintf("Invoice %s", invoice.Invoice_id), map[string]string{
+			"invoice_id": invoice.Invoice_id,
+			"order_id":   invoice.Order_id,
+		})
+		if err != nil {
+			apierror.Render(c, apierror.UpstreamFailed("Payment could not be started: "+err.Error()))
+			return
+		}
+
+		payment := models.Payment{
+			ID:            primitive.NewObjectID(),
+			Order_id:      invoice.Order_id,
+			Invoice_id:    &invoice.Invoice_id,
+			Method:        "CARD",
+			Amount:        amount,
+			Tip:           tip,
+			Currency:      currency,
+			Gateway:       gateway.Name(),
+			Charge_id:     intent.Id,
+			Status:        "PENDING",
+			Client_secret: &intent.Client_secret,
+		}
+		payment.Created_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		payment.Updated_at = payment.Created_at
+		payment.Payment_id = payment.ID.Hex()
+		if _, err := paymentCollection().InsertOne(ctx, payment); err != nil {
+			// Nothing was taken yet, so the intent is just dropped
+			if voidErr := gateway.Void(ctx, intent.Id); voidErr != nil {
+				log.Println("Error cancelling unrecorded payment intent:", voidErr)
+			}
+			apierror.Render(c, apierror.Internal("Payment was not recorded: "+err.Error()))
+			return
+		}
+
+		c.JSON(http.StatusCreated, gin.H{"message": "Payment started", "data": payment})
+	}
+}
+
+// PaymentWebhook takes the charge notifications of the gateway named in the
+// path. A confirmed charge marks its payment and invoice PAID when it took
+// the payment's amount and that still settles the invoice; otherwise the
+// payment is marked MISMATCHED for staff to look into and the invoice stays
+// unpaid. Events for unknown or already settled payments are acknowledged
+// and ignored, as gateways deliver webhooks more than once.
+func PaymentWebhook() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		gateway, err := payments.GatewayNamed(c.Param("gateway"))
+		if err != nil {
+			apierror.Render(c, apierror.NotFound(err.Error()))
+			return
+		}
+		payload, err := c.GetRawData()
+		if err != nil {
+			apierror.Render(c, apierror.BadRequest("Invalid request data: "+err.Error()))
+			return
+		}
+		event, err := gateway.VerifyWebhook(payload, c.Request.Header)
+		if err == payments.ErrInvalidSignature {
+			apierror.Render(c, apierror.Unauthorized(err.Error()))
+			return
+		}
+		if err != nil {
+			apierror.Render(c, apierror.BadRequest("Invalid webhook: "+err.Error()))
+			return
+		}
+
+		now, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		filter := bson.M{"gateway": gateway.Name(), "charge_id": event.Charge_id}
+		var update bson.D
+		mismatch := ""
+		switch event.Type {
+		case "CHARGE_SUCCEEDED":
+			filter["status"] = "PENDING"
+			if mismatch, err = chargeMismatch(ctx, filter, event); err != nil {
+				apierror.Render(c, apierror.Internal("error occurred while checking the charge: "+err.Error()))
+				return
+			}
+			status := "PAID"
+			if mismatch != "" {
+				status = "MISMATCHED"
+			}
+			update = bson.D{{Key: "status", Value: status}}
+		case "CHARGE_FAILED":
+			filter["status"] = "PENDING"
+			update = bson.D{{Key: "status", Value: "FAILED"}}
+		case "REFUNDED":
+			filter["status"] = bson.M{"$in": bson.A{"PAID", "MISMATCHED", "REFUNDED"}}
+			update = bson.D{{Key: "status", Value: "REFUNDED"}, {Key: "refunded_amount", Value: event.Amount}}
+		default:
+			c.JSON(http.StatusOK, gin.H{"message": "Event ignored"})
+			return
+		}
+		update = append(update, bson.E{Key: "updated_at", Value: now})
+
+		var payment models.Payment
+		err = paymentCollection().FindOneAndUpdate(ctx, filter, bson.D{{Key: "$set", Value: update}}).Decode(&payment)
+		if err == mongo.ErrNoDocuments {
+			c.JSON(http.StatusOK, gin.H{"message": "Event ignored"})
+			return
+		}
+		if err != nil {
+			apierror.Render(c, apierror.Internal("Update failed: "+err.Error()))
+			return
+		}
+
+		if mismatch != "" {
+			log.Printf("Payment %s does not settle its invoice: %s", payment.Payment_id, mismatch)
+			c.JSON(http.StatusOK, gin.H{"message": "Payment held for review: " + mismatch, "payment_id": payment.Payment_id})
+			return
+		}
+		if event.Type == "CHARGE_SUCCEEDED" {
+			var order models.Order
+			if err := orderCollection().FindOne(ctx, bson.M{"order_id": payment.Order_id}).Decode(&order); err != nil {
+				apierror.Render(c, apierror.Internal("error occurred while loading order: "+err.Error()))
+				return
+			}
+			if _, err := settleOrderInvoice(ctx, order, stringValue(payment.Invoice_id), orderLocation(ctx, order), payment.Method, payment.Tip); err != nil {
+				apierror.Render(c, apierror.Internal("Invoice could not be settled: "+err.Error()))
+				return
+			}
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Event processed", "payment_id": payment.Payment_id})
+	}
+}
+
+// chargeMismatch says why the successful charge of the pending payment
+// matched by filter cannot settle its invoice, or "" when it can.
+func chargeMismatch(ctx context.Context, filter bson.M, event payments.WebhookEvent) (string, error) {
+	var payment models.Payment
+	err := paymentCollection().FindOne(ctx, filter).Decode(&payment)
+	if err == mongo.ErrNoDocuments {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	if payment.Invoice_id == nil {
+		return paymentMismatch(event, payment, toFixed(payment.Amount-payment.Tip, 2)), nil
+	}
+	var invoice models.Invoice
+	if err := invoiceCollection().FindOne(ctx, bson.M{"invoice_id": *payment.Invoice_id}).Decode(&invoice); err != nil {
+		return "", err
+	}
+	if stringValue(invoice.Payment_status) == "PAID" {
+		return "the invoice is already paid", nil
+	}
+	total, err := invoiceTotal(ctx, invoice)
+	if err != nil {
+		return "", err
+	}
+	return paymentMismatch(event, payment, invoiceBalance(invoice, total)), nil
+}
+
+// paymentMismatch says why a charge reported by event does not settle
+// balance through payment, or "" when it does: the gateway must have taken
+// the payment's amount in its currency, and that amount less the tip must
+// be the balance due.
+func paymentMismatch(event payments.WebhookEvent, payment models.Payment, balance float64) string {
+	if toFixed(event.Amount, 2) != toFixed(payment.Amount, 2) {
+		return fmt.Sprintf("charged %.2f for a payment of %.2f", event.Amount, payment.Amount)
+	}
+	if !strings.EqualFold(event.Currency, payment.Currency) {
+		return fmt.Sprintf("charged in %s for a payment in %s", event.Currency, payment.Currency)
+	}
+	if toFixed(payment.Amount-payment.Tip, 2) != toFixed(balance, 2) {
+		return fmt.Sprintf("the payment covers %.2f of a balance of %.2f", payment.Amount-payment.Tip, balance)
+	}
+	return ""
+}
+
+// invoiceBalance is what is left to pay of an invoice totalling total once
+// the reservation deposit is credited.
+func invoiceBalance(invoice models.Invoice, total float64) float64 {
+	if invoice.Deposit_credit != nil {
+		return toFixed(total-*invoice.Deposit_credit, 2)
+	}
+	return total
+}
+
+// claimOrderInvoice marks the invoice of an order PROCESSING while a wallet
+// charges it, creating the invoice with the reservation deposit credited
+// if the order has none. It returns errInvoiceClaimed when the invoice is
+// paid or another payment holds it.
+func claimOrderInvoice(ctx context.Context, order models.Order, location string) (models.Invoice, error) {
+	var invoice models.Invoice
+	err := invoiceCollection().FindOne(ctx, bson.M{"order_id": order.Order_id}).Decode(&invoice)
+	if err == mongo.ErrNoDocuments {
+		now, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		status := "PENDING"
+		invoice = models.Invoice{
+			ID:               primitive.NewObjectID(),
+			Order_id:         order.Order_id,
+			Payment_status:   &status,
+			Payment_due_date: now.AddDate(0, 0, 1),
+			Location:         &location,
+			Created_at:       now,
+			Updated_at:       now,
+		}
+		invoice.Invoice_id = invoice.ID.Hex()
+		_, err = invoiceCollection().InsertOne(ctx, invoice)
+		if err == nil {
+			if err := applyReservationDeposit(ctx, invoice); err != nil {
+				log.Println("Error applying reservation deposit:", err)
+			}
+		}
+		// A concurrent payment created it first
+		if mongo.IsDuplicateKeyError(err) {
+			err = invoiceCollection().FindOne(ctx, bson.M{"order_id": order.Order_id}).Decode(&invoice)
+		}
+	}
+	if err != nil {
+		return invoice, err
+	}
+
+	err = invoiceCollection().FindOneAndUpdate(
+		ctx,
+		bson.M{"invoice_id": invoice.Invoice_id, "payment_status": "PENDING"},
+		bson.D{{Key: "$set", Value: bson.D{{Key: "payment_status", Value: "PROCESSING"}, {Key: "updated_at", Value: time.Now()}}}},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&invoice)
+	if err == mongo.ErrNoDocuments {
+		return invoice, errInvoiceClaimed
+	}
+	return invoice, err
+}
+
+// releaseInvoice puts an invoice claimed by claimOrderInvoice back to
+// PENDING when its wallet payment did not go through.
+func releaseInvoice(ctx context.Context, invoiceId string) {
+	_, err := invoiceCollection().UpdateOne(
+		ctx,
+		bson.M{"invoice_id": invoiceId, "payment_status": "PROCESSING"},
+		bson.D{{Key: "$set", Value: bson.D{{Key: "payment_status", Value: "PENDING"}, {Key: "updated_at", Value: time.Now()}}}},
+	)
+	if err != nil {
+		log.Println("Error releasing invoice:", err)
+	}
+}
+
+// cancelPayment cancels a pending payment's intent at its gateway, so it
+// can no longer be confirmed, and marks it CANCELLED.
+func cancelPayment(ctx context.Context, payment models.Payment) error {
+	gateway, err := payments.GatewayNamed(payment.Gateway)
+	if err != nil {
+		return err
+	}
+	if err := gateway.Void(ctx, payment.Charge_id); err != nil {
+		return err
+	}
+	_, err = paymentCollection().UpdateOne(
+		ctx,
+		bson.M{"payment_id": payment.Payment_id, "status": "PENDING"},
+		bson.D{{Key: "$set", Value: bson.D{{Key: "status", Value: "CANCELLED"}, {Key: "updated_at", Value: time.Now()}}}},
+	)
+	return err
+}
+
+// settleOrderInvoice marks the invoice a payment was for, or else the
+// invoice of the paid order, PAID with the tip taken with the payment,
+// creating it if the order has none, and runs invoicePaid as UpdateInvoice
+// does for payments taken at the till, for the payment that moves the
+// invoice to PAID only. It returns the invoice id.
+func settleOrderInvoice(ctx context.Context, order models.Order, invoiceId, location, method string, tip float64) (string, error) {
+	now, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+	status := "PAID"
+
+	filter := bson.M{"order_id": order.Order_id}
+	if invoiceId != "" {
+		filter = bson.M{"invoice_id": invoiceId}
+	}
+	var invoice models.Invoice
+	err := invoiceCollection().FindOne(ctx, filter).Decode(&invoice)
+	switch err {
+	case nil:
+		var result *mongo.UpdateResult
+		result, err = invoiceCollection().UpdateOne(
+			ctx,
+			bson.M{"invoice_id": invoice.Invoice_id, "payment_status": bson.M{"$ne": status}},
+			bson.D{{Key: "$set", Value: bson.D{
+				{Key: "payment_method", Value: method},
+				{Key: "payment_status", Value: status},
+				{Key: "tip", Value: tip},
+				{Key: "updated_at", Value: now},
+			}}},
+		)
+		if err == nil && result.ModifiedCount == 0 {
+			return invoice.Invoice_id, nil
+		}
+	case mongo.ErrNoDocuments:
+		invoice = models.Invoice{
+			ID:               primitive.NewObjectID(),
+			Order_id:         order.Order_id,
+			Payment_method:   &method,
+			Payment_status:   &status,
+			Payment_due_date: now,
+			Location:         &location,
+			Tip:              &tip,
+			Created_at:       now,
+			Updated_at:       now,
+		}
+		invoice.Invoice_id = invoice.ID.Hex()
+		_, err = invoiceCollection().InsertOne(ctx, invoice)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	invoicePaid(ctx, invoice.Invoice_id)
+	return invoice.Invoice_id, nil
+}
@@ -0,0 +1,96 @@
+package controllers
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"restaurant-management/models"
+	"restaurant-management/payments"
+
+	"github.com/gin-gonic/gin"
+)
+
+const testWebhookSecret = "test-webhook-secret"
+
+func TestMain(m *testing.M) {
+	os.Setenv("JWT_SECRET", "test-jwt-secret")
+	os.Setenv("APP_ENV", "development")
+	os.Setenv("SANDBOX_WEBHOOK_SECRET", testWebhookSecret)
+	gin.SetMode(gin.TestMode)
+	os.Exit(m.Run())
+}
+
+func TestPaymentMismatch(t *testing.T) {
+	payment := models.Payment{Amount: 55, Tip: 5, Currency: "usd"}
+	tests := []struct {
+		name    string
+		event   payments.WebhookEvent
+		balance float64
+		want    string
+	}{
+		{"settles the balance", payments.WebhookEvent{Amount: 55, Currency: "USD"}, 50, ""},
+		{"ignores sub-cent noise", payments.WebhookEvent{Amount: 55.001, Currency: "usd"}, 50.004, ""},
+		{"charged less than the payment", payments.WebhookEvent{Amount: 50, Currency: "usd"}, 50, "charged 50.00 for a payment of 55.00"},
+		{"charged in another currency", payments.WebhookEvent{Amount: 55, Currency: "eur"}, 50, "charged in eur for a payment in usd"},
+		{"balance changed since the payment", payments.WebhookEvent{Amount: 55, Currency: "usd"}, 62.5, "the payment covers 50.00 of a balance of 62.50"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := paymentMismatch(tt.event, payment, tt.balance); got != tt.want {
+				t.Errorf("paymentMismatch() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestInvoiceBalance(t *testing.T) {
+	deposit := 20.0
+	if got := invoiceBalance(models.Invoice{}, 80.5); got != 80.5 {
+		t.Errorf("without deposit: got %v, want 80.5", got)
+	}
+	if got := invoiceBalance(models.Invoice{Deposit_credit: &deposit}, 80.5); got != 60.5 {
+		t.Errorf("with deposit: got %v, want 60.5", got)
+	}
+}
+
+// TestPaymentWebhookRejectsUnverified checks that no event reaches
+// settlement unless its gateway is known and its signature holds.
+func TestPaymentWebhookRejectsUnverified(t *testing.T) {
+	payload := `{"id":"evt_1","type":"CHARGE_SUCCEEDED","charge_id":"ch_1","amount":55,"currency":"usd"}`
+	mac := hmac.New(sha256.New, []byte("another-secret"))
+	mac.Write([]byte(payload))
+
+	tests := []struct {
+		name      string
+		gateway   string
+		signature string
+		want      int
+	}{
+		{"unknown gateway", "NOPE", "", http.StatusNotFound},
+		{"unsigned", "SANDBOX", "", http.StatusUnauthorized},
+		{"signed with another secret", "SANDBOX", hex.EncodeToString(mac.Sum(nil)), http.StatusUnauthorized},
+		{"malformed signature", "SANDBOX", "not-hex", http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := gin.New()
+			router.POST("/payments/webhook/:gateway", PaymentWebhook())
+
+			request := httptest.NewRequest(http.MethodPost, "/payments/webhook/"+tt.gateway, strings.NewReader(payload))
+			if tt.signature != "" {
+				request.Header.Set("X-Sandbox-Signature", tt.signature)
+			}
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, request)
+			if recorder.Code != tt.want {
+				t.Errorf("status = %d, want %d: %s", recorder.Code, tt.want, recorder.Body.String())
+			}
+		})
+	}
+}
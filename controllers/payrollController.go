@@ -0,0 +1,186 @@
+package controllers
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"net/http"
+	"os"
+	"restaurant-management/apierror"
+	"restaurant-management/config"
+	"restaurant-management/helpers"
+	"restaurant-management/models"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// defaultOvertimeRule applies to locations without a configured rule: time
+// and a half past 40 hours a week.
+var defaultOvertimeRule = helpers.OvertimeRule{Weekly_threshold: 40, Multiplier: 1.5}
+
+// ExportPayroll turns approved shifts and tip distributions for a location and
+// pay period (?from=&to=) into a payroll import file. ?format= is gusto, adp
+// or json.
+func ExportPayroll() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		from, to, err := parseReportRange(c)
+		if err != nil {
+			apierror.Render(c, apierror.BadRequest(err.Error()))
+			return
+		}
+		location := c.DefaultQuery("location", defaultLocation)
+		format := c.DefaultQuery("format", "gusto")
+		if format != "gusto" && format != "adp" && format != "json" {
+			apierror.Render(c, apierror.BadRequest("format must be gusto, adp or json"))
+			return
+		}
+
+		lines, err := payrollLines(ctx, location, from, to)
+		if err != nil {
+			apierror.Render(c, apierror.Internal("error computing payroll: "+err.Error()))
+			return
+		}
+
+		if format == "json" {
+			c.JSON(http.StatusOK, gin.H{"location": location, "from": from, "to": to, "lines": lines})
+			return
+		}
+
+		employeeIds := []string{}
+		for _, line := range lines {
+			employeeIds = append(employeeIds, line.Employee_id)
+		}
+		employees, err := usersById(ctx, employeeIds)
+		if err != nil {
+			apierror.Render(c, apierror.Internal("error loading employees: "+err.Error()))
+			return
+		}
+
+		c.Header("Content-Type", "text/csv")
+		c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=payroll-%s-%s-%s-%s.csv", format, location, from.Format("20060102"), to.AddDate(0, 0, -1).Format("20060102")))
+
+		writer := csv.NewWriter(c.Writer)
+		money := func(value float64) string { return strconv.FormatFloat(value, 'f', 2, 64) }
+		if format == "gusto" {
+			writer.Write([]string{"employee_id", "last_name", "first_name", "regular_hours", "overtime_hours", "paycheck_tips", "cash_tips"})
+			for _, line := range lines {
+				employee := employees[line.Employee_id]
+				writer.Write([]string{
+					line.Employee_id,
+					stringValue(employee.Last_name),
+					stringValue(employee.First_name),
+					money(line.Regular_hours),
+					money(line.Overtime_hours),
+					money(line.Paycheck_tips),
+					money(line.Cash_tips),
+				})
+			}
+		} else {
+			tipsCode := os.Getenv("ADP_TIPS_CODE")
+			if tipsCode == "" {
+				tipsCode = "T"
+			}
+			batchId := from.Format("20060102")
+			writer.Write([]string{"Co Code", "Batch ID", "File #", "Reg Hours", "O/T Hours", "Earnings 3 Code", "Earnings 3 Amount"})
+			for _, line := range lines {
+				writer.Write([]string{
+					os.Getenv("ADP_COMPANY_CODE"),
+					batchId,
+					line.Employee_id,
+					money(line.Regular_hours),
+					money(line.Overtime_hours),
+					tipsCode,
+					money(line.Paycheck_tips + line.Cash_tips),
+				})
+			}
+		}
+		writer.Flush()
+	}
+}
+
+// payrollLines computes hours, overtime and tips for each employee with
+// approved shifts or tips at a location in [from, to).
+func payrollLines(ctx context.Context, location string, from, to time.Time) ([]helpers.PayrollLine, error) {
+	rule := defaultOvertimeRule
+	var configured models.OvertimeRule
+	err := overtimeRuleCollection().FindOne(ctx, bson.M{"location": location}).Decode(&configured)
+	if err != nil && err != mongo.ErrNoDocuments {
+		return nil, err
+	}
+	if err == nil {
+		rule = helpers.OvertimeRule{Multiplier: *configured.Multiplier}
+		if configured.Daily_threshold != nil {
+			rule.Daily_threshold = *configured.Daily_threshold
+		}
+		if configured.Weekly_threshold != nil {
+			rule.Weekly_threshold = *configured.Weekly_threshold
+		}
+	}
+
+	cursor, err := timeClockCollection().Find(ctx, bson.M{"location": location, "status": "APPROVED", "clock_in": bson.M{"$gte": from, "$lt": to}})
+	if err != nil {
+		return nil, err
+	}
+	var records []models.TimeClockRecord
+	if err = cursor.All(ctx, &records); err != nil {
+		return nil, err
+	}
+	shifts := []helpers.Shift{}
+	for _, record := range records {
+		shifts = append(shifts, helpers.Shift{Employee_id: *record.Employee_id, Start: record.Clock_in, End: *record.Clock_out, Rate: *record.Hourly_rate})
+	}
+	lines := helpers.ComputePayroll(shifts, rule)
+
+	tipCursor, err := tipDistributionCollection().Find(ctx, bson.M{"location": location, "business_date": bson.M{"$gte": from, "$lt": to}})
+	if err != nil {
+		return nil, err
+	}
+	var tips []models.TipDistribution
+	if err = tipCursor.All(ctx, &tips); err != nil {
+		return nil, err
+	}
+	index := map[string]int{}
+	for i, line := range lines {
+		index[line.Employee_id] = i
+	}
+	for _, tip := range tips {
+		i, ok := index[*tip.Employee_id]
+		if !ok {
+			lines = append(lines, helpers.PayrollLine{Employee_id: *tip.Employee_id})
+			i = len(lines) - 1
+			index[*tip.Employee_id] = i
+		}
+		if tip.Paid_in_cash {
+			lines[i].Cash_tips = toFixed(lines[i].Cash_tips+*tip.Amount, 2)
+		} else {
+			lines[i].Paycheck_tips = toFixed(lines[i].Paycheck_tips+*tip.Amount, 2)
+		}
+	}
+	return lines, nil
+}
+
+func usersById(ctx context.Context, ids []string) (map[string]models.User, error) {
+	users := map[string]models.User{}
+	if len(ids) == 0 {
+		return users, nil
+	}
+	cursor, err := userCollection().Find(ctx, bson.M{"user_id": bson.M{"$in": ids}})
+	if err != nil {
+		return nil, err
+	}
+	var found []models.User
+	if err = cursor.All(ctx, &found); err != nil {
+		return nil, err
+	}
+	for _, user := range found {
+		users[user.User_id] = user
+	}
+	return users, nil
+}
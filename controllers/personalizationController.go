@@ -0,0 +1,437 @@
+package controllers
+
+import (
+	"context"
+	"net/http"
+	"restaurant-management/apierror"
+	"restaurant-management/config"
+	"restaurant-management/extensions"
+	"restaurant-management/models"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var menuPersonalizationCollection = db.Collection("menuPersonalization")
+var customerSegmentCollection = db.Collection("customerSegment")
+
+// personalizationWindowDays is how far back orders count towards what a
+// segment likes; a customer's own history counts in full.
+const personalizationWindowDays = 90
+
+// popularFoods is how many foods of a segment are highlighted as popular.
+const popularFoods = 10
+
+// GetPersonalizedMenu returns the current menu for the signed-in customer.
+// Categories they order from most come first, then those their segment
+// favours. Within a category, foods that suit the customer's allergies come
+// first, then what they ordered before and what is popular in their
+// segment. A food is flagged as not suiting an allergy when the allergy
+// is named in the food or in one of its recipe ingredients.
+func GetPersonalizedMenu() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		customerId := c.GetString("uid")
+		if customerId == "" {
+			apierror.Render(c, apierror.Unauthorized("Sign in to see a personalized menu"))
+			return
+		}
+
+		var customer models.User
+		if err := userCollection().FindOne(ctx, bson.M{"user_id": customerId}).Decode(&customer); err != nil {
+			apierror.Render(c, apierror.NotFound("Customer not found"))
+			return
+		}
+
+		personalization, err := personalizationFor(ctx, customerId)
+		if err != nil {
+			apierror.Render(c, apierror.Internal("error occurred while loading personalization: "+err.Error()))
+			return
+		}
+		ordered, err := foodOrderCounts(ctx, bson.D{{Key: "customer_id", Value: customerId}})
+		if err != nil {
+			apierror.Render(c, apierror.Internal("error occurred while loading order history: "+err.Error()))
+			return
+		}
+
+		menus, err := currentMenus(ctx)
+		if err != nil {
+			apierror.Render(c, apierror.Internal("error occurred while loading menus: "+err.Error()))
+			return
+		}
+		menuCategories := map[string]string{}
+		menuIds := []string{}
+		for _, menu := range menus {
+			menuCategories[menu.Menu_id] = menu.Category
+			menuIds = append(menuIds, menu.Menu_id)
+		}
+		cursor, err := foodCollection().Find(ctx, bson.M{"menu_id": bson.M{"$in": menuIds}, "available": bson.M{"$ne": false}})
+		if err != nil {
+			apierror.Render(c, apierror.Internal("error occurred while loading foods: "+err.Error()))
+			return
+		}
+		var foods []models.Food
+		if err = cursor.All(ctx, &foods); err != nil {
+			apierror.Render(c, apierror.Internal("error decoding foods: "+err.Error()))
+			return
+		}
+
+		conflicts, err := allergyConflicts(ctx, foods, customer.Allergies)
+		if err != nil {
+			apierror.Render(c, apierror.Internal("error occurred while checking allergies: "+err.Error()))
+			return
+		}
+
+		popularRank := map[string]int{}
+		for i, foodId := range personalization.Popular_food_ids {
+			popularRank[foodId] = i + 1
+		}
+		categoryRank := map[string]int{}
+		for i, category := range personalization.Category_order {
+			categoryRank[category] = i + 1
+		}
+
+		byCategory := map[string][]map[string]interface{}{}
+		orderedInCategory := map[string]int{}
+		for _, food := range foods {
+			category := menuCategories[stringValue(food.Menu_id)]
+			orderedInCategory[category] += ordered[food.Food_id]
+			byCategory[category] = append(byCategory[category], gin.H{
+				"food_id":            food.Food_id,
+				"name":               food.Name,
+				"price":              food.Price,
+				"food_image":         food.Food_image,
+				"times_ordered":      ordered[food.Food_id],
+				"previously_ordered": ordered[food.Food_id] > 0,
+				"popular":            popularRank[food.Food_id] > 0,
+				"dietary_compatible": len(conflicts[food.Food_id]) == 0,
+				"allergy_conflicts":  conflicts[food.Food_id],
+			})
+		}
+
+		rankOf := func(ranks map[string]int, key string) int {
+			if rank, ok := ranks[key]; ok {
+				return rank
+			}
+			return len(ranks) + 1
+		}
+		categories := make([]string, 0, len(byCategory))
+		for category := range byCategory {
+			categories = append(categories, category)
+		}
+		sort.Slice(categories, func(i, j int) bool {
+			a, b := categories[i], categories[j]
+			if orderedInCategory[a] != orderedInCategory[b] {
+				return orderedInCategory[a] > orderedInCategory[b]
+			}
+			if rankOf(categoryRank, a) != rankOf(categoryRank, b) {
+				return rankOf(categoryRank, a) < rankOf(categoryRank, b)
+			}
+			return a < b
+		})
+
+		sections := []extensions.MenuSection{}
+		for _, category := range categories {
+			items := byCategory[category]
+			sort.SliceStable(items, func(i, j int) bool {
+				a, b := items[i], items[j]
+				if a["dietary_compatible"] != b["dietary_compatible"] {
+					return a["dietary_compatible"].(bool)
+				}
+				if a["times_ordered"] != b["times_ordered"] {
+					return a["times_ordered"].(int) > b["times_ordered"].(int)
+				}
+				return rankOf(popularRank, a["food_id"].(string)) < rankOf(popularRank, b["food_id"].(string))
+			})
+			sections = append(sections, extensions.MenuSection{Category: category, Foods: items})
+		}
+		sections, err = extensions.RenderMenu(ctx, customerId, sections)
+		if err != nil {
+			apierror.Render(c, apierror.Internal("error occurred while rendering menu: "+err.Error()))
+			return
+		}
+		result := []gin.H{}
+		for _, section := range sections {
+			result = append(result, gin.H{"category": section.Category, "foods": section.Foods})
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"customer_id": customerId,
+			"segment_id":  personalization.Segment_id,
+			"allergies":   customer.Allergies,
+			"categories":  result,
+		})
+	}
+}
+
+// PersonalizeMenus assigns every customer who has ordered to a segment and
+// works out the favourite categories and foods of each segment, and of all
+// customers together.
+func PersonalizeMenus(ctx context.Context) error {
+	now, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+
+	cursor, err := segmentCollection().Find(ctx, bson.M{}, options.Find().SetSort(bson.D{{Key: "created_at", Value: 1}}))
+	if err != nil {
+		return err
+	}
+	var segments []models.Segment
+	if err = cursor.All(ctx, &segments); err != nil {
+		return err
+	}
+
+	assigned := map[string]string{}
+	customersOf := map[string][]string{}
+	for _, segment := range segments {
+		if segment.Rules == nil {
+			continue
+		}
+		members, err := SegmentMembers(ctx, *segment.Rules, now)
+		if err != nil {
+			return err
+		}
+		for _, member := range members {
+			if _, ok := assigned[member.Customer_id]; ok {
+				continue
+			}
+			assigned[member.Customer_id] = segment.Segment_id
+			customersOf[segment.Segment_id] = append(customersOf[segment.Segment_id], member.Customer_id)
+		}
+	}
+
+	since := now.AddDate(0, 0, -personalizationWindowDays)
+	allCustomers := bson.D{{Key: "customer_id", Value: bson.D{{Key: "$nin", Value: bson.A{nil, ""}}}}}
+	if err := savePersonalization(ctx, "", allCustomers, since, now); err != nil {
+		return err
+	}
+	for segmentId, customerIds := range customersOf {
+		filter := bson.D{{Key: "customer_id", Value: bson.D{{Key: "$in", Value: customerIds}}}}
+		if err := savePersonalization(ctx, segmentId, filter, since, now); err != nil {
+			return err
+		}
+	}
+
+	for customerId, segmentId := range assigned {
+		_, err := customerSegmentCollection().UpdateOne(
+			ctx,
+			bson.M{"customer_id": customerId},
+			bson.D{
+				{Key: "$set", Value: bson.D{{Key: "segment_id", Value: segmentId}, {Key: "computed_at", Value: now}}},
+				{Key: "$setOnInsert", Value: bson.D{{Key: "_id", Value: primitive.NewObjectID()}}},
+			},
+			options.Update().SetUpsert(true),
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Customers who left every segment and emptied segments fall back to all
+	// customers
+	if _, err := customerSegmentCollection().DeleteMany(ctx, bson.M{"computed_at": bson.M{"$lt": now}}); err != nil {
+		return err
+	}
+	_, err = menuPersonalizationCollection().DeleteMany(ctx, bson.M{"computed_at": bson.M{"$lt": now}})
+	return err
+}
+
+// savePersonalization ranks the categories and foods ordered since since by
+// the customers matching filter and stores them for segmentId.
+func savePersonalization(ctx context.Context, segmentId string, filter bson.D, since, now time.Time) error {
+	counts, err := foodOrderCounts(ctx, append(filter, bson.E{Key: "created_at", Value: bson.D{{Key: "$gte", Value: since}}}))
+	if err != nil {
+		return err
+	}
+	customers, err := orderCollection().Distinct(ctx, "customer_id", filter)
+	if err != nil {
+		return err
+	}
+
+	foodIds := make([]string, 0, len(counts))
+	for foodId := range counts {
+		foodIds = append(foodIds, foodId)
+	}
+	sort.Slice(foodIds, func(i, j int) bool {
+		if counts[foodIds[i]] != counts[foodIds[j]] {
+			return counts[foodIds[i]] > counts[foodIds[j]]
+		}
+		return foodIds[i] < foodIds[j]
+	})
+	_, categories, err := foodCategories(ctx, foodIds)
+	if err != nil {
+		return err
+	}
+	categoryCounts := map[string]int{}
+	categoryOrder := []string{}
+	for _, foodId := range foodIds {
+		category, ok := categories[foodId]
+		if !ok || category == "" {
+			continue
+		}
+		if _, ok := categoryCounts[category]; !ok {
+			categoryOrder = append(categoryOrder, category)
+		}
+		categoryCounts[category] += counts[foodId]
+	}
+	sort.SliceStable(categoryOrder, func(i, j int) bool {
+		return categoryCounts[categoryOrder[i]] > categoryCounts[categoryOrder[j]]
+	})
+	if len(foodIds) > popularFoods {
+		foodIds = foodIds[:popularFoods]
+	}
+
+	_, err = menuPersonalizationCollection().UpdateOne(
+		ctx,
+		bson.M{"segment_id": segmentId},
+		bson.D{
+			{Key: "$set", Value: bson.D{
+				{Key: "customers", Value: len(customers)},
+				{Key: "category_order", Value: categoryOrder},
+				{Key: "popular_food_ids", Value: foodIds},
+				{Key: "computed_at", Value: now},
+			}},
+			{Key: "$setOnInsert", Value: bson.D{{Key: "_id", Value: primitive.NewObjectID()}}},
+		},
+		options.Update().SetUpsert(true),
+	)
+	return err
+}
+
+// personalizationFor returns the personalization of a customer's segment,
+// falling back to the one for all customers. Before the first run it is
+// empty.
+func personalizationFor(ctx context.Context, customerId string) (models.MenuPersonalization, error) {
+	var assignment models.CustomerSegment
+	err := customerSegmentCollection().FindOne(ctx, bson.M{"customer_id": customerId}).Decode(&assignment)
+	if err != nil && err != mongo.ErrNoDocuments {
+		return models.MenuPersonalization{}, err
+	}
+
+	for _, segmentId := range []string{assignment.Segment_id, ""} {
+		var personalization models.MenuPersonalization
+		err := menuPersonalizationCollection().FindOne(ctx, bson.M{"segment_id": segmentId}).Decode(&personalization)
+		if err == nil {
+			return personalization, nil
+		}
+		if err != mongo.ErrNoDocuments {
+			return personalization, err
+		}
+	}
+	return models.MenuPersonalization{}, nil
+}
+
+// foodOrderCounts counts how many times each food was ordered on the orders
+// matching filter.
+func foodOrderCounts(ctx context.Context, filter bson.D) (map[string]int, error) {
+	cursor, err := orderCollection().Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$match", Value: filter}},
+		{{Key: "$lookup", Value: bson.D{
+			{Key: "from", Value: "orderItem"},
+			{Key: "localField", Value: "order_id"},
+			{Key: "foreignField", Value: "order_id"},
+			{Key: "as", Value: "items"},
+		}}},
+		{{Key: "$unwind", Value: "$items"}},
+		{{Key: "$group", Value: bson.D{
+			{Key: "_id", Value: "$items.food_id"},
+			{Key: "count", Value: bson.D{{Key: "$sum", Value: 1}}},
+		}}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	var rows []struct {
+		Food_id string `bson:"_id"`
+		Count   int    `bson:"count"`
+	}
+	if err = cursor.All(ctx, &rows); err != nil {
+		return nil, err
+	}
+	counts := map[string]int{}
+	for _, row := range rows {
+		counts[row.Food_id] = row.Count
+	}
+	return counts, nil
+}
+
+// currentMenus returns the menus running now; menus without dates always
+// run.
+func currentMenus(ctx context.Context) ([]models.Menu, error) {
+	cursor, err := menuCollection().Find(ctx, menusRunningAt(time.Now()))
+	if err != nil {
+		return nil, err
+	}
+	var menus []models.Menu
+	if err = cursor.All(ctx, &menus); err != nil {
+		return nil, err
+	}
+	return menus, nil
+}
+
+// allergyConflicts returns, per food, the allergies named in its name or in
+// the sku or inventory name of one of its recipe ingredients.
+func allergyConflicts(ctx context.Context, foods []models.Food, allergies []string) (map[string][]string, error) {
+	conflicts := map[string][]string{}
+	if len(allergies) == 0 {
+		return conflicts, nil
+	}
+
+	foodIds := make([]string, 0, len(foods))
+	for _, food := range foods {
+		foodIds = append(foodIds, food.Food_id)
+	}
+	cursor, err := recipeCollection().Find(ctx, bson.M{"food_id": bson.M{"$in": foodIds}})
+	if err != nil {
+		return nil, err
+	}
+	var recipes []models.Recipe
+	if err = cursor.All(ctx, &recipes); err != nil {
+		return nil, err
+	}
+	ingredients := map[string][]string{}
+	skus := []string{}
+	for _, recipe := range recipes {
+		for _, ingredient := range recipe.Ingredients {
+			ingredients[recipe.Food_id] = append(ingredients[recipe.Food_id], *ingredient.Sku)
+			skus = append(skus, *ingredient.Sku)
+		}
+	}
+	names := map[string]string{}
+	if len(skus) > 0 {
+		cursor, err := inventoryCollection().Find(ctx, bson.M{"sku": bson.M{"$in": skus}}, options.Find().SetProjection(bson.M{"sku": 1, "name": 1}))
+		if err != nil {
+			return nil, err
+		}
+		var stock []models.InventoryItem
+		if err = cursor.All(ctx, &stock); err != nil {
+			return nil, err
+		}
+		for _, item := range stock {
+			names[item.Sku] = stringValue(item.Name)
+		}
+	}
+
+	for _, food := range foods {
+		text := []string{strings.ToLower(stringValue(food.Name))}
+		for _, sku := range ingredients[food.Food_id] {
+			text = append(text, strings.ToLower(sku), strings.ToLower(names[sku]))
+		}
+		for _, allergy := range allergies {
+			term := strings.ToLower(strings.TrimSpace(allergy))
+			for _, value := range text {
+				if term != "" && strings.Contains(value, term) {
+					conflicts[food.Food_id] = append(conflicts[food.Food_id], allergy)
+					break
+				}
+			}
+		}
+	}
+	return conflicts, nil
+}
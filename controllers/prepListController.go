@@ -0,0 +1,538 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"restaurant-management/apierror"
+	"restaurant-management/config"
+	"restaurant-management/helpers"
+	"restaurant-management/models"
+	"restaurant-management/receipt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var recipeCollection = db.Collection("recipe")
+var inventoryCollection = db.Collection("inventory")
+
+// prepListWidth is the printed width of a prep list, for 80mm paper.
+const prepListWidth = 42
+
+// StationPrepList is what one station preps and the ingredients it pulls
+// from stock to do so.
+type StationPrepList struct {
+	Station     string            `json:"station"`
+	Items       []StationPrepItem `json:"items"`
+	Ingredients []IngredientPull  `json:"ingredients"`
+}
+
+type StationPrepItem struct {
+	Food_id        string  `json:"food_id"`
+	Name           string  `json:"name"`
+	Expected_units float64 `json:"expected_units"`
+	On_hand        float64 `json:"on_hand"`
+	Units_to_prep  int     `json:"units_to_prep"`
+	Batches        int     `json:"batches"`
+}
+
+// IngredientPull is an ingredient to take from stock. Short is how much of
+// it the counted stock does not cover.
+type IngredientPull struct {
+	Sku      string  `json:"sku"`
+	Name     string  `json:"name"`
+	Unit     string  `json:"unit"`
+	Quantity float64 `json:"quantity"`
+	On_hand  float64 `json:"on_hand"`
+	Short    float64 `json:"short"`
+}
+
+func GetRecipes() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		filter := bson.M{}
+		if station := c.Query("station"); station != "" {
+			filter["station"] = station
+		}
+
+		result, err := recipeCollection().Find(ctx, filter)
+		if err != nil {
+			apierror.Render(c, apierror.Internal("error occurred while listing recipes: "+err.Error()))
+			return
+		}
+
+		var allRecipes []bson.M
+		if err = result.All(ctx, &allRecipes); err != nil {
+			apierror.Render(c, apierror.Internal("error decoding recipes: "+err.Error()))
+			return
+		}
+
+		c.JSON(http.StatusOK, allRecipes)
+	}
+}
+
+// UpdateRecipe creates or updates the prep recipe of a food. Staff also use
+// it to record the prepped units left at close.
+func UpdateRecipe() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		var recipe models.Recipe
+		if err := c.BindJSON(&recipe); err != nil {
+			apierror.Render(c, apierror.BadRequest("Invalid request data: "+err.Error()))
+			return
+		}
+		if err := validate.StructExcept(recipe, "Station"); err != nil {
+			apierror.Render(c, apierror.FromValidation(err))
+			return
+		}
+
+		count, err := foodCollection().CountDocuments(ctx, bson.M{"food_id": c.Param("food_id")})
+		if err != nil || count == 0 {
+			apierror.Render(c, apierror.NotFound("Food not found"))
+			return
+		}
+
+		var updateObj primitive.D
+		if recipe.Station != nil {
+			updateObj = append(updateObj, bson.E{Key: "station", Value: recipe.Station})
+		}
+		if recipe.Batch_size != nil {
+			updateObj = append(updateObj, bson.E{Key: "batch_size", Value: recipe.Batch_size})
+		}
+		if recipe.Ingredients != nil {
+			updateObj = append(updateObj, bson.E{Key: "ingredients", Value: recipe.Ingredients})
+		}
+		if recipe.Prepped_on_hand != nil {
+			updateObj = append(updateObj, bson.E{Key: "prepped_on_hand", Value: recipe.Prepped_on_hand})
+		}
+
+		now, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		updateObj = append(updateObj, bson.E{Key: "updated_at", Value: now})
+
+		// A new recipe needs a station to land on a prep list
+		var existing models.Recipe
+		if err := recipeCollection().FindOne(ctx, bson.M{"food_id": c.Param("food_id")}).Decode(&existing); err == mongo.ErrNoDocuments && recipe.Station == nil {
+			apierror.Render(c, apierror.Validation("Validation failed: station is required"))
+			return
+		}
+
+		upsert := true
+		opt := options.UpdateOptions{Upsert: &upsert}
+
+		result, err := recipeCollection().UpdateOne(
+			ctx,
+			bson.M{"food_id": c.Param("food_id")},
+			bson.D{
+				{Key: "$set", Value: updateObj},
+				{Key: "$setOnInsert", Value: bson.D{{Key: "_id", Value: primitive.NewObjectID()}, {Key: "created_at", Value: now}}},
+			},
+			&opt,
+		)
+		if err != nil {
+			apierror.Render(c, apierror.Internal("Update failed: "+err.Error()))
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Recipe updated successfully", "result": result})
+	}
+}
+
+func GetInventory() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		opts := options.Find().SetSort(bson.D{{Key: "sku", Value: 1}})
+		result, err := inventoryCollection().Find(ctx, bson.M{"location": c.DefaultQuery("location", defaultLocation)}, opts)
+		if err != nil {
+			apierror.Render(c, apierror.Internal("error occurred while listing inventory: "+err.Error()))
+			return
+		}
+
+		var allItems []bson.M
+		if err = result.All(ctx, &allItems); err != nil {
+			apierror.Render(c, apierror.Internal("error decoding inventory: "+err.Error()))
+			return
+		}
+
+		c.JSON(http.StatusOK, allItems)
+	}
+}
+
+// UpdateInventoryItem records a stock count of an ingredient at ?location=.
+func UpdateInventoryItem() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		var item models.InventoryItem
+		if err := c.BindJSON(&item); err != nil {
+			apierror.Render(c, apierror.BadRequest("Invalid request data: "+err.Error()))
+			return
+		}
+		if err := validate.Struct(item); err != nil {
+			apierror.Render(c, apierror.FromValidation(err))
+			return
+		}
+
+		now, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		updateObj := primitive.D{
+			{Key: "on_hand", Value: item.On_hand},
+			{Key: "counted_at", Value: now},
+			{Key: "updated_at", Value: now},
+		}
+		if item.Name != nil {
+			updateObj = append(updateObj, bson.E{Key: "name", Value: item.Name})
+		}
+		if item.Unit != nil {
+			updateObj = append(updateObj, bson.E{Key: "unit", Value: item.Unit})
+		}
+
+		upsert := true
+		opt := options.UpdateOptions{Upsert: &upsert}
+
+		result, err := inventoryCollection().UpdateOne(
+			ctx,
+			bson.M{"sku": c.Param("sku"), "location": c.DefaultQuery("location", defaultLocation)},
+			bson.D{
+				{Key: "$set", Value: updateObj},
+				{Key: "$setOnInsert", Value: bson.D{{Key: "_id", Value: primitive.NewObjectID()}, {Key: "created_at", Value: now}}},
+			},
+			&opt,
+		)
+		if err != nil {
+			apierror.Render(c, apierror.Internal("Update failed: "+err.Error()))
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Inventory updated successfully", "result": result})
+	}
+}
+
+// GetStationPrepLists combines the demand forecast for ?date= (default
+// today) and ?daypart= with recipes and counted stock into a prep list per
+// station. ?format=text, escpos or pdf prints one page per station.
+func GetStationPrepLists() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		target, daypart, location, lists, err := prepListsFor(ctx, c)
+		if err != nil {
+			apierror.Render(c, apierror.BadRequest(err.Error()))
+			return
+		}
+		if station := c.Query("station"); station != "" {
+			filtered := []StationPrepList{}
+			for _, list := range lists {
+				if list.Station == station {
+					filtered = append(filtered, list)
+				}
+			}
+			lists = filtered
+		}
+
+		date := target.Format("2006-01-02")
+		filename := "prep-list-" + date
+		switch c.DefaultQuery("format", "json") {
+		case "json":
+			c.JSON(http.StatusOK, gin.H{"date": date, "daypart": daypart, "location": location, "stations": lists})
+		case "text":
+			pages := []string{}
+			for _, list := range lists {
+				pages = append(pages, strings.Join(prepListLines(date, daypart, list), "\n"))
+			}
+			c.String(http.StatusOK, strings.Join(pages, "\n\n")+"\n")
+		case "escpos":
+			// One cut ticket per station
+			var out []byte
+			for _, list := range lists {
+				out = append(out, receipt.ESCPOSText(prepListLines(date, daypart, list))...)
+			}
+			c.Header("Content-Disposition", "attachment; filename="+filename+".bin")
+			c.Data(http.StatusOK, "application/octet-stream", out)
+		case "pdf":
+			lines := []string{}
+			for i, list := range lists {
+				if i > 0 {
+					lines = append(lines, "", "")
+				}
+				lines = append(lines, prepListLines(date, daypart, list)...)
+			}
+			pdf, err := receipt.PDFText(lines, prepListWidth)
+			if err != nil {
+				apierror.Render(c, apierror.Internal("error occurred while rendering prep list: "+err.Error()))
+				return
+			}
+			c.Header("Content-Disposition", "inline; filename="+filename+".pdf")
+			c.Data(http.StatusOK, "application/pdf", pdf)
+		default:
+			apierror.Render(c, apierror.BadRequest("format must be json, pdf, escpos or text"))
+		}
+	}
+}
+
+// CreatePrepTasks turns the prep list into PREP tasks, one per item, which
+// stations check off through the task endpoints. Running it again for the
+// same day and daypart adds nothing.
+func CreatePrepTasks() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		target, daypart, location, lists, err := prepListsFor(ctx, c)
+		if err != nil {
+			apierror.Render(c, apierror.BadRequest(err.Error()))
+			return
+		}
+
+		created, err := createPrepTasks(ctx, target, daypart, location, lists)
+		if err != nil {
+			apierror.Render(c, apierror.Internal("error creating prep tasks: "+err.Error()))
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"date": target.Format("2006-01-02"), "daypart": daypart, "location": location, "created": created})
+	}
+}
+
+// GeneratePrepTasks is the morning job creating today's prep tasks at every
+// location that counts stock.
+func GeneratePrepTasks(ctx context.Context) error {
+	locations, err := inventoryCollection().Distinct(ctx, "location", bson.M{})
+	if err != nil {
+		return err
+	}
+	if len(locations) == 0 {
+		locations = []interface{}{defaultLocation}
+	}
+
+	forecaster, err := helpers.NewForecaster("moving_average", 7)
+	if err != nil {
+		return err
+	}
+	now := time.Now().UTC()
+	target := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	forecasts, err := forecastDemand(ctx, target, "", forecaster)
+	if err != nil {
+		return err
+	}
+	for _, value := range locations {
+		location, ok := value.(string)
+		if !ok {
+			continue
+		}
+		lists, err := stationPrepLists(ctx, location, forecasts)
+		if err != nil {
+			return err
+		}
+		if _, err := createPrepTasks(ctx, target, "", location, lists); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// prepListsFor reads the forecast query of a prep list request, defaulting
+// the date to today, and builds the lists.
+func prepListsFor(ctx context.Context, c *gin.Context) (time.Time, string, string, []StationPrepList, error) {
+	target, daypart, forecaster, err := parseForecastQuery(c)
+	if err != nil {
+		return target, daypart, "", nil, err
+	}
+	if c.Query("date") == "" {
+		now := time.Now().UTC()
+		target = time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	}
+	location := c.DefaultQuery("location", defaultLocation)
+
+	forecasts, err := forecastDemand(ctx, target, daypart, forecaster)
+	if err != nil {
+		return target, daypart, location, nil, err
+	}
+	lists, err := stationPrepLists(ctx, location, forecasts)
+	return target, daypart, location, lists, err
+}
+
+// stationPrepLists works out, for every forecast food with a recipe, the
+// whole batches needed on top of what is already prepped, and totals the
+// ingredients each station pulls against the location's stock.
+func stationPrepLists(ctx context.Context, location string, forecasts []FoodForecast) ([]StationPrepList, error) {
+	foodIds := make([]string, 0, len(forecasts))
+	for _, forecast := range forecasts {
+		foodIds = append(foodIds, forecast.Food_id)
+	}
+	cursor, err := recipeCollection().Find(ctx, bson.M{"food_id": bson.M{"$in": foodIds}})
+	if err != nil {
+		return nil, err
+	}
+	var recipes []models.Recipe
+	if err = cursor.All(ctx, &recipes); err != nil {
+		return nil, err
+	}
+	recipesByFood := map[string]models.Recipe{}
+	for _, recipe := range recipes {
+		recipesByFood[recipe.Food_id] = recipe
+	}
+
+	cursor, err = inventoryCollection().Find(ctx, bson.M{"location": location})
+	if err != nil {
+		return nil, err
+	}
+	var stock []models.InventoryItem
+	if err = cursor.All(ctx, &stock); err != nil {
+		return nil, err
+	}
+	stockBySku := map[string]models.InventoryItem{}
+	for _, item := range stock {
+		stockBySku[item.Sku] = item
+	}
+
+	byStation := map[string]*StationPrepList{}
+	pulls := map[string]map[string]float64{}
+	for _, forecast := range forecasts {
+		recipe, ok := recipesByFood[forecast.Food_id]
+		if !ok || recipe.Station == nil {
+			continue
+		}
+		onHand := 0.0
+		if recipe.Prepped_on_hand != nil {
+			onHand = *recipe.Prepped_on_hand
+		}
+		needed := math.Ceil(forecast.Expected_units - onHand)
+		if needed <= 0 {
+			continue
+		}
+		batchSize := 1
+		if recipe.Batch_size != nil {
+			batchSize = *recipe.Batch_size
+		}
+		batches := int(math.Ceil(needed / float64(batchSize)))
+
+		station := *recipe.Station
+		list, ok := byStation[station]
+		if !ok {
+			list = &StationPrepList{Station: station, Items: []StationPrepItem{}, Ingredients: []IngredientPull{}}
+			byStation[station] = list
+			pulls[station] = map[string]float64{}
+		}
+		list.Items = append(list.Items, StationPrepItem{
+			Food_id:        forecast.Food_id,
+			Name:           forecast.Name,
+			Expected_units: forecast.Expected_units,
+			On_hand:        onHand,
+			Units_to_prep:  batches * batchSize,
+			Batches:        batches,
+		})
+		for _, ingredient := range recipe.Ingredients {
+			pulls[station][*ingredient.Sku] += *ingredient.Quantity * float64(batches)
+		}
+	}
+
+	lists := []StationPrepList{}
+	for station, list := range byStation {
+		for sku, quantity := range pulls[station] {
+			pull := IngredientPull{Sku: sku, Name: sku, Quantity: toFixed(quantity, 3)}
+			if item, ok := stockBySku[sku]; ok {
+				pull.Name = stringOr(item.Name, sku)
+				pull.Unit = stringValue(item.Unit)
+				pull.On_hand = *item.On_hand
+			}
+			if pull.Quantity > pull.On_hand {
+				pull.Short = toFixed(pull.Quantity-pull.On_hand, 3)
+			}
+			list.Ingredients = append(list.Ingredients, pull)
+		}
+		sort.Slice(list.Ingredients, func(i, j int) bool { return list.Ingredients[i].Name < list.Ingredients[j].Name })
+		lists = append(lists, *list)
+	}
+	sort.Slice(lists, func(i, j int) bool { return lists[i].Station < lists[j].Station })
+	return lists, nil
+}
+
+// createPrepTasks adds a PREP task per prep list item, due when the daypart
+// starts or at lunch for whole-day lists. It returns how many were new.
+func createPrepTasks(ctx context.Context, target time.Time, daypart, location string, lists []StationPrepList) (int, error) {
+	dueHour := helpers.Dayparts["LUNCH"][0]
+	if daypart != "" {
+		dueHour = helpers.Dayparts[daypart][0]
+	}
+	dueAt := time.Date(target.Year(), target.Month(), target.Day(), dueHour, 0, 0, 0, time.Local)
+	taskDate := target.Format("2006-01-02")
+
+	upsert := true
+	created := 0
+	for _, list := range lists {
+		for _, item := range list.Items {
+			id := primitive.NewObjectID()
+			result, err := taskCollection().UpdateOne(
+				ctx,
+				bson.M{"kind": "PREP", "task_date": taskDate, "daypart": daypart, "food_id": item.Food_id, "location": location},
+				bson.D{{Key: "$setOnInsert", Value: bson.D{
+					{Key: "_id", Value: id},
+					{Key: "task_id", Value: id.Hex()},
+					{Key: "task_template_id", Value: ""},
+					{Key: "name", Value: fmt.Sprintf("Prep %d x %s", item.Units_to_prep, item.Name)},
+					{Key: "station", Value: list.Station},
+					{Key: "due_at", Value: dueAt},
+					{Key: "quantity", Value: item.Units_to_prep},
+					{Key: "status", Value: "PENDING"},
+				}}},
+				&options.UpdateOptions{Upsert: &upsert},
+			)
+			if err != nil {
+				return created, err
+			}
+			if result.UpsertedCount > 0 {
+				created++
+			}
+		}
+	}
+	return created, nil
+}
+
+// prepListLines lays out one station's prep list for printing, with a box
+// to tick per item and shortfalls flagged.
+func prepListLines(date, daypart string, list StationPrepList) []string {
+	rule := strings.Repeat("-", prepListWidth)
+	row := func(left, right string) string {
+		space := prepListWidth - len([]rune(left)) - len([]rune(right))
+		if space < 1 {
+			left = string([]rune(left)[:len([]rune(left))+space-1])
+			space = 1
+		}
+		return left + strings.Repeat(" ", space) + right
+	}
+
+	title := "PREP LIST " + date
+	if daypart != "" {
+		title += " " + daypart
+	}
+	lines := []string{title, "Station: " + list.Station, rule}
+	for _, item := range list.Items {
+		lines = append(lines, row("[ ] "+item.Name, fmt.Sprintf("%d (%dx)", item.Units_to_prep, item.Batches)))
+	}
+	if len(list.Ingredients) > 0 {
+		lines = append(lines, rule, "Pull from stock:")
+		for _, pull := range list.Ingredients {
+			lines = append(lines, row("  "+pull.Name, strings.TrimSpace(strconv.FormatFloat(pull.Quantity, 'f', -1, 64)+" "+pull.Unit)))
+			if pull.Short > 0 {
+				lines = append(lines, "    SHORT "+strconv.FormatFloat(pull.Short, 'f', -1, 64))
+			}
+		}
+	}
+	return lines
+}
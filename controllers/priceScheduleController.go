@@ -0,0 +1,433 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"restaurant-management/apierror"
+	"restaurant-management/config"
+	"restaurant-management/models"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var priceScheduleCollection = db.Collection("priceSchedule")
+
+var weekdayCodes = []string{"SUN", "MON", "TUE", "WED", "THU", "FRI", "SAT"}
+
+func GetPriceSchedules() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		result, err := priceScheduleCollection().Find(ctx, bson.M{})
+		if err != nil {
+			apierror.Render(c, apierror.Internal("error occurred while listing price schedules: "+err.Error()))
+			return
+		}
+
+		var allSchedules []bson.M
+		if err = result.All(ctx, &allSchedules); err != nil {
+			apierror.Render(c, apierror.Internal("error decoding price schedules: "+err.Error()))
+			return
+		}
+
+		c.JSON(http.StatusOK, allSchedules)
+	}
+}
+
+func GetPriceSchedule() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		scheduleId := c.Param("price_schedule_id")
+
+		var schedule models.PriceSchedule
+		err := priceScheduleCollection().FindOne(ctx, bson.M{"price_schedule_id": scheduleId}).Decode(&schedule)
+		if err != nil {
+			apierror.Render(c, apierror.NotFound("Price schedule not found"))
+			return
+		}
+
+		c.JSON(http.StatusOK, schedule)
+	}
+}
+
+func CreatePriceSchedule() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		var schedule models.PriceSchedule
+		if err := c.BindJSON(&schedule); err != nil {
+			apierror.Render(c, apierror.BadRequest("Invalid request data: "+err.Error()))
+			return
+		}
+
+		if err := validate.Struct(schedule); err != nil {
+			apierror.Render(c, apierror.FromValidation(err))
+			return
+		}
+
+		if err := validatePriceSchedule(schedule); err != nil {
+			apierror.Render(c, apierror.FromValidation(err))
+			return
+		}
+
+		if schedule.Active == nil {
+			active := true
+			schedule.Active = &active
+		}
+
+		now := time.Now().Format(time.RFC3339)
+		schedule.Created_at, _ = time.Parse(time.RFC3339, now)
+		schedule.Updated_at, _ = time.Parse(time.RFC3339, now)
+		schedule.ID = primitive.NewObjectID()
+		schedule.Price_schedule_id = schedule.ID.Hex()
+
+		result, insertErr := priceScheduleCollection().InsertOne(ctx, schedule)
+		if insertErr != nil {
+			apierror.Render(c, apierror.Internal("Could not create price schedule"))
+			return
+		}
+
+		c.JSON(http.StatusCreated, gin.H{"message": "Price schedule created", "data": result})
+	}
+}
+
+func UpdatePriceSchedule() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		scheduleId := c.Param("price_schedule_id")
+
+		var existing models.PriceSchedule
+		if err := priceScheduleCollection().FindOne(ctx, bson.M{"price_schedule_id": scheduleId}).Decode(&existing); err != nil {
+			apierror.Render(c, apierror.NotFound("Price schedule not found"))
+			return
+		}
+
+		var schedule models.PriceSchedule
+		if err := c.BindJSON(&schedule); err != nil {
+			apierror.Render(c, apierror.BadRequest("Invalid request data: "+err.Error()))
+			return
+		}
+
+		var updateObj primitive.D
+		if schedule.Name != nil {
+			existing.Name = schedule.Name
+			updateObj = append(updateObj, bson.E{Key: "name", Value: schedule.Name})
+		}
+		if schedule.Food_ids != nil {
+			existing.Food_ids = schedule.Food_ids
+			updateObj = append(updateObj, bson.E{Key: "food_ids", Value: schedule.Food_ids})
+		}
+		if schedule.Category != nil {
+			existing.Category = schedule.Category
+			updateObj = append(updateObj, bson.E{Key: "category", Value: schedule.Category})
+		}
+		if schedule.Discount_percent != nil {
+			existing.Discount_percent = schedule.Discount_percent
+			updateObj = append(updateObj, bson.E{Key: "discount_percent", Value: schedule.Discount_percent})
+		}
+		if schedule.Days != nil {
+			existing.Days = schedule.Days
+			updateObj = append(updateObj, bson.E{Key: "days", Value: schedule.Days})
+		}
+		if schedule.Start_time != nil {
+			existing.Start_time = schedule.Start_time
+			updateObj = append(updateObj, bson.E{Key: "start_time", Value: schedule.Start_time})
+		}
+		if schedule.End_time != nil {
+			existing.End_time = schedule.End_time
+			updateObj = append(updateObj, bson.E{Key: "end_time", Value: schedule.End_time})
+		}
+		if schedule.Effective_from != nil {
+			existing.Effective_from = schedule.Effective_from
+			updateObj = append(updateObj, bson.E{Key: "effective_from", Value: schedule.Effective_from})
+		}
+		if schedule.Effective_to != nil {
+			existing.Effective_to = schedule.Effective_to
+			updateObj = append(updateObj, bson.E{Key: "effective_to", Value: schedule.Effective_to})
+		}
+		if schedule.Active != nil {
+			updateObj = append(updateObj, bson.E{Key: "active", Value: schedule.Active})
+		}
+
+		// Validate the merged schedule so partial updates cannot leave it inconsistent
+		if err := validate.Struct(existing); err != nil {
+			apierror.Render(c, apierror.FromValidation(err))
+			return
+		}
+		if err := validatePriceSchedule(existing); err != nil {
+			apierror.Render(c, apierror.FromValidation(err))
+			return
+		}
+
+		updatedAt, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		updateObj = append(updateObj, bson.E{Key: "updated_at", Value: updatedAt})
+
+		result, err := priceScheduleCollection().UpdateOne(
+			ctx,
+			bson.M{"price_schedule_id": scheduleId},
+			bson.D{{Key: "$set", Value: updateObj}},
+		)
+		if err != nil {
+			apierror.Render(c, apierror.Internal("Update failed: "+err.Error()))
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Price schedule updated successfully", "result": result})
+	}
+}
+
+// GetPriceScheduleCalendar lists, for every day in [from, to], the schedules
+// that take effect that day and their time windows.
+func GetPriceScheduleCalendar() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		today := time.Now().UTC().Truncate(24 * time.Hour)
+		from, to := today, today.AddDate(0, 0, 6)
+		if value := c.Query("from"); value != "" {
+			parsed, err := time.Parse("2006-01-02", value)
+			if err != nil {
+				apierror.Render(c, apierror.BadRequest("from must be formatted as YYYY-MM-DD"))
+				return
+			}
+			from = parsed
+		}
+		if value := c.Query("to"); value != "" {
+			parsed, err := time.Parse("2006-01-02", value)
+			if err != nil {
+				apierror.Render(c, apierror.BadRequest("to must be formatted as YYYY-MM-DD"))
+				return
+			}
+			to = parsed
+		}
+		if to.Before(from) || to.Sub(from) > 92*24*time.Hour {
+			apierror.Render(c, apierror.BadRequest("to must be after from and within 92 days"))
+			return
+		}
+
+		result, err := priceScheduleCollection().Find(ctx, bson.M{"active": true})
+		if err != nil {
+			apierror.Render(c, apierror.Internal("error occurred while listing price schedules: "+err.Error()))
+			return
+		}
+		var schedules []models.PriceSchedule
+		if err = result.All(ctx, &schedules); err != nil {
+			apierror.Render(c, apierror.Internal("error decoding price schedules: "+err.Error()))
+			return
+		}
+
+		calendar := []gin.H{}
+		for day := from; !day.After(to); day = day.AddDate(0, 0, 1) {
+			entries := []gin.H{}
+			for _, schedule := range schedules {
+				if !scheduleRunsOn(schedule, day) {
+					continue
+				}
+				entries = append(entries, gin.H{
+					"price_schedule_id": schedule.Price_schedule_id,
+					"name":              schedule.Name,
+					"start_time":        schedule.Start_time,
+					"end_time":          schedule.End_time,
+					"discount_percent":  schedule.Discount_percent,
+				})
+			}
+			calendar = append(calendar, gin.H{"date": day.Format("2006-01-02"), "schedules": entries})
+		}
+
+		c.JSON(http.StatusOK, calendar)
+	}
+}
+
+func validatePriceSchedule(schedule models.PriceSchedule) error {
+	if len(schedule.Food_ids) == 0 && (schedule.Category == nil || *schedule.Category == "") {
+		return fmt.Errorf("a price schedule must target food_ids or a category")
+	}
+	start, err := time.Parse("15:04", *schedule.Start_time)
+	if err != nil {
+		return fmt.Errorf("start_time must be formatted as HH:MM")
+	}
+	end, err := time.Parse("15:04", *schedule.End_time)
+	if err != nil {
+		return fmt.Errorf("end_time must be formatted as HH:MM")
+	}
+	if !end.After(start) {
+		return fmt.Errorf("end_time must be after start_time")
+	}
+	if schedule.Effective_from != nil && schedule.Effective_to != nil && schedule.Effective_to.Before(*schedule.Effective_from) {
+		return fmt.Errorf("effective_to must be after effective_from")
+	}
+	return nil
+}
+
+// scheduleRunsOn reports whether the schedule is in effect on the given day,
+// ignoring the time of day.
+func scheduleRunsOn(schedule models.PriceSchedule, day time.Time) bool {
+	dayStart := time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, day.Location())
+	if schedule.Effective_from != nil && dayStart.AddDate(0, 0, 1).Before(*schedule.Effective_from) {
+		return false
+	}
+	if schedule.Effective_to != nil && dayStart.After(*schedule.Effective_to) {
+		return false
+	}
+	code := weekdayCodes[day.Weekday()]
+	for _, d := range schedule.Days {
+		if d == code {
+			return true
+		}
+	}
+	return false
+}
+
+// scheduleAppliesAt reports whether the schedule's discount is live at t.
+func scheduleAppliesAt(schedule models.PriceSchedule, t time.Time) bool {
+	if schedule.Active == nil || !*schedule.Active || !scheduleRunsOn(schedule, t) {
+		return false
+	}
+	if schedule.Effective_from != nil && t.Before(*schedule.Effective_from) {
+		return false
+	}
+	if schedule.Effective_to != nil && t.After(*schedule.Effective_to) {
+		return false
+	}
+	clock := t.Format("15:04")
+	return clock >= *schedule.Start_time && clock < *schedule.End_time
+}
+
+// priceScheduleAdjustments returns the happy-hour discounts that apply to an
+// order item at the given time. Only the best discount is applied.
+func priceScheduleAdjustments(ctx context.Context, orderItem models.OrderItem, at time.Time) ([]models.PriceAdjustment, error) {
+	if orderItem.Food_id == nil || orderItem.Unit_price == nil {
+		return nil, nil
+	}
+
+	filter := bson.M{"active": true, "food_ids": *orderItem.Food_id}
+	var food models.Food
+	var menu models.Menu
+	if err := foodCollection().FindOne(ctx, bson.M{"food_id": *orderItem.Food_id}).Decode(&food); err == nil && food.Menu_id != nil {
+		if err := menuCollection().FindOne(ctx, bson.M{"menu_id": *food.Menu_id}).Decode(&menu); err == nil && menu.Category != "" {
+			filter = bson.M{"active": true, "$or": bson.A{
+				bson.M{"food_ids": *orderItem.Food_id},
+				bson.M{"category": menu.Category},
+			}}
+		}
+	}
+
+	result, err := priceScheduleCollection().Find(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	var schedules []models.PriceSchedule
+	if err = result.All(ctx, &schedules); err != nil {
+		return nil, err
+	}
+
+	var best *models.PriceSchedule
+	for i := range schedules {
+		if !scheduleAppliesAt(schedules[i], at) {
+			continue
+		}
+		if best == nil || *schedules[i].Discount_percent > *best.Discount_percent {
+			best = &schedules[i]
+		}
+	}
+	if best == nil {
+		return nil, nil
+	}
+
+	return []models.PriceAdjustment{{
+		Type:        "HAPPY_HOUR",
+		Source_id:   best.Price_schedule_id,
+		Description: fmt.Sprintf("%s (%g%% off)", *best.Name, *best.Discount_percent),
+		Amount:      -toFixed(*orderItem.Unit_price**best.Discount_percent/100, 2),
+	}}, nil
+}
+
+type special struct {
+	Food          models.Food
+	Schedule      models.PriceSchedule
+	Special_price float64
+}
+
+// findSpecials returns up to limit available foods discounted by a schedule
+// accepted by include, each paired with the schedule and discounted price.
+func findSpecials(ctx context.Context, include func(models.PriceSchedule) bool, limit int) ([]special, error) {
+	result, err := priceScheduleCollection().Find(ctx, bson.M{"active": true})
+	if err != nil {
+		return nil, err
+	}
+	var schedules []models.PriceSchedule
+	if err = result.All(ctx, &schedules); err != nil {
+		return nil, err
+	}
+
+	specials := []special{}
+	seen := map[string]bool{}
+	for _, schedule := range schedules {
+		if !include(schedule) {
+			continue
+		}
+
+		filter := bson.M{"food_id": bson.M{"$in": schedule.Food_ids}}
+		if schedule.Category != nil && *schedule.Category != "" {
+			menuIds, err := menuIdsInCategory(ctx, *schedule.Category)
+			if err != nil {
+				return nil, err
+			}
+			filter = bson.M{"$or": bson.A{filter, bson.M{"menu_id": bson.M{"$in": menuIds}}}}
+		}
+		filter = bson.M{"$and": bson.A{filter, bson.M{"available": bson.M{"$ne": false}}}}
+
+		cursor, err := foodCollection().Find(ctx, filter, options.Find().SetLimit(int64(limit)))
+		if err != nil {
+			return nil, err
+		}
+		var foods []models.Food
+		if err = cursor.All(ctx, &foods); err != nil {
+			return nil, err
+		}
+		for _, food := range foods {
+			if seen[food.Food_id] || len(specials) >= limit {
+				continue
+			}
+			seen[food.Food_id] = true
+			price := 0.0
+			if food.Price != nil {
+				price = *food.Price
+			}
+			specials = append(specials, special{
+				Food:          food,
+				Schedule:      schedule,
+				Special_price: toFixed(price*(1-*schedule.Discount_percent/100), 2),
+			})
+		}
+	}
+	return specials, nil
+}
+
+func menuIdsInCategory(ctx context.Context, category string) ([]string, error) {
+	cursor, err := menuCollection().Find(ctx, bson.M{"category": category})
+	if err != nil {
+		return nil, err
+	}
+	var menus []models.Menu
+	if err = cursor.All(ctx, &menus); err != nil {
+		return nil, err
+	}
+	menuIds := make([]string, 0, len(menus))
+	for _, menu := range menus {
+		menuIds = append(menuIds, menu.Menu_id)
+	}
+	return menuIds, nil
+}
@@ -0,0 +1,216 @@
+package controllers
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"restaurant-management/apierror"
+	"restaurant-management/config"
+	"restaurant-management/models"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var purchaseOrderCollection = db.Collection("purchaseOrder")
+var receivingRecordCollection = db.Collection("receivingRecord")
+
+func GetPurchaseOrders() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		filter := bson.M{}
+		if supplier := c.Query("supplier"); supplier != "" {
+			filter["supplier"] = supplier
+		}
+
+		opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}})
+		result, err := purchaseOrderCollection().Find(ctx, filter, opts)
+		if err != nil {
+			apierror.Render(c, apierror.Internal("error occurred while listing purchase orders: "+err.Error()))
+			return
+		}
+
+		var allOrders []bson.M
+		if err = result.All(ctx, &allOrders); err != nil {
+			apierror.Render(c, apierror.Internal("error decoding purchase orders: "+err.Error()))
+			return
+		}
+
+		c.JSON(http.StatusOK, allOrders)
+	}
+}
+
+// GetPurchaseOrder returns the PO with the quantity received so far per SKU.
+func GetPurchaseOrder() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		var purchaseOrder models.PurchaseOrder
+		err := purchaseOrderCollection().FindOne(ctx, bson.M{"purchase_order_id": c.Param("purchase_order_id")}).Decode(&purchaseOrder)
+		if err != nil {
+			apierror.Render(c, apierror.NotFound("Purchase order not found"))
+			return
+		}
+
+		received, err := receivedQuantities(ctx, purchaseOrder.Purchase_order_id)
+		if err != nil {
+			apierror.Render(c, apierror.Internal("error totalling received quantities: "+err.Error()))
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"purchase_order": purchaseOrder, "received": received})
+	}
+}
+
+func CreatePurchaseOrder() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		var purchaseOrder models.PurchaseOrder
+		if err := c.BindJSON(&purchaseOrder); err != nil {
+			apierror.Render(c, apierror.BadRequest("Invalid request data: "+err.Error()))
+			return
+		}
+		if err := validate.Struct(purchaseOrder); err != nil {
+			apierror.Render(c, apierror.FromValidation(err))
+			return
+		}
+
+		count, err := purchaseOrderCollection().CountDocuments(ctx, bson.M{"po_number": purchaseOrder.Po_number})
+		if err != nil {
+			apierror.Render(c, apierror.Internal("error checking po_number: "+err.Error()))
+			return
+		}
+		if count > 0 {
+			apierror.Render(c, apierror.Conflict("A purchase order with this po_number already exists"))
+			return
+		}
+
+		purchaseOrder.Created_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		purchaseOrder.Updated_at = purchaseOrder.Created_at
+		purchaseOrder.ID = primitive.NewObjectID()
+		purchaseOrder.Purchase_order_id = purchaseOrder.ID.Hex()
+
+		result, err := purchaseOrderCollection().InsertOne(ctx, purchaseOrder)
+		if err != nil {
+			apierror.Render(c, apierror.Internal("Purchase order was not created: "+err.Error()))
+			return
+		}
+
+		c.JSON(http.StatusCreated, gin.H{"message": "Purchase order created", "data": result})
+	}
+}
+
+func GetReceivingRecords() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		opts := options.Find().SetSort(bson.D{{Key: "received_at", Value: 1}})
+		result, err := receivingRecordCollection().Find(ctx, bson.M{"purchase_order_id": c.Param("purchase_order_id")}, opts)
+		if err != nil {
+			apierror.Render(c, apierror.Internal("error occurred while listing receiving records: "+err.Error()))
+			return
+		}
+
+		var allRecords []bson.M
+		if err = result.All(ctx, &allRecords); err != nil {
+			apierror.Render(c, apierror.Internal("error decoding receiving records: "+err.Error()))
+			return
+		}
+
+		c.JSON(http.StatusOK, allRecords)
+	}
+}
+
+// CreateReceivingRecord logs a delivery against a PO and re-runs matching for
+// any supplier invoices already billed against it.
+func CreateReceivingRecord() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		var purchaseOrder models.PurchaseOrder
+		err := purchaseOrderCollection().FindOne(ctx, bson.M{"purchase_order_id": c.Param("purchase_order_id")}).Decode(&purchaseOrder)
+		if err != nil {
+			apierror.Render(c, apierror.NotFound("Purchase order not found"))
+			return
+		}
+
+		var record models.ReceivingRecord
+		if err := c.BindJSON(&record); err != nil {
+			apierror.Render(c, apierror.BadRequest("Invalid request data: "+err.Error()))
+			return
+		}
+		if err := validate.Struct(record); err != nil {
+			apierror.Render(c, apierror.FromValidation(err))
+			return
+		}
+
+		onOrder := map[string]bool{}
+		for _, line := range purchaseOrder.Lines {
+			onOrder[*line.Sku] = true
+		}
+		for _, line := range record.Lines {
+			if !onOrder[*line.Sku] {
+				apierror.Render(c, apierror.BadRequest("sku "+*line.Sku+" is not on this purchase order"))
+				return
+			}
+		}
+
+		record.Purchase_order_id = purchaseOrder.Purchase_order_id
+		record.Created_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		if record.Received_at.IsZero() {
+			record.Received_at = record.Created_at
+		}
+		record.ID = primitive.NewObjectID()
+		record.Receiving_record_id = record.ID.Hex()
+
+		result, err := receivingRecordCollection().InsertOne(ctx, record)
+		if err != nil {
+			apierror.Render(c, apierror.Internal("Receiving record was not created: "+err.Error()))
+			return
+		}
+
+		if err := rematchSupplierInvoices(ctx, *purchaseOrder.Po_number); err != nil {
+			log.Println("Error re-matching supplier invoices:", err)
+		}
+
+		c.JSON(http.StatusCreated, gin.H{"message": "Receiving record created", "data": result})
+	}
+}
+
+// receivedQuantities sums every receiving record for a PO by SKU.
+func receivedQuantities(ctx context.Context, purchaseOrderId string) (map[string]float64, error) {
+	cursor, err := receivingRecordCollection().Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$match", Value: bson.D{{Key: "purchase_order_id", Value: purchaseOrderId}}}},
+		{{Key: "$unwind", Value: "$lines"}},
+		{{Key: "$group", Value: bson.D{
+			{Key: "_id", Value: "$lines.sku"},
+			{Key: "quantity", Value: bson.D{{Key: "$sum", Value: "$lines.quantity"}}},
+		}}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	var totals []struct {
+		Sku      string  `bson:"_id"`
+		Quantity float64 `bson:"quantity"`
+	}
+	if err = cursor.All(ctx, &totals); err != nil {
+		return nil, err
+	}
+	received := map[string]float64{}
+	for _, total := range totals {
+		received[total.Sku] = total.Quantity
+	}
+	return received, nil
+}
@@ -0,0 +1,530 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"image"
+	_ "image/jpeg"
+	_ "image/png"
+	"net/http"
+	"os"
+	"path/filepath"
+	"restaurant-management/apierror"
+	"restaurant-management/config"
+	"restaurant-management/helpers"
+	"restaurant-management/i18n"
+	"restaurant-management/models"
+	"restaurant-management/receipt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var receiptTemplateCollection = db.Collection("receiptTemplate")
+
+func GetReceiptTemplates() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		result, err := receiptTemplateCollection().Find(ctx, bson.M{})
+		if err != nil {
+			apierror.Render(c, apierror.Internal("error occurred while listing receipt templates: "+err.Error()))
+			return
+		}
+
+		var allTemplates []bson.M
+		if err = result.All(ctx, &allTemplates); err != nil {
+			apierror.Render(c, apierror.Internal("error decoding receipt templates: "+err.Error()))
+			return
+		}
+
+		c.JSON(http.StatusOK, allTemplates)
+	}
+}
+
+// GetReceiptTemplate returns the template a location prints with, i.e. its
+// overrides merged over the default template.
+func GetReceiptTemplate() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		template, err := effectiveReceiptTemplate(ctx, c.Param("location"))
+		if err != nil {
+			apierror.Render(c, apierror.Internal("error occurred while loading receipt template: "+err.Error()))
+			return
+		}
+
+		c.JSON(http.StatusOK, template)
+	}
+}
+
+// UpdateReceiptTemplate creates or updates the template of a location. An
+// empty string clears a location override so the default applies again.
+func UpdateReceiptTemplate() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		var template models.ReceiptTemplate
+		if err := c.BindJSON(&template); err != nil {
+			apierror.Render(c, apierror.BadRequest("Invalid request data: "+err.Error()))
+			return
+		}
+
+		if err := validate.Struct(template); err != nil {
+			apierror.Render(c, apierror.FromValidation(err))
+			return
+		}
+
+		if template.Locale != nil && *template.Locale != "" {
+			if _, ok := i18n.Lookup(*template.Locale); !ok {
+				apierror.Render(c, apierror.BadRequest("Unsupported locale, use one of: "+strings.Join(i18n.Locales(), ", ")))
+				return
+			}
+		}
+
+		location := c.Param("location")
+		var updateObj primitive.D
+		var unsetObj primitive.D
+		fields := []struct {
+			key   string
+			value *string
+		}{
+			{"business_name", template.Business_name},
+			{"header", template.Header},
+			{"footer", template.Footer},
+			{"tax_id", template.Tax_id},
+			{"promo_message", template.Promo_message},
+			{"locale", template.Locale},
+			{"currency", template.Currency},
+		}
+		for _, field := range fields {
+			if field.value == nil {
+				continue
+			}
+			if *field.value == "" && location != defaultLocation {
+				unsetObj = append(unsetObj, bson.E{Key: field.key, Value: ""})
+				continue
+			}
+			updateObj = append(updateObj, bson.E{Key: field.key, Value: field.value})
+		}
+		if template.Paper_width != nil {
+			updateObj = append(updateObj, bson.E{Key: "paper_width", Value: template.Paper_width})
+		}
+
+		now, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		updateObj = append(updateObj, bson.E{Key: "updated_at", Value: now})
+
+		update := bson.D{
+			{Key: "$set", Value: updateObj},
+			{Key: "$setOnInsert", Value: bson.D{{Key: "_id", Value: primitive.NewObjectID()}, {Key: "created_at", Value: now}}},
+		}
+		if len(unsetObj) > 0 {
+			update = append(update, bson.E{Key: "$unset", Value: unsetObj})
+		}
+
+		upsert := true
+		opt := options.UpdateOptions{Upsert: &upsert}
+
+		result, err := receiptTemplateCollection().UpdateOne(ctx, bson.M{"location": location}, update, &opt)
+		if err != nil {
+			apierror.Render(c, apierror.Internal("Update failed: "+err.Error()))
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Receipt template updated successfully", "result": result})
+	}
+}
+
+// UploadReceiptLogo stores the logo printed at the top of a location's
+// receipts.
+func UploadReceiptLogo() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		location := c.Param("location")
+		url, err := helpers.SaveUpload(c, "logo", "receipt-logos", location, helpers.ImageExtensions)
+		if err != nil {
+			apierror.Render(c, apierror.BadRequest(err.Error()))
+			return
+		}
+
+		now, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		upsert := true
+		opt := options.UpdateOptions{Upsert: &upsert}
+
+		_, err = receiptTemplateCollection().UpdateOne(
+			ctx,
+			bson.M{"location": location},
+			bson.D{
+				{Key: "$set", Value: bson.D{{Key: "logo_url", Value: url}, {Key: "updated_at", Value: now}}},
+				{Key: "$setOnInsert", Value: bson.D{{Key: "_id", Value: primitive.NewObjectID()}, {Key: "created_at", Value: now}}},
+			},
+			&opt,
+		)
+		if err != nil {
+			apierror.Render(c, apierror.Internal("Update failed: "+err.Error()))
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Receipt logo uploaded", "logo_url": url})
+	}
+}
+
+// PreviewReceiptTemplate renders a sample receipt with the location's
+// template. Fields in the request body are applied on top without being
+// saved, so edits can be checked before they go live.
+func PreviewReceiptTemplate() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		var draft models.ReceiptTemplate
+		if c.Request.ContentLength > 0 {
+			if err := c.BindJSON(&draft); err != nil {
+				apierror.Render(c, apierror.BadRequest("Invalid request data: "+err.Error()))
+				return
+			}
+			if err := validate.Struct(draft); err != nil {
+				apierror.Render(c, apierror.FromValidation(err))
+				return
+			}
+		}
+
+		template, err := effectiveReceiptTemplate(ctx, c.Param("location"))
+		if err != nil {
+			apierror.Render(c, apierror.Internal("error occurred while loading receipt template: "+err.Error()))
+			return
+		}
+		mergeReceiptTemplate(&template, draft)
+
+		now := time.Now()
+		sample := receipt.Receipt{
+			Number: "PREVIEW",
+			Date:   now,
+			Table:  "12",
+			Lines: []receipt.Line{
+				{Description: "Margherita Pizza (M)", Amount: 12.50},
+				{Description: "Extra cheese", Amount: 1.50, Indent: true},
+				{Description: "Caesar Salad (S)", Amount: 7.00},
+				{Description: "Lemonade (L)", Amount: 3.75},
+			},
+			Subtotal:       24.75,
+			Fees:           []receipt.Line{{Description: "Service fee", Amount: 2.00}},
+			Total:          26.75,
+			Payment_method: "CARD",
+			Payment_status: "PAID",
+		}
+
+		writeReceipt(c, template, sample)
+	}
+}
+
+// GetInvoiceReceipt renders the receipt of an invoice as ?format=text, escpos
+// or pdf (default), using the invoice's location unless ?location= is given.
+func GetInvoiceReceipt() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		var invoice models.Invoice
+		if err := invoiceCollection().FindOne(ctx, bson.M{"invoice_id": c.Param("invoice_id")}).Decode(&invoice); err != nil {
+			apierror.Render(c, apierror.NotFound("invoice not found"))
+			return
+		}
+
+		r, err := invoiceReceipt(ctx, invoice)
+		if err != nil {
+			apierror.Render(c, apierror.Internal("error occurred while building receipt: "+err.Error()))
+			return
+		}
+
+		location := c.Query("location")
+		if location == "" {
+			location = stringValue(invoice.Location)
+		}
+		template, err := effectiveReceiptTemplate(ctx, location)
+		if err != nil {
+			apierror.Render(c, apierror.Internal("error occurred while loading receipt template: "+err.Error()))
+			return
+		}
+
+		writeReceipt(c, template, r)
+	}
+}
+
+// effectiveReceiptTemplate merges a location's overrides over the default
+// template. Missing templates are not an error; the receipt then just has no
+// branding.
+func effectiveReceiptTemplate(ctx context.Context, location string) (models.ReceiptTemplate, error) {
+	template := models.ReceiptTemplate{Location: defaultLocation}
+	err := receiptTemplateCollection().FindOne(ctx, bson.M{"location": defaultLocation}).Decode(&template)
+	if err != nil && err != mongo.ErrNoDocuments {
+		return template, err
+	}
+	if location == "" || location == defaultLocation {
+		return template, nil
+	}
+
+	var override models.ReceiptTemplate
+	err = receiptTemplateCollection().FindOne(ctx, bson.M{"location": location}).Decode(&override)
+	if err != nil && err != mongo.ErrNoDocuments {
+		return template, err
+	}
+	mergeReceiptTemplate(&template, override)
+	template.Location = location
+	return template, nil
+}
+
+func mergeReceiptTemplate(template *models.ReceiptTemplate, override models.ReceiptTemplate) {
+	if override.Business_name != nil {
+		template.Business_name = override.Business_name
+	}
+	if override.Header != nil {
+		template.Header = override.Header
+	}
+	if override.Footer != nil {
+		template.Footer = override.Footer
+	}
+	if override.Logo_url != nil {
+		template.Logo_url = override.Logo_url
+	}
+	if override.Tax_id != nil {
+		template.Tax_id = override.Tax_id
+	}
+	if override.Promo_message != nil {
+		template.Promo_message = override.Promo_message
+	}
+	if override.Paper_width != nil {
+		template.Paper_width = override.Paper_width
+	}
+	if override.Locale != nil {
+		template.Locale = override.Locale
+	}
+	if override.Currency != nil {
+		template.Currency = override.Currency
+	}
+}
+
+// invoiceReceipt collects the lines of an invoice's order: each item with its
+// adjustments, then order-level fees and the tax per rate.
+func invoiceReceipt(ctx context.Context, invoice models.Invoice) (receipt.Receipt, error) {
+	// Unfinalized invoices have no number yet, so fall back to the id
+	number := invoice.Invoice_id
+	if invoice.Invoice_number != nil {
+		number = *invoice.Invoice_number
+	}
+	r := receipt.Receipt{
+		Number:         number,
+		Date:           invoice.Created_at,
+		Payment_method: stringValue(invoice.Payment_method),
+		Payment_status: stringValue(invoice.Payment_status),
+	}
+
+	var order models.Order
+	if err := orderCollection().FindOne(ctx, bson.M{"order_id": invoice.Order_id}).Decode(&order); err != nil {
+		return r, fmt.Errorf("order %s: %w", invoice.Order_id, err)
+	}
+	if order.Table_id != nil {
+		var table models.Table
+		if err := tableCollection().FindOne(ctx, bson.M{"table_id": order.Table_id}).Decode(&table); err == nil && table.Table_number != nil {
+			r.Table = fmt.Sprint(*table.Table_number)
+		}
+	}
+
+	orderItems, err := orderItemsOf(ctx, invoice.Order_id)
+	if err != nil {
+		return r, err
+	}
+	orderItems, pricing, err := priceOrder(ctx, order, orderItems)
+	if err != nil {
+		return r, err
+	}
+	// Part of a split check lists the items it bills, or all of them for
+	// an even split, and totals to its share
+	if invoice.Split != nil {
+		r.Split = fmt.Sprintf("%d/%d", invoice.Split.Part, invoice.Split.Parts)
+		if len(invoice.Split.Order_item_ids) > 0 {
+			billed := map[string]bool{}
+			for _, id := range invoice.Split.Order_item_ids {
+				billed[id] = true
+			}
+			kept := []models.OrderItem{}
+			for _, orderItem := range orderItems {
+				if billed[orderItem.Order_item_id] {
+					kept = append(kept, orderItem)
+				}
+			}
+			orderItems = kept
+		}
+	}
+
+	foodIds := []string{}
+	for _, orderItem := range orderItems {
+		if orderItem.Food_id != nil {
+			foodIds = append(foodIds, *orderItem.Food_id)
+		}
+	}
+	foods, err := foodsById(ctx, foodIds)
+	if err != nil {
+		return r, err
+	}
+
+	comboIds := []string{}
+	for _, orderItem := range orderItems {
+		if orderItem.Combo_id != nil {
+			comboIds = append(comboIds, *orderItem.Combo_id)
+		}
+	}
+	combos, err := combosById(ctx, comboIds)
+	if err != nil {
+		return r, err
+	}
+
+	// The foods of a combo print as one line priced at the combo's price
+	comboLines := map[string]int{}
+	for _, orderItem := range orderItems {
+		price := 0.0
+		if orderItem.Unit_price != nil {
+			price = *orderItem.Unit_price
+		}
+		r.Subtotal += price
+		if index, ok := comboLines[orderItem.Combo_line_id]; ok {
+			r.Lines[index].Amount = toFixed(r.Lines[index].Amount+price, 2)
+		} else {
+			name := "Item"
+			if food, ok := foods[stringValue(orderItem.Food_id)]; ok && food.Name != nil {
+				name = *food.Name
+			}
+			if orderItem.Quantity != nil {
+				name += " (" + *orderItem.Quantity + ")"
+			}
+			if orderItem.Combo_line_id != "" {
+				name = "Combo"
+				if combo, ok := combos[stringValue(orderItem.Combo_id)]; ok {
+					name = *combo.Name
+				}
+				comboLines[orderItem.Combo_line_id] = len(r.Lines)
+			}
+			r.Lines = append(r.Lines, receipt.Line{Description: name, Amount: price})
+		}
+		for _, adjustment := range orderItem.Adjustments {
+			r.Lines = append(r.Lines, receipt.Line{Description: adjustment.Description, Amount: adjustment.Amount, Indent: true})
+			r.Subtotal += adjustment.Amount
+		}
+	}
+	r.Subtotal = toFixed(r.Subtotal, 2)
+
+	r.Total = r.Subtotal
+	if invoice.Split == nil {
+		for _, fee := range order.Fees {
+			r.Fees = append(r.Fees, receipt.Line{Description: fee.Description, Amount: fee.Amount})
+			r.Total += fee.Amount
+		}
+	}
+
+	// A finalized or split invoice shows the tax it was issued with
+	lines := pricing.Tax_lines
+	if invoice.Finalized_at != nil || invoice.Split != nil {
+		lines = invoice.Tax_lines
+	}
+	taxes := map[receipt.Tax]int{}
+	for _, line := range lines {
+		key := receipt.Tax{Rate: line.Rate, Exclusive: line.Exclusive}
+		index, ok := taxes[key]
+		if !ok {
+			index = len(r.Taxes)
+			taxes[key] = index
+			r.Taxes = append(r.Taxes, key)
+		}
+		r.Taxes[index].Amount = toFixed(r.Taxes[index].Amount+line.Tax, 2)
+		if line.Exclusive {
+			r.Total += line.Tax
+		}
+	}
+	sort.Slice(r.Taxes, func(i, j int) bool { return r.Taxes[i].Rate > r.Taxes[j].Rate })
+	if invoice.Split != nil && invoice.Payment_due != nil {
+		r.Total = *invoice.Payment_due
+	}
+	if invoice.Cash_rounding != nil {
+		r.Cash_rounding = *invoice.Cash_rounding
+		r.Total += r.Cash_rounding
+	}
+	if invoice.Tip != nil {
+		r.Tip = *invoice.Tip
+		r.Total += r.Tip
+	}
+	r.Total = toFixed(r.Total, 2)
+	if invoice.Deposit_credit != nil {
+		r.Deposit = *invoice.Deposit_credit
+	}
+	return r, nil
+}
+
+// receiptLayout turns a stored template into the one the receipt renderers
+// take, loading its logo.
+func receiptLayout(template models.ReceiptTemplate) receipt.Template {
+	t := receipt.Template{
+		Business_name: stringValue(template.Business_name),
+		Header:        stringValue(template.Header),
+		Footer:        stringValue(template.Footer),
+		Tax_id:        stringValue(template.Tax_id),
+		Promo_message: stringValue(template.Promo_message),
+		Locale:        i18n.Get(stringValue(template.Locale)),
+		Currency:      stringValue(template.Currency),
+	}
+	if template.Paper_width != nil {
+		t.Width = *template.Paper_width
+	}
+	if template.Logo_url != nil {
+		// A missing or unreadable logo should not stop the receipt printing.
+		t.Logo, _ = loadReceiptLogo(*template.Logo_url)
+	}
+	return t
+}
+
+// writeReceipt responds with the receipt in the requested ?format=.
+func writeReceipt(c *gin.Context, template models.ReceiptTemplate, r receipt.Receipt) {
+	t := receiptLayout(template)
+
+	filename := "receipt-" + r.Number
+	switch c.DefaultQuery("format", "pdf") {
+	case "text":
+		c.String(http.StatusOK, receipt.Text(t, r))
+	case "escpos":
+		c.Header("Content-Disposition", "attachment; filename="+filename+".bin")
+		c.Data(http.StatusOK, "application/octet-stream", receipt.ESCPOS(t, r))
+	case "pdf":
+		pdf, err := receipt.PDF(t, r)
+		if err != nil {
+			apierror.Render(c, apierror.Internal("error occurred while rendering receipt: "+err.Error()))
+			return
+		}
+		c.Header("Content-Disposition", "inline; filename="+filename+".pdf")
+		c.Data(http.StatusOK, "application/pdf", pdf)
+	default:
+		apierror.Render(c, apierror.BadRequest("format must be pdf, escpos or text"))
+	}
+}
+
+// loadReceiptLogo reads an uploaded logo back from UploadRoot().
+func loadReceiptLogo(url string) (image.Image, error) {
+	path := filepath.Join(helpers.UploadRoot(), filepath.FromSlash(strings.TrimPrefix(url, "/uploads/")))
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	img, _, err := image.Decode(file)
+	return img, err
+}
@@ -0,0 +1,183 @@
+package controllers
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"restaurant-management/apierror"
+	"restaurant-management/config"
+	"restaurant-management/models"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var recommendationCollection = db.Collection("recommendation")
+
+// maxRecommendations is how many foods are kept per food.
+const maxRecommendations = 10
+
+// GetFoodRecommendations returns up to ?limit= (default 3) available foods
+// frequently bought together with a food, for cross-selling.
+func GetFoodRecommendations() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		limit, err := strconv.Atoi(c.DefaultQuery("limit", "3"))
+		if err != nil || limit < 1 || limit > maxRecommendations {
+			apierror.Render(c, apierror.BadRequest("limit must be between 1 and "+strconv.Itoa(maxRecommendations)))
+			return
+		}
+
+		var recommendations models.FoodRecommendations
+		err = recommendationCollection().FindOne(ctx, bson.M{"food_id": c.Param("food_id")}).Decode(&recommendations)
+		if err == mongo.ErrNoDocuments {
+			c.JSON(http.StatusOK, gin.H{"food_id": c.Param("food_id"), "recommendations": []gin.H{}})
+			return
+		}
+		if err != nil {
+			apierror.Render(c, apierror.Internal("error occurred while loading recommendations: "+err.Error()))
+			return
+		}
+
+		foodIds := []string{}
+		for _, item := range recommendations.Items {
+			foodIds = append(foodIds, item.Food_id)
+		}
+		foods, err := foodsById(ctx, foodIds)
+		if err != nil {
+			apierror.Render(c, apierror.Internal("error occurred while loading foods: "+err.Error()))
+			return
+		}
+
+		// 86ed and deleted foods are skipped so the UI never offers them
+		result := []gin.H{}
+		for _, item := range recommendations.Items {
+			food, ok := foods[item.Food_id]
+			if !ok || (food.Available != nil && !*food.Available) {
+				continue
+			}
+			result = append(result, gin.H{
+				"food_id":    food.Food_id,
+				"name":       food.Name,
+				"price":      food.Price,
+				"food_image": food.Food_image,
+				"confidence": item.Confidence,
+			})
+			if len(result) == limit {
+				break
+			}
+		}
+
+		c.JSON(http.StatusOK, gin.H{"food_id": recommendations.Food_id, "computed_at": recommendations.Computed_at, "recommendations": result})
+	}
+}
+
+// ComputeRecommendations rebuilds the recommendations collection from the
+// orders of the last RECOMMENDATION_WINDOW_DAYS (default 90). Two foods are
+// related once they were ordered together RECOMMENDATION_MIN_ORDERS times
+// (default 2); the strongest pairs by co-orders and then lift are kept.
+func ComputeRecommendations(ctx context.Context) error {
+	windowDays := 90
+	if days, err := strconv.Atoi(os.Getenv("RECOMMENDATION_WINDOW_DAYS")); err == nil && days > 0 {
+		windowDays = days
+	}
+	minOrders := 2
+	if count, err := strconv.Atoi(os.Getenv("RECOMMENDATION_MIN_ORDERS")); err == nil && count > 0 {
+		minOrders = count
+	}
+
+	startedAt, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+	cursor, err := orderItemCollection().Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$match", Value: bson.D{{Key: "created_at", Value: bson.D{{Key: "$gte", Value: startedAt.AddDate(0, 0, -windowDays)}}}}}},
+		{{Key: "$group", Value: bson.D{
+			{Key: "_id", Value: "$order_id"},
+			{Key: "foods", Value: bson.D{{Key: "$addToSet", Value: "$food_id"}}},
+		}}},
+	})
+	if err != nil {
+		return err
+	}
+	var orders []struct {
+		Foods []string `bson:"foods"`
+	}
+	if err = cursor.All(ctx, &orders); err != nil {
+		return err
+	}
+
+	ordersWith := map[string]int{}
+	together := map[string]map[string]int{}
+	for _, order := range orders {
+		for _, a := range order.Foods {
+			ordersWith[a]++
+			for _, b := range order.Foods {
+				if a == b {
+					continue
+				}
+				if together[a] == nil {
+					together[a] = map[string]int{}
+				}
+				together[a][b]++
+			}
+		}
+	}
+
+	for foodId, pairs := range together {
+		items := []models.Recommendation{}
+		for other, count := range pairs {
+			if count < minOrders {
+				continue
+			}
+			confidence := float64(count) / float64(ordersWith[foodId])
+			items = append(items, models.Recommendation{
+				Food_id:    other,
+				Co_orders:  count,
+				Confidence: toFixed(confidence, 4),
+				Lift:       toFixed(confidence/(float64(ordersWith[other])/float64(len(orders))), 4),
+			})
+		}
+		if len(items) == 0 {
+			continue
+		}
+		sort.Slice(items, func(i, j int) bool {
+			if items[i].Co_orders != items[j].Co_orders {
+				return items[i].Co_orders > items[j].Co_orders
+			}
+			if items[i].Lift != items[j].Lift {
+				return items[i].Lift > items[j].Lift
+			}
+			return items[i].Food_id < items[j].Food_id
+		})
+		if len(items) > maxRecommendations {
+			items = items[:maxRecommendations]
+		}
+
+		_, err := recommendationCollection().UpdateOne(
+			ctx,
+			bson.M{"food_id": foodId},
+			bson.D{
+				{Key: "$set", Value: bson.D{
+					{Key: "orders", Value: ordersWith[foodId]},
+					{Key: "items", Value: items},
+					{Key: "computed_at", Value: startedAt},
+				}},
+				{Key: "$setOnInsert", Value: bson.D{{Key: "_id", Value: primitive.NewObjectID()}}},
+			},
+			options.Update().SetUpsert(true),
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Foods no longer ordered with anything lose their stale recommendations
+	_, err = recommendationCollection().DeleteMany(ctx, bson.M{"computed_at": bson.M{"$lt": startedAt}})
+	return err
+}
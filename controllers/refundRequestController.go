@@ -0,0 +1,123 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"restaurant-management/apierror"
+	"restaurant-management/config"
+	"restaurant-management/models"
+	"restaurant-management/notifications/push"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var refundRequestCollection = db.Collection("refundRequest")
+
+// GetRefundRequests lists refund requests, newest first, optionally only
+// those with ?status=.
+func GetRefundRequests() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		filter := bson.M{}
+		if status := c.Query("status"); status != "" {
+			filter["status"] = status
+		}
+		cursor, err := refundRequestCollection().Find(ctx, filter, options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}}))
+		if err != nil {
+			apierror.Render(c, apierror.Internal("error occurred while listing refund requests"))
+			return
+		}
+		requests := []models.RefundRequest{}
+		if err := cursor.All(ctx, &requests); err != nil {
+			apierror.Render(c, apierror.Internal(err.Error()))
+			return
+		}
+		c.JSON(http.StatusOK, requests)
+	}
+}
+
+// CreateRefundRequest asks the managers to refund an invoice, and pushes
+// the request to their devices. The refund itself is issued as a credit
+// note.
+func CreateRefundRequest() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		var request models.RefundRequest
+		if err := c.BindJSON(&request); err != nil {
+			apierror.Render(c, apierror.BadRequest("Invalid request data: "+err.Error()))
+			return
+		}
+		if err := validate.Struct(request); err != nil {
+			apierror.Render(c, apierror.FromValidation(err))
+			return
+		}
+
+		var invoice models.Invoice
+		if err := invoiceCollection().FindOne(ctx, bson.M{"invoice_id": c.Param("invoice_id")}).Decode(&invoice); err != nil {
+			apierror.Render(c, apierror.NotFound("invoice not found"))
+			return
+		}
+		if stringValue(invoice.Payment_status) != "PAID" {
+			apierror.Render(c, apierror.Conflict("Only paid invoices can be refunded"))
+			return
+		}
+
+		now, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		request.ID = primitive.NewObjectID()
+		request.Refund_request_id = request.ID.Hex()
+		request.Invoice_id = invoice.Invoice_id
+		if request.Order_item_ids == nil {
+			request.Order_item_ids = []string{}
+		}
+		request.Status = "PENDING"
+		request.Requested_by = c.GetString("uid")
+		request.Credit_note_id = nil
+		request.Created_at = now
+		request.Updated_at = now
+
+		if _, err := refundRequestCollection().InsertOne(ctx, request); err != nil {
+			apierror.Render(c, apierror.Internal("Refund request was not created: "+err.Error()))
+			return
+		}
+
+		number := invoice.Invoice_id
+		if invoice.Invoice_number != nil {
+			number = *invoice.Invoice_number
+		}
+		err := pushToRole(ctx, "MANAGER", push.Notification{
+			Title: "Refund requested",
+			Body:  fmt.Sprintf("Invoice %s: %s", number, *request.Reason),
+			Data:  map[string]string{"type": "refund_request", "refund_request_id": request.Refund_request_id, "invoice_id": invoice.Invoice_id},
+		})
+		if err != nil {
+			log.Println("Error pushing refund request:", err)
+		}
+
+		c.JSON(http.StatusCreated, gin.H{"message": "Refund request created", "data": request})
+	}
+}
+
+// creditRefundRequests marks the invoice's pending refund requests as
+// settled by creditNote.
+func creditRefundRequests(ctx context.Context, creditNote models.CreditNote) error {
+	_, err := refundRequestCollection().UpdateMany(
+		ctx,
+		bson.M{"invoice_id": creditNote.Invoice_id, "status": "PENDING"},
+		bson.D{{Key: "$set", Value: bson.D{
+			{Key: "status", Value: "CREDITED"},
+			{Key: "credit_note_id", Value: creditNote.Credit_note_id},
+			{Key: "updated_at", Value: creditNote.Issued_at},
+		}}},
+	)
+	return err
+}
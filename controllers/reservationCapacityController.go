@@ -0,0 +1,261 @@
+package controllers
+
+import (
+	"context"
+	"net/http"
+	"restaurant-management/apierror"
+	"restaurant-management/bookings"
+	"restaurant-management/config"
+	"restaurant-management/models"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var reservationCapacityCollection = db.Collection("reservationCapacity")
+
+// Defaults for capacities that leave out the slot length or opening hours.
+const (
+	defaultSlotMinutes = 30
+	defaultOpenTime    = "11:00"
+	defaultCloseTime   = "23:00"
+)
+
+// occupyingReservationStatuses are the statuses of bookings that hold seats.
+var occupyingReservationStatuses = bson.A{"BOOKED", "CONFIRMED", "SEATED"}
+
+func GetReservationCapacity() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		var capacity models.ReservationCapacity
+		err := reservationCapacityCollection().FindOne(ctx, bson.M{"location": c.Param("location")}).Decode(&capacity)
+		if err != nil {
+			apierror.Render(c, apierror.NotFound("Reservation capacity not found"))
+			return
+		}
+
+		c.JSON(http.StatusOK, capacity)
+	}
+}
+
+// UpdateReservationCapacity sets how many covers a location takes per slot,
+// replacing any earlier settings. Without one, bookings are not limited.
+func UpdateReservationCapacity() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		var capacity models.ReservationCapacity
+		if err := c.BindJSON(&capacity); err != nil {
+			apierror.Render(c, apierror.BadRequest("Invalid request data: "+err.Error()))
+			return
+		}
+		if err := validate.Struct(capacity); err != nil {
+			apierror.Render(c, apierror.FromValidation(err))
+			return
+		}
+		slotMinutes := defaultSlotMinutes
+		if capacity.Slot_minutes != nil {
+			slotMinutes = *capacity.Slot_minutes
+		}
+		opens := stringOr(capacity.Open_time, defaultOpenTime)
+		closes := stringOr(capacity.Close_time, defaultCloseTime)
+		start, startErr := time.Parse("15:04", opens)
+		end, endErr := time.Parse("15:04", closes)
+		if startErr != nil || endErr != nil || !end.After(start) {
+			apierror.Render(c, apierror.BadRequest("open_time and close_time must be HH:MM with close after open"))
+			return
+		}
+
+		now, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		updateObj := primitive.D{
+			{Key: "max_covers", Value: capacity.Max_covers},
+			{Key: "slot_minutes", Value: slotMinutes},
+			{Key: "open_time", Value: opens},
+			{Key: "close_time", Value: closes},
+			{Key: "updated_at", Value: now},
+		}
+
+		upsert := true
+		opt := options.UpdateOptions{Upsert: &upsert}
+
+		result, err := reservationCapacityCollection().UpdateOne(
+			ctx,
+			bson.M{"location": c.Param("location")},
+			bson.D{
+				{Key: "$set", Value: updateObj},
+				{Key: "$setOnInsert", Value: bson.D{{Key: "_id", Value: primitive.NewObjectID()}, {Key: "created_at", Value: now}}},
+			},
+			&opt,
+		)
+		if err != nil {
+			apierror.Render(c, apierror.Internal("Update failed: "+err.Error()))
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Reservation capacity updated successfully", "result": result})
+	}
+}
+
+// GetReservationAvailability lists the free covers per slot for ?location=
+// on ?date= (YYYY-MM-DD, default today).
+func GetReservationAvailability() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		location := c.DefaultQuery("location", defaultLocation)
+		day := time.Now()
+		if date := c.Query("date"); date != "" {
+			parsed, err := time.ParseInLocation("2006-01-02", date, time.Local)
+			if err != nil {
+				apierror.Render(c, apierror.BadRequest("date must be formatted as YYYY-MM-DD"))
+				return
+			}
+			day = parsed
+		}
+
+		capacity, err := reservationCapacityFor(ctx, location)
+		if err != nil {
+			apierror.Render(c, apierror.Internal("error occurred while loading capacity: "+err.Error()))
+			return
+		}
+		if capacity == nil {
+			apierror.Render(c, apierror.NotFound("No reservation capacity is set for this location"))
+			return
+		}
+
+		slots, err := reservationSlots(ctx, capacity, location, day, 1)
+		if err != nil {
+			apierror.Render(c, apierror.Internal("error occurred while computing availability: "+err.Error()))
+			return
+		}
+
+		c.JSON(http.StatusOK, slots)
+	}
+}
+
+// reservationCapacityFor returns the capacity of a location, or nil when
+// bookings there are not limited.
+func reservationCapacityFor(ctx context.Context, location string) (*models.ReservationCapacity, error) {
+	var capacity models.ReservationCapacity
+	err := reservationCapacityCollection().FindOne(ctx, bson.M{"location": location}).Decode(&capacity)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &capacity, nil
+}
+
+// reservationSlots returns every slot during opening hours over days days
+// from day, with the covers still free in each.
+func reservationSlots(ctx context.Context, capacity *models.ReservationCapacity, location string, day time.Time, days int) ([]bookings.Slot, error) {
+	from := time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, time.Local)
+	to := from.AddDate(0, 0, days)
+	reservations, err := occupyingReservations(ctx, location, from, to, "")
+	if err != nil {
+		return nil, err
+	}
+
+	slot := slotLength(capacity)
+	opens, _ := time.Parse("15:04", stringOr(capacity.Open_time, defaultOpenTime))
+	closes, _ := time.Parse("15:04", stringOr(capacity.Close_time, defaultCloseTime))
+	slots := []bookings.Slot{}
+	for date := from; date.Before(to); date = date.AddDate(0, 0, 1) {
+		start := time.Date(date.Year(), date.Month(), date.Day(), opens.Hour(), opens.Minute(), 0, 0, time.Local)
+		end := time.Date(date.Year(), date.Month(), date.Day(), closes.Hour(), closes.Minute(), 0, 0, time.Local)
+		for t := start; t.Before(end); t = t.Add(slot) {
+			free := *capacity.Max_covers - coversDuring(reservations, t, t.Add(slot))
+			if free < 0 {
+				free = 0
+			}
+			slots = append(slots, bookings.Slot{Start: t, End: t.Add(slot), Available_covers: free})
+		}
+	}
+	return slots, nil
+}
+
+// availableCovers is how many more guests fit in every slot of [start, end),
+// ignoring the reservation excludeId so a booking can be moved.
+func availableCovers(ctx context.Context, capacity *models.ReservationCapacity, location string, start, end time.Time, excludeId string) (int, error) {
+	reservations, err := occupyingReservations(ctx, location, start, end, excludeId)
+	if err != nil {
+		return 0, err
+	}
+	slot := slotLength(capacity)
+	free := *capacity.Max_covers
+	for t := start; t.Before(end); t = t.Add(slot) {
+		if available := *capacity.Max_covers - coversDuring(reservations, t, t.Add(slot)); available < free {
+			free = available
+		}
+	}
+	if free < 0 {
+		return 0, nil
+	}
+	return free, nil
+}
+
+// occupyingReservations loads the bookings holding seats at any time in
+// [from, to).
+func occupyingReservations(ctx context.Context, location string, from, to time.Time, excludeId string) ([]models.Reservation, error) {
+	filter := bson.M{
+		"location": location,
+		"status":   bson.M{"$in": occupyingReservationStatuses},
+		// Bookings last at most 12 hours, so earlier ones cannot overlap
+		"reserved_at": bson.M{"$gte": from.Add(-12 * time.Hour), "$lt": to},
+	}
+	if excludeId != "" {
+		filter["reservation_id"] = bson.M{"$ne": excludeId}
+	}
+	cursor, err := reservationCollection().Find(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	var reservations []models.Reservation
+	if err = cursor.All(ctx, &reservations); err != nil {
+		return nil, err
+	}
+	return reservations, nil
+}
+
+// coversDuring sums the party sizes of the reservations overlapping
+// [start, end).
+func coversDuring(reservations []models.Reservation, start, end time.Time) int {
+	covers := 0
+	for _, reservation := range reservations {
+		if reservation.Reserved_at.Before(end) && reservationEnd(reservation).After(start) {
+			covers += *reservation.Party_size
+		}
+	}
+	return covers
+}
+
+func reservationEnd(reservation models.Reservation) time.Time {
+	duration := defaultReservationMinutes
+	if reservation.Duration_minutes != nil {
+		duration = *reservation.Duration_minutes
+	}
+	return reservation.Reserved_at.Add(time.Duration(duration) * time.Minute)
+}
+
+func slotLength(capacity *models.ReservationCapacity) time.Duration {
+	if capacity.Slot_minutes != nil {
+		return time.Duration(*capacity.Slot_minutes) * time.Minute
+	}
+	return defaultSlotMinutes * time.Minute
+}
+
+func stringOr(value *string, fallback string) string {
+	if value == nil || *value == "" {
+		return fallback
+	}
+	return *value
+}
@@ -0,0 +1,744 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"restaurant-management/apierror"
+	"restaurant-management/config"
+	"restaurant-management/models"
+	"restaurant-management/payments"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var reservationCollection = db.Collection("reservation")
+
+const defaultReservationMinutes = 120
+
+// upcomingReservationStatuses are the statuses of bookings the guest has not
+// arrived for yet, confirmed or not.
+var upcomingReservationStatuses = bson.A{"BOOKED", "CONFIRMED"}
+
+func GetReservations() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		filter := bson.M{"location": c.DefaultQuery("location", defaultLocation)}
+		if status := c.Query("status"); status != "" {
+			filter["status"] = status
+		}
+		if tableId := c.Query("table_id"); tableId != "" {
+			filter["table_id"] = tableId
+		}
+		if date := c.Query("date"); date != "" {
+			day, err := time.ParseInLocation("2006-01-02", date, time.Local)
+			if err != nil {
+				apierror.Render(c, apierror.BadRequest("date must be formatted as YYYY-MM-DD"))
+				return
+			}
+			filter["reserved_at"] = bson.M{"$gte": day, "$lt": day.AddDate(0, 0, 1)}
+		}
+
+		opts := options.Find().SetSort(bson.D{{Key: "reserved_at", Value: 1}})
+		result, err := reservationCollection().Find(ctx, filter, opts)
+		if err != nil {
+			apierror.Render(c, apierror.Internal("error occurred while listing reservations: "+err.Error()))
+			return
+		}
+
+		var allReservations []bson.M
+		if err = result.All(ctx, &allReservations); err != nil {
+			apierror.Render(c, apierror.Internal("error decoding reservations: "+err.Error()))
+			return
+		}
+
+		c.JSON(http.StatusOK, allReservations)
+	}
+}
+
+func GetReservation() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		var reservation models.Reservation
+		err := reservationCollection().FindOne(ctx, bson.M{"reservation_id": c.Param("reservation_id")}).Decode(&reservation)
+		if err != nil {
+			apierror.Render(c, apierror.NotFound("Reservation not found"))
+			return
+		}
+
+		c.JSON(http.StatusOK, reservation)
+	}
+}
+
+// CreateReservation books a table. When the location's deposit policy asks
+// for a deposit it is charged to payment_token before the booking is saved;
+// without a token the response is 402 with the amount due. A booking that
+// does not fit the location's capacity, or a table_id already booked for an
+// overlapping time, is refused with 409.
+func CreateReservation() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		var reservation models.Reservation
+		if err := c.BindJSON(&reservation); err != nil {
+			apierror.Render(c, apierror.BadRequest("Invalid request data: "+err.Error()))
+			return
+		}
+		if err := validate.Struct(reservation); err != nil {
+			apierror.Render(c, apierror.FromValidation(err))
+			return
+		}
+		if !reservation.Reserved_at.After(time.Now()) {
+			apierror.Render(c, apierror.BadRequest("reserved_at must be in the future"))
+			return
+		}
+
+		if reservation.Location == nil {
+			location := defaultLocation
+			reservation.Location = &location
+		}
+		if reservation.Duration_minutes == nil {
+			duration := defaultReservationMinutes
+			reservation.Duration_minutes = &duration
+		}
+		if !checkReservationTable(ctx, c, reservation) {
+			return
+		}
+
+		capacity, err := reservationCapacityFor(ctx, *reservation.Location)
+		if err != nil {
+			apierror.Render(c, apierror.Internal("error occurred while loading capacity: "+err.Error()))
+			return
+		}
+		if capacity != nil {
+			end := reservation.Reserved_at.Add(time.Duration(*reservation.Duration_minutes) * time.Minute)
+			available, err := availableCovers(ctx, capacity, *reservation.Location, *reservation.Reserved_at, end, "")
+			if err != nil {
+				apierror.Render(c, apierror.Internal("error occurred while checking availability: "+err.Error()))
+				return
+			}
+			if *reservation.Party_size > available {
+				apierror.Render(c, apierror.Conflict("No availability for this time").With("available", available))
+				return
+			}
+		}
+
+		policy, err := depositPolicyFor(ctx, *reservation.Location)
+		if err != nil {
+			apierror.Render(c, apierror.Internal("error occurred while loading deposit policy: "+err.Error()))
+			return
+		}
+
+		reservation.ID = primitive.NewObjectID()
+		reservation.Reservation_id = reservation.ID.Hex()
+
+		noShows, err := guestNoShows(ctx, guestKey(reservation.Customer_email, reservation.Customer_phone))
+		if err != nil {
+			apierror.Render(c, apierror.Internal("error occurred while loading guest history: "+err.Error()))
+			return
+		}
+
+		reservation.Deposit = nil
+		reservation.Guarantee = nil
+		deposit := requiredDeposit(policy, *reservation.Party_size, *reservation.Reserved_at, noShows)
+		guarantee := 0.0
+		if deposit == 0 {
+			guarantee = requiredGuarantee(policy, *reservation.Party_size)
+		}
+		if deposit > 0 || guarantee > 0 {
+			currency, err := locationCurrency(ctx, *reservation.Location)
+			if err != nil {
+				apierror.Render(c, apierror.Internal("error occurred while loading currency: "+err.Error()))
+				return
+			}
+			if reservation.Payment_token == nil || *reservation.Payment_token == "" {
+				apierror.Render(c, apierror.PaymentRequired("A card is required for this booking").With("amount", deposit).With("guarantee", guarantee).With("currency", currency))
+				return
+			}
+
+			gateway, err := payments.NewGateway()
+			if err != nil {
+				apierror.Render(c, apierror.Internal(err.Error()))
+				return
+			}
+			if deposit > 0 {
+				description := fmt.Sprintf("Deposit for reservation %s", reservation.Reservation_id)
+				chargeId, err := gateway.Charge(ctx, deposit, currency, *reservation.Payment_token, description)
+				if err == payments.ErrDeclined {
+					apierror.Render(c, apierror.PaymentRequired("The card was declined").With("amount", deposit).With("currency", currency))
+					return
+				}
+				if err != nil {
+					apierror.Render(c, apierror.UpstreamFailed("Deposit could not be charged: "+err.Error()))
+					return
+				}
+				reservation.Deposit = &models.ReservationDeposit{
+					Amount:    deposit,
+					Currency:  currency,
+					Gateway:   gateway.Name(),
+					Charge_id: chargeId,
+					Status:    "PAID",
+				}
+			} else {
+				description := fmt.Sprintf("No-show guarantee for reservation %s", reservation.Reservation_id)
+				authorizationId, err := gateway.Authorize(ctx, guarantee, currency, *reservation.Payment_token, description)
+				if err == payments.ErrDeclined {
+					apierror.Render(c, apierror.PaymentRequired("The card was declined").With("guarantee", guarantee).With("currency", currency))
+					return
+				}
+				if err != nil {
+					apierror.Render(c, apierror.UpstreamFailed("Card guarantee could not be placed: "+err.Error()))
+					return
+				}
+				reservation.Guarantee = &models.CardGuarantee{
+					Amount:           guarantee,
+					Currency:         currency,
+					Gateway:          gateway.Name(),
+					Authorization_id: authorizationId,
+					Status:           "HELD",
+				}
+			}
+		}
+
+		reservation.Status = "BOOKED"
+		reservation.Source = "DIRECT"
+		reservation.Connector_id = ""
+		reservation.External_id = ""
+		reservation.Token, err = newToken()
+		if err != nil {
+			apierror.Render(c, apierror.Internal("error generating reservation token: "+err.Error()))
+			return
+		}
+		reservation.Reminders_sent = []string{}
+		reservation.Confirmed_at = nil
+		reservation.Order_id = nil
+		reservation.No_show_fee = nil
+		reservation.Cancelled_by = nil
+		reservation.Cancelled_at = nil
+		reservation.Created_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		reservation.Updated_at = reservation.Created_at
+
+		err = bookTable(ctx, reservation, func(ctx context.Context) error {
+			_, err := reservationCollection().InsertOne(ctx, reservation)
+			return err
+		})
+		if err != nil {
+			// The card was charged but the booking is lost, so give the money back
+			if reservation.Deposit != nil {
+				if gateway, gatewayErr := payments.NewGateway(); gatewayErr == nil {
+					if refundErr := gateway.Refund(ctx, reservation.Deposit.Charge_id, reservation.Deposit.Amount); refundErr != nil {
+						log.Println("Error refunding deposit of unsaved reservation:", refundErr)
+					}
+				}
+			}
+			releaseGuarantee(ctx, reservation)
+			if err == errTableBooked {
+				apierror.Render(c, apierror.Conflict(err.Error()))
+				return
+			}
+			apierror.Render(c, apierror.Internal("Reservation was not created: "+err.Error()))
+			return
+		}
+
+		if err := emailReservationConfirmation(ctx, reservation); err != nil {
+			log.Println("Error emailing reservation confirmation:", err)
+		}
+
+		c.JSON(http.StatusCreated, gin.H{"message": "Reservation created", "data": reservation})
+	}
+}
+
+// UpdateReservation changes the time, length, party, table or guest details
+// of an upcoming booking. Changes are checked against the location's
+// capacity and the table's other bookings as a new booking would be; the
+// deposit is left as it was taken. Moving the booking resends reminders.
+func UpdateReservation() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		var body struct {
+			Reserved_at       *time.Time `json:"reserved_at"`
+			Duration_minutes  *int       `json:"duration_minutes" validate:"omitempty,gt=0,lte=720"`
+			Party_size        *int       `json:"party_size" validate:"omitempty,gt=0,lte=100"`
+			Table_id          *string    `json:"table_id"`
+			Customer_name     *string    `json:"customer_name" validate:"omitempty,min=2,max=100"`
+			Customer_phone    *string    `json:"customer_phone" validate:"omitempty,min=7,max=20"`
+			Customer_email    *string    `json:"customer_email" validate:"omitempty,email"`
+			Notes             *string    `json:"notes" validate:"omitempty,max=1000"`
+			Reminders_opt_out *bool      `json:"reminders_opt_out"`
+		}
+		if err := c.BindJSON(&body); err != nil {
+			apierror.Render(c, apierror.BadRequest("Invalid request data: "+err.Error()))
+			return
+		}
+		if err := validate.Struct(body); err != nil {
+			apierror.Render(c, apierror.FromValidation(err))
+			return
+		}
+
+		var reservation models.Reservation
+		if err := reservationCollection().FindOne(ctx, bson.M{"reservation_id": c.Param("reservation_id")}).Decode(&reservation); err != nil {
+			apierror.Render(c, apierror.NotFound("Reservation not found"))
+			return
+		}
+		if reservation.Status != "BOOKED" && reservation.Status != "CONFIRMED" {
+			apierror.Render(c, apierror.Conflict("Only upcoming reservations can be changed"))
+			return
+		}
+
+		var updateObj primitive.D
+		moved := false
+		if body.Reserved_at != nil && !body.Reserved_at.Equal(*reservation.Reserved_at) {
+			if !body.Reserved_at.After(time.Now()) {
+				apierror.Render(c, apierror.BadRequest("reserved_at must be in the future"))
+				return
+			}
+			reservation.Reserved_at = body.Reserved_at
+			updateObj = append(updateObj, bson.E{Key: "reserved_at", Value: body.Reserved_at}, bson.E{Key: "reminders_sent", Value: []string{}})
+			moved = true
+		}
+		if body.Duration_minutes != nil {
+			reservation.Duration_minutes = body.Duration_minutes
+			updateObj = append(updateObj, bson.E{Key: "duration_minutes", Value: body.Duration_minutes})
+			moved = true
+		}
+		if body.Party_size != nil {
+			reservation.Party_size = body.Party_size
+			updateObj = append(updateObj, bson.E{Key: "party_size", Value: body.Party_size})
+			moved = true
+		}
+		if body.Table_id != nil {
+			reservation.Table_id = body.Table_id
+			updateObj = append(updateObj, bson.E{Key: "table_id", Value: body.Table_id})
+			moved = true
+		}
+		if body.Customer_name != nil {
+			updateObj = append(updateObj, bson.E{Key: "customer_name", Value: body.Customer_name})
+		}
+		if body.Customer_phone != nil {
+			updateObj = append(updateObj, bson.E{Key: "customer_phone", Value: body.Customer_phone})
+		}
+		if body.Customer_email != nil {
+			updateObj = append(updateObj, bson.E{Key: "customer_email", Value: body.Customer_email})
+		}
+		if body.Notes != nil {
+			updateObj = append(updateObj, bson.E{Key: "notes", Value: body.Notes})
+		}
+		if body.Reminders_opt_out != nil {
+			updateObj = append(updateObj, bson.E{Key: "reminders_opt_out", Value: body.Reminders_opt_out})
+		}
+
+		if moved {
+			if !checkReservationTable(ctx, c, reservation) {
+				return
+			}
+			capacity, err := reservationCapacityFor(ctx, stringOr(reservation.Location, defaultLocation))
+			if err != nil {
+				apierror.Render(c, apierror.Internal("error occurred while loading capacity: "+err.Error()))
+				return
+			}
+			if capacity != nil {
+				available, err := availableCovers(ctx, capacity, stringOr(reservation.Location, defaultLocation), *reservation.Reserved_at, reservationEnd(reservation), reservation.Reservation_id)
+				if err != nil {
+					apierror.Render(c, apierror.Internal("error occurred while checking availability: "+err.Error()))
+					return
+				}
+				if *reservation.Party_size > available {
+					apierror.Render(c, apierror.Conflict("No availability for this time").With("available", available))
+					return
+				}
+			}
+		}
+
+		now, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		updateObj = append(updateObj, bson.E{Key: "updated_at", Value: now})
+
+		var updated models.Reservation
+		err := bookTable(ctx, reservation, func(ctx context.Context) error {
+			return reservationCollection().FindOneAndUpdate(
+				ctx,
+				bson.M{"reservation_id": reservation.Reservation_id, "status": bson.M{"$in": upcomingReservationStatuses}},
+				bson.D{{Key: "$set", Value: updateObj}},
+				options.FindOneAndUpdate().SetReturnDocument(options.After),
+			).Decode(&updated)
+		})
+		switch {
+		case err == errTableBooked:
+			apierror.Render(c, apierror.Conflict(err.Error()))
+			return
+		case err == mongo.ErrNoDocuments:
+			apierror.Render(c, apierror.Conflict("Only upcoming reservations can be changed"))
+			return
+		case err != nil:
+			apierror.Render(c, apierror.Internal("Update failed: "+err.Error()))
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Reservation updated", "data": updated})
+	}
+}
+
+// CancelReservation cancels a booking and refunds its deposit according to
+// the cancellation policy. cancelled_by RESTAURANT always refunds in full.
+func CancelReservation() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		var body struct {
+			Cancelled_by *string `json:"cancelled_by" validate:"required,eq=GUEST|eq=RESTAURANT"`
+		}
+		if err := c.BindJSON(&body); err != nil {
+			apierror.Render(c, apierror.BadRequest("Invalid request data: "+err.Error()))
+			return
+		}
+		if err := validate.Struct(body); err != nil {
+			apierror.Render(c, apierror.FromValidation(err))
+			return
+		}
+
+		reservation, refunded, err := cancelReservation(ctx, c.Param("reservation_id"), *body.Cancelled_by)
+		if err == mongo.ErrNoDocuments {
+			apierror.Render(c, apierror.Conflict("Only upcoming reservations can be cancelled"))
+			return
+		}
+		if err != nil {
+			apierror.Render(c, apierror.Internal("Cancellation failed: "+err.Error()))
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Reservation cancelled", "refunded": refunded, "data": reservation})
+	}
+}
+
+// SeatReservation marks the party as arrived and links the order opened for
+// them, so the deposit is credited on its invoice.
+func SeatReservation() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		var body struct {
+			Order_id *string `json:"order_id" validate:"required"`
+			Table_id *string `json:"table_id"`
+		}
+		if err := c.BindJSON(&body); err != nil {
+			apierror.Render(c, apierror.BadRequest("Invalid request data: "+err.Error()))
+			return
+		}
+		if err := validate.Struct(body); err != nil {
+			apierror.Render(c, apierror.FromValidation(err))
+			return
+		}
+
+		count, err := orderCollection().CountDocuments(ctx, bson.M{"order_id": body.Order_id})
+		if err != nil || count == 0 {
+			apierror.Render(c, apierror.BadRequest("Order not found"))
+			return
+		}
+
+		seated, err := seatReservation(ctx, c.Param("reservation_id"), *body.Order_id, body.Table_id)
+		if err == mongo.ErrNoDocuments {
+			apierror.Render(c, apierror.Conflict("Only upcoming reservations can be seated"))
+			return
+		}
+		if err != nil {
+			apierror.Render(c, apierror.Internal("Update failed: "+err.Error()))
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Reservation seated", "data": seated})
+	}
+}
+
+// seatReservation marks an upcoming reservation SEATED on an order and
+// releases its card guarantee. It returns mongo.ErrNoDocuments if the
+// reservation is not upcoming.
+func seatReservation(ctx context.Context, reservationId, orderId string, tableId *string) (models.Reservation, error) {
+	now, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+	updateObj := primitive.D{
+		{Key: "status", Value: "SEATED"},
+		{Key: "order_id", Value: orderId},
+		{Key: "updated_at", Value: now},
+	}
+	if tableId != nil {
+		updateObj = append(updateObj, bson.E{Key: "table_id", Value: tableId})
+	}
+
+	var seated models.Reservation
+	err := reservationCollection().FindOneAndUpdate(
+		ctx,
+		bson.M{"reservation_id": reservationId, "status": bson.M{"$in": upcomingReservationStatuses}},
+		bson.D{{Key: "$set", Value: updateObj}},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&seated)
+	if err != nil {
+		return seated, err
+	}
+	releaseGuarantee(ctx, seated)
+	return seated, nil
+}
+
+// cancelReservation moves an upcoming reservation to CANCELLED and refunds the
+// share of its deposit the policy allows. It returns mongo.ErrNoDocuments if
+// the reservation is not upcoming.
+func cancelReservation(ctx context.Context, reservationId, cancelledBy string) (models.Reservation, float64, error) {
+	now, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+
+	// Claim the cancellation first so a deposit is never refunded twice
+	var reservation models.Reservation
+	err := reservationCollection().FindOneAndUpdate(
+		ctx,
+		bson.M{"reservation_id": reservationId, "status": bson.M{"$in": upcomingReservationStatuses}},
+		bson.D{{Key: "$set", Value: bson.D{
+			{Key: "status", Value: "CANCELLED"},
+			{Key: "cancelled_by", Value: cancelledBy},
+			{Key: "cancelled_at", Value: now},
+			{Key: "updated_at", Value: now},
+		}}},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&reservation)
+	if err != nil {
+		return reservation, 0, err
+	}
+	releaseGuarantee(ctx, reservation)
+	if reservation.Connector_id != "" && cancelledBy != reservation.Source {
+		if err := cancelExternalBooking(ctx, reservation); err != nil {
+			log.Println("Error cancelling booking", reservation.External_id, "on", reservation.Source, ":", err)
+		}
+	}
+	if reservation.Deposit == nil || reservation.Deposit.Status != "PAID" {
+		return reservation, 0, nil
+	}
+
+	policy, err := depositPolicyFor(ctx, stringValue(reservation.Location))
+	if err != nil {
+		return reservation, 0, err
+	}
+	refund := depositRefund(policy, *reservation.Deposit, *reservation.Reserved_at, now, cancelledBy == "RESTAURANT")
+
+	status := "FORFEITED"
+	if refund > 0 {
+		gateway, err := payments.NewGateway()
+		if err != nil {
+			return reservation, 0, err
+		}
+		if err := gateway.Refund(ctx, reservation.Deposit.Charge_id, refund); err != nil {
+			return reservation, 0, fmt.Errorf("reservation cancelled but deposit refund failed: %w", err)
+		}
+		status = "REFUNDED"
+		if refund < reservation.Deposit.Amount {
+			status = "PARTIALLY_REFUNDED"
+		}
+	}
+
+	reservation.Deposit.Status = status
+	reservation.Deposit.Refunded_amount = refund
+	_, err = reservationCollection().UpdateOne(
+		ctx,
+		bson.M{"reservation_id": reservationId},
+		bson.D{{Key: "$set", Value: bson.D{{Key: "deposit.status", Value: status}, {Key: "deposit.refunded_amount", Value: refund}}}},
+	)
+	return reservation, refund, err
+}
+
+// applyReservationDeposit credits the paid deposit of the reservation seated
+// on an invoice's order to that invoice.
+func applyReservationDeposit(ctx context.Context, invoice models.Invoice) error {
+	var reservation models.Reservation
+	err := reservationCollection().FindOneAndUpdate(
+		ctx,
+		bson.M{"order_id": invoice.Order_id, "deposit.status": "PAID"},
+		bson.D{{Key: "$set", Value: bson.D{
+			{Key: "deposit.status", Value: "APPLIED"},
+			{Key: "deposit.invoice_id", Value: invoice.Invoice_id},
+		}}},
+	).Decode(&reservation)
+	if err == mongo.ErrNoDocuments {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	_, err = invoiceCollection().UpdateOne(
+		ctx,
+		bson.M{"invoice_id": invoice.Invoice_id},
+		bson.D{{Key: "$set", Value: bson.D{{Key: "deposit_credit", Value: reservation.Deposit.Amount}}}},
+	)
+	return err
+}
+
+// MarkNoShow records a no-show before the grace period has run out, e.g.
+// when the guest calls to say they are not coming.
+func MarkNoShow() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		var reservation models.Reservation
+		if err := reservationCollection().FindOne(ctx, bson.M{"reservation_id": c.Param("reservation_id")}).Decode(&reservation); err != nil {
+			apierror.Render(c, apierror.NotFound("Reservation not found"))
+			return
+		}
+
+		fee, err := markNoShow(ctx, reservation)
+		if err == mongo.ErrNoDocuments {
+			apierror.Render(c, apierror.Conflict("Only upcoming reservations can be marked as no-show"))
+			return
+		}
+		if err != nil {
+			apierror.Render(c, apierror.Internal("error occurred while recording no-show: "+err.Error()))
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Reservation marked as no-show", "no_show_fee": fee})
+	}
+}
+
+// DetectNoShows marks upcoming reservations whose grace period has passed as
+// no-shows. It runs on the scheduler.
+func DetectNoShows(ctx context.Context) error {
+	cursor, err := reservationCollection().Find(ctx, bson.M{"status": bson.M{"$in": upcomingReservationStatuses}, "reserved_at": bson.M{"$lt": time.Now()}})
+	if err != nil {
+		return err
+	}
+	var reservations []models.Reservation
+	if err = cursor.All(ctx, &reservations); err != nil {
+		return err
+	}
+
+	policies := map[string]*models.DepositPolicy{}
+	for _, reservation := range reservations {
+		location := stringValue(reservation.Location)
+		if _, ok := policies[location]; !ok {
+			policy, err := depositPolicyFor(ctx, location)
+			if err != nil {
+				return err
+			}
+			policies[location] = policy
+		}
+		if time.Since(*reservation.Reserved_at) < noShowGrace(policies[location]) {
+			continue
+		}
+		if _, err := markNoShow(ctx, reservation); err != nil && err != mongo.ErrNoDocuments {
+			log.Println("Error recording no-show of reservation", reservation.Reservation_id, ":", err)
+		}
+	}
+	return nil
+}
+
+// markNoShow moves an upcoming reservation to NO_SHOW, charges the no-show fee
+// and counts it against the guest. The fee is kept from the deposit, with
+// the rest refunded, or captured from the card guarantee. Without a
+// configured fee the whole deposit is kept. It returns mongo.ErrNoDocuments
+// if the reservation is no longer upcoming.
+func markNoShow(ctx context.Context, reservation models.Reservation) (float64, error) {
+	now, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+	result, err := reservationCollection().UpdateOne(
+		ctx,
+		bson.M{"reservation_id": reservation.Reservation_id, "status": bson.M{"$in": upcomingReservationStatuses}},
+		bson.D{{Key: "$set", Value: bson.D{{Key: "status", Value: "NO_SHOW"}, {Key: "updated_at", Value: now}}}},
+	)
+	if err != nil {
+		return 0, err
+	}
+	if result.MatchedCount == 0 {
+		return 0, mongo.ErrNoDocuments
+	}
+
+	if key := guestKey(reservation.Customer_email, reservation.Customer_phone); key != "" {
+		upsert := true
+		_, err := guestHistoryCollection().UpdateOne(
+			ctx,
+			bson.M{"guest_key": key},
+			bson.D{
+				{Key: "$inc", Value: bson.D{{Key: "no_shows", Value: 1}}},
+				{Key: "$set", Value: bson.D{{Key: "last_no_show_at", Value: now}}},
+				{Key: "$setOnInsert", Value: bson.D{{Key: "_id", Value: primitive.NewObjectID()}}},
+			},
+			&options.UpdateOptions{Upsert: &upsert},
+		)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	policy, err := depositPolicyFor(ctx, stringValue(reservation.Location))
+	if err != nil {
+		return 0, err
+	}
+	fee := noShowFee(policy, *reservation.Party_size)
+
+	gateway, err := payments.NewGateway()
+	if err != nil {
+		return 0, err
+	}
+	updateObj := primitive.D{}
+	switch {
+	case reservation.Deposit != nil && reservation.Deposit.Status == "PAID":
+		kept := reservation.Deposit.Amount
+		if fee > 0 && fee < kept {
+			kept = fee
+		}
+		status := "FORFEITED"
+		if refund := toFixed(reservation.Deposit.Amount-kept, 2); refund > 0 {
+			if err := gateway.Refund(ctx, reservation.Deposit.Charge_id, refund); err != nil {
+				return 0, err
+			}
+			status = "PARTIALLY_REFUNDED"
+			updateObj = append(updateObj, bson.E{Key: "deposit.refunded_amount", Value: refund})
+		}
+		updateObj = append(updateObj, bson.E{Key: "deposit.status", Value: status})
+		fee = kept
+	case reservation.Guarantee != nil && reservation.Guarantee.Status == "HELD" && fee > 0:
+		if fee > reservation.Guarantee.Amount {
+			fee = reservation.Guarantee.Amount
+		}
+		if _, err := gateway.Capture(ctx, reservation.Guarantee.Authorization_id, fee); err != nil {
+			return 0, err
+		}
+		updateObj = append(updateObj, bson.E{Key: "guarantee.status", Value: "CAPTURED"})
+	default:
+		// Nothing to charge the fee against
+		releaseGuarantee(ctx, reservation)
+		fee = 0
+	}
+
+	updateObj = append(updateObj, bson.E{Key: "no_show_fee", Value: fee})
+	_, err = reservationCollection().UpdateOne(ctx, bson.M{"reservation_id": reservation.Reservation_id}, bson.D{{Key: "$set", Value: updateObj}})
+	return fee, err
+}
+
+// releaseGuarantee voids a held card guarantee. Failures are only logged:
+// uncaptured holds expire on their own.
+func releaseGuarantee(ctx context.Context, reservation models.Reservation) {
+	if reservation.Guarantee == nil || reservation.Guarantee.Status != "HELD" {
+		return
+	}
+	gateway, err := payments.NewGateway()
+	if err == nil {
+		err = gateway.Void(ctx, reservation.Guarantee.Authorization_id)
+	}
+	if err != nil {
+		log.Println("Error releasing card guarantee of reservation", reservation.Reservation_id, ":", err)
+		return
+	}
+	reservationCollection().UpdateOne(
+		ctx,
+		bson.M{"reservation_id": reservation.Reservation_id},
+		bson.D{{Key: "$set", Value: bson.D{{Key: "guarantee.status", Value: "RELEASED"}}}},
+	)
+}
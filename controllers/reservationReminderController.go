@@ -0,0 +1,356 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"restaurant-management/apierror"
+	"restaurant-management/config"
+	"restaurant-management/i18n"
+	"restaurant-management/models"
+	"restaurant-management/notifications/email"
+	"restaurant-management/notifications/sms"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var reservationReminderCollection = db.Collection("reservationReminder")
+
+// maxReminderAttempts is how many times a reminder is tried before it is
+// given up on.
+const maxReminderAttempts = 5
+
+// errNoReminderContact is returned for reservations without a phone number
+// or email to remind.
+var errNoReminderContact = errors.New("reservation has no phone number or email")
+
+// GetReservationByToken shows a guest their booking from the links in a
+// reminder. It never changes the booking; the page it backs POSTs the
+// guest's answer.
+func GetReservationByToken() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		var reservation models.Reservation
+		if err := reservationCollection().FindOne(ctx, bson.M{"token": c.Param("token")}).Decode(&reservation); err != nil {
+			apierror.Render(c, apierror.NotFound("Reservation not found"))
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"customer_name": reservation.Customer_name,
+			"party_size":    reservation.Party_size,
+			"reserved_at":   reservation.Reserved_at,
+			"status":        reservation.Status,
+			"deposit":       reservation.Deposit,
+		})
+	}
+}
+
+// ConfirmReservationByToken confirms the booking from the confirm page of a
+// reminder. Confirming twice is harmless.
+func ConfirmReservationByToken() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		now, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		result, err := reservationCollection().UpdateOne(
+			ctx,
+			bson.M{"token": c.Param("token"), "status": "BOOKED"},
+			bson.D{{Key: "$set", Value: bson.D{{Key: "status", Value: "CONFIRMED"}, {Key: "confirmed_at", Value: now}, {Key: "updated_at", Value: now}}}},
+		)
+		if err != nil {
+			apierror.Render(c, apierror.Internal("Update failed: "+err.Error()))
+			return
+		}
+		if result.MatchedCount == 0 {
+			var reservation models.Reservation
+			if err := reservationCollection().FindOne(ctx, bson.M{"token": c.Param("token")}).Decode(&reservation); err != nil {
+				apierror.Render(c, apierror.NotFound("Reservation not found"))
+				return
+			}
+			if reservation.Status != "CONFIRMED" {
+				apierror.Render(c, apierror.Conflict("This reservation can no longer be confirmed").With("status", reservation.Status))
+				return
+			}
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Reservation confirmed"})
+	}
+}
+
+// CancelReservationByToken cancels the booking from the cancel page of a
+// reminder. The table is released and the deposit refunded as for any guest
+// cancellation.
+func CancelReservationByToken() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		var reservation models.Reservation
+		if err := reservationCollection().FindOne(ctx, bson.M{"token": c.Param("token")}).Decode(&reservation); err != nil {
+			apierror.Render(c, apierror.NotFound("Reservation not found"))
+			return
+		}
+		if reservation.Status == "CANCELLED" {
+			c.JSON(http.StatusOK, gin.H{"message": "Reservation cancelled"})
+			return
+		}
+
+		_, refunded, err := cancelReservation(ctx, reservation.Reservation_id, "GUEST")
+		if err == mongo.ErrNoDocuments {
+			apierror.Render(c, apierror.Conflict("This reservation can no longer be cancelled").With("status", reservation.Status))
+			return
+		}
+		if err != nil {
+			apierror.Render(c, apierror.Internal("Cancellation failed: "+err.Error()))
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Reservation cancelled", "refunded": refunded})
+	}
+}
+
+// StopRemindersByToken turns off further reminders for the booking from the
+// opt-out page of a reminder.
+func StopRemindersByToken() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		now, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		result, err := reservationCollection().UpdateOne(
+			ctx,
+			bson.M{"token": c.Param("token")},
+			bson.D{{Key: "$set", Value: bson.D{{Key: "reminders_opt_out", Value: true}, {Key: "updated_at", Value: now}}}},
+		)
+		if err != nil {
+			apierror.Render(c, apierror.Internal("Update failed: "+err.Error()))
+			return
+		}
+		if result.MatchedCount == 0 {
+			apierror.Render(c, apierror.NotFound("Reservation not found"))
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Reminders turned off"})
+	}
+}
+
+// GetReservationReminders lists the reminders sent or tried for a
+// reservation, newest first.
+func GetReservationReminders() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}})
+		cursor, err := reservationReminderCollection().Find(ctx, bson.M{"reservation_id": c.Param("reservation_id")}, opts)
+		if err != nil {
+			apierror.Render(c, apierror.Internal("error occurred while listing reminders: "+err.Error()))
+			return
+		}
+		reminders := []models.ReservationReminder{}
+		if err = cursor.All(ctx, &reminders); err != nil {
+			apierror.Render(c, apierror.Internal(err.Error()))
+			return
+		}
+
+		c.JSON(http.StatusOK, reminders)
+	}
+}
+
+// SendReservationReminders texts and emails guests ahead of their booking at
+// each of RESERVATION_REMINDER_HOURS (default "24,2"), unless they opted
+// out. A reminder is claimed on the reservation before it goes out, so
+// instances running the job side by side never send it twice, and every
+// attempt is logged. If several are due at once, as after downtime, only one
+// message goes out; one that fails is released to be tried again, up to
+// maxReminderAttempts times, unless retrying cannot help: the guest left no
+// contact or no channel is configured.
+func SendReservationReminders(ctx context.Context) error {
+	offsets := reminderOffsets()
+	if len(offsets) == 0 {
+		return nil
+	}
+
+	now := time.Now()
+	cursor, err := reservationCollection().Find(ctx, bson.M{
+		"status":            bson.M{"$in": upcomingReservationStatuses},
+		"reserved_at":       bson.M{"$gt": now, "$lte": now.Add(time.Duration(offsets[0]) * time.Hour)},
+		"reminders_opt_out": bson.M{"$ne": true},
+	})
+	if err != nil {
+		return err
+	}
+	var reservations []models.Reservation
+	if err = cursor.All(ctx, &reservations); err != nil {
+		return err
+	}
+
+	for _, reservation := range reservations {
+		sent := map[string]bool{}
+		for _, label := range reservation.Reminders_sent {
+			sent[label] = true
+		}
+		due := []string{}
+		for _, hours := range offsets {
+			label := fmt.Sprintf("%dh", hours)
+			remindAt := reservation.Reserved_at.Add(-time.Duration(hours) * time.Hour)
+			// Bookings made inside a reminder window don't need that reminder
+			if sent[label] || now.Before(remindAt) || reservation.Created_at.After(remindAt) {
+				continue
+			}
+			due = append(due, label)
+		}
+		if len(due) == 0 {
+			continue
+		}
+
+		claimed, err := reservationCollection().UpdateOne(
+			ctx,
+			bson.M{"reservation_id": reservation.Reservation_id, "reminders_sent": bson.M{"$nin": due}, "reminders_opt_out": bson.M{"$ne": true}},
+			bson.D{{Key: "$addToSet", Value: bson.D{{Key: "reminders_sent", Value: bson.D{{Key: "$each", Value: due}}}}}},
+		)
+		if err != nil {
+			return err
+		}
+		if claimed.ModifiedCount == 0 {
+			continue
+		}
+
+		channels, err := sendReservationReminder(ctx, reservation)
+		record := models.ReservationReminder{
+			ID:             primitive.NewObjectID(),
+			Reservation_id: reservation.Reservation_id,
+			Offsets:        due,
+			Channels:       channels,
+			Status:         "SENT",
+		}
+		record.Created_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		record.Reservation_reminder_id = record.ID.Hex()
+		if err != nil {
+			log.Println("Error sending reminder for reservation", reservation.Reservation_id, ":", err)
+			record.Status = "FAILED"
+			record.Error = err.Error()
+			if !reminderFailedForGood(err) && reminderRetriesLeft(ctx, reservation.Reservation_id, due) {
+				if _, err := reservationCollection().UpdateOne(
+					ctx,
+					bson.M{"reservation_id": reservation.Reservation_id},
+					bson.D{{Key: "$pullAll", Value: bson.D{{Key: "reminders_sent", Value: due}}}},
+				); err != nil {
+					log.Println("Error releasing reminder for reservation", reservation.Reservation_id, ":", err)
+				}
+			}
+		}
+		if _, err := reservationReminderCollection().InsertOne(ctx, record); err != nil {
+			log.Println("Error logging reminder for reservation", reservation.Reservation_id, ":", err)
+		}
+	}
+	return nil
+}
+
+// reminderOffsets parses RESERVATION_REMINDER_HOURS, largest first.
+func reminderOffsets() []int {
+	value := os.Getenv("RESERVATION_REMINDER_HOURS")
+	if value == "" {
+		value = "24,2"
+	}
+	offsets := []int{}
+	for _, part := range strings.Split(value, ",") {
+		if hours, err := strconv.Atoi(strings.TrimSpace(part)); err == nil && hours > 0 {
+			offsets = append(offsets, hours)
+		}
+	}
+	sort.Sort(sort.Reverse(sort.IntSlice(offsets)))
+	return offsets
+}
+
+// sendReservationReminder sends the reminder by SMS and email, whichever the
+// guest gave, and returns the channels it went out by. It fails only if
+// neither channel got through.
+func sendReservationReminder(ctx context.Context, reservation models.Reservation) ([]string, error) {
+	channels := []string{}
+	if reservation.Token == "" {
+		token, err := newToken()
+		if err != nil {
+			return channels, err
+		}
+		if _, err := reservationCollection().UpdateOne(ctx, bson.M{"reservation_id": reservation.Reservation_id}, bson.D{{Key: "$set", Value: bson.D{{Key: "token", Value: token}}}}); err != nil {
+			return channels, err
+		}
+		reservation.Token = token
+	}
+
+	template, err := effectiveReceiptTemplate(ctx, stringValue(reservation.Location))
+	if err != nil {
+		return channels, err
+	}
+	locale := i18n.Get(stringValue(template.Locale))
+	venue := venueName(template)
+
+	link := config.Get().PublicBaseURL + "/reservations/respond/" + reservation.Token
+	text := fmt.Sprintf("Reminder: %s, your table for %d at %s is booked for %s.\nConfirm: %s/confirm\nCancel: %s/cancel\nNo more reminders: %s/stopReminders",
+		*reservation.Customer_name, *reservation.Party_size, venue, locale.Datetime(reservation.Reserved_at.Local()), link, link, link)
+
+	var errs []error
+	if phone := stringValue(reservation.Customer_phone); phone != "" {
+		if err := sms.Send(ctx, phone, text); err != nil {
+			errs = append(errs, err)
+		} else {
+			channels = append(channels, "SMS")
+		}
+	}
+	if address := stringValue(reservation.Customer_email); address != "" {
+		if err := email.Send(ctx, email.Message{To: []string{address}, Subject: "Your reservation at " + venue, Body: text}); err != nil {
+			errs = append(errs, err)
+		} else {
+			channels = append(channels, "EMAIL")
+		}
+	}
+	if len(channels) > 0 {
+		return channels, nil
+	}
+	if len(errs) == 0 {
+		return channels, errNoReminderContact
+	}
+	return channels, errors.Join(errs...)
+}
+
+// reminderFailedForGood reports whether every reason a reminder failed is
+// one retrying cannot fix.
+func reminderFailedForGood(err error) bool {
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		for _, cause := range joined.Unwrap() {
+			if !reminderFailedForGood(cause) {
+				return false
+			}
+		}
+		return true
+	}
+	return errors.Is(err, errNoReminderContact) || errors.Is(err, sms.ErrNotConfigured) || errors.Is(err, email.ErrNotConfigured)
+}
+
+// reminderRetriesLeft reports whether the reminder for offsets has failed
+// fewer than maxReminderAttempts times, counting the attempt being logged.
+func reminderRetriesLeft(ctx context.Context, reservationId string, offsets []string) bool {
+	failed, err := reservationReminderCollection().CountDocuments(ctx, bson.M{"reservation_id": reservationId, "status": "FAILED", "offsets": bson.M{"$in": offsets}})
+	if err != nil {
+		log.Println("Error counting reminder attempts for reservation", reservationId, ":", err)
+		return false
+	}
+	return failed+1 < maxReminderAttempts
+}
@@ -0,0 +1,244 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"restaurant-management/apierror"
+	"restaurant-management/config"
+	"restaurant-management/models"
+	"restaurant-management/reviews"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var reviewCollection = db.Collection("review")
+
+func GetReviews() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		filter := bson.M{}
+		if source := c.Query("source"); source != "" {
+			filter["source"] = source
+		}
+		if foodId := c.Query("food_id"); foodId != "" {
+			filter["food_ids"] = foodId
+		}
+
+		limit, err := strconv.ParseInt(c.DefaultQuery("limit", "50"), 10, 64)
+		if err != nil || limit < 1 {
+			limit = 50
+		}
+		opts := options.Find().SetSort(bson.D{{Key: "review_time", Value: -1}}).SetLimit(limit)
+
+		result, err := reviewCollection().Find(ctx, filter, opts)
+		if err != nil {
+			apierror.Render(c, apierror.Internal("error occurred while listing reviews: "+err.Error()))
+			return
+		}
+
+		var allReviews []bson.M
+		if err = result.All(ctx, &allReviews); err != nil {
+			apierror.Render(c, apierror.Internal("error decoding reviews: "+err.Error()))
+			return
+		}
+
+		c.JSON(http.StatusOK, allReviews)
+	}
+}
+
+// TriggerReviewIngestion pulls reviews immediately instead of waiting for
+// the scheduled run.
+func TriggerReviewIngestion() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		ingested, err := ingestReviews(ctx)
+		if err != nil {
+			apierror.Render(c, apierror.UpstreamFailed("Review ingestion failed: "+err.Error()).With("ingested", ingested))
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Reviews ingested", "ingested": ingested})
+	}
+}
+
+// GetReviewDashboard summarises reviews across sources: totals per source,
+// a sentiment/rating trend per period and the most mentioned foods.
+func GetReviewDashboard() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		from, to, err := parseReportRange(c)
+		if err != nil {
+			apierror.Render(c, apierror.BadRequest(err.Error()))
+			return
+		}
+		if c.Query("from") == "" {
+			from = to.AddDate(0, 0, -90)
+		}
+
+		formats := map[string]string{"day": "%Y-%m-%d", "week": "%G-W%V", "month": "%Y-%m"}
+		granularity := c.DefaultQuery("granularity", "week")
+		format, ok := formats[granularity]
+		if !ok {
+			apierror.Render(c, apierror.BadRequest("granularity must be day, week or month"))
+			return
+		}
+
+		match := bson.D{{Key: "$match", Value: bson.D{{Key: "review_time", Value: bson.D{{Key: "$gte", Value: from}, {Key: "$lt", Value: to}}}}}}
+		stats := bson.D{
+			{Key: "reviews", Value: bson.D{{Key: "$sum", Value: 1}}},
+			{Key: "average_rating", Value: bson.D{{Key: "$avg", Value: "$rating"}}},
+			{Key: "average_sentiment", Value: bson.D{{Key: "$avg", Value: "$sentiment"}}},
+		}
+
+		bySource, err := aggregateReviews(ctx, mongo.Pipeline{
+			match,
+			{{Key: "$group", Value: append(bson.D{{Key: "_id", Value: "$source"}}, stats...)}},
+			{{Key: "$sort", Value: bson.D{{Key: "_id", Value: 1}}}},
+		})
+		if err != nil {
+			apierror.Render(c, apierror.Internal("error occurred while aggregating reviews: "+err.Error()))
+			return
+		}
+
+		trend, err := aggregateReviews(ctx, mongo.Pipeline{
+			match,
+			{{Key: "$group", Value: append(bson.D{{Key: "_id", Value: bson.D{{Key: "$dateToString", Value: bson.D{{Key: "format", Value: format}, {Key: "date", Value: "$review_time"}}}}}}, stats...)}},
+			{{Key: "$sort", Value: bson.D{{Key: "_id", Value: 1}}}},
+		})
+		if err != nil {
+			apierror.Render(c, apierror.Internal("error occurred while aggregating reviews: "+err.Error()))
+			return
+		}
+
+		topFoods, err := aggregateReviews(ctx, mongo.Pipeline{
+			match,
+			{{Key: "$unwind", Value: "$food_ids"}},
+			{{Key: "$group", Value: append(bson.D{{Key: "_id", Value: "$food_ids"}}, stats...)}},
+			{{Key: "$sort", Value: bson.D{{Key: "reviews", Value: -1}}}},
+			{{Key: "$limit", Value: 10}},
+			{{Key: "$lookup", Value: bson.D{
+				{Key: "from", Value: "food"},
+				{Key: "localField", Value: "_id"},
+				{Key: "foreignField", Value: "food_id"},
+				{Key: "as", Value: "food"},
+			}}},
+			{{Key: "$set", Value: bson.D{{Key: "name", Value: bson.D{{Key: "$first", Value: "$food.name"}}}}}},
+			{{Key: "$unset", Value: "food"}},
+		})
+		if err != nil {
+			apierror.Render(c, apierror.Internal("error occurred while aggregating reviews: "+err.Error()))
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"from":        from,
+			"to":          to,
+			"granularity": granularity,
+			"by_source":   bySource,
+			"trend":       trend,
+			"top_foods":   topFoods,
+		})
+	}
+}
+
+func aggregateReviews(ctx context.Context, pipeline mongo.Pipeline) ([]bson.M, error) {
+	cursor, err := reviewCollection().Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	rows := []bson.M{}
+	if err = cursor.All(ctx, &rows); err != nil {
+		return nil, err
+	}
+	return rows, nil
+}
+
+// IngestReviews is the scheduled job that pulls reviews from every
+// configured source.
+func IngestReviews(ctx context.Context) error {
+	_, err := ingestReviews(ctx)
+	return err
+}
+
+// ingestReviews upserts reviews from all configured sources, linking food
+// mentions and scoring sentiment. It keeps going when one source fails.
+func ingestReviews(ctx context.Context) (int, error) {
+	sources := reviews.ConfiguredSources()
+	if len(sources) == 0 {
+		return 0, nil
+	}
+
+	cursor, err := foodCollection().Find(ctx, bson.M{}, options.Find().SetProjection(bson.M{"food_id": 1, "name": 1}))
+	if err != nil {
+		return 0, err
+	}
+	var foods []models.Food
+	if err = cursor.All(ctx, &foods); err != nil {
+		return 0, err
+	}
+	foodNames := map[string]string{}
+	for _, food := range foods {
+		if food.Name != nil {
+			foodNames[food.Food_id] = *food.Name
+		}
+	}
+
+	ingested := 0
+	var failed error
+	for _, source := range sources {
+		fetched, err := source.Fetch(ctx)
+		if err != nil {
+			failed = fmt.Errorf("%s: %w", source.Name(), err)
+			continue
+		}
+
+		for _, review := range fetched {
+			now, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+			id := primitive.NewObjectID()
+			upsert := true
+			opt := options.UpdateOptions{Upsert: &upsert}
+
+			_, err := reviewCollection().UpdateOne(
+				ctx,
+				bson.M{"source": source.Name(), "external_id": review.External_id},
+				bson.D{
+					{Key: "$set", Value: bson.D{
+						{Key: "author", Value: review.Author},
+						{Key: "rating", Value: review.Rating},
+						{Key: "text", Value: review.Text},
+						{Key: "url", Value: review.Url},
+						{Key: "review_time", Value: review.Review_time},
+						{Key: "food_ids", Value: reviews.MentionedFoods(review.Text, foodNames)},
+						{Key: "sentiment", Value: toFixed(reviews.Sentiment(review.Text, review.Rating), 3)},
+						{Key: "updated_at", Value: now},
+					}},
+					{Key: "$setOnInsert", Value: bson.D{
+						{Key: "_id", Value: id},
+						{Key: "review_id", Value: id.Hex()},
+						{Key: "created_at", Value: now},
+					}},
+				},
+				&opt,
+			)
+			if err != nil {
+				return ingested, err
+			}
+			ingested++
+		}
+	}
+
+	return ingested, failed
+}
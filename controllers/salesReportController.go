@@ -0,0 +1,432 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"restaurant-management/apierror"
+	"restaurant-management/config"
+	"restaurant-management/models"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// salesGranularities are the buckets a sales report can be broken down by.
+var salesGranularities = map[string]bool{"day": true, "week": true, "month": true}
+
+// SalesBucket is the sales of one day, week or month. Start is the bucket's
+// first day in the report's time zone.
+type SalesBucket struct {
+	Start          string  `json:"start"`
+	Gross_revenue  float64 `json:"gross_revenue"`
+	Orders         int     `json:"orders"`
+	Average_ticket float64 `json:"average_ticket"`
+}
+
+// SalesReport totals the paid invoices of a period.
+type SalesReport struct {
+	Location       string        `json:"location"`
+	Granularity    string        `json:"granularity"`
+	Timezone       string        `json:"timezone"`
+	From           string        `json:"from"`
+	To             string        `json:"to"`
+	Gross_revenue  float64       `json:"gross_revenue"`
+	Orders         int           `json:"orders"`
+	Average_ticket float64       `json:"average_ticket"`
+	Buckets        []SalesBucket `json:"buckets"`
+}
+
+// GetSalesReport totals the paid invoices of a location between ?from= and
+// ?to= (YYYY-MM-DD, to inclusive, the last 7 days by default), broken down
+// by ?granularity= day (default), week or month. Days and buckets follow
+// the IANA time zone ?tz= (UTC by default); weeks start on Monday.
+func GetSalesReport() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		granularity := c.DefaultQuery("granularity", "day")
+		if !salesGranularities[granularity] {
+			apierror.Render(c, apierror.BadRequest("granularity must be day, week or month"))
+			return
+		}
+		timezone := c.DefaultQuery("tz", "UTC")
+		zone, err := time.LoadLocation(timezone)
+		if err != nil {
+			apierror.Render(c, apierror.BadRequest("tz must be an IANA time zone such as Europe/Berlin"))
+			return
+		}
+		from, to, err := parseReportRangeIn(c, zone)
+		if err != nil {
+			apierror.Render(c, apierror.BadRequest(err.Error()))
+			return
+		}
+		location := c.DefaultQuery("location", defaultLocation)
+
+		buckets, err := salesBuckets(ctx, location, granularity, zone, from, to)
+		if err != nil {
+			apierror.Render(c, apierror.Internal("error occurred while totalling sales: "+err.Error()))
+			return
+		}
+
+		report := SalesReport{
+			Location:    location,
+			Granularity: granularity,
+			Timezone:    timezone,
+			From:        from.Format("2006-01-02"),
+			To:          to.AddDate(0, 0, -1).Format("2006-01-02"),
+			Buckets:     buckets,
+		}
+		for _, bucket := range buckets {
+			report.Gross_revenue += bucket.Gross_revenue
+			report.Orders += bucket.Orders
+		}
+		report.Gross_revenue = toFixed(report.Gross_revenue, 2)
+		report.Average_ticket = averageTicket(report.Gross_revenue, report.Orders)
+
+		c.JSON(http.StatusOK, report)
+	}
+}
+
+// salesBuckets groups the paid invoices in [from, to) by the start of their
+// day, week or month in zone. Every bucket of the range is listed, with
+// zeros when nothing was sold.
+func salesBuckets(ctx context.Context, location, granularity string, zone *time.Location, from, to time.Time) ([]SalesBucket, error) {
+	cursor, err := invoiceCollection().Aggregate(ctx, append(paidInvoiceStages(location, from, to),
+		bson.D{{Key: "$group", Value: bson.D{
+			{Key: "_id", Value: bson.D{{Key: "$dateTrunc", Value: bson.D{
+				{Key: "date", Value: "$sold_at"},
+				{Key: "unit", Value: granularity},
+				{Key: "timezone", Value: zone.String()},
+				{Key: "startOfWeek", Value: "monday"},
+			}}}},
+			{Key: "gross", Value: bson.D{{Key: "$sum", Value: bson.D{{Key: "$ifNull", Value: bson.A{"$payment_due", 0}}}}}},
+			{Key: "orders", Value: bson.D{{Key: "$addToSet", Value: "$order_id"}}},
+		}}},
+		bson.D{{Key: "$project", Value: bson.D{
+			{Key: "gross", Value: 1},
+			{Key: "orders", Value: bson.D{{Key: "$size", Value: "$orders"}}},
+		}}},
+		bson.D{{Key: "$sort", Value: bson.D{{Key: "_id", Value: 1}}}},
+	))
+	if err != nil {
+		return nil, err
+	}
+	var totals []struct {
+		ID     time.Time `bson:"_id"`
+		Gross  float64   `bson:"gross"`
+		Orders int       `bson:"orders"`
+	}
+	if err = cursor.All(ctx, &totals); err != nil {
+		return nil, err
+	}
+
+	byStart := map[string]SalesBucket{}
+	for _, total := range totals {
+		gross := toFixed(total.Gross, 2)
+		start := total.ID.In(zone).Format("2006-01-02")
+		byStart[start] = SalesBucket{Start: start, Gross_revenue: gross, Orders: total.Orders, Average_ticket: averageTicket(gross, total.Orders)}
+	}
+
+	buckets := []SalesBucket{}
+	for start := salesBucketStart(from, granularity); start.Before(to); start = nextSalesBucket(start, granularity) {
+		key := start.Format("2006-01-02")
+		if bucket, ok := byStart[key]; ok {
+			buckets = append(buckets, bucket)
+		} else {
+			buckets = append(buckets, SalesBucket{Start: key})
+		}
+	}
+	return buckets, nil
+}
+
+// paidInvoiceStages match the paid invoices of a location sold in
+// [from, to), setting sold_at on each. An invoice is sold when it was
+// finalized, or created if it never was.
+func paidInvoiceStages(location string, from, to time.Time) mongo.Pipeline {
+	return mongo.Pipeline{
+		{{Key: "$match", Value: bson.D{
+			{Key: "location", Value: location},
+			{Key: "payment_status", Value: "PAID"},
+		}}},
+		{{Key: "$set", Value: bson.D{{Key: "sold_at", Value: bson.D{{Key: "$ifNull", Value: bson.A{"$finalized_at", "$created_at"}}}}}}},
+		{{Key: "$match", Value: bson.D{{Key: "sold_at", Value: bson.M{"$gte": from, "$lt": to}}}}},
+	}
+}
+
+// salesBucketStart is the first day of the bucket holding day, matching
+// $dateTrunc with weeks starting on Monday.
+func salesBucketStart(day time.Time, granularity string) time.Time {
+	switch granularity {
+	case "week":
+		return day.AddDate(0, 0, -(int(day.Weekday())+6)%7)
+	case "month":
+		return time.Date(day.Year(), day.Month(), 1, 0, 0, 0, 0, day.Location())
+	}
+	return day
+}
+
+func nextSalesBucket(start time.Time, granularity string) time.Time {
+	switch granularity {
+	case "week":
+		return start.AddDate(0, 0, 7)
+	case "month":
+		return start.AddDate(0, 1, 0)
+	}
+	return start.AddDate(0, 0, 1)
+}
+
+func averageTicket(gross float64, orders int) float64 {
+	if orders == 0 {
+		return 0
+	}
+	return toFixed(gross/float64(orders), 2)
+}
+
+// maxTopFoods caps ?limit= on the top foods report.
+const maxTopFoods = 100
+
+// TopFood is the sales of one food. Revenue is what its items were sold
+// for, after item discounts.
+type TopFood struct {
+	Food_id     string  `json:"food_id"`
+	Name        string  `json:"name"`
+	Category_id string  `json:"category_id"`
+	Quantity    int     `json:"quantity"`
+	Revenue     float64 `json:"revenue"`
+}
+
+// GetTopFoodsReport ranks the foods on the paid invoices of a location
+// between ?from= and ?to= (YYYY-MM-DD, to inclusive, the last 7 days by
+// default) by quantity sold, or by revenue with ?sort=revenue, returning
+// the first ?limit= (default 10).
+func GetTopFoodsReport() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		from, to, err := parseReportRange(c)
+		if err != nil {
+			apierror.Render(c, apierror.BadRequest(err.Error()))
+			return
+		}
+		limit, err := strconv.Atoi(c.DefaultQuery("limit", "10"))
+		if err != nil || limit < 1 || limit > maxTopFoods {
+			apierror.Render(c, apierror.BadRequest("limit must be between 1 and "+strconv.Itoa(maxTopFoods)))
+			return
+		}
+		sortBy := c.DefaultQuery("sort", "quantity")
+		if sortBy != "quantity" && sortBy != "revenue" {
+			apierror.Render(c, apierror.BadRequest("sort must be quantity or revenue"))
+			return
+		}
+		secondary := map[string]string{"quantity": "revenue", "revenue": "quantity"}[sortBy]
+		location := c.DefaultQuery("location", defaultLocation)
+
+		itemRevenue := bson.D{{Key: "$add", Value: bson.A{
+			bson.D{{Key: "$ifNull", Value: bson.A{"$item.unit_price", 0}}},
+			bson.D{{Key: "$sum", Value: bson.D{{Key: "$ifNull", Value: bson.A{"$item.adjustments.amount", bson.A{}}}}}},
+		}}}
+		cursor, err := invoiceCollection().Aggregate(ctx, append(paidInvoiceStages(location, from, to),
+			bson.D{{Key: "$lookup", Value: bson.D{
+				{Key: "from", Value: "orderItem"},
+				{Key: "localField", Value: "order_id"},
+				{Key: "foreignField", Value: "order_id"},
+				{Key: "as", Value: "item"},
+			}}},
+			bson.D{{Key: "$unwind", Value: "$item"}},
+			bson.D{{Key: "$group", Value: bson.D{
+				{Key: "_id", Value: "$item.food_id"},
+				{Key: "quantity", Value: bson.D{{Key: "$sum", Value: 1}}},
+				{Key: "revenue", Value: bson.D{{Key: "$sum", Value: itemRevenue}}},
+			}}},
+			bson.D{{Key: "$sort", Value: bson.D{{Key: sortBy, Value: -1}, {Key: secondary, Value: -1}, {Key: "_id", Value: 1}}}},
+			bson.D{{Key: "$limit", Value: limit}},
+			bson.D{{Key: "$lookup", Value: bson.D{
+				{Key: "from", Value: "food"},
+				{Key: "localField", Value: "_id"},
+				{Key: "foreignField", Value: "food_id"},
+				{Key: "as", Value: "food"},
+			}}},
+			bson.D{{Key: "$project", Value: bson.D{
+				{Key: "_id", Value: 0},
+				{Key: "food_id", Value: "$_id"},
+				{Key: "name", Value: bson.D{{Key: "$first", Value: "$food.name"}}},
+				{Key: "category_id", Value: bson.D{{Key: "$first", Value: "$food.category_id"}}},
+				{Key: "quantity", Value: 1},
+				{Key: "revenue", Value: 1},
+			}}},
+		))
+		if err != nil {
+			apierror.Render(c, apierror.Internal("error occurred while ranking foods: "+err.Error()))
+			return
+		}
+		foods := []TopFood{}
+		if err := cursor.All(ctx, &foods); err != nil {
+			apierror.Render(c, apierror.Internal(err.Error()))
+			return
+		}
+		for i := range foods {
+			foods[i].Revenue = toFixed(foods[i].Revenue, 2)
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"location": location,
+			"from":     from.Format("2006-01-02"),
+			"to":       to.AddDate(0, 0, -1).Format("2006-01-02"),
+			"sort":     sortBy,
+			"foods":    foods,
+		})
+	}
+}
+
+// RevenueRow is the paid orders of one table or staff member. Turn time
+// runs from opening the order to its invoice being settled. Tips are not
+// part of Revenue.
+type RevenueRow struct {
+	Id                   string  `json:"id"`
+	Name                 string  `json:"name"`
+	Orders               int     `json:"orders"`
+	Revenue              float64 `json:"revenue"`
+	Tips                 float64 `json:"tips"`
+	Average_ticket       float64 `json:"average_ticket"`
+	Average_turn_minutes float64 `json:"average_turn_minutes"`
+}
+
+// GetTableRevenueReport totals the paid invoices of a location between
+// ?from= and ?to= (YYYY-MM-DD, to inclusive, the last 7 days by default)
+// per table, highest revenue first.
+func GetTableRevenueReport() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		revenueReport(c, "$order.table_id", func(ctx context.Context, ids []string) (map[string]string, error) {
+			cursor, err := tableCollection().Find(ctx, bson.M{"table_id": bson.M{"$in": ids}})
+			if err != nil {
+				return nil, err
+			}
+			var tables []models.Table
+			if err := cursor.All(ctx, &tables); err != nil {
+				return nil, err
+			}
+			names := map[string]string{}
+			for _, table := range tables {
+				if table.Table_number != nil {
+					names[table.Table_id] = fmt.Sprintf("Table %d", *table.Table_number)
+				}
+			}
+			return names, nil
+		})
+	}
+}
+
+// GetStaffRevenueReport is GetTableRevenueReport per staff member who
+// opened the orders. Orders opened before that was recorded count for
+// their server.
+func GetStaffRevenueReport() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		revenueReport(c, bson.D{{Key: "$ifNull", Value: bson.A{"$order.opened_by", "$order.server_id"}}}, func(ctx context.Context, ids []string) (map[string]string, error) {
+			cursor, err := userCollection().Find(ctx, bson.M{"user_id": bson.M{"$in": ids}}, options.Find().SetProjection(bson.M{"user_id": 1, "first_name": 1, "last_name": 1}))
+			if err != nil {
+				return nil, err
+			}
+			var users []models.User
+			if err := cursor.All(ctx, &users); err != nil {
+				return nil, err
+			}
+			names := map[string]string{}
+			for _, user := range users {
+				names[user.User_id] = strings.TrimSpace(stringValue(user.First_name) + " " + stringValue(user.Last_name))
+			}
+			return names, nil
+		})
+	}
+}
+
+// revenueReport groups the paid invoices in the requested range by groupBy,
+// an expression over the invoice and its order, and names the groups with
+// names.
+func revenueReport(c *gin.Context, groupBy interface{}, names func(ctx context.Context, ids []string) (map[string]string, error)) {
+	ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+	defer cancel()
+
+	from, to, err := parseReportRange(c)
+	if err != nil {
+		apierror.Render(c, apierror.BadRequest(err.Error()))
+		return
+	}
+	location := c.DefaultQuery("location", defaultLocation)
+
+	cursor, err := invoiceCollection().Aggregate(ctx, append(paidInvoiceStages(location, from, to),
+		bson.D{{Key: "$lookup", Value: bson.D{
+			{Key: "from", Value: "order"},
+			{Key: "localField", Value: "order_id"},
+			{Key: "foreignField", Value: "order_id"},
+			{Key: "as", Value: "order"},
+		}}},
+		bson.D{{Key: "$unwind", Value: "$order"}},
+		bson.D{{Key: "$group", Value: bson.D{
+			{Key: "_id", Value: groupBy},
+			{Key: "orders", Value: bson.D{{Key: "$addToSet", Value: "$order_id"}}},
+			{Key: "revenue", Value: bson.D{{Key: "$sum", Value: bson.D{{Key: "$ifNull", Value: bson.A{"$payment_due", 0}}}}}},
+			{Key: "tips", Value: bson.D{{Key: "$sum", Value: bson.D{{Key: "$ifNull", Value: bson.A{"$tip", 0}}}}}},
+			{Key: "turn_ms", Value: bson.D{{Key: "$avg", Value: bson.D{{Key: "$subtract", Value: bson.A{"$sold_at", "$order.created_at"}}}}}},
+		}}},
+		// The invoices of a split check count as one order
+		bson.D{{Key: "$set", Value: bson.D{{Key: "orders", Value: bson.D{{Key: "$size", Value: "$orders"}}}}}},
+		bson.D{{Key: "$sort", Value: bson.D{{Key: "revenue", Value: -1}, {Key: "_id", Value: 1}}}},
+	))
+	if err != nil {
+		apierror.Render(c, apierror.Internal("error occurred while totalling revenue: "+err.Error()))
+		return
+	}
+	var totals []struct {
+		ID      *string `bson:"_id"`
+		Orders  int     `bson:"orders"`
+		Revenue float64 `bson:"revenue"`
+		Tips    float64 `bson:"tips"`
+		Turn_ms float64 `bson:"turn_ms"`
+	}
+	if err := cursor.All(ctx, &totals); err != nil {
+		apierror.Render(c, apierror.Internal(err.Error()))
+		return
+	}
+
+	ids := []string{}
+	for _, total := range totals {
+		if total.ID != nil {
+			ids = append(ids, *total.ID)
+		}
+	}
+	named, err := names(ctx, ids)
+	if err != nil {
+		apierror.Render(c, apierror.Internal("error occurred while naming report rows: "+err.Error()))
+		return
+	}
+
+	rows := make([]RevenueRow, 0, len(totals))
+	for _, total := range totals {
+		row := RevenueRow{
+			Orders:               total.Orders,
+			Revenue:              toFixed(total.Revenue, 2),
+			Tips:                 toFixed(total.Tips, 2),
+			Average_ticket:       averageTicket(total.Revenue, total.Orders),
+			Average_turn_minutes: toFixed(total.Turn_ms/float64(time.Minute/time.Millisecond), 1),
+		}
+		if total.ID != nil {
+			row.Id, row.Name = *total.ID, named[*total.ID]
+		}
+		rows = append(rows, row)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"location": location,
+		"from":     from.Format("2006-01-02"),
+		"to":       to.AddDate(0, 0, -1).Format("2006-01-02"),
+		"rows":     rows,
+	})
+}
@@ -0,0 +1,273 @@
+package controllers
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"net/http"
+	"restaurant-management/apierror"
+	"restaurant-management/config"
+	"restaurant-management/models"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var segmentCollection = db.Collection("segment")
+
+func GetSegments() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		result, err := segmentCollection().Find(ctx, bson.M{})
+		if err != nil {
+			apierror.Render(c, apierror.Internal("error occurred while listing segments: "+err.Error()))
+			return
+		}
+
+		var allSegments []bson.M
+		if err = result.All(ctx, &allSegments); err != nil {
+			apierror.Render(c, apierror.Internal("error decoding segments: "+err.Error()))
+			return
+		}
+
+		c.JSON(http.StatusOK, allSegments)
+	}
+}
+
+func GetSegment() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		var segment models.Segment
+		err := segmentCollection().FindOne(ctx, bson.M{"segment_id": c.Param("segment_id")}).Decode(&segment)
+		if err != nil {
+			apierror.Render(c, apierror.NotFound("Segment not found"))
+			return
+		}
+
+		c.JSON(http.StatusOK, segment)
+	}
+}
+
+func CreateSegment() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		var segment models.Segment
+		if err := c.BindJSON(&segment); err != nil {
+			apierror.Render(c, apierror.BadRequest("Invalid request data: "+err.Error()))
+			return
+		}
+
+		if err := validate.Struct(segment); err != nil {
+			apierror.Render(c, apierror.FromValidation(err))
+			return
+		}
+
+		now := time.Now().Format(time.RFC3339)
+		segment.Created_at, _ = time.Parse(time.RFC3339, now)
+		segment.Updated_at, _ = time.Parse(time.RFC3339, now)
+		segment.ID = primitive.NewObjectID()
+		segment.Segment_id = segment.ID.Hex()
+
+		result, insertErr := segmentCollection().InsertOne(ctx, segment)
+		if insertErr != nil {
+			apierror.Render(c, apierror.Internal("Could not create segment"))
+			return
+		}
+
+		c.JSON(http.StatusCreated, gin.H{"message": "Segment created", "data": result})
+	}
+}
+
+func UpdateSegment() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		var segment models.Segment
+		if err := c.BindJSON(&segment); err != nil {
+			apierror.Render(c, apierror.BadRequest("Invalid request data: "+err.Error()))
+			return
+		}
+
+		var updateObj primitive.D
+		if segment.Name != nil {
+			updateObj = append(updateObj, bson.E{Key: "name", Value: segment.Name})
+		}
+		if segment.Description != "" {
+			updateObj = append(updateObj, bson.E{Key: "description", Value: segment.Description})
+		}
+		if segment.Rules != nil {
+			if err := validate.Struct(segment.Rules); err != nil {
+				apierror.Render(c, apierror.FromValidation(err))
+				return
+			}
+			updateObj = append(updateObj, bson.E{Key: "rules", Value: segment.Rules})
+		}
+
+		updatedAt, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		updateObj = append(updateObj, bson.E{Key: "updated_at", Value: updatedAt})
+
+		result, err := segmentCollection().UpdateOne(ctx, bson.M{"segment_id": c.Param("segment_id")}, bson.D{{Key: "$set", Value: updateObj}})
+		if err != nil {
+			apierror.Render(c, apierror.Internal("Update failed: "+err.Error()))
+			return
+		}
+		if result.MatchedCount == 0 {
+			apierror.Render(c, apierror.NotFound("Segment not found"))
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Segment updated successfully", "result": result})
+	}
+}
+
+func GetSegmentMembers() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		var segment models.Segment
+		if err := segmentCollection().FindOne(ctx, bson.M{"segment_id": c.Param("segment_id")}).Decode(&segment); err != nil {
+			apierror.Render(c, apierror.NotFound("Segment not found"))
+			return
+		}
+
+		members, err := SegmentMembers(ctx, *segment.Rules, time.Now())
+		if err != nil {
+			apierror.Render(c, apierror.Internal("error occurred while evaluating segment: "+err.Error()))
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"segment_id": segment.Segment_id, "total_count": len(members), "members": members})
+	}
+}
+
+// ExportSegmentMembers writes the segment members as CSV for campaign tools.
+func ExportSegmentMembers() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		var segment models.Segment
+		if err := segmentCollection().FindOne(ctx, bson.M{"segment_id": c.Param("segment_id")}).Decode(&segment); err != nil {
+			apierror.Render(c, apierror.NotFound("Segment not found"))
+			return
+		}
+
+		members, err := SegmentMembers(ctx, *segment.Rules, time.Now())
+		if err != nil {
+			apierror.Render(c, apierror.Internal("error occurred while evaluating segment: "+err.Error()))
+			return
+		}
+
+		c.Header("Content-Type", "text/csv")
+		c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=segment-%s.csv", segment.Segment_id))
+
+		writer := csv.NewWriter(c.Writer)
+		writer.Write([]string{"customer_id", "last_order_at", "orders", "spend", "lifetime_orders", "lifetime_spend"})
+		for _, member := range members {
+			writer.Write([]string{
+				member.Customer_id,
+				member.Last_order_at.Format(time.RFC3339),
+				strconv.Itoa(member.Orders),
+				strconv.FormatFloat(member.Spend, 'f', 2, 64),
+				strconv.Itoa(member.Lifetime_orders),
+				strconv.FormatFloat(member.Lifetime_spend, 'f', 2, 64),
+			})
+		}
+		writer.Flush()
+	}
+}
+
+// SegmentMembers evaluates segment rules against the order history of every
+// customer that has placed an order. Spend is the sum of order item prices
+// including adjustments.
+func SegmentMembers(ctx context.Context, rules models.SegmentRules, now time.Time) ([]models.SegmentMember, error) {
+	windowStart := time.Time{}
+	if rules.Window_days != nil {
+		windowStart = now.AddDate(0, 0, -*rules.Window_days)
+	}
+	inWindow := bson.D{{Key: "$gte", Value: bson.A{"$created_at", windowStart}}}
+
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.D{{Key: "customer_id", Value: bson.D{{Key: "$nin", Value: bson.A{nil, ""}}}}}}},
+		{{Key: "$lookup", Value: bson.D{
+			{Key: "from", Value: "orderItem"},
+			{Key: "localField", Value: "order_id"},
+			{Key: "foreignField", Value: "order_id"},
+			{Key: "as", Value: "items"},
+		}}},
+		{{Key: "$project", Value: bson.D{
+			{Key: "customer_id", Value: 1},
+			{Key: "created_at", Value: 1},
+			{Key: "total", Value: itemsTotal("$items")},
+		}}},
+		{{Key: "$group", Value: bson.D{
+			{Key: "_id", Value: "$customer_id"},
+			{Key: "last_order_at", Value: bson.D{{Key: "$max", Value: "$created_at"}}},
+			{Key: "orders", Value: bson.D{{Key: "$sum", Value: bson.D{{Key: "$cond", Value: bson.A{inWindow, 1, 0}}}}}},
+			{Key: "spend", Value: bson.D{{Key: "$sum", Value: bson.D{{Key: "$cond", Value: bson.A{inWindow, "$total", 0}}}}}},
+			{Key: "lifetime_orders", Value: bson.D{{Key: "$sum", Value: 1}}},
+			{Key: "lifetime_spend", Value: bson.D{{Key: "$sum", Value: "$total"}}},
+		}}},
+	}
+
+	match := bson.D{}
+	lastOrder := bson.D{}
+	if rules.Min_days_since_last_order != nil {
+		lastOrder = append(lastOrder, bson.E{Key: "$lte", Value: now.AddDate(0, 0, -*rules.Min_days_since_last_order)})
+	}
+	if rules.Max_days_since_last_order != nil {
+		lastOrder = append(lastOrder, bson.E{Key: "$gte", Value: now.AddDate(0, 0, -*rules.Max_days_since_last_order)})
+	}
+	if len(lastOrder) > 0 {
+		match = append(match, bson.E{Key: "last_order_at", Value: lastOrder})
+	}
+	if rules.Min_spend != nil {
+		match = append(match, bson.E{Key: "spend", Value: bson.D{{Key: "$gte", Value: *rules.Min_spend}}})
+	}
+	if rules.Min_orders != nil {
+		match = append(match, bson.E{Key: "orders", Value: bson.D{{Key: "$gte", Value: *rules.Min_orders}}})
+	}
+	if len(match) > 0 {
+		pipeline = append(pipeline, bson.D{{Key: "$match", Value: match}})
+	}
+	pipeline = append(pipeline, bson.D{{Key: "$sort", Value: bson.D{{Key: "spend", Value: -1}}}})
+
+	cursor, err := orderCollection().Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+	members := []models.SegmentMember{}
+	if err = cursor.All(ctx, &members); err != nil {
+		return nil, err
+	}
+	for i := range members {
+		members[i].Spend = toFixed(members[i].Spend, 2)
+		members[i].Lifetime_spend = toFixed(members[i].Lifetime_spend, 2)
+	}
+	return members, nil
+}
+
+// itemsTotal builds an aggregation expression summing the prices and
+// adjustments of an array of looked-up order items.
+func itemsTotal(items string) bson.D {
+	return bson.D{{Key: "$add", Value: bson.A{
+		bson.D{{Key: "$sum", Value: items + ".unit_price"}},
+		bson.D{{Key: "$sum", Value: bson.D{{Key: "$reduce", Value: bson.D{
+			{Key: "input", Value: items + ".adjustments"},
+			{Key: "initialValue", Value: 0},
+			{Key: "in", Value: bson.D{{Key: "$add", Value: bson.A{"$$value", bson.D{{Key: "$sum", Value: "$$this.amount"}}}}}},
+		}}}}},
+	}}}
+}
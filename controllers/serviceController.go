@@ -0,0 +1,32 @@
+package controllers
+
+import (
+	"errors"
+	"restaurant-management/apierror"
+	"restaurant-management/services"
+)
+
+// serviceError answers an error from the services package: domain errors
+// by their kind, anything else as the storage failure it is.
+func serviceError(err error) *apierror.Error {
+	var domainErr *services.Error
+	if !errors.As(err, &domainErr) {
+		return apierror.Internal(err.Error())
+	}
+
+	var apiErr *apierror.Error
+	switch {
+	case errors.Is(err, services.ErrValidation):
+		apiErr = apierror.FromValidation(err)
+	case errors.Is(err, services.ErrNotFound):
+		apiErr = apierror.NotFound(domainErr.Message)
+	case errors.Is(err, services.ErrConflict):
+		apiErr = apierror.Conflict(domainErr.Message)
+	default:
+		apiErr = apierror.BadRequest(domainErr.Message)
+	}
+	for key, value := range domainErr.Details {
+		apiErr.With(key, value)
+	}
+	return apiErr
+}
@@ -0,0 +1,159 @@
+package controllers
+
+import (
+	"context"
+	"net/http"
+	"restaurant-management/apierror"
+	"restaurant-management/config"
+	"restaurant-management/models"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var shiftCollection = db.Collection("shift")
+
+// GetShifts lists the rota, optionally for one employee or location and
+// within ?from= and ?to= (YYYY-MM-DD).
+func GetShifts() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		filter := bson.M{}
+		for _, key := range []string{"employee_id", "location", "section", "status"} {
+			if value := c.Query(key); value != "" {
+				filter[key] = value
+			}
+		}
+		if c.Query("from") != "" || c.Query("to") != "" {
+			from, to, err := parseReportRange(c)
+			if err != nil {
+				apierror.Render(c, apierror.BadRequest(err.Error()))
+				return
+			}
+			filter["start"] = bson.M{"$gte": from, "$lt": to}
+		}
+
+		opts := options.Find().SetSort(bson.D{{Key: "start", Value: 1}})
+		result, err := shiftCollection().Find(ctx, filter, opts)
+		if err != nil {
+			apierror.Render(c, apierror.Internal("error occurred while listing shifts: "+err.Error()))
+			return
+		}
+
+		var allShifts []bson.M
+		if err = result.All(ctx, &allShifts); err != nil {
+			apierror.Render(c, apierror.Internal("error decoding shifts: "+err.Error()))
+			return
+		}
+
+		c.JSON(http.StatusOK, allShifts)
+	}
+}
+
+func CreateShift() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		var shift models.Shift
+		if err := c.BindJSON(&shift); err != nil {
+			apierror.Render(c, apierror.BadRequest("Invalid request data: "+err.Error()))
+			return
+		}
+		if err := validate.Struct(shift); err != nil {
+			apierror.Render(c, apierror.FromValidation(err))
+			return
+		}
+
+		if shift.Location == nil {
+			location := defaultLocation
+			shift.Location = &location
+		}
+		shift.Status = "SCHEDULED"
+		shift.Created_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		shift.Updated_at = shift.Created_at
+		shift.ID = primitive.NewObjectID()
+		shift.Shift_id = shift.ID.Hex()
+
+		if _, err := shiftCollection().InsertOne(ctx, shift); err != nil {
+			apierror.Render(c, apierror.Internal("Shift was not created: "+err.Error()))
+			return
+		}
+
+		c.JSON(http.StatusCreated, gin.H{"message": "Shift scheduled", "shift_id": shift.Shift_id})
+	}
+}
+
+// UpdateShift moves, reassigns or cancels a scheduled shift. Cancelled
+// shifts stay on the rota so subscribed calendars drop them too.
+func UpdateShift() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		var existing models.Shift
+		filter := bson.M{"shift_id": c.Param("shift_id")}
+		if err := shiftCollection().FindOne(ctx, filter).Decode(&existing); err != nil {
+			apierror.Render(c, apierror.NotFound("Shift not found"))
+			return
+		}
+
+		var shift models.Shift
+		if err := c.BindJSON(&shift); err != nil {
+			apierror.Render(c, apierror.BadRequest("Invalid request data: "+err.Error()))
+			return
+		}
+
+		var updateObj primitive.D
+		if shift.Employee_id != nil {
+			updateObj = append(updateObj, bson.E{Key: "employee_id", Value: shift.Employee_id})
+		}
+		if shift.Location != nil {
+			updateObj = append(updateObj, bson.E{Key: "location", Value: shift.Location})
+		}
+		if shift.Role != nil {
+			updateObj = append(updateObj, bson.E{Key: "role", Value: shift.Role})
+		}
+		if shift.Section != nil {
+			updateObj = append(updateObj, bson.E{Key: "section", Value: shift.Section})
+		}
+		if shift.Notes != nil {
+			updateObj = append(updateObj, bson.E{Key: "notes", Value: shift.Notes})
+		}
+		start, end := existing.Start, existing.End
+		if shift.Start != nil {
+			start = shift.Start
+			updateObj = append(updateObj, bson.E{Key: "start", Value: shift.Start})
+		}
+		if shift.End != nil {
+			end = shift.End
+			updateObj = append(updateObj, bson.E{Key: "end", Value: shift.End})
+		}
+		if !end.After(*start) {
+			apierror.Render(c, apierror.Validation("Validation failed: end must be after start"))
+			return
+		}
+		if shift.Status != "" {
+			if err := validate.Var(shift.Status, "oneof=SCHEDULED CANCELLED"); err != nil {
+				apierror.Render(c, apierror.Validation("Validation failed: status must be SCHEDULED or CANCELLED"))
+				return
+			}
+			updateObj = append(updateObj, bson.E{Key: "status", Value: shift.Status})
+		}
+
+		updatedAt, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		updateObj = append(updateObj, bson.E{Key: "updated_at", Value: updatedAt})
+
+		if _, err := shiftCollection().UpdateOne(ctx, filter, bson.D{{Key: "$set", Value: updateObj}}); err != nil {
+			apierror.Render(c, apierror.Internal("Update failed: "+err.Error()))
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Shift updated successfully"})
+	}
+}
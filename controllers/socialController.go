@@ -0,0 +1,290 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"restaurant-management/apierror"
+	"restaurant-management/config"
+	"restaurant-management/models"
+	"restaurant-management/social"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var socialAccountCollection = db.Collection("socialAccount")
+var socialPostCollection = db.Collection("socialPost")
+
+// maxSpecialsPerPost keeps captions within what platforms display untruncated.
+const maxSpecialsPerPost = 8
+
+func GetSocialAccounts() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		// Never echo stored access tokens back to clients
+		opts := options.Find().SetProjection(bson.M{"access_token": 0})
+		result, err := socialAccountCollection().Find(ctx, bson.M{}, opts)
+		if err != nil {
+			apierror.Render(c, apierror.Internal("error occurred while listing social accounts: "+err.Error()))
+			return
+		}
+
+		var allAccounts []bson.M
+		if err = result.All(ctx, &allAccounts); err != nil {
+			apierror.Render(c, apierror.Internal("error decoding social accounts: "+err.Error()))
+			return
+		}
+
+		c.JSON(http.StatusOK, allAccounts)
+	}
+}
+
+func CreateSocialAccount() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		var account models.SocialAccount
+		if err := c.BindJSON(&account); err != nil {
+			apierror.Render(c, apierror.BadRequest("Invalid request data: "+err.Error()))
+			return
+		}
+
+		if err := validate.Struct(account); err != nil {
+			apierror.Render(c, apierror.FromValidation(err))
+			return
+		}
+
+		if *account.Platform == "WEBHOOK" && account.Webhook_url == "" {
+			apierror.Render(c, apierror.Validation("Validation failed: webhook_url is required for WEBHOOK accounts"))
+			return
+		}
+		if *account.Platform != "WEBHOOK" && (account.Page_id == "" || account.Access_token == "") {
+			apierror.Render(c, apierror.Validation("Validation failed: page_id and access_token are required"))
+			return
+		}
+
+		if account.Active == nil {
+			active := true
+			account.Active = &active
+		}
+
+		now := time.Now().Format(time.RFC3339)
+		account.Created_at, _ = time.Parse(time.RFC3339, now)
+		account.Updated_at, _ = time.Parse(time.RFC3339, now)
+		account.ID = primitive.NewObjectID()
+		account.Social_account_id = account.ID.Hex()
+
+		_, insertErr := socialAccountCollection().InsertOne(ctx, account)
+		if insertErr != nil {
+			apierror.Render(c, apierror.Internal("Could not create social account"))
+			return
+		}
+
+		c.JSON(http.StatusCreated, gin.H{"message": "Social account created", "social_account_id": account.Social_account_id})
+	}
+}
+
+func UpdateSocialAccount() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		var account models.SocialAccount
+		if err := c.BindJSON(&account); err != nil {
+			apierror.Render(c, apierror.BadRequest("Invalid request data: "+err.Error()))
+			return
+		}
+
+		var updateObj primitive.D
+		if account.Name != nil {
+			updateObj = append(updateObj, bson.E{Key: "name", Value: account.Name})
+		}
+		if account.Page_id != "" {
+			updateObj = append(updateObj, bson.E{Key: "page_id", Value: account.Page_id})
+		}
+		if account.Access_token != "" {
+			updateObj = append(updateObj, bson.E{Key: "access_token", Value: account.Access_token})
+		}
+		if account.Webhook_url != "" {
+			if err := validate.Var(account.Webhook_url, "url"); err != nil {
+				apierror.Render(c, apierror.Validation("Validation failed: webhook_url must be a URL"))
+				return
+			}
+			updateObj = append(updateObj, bson.E{Key: "webhook_url", Value: account.Webhook_url})
+		}
+		if account.Active != nil {
+			updateObj = append(updateObj, bson.E{Key: "active", Value: account.Active})
+		}
+
+		updatedAt, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		updateObj = append(updateObj, bson.E{Key: "updated_at", Value: updatedAt})
+
+		result, err := socialAccountCollection().UpdateOne(ctx, bson.M{"social_account_id": c.Param("social_account_id")}, bson.D{{Key: "$set", Value: updateObj}})
+		if err != nil {
+			apierror.Render(c, apierror.Internal("Update failed: "+err.Error()))
+			return
+		}
+		if result.MatchedCount == 0 {
+			apierror.Render(c, apierror.NotFound("Social account not found"))
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Social account updated successfully"})
+	}
+}
+
+// PreviewSpecialsPost renders today's specials without publishing them.
+func PreviewSpecialsPost() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		post, err := renderSpecialsPost(ctx, time.Now())
+		if err != nil {
+			apierror.Render(c, apierror.Internal("error occurred while rendering specials: "+err.Error()))
+			return
+		}
+		if post == nil {
+			c.JSON(http.StatusOK, gin.H{"message": "No specials today"})
+			return
+		}
+
+		c.JSON(http.StatusOK, post)
+	}
+}
+
+// PublishSpecialsPost publishes today's specials to every active account now.
+func PublishSpecialsPost() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		posts, err := publishSpecials(ctx, time.Now())
+		if err != nil {
+			apierror.Render(c, apierror.Internal("error occurred while publishing specials: "+err.Error()))
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Specials published", "posts": posts})
+	}
+}
+
+func GetSocialPosts() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}}).SetLimit(100)
+		result, err := socialPostCollection().Find(ctx, bson.M{}, opts)
+		if err != nil {
+			apierror.Render(c, apierror.Internal("error occurred while listing social posts: "+err.Error()))
+			return
+		}
+
+		var allPosts []bson.M
+		if err = result.All(ctx, &allPosts); err != nil {
+			apierror.Render(c, apierror.Internal("error decoding social posts: "+err.Error()))
+			return
+		}
+
+		c.JSON(http.StatusOK, allPosts)
+	}
+}
+
+// PublishDailySpecials is the scheduled job posting the day's specials.
+func PublishDailySpecials(ctx context.Context) error {
+	_, err := publishSpecials(ctx, time.Now())
+	return err
+}
+
+func publishSpecials(ctx context.Context, day time.Time) ([]models.SocialPost, error) {
+	post, err := renderSpecialsPost(ctx, day)
+	if err != nil || post == nil {
+		return nil, err
+	}
+
+	result, err := socialAccountCollection().Find(ctx, bson.M{"active": true})
+	if err != nil {
+		return nil, err
+	}
+	var accounts []models.SocialAccount
+	if err = result.All(ctx, &accounts); err != nil {
+		return nil, err
+	}
+
+	posts := []models.SocialPost{}
+	for _, account := range accounts {
+		record := models.SocialPost{
+			ID:                primitive.NewObjectID(),
+			Social_account_id: account.Social_account_id,
+			Platform:          *account.Platform,
+			Caption:           post.Caption,
+			Image_url:         post.Image_url,
+			Status:            "PUBLISHED",
+		}
+		record.Social_post_id = record.ID.Hex()
+		record.Created_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+
+		externalId, err := social.Publish(ctx, social.Account{
+			Platform:     *account.Platform,
+			Page_id:      account.Page_id,
+			Access_token: account.Access_token,
+			Webhook_url:  account.Webhook_url,
+		}, *post)
+		if err != nil {
+			record.Status = "FAILED"
+			record.Error = err.Error()
+		}
+		record.External_id = externalId
+
+		if _, err := socialPostCollection().InsertOne(ctx, record); err != nil {
+			return posts, err
+		}
+		posts = append(posts, record)
+	}
+	return posts, nil
+}
+
+// renderSpecialsPost builds the caption and imagery for the foods on a price
+// schedule that runs on the given day. It returns nil when there are none.
+func renderSpecialsPost(ctx context.Context, day time.Time) (*social.Post, error) {
+	specials, err := findSpecials(ctx, func(schedule models.PriceSchedule) bool {
+		return scheduleRunsOn(schedule, day)
+	}, maxSpecialsPerPost)
+	if err != nil {
+		return nil, err
+	}
+	if len(specials) == 0 {
+		return nil, nil
+	}
+
+	var caption strings.Builder
+	caption.WriteString(fmt.Sprintf("Today's specials (%s):\n", day.Format("Monday, Jan 2")))
+	post := &social.Post{Image_urls: []string{}}
+	for _, s := range specials {
+		name, price := "", 0.0
+		if s.Food.Name != nil {
+			name = *s.Food.Name
+		}
+		if s.Food.Price != nil {
+			price = *s.Food.Price
+		}
+		caption.WriteString(fmt.Sprintf("- %s: %.2f (was %.2f), %s-%s\n", name, s.Special_price, price, *s.Schedule.Start_time, *s.Schedule.End_time))
+		if s.Food.Food_image != nil && *s.Food.Food_image != "" {
+			post.Image_urls = append(post.Image_urls, *s.Food.Food_image)
+		}
+	}
+	post.Caption = strings.TrimSpace(caption.String())
+	if len(post.Image_urls) > 0 {
+		post.Image_url = post.Image_urls[0]
+	}
+	return post, nil
+}
@@ -0,0 +1,116 @@
+package controllers
+
+import (
+	"context"
+	"net/http"
+	"restaurant-management/apierror"
+	"restaurant-management/config"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// notDeleted matches documents that were never soft deleted, including
+// those stored before deleted_at existed.
+var notDeleted interface{} = nil
+
+// includeDeleted says whether a list or get should also return soft
+// deleted records. Only admins can ask for them with ?include_deleted=true.
+func includeDeleted(c *gin.Context) bool {
+	return c.Query("include_deleted") == "true" && c.GetString("role") == "ADMIN"
+}
+
+// liveFilter leaves soft deleted records out of filter unless the caller
+// asked for them.
+func liveFilter(c *gin.Context, filter bson.M) bson.M {
+	if !includeDeleted(c) {
+		filter["deleted_at"] = notDeleted
+	}
+	return filter
+}
+
+// softDelete stamps deleted_at on the document whose idField is id. It
+// reports whether a live document was found.
+func softDelete(ctx context.Context, collection *mongo.Collection, idField, id string) (bool, error) {
+	now, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+	result, err := collection.UpdateOne(
+		ctx,
+		bson.M{idField: id, "deleted_at": notDeleted},
+		bson.D{{Key: "$set", Value: bson.D{
+			{Key: "deleted_at", Value: now},
+			{Key: "updated_at", Value: now},
+		}}},
+	)
+	if err != nil {
+		return false, err
+	}
+	return result.MatchedCount > 0, nil
+}
+
+// idsOf lists the idField of every document in collection matching
+// filter, for telling callers which documents block a delete.
+func idsOf(ctx context.Context, collection *mongo.Collection, filter bson.M, idField string) ([]string, error) {
+	cursor, err := collection.Find(ctx, filter, options.Find().SetProjection(bson.M{idField: 1}))
+	if err != nil {
+		return nil, err
+	}
+	var docs []bson.M
+	if err = cursor.All(ctx, &docs); err != nil {
+		return nil, err
+	}
+	ids := []string{}
+	for _, doc := range docs {
+		if id, ok := doc[idField].(string); ok {
+			ids = append(ids, id)
+		}
+	}
+	return ids, nil
+}
+
+// restoreHandler undoes a soft delete of the document whose idField is the
+// route parameter of the same name.
+func restoreHandler(collection func() *mongo.Collection, idField, name string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		now, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		result, err := collection().UpdateOne(
+			ctx,
+			bson.M{idField: c.Param(idField), "deleted_at": bson.M{"$ne": nil}},
+			bson.D{
+				{Key: "$unset", Value: bson.D{{Key: "deleted_at", Value: ""}}},
+				{Key: "$set", Value: bson.D{{Key: "updated_at", Value: now}}},
+			},
+		)
+		if err != nil {
+			apierror.Render(c, apierror.Internal("Restore failed: "+err.Error()))
+			return
+		}
+		if result.MatchedCount == 0 {
+			apierror.Render(c, apierror.NotFound("No deleted "+name+" with that id"))
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": name + " restored"})
+	}
+}
+
+func RestoreFood() gin.HandlerFunc {
+	return restoreHandler(foodCollection, "food_id", "Food")
+}
+
+func RestoreMenu() gin.HandlerFunc {
+	return restoreHandler(menuCollection, "menu_id", "Menu")
+}
+
+func RestoreOrder() gin.HandlerFunc {
+	return restoreHandler(orderCollection, "order_id", "Order")
+}
+
+func RestoreTable() gin.HandlerFunc {
+	return restoreHandler(tableCollection, "table_id", "Table")
+}
@@ -0,0 +1,185 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"restaurant-management/apierror"
+	"restaurant-management/config"
+	"restaurant-management/models"
+	"restaurant-management/services"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// UpdateFoodSubstitutions replaces the approved substitutions of a food.
+// Substitutions keeping their substitution_id stay valid for open orders.
+func UpdateFoodSubstitutions() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		var body struct {
+			Substitutions []models.Substitution `json:"substitutions" validate:"dive"`
+		}
+		if err := c.BindJSON(&body); err != nil {
+			apierror.Render(c, apierror.BadRequest("Invalid request data: "+err.Error()))
+			return
+		}
+		if err := validate.Struct(body); err != nil {
+			apierror.Render(c, apierror.FromValidation(err))
+			return
+		}
+		if err := services.PrepareSubstitutions(body.Substitutions); err != nil {
+			apierror.Render(c, apierror.FromValidation(err))
+			return
+		}
+		if body.Substitutions == nil {
+			body.Substitutions = []models.Substitution{}
+		}
+
+		updatedAt, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		result, err := foodCollection().UpdateOne(
+			ctx,
+			bson.M{"food_id": c.Param("food_id")},
+			bson.D{{Key: "$set", Value: bson.D{
+				{Key: "substitutions", Value: body.Substitutions},
+				{Key: "updated_at", Value: updatedAt},
+			}}},
+		)
+		if err != nil {
+			apierror.Render(c, apierror.Internal("Update failed: "+err.Error()))
+			return
+		}
+		if result.MatchedCount == 0 {
+			apierror.Render(c, apierror.NotFound("Food item not found"))
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Substitutions updated", "data": body.Substitutions})
+	}
+}
+
+// substitutionAdjustments checks the substitutions requested for an order
+// item against its food's approved ones and prices them. Only one
+// substitute may replace an ingredient.
+func substitutionAdjustments(food models.Food, orderItem models.OrderItem) ([]models.PriceAdjustment, error) {
+	if len(orderItem.Substitutions) == 0 {
+		return nil, nil
+	}
+	approved := map[string]models.Substitution{}
+	for _, substitution := range food.Substitutions {
+		approved[substitution.Substitution_id] = substitution
+	}
+
+	adjustments := []models.PriceAdjustment{}
+	replaced := map[string]bool{}
+	for _, id := range orderItem.Substitutions {
+		substitution, ok := approved[id]
+		if !ok {
+			return nil, fmt.Errorf("substitution %s is not offered for %s", id, stringOr(food.Name, food.Food_id))
+		}
+		if replaced[*substitution.Replaces_sku] {
+			return nil, fmt.Errorf("%s is substituted more than once", *substitution.Replaces_sku)
+		}
+		replaced[*substitution.Replaces_sku] = true
+
+		delta := 0.0
+		if substitution.Price_delta != nil {
+			delta = toFixed(*substitution.Price_delta, 2)
+		}
+		adjustments = append(adjustments, models.PriceAdjustment{
+			Type:        "SUBSTITUTION",
+			Source_id:   substitution.Substitution_id,
+			Description: *substitution.Name,
+			Amount:      delta,
+		})
+	}
+	return adjustments, nil
+}
+
+// depleteInventory takes the ingredients of sold items off the stock of the
+// order's location. An item uses its recipe's batch quantities divided by
+// the batch size, with substitutes used instead of the ingredients they
+// replace. Foods without a recipe and ingredients not stocked are skipped.
+func depleteInventory(ctx context.Context, order models.Order, orderItems []models.OrderItem) error {
+	foodIds := []string{}
+	for _, orderItem := range orderItems {
+		foodIds = append(foodIds, stringValue(orderItem.Food_id))
+	}
+	cursor, err := recipeCollection().Find(ctx, bson.M{"food_id": bson.M{"$in": foodIds}})
+	if err != nil {
+		return err
+	}
+	var recipes []models.Recipe
+	if err = cursor.All(ctx, &recipes); err != nil {
+		return err
+	}
+	if len(recipes) == 0 {
+		return nil
+	}
+	recipesByFood := map[string]models.Recipe{}
+	for _, recipe := range recipes {
+		recipesByFood[recipe.Food_id] = recipe
+	}
+	foods, err := foodsById(ctx, foodIds)
+	if err != nil {
+		return err
+	}
+
+	used := map[string]float64{}
+	skus := []string{}
+	for _, orderItem := range orderItems {
+		foodId := stringValue(orderItem.Food_id)
+		recipe, ok := recipesByFood[foodId]
+		if !ok {
+			continue
+		}
+		batchSize := 1.0
+		if recipe.Batch_size != nil {
+			batchSize = float64(*recipe.Batch_size)
+		}
+
+		swaps := map[string]models.Substitution{}
+		for _, substitution := range foods[foodId].Substitutions {
+			for _, id := range orderItem.Substitutions {
+				if substitution.Substitution_id == id {
+					swaps[*substitution.Replaces_sku] = substitution
+				}
+			}
+		}
+
+		for _, ingredient := range recipe.Ingredients {
+			sku, quantity := *ingredient.Sku, *ingredient.Quantity/batchSize
+			if substitution, ok := swaps[sku]; ok {
+				sku = *substitution.Sku
+				if substitution.Quantity_factor != nil {
+					quantity *= *substitution.Quantity_factor
+				}
+			}
+			if _, ok := used[sku]; !ok {
+				skus = append(skus, sku)
+			}
+			used[sku] += quantity
+		}
+	}
+
+	location := orderLocation(ctx, order)
+	now, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+	for _, sku := range skus {
+		_, err := inventoryCollection().UpdateOne(
+			ctx,
+			bson.M{"sku": sku, "location": location},
+			bson.D{
+				{Key: "$inc", Value: bson.D{{Key: "on_hand", Value: -toFixed(used[sku], 3)}}},
+				{Key: "$set", Value: bson.D{{Key: "updated_at", Value: now}}},
+			},
+		)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
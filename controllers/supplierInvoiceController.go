@@ -0,0 +1,430 @@
+package controllers
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"restaurant-management/apierror"
+	"restaurant-management/config"
+	"restaurant-management/models"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var supplierInvoiceCollection = db.Collection("supplierInvoice")
+
+// Differences at or below these tolerances are treated as rounding noise.
+const (
+	invoicePriceTolerance    = 0.01
+	invoiceQuantityTolerance = 0.001
+)
+
+// invoice_date and description columns are optional in CSV imports.
+var supplierInvoiceColumns = []string{"invoice_number", "supplier", "po_number", "sku", "quantity", "unit_price"}
+
+func GetSupplierInvoices() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		filter := bson.M{}
+		for _, key := range []string{"match_status", "supplier", "po_number"} {
+			if value := c.Query(key); value != "" {
+				filter[key] = value
+			}
+		}
+
+		opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}})
+		result, err := supplierInvoiceCollection().Find(ctx, filter, opts)
+		if err != nil {
+			apierror.Render(c, apierror.Internal("error occurred while listing supplier invoices: "+err.Error()))
+			return
+		}
+
+		var allInvoices []bson.M
+		if err = result.All(ctx, &allInvoices); err != nil {
+			apierror.Render(c, apierror.Internal("error decoding supplier invoices: "+err.Error()))
+			return
+		}
+
+		c.JSON(http.StatusOK, allInvoices)
+	}
+}
+
+func GetSupplierInvoice() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		var invoice models.SupplierInvoice
+		err := supplierInvoiceCollection().FindOne(ctx, bson.M{"supplier_invoice_id": c.Param("supplier_invoice_id")}).Decode(&invoice)
+		if err != nil {
+			apierror.Render(c, apierror.NotFound("Supplier invoice not found"))
+			return
+		}
+
+		c.JSON(http.StatusOK, invoice)
+	}
+}
+
+// CreateSupplierInvoice records a manually keyed invoice and matches it
+// straight away.
+func CreateSupplierInvoice() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		var invoice models.SupplierInvoice
+		if err := c.BindJSON(&invoice); err != nil {
+			apierror.Render(c, apierror.BadRequest("Invalid request data: "+err.Error()))
+			return
+		}
+
+		status, err := saveSupplierInvoice(ctx, &invoice, "MANUAL")
+		if err != nil {
+			apierror.Render(c, apierror.FromStatus(status, err.Error()))
+			return
+		}
+
+		c.JSON(http.StatusCreated, gin.H{"message": "Supplier invoice created", "data": invoice})
+	}
+}
+
+// ImportSupplierInvoices loads invoices from a CSV upload (multipart field
+// "file"), one row per line item. Rows sharing supplier and invoice_number
+// make up one invoice.
+func ImportSupplierInvoices() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		file, err := c.FormFile("file")
+		if err != nil {
+			apierror.Render(c, apierror.BadRequest("file is required"))
+			return
+		}
+		reader, err := file.Open()
+		if err != nil {
+			apierror.Render(c, apierror.BadRequest("error opening upload: "+err.Error()))
+			return
+		}
+		defer reader.Close()
+
+		invoices, err := parseSupplierInvoiceCSV(reader)
+		if err != nil {
+			apierror.Render(c, apierror.BadRequest(err.Error()))
+			return
+		}
+
+		results := []gin.H{}
+		for _, invoice := range invoices {
+			result := gin.H{"invoice_number": invoice.Invoice_number, "supplier": invoice.Supplier}
+			if _, err := saveSupplierInvoice(ctx, invoice, "CSV"); err != nil {
+				result["error"] = err.Error()
+			} else {
+				result["supplier_invoice_id"] = invoice.Supplier_invoice_id
+				result["match_status"] = invoice.Match_status
+			}
+			results = append(results, result)
+		}
+
+		c.JSON(http.StatusOK, gin.H{"imported": results})
+	}
+}
+
+// MatchSupplierInvoice re-runs the three-way match, e.g. after the PO was
+// corrected.
+func MatchSupplierInvoice() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		var invoice models.SupplierInvoice
+		err := supplierInvoiceCollection().FindOne(ctx, bson.M{"supplier_invoice_id": c.Param("supplier_invoice_id")}).Decode(&invoice)
+		if err != nil {
+			apierror.Render(c, apierror.NotFound("Supplier invoice not found"))
+			return
+		}
+		if invoice.Match_status == "RESOLVED" {
+			apierror.Render(c, apierror.Conflict("Supplier invoice has already been resolved"))
+			return
+		}
+
+		if err := applySupplierInvoiceMatch(ctx, &invoice); err != nil {
+			apierror.Render(c, apierror.Internal("error matching supplier invoice: "+err.Error()))
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"match_status": invoice.Match_status, "discrepancies": invoice.Discrepancies})
+	}
+}
+
+// ResolveSupplierInvoice closes out the follow-up on a flagged invoice, e.g.
+// once a credit note has been agreed with the supplier.
+func ResolveSupplierInvoice() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		var body struct {
+			Resolved_by     *string `json:"resolved_by" validate:"required"`
+			Resolution_note *string `json:"resolution_note" validate:"required,max=1000"`
+		}
+		if err := c.BindJSON(&body); err != nil {
+			apierror.Render(c, apierror.BadRequest("Invalid request data: "+err.Error()))
+			return
+		}
+		if err := validate.Struct(body); err != nil {
+			apierror.Render(c, apierror.FromValidation(err))
+			return
+		}
+
+		updatedAt, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		result, err := supplierInvoiceCollection().UpdateOne(
+			ctx,
+			bson.M{"supplier_invoice_id": c.Param("supplier_invoice_id"), "match_status": bson.M{"$in": bson.A{"DISCREPANCY", "NO_PURCHASE_ORDER"}}},
+			bson.D{{Key: "$set", Value: bson.D{
+				{Key: "match_status", Value: "RESOLVED"},
+				{Key: "resolved_by", Value: body.Resolved_by},
+				{Key: "resolution_note", Value: body.Resolution_note},
+				{Key: "updated_at", Value: updatedAt},
+			}}},
+		)
+		if err != nil {
+			apierror.Render(c, apierror.Internal("Update failed: "+err.Error()))
+			return
+		}
+		if result.MatchedCount == 0 {
+			apierror.Render(c, apierror.NotFound("No flagged supplier invoice found"))
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Supplier invoice resolved"})
+	}
+}
+
+// saveSupplierInvoice validates, matches and inserts an invoice, returning
+// the HTTP status to report if it fails.
+func saveSupplierInvoice(ctx context.Context, invoice *models.SupplierInvoice, source string) (int, error) {
+	if err := validate.Struct(invoice); err != nil {
+		return http.StatusBadRequest, fmt.Errorf("Validation failed: %s", err.Error())
+	}
+
+	count, err := supplierInvoiceCollection().CountDocuments(ctx, bson.M{"supplier": invoice.Supplier, "invoice_number": invoice.Invoice_number})
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	if count > 0 {
+		return http.StatusConflict, fmt.Errorf("invoice %s from %s has already been entered", *invoice.Invoice_number, *invoice.Supplier)
+	}
+
+	total := 0.0
+	for _, line := range invoice.Lines {
+		total += *line.Quantity * *line.Unit_price
+	}
+	invoice.Total = toFixed(total, 2)
+	invoice.Source = source
+	invoice.Resolved_by = nil
+	invoice.Resolution_note = nil
+	invoice.Created_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+	invoice.Updated_at = invoice.Created_at
+	invoice.ID = primitive.NewObjectID()
+	invoice.Supplier_invoice_id = invoice.ID.Hex()
+
+	if err := matchSupplierInvoice(ctx, invoice); err != nil {
+		return http.StatusInternalServerError, err
+	}
+	if _, err := supplierInvoiceCollection().InsertOne(ctx, invoice); err != nil {
+		return http.StatusInternalServerError, err
+	}
+	return http.StatusCreated, nil
+}
+
+// rematchSupplierInvoices refreshes the match on every open invoice billed
+// against a PO.
+func rematchSupplierInvoices(ctx context.Context, poNumber string) error {
+	cursor, err := supplierInvoiceCollection().Find(ctx, bson.M{"po_number": poNumber, "match_status": bson.M{"$ne": "RESOLVED"}})
+	if err != nil {
+		return err
+	}
+	var invoices []models.SupplierInvoice
+	if err = cursor.All(ctx, &invoices); err != nil {
+		return err
+	}
+	for i := range invoices {
+		if err := applySupplierInvoiceMatch(ctx, &invoices[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func applySupplierInvoiceMatch(ctx context.Context, invoice *models.SupplierInvoice) error {
+	if err := matchSupplierInvoice(ctx, invoice); err != nil {
+		return err
+	}
+	invoice.Updated_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+	_, err := supplierInvoiceCollection().UpdateOne(
+		ctx,
+		bson.M{"supplier_invoice_id": invoice.Supplier_invoice_id},
+		bson.D{{Key: "$set", Value: bson.D{
+			{Key: "match_status", Value: invoice.Match_status},
+			{Key: "discrepancies", Value: invoice.Discrepancies},
+			{Key: "matched_at", Value: invoice.Matched_at},
+			{Key: "updated_at", Value: invoice.Updated_at},
+		}}},
+	)
+	return err
+}
+
+// matchSupplierInvoice performs the three-way match: every invoiced line must
+// be on the PO at the PO price, and the quantity billed may not exceed what
+// was ordered or what was actually received.
+func matchSupplierInvoice(ctx context.Context, invoice *models.SupplierInvoice) error {
+	matchedAt, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+	invoice.Matched_at = &matchedAt
+	invoice.Discrepancies = []models.InvoiceDiscrepancy{}
+
+	var purchaseOrder models.PurchaseOrder
+	err := purchaseOrderCollection().FindOne(ctx, bson.M{"po_number": invoice.Po_number}).Decode(&purchaseOrder)
+	if err == mongo.ErrNoDocuments {
+		invoice.Match_status = "NO_PURCHASE_ORDER"
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	received, err := receivedQuantities(ctx, purchaseOrder.Purchase_order_id)
+	if err != nil {
+		return err
+	}
+
+	flag := func(sku, kind string, expected, actual float64, message string) {
+		invoice.Discrepancies = append(invoice.Discrepancies, models.InvoiceDiscrepancy{
+			Sku: sku, Type: kind, Expected: expected, Actual: actual, Message: message,
+		})
+	}
+
+	if *purchaseOrder.Supplier != *invoice.Supplier {
+		flag("", "SUPPLIER", 0, 0, fmt.Sprintf("PO %s was raised with %s", *purchaseOrder.Po_number, *purchaseOrder.Supplier))
+	}
+
+	ordered := map[string]models.PurchaseOrderLine{}
+	orderedQuantity := map[string]float64{}
+	for _, line := range purchaseOrder.Lines {
+		ordered[*line.Sku] = line
+		orderedQuantity[*line.Sku] += *line.Quantity
+	}
+
+	billed := map[string]float64{}
+	skus := []string{}
+	for _, line := range invoice.Lines {
+		sku := *line.Sku
+		poLine, ok := ordered[sku]
+		if !ok {
+			flag(sku, "NOT_ORDERED", 0, *line.Quantity, "billed item is not on the purchase order")
+			continue
+		}
+		if math.Abs(*line.Unit_price-*poLine.Unit_price) > invoicePriceTolerance {
+			flag(sku, "PRICE", *poLine.Unit_price, *line.Unit_price, "unit price differs from the purchase order")
+		}
+		if _, seen := billed[sku]; !seen {
+			skus = append(skus, sku)
+		}
+		billed[sku] += *line.Quantity
+	}
+
+	for _, sku := range skus {
+		if billed[sku] > orderedQuantity[sku]+invoiceQuantityTolerance {
+			flag(sku, "OVER_ORDERED", orderedQuantity[sku], billed[sku], "billed quantity exceeds the quantity ordered")
+		}
+		if billed[sku] > received[sku]+invoiceQuantityTolerance {
+			flag(sku, "QUANTITY", received[sku], billed[sku], "billed quantity exceeds the quantity received")
+		}
+	}
+
+	invoice.Match_status = "MATCHED"
+	if len(invoice.Discrepancies) > 0 {
+		invoice.Match_status = "DISCREPANCY"
+	}
+	return nil
+}
+
+func parseSupplierInvoiceCSV(r io.Reader) ([]*models.SupplierInvoice, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("error reading CSV header: %s", err.Error())
+	}
+	columns := map[string]int{}
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	for _, name := range supplierInvoiceColumns {
+		if _, ok := columns[name]; !ok {
+			return nil, fmt.Errorf("CSV is missing the %s column", name)
+		}
+	}
+
+	invoices := []*models.SupplierInvoice{}
+	byKey := map[string]*models.SupplierInvoice{}
+	for row := 2; ; row++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("row %d: %s", row, err.Error())
+		}
+		field := func(name string) string {
+			if i, ok := columns[name]; ok && i < len(record) {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		}
+
+		quantity, err := strconv.ParseFloat(field("quantity"), 64)
+		if err != nil {
+			return nil, fmt.Errorf("row %d: quantity must be a number", row)
+		}
+		unitPrice, err := strconv.ParseFloat(field("unit_price"), 64)
+		if err != nil {
+			return nil, fmt.Errorf("row %d: unit_price must be a number", row)
+		}
+
+		invoiceNumber, supplier, poNumber := field("invoice_number"), field("supplier"), field("po_number")
+		key := supplier + "\x00" + invoiceNumber
+		invoice, ok := byKey[key]
+		if !ok {
+			invoice = &models.SupplierInvoice{Invoice_number: &invoiceNumber, Supplier: &supplier, Po_number: &poNumber}
+			if value := field("invoice_date"); value != "" {
+				invoiceDate, err := time.Parse("2006-01-02", value)
+				if err != nil {
+					return nil, fmt.Errorf("row %d: invoice_date must be formatted as YYYY-MM-DD", row)
+				}
+				invoice.Invoice_date = &invoiceDate
+			}
+			byKey[key] = invoice
+			invoices = append(invoices, invoice)
+		}
+
+		sku, description := field("sku"), field("description")
+		invoice.Lines = append(invoice.Lines, models.SupplierInvoiceLine{
+			Sku: &sku, Description: &description, Quantity: &quantity, Unit_price: &unitPrice,
+		})
+	}
+	return invoices, nil
+}
@@ -0,0 +1,411 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"restaurant-management/apierror"
+	"restaurant-management/config"
+	"restaurant-management/models"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var surgeRuleCollection = db.Collection("surgeRule")
+
+// kitchenLoadWindow is how far back order items are counted when measuring
+// kitchen load for KITCHEN_LOAD surge rules.
+const kitchenLoadWindow = 15 * time.Minute
+
+const defaultChannel = "DINE_IN"
+
+func GetSurgeRules() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		result, err := surgeRuleCollection().Find(ctx, bson.M{})
+		if err != nil {
+			apierror.Render(c, apierror.Internal("error occurred while listing surge rules: "+err.Error()))
+			return
+		}
+
+		var allRules []bson.M
+		if err = result.All(ctx, &allRules); err != nil {
+			apierror.Render(c, apierror.Internal("error decoding surge rules: "+err.Error()))
+			return
+		}
+
+		load, err := kitchenLoad(ctx)
+		if err != nil {
+			apierror.Render(c, apierror.Internal("error measuring kitchen load: "+err.Error()))
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"kitchen_load": load, "surge_rules": allRules})
+	}
+}
+
+func CreateSurgeRule() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		var rule models.SurgeRule
+		if err := c.BindJSON(&rule); err != nil {
+			apierror.Render(c, apierror.BadRequest("Invalid request data: "+err.Error()))
+			return
+		}
+
+		if err := validate.Struct(rule); err != nil {
+			apierror.Render(c, apierror.FromValidation(err))
+			return
+		}
+
+		if err := validateSurgeRule(rule); err != nil {
+			apierror.Render(c, apierror.FromValidation(err))
+			return
+		}
+
+		if rule.Active == nil {
+			active := false
+			rule.Active = &active
+		}
+
+		now := time.Now().Format(time.RFC3339)
+		rule.Created_at, _ = time.Parse(time.RFC3339, now)
+		rule.Updated_at, _ = time.Parse(time.RFC3339, now)
+		rule.ID = primitive.NewObjectID()
+		rule.Surge_rule_id = rule.ID.Hex()
+
+		result, insertErr := surgeRuleCollection().InsertOne(ctx, rule)
+		if insertErr != nil {
+			apierror.Render(c, apierror.Internal("Could not create surge rule"))
+			return
+		}
+
+		c.JSON(http.StatusCreated, gin.H{"message": "Surge rule created", "data": result})
+	}
+}
+
+func UpdateSurgeRule() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		ruleId := c.Param("surge_rule_id")
+
+		var existing models.SurgeRule
+		if err := surgeRuleCollection().FindOne(ctx, bson.M{"surge_rule_id": ruleId}).Decode(&existing); err != nil {
+			apierror.Render(c, apierror.NotFound("Surge rule not found"))
+			return
+		}
+
+		var rule models.SurgeRule
+		if err := c.BindJSON(&rule); err != nil {
+			apierror.Render(c, apierror.BadRequest("Invalid request data: "+err.Error()))
+			return
+		}
+
+		var updateObj primitive.D
+		if rule.Name != nil {
+			existing.Name = rule.Name
+			updateObj = append(updateObj, bson.E{Key: "name", Value: rule.Name})
+		}
+		if rule.Channel != nil {
+			existing.Channel = rule.Channel
+			updateObj = append(updateObj, bson.E{Key: "channel", Value: rule.Channel})
+		}
+		if rule.Multiplier != nil {
+			existing.Multiplier = rule.Multiplier
+			updateObj = append(updateObj, bson.E{Key: "multiplier", Value: rule.Multiplier})
+		}
+		if rule.Demand_fee != nil {
+			existing.Demand_fee = rule.Demand_fee
+			updateObj = append(updateObj, bson.E{Key: "demand_fee", Value: rule.Demand_fee})
+		}
+		if rule.Trigger != nil {
+			existing.Trigger = rule.Trigger
+			updateObj = append(updateObj, bson.E{Key: "trigger", Value: rule.Trigger})
+		}
+		if rule.Load_threshold != nil {
+			existing.Load_threshold = rule.Load_threshold
+			updateObj = append(updateObj, bson.E{Key: "load_threshold", Value: rule.Load_threshold})
+		}
+		if rule.Starts_at != nil {
+			updateObj = append(updateObj, bson.E{Key: "starts_at", Value: rule.Starts_at})
+		}
+		if rule.Ends_at != nil {
+			updateObj = append(updateObj, bson.E{Key: "ends_at", Value: rule.Ends_at})
+		}
+
+		if err := validate.Struct(existing); err != nil {
+			apierror.Render(c, apierror.FromValidation(err))
+			return
+		}
+		if err := validateSurgeRule(existing); err != nil {
+			apierror.Render(c, apierror.FromValidation(err))
+			return
+		}
+
+		updatedAt, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		updateObj = append(updateObj, bson.E{Key: "updated_at", Value: updatedAt})
+
+		result, err := surgeRuleCollection().UpdateOne(ctx, bson.M{"surge_rule_id": ruleId}, bson.D{{Key: "$set", Value: updateObj}})
+		if err != nil {
+			apierror.Render(c, apierror.Internal("Update failed: "+err.Error()))
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Surge rule updated successfully", "result": result})
+	}
+}
+
+// ToggleSurgeRule switches a rule on or off, e.g. when a manager sees the
+// delivery queue backing up.
+func ToggleSurgeRule() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		ruleId := c.Param("surge_rule_id")
+
+		var body struct {
+			Active *bool `json:"active" validate:"required"`
+		}
+		if err := c.BindJSON(&body); err != nil {
+			apierror.Render(c, apierror.BadRequest("Invalid request data: "+err.Error()))
+			return
+		}
+		if err := validate.Struct(body); err != nil {
+			apierror.Render(c, apierror.FromValidation(err))
+			return
+		}
+
+		updatedAt, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		result, err := surgeRuleCollection().UpdateOne(
+			ctx,
+			bson.M{"surge_rule_id": ruleId},
+			bson.D{{Key: "$set", Value: bson.D{{Key: "active", Value: body.Active}, {Key: "updated_at", Value: updatedAt}}}},
+		)
+		if err != nil {
+			apierror.Render(c, apierror.Internal("Update failed: "+err.Error()))
+			return
+		}
+		if result.MatchedCount == 0 {
+			apierror.Render(c, apierror.NotFound("Surge rule not found"))
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Surge rule toggled", "active": body.Active})
+	}
+}
+
+// GetSurgeReport totals surge markups and demand fees per channel, kept
+// separate from menu revenue.
+func GetSurgeReport() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		from, to, err := parseReportRange(c)
+		if err != nil {
+			apierror.Render(c, apierror.BadRequest(err.Error()))
+			return
+		}
+		createdAt := bson.D{{Key: "$gte", Value: from}, {Key: "$lt", Value: to}}
+
+		feePipeline := mongo.Pipeline{
+			{{Key: "$match", Value: bson.D{{Key: "created_at", Value: createdAt}}}},
+			{{Key: "$unwind", Value: "$fees"}},
+			{{Key: "$match", Value: bson.D{{Key: "fees.type", Value: "DEMAND_FEE"}}}},
+			{{Key: "$group", Value: bson.D{
+				{Key: "_id", Value: "$channel"},
+				{Key: "orders", Value: bson.D{{Key: "$sum", Value: 1}}},
+				{Key: "demand_fees", Value: bson.D{{Key: "$sum", Value: "$fees.amount"}}},
+			}}},
+		}
+		feeCursor, err := orderCollection().Aggregate(ctx, feePipeline)
+		if err != nil {
+			apierror.Render(c, apierror.Internal("error occurred while aggregating demand fees: "+err.Error()))
+			return
+		}
+		var demandFees []bson.M
+		if err = feeCursor.All(ctx, &demandFees); err != nil {
+			apierror.Render(c, apierror.Internal("error decoding demand fees: "+err.Error()))
+			return
+		}
+
+		surgePipeline := mongo.Pipeline{
+			{{Key: "$match", Value: bson.D{{Key: "created_at", Value: createdAt}}}},
+			{{Key: "$unwind", Value: "$adjustments"}},
+			{{Key: "$match", Value: bson.D{{Key: "adjustments.type", Value: "SURGE"}}}},
+			{{Key: "$group", Value: bson.D{
+				{Key: "_id", Value: "$adjustments.source_id"},
+				{Key: "items", Value: bson.D{{Key: "$sum", Value: 1}}},
+				{Key: "surge_amount", Value: bson.D{{Key: "$sum", Value: "$adjustments.amount"}}},
+			}}},
+		}
+		surgeCursor, err := orderItemCollection().Aggregate(ctx, surgePipeline)
+		if err != nil {
+			apierror.Render(c, apierror.Internal("error occurred while aggregating surge adjustments: "+err.Error()))
+			return
+		}
+		var surgeAmounts []bson.M
+		if err = surgeCursor.All(ctx, &surgeAmounts); err != nil {
+			apierror.Render(c, apierror.Internal("error decoding surge adjustments: "+err.Error()))
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"from":          from,
+			"to":            to,
+			"demand_fees":   demandFees,
+			"surge_by_rule": surgeAmounts,
+		})
+	}
+}
+
+// parseReportRange reads ?from=&to= (YYYY-MM-DD, to inclusive) defaulting to
+// the last 7 days.
+func parseReportRange(c *gin.Context) (time.Time, time.Time, error) {
+	return parseReportRangeIn(c, time.UTC)
+}
+
+// parseReportRangeIn is parseReportRange with the days taken in zone.
+func parseReportRangeIn(c *gin.Context, zone *time.Location) (time.Time, time.Time, error) {
+	now := time.Now().In(zone)
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, zone)
+	from, to := today.AddDate(0, 0, -6), today
+	if value := c.Query("from"); value != "" {
+		parsed, err := time.ParseInLocation("2006-01-02", value, zone)
+		if err != nil {
+			return from, to, fmt.Errorf("from must be formatted as YYYY-MM-DD")
+		}
+		from = parsed
+	}
+	if value := c.Query("to"); value != "" {
+		parsed, err := time.ParseInLocation("2006-01-02", value, zone)
+		if err != nil {
+			return from, to, fmt.Errorf("to must be formatted as YYYY-MM-DD")
+		}
+		to = parsed
+	}
+	if to.Before(from) {
+		return from, to, fmt.Errorf("to must not be before from")
+	}
+	return from, to.AddDate(0, 0, 1), nil
+}
+
+func validateSurgeRule(rule models.SurgeRule) error {
+	if rule.Multiplier == nil && rule.Demand_fee == nil {
+		return fmt.Errorf("a surge rule needs a multiplier or a demand_fee")
+	}
+	if *rule.Trigger == "KITCHEN_LOAD" && rule.Load_threshold == nil {
+		return fmt.Errorf("load_threshold is required for KITCHEN_LOAD rules")
+	}
+	if rule.Starts_at != nil && rule.Ends_at != nil && !rule.Ends_at.After(*rule.Starts_at) {
+		return fmt.Errorf("ends_at must be after starts_at")
+	}
+	return nil
+}
+
+// kitchenLoad counts the order items fired within the load window.
+func kitchenLoad(ctx context.Context) (int64, error) {
+	return orderItemCollection().CountDocuments(ctx, bson.M{
+		"created_at": bson.M{"$gte": time.Now().Add(-kitchenLoadWindow)},
+	})
+}
+
+// activeSurgeRules returns the rules currently in force for a channel.
+func activeSurgeRules(ctx context.Context, channel string, at time.Time) ([]models.SurgeRule, error) {
+	result, err := surgeRuleCollection().Find(ctx, bson.M{"channel": channel, "active": true})
+	if err != nil {
+		return nil, err
+	}
+	var rules []models.SurgeRule
+	if err = result.All(ctx, &rules); err != nil {
+		return nil, err
+	}
+
+	var load int64 = -1
+	active := []models.SurgeRule{}
+	for _, rule := range rules {
+		if rule.Starts_at != nil && at.Before(*rule.Starts_at) {
+			continue
+		}
+		if rule.Ends_at != nil && !at.Before(*rule.Ends_at) {
+			continue
+		}
+		if rule.Trigger != nil && *rule.Trigger == "KITCHEN_LOAD" {
+			if load < 0 {
+				if load, err = kitchenLoad(ctx); err != nil {
+					return nil, err
+				}
+			}
+			if rule.Load_threshold == nil || load < int64(*rule.Load_threshold) {
+				continue
+			}
+		}
+		active = append(active, rule)
+	}
+	return active, nil
+}
+
+// surgeAdjustments marks up an order item by the highest active multiplier
+// for the channel.
+func surgeAdjustments(ctx context.Context, orderItem models.OrderItem, channel string, at time.Time) ([]models.PriceAdjustment, error) {
+	if orderItem.Unit_price == nil {
+		return nil, nil
+	}
+	rules, err := activeSurgeRules(ctx, channel, at)
+	if err != nil {
+		return nil, err
+	}
+
+	var best *models.SurgeRule
+	for i := range rules {
+		if rules[i].Multiplier == nil || *rules[i].Multiplier <= 1 {
+			continue
+		}
+		if best == nil || *rules[i].Multiplier > *best.Multiplier {
+			best = &rules[i]
+		}
+	}
+	if best == nil {
+		return nil, nil
+	}
+
+	return []models.PriceAdjustment{{
+		Type:        "SURGE",
+		Source_id:   best.Surge_rule_id,
+		Description: fmt.Sprintf("%s (x%g)", *best.Name, *best.Multiplier),
+		Amount:      toFixed(*orderItem.Unit_price*(*best.Multiplier-1), 2),
+	}}, nil
+}
+
+// demandFees returns the order-level fees charged on the channel right now.
+func demandFees(ctx context.Context, channel string, at time.Time) ([]models.PriceAdjustment, error) {
+	rules, err := activeSurgeRules(ctx, channel, at)
+	if err != nil {
+		return nil, err
+	}
+
+	fees := []models.PriceAdjustment{}
+	for _, rule := range rules {
+		if rule.Demand_fee == nil || *rule.Demand_fee == 0 {
+			continue
+		}
+		fees = append(fees, models.PriceAdjustment{
+			Type:        "DEMAND_FEE",
+			Source_id:   rule.Surge_rule_id,
+			Description: *rule.Name,
+			Amount:      toFixed(*rule.Demand_fee, 2),
+		})
+	}
+	return fees, nil
+}
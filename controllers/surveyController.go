@@ -0,0 +1,488 @@
+package controllers
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"net/http"
+	"restaurant-management/apierror"
+	"restaurant-management/config"
+	"restaurant-management/models"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var surveyCollection = db.Collection("survey")
+var surveyInvitationCollection = db.Collection("surveyInvitation")
+var surveyResponseCollection = db.Collection("surveyResponse")
+var surveyAlertCollection = db.Collection("surveyAlert")
+
+// NPS scores at or below detractorThreshold raise an alert for managers.
+const detractorThreshold = 6
+
+const defaultLocation = "default"
+
+func GetSurveys() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		result, err := surveyCollection().Find(ctx, bson.M{})
+		if err != nil {
+			apierror.Render(c, apierror.Internal("error occurred while listing surveys: "+err.Error()))
+			return
+		}
+
+		var allSurveys []bson.M
+		if err = result.All(ctx, &allSurveys); err != nil {
+			apierror.Render(c, apierror.Internal("error decoding surveys: "+err.Error()))
+			return
+		}
+
+		c.JSON(http.StatusOK, allSurveys)
+	}
+}
+
+func GetSurvey() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		var survey models.Survey
+		err := surveyCollection().FindOne(ctx, bson.M{"survey_id": c.Param("survey_id")}).Decode(&survey)
+		if err != nil {
+			apierror.Render(c, apierror.NotFound("Survey not found"))
+			return
+		}
+
+		c.JSON(http.StatusOK, survey)
+	}
+}
+
+func CreateSurvey() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		var survey models.Survey
+		if err := c.BindJSON(&survey); err != nil {
+			apierror.Render(c, apierror.BadRequest("Invalid request data: "+err.Error()))
+			return
+		}
+
+		if err := validate.Struct(survey); err != nil {
+			apierror.Render(c, apierror.FromValidation(err))
+			return
+		}
+
+		if survey.Active == nil {
+			active := true
+			survey.Active = &active
+		}
+
+		now := time.Now().Format(time.RFC3339)
+		survey.Created_at, _ = time.Parse(time.RFC3339, now)
+		survey.Updated_at, _ = time.Parse(time.RFC3339, now)
+		survey.ID = primitive.NewObjectID()
+		survey.Survey_id = survey.ID.Hex()
+
+		result, insertErr := surveyCollection().InsertOne(ctx, survey)
+		if insertErr != nil {
+			apierror.Render(c, apierror.Internal("Could not create survey"))
+			return
+		}
+
+		c.JSON(http.StatusCreated, gin.H{"message": "Survey created", "data": result})
+	}
+}
+
+func UpdateSurvey() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		var survey models.Survey
+		if err := c.BindJSON(&survey); err != nil {
+			apierror.Render(c, apierror.BadRequest("Invalid request data: "+err.Error()))
+			return
+		}
+
+		var updateObj primitive.D
+		if survey.Name != nil {
+			updateObj = append(updateObj, bson.E{Key: "name", Value: survey.Name})
+		}
+		if survey.Location != nil {
+			updateObj = append(updateObj, bson.E{Key: "location", Value: survey.Location})
+		}
+		if survey.Questions != nil {
+			if err := validate.Var(survey.Questions, "min=1,dive"); err != nil {
+				apierror.Render(c, apierror.FromValidation(err))
+				return
+			}
+			updateObj = append(updateObj, bson.E{Key: "questions", Value: survey.Questions})
+		}
+		if survey.Active != nil {
+			updateObj = append(updateObj, bson.E{Key: "active", Value: survey.Active})
+		}
+
+		updatedAt, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		updateObj = append(updateObj, bson.E{Key: "updated_at", Value: updatedAt})
+
+		result, err := surveyCollection().UpdateOne(ctx, bson.M{"survey_id": c.Param("survey_id")}, bson.D{{Key: "$set", Value: updateObj}})
+		if err != nil {
+			apierror.Render(c, apierror.Internal("Update failed: "+err.Error()))
+			return
+		}
+		if result.MatchedCount == 0 {
+			apierror.Render(c, apierror.NotFound("Survey not found"))
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Survey updated successfully", "result": result})
+	}
+}
+
+// GetSurveyInvitation returns the questions for a guest's survey link.
+func GetSurveyInvitation() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		var invitation models.SurveyInvitation
+		if err := surveyInvitationCollection().FindOne(ctx, bson.M{"token": c.Param("token")}).Decode(&invitation); err != nil {
+			apierror.Render(c, apierror.NotFound("Survey invitation not found"))
+			return
+		}
+
+		var survey models.Survey
+		if err := surveyCollection().FindOne(ctx, bson.M{"survey_id": invitation.Survey_id}).Decode(&survey); err != nil {
+			apierror.Render(c, apierror.NotFound("Survey not found"))
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"name":      survey.Name,
+			"questions": survey.Questions,
+			"status":    invitation.Status,
+		})
+	}
+}
+
+// SubmitSurveyResponse records a guest's answers for an invitation token.
+func SubmitSurveyResponse() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		var invitation models.SurveyInvitation
+		if err := surveyInvitationCollection().FindOne(ctx, bson.M{"token": c.Param("token")}).Decode(&invitation); err != nil {
+			apierror.Render(c, apierror.NotFound("Survey invitation not found"))
+			return
+		}
+		if invitation.Status == "RESPONDED" {
+			apierror.Render(c, apierror.Conflict("Survey was already answered"))
+			return
+		}
+
+		var survey models.Survey
+		if err := surveyCollection().FindOne(ctx, bson.M{"survey_id": invitation.Survey_id}).Decode(&survey); err != nil {
+			apierror.Render(c, apierror.NotFound("Survey not found"))
+			return
+		}
+
+		var body struct {
+			Answers map[string]interface{} `json:"answers" validate:"required"`
+		}
+		if err := c.BindJSON(&body); err != nil {
+			apierror.Render(c, apierror.BadRequest("Invalid request data: "+err.Error()))
+			return
+		}
+		if err := validate.Struct(body); err != nil {
+			apierror.Render(c, apierror.FromValidation(err))
+			return
+		}
+
+		response := models.SurveyResponse{
+			Survey_id:     survey.Survey_id,
+			Invitation_id: invitation.Invitation_id,
+			Order_id:      invitation.Order_id,
+			Location:      invitation.Location,
+			Answers:       map[string]interface{}{},
+		}
+		comment := ""
+		for _, question := range survey.Questions {
+			answer, ok := body.Answers[question.Key]
+			if !ok {
+				continue
+			}
+			switch question.Type {
+			case "NPS", "RATING":
+				score, ok := answer.(float64)
+				max := 10.0
+				if question.Type == "RATING" {
+					max = 5
+				}
+				if !ok || score != float64(int(score)) || score < 0 || score > max {
+					apierror.Render(c, apierror.BadRequest(fmt.Sprintf("%s must be a whole number between 0 and %g", question.Key, max)))
+					return
+				}
+				if question.Type == "NPS" && response.Nps_score == nil {
+					nps := int(score)
+					response.Nps_score = &nps
+				}
+				response.Answers[question.Key] = int(score)
+			case "TEXT":
+				text, ok := answer.(string)
+				if !ok {
+					apierror.Render(c, apierror.BadRequest(question.Key+" must be text"))
+					return
+				}
+				if comment == "" {
+					comment = text
+				}
+				response.Answers[question.Key] = text
+			}
+		}
+
+		now, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		response.Created_at = now
+		response.ID = primitive.NewObjectID()
+		response.Response_id = response.ID.Hex()
+
+		// Claim the invitation first so concurrent submissions cannot both succeed
+		claim, err := surveyInvitationCollection().UpdateOne(
+			ctx,
+			bson.M{"invitation_id": invitation.Invitation_id, "status": "SENT"},
+			bson.D{{Key: "$set", Value: bson.D{{Key: "status", Value: "RESPONDED"}, {Key: "responded_at", Value: now}}}},
+		)
+		if err != nil {
+			apierror.Render(c, apierror.Internal("Could not record survey response"))
+			return
+		}
+		if claim.ModifiedCount == 0 {
+			apierror.Render(c, apierror.Conflict("Survey was already answered"))
+			return
+		}
+
+		if _, err := surveyResponseCollection().InsertOne(ctx, response); err != nil {
+			apierror.Render(c, apierror.Internal("Could not record survey response"))
+			return
+		}
+
+		if response.Nps_score != nil && *response.Nps_score <= detractorThreshold {
+			raiseDetractorAlert(ctx, response, comment)
+		}
+
+		c.JSON(http.StatusCreated, gin.H{"message": "Thank you for your feedback"})
+	}
+}
+
+// GetNpsReport computes NPS per location and period from survey responses.
+func GetNpsReport() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		from, to, err := parseReportRange(c)
+		if err != nil {
+			apierror.Render(c, apierror.BadRequest(err.Error()))
+			return
+		}
+
+		formats := map[string]string{"day": "%Y-%m-%d", "week": "%G-W%V", "month": "%Y-%m"}
+		granularity := c.DefaultQuery("granularity", "week")
+		format, ok := formats[granularity]
+		if !ok {
+			apierror.Render(c, apierror.BadRequest("granularity must be day, week or month"))
+			return
+		}
+
+		match := bson.D{
+			{Key: "created_at", Value: bson.D{{Key: "$gte", Value: from}, {Key: "$lt", Value: to}}},
+			{Key: "nps_score", Value: bson.D{{Key: "$ne", Value: nil}}},
+		}
+		if location := c.Query("location"); location != "" {
+			match = append(match, bson.E{Key: "location", Value: location})
+		}
+
+		countIf := func(cond bson.D) bson.D {
+			return bson.D{{Key: "$sum", Value: bson.D{{Key: "$cond", Value: bson.A{cond, 1, 0}}}}}
+		}
+		pipeline := mongo.Pipeline{
+			{{Key: "$match", Value: match}},
+			{{Key: "$group", Value: bson.D{
+				{Key: "_id", Value: bson.D{
+					{Key: "location", Value: "$location"},
+					{Key: "period", Value: bson.D{{Key: "$dateToString", Value: bson.D{{Key: "format", Value: format}, {Key: "date", Value: "$created_at"}}}}},
+				}},
+				{Key: "responses", Value: bson.D{{Key: "$sum", Value: 1}}},
+				{Key: "promoters", Value: countIf(bson.D{{Key: "$gte", Value: bson.A{"$nps_score", 9}}})},
+				{Key: "detractors", Value: countIf(bson.D{{Key: "$lte", Value: bson.A{"$nps_score", detractorThreshold}}})},
+			}}},
+			{{Key: "$sort", Value: bson.D{{Key: "_id.location", Value: 1}, {Key: "_id.period", Value: 1}}}},
+		}
+
+		cursor, err := surveyResponseCollection().Aggregate(ctx, pipeline)
+		if err != nil {
+			apierror.Render(c, apierror.Internal("error occurred while computing NPS: "+err.Error()))
+			return
+		}
+		var rows []struct {
+			ID struct {
+				Location string `bson:"location"`
+				Period   string `bson:"period"`
+			} `bson:"_id"`
+			Responses  int `bson:"responses"`
+			Promoters  int `bson:"promoters"`
+			Detractors int `bson:"detractors"`
+		}
+		if err = cursor.All(ctx, &rows); err != nil {
+			apierror.Render(c, apierror.Internal("error decoding NPS: "+err.Error()))
+			return
+		}
+
+		report := []gin.H{}
+		for _, row := range rows {
+			report = append(report, gin.H{
+				"location":   row.ID.Location,
+				"period":     row.ID.Period,
+				"responses":  row.Responses,
+				"promoters":  row.Promoters,
+				"passives":   row.Responses - row.Promoters - row.Detractors,
+				"detractors": row.Detractors,
+				"nps":        toFixed(float64(row.Promoters-row.Detractors)/float64(row.Responses)*100, 1),
+			})
+		}
+
+		c.JSON(http.StatusOK, gin.H{"granularity": granularity, "from": from, "to": to, "report": report})
+	}
+}
+
+func GetSurveyAlerts() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		filter := bson.M{}
+		if c.Query("resolved") != "true" {
+			filter["resolved"] = false
+		}
+
+		result, err := surveyAlertCollection().Find(ctx, filter)
+		if err != nil {
+			apierror.Render(c, apierror.Internal("error occurred while listing survey alerts: "+err.Error()))
+			return
+		}
+
+		var allAlerts []bson.M
+		if err = result.All(ctx, &allAlerts); err != nil {
+			apierror.Render(c, apierror.Internal("error decoding survey alerts: "+err.Error()))
+			return
+		}
+
+		c.JSON(http.StatusOK, allAlerts)
+	}
+}
+
+func ResolveSurveyAlert() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		result, err := surveyAlertCollection().UpdateOne(ctx, bson.M{"alert_id": c.Param("alert_id")}, bson.D{{Key: "$set", Value: bson.D{{Key: "resolved", Value: true}}}})
+		if err != nil {
+			apierror.Render(c, apierror.Internal("Update failed: "+err.Error()))
+			return
+		}
+		if result.MatchedCount == 0 {
+			apierror.Render(c, apierror.NotFound("Survey alert not found"))
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Survey alert resolved"})
+	}
+}
+
+// sendSurveyInvitations creates an invitation for every active survey once an
+// order is completed. Surveys without a location apply to the default one.
+func sendSurveyInvitations(ctx context.Context, orderId string) error {
+	var order models.Order
+	if err := orderCollection().FindOne(ctx, bson.M{"order_id": orderId}).Decode(&order); err != nil {
+		return err
+	}
+
+	existing, err := surveyInvitationCollection().CountDocuments(ctx, bson.M{"order_id": orderId})
+	if err != nil || existing > 0 {
+		return err
+	}
+
+	result, err := surveyCollection().Find(ctx, bson.M{"active": true})
+	if err != nil {
+		return err
+	}
+	var surveys []models.Survey
+	if err = result.All(ctx, &surveys); err != nil {
+		return err
+	}
+
+	for _, survey := range surveys {
+		token, err := newToken()
+		if err != nil {
+			return err
+		}
+		invitation := models.SurveyInvitation{
+			ID:          primitive.NewObjectID(),
+			Survey_id:   survey.Survey_id,
+			Order_id:    orderId,
+			Customer_id: order.Customer_id,
+			Location:    defaultLocation,
+			Token:       token,
+			Status:      "SENT",
+		}
+		if survey.Location != nil && *survey.Location != "" {
+			invitation.Location = *survey.Location
+		}
+		invitation.Created_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		invitation.Invitation_id = invitation.ID.Hex()
+
+		if _, err := surveyInvitationCollection().InsertOne(ctx, invitation); err != nil {
+			return err
+		}
+		log.Printf("survey %s sent for order %s: /surveys/respond/%s", survey.Survey_id, orderId, token)
+	}
+	return nil
+}
+
+func raiseDetractorAlert(ctx context.Context, response models.SurveyResponse, comment string) {
+	alert := models.SurveyAlert{
+		ID:          primitive.NewObjectID(),
+		Response_id: response.Response_id,
+		Survey_id:   response.Survey_id,
+		Order_id:    response.Order_id,
+		Location:    response.Location,
+		Nps_score:   *response.Nps_score,
+		Comment:     comment,
+		Created_at:  response.Created_at,
+	}
+	alert.Alert_id = alert.ID.Hex()
+
+	if _, err := surveyAlertCollection().InsertOne(ctx, alert); err != nil {
+		log.Println("Error saving detractor alert:", err)
+		return
+	}
+	log.Printf("detractor alert: order %s scored %d at %s", response.Order_id, *response.Nps_score, response.Location)
+}
+
+func newToken() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
@@ -0,0 +1,315 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"restaurant-management/apierror"
+	"restaurant-management/config"
+	"restaurant-management/models"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var terminalSyncCollection = db.Collection("terminalSync")
+
+// SyncTerminalOrders accepts a batch of orders queued by a POS terminal while
+// offline. Orders and items are deduplicated by their client-generated UUIDs,
+// so a terminal can safely resend a batch after a dropped response.
+func SyncTerminalOrders() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		terminalId := c.Param("terminal_id")
+
+		var batch models.SyncBatch
+		if err := c.BindJSON(&batch); err != nil {
+			apierror.Render(c, apierror.BadRequest("Invalid request data: "+err.Error()))
+			return
+		}
+		if err := validate.Struct(batch); err != nil {
+			apierror.Render(c, apierror.FromValidation(err))
+			return
+		}
+
+		var checkpoint models.TerminalSync
+		err := terminalSyncCollection().FindOne(ctx, bson.M{"terminal_id": terminalId}).Decode(&checkpoint)
+		if err != nil && err != mongo.ErrNoDocuments {
+			apierror.Render(c, apierror.Internal("error loading sync checkpoint: "+err.Error()))
+			return
+		}
+		if err == nil && checkpoint.Last_batch_id == batch.Batch_id {
+			c.JSON(http.StatusOK, gin.H{"batch_id": batch.Batch_id, "replayed": true, "results": checkpoint.Last_results, "synced_at": checkpoint.Last_synced_at})
+			return
+		}
+
+		results := []models.SyncResult{}
+		synced := 0
+		for _, offlineOrder := range batch.Orders {
+			result := syncOfflineOrder(ctx, terminalId, offlineOrder, staffId(c))
+			if result.Status != "REJECTED" {
+				synced++
+			}
+			results = append(results, result)
+		}
+
+		now, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		upsert := true
+		opt := options.UpdateOptions{Upsert: &upsert}
+		_, err = terminalSyncCollection().UpdateOne(
+			ctx,
+			bson.M{"terminal_id": terminalId},
+			bson.D{
+				{Key: "$set", Value: bson.D{
+					{Key: "last_batch_id", Value: batch.Batch_id},
+					{Key: "last_results", Value: results},
+					{Key: "last_synced_at", Value: now},
+					{Key: "updated_at", Value: now},
+				}},
+				{Key: "$inc", Value: bson.D{{Key: "orders_synced", Value: synced}}},
+				{Key: "$setOnInsert", Value: bson.D{{Key: "_id", Value: primitive.NewObjectID()}, {Key: "created_at", Value: now}}},
+			},
+			&opt,
+		)
+		if err != nil {
+			apierror.Render(c, apierror.Internal("error saving sync checkpoint: "+err.Error()))
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"batch_id": batch.Batch_id, "replayed": false, "results": results, "synced_at": now})
+	}
+}
+
+// GetTerminalCheckpoint returns the terminal's last acknowledged batch and
+// the orders changed on the server since ?since= so the terminal can refresh
+// its local state after reconnecting.
+func GetTerminalCheckpoint() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		terminalId := c.Param("terminal_id")
+
+		var checkpoint models.TerminalSync
+		err := terminalSyncCollection().FindOne(ctx, bson.M{"terminal_id": terminalId}).Decode(&checkpoint)
+		if err != nil && err != mongo.ErrNoDocuments {
+			apierror.Render(c, apierror.Internal("error loading sync checkpoint: "+err.Error()))
+			return
+		}
+
+		response := gin.H{"terminal_id": terminalId, "server_time": time.Now().UTC()}
+		if err == nil {
+			response["last_batch_id"] = checkpoint.Last_batch_id
+			response["last_synced_at"] = checkpoint.Last_synced_at
+			response["orders_synced"] = checkpoint.Orders_synced
+		}
+
+		if since := c.Query("since"); since != "" {
+			sinceTime, err := time.Parse(time.RFC3339, since)
+			if err != nil {
+				apierror.Render(c, apierror.BadRequest("since must be an RFC3339 timestamp"))
+				return
+			}
+			opts := options.Find().SetSort(bson.D{{Key: "updated_at", Value: 1}}).SetLimit(500)
+			cursor, err := orderCollection().Find(ctx, bson.M{"updated_at": bson.M{"$gt": sinceTime}}, opts)
+			if err != nil {
+				apierror.Render(c, apierror.Internal("error occurred while listing changed orders: "+err.Error()))
+				return
+			}
+			var changed []bson.M
+			if err = cursor.All(ctx, &changed); err != nil {
+				apierror.Render(c, apierror.Internal("error decoding changed orders: "+err.Error()))
+				return
+			}
+			response["changed_orders"] = changed
+		}
+
+		c.JSON(http.StatusOK, response)
+	}
+}
+
+// syncOfflineOrder creates the order on first sight, otherwise merges in any
+// items the server has not seen. A table change made offline loses to a
+// server-side edit made after the terminal's last sync and is reported as
+// a conflict.
+func syncOfflineOrder(ctx context.Context, terminalId string, offlineOrder models.OfflineOrder, openedBy *string) models.SyncResult {
+	result := models.SyncResult{Client_uuid: *offlineOrder.Client_uuid}
+	reject := func(err string) models.SyncResult {
+		result.Status = "REJECTED"
+		result.Error = err
+		return result
+	}
+
+	for i, orderItem := range offlineOrder.Order_items {
+		if orderItem.Client_uuid == nil {
+			return reject(fmt.Sprintf("order_items[%d]: client_uuid is required for offline items", i))
+		}
+	}
+
+	var table models.Table
+	if err := tableCollection().FindOne(ctx, bson.M{"table_id": offlineOrder.Table_id}).Decode(&table); err != nil {
+		return reject("Table not found")
+	}
+
+	var order models.Order
+	err := orderCollection().FindOne(ctx, bson.M{"client_uuid": offlineOrder.Client_uuid}).Decode(&order)
+	if err != nil && err != mongo.ErrNoDocuments {
+		return reject(err.Error())
+	}
+
+	now, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+	if err == mongo.ErrNoDocuments {
+		channel := defaultChannel
+		if offlineOrder.Channel != nil {
+			channel = *offlineOrder.Channel
+		}
+		order = models.Order{
+			ID:          primitive.NewObjectID(),
+			Order_Date:  *offlineOrder.Order_date,
+			Created_at:  now,
+			Updated_at:  now,
+			Table_id:    offlineOrder.Table_id,
+			Channel:     &channel,
+			Customer_id: offlineOrder.Customer_id,
+			Client_uuid: offlineOrder.Client_uuid,
+			Terminal_id: &terminalId,
+			Opened_by:   openedBy,
+		}
+		order.Order_id = order.ID.Hex()
+		if _, err := orderCollection().InsertOne(ctx, order); err != nil {
+			// A concurrent sync of the same order won the race; merge into it instead
+			if mongo.IsDuplicateKeyError(err) {
+				return syncOfflineOrder(ctx, terminalId, offlineOrder, openedBy)
+			}
+			return reject(err.Error())
+		}
+		result.Status = "CREATED"
+	} else {
+		result.Status = "DUPLICATE"
+		if *order.Table_id != *offlineOrder.Table_id {
+			serverChanged := offlineOrder.Base_synced_at == nil || order.Updated_at.After(*offlineOrder.Base_synced_at)
+			if serverChanged {
+				result.Conflicts = append(result.Conflicts, fmt.Sprintf("table_id was changed on the server to %s; keeping the server value", *order.Table_id))
+			} else {
+				_, err := orderCollection().UpdateOne(ctx, bson.M{"order_id": order.Order_id}, bson.D{{Key: "$set", Value: bson.D{
+					{Key: "table_id", Value: offlineOrder.Table_id},
+					{Key: "updated_at", Value: now},
+				}}})
+				if err != nil {
+					return reject(err.Error())
+				}
+				result.Status = "MERGED"
+			}
+		}
+	}
+	result.Order_id = order.Order_id
+
+	added, conflicts, err := mergeOfflineItems(ctx, order, offlineOrder.Order_items)
+	if err != nil {
+		return reject(err.Error())
+	}
+	result.Items_added = added
+	result.Conflicts = append(result.Conflicts, conflicts...)
+	if added > 0 && result.Status == "DUPLICATE" {
+		result.Status = "MERGED"
+	}
+	if len(result.Conflicts) > 0 {
+		result.Status = "CONFLICT"
+	}
+	return result
+}
+
+// mergeOfflineItems inserts the offline items whose client UUIDs are not yet
+// stored for the order. Items that fail validation are reported, not fatal.
+func mergeOfflineItems(ctx context.Context, order models.Order, offlineItems []models.OrderItem) (int, []string, error) {
+	cursor, err := orderItemCollection().Find(ctx, bson.M{"order_id": order.Order_id}, options.Find().SetProjection(bson.M{"client_uuid": 1}))
+	if err != nil {
+		return 0, nil, err
+	}
+	var existing []models.OrderItem
+	if err = cursor.All(ctx, &existing); err != nil {
+		return 0, nil, err
+	}
+	seen := map[string]bool{}
+	for _, orderItem := range existing {
+		if orderItem.Client_uuid != nil {
+			seen[*orderItem.Client_uuid] = true
+		}
+	}
+
+	foodIds := []string{}
+	for _, orderItem := range offlineItems {
+		foodIds = append(foodIds, stringValue(orderItem.Food_id))
+	}
+	foods, err := foodsById(ctx, foodIds)
+	if err != nil {
+		return 0, nil, err
+	}
+	modifierGroups, err := modifierGroupsOf(ctx, foods)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	conflicts := []string{}
+	toInsert := []interface{}{}
+	fired := []models.OrderItem{}
+	for _, orderItem := range offlineItems {
+		if seen[*orderItem.Client_uuid] {
+			continue
+		}
+		seen[*orderItem.Client_uuid] = true
+
+		orderItem.Order_id = order.Order_id
+		if err := validate.Struct(orderItem); err != nil {
+			conflicts = append(conflicts, fmt.Sprintf("item %s rejected: %s", *orderItem.Client_uuid, err.Error()))
+			continue
+		}
+
+		orderItem.ID = primitive.NewObjectID()
+		orderItem.Order_item_id = orderItem.ID.Hex()
+		// Keep the time the item was actually sold so reports and forecasts line up
+		orderItem.Created_at = order.Order_Date
+		orderItem.Updated_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		price := toFixed(*orderItem.Unit_price, 2)
+		orderItem.Unit_price = &price
+
+		substitutions, err := substitutionAdjustments(foods[*orderItem.Food_id], orderItem)
+		if err != nil {
+			conflicts = append(conflicts, fmt.Sprintf("item %s rejected: %s", *orderItem.Client_uuid, err.Error()))
+			continue
+		}
+		modifiers, err := modifierAdjustments(modifierGroups, foods[*orderItem.Food_id], orderItem)
+		if err != nil {
+			conflicts = append(conflicts, fmt.Sprintf("item %s rejected: %s", *orderItem.Client_uuid, err.Error()))
+			continue
+		}
+		adjustments, err := priceScheduleAdjustments(ctx, orderItem, order.Order_Date)
+		if err != nil {
+			return 0, nil, err
+		}
+		orderItem.Adjustments = append(append(modifiers, substitutions...), adjustments...)
+		toInsert = append(toInsert, orderItem)
+		fired = append(fired, orderItem)
+	}
+
+	if len(toInsert) == 0 {
+		return 0, conflicts, nil
+	}
+	if _, err := orderItemCollection().InsertMany(ctx, toInsert); err != nil {
+		return 0, nil, err
+	}
+	if err := fireKitchenTickets(ctx, order, fired); err != nil {
+		log.Println("Error firing kitchen tickets:", err)
+	}
+	if err := depleteInventory(ctx, order, fired); err != nil {
+		log.Println("Error depleting inventory:", err)
+	}
+	return len(toInsert), conflicts, nil
+}
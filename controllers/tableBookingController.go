@@ -0,0 +1,174 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"restaurant-management/apierror"
+	"restaurant-management/config"
+	"restaurant-management/database"
+	"restaurant-management/models"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// errTableBooked is returned when a table is already reserved for part of
+// the requested time.
+var errTableBooked = errors.New("table is already booked for this time")
+
+// GetTableAvailability lists the bookings holding a table on ?date=
+// (YYYY-MM-DD, default today). Given ?reserved_at= (RFC 3339) and
+// ?duration_minutes=, it also tells whether the table is free then.
+func GetTableAvailability() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		var table models.Table
+		if err := tableCollection().FindOne(ctx, bson.M{"table_id": c.Param("table_id")}).Decode(&table); err != nil {
+			apierror.Render(c, apierror.NotFound("Table not found"))
+			return
+		}
+
+		day := time.Now()
+		if date := c.Query("date"); date != "" {
+			parsed, err := time.ParseInLocation("2006-01-02", date, time.Local)
+			if err != nil {
+				apierror.Render(c, apierror.BadRequest("date must be formatted as YYYY-MM-DD"))
+				return
+			}
+			day = parsed
+		}
+		from := time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, time.Local)
+		booked, err := tableConflicts(ctx, table.Table_id, from, from.AddDate(0, 0, 1), "")
+		if err != nil {
+			apierror.Render(c, apierror.Internal("error occurred while listing reservations: "+err.Error()))
+			return
+		}
+
+		windows := []gin.H{}
+		for _, reservation := range booked {
+			windows = append(windows, gin.H{
+				"reservation_id": reservation.Reservation_id,
+				"status":         reservation.Status,
+				"party_size":     reservation.Party_size,
+				"start":          reservation.Reserved_at,
+				"end":            reservationEnd(reservation),
+			})
+		}
+		response := gin.H{"table_id": table.Table_id, "number_of_guests": table.Number_of_guests, "date": from.Format("2006-01-02"), "booked": windows}
+
+		if reservedAt := c.Query("reserved_at"); reservedAt != "" {
+			start, err := time.Parse(time.RFC3339, reservedAt)
+			if err != nil {
+				apierror.Render(c, apierror.BadRequest("reserved_at must be an RFC 3339 time"))
+				return
+			}
+			duration := defaultReservationMinutes
+			if minutes, err := strconv.Atoi(c.Query("duration_minutes")); err == nil && minutes > 0 {
+				duration = minutes
+			}
+			conflicts, err := tableConflicts(ctx, table.Table_id, start, start.Add(time.Duration(duration)*time.Minute), "")
+			if err != nil {
+				apierror.Render(c, apierror.Internal("error occurred while checking availability: "+err.Error()))
+				return
+			}
+			response["available"] = len(conflicts) == 0
+		}
+
+		c.JSON(http.StatusOK, response)
+	}
+}
+
+// tableConflicts returns the bookings holding a table at any time in
+// [start, end), ignoring the reservation excludeId so a booking can be
+// moved.
+func tableConflicts(ctx context.Context, tableId string, start, end time.Time, excludeId string) ([]models.Reservation, error) {
+	filter := bson.M{
+		"table_id": tableId,
+		"status":   bson.M{"$in": occupyingReservationStatuses},
+		// Bookings last at most 12 hours, so earlier ones cannot overlap
+		"reserved_at": bson.M{"$gte": start.Add(-12 * time.Hour), "$lt": end},
+	}
+	if excludeId != "" {
+		filter["reservation_id"] = bson.M{"$ne": excludeId}
+	}
+	cursor, err := reservationCollection().Find(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	var reservations []models.Reservation
+	if err = cursor.All(ctx, &reservations); err != nil {
+		return nil, err
+	}
+
+	conflicts := []models.Reservation{}
+	for _, reservation := range reservations {
+		if reservation.Reserved_at.Before(end) && reservationEnd(reservation).After(start) {
+			conflicts = append(conflicts, reservation)
+		}
+	}
+	return conflicts, nil
+}
+
+// bookTable runs write, which stores a reservation or its changes, unless
+// the reservation's table is booked for an overlapping time. Bumping the
+// table's booking version in the same transaction makes concurrent
+// bookings of one table conflict, so the retried one sees the other and
+// fails with errTableBooked. Reservations without a table are written
+// directly.
+func bookTable(ctx context.Context, reservation models.Reservation, write func(ctx context.Context) error) error {
+	if reservation.Table_id == nil || *reservation.Table_id == "" {
+		return write(ctx)
+	}
+
+	return database.WithTransaction(ctx, db.Client(), func(txCtx context.Context) error {
+		result, err := tableCollection().UpdateOne(txCtx, bson.M{"table_id": *reservation.Table_id}, bson.D{{Key: "$inc", Value: bson.D{{Key: "booking_version", Value: 1}}}})
+		if err != nil {
+			return err
+		}
+		if result.MatchedCount == 0 {
+			return mongo.ErrNoDocuments
+		}
+		conflicts, err := tableConflicts(txCtx, *reservation.Table_id, *reservation.Reserved_at, reservationEnd(reservation), reservation.Reservation_id)
+		if err != nil {
+			return err
+		}
+		if len(conflicts) > 0 {
+			return errTableBooked
+		}
+		return write(txCtx)
+	})
+}
+
+// checkReservationTable checks that a reservation's table exists, seats the
+// party and is free for the booking, and writes the response if not. It
+// reports whether the handler may go on.
+func checkReservationTable(ctx context.Context, c *gin.Context, reservation models.Reservation) bool {
+	if reservation.Table_id == nil || *reservation.Table_id == "" {
+		return true
+	}
+	var table models.Table
+	if err := tableCollection().FindOne(ctx, bson.M{"table_id": *reservation.Table_id}).Decode(&table); err != nil {
+		apierror.Render(c, apierror.BadRequest("Table not found"))
+		return false
+	}
+	if table.Number_of_guests != nil && *reservation.Party_size > *table.Number_of_guests {
+		apierror.Render(c, apierror.Conflict("The party is larger than the table seats").With("number_of_guests", table.Number_of_guests))
+		return false
+	}
+	conflicts, err := tableConflicts(ctx, table.Table_id, *reservation.Reserved_at, reservationEnd(reservation), reservation.Reservation_id)
+	if err != nil {
+		apierror.Render(c, apierror.Internal("error occurred while checking the table: "+err.Error()))
+		return false
+	}
+	if len(conflicts) > 0 {
+		apierror.Render(c, apierror.Conflict(errTableBooked.Error()).With("conflicts", conflicts))
+		return false
+	}
+	return true
+}
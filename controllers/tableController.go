@@ -1,19 +1,304 @@
 package controllers
 
-import "github.com/gin-gonic/gin"
+import (
+	"context"
+	"net/http"
+	"restaurant-management/apierror"
+	"restaurant-management/config"
+	"restaurant-management/models"
+	"time"
 
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var tableCollection = db.Collection("table")
+
+// GetTables lists the tables of ?location=, optionally in one ?section=,
+// by table number. Admins see deleted tables too with ?include_deleted=true.
 func GetTables() gin.HandlerFunc {
-	return func(c *gin.Context) {}
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		filter := bson.M{"location": c.DefaultQuery("location", defaultLocation)}
+		if section := c.Query("section"); section != "" {
+			filter["section"] = section
+		}
+
+		opts := options.Find().SetSort(bson.D{{Key: "table_number", Value: 1}})
+		result, err := tableCollection().Find(ctx, liveFilter(c, filter), opts)
+		if err != nil {
+			apierror.Render(c, apierror.Internal("error occurred while listing tables: "+err.Error()))
+			return
+		}
+
+		var allTables []bson.M
+		if err = result.All(ctx, &allTables); err != nil {
+			apierror.Render(c, apierror.Internal("error decoding tables: "+err.Error()))
+			return
+		}
+
+		c.JSON(http.StatusOK, allTables)
+	}
 }
 
 func GetTable() gin.HandlerFunc {
-	return func(c *gin.Context) {}
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		var table models.Table
+		err := tableCollection().FindOne(ctx, liveFilter(c, bson.M{"table_id": c.Param("table_id")})).Decode(&table)
+		if err != nil {
+			apierror.Render(c, apierror.NotFound("Table not found"))
+			return
+		}
+
+		c.JSON(http.StatusOK, table)
+	}
 }
 
+// CreateTable adds a FREE table. Table numbers are unique per location.
 func CreateTable() gin.HandlerFunc {
-	return func(c *gin.Context) {}
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		var table models.Table
+		if err := c.BindJSON(&table); err != nil {
+			apierror.Render(c, apierror.BadRequest("Invalid request data: "+err.Error()))
+			return
+		}
+		if err := validate.Struct(table); err != nil {
+			apierror.Render(c, apierror.FromValidation(err))
+			return
+		}
+		if table.Location == nil {
+			location := defaultLocation
+			table.Location = &location
+		}
+
+		count, err := tableCollection().CountDocuments(ctx, bson.M{"location": table.Location, "table_number": table.Table_number})
+		if err != nil {
+			apierror.Render(c, apierror.Internal("error occurred while checking the table number: "+err.Error()))
+			return
+		}
+		if count > 0 {
+			apierror.Render(c, apierror.Conflict("A table with this number already exists"))
+			return
+		}
+
+		table.Status = "FREE"
+		table.Created_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		table.Updated_at = table.Created_at
+		table.ID = primitive.NewObjectID()
+		table.Table_id = table.ID.Hex()
+
+		result, err := tableCollection().InsertOne(ctx, table)
+		if err != nil {
+			apierror.Render(c, apierror.Internal("Table was not created"))
+			return
+		}
+		auditCreated(c, table.Table_id)
+
+		c.JSON(http.StatusCreated, gin.H{"message": "Table created", "data": result, "table_id": table.Table_id})
+	}
 }
 
 func UpdateTable() gin.HandlerFunc {
-	return func(c *gin.Context) {}
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		var table models.Table
+		if err := c.BindJSON(&table); err != nil {
+			apierror.Render(c, apierror.BadRequest("Invalid request data: "+err.Error()))
+			return
+		}
+
+		var updateObj primitive.D
+		if table.Number_of_guests != nil {
+			if *table.Number_of_guests < 1 {
+				apierror.Render(c, apierror.Validation("Validation failed: number_of_guests must be positive"))
+				return
+			}
+			updateObj = append(updateObj, bson.E{Key: "number_of_guests", Value: table.Number_of_guests})
+		}
+		if table.Table_number != nil {
+			var current models.Table
+			if err := tableCollection().FindOne(ctx, bson.M{"table_id": c.Param("table_id")}).Decode(&current); err != nil {
+				apierror.Render(c, apierror.NotFound("Table not found"))
+				return
+			}
+			count, err := tableCollection().CountDocuments(ctx, bson.M{"location": current.Location, "table_number": table.Table_number, "table_id": bson.M{"$ne": current.Table_id}})
+			if err != nil {
+				apierror.Render(c, apierror.Internal("error occurred while checking the table number: "+err.Error()))
+				return
+			}
+			if count > 0 {
+				apierror.Render(c, apierror.Conflict("A table with this number already exists"))
+				return
+			}
+			updateObj = append(updateObj, bson.E{Key: "table_number", Value: table.Table_number})
+		}
+		if table.Section != nil {
+			updateObj = append(updateObj, bson.E{Key: "section", Value: table.Section})
+		}
+
+		table.Updated_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		updateObj = append(updateObj, bson.E{Key: "updated_at", Value: table.Updated_at})
+
+		result, err := tableCollection().UpdateOne(
+			ctx,
+			bson.M{"table_id": c.Param("table_id")},
+			bson.D{{Key: "$set", Value: updateObj}},
+		)
+		if err != nil {
+			apierror.Render(c, apierror.Internal("Update failed: "+err.Error()))
+			return
+		}
+		if result.MatchedCount == 0 {
+			apierror.Render(c, apierror.NotFound("Table not found"))
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Table updated successfully", "result": result})
+	}
+}
+
+// UpdateTableStatus sets a table's live status by hand, e.g. FREE once it
+// has been cleaned after the guests paid.
+func UpdateTableStatus() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		var body struct {
+			Status *string `json:"status" validate:"required,oneof=FREE OCCUPIED RESERVED CLEANING"`
+		}
+		if err := c.BindJSON(&body); err != nil {
+			apierror.Render(c, apierror.BadRequest("Invalid request data: "+err.Error()))
+			return
+		}
+		if err := validate.Struct(body); err != nil {
+			apierror.Render(c, apierror.FromValidation(err))
+			return
+		}
+
+		now, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		var table models.Table
+		err := tableCollection().FindOneAndUpdate(
+			ctx,
+			bson.M{"table_id": c.Param("table_id")},
+			bson.D{{Key: "$set", Value: bson.D{{Key: "status", Value: body.Status}, {Key: "updated_at", Value: now}}}},
+			options.FindOneAndUpdate().SetReturnDocument(options.After),
+		).Decode(&table)
+		if err == mongo.ErrNoDocuments {
+			apierror.Render(c, apierror.NotFound("Table not found"))
+			return
+		}
+		if err != nil {
+			apierror.Render(c, apierror.Internal("Update failed: "+err.Error()))
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Table status updated", "data": table})
+	}
+}
+
+// occupyTable marks the table of a new dine-in order OCCUPIED.
+func occupyTable(ctx context.Context, order models.Order) error {
+	if order.Table_id == nil || stringOr(order.Channel, defaultChannel) != "DINE_IN" {
+		return nil
+	}
+	now, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+	_, err := tableCollection().UpdateOne(
+		ctx,
+		bson.M{"table_id": order.Table_id},
+		bson.D{{Key: "$set", Value: bson.D{{Key: "status", Value: "OCCUPIED"}, {Key: "updated_at", Value: now}}}},
+	)
+	return err
+}
+
+// clearTable marks the table of a settled order CLEANING, unless another
+// check at the table is still open.
+func clearTable(ctx context.Context, orderId string) error {
+	var order models.Order
+	if err := orderCollection().FindOne(ctx, bson.M{"order_id": orderId}).Decode(&order); err != nil {
+		return err
+	}
+	if order.Table_id == nil || stringOr(order.Channel, defaultChannel) != "DINE_IN" {
+		return nil
+	}
+	// A split check is settled once the last of its invoices is paid
+	unpaid, err := invoiceCollection().CountDocuments(ctx, bson.M{"order_id": orderId, "payment_status": bson.M{"$ne": "PAID"}})
+	if err != nil || unpaid > 0 {
+		return err
+	}
+
+	cursor, err := orderCollection().Find(ctx, bson.M{"table_id": order.Table_id, "status": "OPEN", "order_id": bson.M{"$ne": orderId}, "deleted_at": notDeleted}, options.Find().SetProjection(bson.M{"order_id": 1}))
+	if err != nil {
+		return err
+	}
+	var others []models.Order
+	if err = cursor.All(ctx, &others); err != nil {
+		return err
+	}
+	for _, other := range others {
+		if _, err := openOrder(ctx, other.Order_id); err == nil {
+			return nil
+		} else if err != errOrderClosed {
+			return err
+		}
+	}
+
+	now, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+	_, err = tableCollection().UpdateOne(
+		ctx,
+		bson.M{"table_id": order.Table_id, "status": "OCCUPIED"},
+		bson.D{{Key: "$set", Value: bson.D{{Key: "status", Value: "CLEANING"}, {Key: "updated_at", Value: now}}}},
+	)
+	return err
+}
+
+// DeleteTable soft deletes a table. Open checks and upcoming reservations
+// at the table block the delete; they are never cascaded, since guests
+// are relying on them, and have to be moved first.
+func DeleteTable() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		tableId := c.Param("table_id")
+		orders, err := idsOf(ctx, orderCollection(), bson.M{"table_id": tableId, "status": "OPEN", "deleted_at": notDeleted}, "order_id")
+		if err != nil {
+			apierror.Render(c, apierror.Internal("error occurred while checking the table's orders"))
+			return
+		}
+		reservations, err := idsOf(ctx, reservationCollection(), bson.M{"table_id": tableId, "status": bson.M{"$in": upcomingReservationStatuses}}, "reservation_id")
+		if err != nil {
+			apierror.Render(c, apierror.Internal("error occurred while checking the table's reservations"))
+			return
+		}
+		if len(orders) > 0 || len(reservations) > 0 {
+			apierror.Render(c, apierror.Conflict("Settle or move the table's open checks and reservations first").With("order_ids", orders).With("reservation_ids", reservations))
+			return
+		}
+
+		found, err := softDelete(ctx, tableCollection(), "table_id", tableId)
+		if err != nil {
+			apierror.Render(c, apierror.Internal("Delete failed: "+err.Error()))
+			return
+		}
+		if !found {
+			apierror.Render(c, apierror.NotFound("Table not found"))
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Table deleted"})
+	}
 }
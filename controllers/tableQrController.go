@@ -0,0 +1,318 @@
+package controllers
+
+import (
+	"context"
+	"net/http"
+	"restaurant-management/apierror"
+	"restaurant-management/config"
+	"restaurant-management/helpers"
+	"restaurant-management/models"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// GetTableQr returns the signed token for a table's QR code and the
+// self-ordering link to print in it.
+func GetTableQr() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		if config.Get().PublicBaseURL == "" {
+			apierror.Render(c, apierror.Unavailable("PUBLIC_BASE_URL is not set, so QR links cannot be made"))
+			return
+		}
+		var table models.Table
+		if err := tableCollection().FindOne(ctx, bson.M{"table_id": c.Param("table_id"), "deleted_at": notDeleted}).Decode(&table); err != nil {
+			apierror.Render(c, apierror.NotFound("Table not found"))
+			return
+		}
+
+		c.JSON(http.StatusOK, tableQr(table))
+	}
+}
+
+// RegenerateTableQr voids a table's QR code, e.g. when a photo of it got
+// around, and returns the code to print instead.
+func RegenerateTableQr() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		if config.Get().PublicBaseURL == "" {
+			apierror.Render(c, apierror.Unavailable("PUBLIC_BASE_URL is not set, so QR links cannot be made"))
+			return
+		}
+		var table models.Table
+		err := tableCollection().FindOneAndUpdate(
+			ctx,
+			bson.M{"table_id": c.Param("table_id"), "deleted_at": notDeleted},
+			bson.D{
+				{Key: "$inc", Value: bson.D{{Key: "qr_version", Value: 1}}},
+				{Key: "$set", Value: bson.D{{Key: "updated_at", Value: time.Now()}}},
+			},
+			options.FindOneAndUpdate().SetReturnDocument(options.After),
+		).Decode(&table)
+		if err == mongo.ErrNoDocuments {
+			apierror.Render(c, apierror.NotFound("Table not found"))
+			return
+		}
+		if err != nil {
+			apierror.Render(c, apierror.Internal("error occurred while regenerating the QR code: "+err.Error()))
+			return
+		}
+
+		c.JSON(http.StatusOK, tableQr(table))
+	}
+}
+
+// tableQr is the token of a table's current QR code and the link printed
+// in it.
+func tableQr(table models.Table) gin.H {
+	token := helpers.TableToken(table.Table_id, table.Qr_version)
+	return gin.H{
+		"table_id":     table.Table_id,
+		"table_number": table.Table_number,
+		"qr_version":   table.Qr_version,
+		"token":        token,
+		"url":          config.Get().PublicBaseURL + "/qr/" + token,
+	}
+}
+
+// GetQrMenu lists the menus running now, with their foods that are not 86ed,
+// to a guest who scanned a table's QR code.
+func GetQrMenu() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		table, ok := qrTable(ctx, c)
+		if !ok {
+			return
+		}
+
+		menus, foods, err := runningMenuFoods(ctx)
+		if err != nil {
+			apierror.Render(c, apierror.Internal("error occurred while listing menus: "+err.Error()))
+			return
+		}
+		byMenu := map[string][]models.Food{}
+		for _, food := range foods {
+			byMenu[stringValue(food.Menu_id)] = append(byMenu[stringValue(food.Menu_id)], food)
+		}
+		listed := []gin.H{}
+		for _, menu := range menus {
+			menuFoods := byMenu[menu.Menu_id]
+			if menuFoods == nil {
+				menuFoods = []models.Food{}
+			}
+			listed = append(listed, gin.H{"menu_id": menu.Menu_id, "name": menu.Name, "category": menu.Category, "foods": menuFoods})
+		}
+
+		c.JSON(http.StatusOK, gin.H{"table_number": table.Table_number, "menus": listed})
+	}
+}
+
+// GetQrOrder shows a guest the open check on their table with its pricing.
+func GetQrOrder() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		table, ok := qrTable(ctx, c)
+		if !ok {
+			return
+		}
+
+		order, err := tableOpenOrder(ctx, table.Table_id)
+		if err == mongo.ErrNoDocuments {
+			c.JSON(http.StatusOK, gin.H{"table_number": table.Table_number, "order_id": nil, "order_items": []models.OrderItem{}})
+			return
+		}
+		if err != nil {
+			apierror.Render(c, apierror.Internal("error occurred while loading order: "+err.Error()))
+			return
+		}
+		orderItems, err := orderItemsOf(ctx, order.Order_id)
+		if err != nil {
+			apierror.Render(c, apierror.Internal("error occurred while listing order items: "+err.Error()))
+			return
+		}
+		priced, pricing, err := priceOrder(ctx, order, orderItems)
+		if err != nil {
+			apierror.Render(c, apierror.Internal("error occurred while pricing order: "+err.Error()))
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"table_number": table.Table_number, "order_id": order.Order_id, "order_items": priced, "pricing": pricing})
+	}
+}
+
+// PlaceQrOrder puts a guest's items on the open check of the table whose QR
+// code they scanned, opening one if there is none; guests ordering at once
+// at a table without a check share the one that is opened. The table
+// comes from the token alone. Foods must be available on a menu running now and are
+// charged at their menu price, whatever the guest sends; combos must be
+// active and made of such foods, and are priced as usual.
+func PlaceQrOrder() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		var body struct {
+			Order_items []models.OrderItem `json:"order_items" validate:"required,min=1,max=50"`
+		}
+		if err := c.BindJSON(&body); err != nil {
+			apierror.Render(c, apierror.BadRequest("Invalid request data: "+err.Error()))
+			return
+		}
+		if err := validate.Struct(body); err != nil {
+			apierror.Render(c, apierror.FromValidation(err))
+			return
+		}
+
+		table, ok := qrTable(ctx, c)
+		if !ok {
+			return
+		}
+
+		_, foods, err := runningMenuFoods(ctx)
+		if err != nil {
+			apierror.Render(c, apierror.Internal("error occurred while listing menus: "+err.Error()))
+			return
+		}
+		onMenu := map[string]models.Food{}
+		for _, food := range foods {
+			onMenu[food.Food_id] = food
+		}
+		comboIds := []string{}
+		for _, orderItem := range body.Order_items {
+			if orderItem.Combo_id != nil {
+				comboIds = append(comboIds, *orderItem.Combo_id)
+			}
+		}
+		combos, err := combosById(ctx, comboIds)
+		if err != nil {
+			apierror.Render(c, apierror.Internal("error occurred while loading combos: "+err.Error()))
+			return
+		}
+		notOnMenu := []string{}
+		for i := range body.Order_items {
+			orderItem := &body.Order_items[i]
+			orderItem.Order_id = ""
+			orderItem.Server_id = nil
+			orderItem.Adjustments = nil
+			orderItem.Combo_line_id = ""
+			if orderItem.Combo_id != nil {
+				if !comboOnMenu(combos[*orderItem.Combo_id], onMenu) {
+					notOnMenu = append(notOnMenu, *orderItem.Combo_id)
+				}
+				continue
+			}
+			food, ok := onMenu[stringValue(orderItem.Food_id)]
+			if !ok || food.Price == nil {
+				notOnMenu = append(notOnMenu, stringValue(orderItem.Food_id))
+				continue
+			}
+			price := *food.Price
+			orderItem.Unit_price = &price
+		}
+		if len(notOnMenu) > 0 {
+			apierror.Render(c, apierror.BadRequest("Some foods or combos are not on a menu running now").With("food_ids", notOnMenu))
+			return
+		}
+
+		channel := "DINE_IN"
+		pack := OrderItemPack{Table_id: &table.Table_id, Channel: &channel, Order_items: body.Order_items, Join_table_check: true}
+		order, err := tableOpenOrder(ctx, table.Table_id)
+		switch {
+		case err == nil:
+			pack.Order_id = &order.Order_id
+		case err != mongo.ErrNoDocuments:
+			apierror.Render(c, apierror.Internal("error occurred while loading order: "+err.Error()))
+			return
+		}
+
+		order, orderItems, err := placeOrder(ctx, pack, nil)
+		if rejection, ok := err.(*apierror.Error); ok {
+			apierror.Render(c, rejection)
+			return
+		}
+		if err != nil {
+			apierror.Render(c, apierror.Internal("Order items were not created: "+err.Error()))
+			return
+		}
+
+		c.JSON(http.StatusCreated, gin.H{"order_id": order.Order_id, "order_items": orderItems})
+	}
+}
+
+// qrTable loads the table named by the QR token in the path, rendering
+// the error if the token is forged or voided or the table gone.
+func qrTable(ctx context.Context, c *gin.Context) (models.Table, bool) {
+	var table models.Table
+	tableId, version, err := helpers.ValidateTableToken(c.Param("token"))
+	if err != nil {
+		apierror.Render(c, apierror.NotFound("Table not found"))
+		return table, false
+	}
+	if err := tableCollection().FindOne(ctx, bson.M{"table_id": tableId, "qr_version": qrVersionFilter(version), "deleted_at": notDeleted}).Decode(&table); err != nil {
+		apierror.Render(c, apierror.NotFound("Table not found"))
+		return table, false
+	}
+	return table, true
+}
+
+// qrVersionFilter matches tables whose QR code is at version, counting
+// tables stored before QR codes had versions as version 0.
+func qrVersionFilter(version int) interface{} {
+	if version == 0 {
+		return bson.M{"$in": bson.A{0, nil}}
+	}
+	return version
+}
+
+// comboOnMenu reports whether combo is active and all its foods are in
+// onMenu.
+func comboOnMenu(combo models.Combo, onMenu map[string]models.Food) bool {
+	if combo.Active == nil || !*combo.Active {
+		return false
+	}
+	for _, item := range combo.Items {
+		if _, ok := onMenu[stringValue(item.Food_id)]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// runningMenuFoods returns the menus running now and their live foods that
+// are not 86ed.
+func runningMenuFoods(ctx context.Context) ([]models.Menu, []models.Food, error) {
+	cursor, err := menuCollection().Find(ctx, menusRunningAt(time.Now()))
+	if err != nil {
+		return nil, nil, err
+	}
+	var menus []models.Menu
+	if err = cursor.All(ctx, &menus); err != nil {
+		return nil, nil, err
+	}
+	menuIds := []string{}
+	for _, menu := range menus {
+		menuIds = append(menuIds, menu.Menu_id)
+	}
+
+	cursor, err = foodCollection().Find(ctx, bson.M{"menu_id": bson.M{"$in": menuIds}, "available": bson.M{"$ne": false}, "deleted_at": notDeleted})
+	if err != nil {
+		return nil, nil, err
+	}
+	var foods []models.Food
+	if err = cursor.All(ctx, &foods); err != nil {
+		return nil, nil, err
+	}
+	return menus, foods, nil
+}
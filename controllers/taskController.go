@@ -0,0 +1,380 @@
+package controllers
+
+import (
+	"context"
+	"net/http"
+	"restaurant-management/apierror"
+	"restaurant-management/config"
+	"restaurant-management/models"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var taskTemplateCollection = db.Collection("taskTemplate")
+var taskCollection = db.Collection("task")
+
+func GetTaskTemplates() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		filter := bson.M{}
+		for _, key := range []string{"station", "kind", "location"} {
+			if value := c.Query(key); value != "" {
+				filter[key] = value
+			}
+		}
+
+		result, err := taskTemplateCollection().Find(ctx, filter)
+		if err != nil {
+			apierror.Render(c, apierror.Internal("error occurred while listing task templates: "+err.Error()))
+			return
+		}
+
+		var allTemplates []bson.M
+		if err = result.All(ctx, &allTemplates); err != nil {
+			apierror.Render(c, apierror.Internal("error decoding task templates: "+err.Error()))
+			return
+		}
+
+		c.JSON(http.StatusOK, allTemplates)
+	}
+}
+
+func CreateTaskTemplate() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		var template models.TaskTemplate
+		if err := c.BindJSON(&template); err != nil {
+			apierror.Render(c, apierror.BadRequest("Invalid request data: "+err.Error()))
+			return
+		}
+		if err := validate.Struct(template); err != nil {
+			apierror.Render(c, apierror.FromValidation(err))
+			return
+		}
+
+		if template.Location == nil {
+			location := defaultLocation
+			template.Location = &location
+		}
+		if template.Active == nil {
+			active := true
+			template.Active = &active
+		}
+		template.Created_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		template.Updated_at = template.Created_at
+		template.ID = primitive.NewObjectID()
+		template.Task_template_id = template.ID.Hex()
+
+		result, err := taskTemplateCollection().InsertOne(ctx, template)
+		if err != nil {
+			apierror.Render(c, apierror.Internal("Task template was not created: "+err.Error()))
+			return
+		}
+
+		// Put today's occurrence on the list straight away
+		if err := materializeTasks(ctx, time.Now()); err != nil {
+			apierror.Render(c, apierror.Internal("error scheduling tasks: "+err.Error()))
+			return
+		}
+
+		c.JSON(http.StatusCreated, gin.H{"message": "Task template created", "data": result})
+	}
+}
+
+// UpdateTaskTemplate changes a template. Tasks already on today's list keep
+// their original details.
+func UpdateTaskTemplate() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		templateId := c.Param("task_template_id")
+
+		var existing models.TaskTemplate
+		if err := taskTemplateCollection().FindOne(ctx, bson.M{"task_template_id": templateId}).Decode(&existing); err != nil {
+			apierror.Render(c, apierror.NotFound("Task template not found"))
+			return
+		}
+
+		var template models.TaskTemplate
+		if err := c.BindJSON(&template); err != nil {
+			apierror.Render(c, apierror.BadRequest("Invalid request data: "+err.Error()))
+			return
+		}
+
+		var updateObj primitive.D
+		if template.Name != nil {
+			existing.Name = template.Name
+			updateObj = append(updateObj, bson.E{Key: "name", Value: template.Name})
+		}
+		if template.Station != nil {
+			existing.Station = template.Station
+			updateObj = append(updateObj, bson.E{Key: "station", Value: template.Station})
+		}
+		if template.Recurrence != nil {
+			existing.Recurrence = template.Recurrence
+			updateObj = append(updateObj, bson.E{Key: "recurrence", Value: template.Recurrence})
+		}
+		if template.Days != nil {
+			existing.Days = template.Days
+			updateObj = append(updateObj, bson.E{Key: "days", Value: template.Days})
+		}
+		if template.Due_time != nil {
+			existing.Due_time = template.Due_time
+			updateObj = append(updateObj, bson.E{Key: "due_time", Value: template.Due_time})
+		}
+		if template.Instructions != nil {
+			updateObj = append(updateObj, bson.E{Key: "instructions", Value: template.Instructions})
+		}
+		if template.Active != nil {
+			updateObj = append(updateObj, bson.E{Key: "active", Value: template.Active})
+		}
+		if err := validate.Struct(existing); err != nil {
+			apierror.Render(c, apierror.FromValidation(err))
+			return
+		}
+
+		updatedAt, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		updateObj = append(updateObj, bson.E{Key: "updated_at", Value: updatedAt})
+
+		result, err := taskTemplateCollection().UpdateOne(ctx, bson.M{"task_template_id": templateId}, bson.D{{Key: "$set", Value: updateObj}})
+		if err != nil {
+			apierror.Render(c, apierror.Internal("Update failed: "+err.Error()))
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Task template updated successfully", "result": result})
+	}
+}
+
+// GetTasks returns the task list for ?date= (default today), optionally for
+// one ?station=. Lists for today and future days are generated on demand.
+func GetTasks() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		day := time.Now()
+		if value := c.Query("date"); value != "" {
+			parsed, err := time.ParseInLocation("2006-01-02", value, time.Local)
+			if err != nil {
+				apierror.Render(c, apierror.BadRequest("date must be formatted as YYYY-MM-DD"))
+				return
+			}
+			day = parsed
+		}
+		if day.Format("2006-01-02") >= time.Now().Format("2006-01-02") {
+			if err := materializeTasks(ctx, day); err != nil {
+				apierror.Render(c, apierror.Internal("error scheduling tasks: "+err.Error()))
+				return
+			}
+		}
+
+		filter := bson.M{"task_date": day.Format("2006-01-02")}
+		for _, key := range []string{"station", "location", "status"} {
+			if value := c.Query(key); value != "" {
+				filter[key] = value
+			}
+		}
+
+		opts := options.Find().SetSort(bson.D{{Key: "station", Value: 1}, {Key: "due_at", Value: 1}})
+		result, err := taskCollection().Find(ctx, filter, opts)
+		if err != nil {
+			apierror.Render(c, apierror.Internal("error occurred while listing tasks: "+err.Error()))
+			return
+		}
+
+		var allTasks []models.Task
+		if err = result.All(ctx, &allTasks); err != nil {
+			apierror.Render(c, apierror.Internal("error decoding tasks: "+err.Error()))
+			return
+		}
+
+		byStation := map[string][]models.Task{}
+		for _, task := range allTasks {
+			byStation[task.Station] = append(byStation[task.Station], task)
+		}
+
+		c.JSON(http.StatusOK, gin.H{"date": day.Format("2006-01-02"), "stations": byStation})
+	}
+}
+
+func CompleteTask() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		var body struct {
+			Completed_by *string `json:"completed_by" validate:"required"`
+			Note         *string `json:"note" validate:"omitempty,max=500"`
+		}
+		if err := c.BindJSON(&body); err != nil {
+			apierror.Render(c, apierror.BadRequest("Invalid request data: "+err.Error()))
+			return
+		}
+		if err := validate.Struct(body); err != nil {
+			apierror.Render(c, apierror.FromValidation(err))
+			return
+		}
+
+		// Missed tasks can still be signed off late; they keep showing as late
+		completedAt, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		result, err := taskCollection().UpdateOne(
+			ctx,
+			bson.M{"task_id": c.Param("task_id"), "status": bson.M{"$in": bson.A{"PENDING", "MISSED"}}},
+			bson.D{{Key: "$set", Value: bson.D{
+				{Key: "status", Value: "DONE"},
+				{Key: "completed_by", Value: body.Completed_by},
+				{Key: "completed_at", Value: completedAt},
+				{Key: "note", Value: body.Note},
+			}}},
+		)
+		if err != nil {
+			apierror.Render(c, apierror.Internal("Update failed: "+err.Error()))
+			return
+		}
+		if result.MatchedCount == 0 {
+			apierror.Render(c, apierror.NotFound("No open task found"))
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Task completed"})
+	}
+}
+
+// GetMissedTasks shows managers what was missed or finished late in
+// ?from=&to=, with totals per station.
+func GetMissedTasks() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		from, to, err := parseReportRange(c)
+		if err != nil {
+			apierror.Render(c, apierror.BadRequest(err.Error()))
+			return
+		}
+
+		match := bson.D{
+			{Key: "due_at", Value: bson.D{{Key: "$gte", Value: from}, {Key: "$lt", Value: to}}},
+			{Key: "$or", Value: bson.A{
+				bson.D{{Key: "status", Value: "MISSED"}},
+				bson.D{{Key: "$expr", Value: bson.D{{Key: "$gt", Value: bson.A{"$completed_at", "$due_at"}}}}},
+			}},
+		}
+		if location := c.Query("location"); location != "" {
+			match = append(match, bson.E{Key: "location", Value: location})
+		}
+
+		cursor, err := taskCollection().Aggregate(ctx, mongo.Pipeline{
+			{{Key: "$match", Value: match}},
+			{{Key: "$sort", Value: bson.D{{Key: "due_at", Value: 1}}}},
+			{{Key: "$group", Value: bson.D{
+				{Key: "_id", Value: "$station"},
+				{Key: "missed", Value: bson.D{{Key: "$sum", Value: bson.D{{Key: "$cond", Value: bson.A{bson.D{{Key: "$eq", Value: bson.A{"$status", "MISSED"}}}, 1, 0}}}}}},
+				{Key: "late", Value: bson.D{{Key: "$sum", Value: bson.D{{Key: "$cond", Value: bson.A{bson.D{{Key: "$eq", Value: bson.A{"$status", "DONE"}}}, 1, 0}}}}}},
+				{Key: "tasks", Value: bson.D{{Key: "$push", Value: bson.D{
+					{Key: "task_id", Value: "$task_id"},
+					{Key: "name", Value: "$name"},
+					{Key: "task_date", Value: "$task_date"},
+					{Key: "status", Value: "$status"},
+					{Key: "due_at", Value: "$due_at"},
+					{Key: "completed_at", Value: "$completed_at"},
+				}}}},
+			}}},
+			{{Key: "$sort", Value: bson.D{{Key: "missed", Value: -1}}}},
+		})
+		if err != nil {
+			apierror.Render(c, apierror.Internal("error occurred while aggregating missed tasks: "+err.Error()))
+			return
+		}
+		var stations []bson.M
+		if err = cursor.All(ctx, &stations); err != nil {
+			apierror.Render(c, apierror.Internal("error decoding missed tasks: "+err.Error()))
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"from": from, "to": to, "stations": stations})
+	}
+}
+
+// RunTaskSchedule generates today's task lists and marks overdue tasks as
+// missed. It runs on the scheduler; both steps are idempotent.
+func RunTaskSchedule(ctx context.Context) error {
+	now := time.Now()
+	if err := materializeTasks(ctx, now); err != nil {
+		return err
+	}
+	_, err := taskCollection().UpdateMany(
+		ctx,
+		bson.M{"status": "PENDING", "due_at": bson.M{"$lt": now}},
+		bson.D{{Key: "$set", Value: bson.D{{Key: "status", Value: "MISSED"}}}},
+	)
+	return err
+}
+
+// materializeTasks creates the day's task from every active template that
+// runs on that day, skipping tasks that already exist.
+func materializeTasks(ctx context.Context, day time.Time) error {
+	cursor, err := taskTemplateCollection().Find(ctx, bson.M{"active": true})
+	if err != nil {
+		return err
+	}
+	var templates []models.TaskTemplate
+	if err = cursor.All(ctx, &templates); err != nil {
+		return err
+	}
+
+	taskDate := day.Format("2006-01-02")
+	upsert := true
+	for _, template := range templates {
+		if !templateRunsOn(template, day) {
+			continue
+		}
+		clock, _ := time.Parse("15:04", *template.Due_time)
+		dueAt := time.Date(day.Year(), day.Month(), day.Day(), clock.Hour(), clock.Minute(), 0, 0, day.Location())
+
+		id := primitive.NewObjectID()
+		_, err := taskCollection().UpdateOne(
+			ctx,
+			bson.M{"task_template_id": template.Task_template_id, "task_date": taskDate},
+			bson.D{{Key: "$setOnInsert", Value: bson.D{
+				{Key: "_id", Value: id},
+				{Key: "task_id", Value: id.Hex()},
+				{Key: "name", Value: template.Name},
+				{Key: "kind", Value: template.Kind},
+				{Key: "station", Value: template.Station},
+				{Key: "location", Value: template.Location},
+				{Key: "due_at", Value: dueAt},
+				{Key: "status", Value: "PENDING"},
+			}}},
+			&options.UpdateOptions{Upsert: &upsert},
+		)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func templateRunsOn(template models.TaskTemplate, day time.Time) bool {
+	if *template.Recurrence == "DAILY" {
+		return true
+	}
+	code := weekdayCodes[day.Weekday()]
+	for _, d := range template.Days {
+		if d == code {
+			return true
+		}
+	}
+	return false
+}
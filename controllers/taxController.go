@@ -0,0 +1,433 @@
+package controllers
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"restaurant-management/apierror"
+	"restaurant-management/config"
+	"restaurant-management/models"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var taxRateCollection = db.Collection("taxRate")
+
+// feesCategory is the tax category of order-level fees, which belong to no
+// menu.
+const feesCategory = "FEES"
+
+// TaxReportRow is the revenue of one category at one rate over a period.
+// Credit notes are shown separately and already subtracted from the totals.
+type TaxReportRow struct {
+	Rate         float64 `json:"rate" xml:"Rate"`
+	Category     string  `json:"category" xml:"Category"`
+	Sales_net    float64 `json:"sales_net" xml:"SalesNet"`
+	Sales_tax    float64 `json:"sales_tax" xml:"SalesTax"`
+	Credits_net  float64 `json:"credits_net" xml:"CreditsNet"`
+	Credits_tax  float64 `json:"credits_tax" xml:"CreditsTax"`
+	Net          float64 `json:"net" xml:"Net"`
+	Tax          float64 `json:"tax" xml:"Tax"`
+	Gross        float64 `json:"gross" xml:"Gross"`
+	Invoices     int     `json:"invoices" xml:"Invoices"`
+	Credit_notes int     `json:"credit_notes" xml:"CreditNotes"`
+}
+
+type TaxReport struct {
+	XMLName  xml.Name       `json:"-" xml:"TaxReturn"`
+	Location string         `json:"location" xml:"Location"`
+	From     string         `json:"from" xml:"PeriodStart"`
+	To       string         `json:"to" xml:"PeriodEnd"`
+	Rows     []TaxReportRow `json:"rows" xml:"Lines>Line"`
+	Net      float64        `json:"net" xml:"TotalNet"`
+	Tax      float64        `json:"tax" xml:"TotalTax"`
+	Gross    float64        `json:"gross" xml:"TotalGross"`
+}
+
+func GetTaxRates() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		result, err := taxRateCollection().Find(ctx, bson.M{})
+		if err != nil {
+			apierror.Render(c, apierror.Internal("error occurred while listing tax rates: "+err.Error()))
+			return
+		}
+
+		var allRates []bson.M
+		if err = result.All(ctx, &allRates); err != nil {
+			apierror.Render(c, apierror.Internal("error decoding tax rates: "+err.Error()))
+			return
+		}
+
+		c.JSON(http.StatusOK, allRates)
+	}
+}
+
+func CreateTaxRate() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		var rate models.TaxRate
+		if err := c.BindJSON(&rate); err != nil {
+			apierror.Render(c, apierror.BadRequest("Invalid request data: "+err.Error()))
+			return
+		}
+		if err := validate.Struct(rate); err != nil {
+			apierror.Render(c, apierror.FromValidation(err))
+			return
+		}
+
+		count, err := taxRateCollection().CountDocuments(ctx, bson.M{"category": rate.Category, "location": rate.Location})
+		if err != nil {
+			apierror.Render(c, apierror.Internal("error checking tax rates: "+err.Error()))
+			return
+		}
+		if count > 0 {
+			apierror.Render(c, apierror.Conflict("A tax rate for this category and location already exists"))
+			return
+		}
+
+		if rate.Exclusive == nil {
+			exclusive := false
+			rate.Exclusive = &exclusive
+		}
+		rate.Created_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		rate.Updated_at = rate.Created_at
+		rate.ID = primitive.NewObjectID()
+		rate.Tax_rate_id = rate.ID.Hex()
+
+		result, err := taxRateCollection().InsertOne(ctx, rate)
+		if err != nil {
+			apierror.Render(c, apierror.Internal("Tax rate was not created: "+err.Error()))
+			return
+		}
+
+		c.JSON(http.StatusCreated, gin.H{"message": "Tax rate created", "data": result})
+	}
+}
+
+// UpdateTaxRate changes a rate and, with exclusive, whether it is added on
+// top of menu prices. Finalized invoices keep the rate they were issued
+// with.
+func UpdateTaxRate() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		var rate models.TaxRate
+		if err := c.BindJSON(&rate); err != nil {
+			apierror.Render(c, apierror.BadRequest("Invalid request data: "+err.Error()))
+			return
+		}
+		if err := validate.Struct(rate); err != nil {
+			apierror.Render(c, apierror.FromValidation(err))
+			return
+		}
+
+		now, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		updateObj := bson.D{{Key: "rate", Value: rate.Rate}}
+		if rate.Exclusive != nil {
+			updateObj = append(updateObj, bson.E{Key: "exclusive", Value: rate.Exclusive})
+		}
+		updateObj = append(updateObj, bson.E{Key: "updated_at", Value: now})
+		result, err := taxRateCollection().UpdateOne(
+			ctx,
+			bson.M{"tax_rate_id": c.Param("tax_rate_id")},
+			bson.D{{Key: "$set", Value: updateObj}},
+		)
+		if err != nil {
+			apierror.Render(c, apierror.Internal("Update failed: "+err.Error()))
+			return
+		}
+		if result.MatchedCount == 0 {
+			apierror.Render(c, apierror.NotFound("Tax rate not found"))
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Tax rate updated successfully", "result": result})
+	}
+}
+
+// GetTaxReport breaks revenue down by tax rate and category for a VAT/GST
+// return over ?from= and ?to=, by invoice finalization and credit note issue
+// date. ?format=csv or xml downloads it for filing.
+func GetTaxReport() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		from, to, err := parseReportRange(c)
+		if err != nil {
+			apierror.Render(c, apierror.BadRequest(err.Error()))
+			return
+		}
+		location := c.DefaultQuery("location", defaultLocation)
+
+		sales, err := taxTotals(ctx, invoiceCollection(), bson.D{{Key: "location", Value: location}, {Key: "finalized_at", Value: bson.M{"$gte": from, "$lt": to}}})
+		if err != nil {
+			apierror.Render(c, apierror.Internal("error occurred while totalling invoices: "+err.Error()))
+			return
+		}
+		credits, err := taxTotals(ctx, creditNoteCollection(), bson.D{{Key: "location", Value: location}, {Key: "issued_at", Value: bson.M{"$gte": from, "$lt": to}}})
+		if err != nil {
+			apierror.Render(c, apierror.Internal("error occurred while totalling credit notes: "+err.Error()))
+			return
+		}
+
+		report := TaxReport{Location: location, From: from.Format("2006-01-02"), To: to.AddDate(0, 0, -1).Format("2006-01-02"), Rows: []TaxReportRow{}}
+		rows := map[taxKey]*TaxReportRow{}
+		row := func(key taxKey) *TaxReportRow {
+			if rows[key] == nil {
+				rows[key] = &TaxReportRow{Rate: key.Rate, Category: key.Category}
+			}
+			return rows[key]
+		}
+		for _, total := range sales {
+			r := row(total.ID)
+			r.Sales_net, r.Sales_tax, r.Invoices = total.Net, total.Tax, total.Documents
+		}
+		// Credit note lines are negative, so adding them nets out the refunds
+		for _, total := range credits {
+			r := row(total.ID)
+			r.Credits_net, r.Credits_tax, r.Credit_notes = total.Net, total.Tax, total.Documents
+		}
+		for _, r := range rows {
+			r.Net = toFixed(r.Sales_net+r.Credits_net, 2)
+			r.Tax = toFixed(r.Sales_tax+r.Credits_tax, 2)
+			r.Gross = toFixed(r.Net+r.Tax, 2)
+			report.Rows = append(report.Rows, *r)
+			report.Net += r.Net
+			report.Tax += r.Tax
+		}
+		sort.Slice(report.Rows, func(i, j int) bool {
+			if report.Rows[i].Rate != report.Rows[j].Rate {
+				return report.Rows[i].Rate > report.Rows[j].Rate
+			}
+			return report.Rows[i].Category < report.Rows[j].Category
+		})
+		report.Net = toFixed(report.Net, 2)
+		report.Tax = toFixed(report.Tax, 2)
+		report.Gross = toFixed(report.Net+report.Tax, 2)
+
+		filename := fmt.Sprintf("tax-%s-%s-%s", location, from.Format("20060102"), to.AddDate(0, 0, -1).Format("20060102"))
+		switch c.Query("format") {
+		case "csv":
+			c.Header("Content-Type", "text/csv")
+			c.Header("Content-Disposition", "attachment; filename="+filename+".csv")
+
+			money := func(amount float64) string { return strconv.FormatFloat(amount, 'f', 2, 64) }
+			writer := csv.NewWriter(c.Writer)
+			writer.Write([]string{"rate", "category", "sales_net", "sales_tax", "credits_net", "credits_tax", "net", "tax", "gross", "invoices", "credit_notes"})
+			for _, r := range report.Rows {
+				writer.Write([]string{
+					strconv.FormatFloat(r.Rate, 'f', -1, 64), r.Category,
+					money(r.Sales_net), money(r.Sales_tax), money(r.Credits_net), money(r.Credits_tax),
+					money(r.Net), money(r.Tax), money(r.Gross),
+					strconv.Itoa(r.Invoices), strconv.Itoa(r.Credit_notes),
+				})
+			}
+			writer.Write([]string{"TOTAL", "", "", "", "", "", money(report.Net), money(report.Tax), money(report.Gross), "", ""})
+			writer.Flush()
+		case "xml":
+			c.Header("Content-Disposition", "attachment; filename="+filename+".xml")
+			c.XML(http.StatusOK, report)
+		default:
+			c.JSON(http.StatusOK, report)
+		}
+	}
+}
+
+type taxKey struct {
+	Rate     float64 `bson:"rate"`
+	Category string  `bson:"category"`
+}
+
+type taxTotal struct {
+	ID        taxKey  `bson:"_id"`
+	Net       float64 `bson:"net"`
+	Tax       float64 `bson:"tax"`
+	Documents int     `bson:"documents"`
+}
+
+// taxTotals sums the tax lines of the matching invoices or credit notes per
+// rate and category.
+func taxTotals(ctx context.Context, collection *mongo.Collection, match bson.D) ([]taxTotal, error) {
+	cursor, err := collection.Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$match", Value: match}},
+		{{Key: "$unwind", Value: "$tax_lines"}},
+		{{Key: "$group", Value: bson.D{
+			{Key: "_id", Value: bson.D{{Key: "rate", Value: "$tax_lines.rate"}, {Key: "category", Value: "$tax_lines.category"}}},
+			{Key: "net", Value: bson.D{{Key: "$sum", Value: "$tax_lines.net"}}},
+			{Key: "tax", Value: bson.D{{Key: "$sum", Value: "$tax_lines.tax"}}},
+			{Key: "documents", Value: bson.D{{Key: "$sum", Value: 1}}},
+		}}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	var totals []taxTotal
+	if err = cursor.All(ctx, &totals); err != nil {
+		return nil, err
+	}
+	return totals, nil
+}
+
+// taxRule is the rate a category is taxed at and whether the tax comes on
+// top of menu prices.
+type taxRule struct {
+	rate      float64
+	exclusive bool
+}
+
+// currentTaxRates returns a lookup of the rates in force at a location.
+func currentTaxRates(ctx context.Context, location string) (func(category string) taxRule, error) {
+	var rates []models.TaxRate
+	cursor, err := taxRateCollection().Find(ctx, bson.M{"location": bson.M{"$in": bson.A{location, nil, ""}}})
+	if err != nil {
+		return nil, err
+	}
+	if err = cursor.All(ctx, &rates); err != nil {
+		return nil, err
+	}
+	return func(category string) taxRule { return taxRateFor(rates, category, location) }, nil
+}
+
+// taxLines works out the tax on order items and fees at the rates rateFor
+// gives per category. Inclusive tax is taken out of what each category
+// sold for; exclusive tax is added on top of it.
+func taxLines(ctx context.Context, orderItems []models.OrderItem, fees []models.PriceAdjustment, rateFor func(category string) taxRule) ([]models.TaxLine, error) {
+	foodIds := []string{}
+	for _, orderItem := range orderItems {
+		foodIds = append(foodIds, stringValue(orderItem.Food_id))
+	}
+	_, categories, err := foodCategories(ctx, foodIds)
+	if err != nil {
+		return nil, err
+	}
+
+	sold := map[taxKey]float64{}
+	exclusive := map[taxKey]bool{}
+	add := func(category string, amount float64) {
+		rule := rateFor(category)
+		key := taxKey{Rate: rule.rate, Category: category}
+		sold[key] += amount
+		exclusive[key] = rule.exclusive
+	}
+	for _, orderItem := range orderItems {
+		amount := 0.0
+		if orderItem.Unit_price != nil {
+			amount = *orderItem.Unit_price
+		}
+		for _, adjustment := range orderItem.Adjustments {
+			amount += adjustment.Amount
+		}
+		add(categories[stringValue(orderItem.Food_id)], amount)
+	}
+	for _, fee := range fees {
+		add(feesCategory, fee.Amount)
+	}
+
+	lines := []models.TaxLine{}
+	for key, amount := range sold {
+		amount = toFixed(amount, 2)
+		line := models.TaxLine{Category: key.Category, Rate: key.Rate, Exclusive: exclusive[key]}
+		if line.Exclusive {
+			line.Net = amount
+			line.Tax = toFixed(amount*key.Rate/100, 2)
+			line.Gross = toFixed(amount+line.Tax, 2)
+		} else {
+			line.Gross = amount
+			line.Net = toFixed(amount/(1+key.Rate/100), 2)
+			line.Tax = toFixed(amount-line.Net, 2)
+		}
+		lines = append(lines, line)
+	}
+	sort.Slice(lines, func(i, j int) bool { return lines[i].Category < lines[j].Category })
+	return lines, nil
+}
+
+// foodCategories looks up foods by id together with the menu category of
+// each, keyed by food id.
+func foodCategories(ctx context.Context, foodIds []string) (map[string]models.Food, map[string]string, error) {
+	foods, err := foodsById(ctx, foodIds)
+	if err != nil {
+		return nil, nil, err
+	}
+	menuIds := []string{}
+	for _, food := range foods {
+		menuIds = append(menuIds, stringValue(food.Menu_id))
+	}
+	categories := map[string]string{}
+	if len(menuIds) == 0 {
+		return foods, categories, nil
+	}
+
+	var menus []models.Menu
+	cursor, err := menuCollection().Find(ctx, bson.M{"menu_id": bson.M{"$in": menuIds}}, options.Find().SetProjection(bson.M{"menu_id": 1, "category": 1}))
+	if err != nil {
+		return nil, nil, err
+	}
+	if err = cursor.All(ctx, &menus); err != nil {
+		return nil, nil, err
+	}
+	menuCategories := map[string]string{}
+	for _, menu := range menus {
+		menuCategories[menu.Menu_id] = menu.Category
+	}
+	for foodId, food := range foods {
+		categories[foodId] = menuCategories[stringValue(food.Menu_id)]
+	}
+	return foods, categories, nil
+}
+
+// taxRateFor picks the most specific rate: category and location, then
+// category, then location, then the catch-all rate. Without any it is 0.
+func taxRateFor(rates []models.TaxRate, category, location string) taxRule {
+	best, bestScore := taxRule{}, -1
+	for _, rate := range rates {
+		rateCategory, rateLocation := stringValue(rate.Category), stringValue(rate.Location)
+		if (rateCategory != "" && rateCategory != category) || (rateLocation != "" && rateLocation != location) {
+			continue
+		}
+		score := 0
+		if rateCategory != "" {
+			score += 2
+		}
+		if rateLocation != "" {
+			score++
+		}
+		if score > bestScore {
+			best, bestScore = taxRule{rate: *rate.Rate, exclusive: rate.Exclusive != nil && *rate.Exclusive}, score
+		}
+	}
+	return best
+}
+
+// negateTaxLines reverses tax lines for a credit note.
+func negateTaxLines(lines []models.TaxLine) []models.TaxLine {
+	negated := make([]models.TaxLine, len(lines))
+	for i, line := range lines {
+		negated[i] = models.TaxLine{Category: line.Category, Rate: line.Rate, Exclusive: line.Exclusive, Net: -line.Net, Tax: -line.Tax, Gross: -line.Gross}
+	}
+	return negated
+}
+
+func taxLinesGross(lines []models.TaxLine) float64 {
+	total := 0.0
+	for _, line := range lines {
+		total += line.Gross
+	}
+	return toFixed(total, 2)
+}
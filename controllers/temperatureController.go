@@ -0,0 +1,533 @@
+package controllers
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"log"
+	"net/http"
+	"restaurant-management/apierror"
+	"restaurant-management/config"
+	"restaurant-management/models"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var temperatureUnitCollection = db.Collection("temperatureUnit")
+var temperatureReadingCollection = db.Collection("temperatureReading")
+var temperatureAlertCollection = db.Collection("temperatureAlert")
+
+func GetTemperatureUnits() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		filter := bson.M{}
+		if location := c.Query("location"); location != "" {
+			filter["location"] = location
+		}
+
+		result, err := temperatureUnitCollection().Find(ctx, filter)
+		if err != nil {
+			apierror.Render(c, apierror.Internal("error occurred while listing temperature units: "+err.Error()))
+			return
+		}
+
+		var allUnits []models.TemperatureUnit
+		if err = result.All(ctx, &allUnits); err != nil {
+			apierror.Render(c, apierror.Internal("error decoding temperature units: "+err.Error()))
+			return
+		}
+
+		c.JSON(http.StatusOK, allUnits)
+	}
+}
+
+// CreateTemperatureUnit registers a unit. The ingest token for its sensor is
+// only shown in this response.
+func CreateTemperatureUnit() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		var unit models.TemperatureUnit
+		if err := c.BindJSON(&unit); err != nil {
+			apierror.Render(c, apierror.BadRequest("Invalid request data: "+err.Error()))
+			return
+		}
+		if err := validate.Struct(unit); err != nil {
+			apierror.Render(c, apierror.FromValidation(err))
+			return
+		}
+
+		token, err := newToken()
+		if err != nil {
+			apierror.Render(c, apierror.Internal("error generating ingest token: "+err.Error()))
+			return
+		}
+		if unit.Location == nil {
+			location := defaultLocation
+			unit.Location = &location
+		}
+		if unit.Active == nil {
+			active := true
+			unit.Active = &active
+		}
+		unit.Ingest_token = token
+		unit.Last_reading_at = nil
+		unit.Created_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		unit.Updated_at = unit.Created_at
+		unit.ID = primitive.NewObjectID()
+		unit.Unit_id = unit.ID.Hex()
+
+		result, err := temperatureUnitCollection().InsertOne(ctx, unit)
+		if err != nil {
+			apierror.Render(c, apierror.Internal("Temperature unit was not created: "+err.Error()))
+			return
+		}
+
+		c.JSON(http.StatusCreated, gin.H{"message": "Temperature unit created", "data": result, "unit_id": unit.Unit_id, "ingest_token": token})
+	}
+}
+
+func UpdateTemperatureUnit() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		unitId := c.Param("unit_id")
+
+		var existing models.TemperatureUnit
+		if err := temperatureUnitCollection().FindOne(ctx, bson.M{"unit_id": unitId}).Decode(&existing); err != nil {
+			apierror.Render(c, apierror.NotFound("Temperature unit not found"))
+			return
+		}
+
+		var unit models.TemperatureUnit
+		if err := c.BindJSON(&unit); err != nil {
+			apierror.Render(c, apierror.BadRequest("Invalid request data: "+err.Error()))
+			return
+		}
+
+		var updateObj primitive.D
+		if unit.Name != nil {
+			existing.Name = unit.Name
+			updateObj = append(updateObj, bson.E{Key: "name", Value: unit.Name})
+		}
+		if unit.Min_temp != nil {
+			existing.Min_temp = unit.Min_temp
+			updateObj = append(updateObj, bson.E{Key: "min_temp", Value: unit.Min_temp})
+		}
+		if unit.Max_temp != nil {
+			existing.Max_temp = unit.Max_temp
+			updateObj = append(updateObj, bson.E{Key: "max_temp", Value: unit.Max_temp})
+		}
+		if unit.Check_interval_minutes != nil {
+			existing.Check_interval_minutes = unit.Check_interval_minutes
+			updateObj = append(updateObj, bson.E{Key: "check_interval_minutes", Value: unit.Check_interval_minutes})
+		}
+		if unit.Active != nil {
+			updateObj = append(updateObj, bson.E{Key: "active", Value: unit.Active})
+		}
+		if err := validate.Struct(existing); err != nil {
+			apierror.Render(c, apierror.FromValidation(err))
+			return
+		}
+
+		updatedAt, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		updateObj = append(updateObj, bson.E{Key: "updated_at", Value: updatedAt})
+
+		result, err := temperatureUnitCollection().UpdateOne(ctx, bson.M{"unit_id": unitId}, bson.D{{Key: "$set", Value: updateObj}})
+		if err != nil {
+			apierror.Render(c, apierror.Internal("Update failed: "+err.Error()))
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Temperature unit updated successfully", "result": result})
+	}
+}
+
+// RotateIngestToken issues a new sensor token, invalidating the old one.
+func RotateIngestToken() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		token, err := newToken()
+		if err != nil {
+			apierror.Render(c, apierror.Internal("error generating ingest token: "+err.Error()))
+			return
+		}
+
+		updatedAt, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		result, err := temperatureUnitCollection().UpdateOne(
+			ctx,
+			bson.M{"unit_id": c.Param("unit_id")},
+			bson.D{{Key: "$set", Value: bson.D{{Key: "ingest_token", Value: token}, {Key: "updated_at", Value: updatedAt}}}},
+		)
+		if err != nil {
+			apierror.Render(c, apierror.Internal("Update failed: "+err.Error()))
+			return
+		}
+		if result.MatchedCount == 0 {
+			apierror.Render(c, apierror.NotFound("Temperature unit not found"))
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"ingest_token": token})
+	}
+}
+
+// RecordTemperature logs a manual check taken by a member of staff.
+func RecordTemperature() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		var unit models.TemperatureUnit
+		if err := temperatureUnitCollection().FindOne(ctx, bson.M{"unit_id": c.Param("unit_id")}).Decode(&unit); err != nil {
+			apierror.Render(c, apierror.NotFound("Temperature unit not found"))
+			return
+		}
+
+		var reading models.TemperatureReading
+		if err := c.BindJSON(&reading); err != nil {
+			apierror.Render(c, apierror.BadRequest("Invalid request data: "+err.Error()))
+			return
+		}
+		if err := validate.Struct(reading); err != nil {
+			apierror.Render(c, apierror.FromValidation(err))
+			return
+		}
+		if reading.Recorded_by == nil {
+			apierror.Render(c, apierror.BadRequest("recorded_by is required for manual checks"))
+			return
+		}
+
+		reading.Source = "MANUAL"
+		reading.Recorded_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		if err := recordTemperatureReading(ctx, unit, &reading); err != nil {
+			apierror.Render(c, apierror.Internal("Reading was not recorded: "+err.Error()))
+			return
+		}
+
+		c.JSON(http.StatusCreated, gin.H{"message": "Reading recorded", "data": reading})
+	}
+}
+
+// IngestSensorReading accepts readings pushed by IoT probes. The unit is
+// identified by the X-Ingest-Token header rather than a user session.
+func IngestSensorReading() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		token := c.GetHeader("X-Ingest-Token")
+		if token == "" {
+			apierror.Render(c, apierror.Unauthorized("X-Ingest-Token header is required"))
+			return
+		}
+
+		var unit models.TemperatureUnit
+		if err := temperatureUnitCollection().FindOne(ctx, bson.M{"ingest_token": token}).Decode(&unit); err != nil {
+			apierror.Render(c, apierror.Unauthorized("Invalid ingest token"))
+			return
+		}
+
+		var body struct {
+			Temperature *float64   `json:"temperature" validate:"required"`
+			Recorded_at *time.Time `json:"recorded_at"`
+		}
+		if err := c.BindJSON(&body); err != nil {
+			apierror.Render(c, apierror.BadRequest("Invalid request data: "+err.Error()))
+			return
+		}
+		if err := validate.Struct(body); err != nil {
+			apierror.Render(c, apierror.FromValidation(err))
+			return
+		}
+
+		reading := models.TemperatureReading{Temperature: body.Temperature, Source: "SENSOR"}
+		reading.Recorded_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		// Probes buffer readings while offline; trust their clock unless it is in the future
+		if body.Recorded_at != nil && body.Recorded_at.Before(reading.Recorded_at) {
+			reading.Recorded_at = *body.Recorded_at
+		}
+		if err := recordTemperatureReading(ctx, unit, &reading); err != nil {
+			apierror.Render(c, apierror.Internal("Reading was not recorded: "+err.Error()))
+			return
+		}
+
+		c.JSON(http.StatusCreated, gin.H{"reading_id": reading.Reading_id, "in_range": reading.In_range})
+	}
+}
+
+func GetTemperatureReadings() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		from, to, err := parseReportRange(c)
+		if err != nil {
+			apierror.Render(c, apierror.BadRequest(err.Error()))
+			return
+		}
+
+		opts := options.Find().SetSort(bson.D{{Key: "recorded_at", Value: -1}})
+		result, err := temperatureReadingCollection().Find(ctx, bson.M{"unit_id": c.Param("unit_id"), "recorded_at": bson.M{"$gte": from, "$lt": to}}, opts)
+		if err != nil {
+			apierror.Render(c, apierror.Internal("error occurred while listing readings: "+err.Error()))
+			return
+		}
+
+		var allReadings []bson.M
+		if err = result.All(ctx, &allReadings); err != nil {
+			apierror.Render(c, apierror.Internal("error decoding readings: "+err.Error()))
+			return
+		}
+
+		c.JSON(http.StatusOK, allReadings)
+	}
+}
+
+func GetTemperatureAlerts() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		filter := bson.M{"resolved": c.Query("resolved") == "true"}
+		if unitId := c.Query("unit_id"); unitId != "" {
+			filter["unit_id"] = unitId
+		}
+
+		opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}})
+		result, err := temperatureAlertCollection().Find(ctx, filter, opts)
+		if err != nil {
+			apierror.Render(c, apierror.Internal("error occurred while listing alerts: "+err.Error()))
+			return
+		}
+
+		var allAlerts []bson.M
+		if err = result.All(ctx, &allAlerts); err != nil {
+			apierror.Render(c, apierror.Internal("error decoding alerts: "+err.Error()))
+			return
+		}
+
+		c.JSON(http.StatusOK, allAlerts)
+	}
+}
+
+// ResolveTemperatureAlert records the corrective action taken.
+func ResolveTemperatureAlert() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		var body struct {
+			Resolved_by *string `json:"resolved_by" validate:"required"`
+			Resolution  *string `json:"resolution" validate:"required,max=500"`
+		}
+		if err := c.BindJSON(&body); err != nil {
+			apierror.Render(c, apierror.BadRequest("Invalid request data: "+err.Error()))
+			return
+		}
+		if err := validate.Struct(body); err != nil {
+			apierror.Render(c, apierror.FromValidation(err))
+			return
+		}
+
+		resolvedAt, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		result, err := temperatureAlertCollection().UpdateOne(
+			ctx,
+			bson.M{"alert_id": c.Param("alert_id"), "resolved": false},
+			bson.D{{Key: "$set", Value: bson.D{
+				{Key: "resolved", Value: true},
+				{Key: "resolved_by", Value: body.Resolved_by},
+				{Key: "resolution", Value: body.Resolution},
+				{Key: "resolved_at", Value: resolvedAt},
+			}}},
+		)
+		if err != nil {
+			apierror.Render(c, apierror.Internal("Update failed: "+err.Error()))
+			return
+		}
+		if result.MatchedCount == 0 {
+			apierror.Render(c, apierror.NotFound("No open alert found"))
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Alert resolved"})
+	}
+}
+
+// ExportTemperatureLog writes the compliance log for ?from=&to= as CSV, one
+// row per reading with any corrective action taken.
+func ExportTemperatureLog() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		from, to, err := parseReportRange(c)
+		if err != nil {
+			apierror.Render(c, apierror.BadRequest(err.Error()))
+			return
+		}
+
+		unitFilter := bson.M{}
+		if location := c.Query("location"); location != "" {
+			unitFilter["location"] = location
+		}
+		unitCursor, err := temperatureUnitCollection().Find(ctx, unitFilter)
+		if err != nil {
+			apierror.Render(c, apierror.Internal("error occurred while listing temperature units: "+err.Error()))
+			return
+		}
+		var units []models.TemperatureUnit
+		if err = unitCursor.All(ctx, &units); err != nil {
+			apierror.Render(c, apierror.Internal("error decoding temperature units: "+err.Error()))
+			return
+		}
+		unitsById := map[string]models.TemperatureUnit{}
+		unitIds := []string{}
+		for _, unit := range units {
+			unitsById[unit.Unit_id] = unit
+			unitIds = append(unitIds, unit.Unit_id)
+		}
+
+		opts := options.Find().SetSort(bson.D{{Key: "recorded_at", Value: 1}})
+		cursor, err := temperatureReadingCollection().Find(ctx, bson.M{"unit_id": bson.M{"$in": unitIds}, "recorded_at": bson.M{"$gte": from, "$lt": to}}, opts)
+		if err != nil {
+			apierror.Render(c, apierror.Internal("error occurred while listing readings: "+err.Error()))
+			return
+		}
+		var readings []models.TemperatureReading
+		if err = cursor.All(ctx, &readings); err != nil {
+			apierror.Render(c, apierror.Internal("error decoding readings: "+err.Error()))
+			return
+		}
+
+		c.Header("Content-Type", "text/csv")
+		c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=haccp-temperatures-%s-%s.csv", from.Format("20060102"), to.AddDate(0, 0, -1).Format("20060102")))
+
+		writer := csv.NewWriter(c.Writer)
+		writer.Write([]string{"recorded_at", "location", "unit", "type", "temperature_c", "min_c", "max_c", "in_range", "source", "recorded_by", "corrective_action"})
+		for _, reading := range readings {
+			unit := unitsById[reading.Unit_id]
+			writer.Write([]string{
+				reading.Recorded_at.Format(time.RFC3339),
+				stringValue(unit.Location),
+				*unit.Name,
+				*unit.Type,
+				strconv.FormatFloat(*reading.Temperature, 'f', 1, 64),
+				strconv.FormatFloat(*unit.Min_temp, 'f', 1, 64),
+				strconv.FormatFloat(*unit.Max_temp, 'f', 1, 64),
+				strconv.FormatBool(reading.In_range),
+				reading.Source,
+				stringValue(reading.Recorded_by),
+				stringValue(reading.Corrective_action),
+			})
+		}
+		writer.Flush()
+	}
+}
+
+// CheckMissedTemperatureChecks raises a MISSED_CHECK alert for each active
+// unit whose last reading is older than its check interval. It runs on the
+// scheduler and only keeps one open missed-check alert per unit.
+func CheckMissedTemperatureChecks(ctx context.Context) error {
+	cursor, err := temperatureUnitCollection().Find(ctx, bson.M{"active": true})
+	if err != nil {
+		return err
+	}
+	var units []models.TemperatureUnit
+	if err = cursor.All(ctx, &units); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	for _, unit := range units {
+		lastCheck := unit.Created_at
+		if unit.Last_reading_at != nil {
+			lastCheck = *unit.Last_reading_at
+		}
+		due := lastCheck.Add(time.Duration(*unit.Check_interval_minutes) * time.Minute)
+		if now.Before(due) {
+			continue
+		}
+
+		open, err := temperatureAlertCollection().CountDocuments(ctx, bson.M{"unit_id": unit.Unit_id, "type": "MISSED_CHECK", "resolved": false})
+		if err != nil {
+			return err
+		}
+		if open > 0 {
+			continue
+		}
+		raiseTemperatureAlert(ctx, unit, "MISSED_CHECK", nil, fmt.Sprintf("%s has not been checked since %s", *unit.Name, lastCheck.Format(time.RFC3339)))
+	}
+	return nil
+}
+
+func recordTemperatureReading(ctx context.Context, unit models.TemperatureUnit, reading *models.TemperatureReading) error {
+	reading.Unit_id = unit.Unit_id
+	reading.In_range = *reading.Temperature >= *unit.Min_temp && *reading.Temperature <= *unit.Max_temp
+	reading.ID = primitive.NewObjectID()
+	reading.Reading_id = reading.ID.Hex()
+
+	if _, err := temperatureReadingCollection().InsertOne(ctx, reading); err != nil {
+		return err
+	}
+
+	_, err := temperatureUnitCollection().UpdateOne(
+		ctx,
+		bson.M{"unit_id": unit.Unit_id, "$or": bson.A{bson.M{"last_reading_at": nil}, bson.M{"last_reading_at": bson.M{"$lt": reading.Recorded_at}}}},
+		bson.D{{Key: "$set", Value: bson.D{{Key: "last_reading_at", Value: reading.Recorded_at}}}},
+	)
+	if err != nil {
+		return err
+	}
+
+	if !reading.In_range {
+		raiseTemperatureAlert(ctx, unit, "OUT_OF_RANGE", &reading.Reading_id, fmt.Sprintf("%s read %.1f°C, outside %.1f–%.1f°C", *unit.Name, *reading.Temperature, *unit.Min_temp, *unit.Max_temp))
+	}
+	return nil
+}
+
+func raiseTemperatureAlert(ctx context.Context, unit models.TemperatureUnit, kind string, readingId *string, message string) {
+	alert := models.TemperatureAlert{
+		ID:         primitive.NewObjectID(),
+		Unit_id:    unit.Unit_id,
+		Type:       kind,
+		Reading_id: readingId,
+		Message:    message,
+	}
+	alert.Created_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+	alert.Alert_id = alert.ID.Hex()
+
+	if _, err := temperatureAlertCollection().InsertOne(ctx, alert); err != nil {
+		log.Println("Error saving temperature alert:", err)
+		return
+	}
+	log.Printf("temperature alert: %s", message)
+}
+
+// openTemperatureAlertCount counts unresolved alerts for a location's units.
+func openTemperatureAlertCount(ctx context.Context, location string) (int64, error) {
+	cursor, err := temperatureUnitCollection().Find(ctx, bson.M{"location": location}, options.Find().SetProjection(bson.M{"unit_id": 1}))
+	if err != nil {
+		return 0, err
+	}
+	var units []models.TemperatureUnit
+	if err = cursor.All(ctx, &units); err != nil {
+		return 0, err
+	}
+	unitIds := []string{}
+	for _, unit := range units {
+		unitIds = append(unitIds, unit.Unit_id)
+	}
+	return temperatureAlertCollection().CountDocuments(ctx, bson.M{"unit_id": bson.M{"$in": unitIds}, "resolved": false})
+}
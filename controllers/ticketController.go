@@ -0,0 +1,344 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"restaurant-management/apierror"
+	"restaurant-management/config"
+	"restaurant-management/helpers"
+	"restaurant-management/models"
+	"sort"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var ticketCollection = db.Collection("maintenanceTicket")
+
+// ticketTransitions is the status flow a ticket may follow.
+var ticketTransitions = map[string][]string{
+	"OPEN":        {"ASSIGNED", "CANCELLED"},
+	"ASSIGNED":    {"IN_PROGRESS", "OPEN", "CANCELLED"},
+	"IN_PROGRESS": {"RESOLVED", "ASSIGNED"},
+	"RESOLVED":    {"CLOSED", "IN_PROGRESS"},
+	"CLOSED":      {},
+	"CANCELLED":   {},
+}
+
+func GetTickets() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		filter := bson.M{}
+		for _, key := range []string{"status", "priority", "assignee_id", "equipment_id", "location"} {
+			if value := c.Query(key); value != "" {
+				filter[key] = value
+			}
+		}
+
+		opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}})
+		result, err := ticketCollection().Find(ctx, filter, opts)
+		if err != nil {
+			apierror.Render(c, apierror.Internal("error occurred while listing tickets: "+err.Error()))
+			return
+		}
+
+		var allTickets []bson.M
+		if err = result.All(ctx, &allTickets); err != nil {
+			apierror.Render(c, apierror.Internal("error decoding tickets: "+err.Error()))
+			return
+		}
+
+		c.JSON(http.StatusOK, allTickets)
+	}
+}
+
+func GetTicket() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		var ticket models.MaintenanceTicket
+		if err := ticketCollection().FindOne(ctx, bson.M{"ticket_id": c.Param("ticket_id")}).Decode(&ticket); err != nil {
+			apierror.Render(c, apierror.NotFound("Ticket not found"))
+			return
+		}
+
+		c.JSON(http.StatusOK, ticket)
+	}
+}
+
+func CreateTicket() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		var ticket models.MaintenanceTicket
+		if err := c.BindJSON(&ticket); err != nil {
+			apierror.Render(c, apierror.BadRequest("Invalid request data: "+err.Error()))
+			return
+		}
+		if err := validate.Struct(ticket); err != nil {
+			apierror.Render(c, apierror.FromValidation(err))
+			return
+		}
+
+		if *ticket.Category == "EQUIPMENT" {
+			var asset models.Asset
+			if err := assetCollection().FindOne(ctx, bson.M{"asset_id": ticket.Equipment_id}).Decode(&asset); err != nil {
+				apierror.Render(c, apierror.BadRequest("equipment_id must be a registered asset"))
+				return
+			}
+			if ticket.Location == nil {
+				ticket.Location = asset.Location
+			}
+		}
+		if ticket.Location == nil {
+			location := defaultLocation
+			ticket.Location = &location
+		}
+		ticket.Created_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		ticket.Updated_at = ticket.Created_at
+		ticket.Status = "OPEN"
+		if ticket.Assignee_id != nil {
+			ticket.Status = "ASSIGNED"
+		}
+		if ticket.Photos == nil {
+			ticket.Photos = []string{}
+		}
+		ticket.Resolved_at = nil
+		ticket.History = []models.TicketEvent{{Status: ticket.Status, By: *ticket.Reported_by, At: ticket.Created_at}}
+		ticket.ID = primitive.NewObjectID()
+		ticket.Ticket_id = ticket.ID.Hex()
+
+		result, err := ticketCollection().InsertOne(ctx, ticket)
+		if err != nil {
+			apierror.Render(c, apierror.Internal("Ticket was not created: "+err.Error()))
+			return
+		}
+
+		c.JSON(http.StatusCreated, gin.H{"message": "Ticket created", "data": result, "ticket_id": ticket.Ticket_id})
+	}
+}
+
+// UpdateTicket edits a ticket's details and moves it along the status flow.
+// Every status change is appended to the ticket's history.
+func UpdateTicket() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		ticketId := c.Param("ticket_id")
+
+		var body struct {
+			Priority       *string `json:"priority" validate:"omitempty,eq=LOW|eq=MEDIUM|eq=HIGH|eq=URGENT"`
+			Assignee_id    *string `json:"assignee_id"`
+			Out_of_service *bool   `json:"out_of_service"`
+			Status         *string `json:"status"`
+			Updated_by     *string `json:"updated_by" validate:"required"`
+			Note           *string `json:"note" validate:"omitempty,max=1000"`
+		}
+		if err := c.BindJSON(&body); err != nil {
+			apierror.Render(c, apierror.BadRequest("Invalid request data: "+err.Error()))
+			return
+		}
+		if err := validate.Struct(body); err != nil {
+			apierror.Render(c, apierror.FromValidation(err))
+			return
+		}
+
+		var ticket models.MaintenanceTicket
+		if err := ticketCollection().FindOne(ctx, bson.M{"ticket_id": ticketId}).Decode(&ticket); err != nil {
+			apierror.Render(c, apierror.NotFound("Ticket not found"))
+			return
+		}
+
+		updatedAt, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		var updateObj primitive.D
+		if body.Priority != nil {
+			updateObj = append(updateObj, bson.E{Key: "priority", Value: body.Priority})
+		}
+		if body.Out_of_service != nil {
+			updateObj = append(updateObj, bson.E{Key: "out_of_service", Value: body.Out_of_service})
+		}
+
+		status := ticket.Status
+		if body.Assignee_id != nil {
+			updateObj = append(updateObj, bson.E{Key: "assignee_id", Value: body.Assignee_id})
+			if status == "OPEN" && body.Status == nil {
+				status = "ASSIGNED"
+			}
+		}
+		if body.Status != nil {
+			status = *body.Status
+		}
+		if status != ticket.Status {
+			if !ticketCanMove(ticket.Status, status) {
+				apierror.Render(c, apierror.Conflict(fmt.Sprintf("Ticket cannot move from %s to %s", ticket.Status, status)))
+				return
+			}
+			if status == "ASSIGNED" && body.Assignee_id == nil && ticket.Assignee_id == nil {
+				apierror.Render(c, apierror.BadRequest("assignee_id is required to assign a ticket"))
+				return
+			}
+			updateObj = append(updateObj, bson.E{Key: "status", Value: status})
+			switch status {
+			case "RESOLVED":
+				updateObj = append(updateObj, bson.E{Key: "resolved_at", Value: updatedAt})
+			case "IN_PROGRESS":
+				updateObj = append(updateObj, bson.E{Key: "resolved_at", Value: nil})
+			}
+		}
+		updateObj = append(updateObj, bson.E{Key: "updated_at", Value: updatedAt})
+
+		event := models.TicketEvent{Status: status, By: *body.Updated_by, Note: stringValue(body.Note), At: updatedAt}
+		result, err := ticketCollection().UpdateOne(
+			ctx,
+			bson.M{"ticket_id": ticketId, "status": ticket.Status},
+			bson.D{{Key: "$set", Value: updateObj}, {Key: "$push", Value: bson.D{{Key: "history", Value: event}}}},
+		)
+		if err != nil {
+			apierror.Render(c, apierror.Internal("Update failed: "+err.Error()))
+			return
+		}
+		if result.MatchedCount == 0 {
+			apierror.Render(c, apierror.Conflict("Ticket was changed by someone else, reload and try again"))
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Ticket updated successfully", "status": status})
+	}
+}
+
+// UploadTicketPhoto attaches a photo (multipart field "photo") to a ticket.
+func UploadTicketPhoto() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		ticketId := c.Param("ticket_id")
+		count, err := ticketCollection().CountDocuments(ctx, bson.M{"ticket_id": ticketId})
+		if err != nil || count == 0 {
+			apierror.Render(c, apierror.NotFound("Ticket not found"))
+			return
+		}
+
+		photoUrl, err := helpers.SaveUpload(c, "photo", "tickets", ticketId+"-"+primitive.NewObjectID().Hex(), helpers.ImageExtensions)
+		if err != nil {
+			apierror.Render(c, apierror.BadRequest(err.Error()))
+			return
+		}
+
+		updatedAt, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		_, err = ticketCollection().UpdateOne(
+			ctx,
+			bson.M{"ticket_id": ticketId},
+			bson.D{
+				{Key: "$push", Value: bson.D{{Key: "photos", Value: photoUrl}}},
+				{Key: "$set", Value: bson.D{{Key: "updated_at", Value: updatedAt}}},
+			},
+		)
+		if err != nil {
+			apierror.Render(c, apierror.Internal("Update failed: "+err.Error()))
+			return
+		}
+
+		c.JSON(http.StatusCreated, gin.H{"photo_url": photoUrl})
+	}
+}
+
+// GetDowntimeReport totals the hours each equipment item spent out of
+// service within ?from=&to=, clipping tickets to the range.
+func GetDowntimeReport() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		from, to, err := parseReportRange(c)
+		if err != nil {
+			apierror.Render(c, apierror.BadRequest(err.Error()))
+			return
+		}
+
+		downtime, err := equipmentDowntime(ctx, bson.M{}, from, to)
+		if err != nil {
+			apierror.Render(c, apierror.Internal("error computing downtime: "+err.Error()))
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"from": from, "to": to, "equipment": downtime})
+	}
+}
+
+type EquipmentDowntime struct {
+	Equipment_id   string  `json:"equipment_id"`
+	Tickets        int     `json:"tickets"`
+	Downtime_hours float64 `json:"downtime_hours"`
+	Open_now       bool    `json:"open_now"`
+}
+
+// equipmentDowntime sums out-of-service time per equipment item for tickets
+// matching filter that overlap [from, to).
+func equipmentDowntime(ctx context.Context, filter bson.M, from, to time.Time) ([]EquipmentDowntime, error) {
+	filter["out_of_service"] = true
+	filter["equipment_id"] = bson.M{"$nin": bson.A{nil, ""}}
+	filter["status"] = bson.M{"$ne": "CANCELLED"}
+	filter["created_at"] = bson.M{"$lt": to}
+	filter["$or"] = bson.A{bson.M{"resolved_at": nil}, bson.M{"resolved_at": bson.M{"$gte": from}}}
+
+	cursor, err := ticketCollection().Find(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	var tickets []models.MaintenanceTicket
+	if err = cursor.All(ctx, &tickets); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	byEquipment := map[string]*EquipmentDowntime{}
+	for _, ticket := range tickets {
+		start, end := ticket.Created_at, now
+		if ticket.Resolved_at != nil {
+			end = *ticket.Resolved_at
+		}
+		if start.Before(from) {
+			start = from
+		}
+		if end.After(to) {
+			end = to
+		}
+
+		entry, ok := byEquipment[*ticket.Equipment_id]
+		if !ok {
+			entry = &EquipmentDowntime{Equipment_id: *ticket.Equipment_id}
+			byEquipment[*ticket.Equipment_id] = entry
+		}
+		entry.Tickets++
+		if end.After(start) {
+			entry.Downtime_hours = toFixed(entry.Downtime_hours+end.Sub(start).Hours(), 2)
+		}
+		entry.Open_now = entry.Open_now || ticket.Resolved_at == nil
+	}
+
+	downtime := []EquipmentDowntime{}
+	for _, entry := range byEquipment {
+		downtime = append(downtime, *entry)
+	}
+	sort.Slice(downtime, func(i, j int) bool { return downtime[i].Downtime_hours > downtime[j].Downtime_hours })
+	return downtime, nil
+}
+
+func ticketCanMove(from, to string) bool {
+	for _, next := range ticketTransitions[from] {
+		if next == to {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,312 @@
+package controllers
+
+import (
+	"context"
+	"net/http"
+	"restaurant-management/apierror"
+	"restaurant-management/config"
+	"restaurant-management/models"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var timeClockCollection = db.Collection("timeClock")
+var tipDistributionCollection = db.Collection("tipDistribution")
+var overtimeRuleCollection = db.Collection("overtimeRule")
+
+func GetTimeClockRecords() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		filter := bson.M{}
+		for _, key := range []string{"employee_id", "location", "status"} {
+			if value := c.Query(key); value != "" {
+				filter[key] = value
+			}
+		}
+
+		opts := options.Find().SetSort(bson.D{{Key: "clock_in", Value: -1}})
+		result, err := timeClockCollection().Find(ctx, filter, opts)
+		if err != nil {
+			apierror.Render(c, apierror.Internal("error occurred while listing time-clock records: "+err.Error()))
+			return
+		}
+
+		var allRecords []bson.M
+		if err = result.All(ctx, &allRecords); err != nil {
+			apierror.Render(c, apierror.Internal("error decoding time-clock records: "+err.Error()))
+			return
+		}
+
+		c.JSON(http.StatusOK, allRecords)
+	}
+}
+
+func ClockIn() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		var record models.TimeClockRecord
+		if err := c.BindJSON(&record); err != nil {
+			apierror.Render(c, apierror.BadRequest("Invalid request data: "+err.Error()))
+			return
+		}
+		if err := validate.Struct(record); err != nil {
+			apierror.Render(c, apierror.FromValidation(err))
+			return
+		}
+
+		count, err := timeClockCollection().CountDocuments(ctx, bson.M{"employee_id": record.Employee_id, "status": "OPEN"})
+		if err != nil {
+			apierror.Render(c, apierror.Internal("error checking open shifts: "+err.Error()))
+			return
+		}
+		if count > 0 {
+			apierror.Render(c, apierror.Conflict("Employee is already clocked in"))
+			return
+		}
+
+		if record.Location == nil {
+			location := defaultLocation
+			record.Location = &location
+		}
+		record.Status = "OPEN"
+		record.Clock_out = nil
+		record.Approved_by = nil
+		record.Approved_at = nil
+		record.Clock_in, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		record.Created_at = record.Clock_in
+		record.Updated_at = record.Clock_in
+		record.ID = primitive.NewObjectID()
+		record.Time_clock_record_id = record.ID.Hex()
+
+		result, err := timeClockCollection().InsertOne(ctx, record)
+		if err != nil {
+			apierror.Render(c, apierror.Internal("Clock-in was not recorded: "+err.Error()))
+			return
+		}
+
+		c.JSON(http.StatusCreated, gin.H{"message": "Clocked in", "data": result, "time_clock_record_id": record.Time_clock_record_id})
+	}
+}
+
+func ClockOut() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		clockOut, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		result, err := timeClockCollection().UpdateOne(
+			ctx,
+			bson.M{"time_clock_record_id": c.Param("time_clock_record_id"), "status": "OPEN"},
+			bson.D{{Key: "$set", Value: bson.D{
+				{Key: "clock_out", Value: clockOut},
+				{Key: "status", Value: "PENDING"},
+				{Key: "updated_at", Value: clockOut},
+			}}},
+		)
+		if err != nil {
+			apierror.Render(c, apierror.Internal("Update failed: "+err.Error()))
+			return
+		}
+		if result.MatchedCount == 0 {
+			apierror.Render(c, apierror.NotFound("No open shift found"))
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Clocked out", "clock_out": clockOut})
+	}
+}
+
+// ApproveTimeClockRecord signs off a completed shift, optionally correcting
+// the punch times first. Only approved shifts are paid.
+func ApproveTimeClockRecord() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		var body struct {
+			Approved_by *string    `json:"approved_by" validate:"required"`
+			Clock_in    *time.Time `json:"clock_in"`
+			Clock_out   *time.Time `json:"clock_out"`
+		}
+		if err := c.BindJSON(&body); err != nil {
+			apierror.Render(c, apierror.BadRequest("Invalid request data: "+err.Error()))
+			return
+		}
+		if err := validate.Struct(body); err != nil {
+			apierror.Render(c, apierror.FromValidation(err))
+			return
+		}
+
+		var record models.TimeClockRecord
+		filter := bson.M{"time_clock_record_id": c.Param("time_clock_record_id"), "status": "PENDING"}
+		if err := timeClockCollection().FindOne(ctx, filter).Decode(&record); err != nil {
+			apierror.Render(c, apierror.NotFound("No completed shift awaiting approval found"))
+			return
+		}
+
+		approvedAt, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		updateObj := primitive.D{
+			{Key: "status", Value: "APPROVED"},
+			{Key: "approved_by", Value: body.Approved_by},
+			{Key: "approved_at", Value: approvedAt},
+			{Key: "updated_at", Value: approvedAt},
+		}
+		clockIn, clockOut := record.Clock_in, *record.Clock_out
+		if body.Clock_in != nil {
+			clockIn = *body.Clock_in
+			updateObj = append(updateObj, bson.E{Key: "clock_in", Value: clockIn})
+		}
+		if body.Clock_out != nil {
+			clockOut = *body.Clock_out
+			updateObj = append(updateObj, bson.E{Key: "clock_out", Value: clockOut})
+		}
+		if !clockOut.After(clockIn) {
+			apierror.Render(c, apierror.BadRequest("clock_out must be after clock_in"))
+			return
+		}
+
+		if _, err := timeClockCollection().UpdateOne(ctx, filter, bson.D{{Key: "$set", Value: updateObj}}); err != nil {
+			apierror.Render(c, apierror.Internal("Update failed: "+err.Error()))
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Shift approved"})
+	}
+}
+
+func GetTipDistributions() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		filter := bson.M{}
+		for _, key := range []string{"employee_id", "location"} {
+			if value := c.Query(key); value != "" {
+				filter[key] = value
+			}
+		}
+
+		opts := options.Find().SetSort(bson.D{{Key: "business_date", Value: -1}})
+		result, err := tipDistributionCollection().Find(ctx, filter, opts)
+		if err != nil {
+			apierror.Render(c, apierror.Internal("error occurred while listing tip distributions: "+err.Error()))
+			return
+		}
+
+		var allTips []bson.M
+		if err = result.All(ctx, &allTips); err != nil {
+			apierror.Render(c, apierror.Internal("error decoding tip distributions: "+err.Error()))
+			return
+		}
+
+		c.JSON(http.StatusOK, allTips)
+	}
+}
+
+func CreateTipDistribution() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		var tip models.TipDistribution
+		if err := c.BindJSON(&tip); err != nil {
+			apierror.Render(c, apierror.BadRequest("Invalid request data: "+err.Error()))
+			return
+		}
+		if err := validate.Struct(tip); err != nil {
+			apierror.Render(c, apierror.FromValidation(err))
+			return
+		}
+
+		if tip.Location == nil {
+			location := defaultLocation
+			tip.Location = &location
+		}
+		amount := toFixed(*tip.Amount, 2)
+		tip.Amount = &amount
+		tip.Created_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		tip.ID = primitive.NewObjectID()
+		tip.Tip_distribution_id = tip.ID.Hex()
+
+		result, err := tipDistributionCollection().InsertOne(ctx, tip)
+		if err != nil {
+			apierror.Render(c, apierror.Internal("Tip distribution was not recorded: "+err.Error()))
+			return
+		}
+
+		c.JSON(http.StatusCreated, gin.H{"message": "Tip distribution recorded", "data": result})
+	}
+}
+
+func GetOvertimeRules() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		result, err := overtimeRuleCollection().Find(ctx, bson.M{})
+		if err != nil {
+			apierror.Render(c, apierror.Internal("error occurred while listing overtime rules: "+err.Error()))
+			return
+		}
+
+		var allRules []bson.M
+		if err = result.All(ctx, &allRules); err != nil {
+			apierror.Render(c, apierror.Internal("error decoding overtime rules: "+err.Error()))
+			return
+		}
+
+		c.JSON(http.StatusOK, allRules)
+	}
+}
+
+// UpdateOvertimeRule creates or replaces the overtime rule for a location.
+func UpdateOvertimeRule() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		var rule models.OvertimeRule
+		if err := c.BindJSON(&rule); err != nil {
+			apierror.Render(c, apierror.BadRequest("Invalid request data: "+err.Error()))
+			return
+		}
+		if err := validate.Struct(rule); err != nil {
+			apierror.Render(c, apierror.FromValidation(err))
+			return
+		}
+
+		rule.Location = c.Param("location")
+		rule.Updated_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+
+		upsert := true
+		opt := options.UpdateOptions{Upsert: &upsert}
+		result, err := overtimeRuleCollection().UpdateOne(
+			ctx,
+			bson.M{"location": rule.Location},
+			bson.D{
+				{Key: "$set", Value: bson.D{
+					{Key: "daily_threshold", Value: rule.Daily_threshold},
+					{Key: "weekly_threshold", Value: rule.Weekly_threshold},
+					{Key: "multiplier", Value: rule.Multiplier},
+					{Key: "updated_at", Value: rule.Updated_at},
+				}},
+				{Key: "$setOnInsert", Value: bson.D{{Key: "_id", Value: primitive.NewObjectID()}}},
+			},
+			&opt,
+		)
+		if err != nil {
+			apierror.Render(c, apierror.Internal("Update failed: "+err.Error()))
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Overtime rule saved", "result": result})
+	}
+}
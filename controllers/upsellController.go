@@ -0,0 +1,579 @@
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"restaurant-management/apierror"
+	"restaurant-management/config"
+	"restaurant-management/models"
+	"sort"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var upsellRuleCollection = db.Collection("upsellRule")
+var upsellPromptCollection = db.Collection("upsellPrompt")
+
+// maxUpsellPrompts is the most prompts one checkout may show.
+const maxUpsellPrompts = 3
+
+func GetUpsellRules() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		opts := options.Find().SetSort(bson.D{{Key: "priority", Value: -1}})
+		result, err := upsellRuleCollection().Find(ctx, bson.M{}, opts)
+		if err != nil {
+			apierror.Render(c, apierror.Internal("error occurred while listing upsell rules: "+err.Error()))
+			return
+		}
+
+		var allRules []bson.M
+		if err = result.All(ctx, &allRules); err != nil {
+			apierror.Render(c, apierror.Internal("error decoding upsell rules: "+err.Error()))
+			return
+		}
+
+		c.JSON(http.StatusOK, allRules)
+	}
+}
+
+func CreateUpsellRule() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		var rule models.UpsellRule
+		if err := c.BindJSON(&rule); err != nil {
+			apierror.Render(c, apierror.BadRequest("Invalid request data: "+err.Error()))
+			return
+		}
+
+		if err := validate.Struct(rule); err != nil {
+			apierror.Render(c, apierror.FromValidation(err))
+			return
+		}
+
+		if err := validateUpsellRule(rule); err != nil {
+			apierror.Render(c, apierror.FromValidation(err))
+			return
+		}
+
+		if rule.Active == nil {
+			active := true
+			rule.Active = &active
+		}
+
+		now := time.Now().Format(time.RFC3339)
+		rule.Created_at, _ = time.Parse(time.RFC3339, now)
+		rule.Updated_at, _ = time.Parse(time.RFC3339, now)
+		rule.ID = primitive.NewObjectID()
+		rule.Upsell_rule_id = rule.ID.Hex()
+
+		result, insertErr := upsellRuleCollection().InsertOne(ctx, rule)
+		if insertErr != nil {
+			apierror.Render(c, apierror.Internal("Could not create upsell rule"))
+			return
+		}
+
+		c.JSON(http.StatusCreated, gin.H{"message": "Upsell rule created", "data": result})
+	}
+}
+
+func UpdateUpsellRule() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		ruleId := c.Param("upsell_rule_id")
+
+		var existing models.UpsellRule
+		if err := upsellRuleCollection().FindOne(ctx, bson.M{"upsell_rule_id": ruleId}).Decode(&existing); err != nil {
+			apierror.Render(c, apierror.NotFound("Upsell rule not found"))
+			return
+		}
+
+		var rule models.UpsellRule
+		if err := c.BindJSON(&rule); err != nil {
+			apierror.Render(c, apierror.BadRequest("Invalid request data: "+err.Error()))
+			return
+		}
+
+		var updateObj primitive.D
+		if rule.Name != nil {
+			existing.Name = rule.Name
+			updateObj = append(updateObj, bson.E{Key: "name", Value: rule.Name})
+		}
+		if rule.Cart_food_ids != nil {
+			existing.Cart_food_ids = rule.Cart_food_ids
+			updateObj = append(updateObj, bson.E{Key: "cart_food_ids", Value: rule.Cart_food_ids})
+		}
+		if rule.Cart_category != nil {
+			existing.Cart_category = rule.Cart_category
+			updateObj = append(updateObj, bson.E{Key: "cart_category", Value: rule.Cart_category})
+		}
+		if rule.Missing_food_ids != nil {
+			existing.Missing_food_ids = rule.Missing_food_ids
+			updateObj = append(updateObj, bson.E{Key: "missing_food_ids", Value: rule.Missing_food_ids})
+		}
+		if rule.Missing_category != nil {
+			existing.Missing_category = rule.Missing_category
+			updateObj = append(updateObj, bson.E{Key: "missing_category", Value: rule.Missing_category})
+		}
+		if rule.Offer_food_ids != nil {
+			existing.Offer_food_ids = rule.Offer_food_ids
+			updateObj = append(updateObj, bson.E{Key: "offer_food_ids", Value: rule.Offer_food_ids})
+		}
+		if rule.Offer_category != nil {
+			existing.Offer_category = rule.Offer_category
+			updateObj = append(updateObj, bson.E{Key: "offer_category", Value: rule.Offer_category})
+		}
+		if rule.Percent_off != nil {
+			existing.Percent_off = rule.Percent_off
+			updateObj = append(updateObj, bson.E{Key: "percent_off", Value: rule.Percent_off})
+		}
+		if rule.Message != nil {
+			existing.Message = rule.Message
+			updateObj = append(updateObj, bson.E{Key: "message", Value: rule.Message})
+		}
+		if rule.Priority != nil {
+			updateObj = append(updateObj, bson.E{Key: "priority", Value: rule.Priority})
+		}
+		if rule.Active != nil {
+			updateObj = append(updateObj, bson.E{Key: "active", Value: rule.Active})
+		}
+
+		if err := validate.Struct(existing); err != nil {
+			apierror.Render(c, apierror.FromValidation(err))
+			return
+		}
+		if err := validateUpsellRule(existing); err != nil {
+			apierror.Render(c, apierror.FromValidation(err))
+			return
+		}
+
+		updatedAt, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		updateObj = append(updateObj, bson.E{Key: "updated_at", Value: updatedAt})
+
+		result, err := upsellRuleCollection().UpdateOne(ctx, bson.M{"upsell_rule_id": ruleId}, bson.D{{Key: "$set", Value: updateObj}})
+		if err != nil {
+			apierror.Render(c, apierror.Internal("Update failed: "+err.Error()))
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Upsell rule updated successfully", "result": result})
+	}
+}
+
+// EvaluateUpsells checks the cart against the active upsell rules and
+// returns the prompts to show, recording each so its outcome can be
+// tracked. The cart is the items of order_id, if given, and food_ids.
+// Rules already answered on the order are not asked again.
+func EvaluateUpsells() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		var body struct {
+			Order_id *string  `json:"order_id"`
+			Food_ids []string `json:"food_ids"`
+			Max      *int     `json:"max" validate:"omitempty,gt=0,lte=3"`
+		}
+		if err := c.BindJSON(&body); err != nil {
+			apierror.Render(c, apierror.BadRequest("Invalid request data: "+err.Error()))
+			return
+		}
+		if err := validate.Struct(body); err != nil {
+			apierror.Render(c, apierror.FromValidation(err))
+			return
+		}
+		limit := 1
+		if body.Max != nil {
+			limit = *body.Max
+		}
+
+		cart := body.Food_ids
+		answered := map[string]bool{}
+		if body.Order_id != nil {
+			orderItems, err := orderItemsOf(ctx, *body.Order_id)
+			if err != nil {
+				apierror.Render(c, apierror.Internal("error occurred while loading order items: "+err.Error()))
+				return
+			}
+			for _, orderItem := range orderItems {
+				cart = append(cart, stringValue(orderItem.Food_id))
+			}
+
+			cursor, err := upsellPromptCollection().Find(ctx, bson.M{"order_id": body.Order_id, "status": bson.M{"$ne": "SHOWN"}})
+			if err != nil {
+				apierror.Render(c, apierror.Internal("error occurred while loading upsell prompts: "+err.Error()))
+				return
+			}
+			var prompts []models.UpsellPrompt
+			if err = cursor.All(ctx, &prompts); err != nil {
+				apierror.Render(c, apierror.Internal("error decoding upsell prompts: "+err.Error()))
+				return
+			}
+			for _, prompt := range prompts {
+				answered[prompt.Upsell_rule_id] = true
+			}
+		}
+		if len(cart) == 0 {
+			c.JSON(http.StatusOK, gin.H{"prompts": []models.UpsellPrompt{}})
+			return
+		}
+
+		prompts, err := upsellPrompts(ctx, cart, answered, limit)
+		if err != nil {
+			apierror.Render(c, apierror.Internal("error occurred while evaluating upsell rules: "+err.Error()))
+			return
+		}
+
+		shownAt, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		documents := make([]interface{}, 0, len(prompts))
+		for i := range prompts {
+			prompts[i].ID = primitive.NewObjectID()
+			prompts[i].Upsell_prompt_id = prompts[i].ID.Hex()
+			prompts[i].Order_id = body.Order_id
+			prompts[i].Status = "SHOWN"
+			prompts[i].Shown_at = shownAt
+			documents = append(documents, prompts[i])
+		}
+		if len(documents) > 0 {
+			if _, err := upsellPromptCollection().InsertMany(ctx, documents); err != nil {
+				apierror.Render(c, apierror.Internal("Could not record upsell prompts"))
+				return
+			}
+		}
+
+		c.JSON(http.StatusOK, gin.H{"prompts": prompts})
+	}
+}
+
+// AcceptUpsellPrompt records that the guest took an offer. Once the offered
+// food is rung up, its order_item_id is given so the offer's discount is
+// added to the order.
+func AcceptUpsellPrompt() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		var body struct {
+			Order_item_id *string `json:"order_item_id" validate:"required"`
+		}
+		if err := c.BindJSON(&body); err != nil {
+			apierror.Render(c, apierror.BadRequest("Invalid request data: "+err.Error()))
+			return
+		}
+		if err := validate.Struct(body); err != nil {
+			apierror.Render(c, apierror.FromValidation(err))
+			return
+		}
+
+		prompt, ok := pendingUpsellPrompt(ctx, c)
+		if !ok {
+			return
+		}
+
+		var orderItem models.OrderItem
+		if err := orderItemCollection().FindOne(ctx, bson.M{"order_item_id": body.Order_item_id}).Decode(&orderItem); err != nil {
+			apierror.Render(c, apierror.NotFound("Order item not found"))
+			return
+		}
+		if stringValue(orderItem.Food_id) != prompt.Food_id {
+			apierror.Render(c, apierror.BadRequest("Order item is not the offered food"))
+			return
+		}
+		if prompt.Order_id != nil && *prompt.Order_id != orderItem.Order_id {
+			apierror.Render(c, apierror.BadRequest("Order item is not on the prompted order"))
+			return
+		}
+
+		respondedAt, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		if prompt.Percent_off != nil {
+			var order models.Order
+			if err := orderCollection().FindOne(ctx, bson.M{"order_id": orderItem.Order_id}).Decode(&order); err != nil {
+				apierror.Render(c, apierror.NotFound("Order not found"))
+				return
+			}
+			if order.Priced_at != nil {
+				apierror.Render(c, apierror.Conflict("Order is already invoiced"))
+				return
+			}
+
+			kind, scope, reason := "UPSELL", "ITEM", prompt.Message
+			discount := models.OrderDiscount{
+				Kind:              &kind,
+				Scope:             &scope,
+				Order_item_id:     body.Order_item_id,
+				Percent_off:       prompt.Percent_off,
+				Reason:            &reason,
+				Added_at:          respondedAt,
+				Order_discount_id: prompt.Upsell_prompt_id,
+			}
+			_, err := orderCollection().UpdateOne(
+				ctx,
+				bson.M{"order_id": order.Order_id},
+				bson.D{
+					{Key: "$push", Value: bson.D{{Key: "discounts", Value: discount}}},
+					{Key: "$set", Value: bson.D{{Key: "updated_at", Value: respondedAt}}},
+				},
+			)
+			if err != nil {
+				apierror.Render(c, apierror.Internal("Update failed: "+err.Error()))
+				return
+			}
+		}
+
+		_, err := upsellPromptCollection().UpdateOne(
+			ctx,
+			bson.M{"upsell_prompt_id": prompt.Upsell_prompt_id},
+			bson.D{{Key: "$set", Value: bson.D{
+				{Key: "status", Value: "ACCEPTED"},
+				{Key: "order_id", Value: orderItem.Order_id},
+				{Key: "order_item_id", Value: body.Order_item_id},
+				{Key: "responded_at", Value: respondedAt},
+			}}},
+		)
+		if err != nil {
+			apierror.Render(c, apierror.Internal("Update failed: "+err.Error()))
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Upsell accepted"})
+	}
+}
+
+func DeclineUpsellPrompt() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		prompt, ok := pendingUpsellPrompt(ctx, c)
+		if !ok {
+			return
+		}
+
+		respondedAt, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		_, err := upsellPromptCollection().UpdateOne(
+			ctx,
+			bson.M{"upsell_prompt_id": prompt.Upsell_prompt_id},
+			bson.D{{Key: "$set", Value: bson.D{
+				{Key: "status", Value: "DECLINED"},
+				{Key: "responded_at", Value: respondedAt},
+			}}},
+		)
+		if err != nil {
+			apierror.Render(c, apierror.Internal("Update failed: "+err.Error()))
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Upsell declined"})
+	}
+}
+
+// GetUpsellReport shows per rule how often its prompt was shown, accepted
+// and declined over ?from=&to=, and what accepted offers brought in.
+func GetUpsellReport() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		from, to, err := parseReportRange(c)
+		if err != nil {
+			apierror.Render(c, apierror.BadRequest(err.Error()))
+			return
+		}
+
+		countStatus := func(status string) bson.D {
+			return bson.D{{Key: "$sum", Value: bson.D{{Key: "$cond", Value: bson.A{bson.D{{Key: "$eq", Value: bson.A{"$status", status}}}, 1, 0}}}}}
+		}
+		pipeline := mongo.Pipeline{
+			{{Key: "$match", Value: bson.D{{Key: "shown_at", Value: bson.D{{Key: "$gte", Value: from}, {Key: "$lt", Value: to}}}}}},
+			{{Key: "$group", Value: bson.D{
+				{Key: "_id", Value: "$upsell_rule_id"},
+				{Key: "shown", Value: bson.D{{Key: "$sum", Value: 1}}},
+				{Key: "accepted", Value: countStatus("ACCEPTED")},
+				{Key: "declined", Value: countStatus("DECLINED")},
+				{Key: "revenue", Value: bson.D{{Key: "$sum", Value: bson.D{{Key: "$cond", Value: bson.A{bson.D{{Key: "$eq", Value: bson.A{"$status", "ACCEPTED"}}}, "$offer_price", 0}}}}}},
+			}}},
+		}
+		cursor, err := upsellPromptCollection().Aggregate(ctx, pipeline)
+		if err != nil {
+			apierror.Render(c, apierror.Internal("error occurred while aggregating upsell prompts: "+err.Error()))
+			return
+		}
+		var rows []struct {
+			Upsell_rule_id string  `bson:"_id"`
+			Shown          int     `bson:"shown"`
+			Accepted       int     `bson:"accepted"`
+			Declined       int     `bson:"declined"`
+			Revenue        float64 `bson:"revenue"`
+		}
+		if err = cursor.All(ctx, &rows); err != nil {
+			apierror.Render(c, apierror.Internal("error decoding upsell prompts: "+err.Error()))
+			return
+		}
+
+		ruleIds := []string{}
+		for _, row := range rows {
+			ruleIds = append(ruleIds, row.Upsell_rule_id)
+		}
+		names := map[string]string{}
+		ruleCursor, err := upsellRuleCollection().Find(ctx, bson.M{"upsell_rule_id": bson.M{"$in": ruleIds}})
+		if err != nil {
+			apierror.Render(c, apierror.Internal("error occurred while loading upsell rules: "+err.Error()))
+			return
+		}
+		var rules []models.UpsellRule
+		if err = ruleCursor.All(ctx, &rules); err != nil {
+			apierror.Render(c, apierror.Internal("error decoding upsell rules: "+err.Error()))
+			return
+		}
+		for _, rule := range rules {
+			names[rule.Upsell_rule_id] = stringValue(rule.Name)
+		}
+
+		report := []gin.H{}
+		for _, row := range rows {
+			conversion := 0.0
+			if row.Shown > 0 {
+				conversion = toFixed(float64(row.Accepted)/float64(row.Shown)*100, 1)
+			}
+			report = append(report, gin.H{
+				"upsell_rule_id":     row.Upsell_rule_id,
+				"name":               names[row.Upsell_rule_id],
+				"shown":              row.Shown,
+				"accepted":           row.Accepted,
+				"declined":           row.Declined,
+				"conversion_percent": conversion,
+				"revenue":            toFixed(row.Revenue, 2),
+			})
+		}
+		sort.Slice(report, func(i, j int) bool {
+			return report[i]["conversion_percent"].(float64) > report[j]["conversion_percent"].(float64)
+		})
+
+		c.JSON(http.StatusOK, gin.H{"from": from, "to": to, "rules": report})
+	}
+}
+
+// pendingUpsellPrompt loads the prompt of the request, writing the error
+// response if it does not exist or was already answered.
+func pendingUpsellPrompt(ctx context.Context, c *gin.Context) (models.UpsellPrompt, bool) {
+	var prompt models.UpsellPrompt
+	if err := upsellPromptCollection().FindOne(ctx, bson.M{"upsell_prompt_id": c.Param("upsell_prompt_id")}).Decode(&prompt); err != nil {
+		apierror.Render(c, apierror.NotFound("Upsell prompt not found"))
+		return prompt, false
+	}
+	if prompt.Status != "SHOWN" {
+		apierror.Render(c, apierror.Conflict("Upsell prompt was already "+prompt.Status))
+		return prompt, false
+	}
+	return prompt, true
+}
+
+func validateUpsellRule(rule models.UpsellRule) error {
+	if len(rule.Cart_food_ids) == 0 && rule.Cart_category == nil {
+		return fmt.Errorf("an upsell rule needs cart_food_ids or a cart_category")
+	}
+	if len(rule.Offer_food_ids) == 0 && rule.Offer_category == nil {
+		return fmt.Errorf("an upsell rule needs offer_food_ids or an offer_category")
+	}
+	return nil
+}
+
+// upsellPrompts matches the active rules against the foods in the cart,
+// highest priority first, and returns up to limit offers, never two for
+// the same food. Rules in skip are left out.
+func upsellPrompts(ctx context.Context, cart []string, skip map[string]bool, limit int) ([]models.UpsellPrompt, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "priority", Value: -1}, {Key: "created_at", Value: 1}})
+	cursor, err := upsellRuleCollection().Find(ctx, bson.M{"active": true}, opts)
+	if err != nil {
+		return nil, err
+	}
+	var rules []models.UpsellRule
+	if err = cursor.All(ctx, &rules); err != nil {
+		return nil, err
+	}
+
+	_, categories, err := foodCategories(ctx, cart)
+	if err != nil {
+		return nil, err
+	}
+	inCart := map[string]bool{}
+	cartCategories := map[string]bool{}
+	for _, foodId := range cart {
+		inCart[foodId] = true
+		cartCategories[categories[foodId]] = true
+	}
+	hasAny := func(foodIds []string, category *string) bool {
+		for _, foodId := range foodIds {
+			if inCart[foodId] {
+				return true
+			}
+		}
+		return category != nil && cartCategories[*category]
+	}
+
+	prompts := []models.UpsellPrompt{}
+	offered := map[string]bool{}
+	for _, rule := range rules {
+		if len(prompts) == limit {
+			break
+		}
+		if skip[rule.Upsell_rule_id] || !hasAny(rule.Cart_food_ids, rule.Cart_category) || hasAny(rule.Missing_food_ids, rule.Missing_category) {
+			continue
+		}
+
+		offerIds := rule.Offer_food_ids
+		if offerIds == nil {
+			offerIds = []string{}
+		}
+		filter := bson.M{"food_id": bson.M{"$in": offerIds}}
+		if rule.Offer_category != nil {
+			menuIds, err := menuIdsInCategory(ctx, *rule.Offer_category)
+			if err != nil {
+				return nil, err
+			}
+			filter = bson.M{"$or": bson.A{filter, bson.M{"menu_id": bson.M{"$in": menuIds}}}}
+		}
+		filter["available"] = bson.M{"$ne": false}
+		cursor, err := foodCollection().Find(ctx, filter, options.Find().SetSort(bson.D{{Key: "price", Value: 1}}))
+		if err != nil {
+			return nil, err
+		}
+		var foods []models.Food
+		if err = cursor.All(ctx, &foods); err != nil {
+			return nil, err
+		}
+
+		for _, food := range foods {
+			if inCart[food.Food_id] || offered[food.Food_id] || food.Price == nil {
+				continue
+			}
+			offered[food.Food_id] = true
+			prompt := models.UpsellPrompt{
+				Upsell_rule_id: rule.Upsell_rule_id,
+				Food_id:        food.Food_id,
+				Message:        stringOr(rule.Message, "Add "+stringValue(food.Name)+"?"),
+				Price:          *food.Price,
+				Offer_price:    *food.Price,
+				Percent_off:    rule.Percent_off,
+			}
+			if rule.Percent_off != nil {
+				prompt.Offer_price = toFixed(*food.Price*(1-*rule.Percent_off/100), 2)
+			}
+			prompts = append(prompts, prompt)
+			break
+		}
+	}
+	return prompts, nil
+}
@@ -1,27 +1,327 @@
 package controllers
 
-import "github.com/gin-gonic/gin"
+import (
+	"context"
+	"net/http"
+	"restaurant-management/apierror"
+	"restaurant-management/config"
+	"restaurant-management/helpers"
+	"restaurant-management/models"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"golang.org/x/crypto/bcrypt"
+)
+
+var userCollection = db.Collection("user")
+
+// bootstrapCollection holds the marker of the first ADMIN. Its fixed _id
+// lets only one sign up claim the role.
+var bootstrapCollection = db.Collection("bootstrap")
+
+// userSecrets are never sent back when users are listed or read.
+var userSecrets = bson.M{"password": 0, "token": 0, "refresh_token": 0}
 
 func GetUsers() gin.HandlerFunc {
-	return func(c *gin.Context) {}
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		recordsPerPage, err := strconv.Atoi(c.Query("recordsPerPage"))
+		if err != nil || recordsPerPage < 1 {
+			recordsPerPage = 10
+		}
+		page, err := strconv.Atoi(c.Query("page"))
+		if err != nil || page < 1 {
+			page = 1
+		}
+
+		opts := options.Find().
+			SetSort(bson.D{{Key: "created_at", Value: 1}}).
+			SetSkip(int64((page - 1) * recordsPerPage)).
+			SetLimit(int64(recordsPerPage)).
+			SetProjection(userSecrets)
+		result, err := userCollection().Find(ctx, bson.M{}, opts)
+		if err != nil {
+			apierror.Render(c, apierror.Internal("error occurred while listing users: "+err.Error()))
+			return
+		}
+
+		var allUsers []bson.M
+		if err = result.All(ctx, &allUsers); err != nil {
+			apierror.Render(c, apierror.Internal("error decoding users: "+err.Error()))
+			return
+		}
+		totalCount, err := userCollection().CountDocuments(ctx, bson.M{})
+		if err != nil {
+			apierror.Render(c, apierror.Internal("error counting users: "+err.Error()))
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"total_count": totalCount, "user_items": allUsers})
+	}
 }
 
 func GetUser() gin.HandlerFunc {
-	return func(c *gin.Context) {}
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		var user bson.M
+		err := userCollection().FindOne(ctx, bson.M{"user_id": c.Param("user_id")}, options.FindOne().SetProjection(userSecrets)).Decode(&user)
+		if err != nil {
+			apierror.Render(c, apierror.NotFound("User not found"))
+			return
+		}
+
+		c.JSON(http.StatusOK, user)
+	}
 }
 
+// SignUp creates a user with a hashed password and returns its tokens.
+// Email and phone must not belong to another user. New users are customers,
+// except the very first, who becomes the ADMIN that assigns staff roles.
+// Concurrent first sign ups race for the bootstrap marker, so only one of
+// them is made ADMIN.
 func SignUp() gin.HandlerFunc {
-	return func(c *gin.Context) {}
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		var user models.User
+		if err := c.BindJSON(&user); err != nil {
+			apierror.Render(c, apierror.BadRequest("Invalid request data: "+err.Error()))
+			return
+		}
+		if err := validate.Struct(user); err != nil {
+			apierror.Render(c, apierror.FromValidation(err))
+			return
+		}
+
+		count, err := userCollection().CountDocuments(ctx, bson.M{"$or": []bson.M{{"email": user.Email}, {"phone": user.Phone}}})
+		if err != nil {
+			apierror.Render(c, apierror.Internal("error occurred while checking for the email and phone: "+err.Error()))
+			return
+		}
+		if count > 0 {
+			apierror.Render(c, apierror.Conflict("This email or phone number already exists"))
+			return
+		}
+
+		password, err := HashPassword(*user.Password)
+		if err != nil {
+			apierror.Render(c, apierror.Internal("Could not hash password"))
+			return
+		}
+		user.Password = &password
+
+		role := "CUSTOMER"
+		bootstrapped := false
+		if existing, err := userCollection().EstimatedDocumentCount(ctx); err == nil && existing == 0 {
+			if _, err := bootstrapCollection().InsertOne(ctx, bson.M{"_id": "admin", "created_at": time.Now()}); err == nil {
+				role = "ADMIN"
+				bootstrapped = true
+			}
+		}
+		user.Role = &role
+
+		user.Created_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		user.Updated_at, _ = time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		user.ID = primitive.NewObjectID()
+		user.User_id = user.ID.Hex()
+
+		token, refreshToken, err := helpers.GenerateAllTokens(*user.Email, *user.First_name, *user.Last_name, user.User_id, stringOr(user.Role, "CUSTOMER"))
+		if err != nil {
+			apierror.Render(c, apierror.Internal("Could not issue tokens: "+err.Error()))
+			return
+		}
+		user.Token = &token
+		user.Refresh_Token = &refreshToken
+
+		result, err := userCollection().InsertOne(ctx, user)
+		if err != nil {
+			// Give the ADMIN role back to the next sign up
+			if bootstrapped {
+				bootstrapCollection().DeleteOne(ctx, bson.M{"_id": "admin"})
+			}
+			// A concurrent sign up took the email or phone after the check
+			if mongo.IsDuplicateKeyError(err) {
+				apierror.Render(c, apierror.Conflict("This email or phone number already exists"))
+				return
+			}
+			apierror.Render(c, apierror.Internal("User was not created"))
+			return
+		}
+
+		c.JSON(http.StatusCreated, gin.H{"data": result, "user_id": user.User_id, "role": role, "token": token, "refresh_token": refreshToken})
+	}
 }
 
+// Login checks a user's email and password and issues fresh tokens.
 func Login() gin.HandlerFunc {
-	return func(c *gin.Context) {}
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		var body struct {
+			Email    *string `json:"email" validate:"required,email"`
+			Password *string `json:"password" validate:"required"`
+		}
+		if err := c.BindJSON(&body); err != nil {
+			apierror.Render(c, apierror.BadRequest("Invalid request data: "+err.Error()))
+			return
+		}
+		if err := validate.Struct(body); err != nil {
+			apierror.Render(c, apierror.FromValidation(err))
+			return
+		}
+
+		// The same answer for an unknown email and a wrong password
+		var user models.User
+		if err := userCollection().FindOne(ctx, bson.M{"email": body.Email}).Decode(&user); err != nil {
+			apierror.Render(c, apierror.Unauthorized("Email or password is incorrect"))
+			return
+		}
+		if user.Password == nil || !VerifyPassword(*user.Password, *body.Password) {
+			apierror.Render(c, apierror.Unauthorized("Email or password is incorrect"))
+			return
+		}
+
+		token, refreshToken, err := helpers.GenerateAllTokens(*user.Email, *user.First_name, *user.Last_name, user.User_id, stringOr(user.Role, "CUSTOMER"))
+		if err != nil {
+			apierror.Render(c, apierror.Internal("Could not issue tokens: "+err.Error()))
+			return
+		}
+		if err := updateAllTokens(ctx, token, refreshToken, user.User_id); err != nil {
+			apierror.Render(c, apierror.Internal("Update failed: "+err.Error()))
+			return
+		}
+
+		user.Password = nil
+		user.Token = &token
+		user.Refresh_Token = &refreshToken
+		c.JSON(http.StatusOK, user)
+	}
 }
 
-func HashPassword(password string) string {
+// RefreshToken trades a user's current refresh token for a new pair of
+// tokens. A refresh token is only honored while it is the one on file.
+func RefreshToken() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		var body struct {
+			Refresh_token *string `json:"refresh_token" validate:"required"`
+		}
+		if err := c.BindJSON(&body); err != nil {
+			apierror.Render(c, apierror.BadRequest("Invalid request data: "+err.Error()))
+			return
+		}
+		if err := validate.Struct(body); err != nil {
+			apierror.Render(c, apierror.FromValidation(err))
+			return
+		}
 
+		claims, err := helpers.ValidateToken(*body.Refresh_token)
+		if err != nil || claims.Type != "refresh" {
+			apierror.Render(c, apierror.Unauthorized("Refresh token is invalid or expired"))
+			return
+		}
+		var user models.User
+		if err := userCollection().FindOne(ctx, bson.M{"user_id": claims.Uid, "refresh_token": body.Refresh_token}).Decode(&user); err != nil {
+			apierror.Render(c, apierror.Unauthorized("Refresh token is invalid or expired"))
+			return
+		}
+
+		token, refreshToken, err := helpers.GenerateAllTokens(*user.Email, *user.First_name, *user.Last_name, user.User_id, stringOr(user.Role, "CUSTOMER"))
+		if err != nil {
+			apierror.Render(c, apierror.Internal("Could not issue tokens: "+err.Error()))
+			return
+		}
+		if err := updateAllTokens(ctx, token, refreshToken, user.User_id); err != nil {
+			apierror.Render(c, apierror.Internal("Update failed: "+err.Error()))
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"token": token, "refresh_token": refreshToken})
+	}
 }
 
-func VerifyPassword(userPassword string, providedPassword string) bool {
+// UpdateUserRole assigns a user's role. It applies from the user's next
+// login or token refresh.
+func UpdateUserRole() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		var body struct {
+			Role *string `json:"role" validate:"required,oneof=ADMIN MANAGER WAITER KITCHEN CUSTOMER"`
+		}
+		if err := c.BindJSON(&body); err != nil {
+			apierror.Render(c, apierror.BadRequest("Invalid request data: "+err.Error()))
+			return
+		}
+		if err := validate.Struct(body); err != nil {
+			apierror.Render(c, apierror.FromValidation(err))
+			return
+		}
 
+		userId := c.Param("user_id")
+		if userId == c.GetString("uid") && *body.Role != "ADMIN" {
+			apierror.Render(c, apierror.Conflict("Admins cannot take away their own role"))
+			return
+		}
+
+		updatedAt, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		result, err := userCollection().UpdateOne(
+			ctx,
+			bson.M{"user_id": userId},
+			bson.D{{Key: "$set", Value: bson.D{
+				{Key: "role", Value: body.Role},
+				{Key: "updated_at", Value: updatedAt},
+			}}},
+		)
+		if err != nil {
+			apierror.Render(c, apierror.Internal("Update failed: "+err.Error()))
+			return
+		}
+		if result.MatchedCount == 0 {
+			apierror.Render(c, apierror.NotFound("User not found"))
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Role updated", "user_id": userId, "role": body.Role})
+	}
+}
+
+func updateAllTokens(ctx context.Context, token, refreshToken, userId string) error {
+	updatedAt, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+	_, err := userCollection().UpdateOne(
+		ctx,
+		bson.M{"user_id": userId},
+		bson.D{{Key: "$set", Value: bson.D{
+			{Key: "token", Value: token},
+			{Key: "refresh_token", Value: refreshToken},
+			{Key: "updated_at", Value: updatedAt},
+		}}},
+	)
+	return err
+}
+
+func HashPassword(password string) (string, error) {
+	bytes, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+	return string(bytes), nil
+}
+
+func VerifyPassword(userPassword string, providedPassword string) bool {
+	return bcrypt.CompareHashAndPassword([]byte(userPassword), []byte(providedPassword)) == nil
 }
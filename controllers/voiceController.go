@@ -0,0 +1,462 @@
+package controllers
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"regexp"
+	"restaurant-management/apierror"
+	"restaurant-management/config"
+	"restaurant-management/helpers"
+	"restaurant-management/i18n"
+	"restaurant-management/models"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var voiceSessionCollection = db.Collection("voiceSession")
+
+// maxVoiceCount is the most of one food a single utterance may add.
+const maxVoiceCount = 20
+
+// voiceRequest is a fulfillment request with the platform details taken
+// out. Params holds the intent's parameters or slots by name.
+type voiceRequest struct {
+	platform  string
+	sessionId string
+	intent    string
+	params    map[string]string
+	phone     string
+}
+
+// DialogflowWebhook fulfills Dialogflow intents. Dialogflow is set up to
+// send the X-Voice-Token header with the value of VOICE_WEBHOOK_TOKEN.
+func DialogflowWebhook() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		expected := os.Getenv("VOICE_WEBHOOK_TOKEN")
+		if expected == "" {
+			apierror.Render(c, apierror.Unavailable("Voice ordering is not configured"))
+			return
+		}
+		if subtle.ConstantTimeCompare([]byte(c.GetHeader("X-Voice-Token")), []byte(expected)) != 1 {
+			apierror.Render(c, apierror.Unauthorized("Invalid voice token"))
+			return
+		}
+
+		var body struct {
+			Session     string `json:"session"`
+			QueryResult struct {
+				Parameters map[string]interface{} `json:"parameters"`
+				Intent     struct {
+					DisplayName string `json:"displayName"`
+				} `json:"intent"`
+			} `json:"queryResult"`
+			OriginalDetectIntentRequest struct {
+				Payload struct {
+					Telephony struct {
+						Caller_id string `json:"caller_id"`
+					} `json:"telephony"`
+				} `json:"payload"`
+			} `json:"originalDetectIntentRequest"`
+		}
+		if err := c.BindJSON(&body); err != nil {
+			apierror.Render(c, apierror.BadRequest("Invalid request data: "+err.Error()))
+			return
+		}
+
+		request := voiceRequest{
+			platform:  "DIALOGFLOW",
+			sessionId: body.Session,
+			intent:    body.QueryResult.Intent.DisplayName,
+			params:    map[string]string{},
+			phone:     body.OriginalDetectIntentRequest.Payload.Telephony.Caller_id,
+		}
+		for name, value := range body.QueryResult.Parameters {
+			if value != nil {
+				request.params[strings.ToLower(name)] = strings.TrimSpace(fmt.Sprint(value))
+			}
+		}
+
+		speech, _ := handleVoiceRequest(ctx, request)
+		c.JSON(http.StatusOK, gin.H{"fulfillmentText": speech})
+	}
+}
+
+// AlexaWebhook fulfills Alexa skill requests. Requests must be signed by
+// Alexa, recent, and come from the skill whose id is ALEXA_SKILL_ID; the
+// skill id alone is not a secret.
+func AlexaWebhook() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		skillId := os.Getenv("ALEXA_SKILL_ID")
+		if skillId == "" {
+			apierror.Render(c, apierror.Unavailable("Voice ordering is not configured"))
+			return
+		}
+
+		payload, err := c.GetRawData()
+		if err != nil {
+			apierror.Render(c, apierror.BadRequest("Invalid request data: "+err.Error()))
+			return
+		}
+		if err := helpers.VerifyAlexaRequest(ctx, c.GetHeader("SignatureCertChainUrl"), c.GetHeader("Signature-256"), payload, time.Now()); err != nil {
+			apierror.Render(c, apierror.Unauthorized("Invalid Alexa request: "+err.Error()))
+			return
+		}
+
+		var body struct {
+			Session struct {
+				SessionId   string `json:"sessionId"`
+				Application struct {
+					ApplicationId string `json:"applicationId"`
+				} `json:"application"`
+			} `json:"session"`
+			Request struct {
+				Type   string `json:"type"`
+				Intent struct {
+					Name  string `json:"name"`
+					Slots map[string]struct {
+						Value string `json:"value"`
+					} `json:"slots"`
+				} `json:"intent"`
+			} `json:"request"`
+		}
+		if err := json.Unmarshal(payload, &body); err != nil {
+			apierror.Render(c, apierror.BadRequest("Invalid request data: "+err.Error()))
+			return
+		}
+		if body.Session.Application.ApplicationId != skillId {
+			apierror.Render(c, apierror.Unauthorized("Unknown skill"))
+			return
+		}
+
+		var speech string
+		endSession := false
+		switch body.Request.Type {
+		case "LaunchRequest":
+			speech = "Welcome! What would you like to order?"
+		case "SessionEndedRequest":
+			c.JSON(http.StatusOK, gin.H{"version": "1.0", "response": gin.H{}})
+			return
+		default:
+			request := voiceRequest{
+				platform:  "ALEXA",
+				sessionId: body.Session.SessionId,
+				intent:    body.Request.Intent.Name,
+				params:    map[string]string{},
+			}
+			for name, slot := range body.Request.Intent.Slots {
+				request.params[strings.ToLower(name)] = strings.TrimSpace(slot.Value)
+			}
+			speech, endSession = handleVoiceRequest(ctx, request)
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"version": "1.0",
+			"response": gin.H{
+				"outputSpeech":     gin.H{"type": "PlainText", "text": speech},
+				"shouldEndSession": endSession,
+			},
+		})
+	}
+}
+
+// handleVoiceRequest runs an intent against the session's cart and returns
+// what to say back and whether the conversation is over. Intents are
+// matched by name: AddItem, ModifyItem, RemoveItem, ReviewCart and
+// Checkout, with the food, count and size parameters.
+func handleVoiceRequest(ctx context.Context, request voiceRequest) (string, bool) {
+	if request.sessionId == "" {
+		return "Sorry, something went wrong. Please try again.", true
+	}
+	session, err := voiceSessionFor(ctx, request)
+	if err != nil {
+		return "Sorry, something went wrong. Please try again.", true
+	}
+
+	intent := request.intent
+	if index := strings.LastIndex(intent, "."); index >= 0 {
+		intent = intent[index+1:]
+	}
+	switch strings.ToLower(intent) {
+	case "additem":
+		return addVoiceItem(ctx, &session, request.params), false
+	case "modifyitem":
+		return modifyVoiceItem(ctx, &session, request.params), false
+	case "removeitem":
+		params := map[string]string{"food": request.params["food"], "count": "0"}
+		return modifyVoiceItem(ctx, &session, params), false
+	case "reviewcart":
+		return describeVoiceCart(ctx, session), false
+	case "checkout":
+		return checkoutVoiceCart(ctx, &session)
+	case "stopintent", "cancelintent":
+		return "Goodbye!", true
+	}
+	return "Sorry, I can add items, change them, read back your order or check out.", false
+}
+
+func addVoiceItem(ctx context.Context, session *models.VoiceSession, params map[string]string) string {
+	food, speech := voiceFood(ctx, params["food"])
+	if food == nil {
+		return speech
+	}
+	count, ok := voiceCount(params["count"], 1)
+	if !ok || count == 0 {
+		return "How many would you like?"
+	}
+	size, ok := voiceSize(params["size"], "M")
+	if !ok {
+		return "Would you like that small, medium or large?"
+	}
+
+	added := false
+	for i := range session.Cart {
+		if session.Cart[i].Food_id == food.Food_id && session.Cart[i].Size == size {
+			session.Cart[i].Count = min(session.Cart[i].Count+count, maxVoiceCount)
+			added = true
+		}
+	}
+	if !added {
+		session.Cart = append(session.Cart, models.VoiceCartLine{
+			Food_id:    food.Food_id,
+			Name:       stringValue(food.Name),
+			Size:       size,
+			Count:      count,
+			Unit_price: toFixed(*food.Price, 2),
+		})
+	}
+	if err := saveVoiceSession(ctx, *session); err != nil {
+		return "Sorry, I could not update your order. Please try again."
+	}
+	return fmt.Sprintf("Added %d %s. Anything else?", count, stringValue(food.Name))
+}
+
+// modifyVoiceItem changes the count or size of a food in the cart; a count
+// of zero takes it off.
+func modifyVoiceItem(ctx context.Context, session *models.VoiceSession, params map[string]string) string {
+	food, speech := voiceFood(ctx, params["food"])
+	if food == nil {
+		return speech
+	}
+
+	index := -1
+	for i, line := range session.Cart {
+		if line.Food_id == food.Food_id {
+			index = i
+		}
+	}
+	if index < 0 {
+		return fmt.Sprintf("There is no %s in your order.", stringValue(food.Name))
+	}
+
+	count, ok := voiceCount(params["count"], session.Cart[index].Count)
+	if !ok {
+		return "How many would you like?"
+	}
+	size, ok := voiceSize(params["size"], session.Cart[index].Size)
+	if !ok {
+		return "Would you like that small, medium or large?"
+	}
+	if count == 0 {
+		session.Cart = append(session.Cart[:index], session.Cart[index+1:]...)
+		speech = fmt.Sprintf("Removed %s.", stringValue(food.Name))
+	} else {
+		session.Cart[index].Count, session.Cart[index].Size = count, size
+		speech = fmt.Sprintf("Changed to %d %s.", count, stringValue(food.Name))
+	}
+	if err := saveVoiceSession(ctx, *session); err != nil {
+		return "Sorry, I could not update your order. Please try again."
+	}
+	return speech + " Anything else?"
+}
+
+func describeVoiceCart(ctx context.Context, session models.VoiceSession) string {
+	if len(session.Cart) == 0 {
+		return "Your order is empty. What would you like?"
+	}
+	lines := []string{}
+	total := 0.0
+	for _, line := range session.Cart {
+		lines = append(lines, fmt.Sprintf("%d %s", line.Count, line.Name))
+		total += line.Unit_price * float64(line.Count)
+	}
+	return fmt.Sprintf("You have %s, about %s before tax. Say checkout when you are ready.", strings.Join(lines, ", "), voiceMoney(ctx, defaultLocation, total))
+}
+
+// checkoutVoiceCart places the cart as a takeout order through the same
+// path as the POS, so channel policies, availability and pricing apply.
+func checkoutVoiceCart(ctx context.Context, session *models.VoiceSession) (string, bool) {
+	if len(session.Cart) == 0 {
+		return "Your order is empty. What would you like?", false
+	}
+
+	channel := "TAKEOUT"
+	pack := OrderItemPack{Channel: &channel, Customer_id: session.Customer_id}
+	for _, line := range session.Cart {
+		for i := 0; i < line.Count; i++ {
+			foodId, size, price := line.Food_id, line.Size, line.Unit_price
+			pack.Order_items = append(pack.Order_items, models.OrderItem{Food_id: &foodId, Quantity: &size, Unit_price: &price})
+		}
+	}
+
+	order, _, err := placeOrder(ctx, pack, nil)
+	if rejection, ok := err.(*apierror.Error); ok {
+		if unavailable, ok := rejection.Details["unavailable"].([]UnavailableFood); ok && len(unavailable) > 0 {
+			return fmt.Sprintf("Sorry, %s is sold out. Please change your order.", unavailable[0].Name), false
+		}
+		return "Sorry, I could not place your order: " + rejection.Error() + ".", false
+	}
+	if err != nil {
+		return "Sorry, I could not place your order. Please try again.", false
+	}
+
+	session.Cart = []models.VoiceCartLine{}
+	session.Order_id = &order.Order_id
+	if err := saveVoiceSession(ctx, *session); err != nil {
+		return "Your order is placed, but I lost track of it. Please call the restaurant.", true
+	}
+
+	pricing, err := orderPricing(ctx, order)
+	if err != nil {
+		return fmt.Sprintf("Your order is placed. Your order number is %s.", voiceOrderNumber(order.Order_id)), true
+	}
+	return fmt.Sprintf("Your order is placed. The total is %s and your order number is %s.", voiceMoney(ctx, orderLocation(ctx, order), pricing.Total), voiceOrderNumber(order.Order_id)), true
+}
+
+// voiceMoney says amount the way the location's receipts print it. A
+// location whose template cannot be loaded falls back to the default
+// locale rather than failing the reply.
+func voiceMoney(ctx context.Context, location string, amount float64) string {
+	template, _ := effectiveReceiptTemplate(ctx, location)
+	locale := i18n.Get(stringValue(template.Locale))
+	currency := locale.Currency
+	if template.Currency != nil && *template.Currency != "" {
+		currency = *template.Currency
+	}
+	return locale.Money(amount, currency)
+}
+
+// voiceFood finds the available food named in an utterance: an exact name
+// match first, otherwise the only food whose name contains it. When the
+// food cannot be told apart, it returns what to ask instead.
+func voiceFood(ctx context.Context, name string) (*models.Food, string) {
+	if name == "" {
+		return nil, "Which item would you like?"
+	}
+	quoted := regexp.QuoteMeta(name)
+	for _, pattern := range []string{"^" + quoted + "$", quoted} {
+		cursor, err := foodCollection().Find(ctx, bson.M{
+			"name":      bson.M{"$regex": pattern, "$options": "i"},
+			"available": bson.M{"$ne": false},
+		}, options.Find().SetLimit(3))
+		if err != nil {
+			return nil, "Sorry, something went wrong. Please try again."
+		}
+		var foods []models.Food
+		if err = cursor.All(ctx, &foods); err != nil {
+			return nil, "Sorry, something went wrong. Please try again."
+		}
+		if len(foods) == 1 && foods[0].Price != nil {
+			return &foods[0], ""
+		}
+		if len(foods) > 1 {
+			names := []string{}
+			for _, food := range foods {
+				names = append(names, stringValue(food.Name))
+			}
+			return nil, "Did you mean " + strings.Join(names, " or ") + "?"
+		}
+	}
+	return nil, fmt.Sprintf("Sorry, we don't have %s right now.", name)
+}
+
+func voiceCount(value string, fallback int) (int, bool) {
+	if value == "" {
+		return fallback, true
+	}
+	count, err := strconv.ParseFloat(value, 64)
+	if err != nil || count < 0 || count > maxVoiceCount || count != float64(int(count)) {
+		return 0, false
+	}
+	return int(count), true
+}
+
+func voiceSize(value string, fallback string) (string, bool) {
+	switch strings.ToLower(value) {
+	case "":
+		return fallback, true
+	case "s", "small":
+		return "S", true
+	case "m", "medium", "regular":
+		return "M", true
+	case "l", "large":
+		return "L", true
+	}
+	return "", false
+}
+
+// voiceOrderNumber is the short form of an order id read out to callers.
+func voiceOrderNumber(orderId string) string {
+	if len(orderId) > 6 {
+		orderId = orderId[len(orderId)-6:]
+	}
+	return strings.Join(strings.Split(strings.ToUpper(orderId), ""), " ")
+}
+
+// voiceSessionFor loads the session of a request, starting one for a new
+// conversation. Phone callers are matched to a customer by caller id.
+func voiceSessionFor(ctx context.Context, request voiceRequest) (models.VoiceSession, error) {
+	var session models.VoiceSession
+	err := voiceSessionCollection().FindOne(ctx, bson.M{"platform": request.platform, "session_id": request.sessionId}).Decode(&session)
+	if err != mongo.ErrNoDocuments {
+		return session, err
+	}
+
+	now, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+	session = models.VoiceSession{
+		ID:         primitive.NewObjectID(),
+		Platform:   request.platform,
+		Session_id: request.sessionId,
+		Cart:       []models.VoiceCartLine{},
+		Created_at: now,
+		Updated_at: now,
+	}
+	if request.phone != "" {
+		var customer models.User
+		if err := userCollection().FindOne(ctx, bson.M{"phone": request.phone}).Decode(&customer); err == nil {
+			session.Customer_id = &customer.User_id
+		}
+	}
+	_, err = voiceSessionCollection().InsertOne(ctx, session)
+	return session, err
+}
+
+func saveVoiceSession(ctx context.Context, session models.VoiceSession) error {
+	updatedAt, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+	_, err := voiceSessionCollection().UpdateOne(
+		ctx,
+		bson.M{"_id": session.ID},
+		bson.D{{Key: "$set", Value: bson.D{
+			{Key: "cart", Value: session.Cart},
+			{Key: "order_id", Value: session.Order_id},
+			{Key: "updated_at", Value: updatedAt},
+		}}},
+	)
+	return err
+}
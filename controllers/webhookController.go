@@ -0,0 +1,321 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"restaurant-management/apierror"
+	"restaurant-management/config"
+	"restaurant-management/models"
+	"restaurant-management/webhooks"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var webhookCollection = db.Collection("webhook")
+var webhookDeliveryCollection = db.Collection("webhookDelivery")
+
+// webhookDeliveryBatch is how many due deliveries one run of the worker
+// sends.
+const webhookDeliveryBatch = 100
+
+func GetWebhooks() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		// The signing secret is only shown when the webhook is created
+		opts := options.Find().SetProjection(bson.M{"secret": 0})
+		result, err := webhookCollection().Find(ctx, bson.M{}, opts)
+		if err != nil {
+			apierror.Render(c, apierror.Internal("error occurred while listing webhooks: "+err.Error()))
+			return
+		}
+
+		var allWebhooks []bson.M
+		if err = result.All(ctx, &allWebhooks); err != nil {
+			apierror.Render(c, apierror.Internal("error decoding webhooks: "+err.Error()))
+			return
+		}
+
+		c.JSON(http.StatusOK, allWebhooks)
+	}
+}
+
+// CreateWebhook registers a URL for the listed events. A signing secret is
+// generated unless one is given, and returned only in this response.
+func CreateWebhook() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		var webhook models.Webhook
+		if err := c.BindJSON(&webhook); err != nil {
+			apierror.Render(c, apierror.BadRequest("Invalid request data: "+err.Error()))
+			return
+		}
+		if err := validate.Struct(webhook); err != nil {
+			apierror.Render(c, apierror.FromValidation(err))
+			return
+		}
+
+		if webhook.Secret == "" {
+			secret, err := webhooks.NewSecret()
+			if err != nil {
+				apierror.Render(c, apierror.Internal("Could not generate a signing secret"))
+				return
+			}
+			webhook.Secret = secret
+		}
+		if webhook.Active == nil {
+			active := true
+			webhook.Active = &active
+		}
+
+		now := time.Now().Format(time.RFC3339)
+		webhook.Created_at, _ = time.Parse(time.RFC3339, now)
+		webhook.Updated_at, _ = time.Parse(time.RFC3339, now)
+		webhook.ID = primitive.NewObjectID()
+		webhook.Webhook_id = webhook.ID.Hex()
+
+		if _, err := webhookCollection().InsertOne(ctx, webhook); err != nil {
+			apierror.Render(c, apierror.Internal("Could not create webhook"))
+			return
+		}
+
+		c.JSON(http.StatusCreated, gin.H{"message": "Webhook created", "data": webhook})
+	}
+}
+
+func UpdateWebhook() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		var webhook models.Webhook
+		if err := c.BindJSON(&webhook); err != nil {
+			apierror.Render(c, apierror.BadRequest("Invalid request data: "+err.Error()))
+			return
+		}
+
+		var updateObj primitive.D
+		if webhook.Name != nil {
+			updateObj = append(updateObj, bson.E{Key: "name", Value: webhook.Name})
+		}
+		if webhook.Url != nil {
+			if err := validate.Var(*webhook.Url, "url"); err != nil {
+				apierror.Render(c, apierror.Validation("Validation failed: url must be a URL"))
+				return
+			}
+			updateObj = append(updateObj, bson.E{Key: "url", Value: webhook.Url})
+		}
+		if webhook.Events != nil {
+			if err := validate.StructPartial(webhook, "Events"); err != nil {
+				apierror.Render(c, apierror.FromValidation(err))
+				return
+			}
+			updateObj = append(updateObj, bson.E{Key: "events", Value: webhook.Events})
+		}
+		if webhook.Secret != "" {
+			updateObj = append(updateObj, bson.E{Key: "secret", Value: webhook.Secret})
+		}
+		if webhook.Active != nil {
+			updateObj = append(updateObj, bson.E{Key: "active", Value: webhook.Active})
+		}
+
+		updatedAt, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		updateObj = append(updateObj, bson.E{Key: "updated_at", Value: updatedAt})
+
+		result, err := webhookCollection().UpdateOne(ctx, bson.M{"webhook_id": c.Param("webhook_id")}, bson.D{{Key: "$set", Value: updateObj}})
+		if err != nil {
+			apierror.Render(c, apierror.Internal("Update failed: "+err.Error()))
+			return
+		}
+		if result.MatchedCount == 0 {
+			apierror.Render(c, apierror.NotFound("Webhook not found"))
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Webhook updated successfully"})
+	}
+}
+
+// GetWebhookDeliveries lists deliveries, newest first, optionally for one
+// ?webhook_id= or with one ?status=.
+func GetWebhookDeliveries() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		filter := bson.M{}
+		if webhookId := c.Query("webhook_id"); webhookId != "" {
+			filter["webhook_id"] = webhookId
+		}
+		if status := c.Query("status"); status != "" {
+			filter["status"] = status
+		}
+
+		opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: -1}}).SetLimit(200)
+		result, err := webhookDeliveryCollection().Find(ctx, filter, opts)
+		if err != nil {
+			apierror.Render(c, apierror.Internal("error occurred while listing webhook deliveries: "+err.Error()))
+			return
+		}
+
+		var allDeliveries []bson.M
+		if err = result.All(ctx, &allDeliveries); err != nil {
+			apierror.Render(c, apierror.Internal("error decoding webhook deliveries: "+err.Error()))
+			return
+		}
+
+		c.JSON(http.StatusOK, allDeliveries)
+	}
+}
+
+// RetryWebhookDelivery queues a failed delivery again with a fresh set of
+// attempts.
+func RetryWebhookDelivery() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(context.Background(), config.Get().RequestTimeout)
+		defer cancel()
+
+		now, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		result, err := webhookDeliveryCollection().UpdateOne(
+			ctx,
+			bson.M{"webhook_delivery_id": c.Param("webhook_delivery_id"), "status": "FAILED"},
+			bson.D{{Key: "$set", Value: bson.D{
+				{Key: "status", Value: "PENDING"},
+				{Key: "attempts", Value: 0},
+				{Key: "next_attempt_at", Value: now},
+				{Key: "updated_at", Value: now},
+			}}},
+		)
+		if err != nil {
+			apierror.Render(c, apierror.Internal("Update failed: "+err.Error()))
+			return
+		}
+		if result.MatchedCount == 0 {
+			apierror.Render(c, apierror.NotFound("No failed delivery with this id"))
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Delivery queued"})
+	}
+}
+
+// queueWebhookEvent queues an event for every active webhook subscribed to
+// its type. The worker in DeliverWebhooks sends it.
+func queueWebhookEvent(ctx context.Context, eventType string, data interface{}) error {
+	cursor, err := webhookCollection().Find(ctx, bson.M{"active": true, "events": eventType}, options.Find().SetProjection(bson.M{"webhook_id": 1}))
+	if err != nil {
+		return err
+	}
+	var subscribed []models.Webhook
+	if err = cursor.All(ctx, &subscribed); err != nil {
+		return err
+	}
+	if len(subscribed) == 0 {
+		return nil
+	}
+
+	now, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+	eventId := primitive.NewObjectID().Hex()
+	payload, err := json.Marshal(gin.H{"id": eventId, "type": eventType, "created_at": now, "data": data})
+	if err != nil {
+		return err
+	}
+
+	deliveries := make([]interface{}, 0, len(subscribed))
+	for _, webhook := range subscribed {
+		delivery := models.WebhookDelivery{
+			ID:              primitive.NewObjectID(),
+			Webhook_id:      webhook.Webhook_id,
+			Event_id:        eventId,
+			Event_type:      eventType,
+			Payload:         string(payload),
+			Status:          "PENDING",
+			Next_attempt_at: now,
+			Created_at:      now,
+			Updated_at:      now,
+		}
+		delivery.Webhook_delivery_id = delivery.ID.Hex()
+		deliveries = append(deliveries, delivery)
+	}
+	_, err = webhookDeliveryCollection().InsertMany(ctx, deliveries)
+	return err
+}
+
+// DeliverWebhooks sends the deliveries that are due. A failed attempt is
+// retried with exponential backoff until webhooks.MaxAttempts, after which
+// the delivery is marked FAILED. Deliveries of webhooks since deactivated
+// or removed fail without being sent.
+func DeliverWebhooks(ctx context.Context) error {
+	now := time.Now()
+	opts := options.Find().SetSort(bson.D{{Key: "next_attempt_at", Value: 1}}).SetLimit(webhookDeliveryBatch)
+	cursor, err := webhookDeliveryCollection().Find(ctx, bson.M{"status": "PENDING", "next_attempt_at": bson.M{"$lte": now}}, opts)
+	if err != nil {
+		return err
+	}
+	var due []models.WebhookDelivery
+	if err = cursor.All(ctx, &due); err != nil {
+		return err
+	}
+
+	registered := map[string]*models.Webhook{}
+	for _, delivery := range due {
+		webhook, ok := registered[delivery.Webhook_id]
+		if !ok {
+			var found models.Webhook
+			err := webhookCollection().FindOne(ctx, bson.M{"webhook_id": delivery.Webhook_id}).Decode(&found)
+			if err != nil && err != mongo.ErrNoDocuments {
+				return err
+			}
+			if err == nil && found.Active != nil && *found.Active {
+				webhook = &found
+			}
+			registered[delivery.Webhook_id] = webhook
+		}
+
+		attemptedAt, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+		update := bson.D{{Key: "updated_at", Value: attemptedAt}}
+		if webhook == nil {
+			update = append(update, bson.E{Key: "status", Value: "FAILED"}, bson.E{Key: "last_error", Value: "webhook is inactive or was removed"})
+		} else {
+			// Push the next attempt out first so that a run overlapping this
+			// one does not send the same delivery
+			claimed, err := webhookDeliveryCollection().UpdateOne(
+				ctx,
+				bson.M{"webhook_delivery_id": delivery.Webhook_delivery_id, "status": "PENDING", "attempts": delivery.Attempts},
+				bson.D{{Key: "$set", Value: bson.D{{Key: "next_attempt_at", Value: attemptedAt.Add(webhooks.Backoff(delivery.Attempts + 1))}}}},
+			)
+			if err != nil {
+				return err
+			}
+			if claimed.ModifiedCount == 0 {
+				continue
+			}
+
+			attempts := delivery.Attempts + 1
+			response, err := webhooks.Deliver(ctx, *webhook.Url, webhook.Secret, delivery.Event_id, delivery.Event_type, []byte(delivery.Payload))
+			update = append(update, bson.E{Key: "attempts", Value: attempts}, bson.E{Key: "response_status", Value: response.Status})
+			switch {
+			case err == nil:
+				update = append(update, bson.E{Key: "status", Value: "DELIVERED"}, bson.E{Key: "delivered_at", Value: attemptedAt}, bson.E{Key: "last_error", Value: ""})
+			case attempts >= webhooks.MaxAttempts:
+				update = append(update, bson.E{Key: "status", Value: "FAILED"}, bson.E{Key: "last_error", Value: err.Error()})
+			default:
+				update = append(update, bson.E{Key: "last_error", Value: err.Error()})
+			}
+		}
+
+		if _, err := webhookDeliveryCollection().UpdateOne(ctx, bson.M{"webhook_delivery_id": delivery.Webhook_delivery_id}, bson.D{{Key: "$set", Value: update}}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -3,39 +3,71 @@ package database
 import (
 	"context"
 	"fmt"
-	"log"
-	"time"
+	"restaurant-management/config"
+	"sync/atomic"
 
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
-func DBinstance() *mongo.Client {
-	MongoDB := "mongodb://localhost:27017"
-	fmt.Println("Connecting to MongoDB:", MongoDB)
+// Connect opens a client to the configured MongoDB and checks that it
+// answers. The caller owns the client and disconnects it.
+func Connect(cfg *config.Config) (*mongo.Client, error) {
+	fmt.Println("Connecting to MongoDB")
 
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	// Defer cancel AFTER connection attempt
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.ConnectTimeout)
 	defer cancel()
 
-	client, err := mongo.Connect(ctx, options.Client().ApplyURI(MongoDB))
+	client, err := mongo.Connect(ctx, options.Client().ApplyURI(cfg.MongoURI))
 	if err != nil {
-		log.Fatal("Error connecting to MongoDB:", err)
+		return nil, fmt.Errorf("error connecting to MongoDB: %w", err)
 	}
-
-	err = client.Ping(ctx, nil)
-	if err != nil {
-		log.Fatal("Failed to ping MongoDB:", err)
+	if err := client.Ping(ctx, nil); err != nil {
+		client.Disconnect(context.Background())
+		return nil, fmt.Errorf("failed to ping MongoDB: %w", err)
 	}
 
 	fmt.Println("Connected to MongoDB successfully!")
+	return client, nil
+}
+
+func OpenCollection(client *mongo.Client, collectionName string) *mongo.Collection {
+	var collection *mongo.Collection = client.Database(config.Get().DBName).Collection(collectionName)
+	return collection
+}
+
+// Handle is the client a package looks its collections up on. Packages
+// declare their collections against a Handle when they load and main
+// binds it to the client it connected, so importing a package never
+// connects to anything.
+type Handle struct {
+	client atomic.Pointer[mongo.Client]
+}
+
+// Bind points the handle, and every collection declared on it, at client.
+func (h *Handle) Bind(client *mongo.Client) {
+	h.client.Store(client)
+}
 
+// Client is the bound client. Using a handle before Bind is a programming
+// error and panics.
+func (h *Handle) Client() *mongo.Client {
+	client := h.client.Load()
+	if client == nil {
+		panic("database: handle used before Bind")
+	}
 	return client
 }
 
-var Client *mongo.Client = DBinstance()
+// Database is the configured database on the bound client.
+func (h *Handle) Database() *mongo.Database {
+	return h.Client().Database(config.Get().DBName)
+}
 
-func OpenCollection(client *mongo.Client, collectionName string) *mongo.Collection {
-	var collection *mongo.Collection = client.Database("restaurant").Collection(collectionName)
-	return collection
+// Collection returns a function that resolves the named collection on the
+// bound client each time it is called.
+func (h *Handle) Collection(name string) func() *mongo.Collection {
+	return func() *mongo.Collection {
+		return h.Database().Collection(name)
+	}
 }
@@ -0,0 +1,180 @@
+package migrations
+
+import (
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// all are the migrations there are. Add new ones at the end with the next
+// version; never change or reorder one that has shipped.
+var all = []Migration{
+	{
+		Version:     1,
+		Description: "text index on food names and descriptions",
+		Up: createIndexes(map[string][]mongo.IndexModel{
+			"food": {{
+				Keys: bson.D{{Key: "name", Value: "text"}, {Key: "description", Value: "text"}},
+				Options: options.Index().
+					SetName("food_text").
+					SetWeights(bson.D{{Key: "name", Value: 10}, {Key: "description", Value: 1}}),
+			}},
+		}),
+	},
+	{
+		Version:     2,
+		Description: "idempotency keys unique per user and expiring",
+		Up: createIndexes(map[string][]mongo.IndexModel{
+			"idempotencyRecord": {
+				{
+					Keys:    bson.D{{Key: "user_id", Value: 1}, {Key: "key", Value: 1}},
+					Options: options.Index().SetName("idempotency_key").SetUnique(true),
+				},
+				{
+					Keys:    bson.D{{Key: "expires_at", Value: 1}},
+					Options: options.Index().SetName("idempotency_expiry").SetExpireAfterSeconds(0),
+				},
+			},
+		}),
+	},
+	{
+		Version:     3,
+		Description: "unique indexes on record ids",
+		Up: createIndexes(map[string][]mongo.IndexModel{
+			"auditLog":        {uniqueId("audit_log_id")},
+			"category":        {uniqueId("category_id")},
+			"combo":           {uniqueId("combo_id")},
+			"food":            {uniqueId("food_id")},
+			"invoice":         {uniqueId("invoice_id")},
+			"kitchenTicket":   {uniqueId("kitchen_ticket_id")},
+			"menu":            {uniqueId("menu_id")},
+			"menuVersion":     {uniqueId("menu_version_id")},
+			"modifierGroup":   {uniqueId("modifier_group_id")},
+			"order":           {uniqueId("order_id")},
+			"orderItem":       {uniqueId("order_item_id")},
+			"payment":         {uniqueId("payment_id")},
+			"reservation":     {uniqueId("reservation_id")},
+			"table":           {uniqueId("table_id")},
+			"user":            {uniqueId("user_id")},
+			"webhookDelivery": {uniqueId("webhook_delivery_id")},
+		}),
+	},
+	{
+		Version:     4,
+		Description: "compound indexes for common filters",
+		Up: createIndexes(map[string][]mongo.IndexModel{
+			"auditLog": {
+				{Keys: bson.D{{Key: "resource", Value: 1}, {Key: "resource_id", Value: 1}, {Key: "created_at", Value: -1}}},
+				{Keys: bson.D{{Key: "actor_id", Value: 1}, {Key: "created_at", Value: -1}}},
+			},
+			"food": {
+				{Keys: bson.D{{Key: "menu_id", Value: 1}, {Key: "deleted_at", Value: 1}}},
+				{Keys: bson.D{{Key: "category_id", Value: 1}, {Key: "deleted_at", Value: 1}}},
+			},
+			"invoice":       {{Keys: bson.D{{Key: "order_id", Value: 1}, {Key: "payment_status", Value: 1}}}},
+			"kitchenTicket": {{Keys: bson.D{{Key: "order_id", Value: 1}}}},
+			"menuVersion": {{
+				Keys:    bson.D{{Key: "menu_id", Value: 1}, {Key: "version", Value: -1}},
+				Options: options.Index().SetUnique(true),
+			}},
+			"order":           {{Keys: bson.D{{Key: "table_id", Value: 1}, {Key: "status", Value: 1}}}},
+			"orderItem":       {{Keys: bson.D{{Key: "order_id", Value: 1}}}},
+			"payment":         {{Keys: bson.D{{Key: "order_id", Value: 1}}}},
+			"reservation":     {{Keys: bson.D{{Key: "table_id", Value: 1}, {Key: "reserved_at", Value: 1}}}},
+			"webhookDelivery": {{Keys: bson.D{{Key: "status", Value: 1}, {Key: "next_attempt_at", Value: 1}}}},
+		}),
+	},
+	{
+		Version:     5,
+		Description: "push device tokens and refund requests",
+		Up: createIndexes(map[string][]mongo.IndexModel{
+			"device": {
+				uniqueId("device_id"),
+				{Keys: bson.D{{Key: "token", Value: 1}}, Options: options.Index().SetUnique(true)},
+				{Keys: bson.D{{Key: "user_id", Value: 1}}},
+			},
+			"refundRequest": {
+				uniqueId("refund_request_id"),
+				{Keys: bson.D{{Key: "invoice_id", Value: 1}, {Key: "status", Value: 1}}},
+			},
+		}),
+	},
+	{
+		Version:     6,
+		Description: "customer profiles and order history",
+		Up: createIndexes(map[string][]mongo.IndexModel{
+			"customer": {
+				uniqueId("customer_id"),
+				{
+					Keys: bson.D{{Key: "phone", Value: 1}},
+					Options: options.Index().SetUnique(true).
+						SetPartialFilterExpression(bson.D{{Key: "phone", Value: bson.D{{Key: "$type", Value: "string"}}}}),
+				},
+				{Keys: bson.D{{Key: "email", Value: 1}}},
+				{Keys: bson.D{{Key: "name", Value: 1}}},
+			},
+			"order": {{Keys: bson.D{{Key: "customer_id", Value: 1}, {Key: "created_at", Value: -1}}}},
+		}),
+	},
+	{
+		Version:     7,
+		Description: "loyalty ledger, one EARN entry per invoice",
+		Up: createIndexes(map[string][]mongo.IndexModel{
+			"loyaltyLedger": {
+				uniqueId("loyalty_entry_id"),
+				{Keys: bson.D{{Key: "customer_id", Value: 1}, {Key: "created_at", Value: -1}}},
+				{
+					Keys: bson.D{{Key: "invoice_id", Value: 1}},
+					Options: options.Index().SetUnique(true).
+						SetPartialFilterExpression(bson.D{{Key: "kind", Value: "EARN"}}),
+				},
+			},
+		}),
+	},
+	{
+		Version:     8,
+		Description: "reservation reminder log",
+		Up: createIndexes(map[string][]mongo.IndexModel{
+			"reservationReminder": {
+				uniqueId("reservation_reminder_id"),
+				{Keys: bson.D{{Key: "reservation_id", Value: 1}, {Key: "created_at", Value: -1}}},
+			},
+		}),
+	},
+	{
+		Version:     9,
+		Description: "one invoice per order unless the check is split",
+		Up: createIndexes(map[string][]mongo.IndexModel{
+			"invoice": {{
+				Keys: bson.D{{Key: "order_id", Value: 1}},
+				Options: options.Index().SetUnique(true).SetName("order_id_unsplit").
+					SetPartialFilterExpression(bson.D{{Key: "split", Value: bson.D{{Key: "$type", Value: "null"}}}}),
+			}},
+		}),
+	},
+	{
+		Version:     10,
+		Description: "latest unpriced check by table",
+		Up: createIndexes(map[string][]mongo.IndexModel{
+			"order": {{Keys: bson.D{{Key: "table_id", Value: 1}, {Key: "priced_at", Value: 1}, {Key: "created_at", Value: -1}}}},
+		}),
+	},
+	{
+		Version:     11,
+		Description: "one user per email and per phone",
+		Up: createIndexes(map[string][]mongo.IndexModel{
+			"user": {
+				{
+					Keys: bson.D{{Key: "email", Value: 1}},
+					Options: options.Index().SetUnique(true).
+						SetPartialFilterExpression(bson.D{{Key: "email", Value: bson.D{{Key: "$type", Value: "string"}}}}),
+				},
+				{
+					Keys: bson.D{{Key: "phone", Value: 1}},
+					Options: options.Index().SetUnique(true).
+						SetPartialFilterExpression(bson.D{{Key: "phone", Value: bson.D{{Key: "$type", Value: "string"}}}}),
+				},
+			},
+		}),
+	},
+}
@@ -0,0 +1,120 @@
+// Package migrations brings the database's indexes, and anything else
+// about its shape, up to date. Each migration has a version; a database
+// gets the ones it has not had yet, in version order, and each is recorded
+// in the schemaMigration collection once applied.
+package migrations
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Migration is one versioned change. Up must be safe to run twice, as
+// instances starting together may both apply a migration before either
+// records it; creating an index that exists is.
+type Migration struct {
+	Version     int
+	Description string
+	Up          func(ctx context.Context, db *mongo.Database) error
+}
+
+// appliedMigration is the record of a migration in schemaMigration.
+type appliedMigration struct {
+	Version     int       `bson:"_id"`
+	Description string    `bson:"description"`
+	Applied_at  time.Time `bson:"applied_at"`
+}
+
+const collectionName = "schemaMigration"
+
+// Run applies the migrations db has not had, oldest first, stopping at the
+// first that fails. It returns how many it applied.
+func Run(ctx context.Context, db *mongo.Database) (int, error) {
+	pending, err := Pending(ctx, db)
+	if err != nil {
+		return 0, err
+	}
+
+	for i, migration := range pending {
+		log.Printf("Applying migration %d: %s", migration.Version, migration.Description)
+		if err := migration.Up(ctx, db); err != nil {
+			return i, fmt.Errorf("migration %d (%s) failed: %w", migration.Version, migration.Description, err)
+		}
+		record := appliedMigration{Version: migration.Version, Description: migration.Description, Applied_at: time.Now()}
+		if _, err := db.Collection(collectionName).InsertOne(ctx, record); err != nil && !mongo.IsDuplicateKeyError(err) {
+			return i, fmt.Errorf("error recording migration %d: %w", migration.Version, err)
+		}
+	}
+	return len(pending), nil
+}
+
+// Pending lists the migrations db has not had, oldest first.
+func Pending(ctx context.Context, db *mongo.Database) ([]Migration, error) {
+	cursor, err := db.Collection(collectionName).Find(ctx, bson.M{})
+	if err != nil {
+		return nil, fmt.Errorf("error loading applied migrations: %w", err)
+	}
+	var applied []appliedMigration
+	if err := cursor.All(ctx, &applied); err != nil {
+		return nil, fmt.Errorf("error loading applied migrations: %w", err)
+	}
+	done := map[int]bool{}
+	for _, record := range applied {
+		done[record.Version] = true
+	}
+
+	pending := []Migration{}
+	for _, migration := range sorted() {
+		if !done[migration.Version] {
+			pending = append(pending, migration)
+		}
+	}
+	return pending, nil
+}
+
+// sorted returns all in version order, panicking on a repeated version,
+// which is a mistake in this package.
+func sorted() []Migration {
+	list := append([]Migration(nil), all...)
+	sort.Slice(list, func(i, j int) bool { return list[i].Version < list[j].Version })
+	for i := 1; i < len(list); i++ {
+		if list[i].Version == list[i-1].Version {
+			panic(fmt.Sprintf("migrations: version %d is used twice", list[i].Version))
+		}
+	}
+	return list
+}
+
+// createIndexes is a migration step that creates indexes on collections,
+// by collection name.
+func createIndexes(indexes map[string][]mongo.IndexModel) func(ctx context.Context, db *mongo.Database) error {
+	return func(ctx context.Context, db *mongo.Database) error {
+		names := make([]string, 0, len(indexes))
+		for name := range indexes {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			if _, err := db.Collection(name).Indexes().CreateMany(ctx, indexes[name]); err != nil {
+				return fmt.Errorf("error creating indexes on %s: %w", name, err)
+			}
+		}
+		return nil
+	}
+}
+
+// uniqueId is the unique index on the id a collection's records are
+// looked up by, such as food_id.
+func uniqueId(field string) mongo.IndexModel {
+	return mongo.IndexModel{
+		Keys:    bson.D{{Key: field, Value: 1}},
+		Options: options.Index().SetUnique(true),
+	}
+}
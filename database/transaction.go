@@ -0,0 +1,63 @@
+package database
+
+import (
+	"context"
+	"log"
+	"sync"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+var (
+	transactionsMu        sync.Mutex
+	transactionsChecked   bool
+	transactionsSupported bool
+)
+
+// SupportsTransactions reports whether the server is a replica set member
+// or a mongos, the deployments that support multi-document transactions.
+// The answer is looked up once and kept; a lookup that fails is tried again
+// on the next call.
+func SupportsTransactions(ctx context.Context, client *mongo.Client) bool {
+	transactionsMu.Lock()
+	defer transactionsMu.Unlock()
+	if transactionsChecked {
+		return transactionsSupported
+	}
+
+	var hello bson.M
+	if err := client.Database("admin").RunCommand(ctx, bson.D{{Key: "hello", Value: 1}}).Decode(&hello); err != nil {
+		// Assume a replica set, so that a server that cannot run
+		// transactions fails loudly instead of writing halfway
+		log.Println("Error checking for transaction support:", err)
+		return true
+	}
+	_, replicaSet := hello["setName"]
+	transactionsSupported = replicaSet || hello["msg"] == "isdbgrid"
+	transactionsChecked = true
+	return transactionsSupported
+}
+
+// WithTransaction runs fn in a transaction on client, so that its writes
+// all commit or none do; the driver retries fn on transient errors. Against a
+// standalone server, such as a local development mongod, there are no
+// transactions: fn runs once without one, and if it fails part way the
+// writes before the failure stay. Callers that must not leave those
+// behind check SupportsTransactions and undo them.
+func WithTransaction(ctx context.Context, client *mongo.Client, fn func(ctx context.Context) error) error {
+	if !SupportsTransactions(ctx, client) {
+		return fn(ctx)
+	}
+
+	session, err := client.StartSession()
+	if err != nil {
+		return err
+	}
+	defer session.EndSession(ctx)
+
+	_, err = session.WithTransaction(ctx, func(sessCtx mongo.SessionContext) (interface{}, error) {
+		return nil, fn(sessCtx)
+	})
+	return err
+}
@@ -0,0 +1,151 @@
+// Package extensions lets custom business logic hook into core flows
+// without changing the controllers: order creation, order pricing (and so
+// invoices) and menu rendering. Extensions register their hooks from an
+// init function, either compiled in with a blank import in main.go or
+// built as Go plugins listed in EXTENSION_PLUGINS. Hooks run in the order
+// they were registered.
+package extensions
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"plugin"
+	"restaurant-management/models"
+	"strings"
+	"sync"
+)
+
+// BeforeOrderHook runs before an order is stored. It may change the order
+// and its items, or reject the order by returning an error, which is shown
+// to the client.
+type BeforeOrderHook func(ctx context.Context, order *models.Order, items []models.OrderItem) error
+
+// AfterOrderHook runs once an order and its items are stored. Errors are
+// logged and do not undo the order.
+type AfterOrderHook func(ctx context.Context, order models.Order, items []models.OrderItem) error
+
+// PricingHook runs after an order's discounts are worked out, before its
+// total, and may add fees or change amounts. Orders are priced whenever
+// they are shown, so pricing hooks must give the same result each time.
+type PricingHook func(ctx context.Context, order models.Order, items []models.OrderItem, pricing *models.OrderPricing) error
+
+// MenuHook runs on the menu shown to a customer and returns the sections to
+// show, which it may reorder, filter or annotate.
+type MenuHook func(ctx context.Context, customerId string, sections []MenuSection) ([]MenuSection, error)
+
+// MenuSection is a category of the rendered menu with its foods as they are
+// sent to the client.
+type MenuSection struct {
+	Category string
+	Foods    []map[string]interface{}
+}
+
+var (
+	mu          sync.RWMutex
+	beforeOrder []BeforeOrderHook
+	afterOrder  []AfterOrderHook
+	pricing     []PricingHook
+	menu        []MenuHook
+)
+
+func OnBeforeOrder(hook BeforeOrderHook) {
+	mu.Lock()
+	defer mu.Unlock()
+	beforeOrder = append(beforeOrder, hook)
+}
+
+func OnAfterOrder(hook AfterOrderHook) {
+	mu.Lock()
+	defer mu.Unlock()
+	afterOrder = append(afterOrder, hook)
+}
+
+func OnPricing(hook PricingHook) {
+	mu.Lock()
+	defer mu.Unlock()
+	pricing = append(pricing, hook)
+}
+
+func OnMenuRender(hook MenuHook) {
+	mu.Lock()
+	defer mu.Unlock()
+	menu = append(menu, hook)
+}
+
+// BeforeOrder runs the before-order hooks, stopping at the first that
+// rejects the order.
+func BeforeOrder(ctx context.Context, order *models.Order, items []models.OrderItem) error {
+	mu.RLock()
+	hooks := beforeOrder
+	mu.RUnlock()
+	for _, hook := range hooks {
+		if err := hook(ctx, order, items); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// AfterOrder runs every after-order hook, logging the ones that fail.
+func AfterOrder(ctx context.Context, order models.Order, items []models.OrderItem) {
+	mu.RLock()
+	hooks := afterOrder
+	mu.RUnlock()
+	for i, hook := range hooks {
+		if err := hook(ctx, order, items); err != nil {
+			log.Printf("extensions: after-order hook %d failed for order %s: %v", i, order.Order_id, err)
+		}
+	}
+}
+
+func Pricing(ctx context.Context, order models.Order, items []models.OrderItem, orderPricing *models.OrderPricing) error {
+	mu.RLock()
+	hooks := pricing
+	mu.RUnlock()
+	for _, hook := range hooks {
+		if err := hook(ctx, order, items, orderPricing); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func RenderMenu(ctx context.Context, customerId string, sections []MenuSection) ([]MenuSection, error) {
+	mu.RLock()
+	hooks := menu
+	mu.RUnlock()
+	for _, hook := range hooks {
+		var err error
+		if sections, err = hook(ctx, customerId, sections); err != nil {
+			return nil, err
+		}
+	}
+	return sections, nil
+}
+
+// LoadPlugins opens the comma-separated Go plugins in paths. A plugin
+// registers its hooks from init; if it exports a Register function, that
+// is called too.
+func LoadPlugins(paths string) error {
+	for _, path := range strings.Split(paths, ",") {
+		path = strings.TrimSpace(path)
+		if path == "" {
+			continue
+		}
+		opened, err := plugin.Open(path)
+		if err != nil {
+			return fmt.Errorf("extensions: opening %s: %w", path, err)
+		}
+		symbol, err := opened.Lookup("Register")
+		if err != nil {
+			continue
+		}
+		register, ok := symbol.(func())
+		if !ok {
+			return fmt.Errorf("extensions: Register in %s must be a func()", path)
+		}
+		register()
+	}
+	return nil
+}
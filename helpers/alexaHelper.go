@@ -0,0 +1,150 @@
+package helpers
+
+import (
+	"context"
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	ErrAlexaSignature = errors.New("alexa request signature is invalid")
+	ErrAlexaTimestamp = errors.New("alexa request timestamp is out of range")
+)
+
+// alexaTolerance is how far a request's timestamp may be from now, as
+// required by Amazon for skills hosted outside Lambda.
+const alexaTolerance = 150 * time.Second
+
+// alexaCertName is the name Amazon's signing certificate is issued for.
+const alexaCertName = "echo-api.amazon.com"
+
+var alexaHTTPClient = &http.Client{Timeout: 10 * time.Second}
+
+// alexaCerts keeps the verified signing certificate of each chain URL, so
+// the chain is fetched once rather than on every request.
+var alexaCerts = struct {
+	sync.Mutex
+	byURL map[string]*x509.Certificate
+}{byURL: map[string]*x509.Certificate{}}
+
+// VerifyAlexaRequest checks that a skill request was sent by Alexa: the
+// certificate chain named by the SignatureCertChainUrl header is on
+// Amazon's S3 bucket, chains to a trusted root and is issued for
+// echo-api.amazon.com, the Signature-256 header is its signature of the
+// body, and the request's timestamp is within 150 seconds of now.
+func VerifyAlexaRequest(ctx context.Context, certURL, signature string, body []byte, now time.Time) error {
+	var request struct {
+		Request struct {
+			Timestamp time.Time `json:"timestamp"`
+		} `json:"request"`
+	}
+	if err := json.Unmarshal(body, &request); err != nil || request.Request.Timestamp.IsZero() {
+		return ErrAlexaTimestamp
+	}
+	if skew := now.Sub(request.Request.Timestamp); skew > alexaTolerance || skew < -alexaTolerance {
+		return ErrAlexaTimestamp
+	}
+
+	decoded, err := base64.StdEncoding.DecodeString(signature)
+	if err != nil || len(decoded) == 0 {
+		return ErrAlexaSignature
+	}
+	cert, err := alexaCert(ctx, certURL, now)
+	if err != nil {
+		return err
+	}
+	key, ok := cert.PublicKey.(*rsa.PublicKey)
+	if !ok {
+		return ErrAlexaSignature
+	}
+	digest := sha256.Sum256(body)
+	if err := rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], decoded); err != nil {
+		return ErrAlexaSignature
+	}
+	return nil
+}
+
+// alexaCert returns the signing certificate at certURL once its chain has
+// been verified, from the cache while it is still valid.
+func alexaCert(ctx context.Context, certURL string, now time.Time) (*x509.Certificate, error) {
+	if !alexaCertURL(certURL) {
+		return nil, ErrAlexaSignature
+	}
+
+	alexaCerts.Lock()
+	cert, ok := alexaCerts.byURL[certURL]
+	alexaCerts.Unlock()
+	if ok && now.After(cert.NotBefore) && now.Before(cert.NotAfter) {
+		return cert, nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, certURL, nil)
+	if err != nil {
+		return nil, ErrAlexaSignature
+	}
+	resp, err := alexaHTTPClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, ErrAlexaSignature
+	}
+	chain, err := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	if err != nil {
+		return nil, err
+	}
+
+	var certs []*x509.Certificate
+	for block, rest := pem.Decode(chain); block != nil; block, rest = pem.Decode(rest) {
+		parsed, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, ErrAlexaSignature
+		}
+		certs = append(certs, parsed)
+	}
+	if len(certs) == 0 {
+		return nil, ErrAlexaSignature
+	}
+	intermediates := x509.NewCertPool()
+	for _, intermediate := range certs[1:] {
+		intermediates.AddCert(intermediate)
+	}
+	if _, err := certs[0].Verify(x509.VerifyOptions{DNSName: alexaCertName, Intermediates: intermediates, CurrentTime: now}); err != nil {
+		return nil, ErrAlexaSignature
+	}
+
+	alexaCerts.Lock()
+	alexaCerts.byURL[certURL] = certs[0]
+	alexaCerts.Unlock()
+	return certs[0], nil
+}
+
+// alexaCertURL reports whether a chain URL is one Amazon signs from:
+// https on s3.amazonaws.com, port 443, under /echo.api/.
+func alexaCertURL(certURL string) bool {
+	parsed, err := url.Parse(certURL)
+	if err != nil {
+		return false
+	}
+	if !strings.EqualFold(parsed.Scheme, "https") || !strings.EqualFold(parsed.Hostname(), "s3.amazonaws.com") {
+		return false
+	}
+	if port := parsed.Port(); port != "" && port != "443" {
+		return false
+	}
+	return strings.HasPrefix(path.Clean(parsed.Path), "/echo.api/")
+}
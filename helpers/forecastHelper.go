@@ -0,0 +1,119 @@
+package helpers
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Dayparts recognised by the forecasting and prep endpoints, keyed by name
+// with the [start, end) hour range they cover.
+var Dayparts = map[string][2]int{
+	"BREAKFAST": {5, 11},
+	"LUNCH":     {11, 16},
+	"DINNER":    {16, 22},
+	"LATE":      {22, 29}, // wraps past midnight until 05:00
+}
+
+// DaypartOf returns the daypart a timestamp falls into.
+func DaypartOf(t time.Time) string {
+	hour := t.Hour()
+	for name, span := range Dayparts {
+		if hour >= span[0] && hour < span[1] || hour+24 >= span[0] && hour+24 < span[1] {
+			return name
+		}
+	}
+	return "LATE"
+}
+
+// SalesPoint is the number of units of a single food sold on one day.
+type SalesPoint struct {
+	Date  time.Time
+	Units float64
+}
+
+// Forecaster predicts the units expected on the target day from a
+// food's daily sales history. Days without sales may be missing from
+// the history and are treated as zero.
+type Forecaster interface {
+	Forecast(history []SalesPoint, target time.Time) float64
+	// Lookback is how many days of history the model needs.
+	Lookback() int
+}
+
+// MovingAverageForecaster averages the last Window days of sales.
+type MovingAverageForecaster struct {
+	Window int
+}
+
+func (f MovingAverageForecaster) Lookback() int {
+	return f.Window
+}
+
+func (f MovingAverageForecaster) Forecast(history []SalesPoint, target time.Time) float64 {
+	start := truncateDay(target).AddDate(0, 0, -f.Window)
+	total := 0.0
+	for _, point := range history {
+		day := truncateDay(point.Date)
+		if !day.Before(start) && day.Before(truncateDay(target)) {
+			total += point.Units
+		}
+	}
+	return total / float64(f.Window)
+}
+
+// SeasonalForecaster averages sales on the same weekday as the target
+// over the last Weeks weeks, which captures weekend/weekday swings.
+type SeasonalForecaster struct {
+	Weeks int
+}
+
+func (f SeasonalForecaster) Lookback() int {
+	return f.Weeks * 7
+}
+
+func (f SeasonalForecaster) Forecast(history []SalesPoint, target time.Time) float64 {
+	targetDay := truncateDay(target)
+	start := targetDay.AddDate(0, 0, -f.Weeks*7)
+	total := 0.0
+	for _, point := range history {
+		day := truncateDay(point.Date)
+		if !day.Before(start) && day.Before(targetDay) && day.Weekday() == targetDay.Weekday() {
+			total += point.Units
+		}
+	}
+	return total / float64(f.Weeks)
+}
+
+// forecasters maps model names to constructors taking the window size
+// (days for moving average, weeks for seasonal).
+var forecasters = map[string]func(window int) Forecaster{
+	"moving_average": func(window int) Forecaster { return MovingAverageForecaster{Window: window} },
+	"seasonal":       func(window int) Forecaster { return SeasonalForecaster{Weeks: window} },
+}
+
+// RegisterForecaster makes an additional forecasting model available by name.
+func RegisterForecaster(name string, factory func(window int) Forecaster) {
+	forecasters[name] = factory
+}
+
+// NewForecaster builds the named forecasting model.
+func NewForecaster(model string, window int) (Forecaster, error) {
+	factory, ok := forecasters[model]
+	if !ok {
+		names := make([]string, 0, len(forecasters))
+		for name := range forecasters {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return nil, fmt.Errorf("unknown forecast model %q, expected one of %v", model, names)
+	}
+	if window < 1 {
+		return nil, fmt.Errorf("forecast window must be at least 1")
+	}
+	return factory(window), nil
+}
+
+func truncateDay(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+}
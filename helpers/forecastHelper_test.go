@@ -0,0 +1,92 @@
+package helpers
+
+import (
+	"testing"
+	"time"
+)
+
+func day(d int) time.Time {
+	return time.Date(2026, time.March, d, 12, 0, 0, 0, time.UTC)
+}
+
+func TestDaypartOf(t *testing.T) {
+	tests := []struct {
+		hour int
+		want string
+	}{
+		{5, "BREAKFAST"},
+		{10, "BREAKFAST"},
+		{11, "LUNCH"},
+		{16, "DINNER"},
+		{21, "DINNER"},
+		{22, "LATE"},
+		{0, "LATE"},
+		{4, "LATE"},
+	}
+	for _, tt := range tests {
+		at := time.Date(2026, time.March, 2, tt.hour, 30, 0, 0, time.UTC)
+		if got := DaypartOf(at); got != tt.want {
+			t.Errorf("DaypartOf(%02d:30) = %s, want %s", tt.hour, got, tt.want)
+		}
+	}
+}
+
+func TestForecast(t *testing.T) {
+	// 2026-03-16 is a Monday
+	target := day(16)
+	history := []SalesPoint{
+		{Date: day(2), Units: 40}, // Monday, two weeks back
+		{Date: day(9), Units: 20}, // Monday, one week back
+		{Date: day(12), Units: 6},
+		{Date: day(14), Units: 9},
+		{Date: day(15), Units: 3},
+		{Date: day(16), Units: 100}, // the target day itself is not history
+	}
+	tests := []struct {
+		name       string
+		forecaster Forecaster
+		want       float64
+	}{
+		{"moving average over the window", MovingAverageForecaster{Window: 3}, 4},
+		{"moving average counts missing days as zero", MovingAverageForecaster{Window: 6}, 3},
+		{"seasonal takes the last weeks only", SeasonalForecaster{Weeks: 1}, 20},
+		{"seasonal averages the same weekday", SeasonalForecaster{Weeks: 2}, 30},
+		{"seasonal counts missing weeks as zero", SeasonalForecaster{Weeks: 4}, 15},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.forecaster.Forecast(history, target); got != tt.want {
+				t.Errorf("Forecast() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewForecaster(t *testing.T) {
+	tests := []struct {
+		name     string
+		model    string
+		window   int
+		want     Forecaster
+		wantFail bool
+	}{
+		{"moving average", "moving_average", 7, MovingAverageForecaster{Window: 7}, false},
+		{"seasonal", "seasonal", 4, SeasonalForecaster{Weeks: 4}, false},
+		{"unknown model", "arima", 7, nil, true},
+		{"empty window", "moving_average", 0, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := NewForecaster(tt.model, tt.window)
+			if (err != nil) != tt.wantFail {
+				t.Fatalf("NewForecaster() error = %v, want failure %v", err, tt.wantFail)
+			}
+			if got != tt.want {
+				t.Errorf("NewForecaster() = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+	if got := (SeasonalForecaster{Weeks: 4}).Lookback(); got != 28 {
+		t.Errorf("SeasonalForecaster.Lookback() = %d, want 28", got)
+	}
+}
@@ -0,0 +1,97 @@
+package helpers
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+)
+
+// Shift is one approved time-clock record.
+type Shift struct {
+	Employee_id string
+	Start       time.Time
+	End         time.Time
+	Rate        float64
+}
+
+// OvertimeRule sets when hours become overtime. A zero threshold disables
+// that check. Daily overtime is applied first and those hours do not count
+// again towards the weekly threshold.
+type OvertimeRule struct {
+	Daily_threshold  float64
+	Weekly_threshold float64
+	Multiplier       float64
+}
+
+// PayrollLine is an employee's totals for a pay period.
+type PayrollLine struct {
+	Employee_id    string  `json:"employee_id"`
+	Regular_hours  float64 `json:"regular_hours"`
+	Overtime_hours float64 `json:"overtime_hours"`
+	Regular_pay    float64 `json:"regular_pay"`
+	Overtime_pay   float64 `json:"overtime_pay"`
+	Cash_tips      float64 `json:"cash_tips"`
+	Paycheck_tips  float64 `json:"paycheck_tips"`
+}
+
+// ComputePayroll splits shifts into regular and overtime hours per employee.
+// Shifts are attributed to the day and ISO week they start in, and each
+// shift's hours are paid at that shift's rate.
+func ComputePayroll(shifts []Shift, rule OvertimeRule) []PayrollLine {
+	sorted := append([]Shift(nil), shifts...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Start.Before(sorted[j].Start) })
+
+	lines := map[string]*PayrollLine{}
+	dailyHours := map[string]float64{}
+	weeklyHours := map[string]float64{}
+	for _, shift := range sorted {
+		hours := shift.End.Sub(shift.Start).Hours()
+		if hours <= 0 {
+			continue
+		}
+		line, ok := lines[shift.Employee_id]
+		if !ok {
+			line = &PayrollLine{Employee_id: shift.Employee_id}
+			lines[shift.Employee_id] = line
+		}
+
+		year, week := shift.Start.ISOWeek()
+		dayKey := fmt.Sprintf("%s/%s", shift.Employee_id, shift.Start.Format("2006-01-02"))
+		weekKey := fmt.Sprintf("%s/%d-W%02d", shift.Employee_id, year, week)
+
+		overtime := 0.0
+		if rule.Daily_threshold > 0 {
+			overtime = math.Max(0, dailyHours[dayKey]+hours-math.Max(rule.Daily_threshold, dailyHours[dayKey]))
+		}
+		dailyHours[dayKey] += hours
+
+		regular := hours - overtime
+		if rule.Weekly_threshold > 0 {
+			weeklyOvertime := math.Max(0, weeklyHours[weekKey]+regular-math.Max(rule.Weekly_threshold, weeklyHours[weekKey]))
+			overtime += weeklyOvertime
+			regular -= weeklyOvertime
+		}
+		weeklyHours[weekKey] += regular
+
+		line.Regular_hours += regular
+		line.Overtime_hours += overtime
+		line.Regular_pay += regular * shift.Rate
+		line.Overtime_pay += overtime * shift.Rate * rule.Multiplier
+	}
+
+	result := []PayrollLine{}
+	for _, line := range lines {
+		line.Regular_hours = round2(line.Regular_hours)
+		line.Overtime_hours = round2(line.Overtime_hours)
+		line.Regular_pay = round2(line.Regular_pay)
+		line.Overtime_pay = round2(line.Overtime_pay)
+		result = append(result, *line)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Employee_id < result[j].Employee_id })
+	return result
+}
+
+func round2(value float64) float64 {
+	return math.Round(value*100) / 100
+}
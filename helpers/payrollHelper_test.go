@@ -0,0 +1,86 @@
+package helpers
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+// shift starts on the given day of March 2026 at 09:00.
+func shift(employee string, d int, hours, rate float64) Shift {
+	start := time.Date(2026, time.March, d, 9, 0, 0, 0, time.UTC)
+	return Shift{Employee_id: employee, Start: start, End: start.Add(time.Duration(hours * float64(time.Hour))), Rate: rate}
+}
+
+func TestComputePayroll(t *testing.T) {
+	daily := OvertimeRule{Daily_threshold: 8, Multiplier: 1.5}
+	weekly := OvertimeRule{Weekly_threshold: 40, Multiplier: 1.5}
+	both := OvertimeRule{Daily_threshold: 8, Weekly_threshold: 40, Multiplier: 1.5}
+
+	// 2026-03-02 is a Monday
+	tests := []struct {
+		name   string
+		shifts []Shift
+		rule   OvertimeRule
+		want   []PayrollLine
+	}{
+		{
+			"no overtime under the thresholds",
+			[]Shift{shift("a", 2, 8, 20)},
+			both,
+			[]PayrollLine{{Employee_id: "a", Regular_hours: 8, Regular_pay: 160}},
+		},
+		{
+			"daily overtime",
+			[]Shift{shift("a", 2, 10, 20)},
+			daily,
+			[]PayrollLine{{Employee_id: "a", Regular_hours: 8, Overtime_hours: 2, Regular_pay: 160, Overtime_pay: 60}},
+		},
+		{
+			"daily overtime across two shifts is paid at the later rate",
+			[]Shift{shift("a", 2, 6, 20), {Employee_id: "a", Start: time.Date(2026, time.March, 2, 16, 0, 0, 0, time.UTC), End: time.Date(2026, time.March, 2, 20, 0, 0, 0, time.UTC), Rate: 30}},
+			daily,
+			[]PayrollLine{{Employee_id: "a", Regular_hours: 8, Overtime_hours: 2, Regular_pay: 180, Overtime_pay: 90}},
+		},
+		{
+			"weekly overtime",
+			[]Shift{shift("a", 2, 9, 10), shift("a", 3, 9, 10), shift("a", 4, 9, 10), shift("a", 5, 9, 10), shift("a", 6, 9, 10)},
+			weekly,
+			[]PayrollLine{{Employee_id: "a", Regular_hours: 40, Overtime_hours: 5, Regular_pay: 400, Overtime_pay: 75}},
+		},
+		{
+			"daily overtime does not count again towards the week",
+			[]Shift{shift("a", 2, 9, 10), shift("a", 3, 9, 10), shift("a", 4, 9, 10), shift("a", 5, 9, 10), shift("a", 6, 9, 10)},
+			both,
+			[]PayrollLine{{Employee_id: "a", Regular_hours: 40, Overtime_hours: 5, Regular_pay: 400, Overtime_pay: 75}},
+		},
+		{
+			"weeks are counted separately",
+			[]Shift{shift("a", 6, 30, 10), shift("a", 9, 30, 10)},
+			weekly,
+			[]PayrollLine{{Employee_id: "a", Regular_hours: 60, Regular_pay: 600}},
+		},
+		{
+			"employees are counted separately and sorted",
+			[]Shift{shift("b", 2, 10, 10), shift("a", 2, 4, 10)},
+			daily,
+			[]PayrollLine{
+				{Employee_id: "a", Regular_hours: 4, Regular_pay: 40},
+				{Employee_id: "b", Regular_hours: 8, Overtime_hours: 2, Regular_pay: 80, Overtime_pay: 30},
+			},
+		},
+		{
+			"empty shifts are skipped",
+			[]Shift{shift("a", 2, 0, 10)},
+			both,
+			[]PayrollLine{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ComputePayroll(tt.shifts, tt.rule); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ComputePayroll() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,57 @@
+package helpers
+
+import (
+	"crypto/hmac"
+	"encoding/base64"
+	"restaurant-management/config"
+	"strconv"
+	"strings"
+)
+
+// tableTokenPurpose keeps table tokens from being valid signatures of
+// anything else signed with the same secret.
+const tableTokenPurpose = "table-qr:"
+
+// TableToken is the token printed in a table's QR code. It names the table
+// and the version of its QR code and is signed with the JWT secret, so
+// guests cannot forge one for another table. It does not expire, but
+// stops working once the table's QR code is regenerated.
+func TableToken(tableId string, version int) string {
+	named := tableId
+	if version > 0 {
+		named += ":" + strconv.Itoa(version)
+	}
+	payload := base64.RawURLEncoding.EncodeToString([]byte(named))
+	signature := sign(config.Get().JWTSecret, tableTokenPurpose+payload)
+	return payload + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+// ValidateTableToken checks the signature of a table token and returns the
+// table id and QR code version it names. Tokens printed before QR codes
+// had versions are version 0.
+func ValidateTableToken(token string) (string, int, error) {
+	payload, encoded, ok := strings.Cut(token, ".")
+	if !ok {
+		return "", 0, ErrInvalidToken
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil || !hmac.Equal(signature, sign(config.Get().JWTSecret, tableTokenPurpose+payload)) {
+		return "", 0, ErrInvalidToken
+	}
+	named, err := base64.RawURLEncoding.DecodeString(payload)
+	if err != nil {
+		return "", 0, ErrInvalidToken
+	}
+	tableId, version, versioned := strings.Cut(string(named), ":")
+	if tableId == "" {
+		return "", 0, ErrInvalidToken
+	}
+	if !versioned {
+		return tableId, 0, nil
+	}
+	number, err := strconv.Atoi(version)
+	if err != nil || number <= 0 {
+		return "", 0, ErrInvalidToken
+	}
+	return tableId, number, nil
+}
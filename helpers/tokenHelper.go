@@ -0,0 +1,116 @@
+package helpers
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"restaurant-management/config"
+	"strings"
+	"time"
+)
+
+// AccessTokenTTL and RefreshTokenTTL are how long issued tokens are valid.
+const (
+	AccessTokenTTL  = 24 * time.Hour
+	RefreshTokenTTL = 7 * 24 * time.Hour
+)
+
+var (
+	ErrInvalidToken = errors.New("token is invalid")
+	ErrExpiredToken = errors.New("token has expired")
+)
+
+// SignedDetails are the claims of the JWTs issued at sign up and login.
+// Type is "access" or "refresh"; only access tokens open protected routes.
+type SignedDetails struct {
+	Email      string `json:"email"`
+	First_name string `json:"first_name"`
+	Last_name  string `json:"last_name"`
+	Uid        string `json:"uid"`
+	Role       string `json:"role"`
+	Type       string `json:"typ"`
+	IssuedAt   int64  `json:"iat"`
+	ExpiresAt  int64  `json:"exp"`
+}
+
+var jwtHeader = base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"HS256","typ":"JWT"}`))
+
+// GenerateAllTokens issues an access token and a refresh token for a user,
+// signed with HS256 and the configured JWT secret. The role is
+// carried in the access token, so a role change applies from the next
+// login or refresh.
+func GenerateAllTokens(email, firstName, lastName, uid, role string) (string, string, error) {
+	now := time.Now()
+	claims := SignedDetails{
+		Email:      email,
+		First_name: firstName,
+		Last_name:  lastName,
+		Uid:        uid,
+		Role:       role,
+		Type:       "access",
+		IssuedAt:   now.Unix(),
+		ExpiresAt:  now.Add(AccessTokenTTL).Unix(),
+	}
+	token, err := signToken(claims)
+	if err != nil {
+		return "", "", err
+	}
+
+	refreshClaims := SignedDetails{
+		Uid:       uid,
+		Type:      "refresh",
+		IssuedAt:  now.Unix(),
+		ExpiresAt: now.Add(RefreshTokenTTL).Unix(),
+	}
+	refreshToken, err := signToken(refreshClaims)
+	if err != nil {
+		return "", "", err
+	}
+	return token, refreshToken, nil
+}
+
+// ValidateToken checks the signature and expiry of a token and returns its
+// claims.
+func ValidateToken(signedToken string) (*SignedDetails, error) {
+	secret := config.Get().JWTSecret
+
+	parts := strings.Split(signedToken, ".")
+	if len(parts) != 3 || parts[0] != jwtHeader {
+		return nil, ErrInvalidToken
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil || !hmac.Equal(signature, sign(secret, parts[0]+"."+parts[1])) {
+		return nil, ErrInvalidToken
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+	var claims SignedDetails
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return nil, ErrInvalidToken
+	}
+	if time.Now().Unix() >= claims.ExpiresAt {
+		return nil, ErrExpiredToken
+	}
+	return &claims, nil
+}
+
+func signToken(claims SignedDetails) (string, error) {
+	secret := config.Get().JWTSecret
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	unsigned := jwtHeader + "." + base64.RawURLEncoding.EncodeToString(payload)
+	return unsigned + "." + base64.RawURLEncoding.EncodeToString(sign(secret, unsigned)), nil
+}
+
+func sign(secret, unsigned string) []byte {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(unsigned))
+	return mac.Sum(nil)
+}
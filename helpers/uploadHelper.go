@@ -0,0 +1,52 @@
+package helpers
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ImageExtensions are accepted for photo uploads; DocumentExtensions also
+// allow scanned PDFs.
+var (
+	ImageExtensions    = map[string]bool{".jpg": true, ".jpeg": true, ".png": true}
+	DocumentExtensions = map[string]bool{".jpg": true, ".jpeg": true, ".png": true, ".pdf": true}
+)
+
+const MaxUploadSize = 10 << 20
+
+// UploadRoot is the directory uploads are written to, served under /uploads.
+func UploadRoot() string {
+	if dir := os.Getenv("UPLOAD_DIR"); dir != "" {
+		return dir
+	}
+	return "uploads"
+}
+
+// SaveUpload stores the multipart file in field under UploadRoot()/kind as
+// name plus the file's extension and returns its public URL.
+func SaveUpload(c *gin.Context, field, kind, name string, allowed map[string]bool) (string, error) {
+	file, err := c.FormFile(field)
+	if err != nil {
+		return "", fmt.Errorf("%s file is required", field)
+	}
+	if file.Size > MaxUploadSize {
+		return "", fmt.Errorf("%s must be 10MB or smaller", field)
+	}
+	ext := strings.ToLower(filepath.Ext(file.Filename))
+	if !allowed[ext] {
+		return "", fmt.Errorf("%s has an unsupported file type", field)
+	}
+
+	dir := filepath.Join(UploadRoot(), kind)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", err
+	}
+	if err := c.SaveUploadedFile(file, filepath.Join(dir, name+ext)); err != nil {
+		return "", err
+	}
+	return "/uploads/" + kind + "/" + name + ext, nil
+}
@@ -0,0 +1,35 @@
+package i18n
+
+// Currency describes how amounts in an ISO 4217 currency are written.
+type Currency struct {
+	Code     string
+	Symbol   string
+	Decimals int
+}
+
+var currencies = map[string]Currency{
+	"USD": {Code: "USD", Symbol: "$", Decimals: 2},
+	"CAD": {Code: "CAD", Symbol: "$", Decimals: 2},
+	"AUD": {Code: "AUD", Symbol: "$", Decimals: 2},
+	"NZD": {Code: "NZD", Symbol: "$", Decimals: 2},
+	"MXN": {Code: "MXN", Symbol: "$", Decimals: 2},
+	"EUR": {Code: "EUR", Symbol: "€", Decimals: 2},
+	"GBP": {Code: "GBP", Symbol: "£", Decimals: 2},
+	"CHF": {Code: "CHF", Symbol: "CHF", Decimals: 2},
+	"INR": {Code: "INR", Symbol: "Rs.", Decimals: 2},
+	"JPY": {Code: "JPY", Symbol: "¥", Decimals: 0},
+}
+
+func LookupCurrency(code string) (Currency, bool) {
+	currency, ok := currencies[code]
+	return currency, ok
+}
+
+// GetCurrency returns the registered currency, or one that prints its ISO
+// code with two decimals if code is unknown.
+func GetCurrency(code string) Currency {
+	if currency, ok := currencies[code]; ok {
+		return currency
+	}
+	return Currency{Code: code, Symbol: code, Decimals: 2}
+}
@@ -0,0 +1,168 @@
+// Package i18n formats money, numbers, dates and receipt labels for the
+// locale and currency a location operates in.
+package i18n
+
+import (
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Locale holds the formatting conventions of a region.
+type Locale struct {
+	Code            string
+	Language        string
+	Currency        string
+	Decimal         string
+	Group           string
+	Grouping        []int
+	Symbol_first    bool
+	Symbol_space    bool
+	Date_format     string
+	Datetime_format string
+	Tax_label       string
+	Tax_id_label    string
+}
+
+// DefaultLocale is used when a location has not chosen one.
+const DefaultLocale = "en-US"
+
+var locales = map[string]Locale{
+	"en-US": {Code: "en-US", Language: "en", Currency: "USD", Decimal: ".", Group: ",", Grouping: []int{3}, Symbol_first: true,
+		Date_format: "01/02/2006", Datetime_format: "01/02/2006 3:04 PM", Tax_label: "Sales tax", Tax_id_label: "Tax ID"},
+	"en-CA": {Code: "en-CA", Language: "en", Currency: "CAD", Decimal: ".", Group: ",", Grouping: []int{3}, Symbol_first: true,
+		Date_format: "2006-01-02", Datetime_format: "2006-01-02 3:04 PM", Tax_label: "GST/HST", Tax_id_label: "GST/HST No."},
+	"fr-CA": {Code: "fr-CA", Language: "fr", Currency: "CAD", Decimal: ",", Group: " ", Grouping: []int{3}, Symbol_space: true,
+		Date_format: "2006-01-02", Datetime_format: "2006-01-02 15:04", Tax_label: "TPS/TVH", Tax_id_label: "No TPS/TVH"},
+	"en-GB": {Code: "en-GB", Language: "en", Currency: "GBP", Decimal: ".", Group: ",", Grouping: []int{3}, Symbol_first: true,
+		Date_format: "02/01/2006", Datetime_format: "02/01/2006 15:04", Tax_label: "VAT", Tax_id_label: "VAT No."},
+	"en-IE": {Code: "en-IE", Language: "en", Currency: "EUR", Decimal: ".", Group: ",", Grouping: []int{3}, Symbol_first: true,
+		Date_format: "02/01/2006", Datetime_format: "02/01/2006 15:04", Tax_label: "VAT", Tax_id_label: "VAT No."},
+	"en-AU": {Code: "en-AU", Language: "en", Currency: "AUD", Decimal: ".", Group: ",", Grouping: []int{3}, Symbol_first: true,
+		Date_format: "02/01/2006", Datetime_format: "02/01/2006 3:04 PM", Tax_label: "GST", Tax_id_label: "ABN"},
+	"en-NZ": {Code: "en-NZ", Language: "en", Currency: "NZD", Decimal: ".", Group: ",", Grouping: []int{3}, Symbol_first: true,
+		Date_format: "02/01/2006", Datetime_format: "02/01/2006 3:04 PM", Tax_label: "GST", Tax_id_label: "GST No."},
+	"en-IN": {Code: "en-IN", Language: "en", Currency: "INR", Decimal: ".", Group: ",", Grouping: []int{3, 2}, Symbol_first: true, Symbol_space: true,
+		Date_format: "02/01/2006", Datetime_format: "02/01/2006 3:04 PM", Tax_label: "GST", Tax_id_label: "GSTIN"},
+	"de-DE": {Code: "de-DE", Language: "de", Currency: "EUR", Decimal: ",", Group: ".", Grouping: []int{3}, Symbol_space: true,
+		Date_format: "02.01.2006", Datetime_format: "02.01.2006 15:04", Tax_label: "MwSt.", Tax_id_label: "USt-IdNr."},
+	"de-AT": {Code: "de-AT", Language: "de", Currency: "EUR", Decimal: ",", Group: " ", Grouping: []int{3}, Symbol_first: true, Symbol_space: true,
+		Date_format: "02.01.2006", Datetime_format: "02.01.2006 15:04", Tax_label: "USt.", Tax_id_label: "UID-Nr."},
+	"de-CH": {Code: "de-CH", Language: "de", Currency: "CHF", Decimal: ".", Group: "'", Grouping: []int{3}, Symbol_first: true, Symbol_space: true,
+		Date_format: "02.01.2006", Datetime_format: "02.01.2006 15:04", Tax_label: "MWST", Tax_id_label: "MWST-Nr."},
+	"fr-FR": {Code: "fr-FR", Language: "fr", Currency: "EUR", Decimal: ",", Group: " ", Grouping: []int{3}, Symbol_space: true,
+		Date_format: "02/01/2006", Datetime_format: "02/01/2006 15:04", Tax_label: "TVA", Tax_id_label: "N° TVA"},
+	"es-ES": {Code: "es-ES", Language: "es", Currency: "EUR", Decimal: ",", Group: ".", Grouping: []int{3}, Symbol_space: true,
+		Date_format: "02/01/2006", Datetime_format: "02/01/2006 15:04", Tax_label: "IVA", Tax_id_label: "NIF"},
+	"es-MX": {Code: "es-MX", Language: "es", Currency: "MXN", Decimal: ".", Group: ",", Grouping: []int{3}, Symbol_first: true,
+		Date_format: "02/01/2006", Datetime_format: "02/01/2006 15:04", Tax_label: "IVA", Tax_id_label: "RFC"},
+	"it-IT": {Code: "it-IT", Language: "it", Currency: "EUR", Decimal: ",", Group: ".", Grouping: []int{3}, Symbol_space: true,
+		Date_format: "02/01/2006", Datetime_format: "02/01/2006 15:04", Tax_label: "IVA", Tax_id_label: "P.IVA"},
+	"nl-NL": {Code: "nl-NL", Language: "nl", Currency: "EUR", Decimal: ",", Group: ".", Grouping: []int{3}, Symbol_first: true, Symbol_space: true,
+		Date_format: "02-01-2006", Datetime_format: "02-01-2006 15:04", Tax_label: "BTW", Tax_id_label: "BTW-nr."},
+	"ja-JP": {Code: "ja-JP", Language: "en", Currency: "JPY", Decimal: ".", Group: ",", Grouping: []int{3}, Symbol_first: true,
+		Date_format: "2006/01/02", Datetime_format: "2006/01/02 15:04", Tax_label: "Consumption tax", Tax_id_label: "Registration No."},
+}
+
+// Lookup returns the locale registered under code, e.g. "de-DE".
+func Lookup(code string) (Locale, bool) {
+	locale, ok := locales[code]
+	return locale, ok
+}
+
+// Get returns the locale registered under code, falling back to
+// DefaultLocale for unknown codes.
+func Get(code string) Locale {
+	if locale, ok := locales[code]; ok {
+		return locale
+	}
+	return locales[DefaultLocale]
+}
+
+// Locales lists the supported locale codes.
+func Locales() []string {
+	codes := make([]string, 0, len(locales))
+	for code := range locales {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+	return codes
+}
+
+// Number formats amount with the locale's decimal and grouping separators.
+func (l Locale) Number(amount float64, decimals int) string {
+	sign := ""
+	if amount < 0 {
+		sign = "-"
+		amount = -amount
+	}
+	digits := strconv.FormatFloat(amount, 'f', decimals, 64)
+	whole, fraction, _ := strings.Cut(digits, ".")
+	if math.Round(amount*math.Pow10(decimals)) == 0 {
+		sign = ""
+	}
+
+	formatted := sign + l.group(whole)
+	if decimals > 0 {
+		formatted += l.Decimal + fraction
+	}
+	return formatted
+}
+
+// Money formats amount in currency, placing the symbol where the locale
+// expects it. An empty currency uses the locale's own.
+func (l Locale) Money(amount float64, currencyCode string) string {
+	if currencyCode == "" {
+		currencyCode = l.Currency
+	}
+	currency := GetCurrency(currencyCode)
+
+	number := l.Number(amount, currency.Decimals)
+	sign := ""
+	if strings.HasPrefix(number, "-") {
+		sign, number = "-", number[1:]
+	}
+	space := ""
+	if l.Symbol_space {
+		space = " "
+	}
+	if l.Symbol_first {
+		return sign + currency.Symbol + space + number
+	}
+	return sign + number + space + currency.Symbol
+}
+
+func (l Locale) Date(t time.Time) string {
+	return t.Format(l.Date_format)
+}
+
+func (l Locale) Datetime(t time.Time) string {
+	return t.Format(l.Datetime_format)
+}
+
+// group inserts the group separator into a string of digits. Grouping lists
+// group sizes from the right; the last size repeats.
+func (l Locale) group(digits string) string {
+	if len(l.Grouping) == 0 || l.Group == "" {
+		return digits
+	}
+	groups := []string{}
+	for i := 0; len(digits) > 0; i++ {
+		size := l.Grouping[len(l.Grouping)-1]
+		if i < len(l.Grouping) {
+			size = l.Grouping[i]
+		}
+		if size >= len(digits) {
+			groups = append(groups, digits)
+			break
+		}
+		groups = append(groups, digits[len(digits)-size:])
+		digits = digits[:len(digits)-size]
+	}
+	for i, j := 0, len(groups)-1; i < j; i, j = i+1, j-1 {
+		groups[i], groups[j] = groups[j], groups[i]
+	}
+	return strings.Join(groups, l.Group)
+}
@@ -0,0 +1,86 @@
+package i18n
+
+import "testing"
+
+func TestGet(t *testing.T) {
+	tests := []struct {
+		code string
+		want string
+	}{
+		{"de-DE", "de-DE"},
+		{"ja-JP", "ja-JP"},
+		{"", DefaultLocale},
+		{"xx-XX", DefaultLocale},
+		{"de-de", DefaultLocale},
+	}
+	for _, tt := range tests {
+		if got := Get(tt.code); got.Code != tt.want {
+			t.Errorf("Get(%q) = %s, want %s", tt.code, got.Code, tt.want)
+		}
+	}
+	if _, ok := Lookup("xx-XX"); ok {
+		t.Error("Lookup(xx-XX) found a locale")
+	}
+}
+
+func TestGetCurrency(t *testing.T) {
+	tests := []struct {
+		code string
+		want Currency
+	}{
+		{"EUR", Currency{Code: "EUR", Symbol: "€", Decimals: 2}},
+		{"JPY", Currency{Code: "JPY", Symbol: "¥", Decimals: 0}},
+		{"SEK", Currency{Code: "SEK", Symbol: "SEK", Decimals: 2}},
+	}
+	for _, tt := range tests {
+		if got := GetCurrency(tt.code); got != tt.want {
+			t.Errorf("GetCurrency(%q) = %+v, want %+v", tt.code, got, tt.want)
+		}
+	}
+	if _, ok := LookupCurrency("SEK"); ok {
+		t.Error("LookupCurrency(SEK) found a currency")
+	}
+}
+
+func TestMoney(t *testing.T) {
+	tests := []struct {
+		locale   string
+		amount   float64
+		currency string
+		want     string
+	}{
+		{"en-US", 1234.5, "", "$1,234.50"},
+		{"en-US", -1234.5, "", "-$1,234.50"},
+		{"en-US", -0.001, "", "$0.00"},
+		{"de-DE", 1234567.891, "", "1.234.567,89 €"},
+		{"fr-FR", 1234.5, "", "1 234,50 €"},
+		{"de-CH", 1234.5, "", "CHF 1'234.50"},
+		{"en-IN", 12345678, "", "Rs. 1,23,45,678.00"},
+		{"ja-JP", 1234.5, "", "¥1,234"},
+		{"en-GB", 12, "EUR", "€12.00"},
+		{"en-US", 12, "SEK", "SEK12.00"},
+	}
+	for _, tt := range tests {
+		if got := Get(tt.locale).Money(tt.amount, tt.currency); got != tt.want {
+			t.Errorf("%s Money(%v, %q) = %q, want %q", tt.locale, tt.amount, tt.currency, got, tt.want)
+		}
+	}
+}
+
+func TestT(t *testing.T) {
+	tests := []struct {
+		locale string
+		key    string
+		want   string
+	}{
+		{"en-US", "tip", "Tip"},
+		{"de-DE", "tip", "Trinkgeld"},
+		{"ja-JP", "tip", "Tip"},
+		{"de-DE", "no_such_label", "no_such_label"},
+	}
+	for _, tt := range tests {
+		if got := Get(tt.locale).T(tt.key); got != tt.want {
+			t.Errorf("%s T(%q) = %q, want %q", tt.locale, tt.key, got, tt.want)
+		}
+	}
+}
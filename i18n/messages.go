@@ -0,0 +1,107 @@
+package i18n
+
+// messages holds receipt labels per language. English is the fallback for
+// missing keys and languages.
+var messages = map[string]map[string]string{
+	"en": {
+		"receipt":       "Receipt",
+		"date":          "Date",
+		"table":         "Table",
+		"subtotal":      "Subtotal",
+		"cash_rounding": "Cash rounding",
+		"total":         "TOTAL",
+		"deposit":       "Deposit paid",
+		"balance_due":   "Balance due",
+		"paid_by":       "Paid by",
+		"status":        "Status",
+		"included":      "incl.",
+		"tip":           "Tip",
+		"split":         "Split",
+	},
+	"de": {
+		"receipt":       "Beleg",
+		"date":          "Datum",
+		"table":         "Tisch",
+		"subtotal":      "Zwischensumme",
+		"cash_rounding": "Rundung",
+		"total":         "SUMME",
+		"deposit":       "Anzahlung",
+		"balance_due":   "Restbetrag",
+		"paid_by":       "Bezahlt mit",
+		"status":        "Status",
+		"included":      "inkl.",
+		"tip":           "Trinkgeld",
+		"split":         "Teilrechnung",
+	},
+	"fr": {
+		"receipt":       "Ticket",
+		"date":          "Date",
+		"table":         "Table",
+		"subtotal":      "Sous-total",
+		"cash_rounding": "Arrondi",
+		"total":         "TOTAL",
+		"deposit":       "Acompte versé",
+		"balance_due":   "Reste à payer",
+		"paid_by":       "Payé par",
+		"status":        "Statut",
+		"included":      "incl.",
+		"tip":           "Pourboire",
+		"split":         "Partage",
+	},
+	"es": {
+		"receipt":       "Ticket",
+		"date":          "Fecha",
+		"table":         "Mesa",
+		"subtotal":      "Subtotal",
+		"cash_rounding": "Redondeo",
+		"total":         "TOTAL",
+		"deposit":       "Depósito",
+		"balance_due":   "Saldo pendiente",
+		"paid_by":       "Pagado con",
+		"status":        "Estado",
+		"included":      "incl.",
+		"tip":           "Propina",
+		"split":         "División",
+	},
+	"it": {
+		"receipt":       "Scontrino",
+		"date":          "Data",
+		"table":         "Tavolo",
+		"subtotal":      "Subtotale",
+		"cash_rounding": "Arrotondamento",
+		"total":         "TOTALE",
+		"deposit":       "Acconto",
+		"balance_due":   "Saldo dovuto",
+		"paid_by":       "Pagato con",
+		"status":        "Stato",
+		"included":      "incl.",
+		"tip":           "Mancia",
+		"split":         "Divisione",
+	},
+	"nl": {
+		"receipt":       "Bon",
+		"date":          "Datum",
+		"table":         "Tafel",
+		"subtotal":      "Subtotaal",
+		"cash_rounding": "Afronding",
+		"total":         "TOTAAL",
+		"deposit":       "Aanbetaling",
+		"balance_due":   "Openstaand",
+		"paid_by":       "Betaald met",
+		"status":        "Status",
+		"included":      "incl.",
+		"tip":           "Fooi",
+		"split":         "Deel",
+	},
+}
+
+// T translates a label key into the locale's language.
+func (l Locale) T(key string) string {
+	if message, ok := messages[l.Language][key]; ok {
+		return message
+	}
+	if message, ok := messages["en"][key]; ok {
+		return message
+	}
+	return key
+}
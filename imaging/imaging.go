@@ -0,0 +1,86 @@
+// Package imaging makes the smaller copies of uploaded photos that menus
+// load, using only the standard library's JPEG and PNG codecs.
+package imaging
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/jpeg"
+	_ "image/png"
+)
+
+// jpegQuality keeps variants small without visible artifacts on food photos.
+const jpegQuality = 82
+
+// Decode reads a JPEG or PNG image.
+func Decode(data []byte) (image.Image, error) {
+	img, _, err := image.Decode(bytes.NewReader(data))
+	return img, err
+}
+
+// JPEG scales img down to fit within maxSize on its longest side, keeping
+// its proportions, and encodes it as JPEG. Images already small enough are
+// only re-encoded. Transparent areas turn white.
+func JPEG(img image.Image, maxSize int) ([]byte, error) {
+	bounds := img.Bounds()
+	flat := image.NewRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	draw.Draw(flat, flat.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+	draw.Draw(flat, flat.Bounds(), img, bounds.Min, draw.Over)
+
+	var buf bytes.Buffer
+	err := jpeg.Encode(&buf, fit(flat, maxSize), &jpeg.Options{Quality: jpegQuality})
+	return buf.Bytes(), err
+}
+
+// fit shrinks src by averaging the source pixels under each target pixel,
+// which keeps downscaled photos smooth.
+func fit(src *image.RGBA, maxSize int) *image.RGBA {
+	width, height := src.Rect.Dx(), src.Rect.Dy()
+	if width <= maxSize && height <= maxSize {
+		return src
+	}
+	newWidth, newHeight := maxSize, height*maxSize/width
+	if height > width {
+		newWidth, newHeight = width*maxSize/height, maxSize
+	}
+	if newWidth < 1 {
+		newWidth = 1
+	}
+	if newHeight < 1 {
+		newHeight = 1
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, newWidth, newHeight))
+	for y := 0; y < newHeight; y++ {
+		y0, y1 := y*height/newHeight, (y+1)*height/newHeight
+		if y1 == y0 {
+			y1 = y0 + 1
+		}
+		for x := 0; x < newWidth; x++ {
+			x0, x1 := x*width/newWidth, (x+1)*width/newWidth
+			if x1 == x0 {
+				x1 = x0 + 1
+			}
+			var r, g, b, a, n int
+			for sy := y0; sy < y1; sy++ {
+				row := src.Pix[sy*src.Stride:]
+				for sx := x0; sx < x1; sx++ {
+					pixel := row[sx*4 : sx*4+4]
+					r += int(pixel[0])
+					g += int(pixel[1])
+					b += int(pixel[2])
+					a += int(pixel[3])
+					n++
+				}
+			}
+			offset := y*dst.Stride + x*4
+			dst.Pix[offset] = uint8(r / n)
+			dst.Pix[offset+1] = uint8(g / n)
+			dst.Pix[offset+2] = uint8(b / n)
+			dst.Pix[offset+3] = uint8(a / n)
+		}
+	}
+	return dst
+}
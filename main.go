@@ -1,31 +1,168 @@
 package main
 
 import (
+	"context"
+	"flag"
+	"log"
+	"net/http"
 	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
-	"restaurant-management/middleware"
+	"restaurant-management/config"
+	controller "restaurant-management/controllers"
+	"restaurant-management/database"
+	"restaurant-management/database/migrations"
+	"restaurant-management/extensions"
+	"restaurant-management/notifications/email"
+	"restaurant-management/notifications/push"
+	"restaurant-management/notifications/sms"
+	"restaurant-management/payments"
+	"restaurant-management/realtime"
 	"restaurant-management/routes"
-
-	"github.com/gin-gonic/gin"
+	"restaurant-management/scheduler"
 )
 
 func main() {
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = "8000"
+	migrateOnly := flag.Bool("migrate", false, "apply database migrations and exit")
+	flag.Parse()
+	cfg := config.Get()
+
+	if err := extensions.LoadPlugins(os.Getenv("EXTENSION_PLUGINS")); err != nil {
+		log.Fatal("Error loading extensions:", err)
+	}
+	// Payments fail closed: a server without a real gateway and webhook
+	// secret does not start rather than approve charges it cannot take
+	if !*migrateOnly {
+		if _, err := payments.NewGateway(); err != nil {
+			log.Fatal("Invalid payment gateway: ", err)
+		}
+	}
+
+	client, err := database.Connect(cfg)
+	if err != nil {
+		log.Fatal(err)
+	}
+	// Deploys can apply migrations in a step of their own with -migrate,
+	// and start the servers with MIGRATE_ON_START=false
+	if *migrateOnly || cfg.MigrateOnStart {
+		migrateCtx, cancelMigrations := context.WithTimeout(context.Background(), 5*time.Minute)
+		applied, err := migrations.Run(migrateCtx, client.Database(cfg.DBName))
+		cancelMigrations()
+		if err != nil {
+			log.Fatal("Error migrating database: ", err)
+		}
+		log.Printf("Applied %d migrations", applied)
+		if *migrateOnly {
+			if err := client.Disconnect(context.Background()); err != nil {
+				log.Println("Error disconnecting from MongoDB:", err)
+			}
+			return
+		}
+	}
+
+	router := routes.NewRouter(cfg, client)
+
+	scheduler.Register("review-ingestion", 6*time.Hour, controller.IngestReviews)
+	scheduler.Register("haccp-missed-checks", 5*time.Minute, controller.CheckMissedTemperatureChecks)
+	scheduler.Register("overdue-checklists", 15*time.Minute, controller.CheckOverdueChecklists)
+	scheduler.Register("warranty-reminders", 24*time.Hour, controller.SendWarrantyReminders)
+	scheduler.Register("task-schedule", 15*time.Minute, controller.RunTaskSchedule)
+	scheduler.Register("no-show-detection", 5*time.Minute, controller.DetectNoShows)
+	scheduler.Register("reservation-reminders", 5*time.Minute, controller.SendReservationReminders)
+	scheduler.Register("booking-sync", 5*time.Minute, controller.SyncBookingConnectors)
+	scheduler.Register("calendar-push", 15*time.Minute, controller.PushCalendarFeeds)
+	scheduler.Register("webhook-delivery", 30*time.Second, controller.DeliverWebhooks)
+	scheduler.Register("sold-out-restock", time.Minute, controller.RestockSoldOutFoods)
+	scheduler.Register("menu-activation", time.Minute, controller.ActivateMenus)
+
+	socialPostTime := os.Getenv("SOCIAL_POST_TIME")
+	if socialPostTime == "" {
+		socialPostTime = "10:00"
+	}
+	if err := scheduler.RegisterDaily("social-specials", socialPostTime, controller.PublishDailySpecials); err != nil {
+		log.Fatal("Invalid SOCIAL_POST_TIME:", err)
 	}
 
-	router := gin.New()
-	router.Use(gin.Logger())
+	eodReportTime := os.Getenv("EOD_REPORT_TIME")
+	if eodReportTime == "" {
+		eodReportTime = "23:30"
+	}
+	if err := scheduler.RegisterDaily("end-of-day-report", eodReportTime, controller.SendEndOfDayReport); err != nil {
+		log.Fatal("Invalid EOD_REPORT_TIME:", err)
+	}
 
-	routes.UserRoutes(router)
-	router.Use(middleware.Authentication())
+	prepListTime := os.Getenv("PREP_LIST_TIME")
+	if prepListTime == "" {
+		prepListTime = "05:30"
+	}
+	if err := scheduler.RegisterDaily("prep-list", prepListTime, controller.GeneratePrepTasks); err != nil {
+		log.Fatal("Invalid PREP_LIST_TIME:", err)
+	}
 
-	routes.FoodRoutes(router)
-	routes.MenuRoutes(router)
-	routes.TableRoutes(router)
-	routes.OrderRoutes(router)
-	routes.OrderItemRoutes(router)
-	routes.InvoiceRoutes(router)
-	router.Run(":" + port)
+	recommendationsTime := os.Getenv("RECOMMENDATIONS_TIME")
+	if recommendationsTime == "" {
+		recommendationsTime = "03:00"
+	}
+	if err := scheduler.RegisterDaily("recommendations", recommendationsTime, controller.ComputeRecommendations); err != nil {
+		log.Fatal("Invalid RECOMMENDATIONS_TIME:", err)
+	}
+
+	personalizationTime := os.Getenv("PERSONALIZATION_TIME")
+	if personalizationTime == "" {
+		personalizationTime = "02:30"
+	}
+	if err := scheduler.RegisterDaily("menu-personalization", personalizationTime, controller.PersonalizeMenus); err != nil {
+		log.Fatal("Invalid PERSONALIZATION_TIME:", err)
+	}
+	workers, stopWorkers := context.WithCancel(context.Background())
+	scheduler.Start(workers)
+	controller.StartThumbnailWorkers(workers, 2)
+	email.Start(workers, 2)
+	sms.Start(workers, 2)
+	push.Start(workers, 2, controller.ForgetDevice)
+
+	server := &http.Server{Addr: ":" + cfg.Port, Handler: router}
+	go func() {
+		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.Fatal("Error starting server:", err)
+		}
+	}()
+
+	// Kubernetes sends SIGTERM before replacing a pod, so stop taking
+	// requests and let the ones under way finish their writes
+	stop, stopSignals := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	<-stop.Done()
+	stopSignals()
+	log.Println("Shutting down")
+
+	shutdown, cancelShutdown := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	defer cancelShutdown()
+
+	// Event streams never finish on their own; ending them lets their
+	// clients reconnect elsewhere and the drain below complete
+	realtime.Close()
+	if err := server.Shutdown(shutdown); err != nil {
+		log.Println("Error draining requests:", err)
+	}
+	stopWorkers()
+	if err := scheduler.Wait(shutdown); err != nil {
+		log.Println("Error waiting for background jobs:", err)
+	}
+	if err := controller.WaitThumbnailWorkers(shutdown); err != nil {
+		log.Println("Error waiting for thumbnail workers:", err)
+	}
+	if err := email.Wait(shutdown); err != nil {
+		log.Println("Error waiting for email workers:", err)
+	}
+	if err := sms.Wait(shutdown); err != nil {
+		log.Println("Error waiting for SMS workers:", err)
+	}
+	if err := push.Wait(shutdown); err != nil {
+		log.Println("Error waiting for push workers:", err)
+	}
+	if err := client.Disconnect(shutdown); err != nil {
+		log.Println("Error disconnecting from MongoDB:", err)
+	}
 }
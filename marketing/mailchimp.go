@@ -0,0 +1,112 @@
+package marketing
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+)
+
+// Mailchimp uses a single configured audience (list) and represents each
+// synced segment as a static segment (tag) within it.
+type Mailchimp struct {
+	APIKey  string
+	BaseURL string
+	ListId  string
+}
+
+func (m *Mailchimp) auth(req *http.Request) {
+	req.SetBasicAuth("restaurant", m.APIKey)
+}
+
+func (m *Mailchimp) SyncAudience(ctx context.Context, name string, contacts []Contact) (string, error) {
+	type member struct {
+		Email_address string            `json:"email_address"`
+		Status_if_new string            `json:"status_if_new"`
+		Merge_fields  map[string]string `json:"merge_fields"`
+	}
+	batch := struct {
+		Members         []member `json:"members"`
+		Update_existing bool     `json:"update_existing"`
+	}{Update_existing: true}
+	emails := []string{}
+	for _, contact := range contacts {
+		batch.Members = append(batch.Members, member{
+			Email_address: contact.Email,
+			Status_if_new: "subscribed",
+			Merge_fields:  map[string]string{"FNAME": contact.First_name, "LNAME": contact.Last_name},
+		})
+		emails = append(emails, contact.Email)
+	}
+	if len(batch.Members) > 0 {
+		if err := doJSON(ctx, http.MethodPost, m.BaseURL+"/lists/"+m.ListId, m.auth, batch, nil); err != nil {
+			return "", err
+		}
+	}
+
+	var segments struct {
+		Segments []struct {
+			ID   int    `json:"id"`
+			Name string `json:"name"`
+		} `json:"segments"`
+	}
+	if err := doJSON(ctx, http.MethodGet, m.BaseURL+"/lists/"+m.ListId+"/segments?type=static&count=1000", m.auth, nil, &segments); err != nil {
+		return "", err
+	}
+
+	for _, segment := range segments.Segments {
+		if segment.Name == name {
+			segmentId := strconv.Itoa(segment.ID)
+			// Replace the static segment's members with the current snapshot
+			body := map[string][]string{"static_segment": emails}
+			if err := doJSON(ctx, http.MethodPatch, m.BaseURL+"/lists/"+m.ListId+"/segments/"+segmentId, m.auth, body, nil); err != nil {
+				return "", err
+			}
+			return segmentId, nil
+		}
+	}
+
+	var created struct {
+		ID int `json:"id"`
+	}
+	body := map[string]interface{}{"name": name, "static_segment": emails}
+	if err := doJSON(ctx, http.MethodPost, m.BaseURL+"/lists/"+m.ListId+"/segments", m.auth, body, &created); err != nil {
+		return "", err
+	}
+	return strconv.Itoa(created.ID), nil
+}
+
+func (m *Mailchimp) SendCampaign(ctx context.Context, audienceId string, message Message) (string, error) {
+	segmentId, err := strconv.Atoi(audienceId)
+	if err != nil {
+		return "", err
+	}
+
+	campaign := map[string]interface{}{
+		"type": "regular",
+		"recipients": map[string]interface{}{
+			"list_id":      m.ListId,
+			"segment_opts": map[string]interface{}{"saved_segment_id": segmentId},
+		},
+		"settings": map[string]interface{}{
+			"subject_line": message.Subject,
+			"title":        message.Name,
+			"from_name":    message.From_name,
+			"reply_to":     message.From_email,
+		},
+	}
+	var created struct {
+		ID string `json:"id"`
+	}
+	if err := doJSON(ctx, http.MethodPost, m.BaseURL+"/campaigns", m.auth, campaign, &created); err != nil {
+		return "", err
+	}
+
+	content := map[string]string{"html": message.Html_content}
+	if err := doJSON(ctx, http.MethodPut, m.BaseURL+"/campaigns/"+created.ID+"/content", m.auth, content, nil); err != nil {
+		return "", err
+	}
+	if err := doJSON(ctx, http.MethodPost, m.BaseURL+"/campaigns/"+created.ID+"/actions/send", m.auth, nil, nil); err != nil {
+		return "", err
+	}
+	return created.ID, nil
+}
@@ -0,0 +1,102 @@
+package marketing
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+)
+
+// Contact is a campaign recipient.
+type Contact struct {
+	Customer_id string
+	Email       string
+	First_name  string
+	Last_name   string
+}
+
+// Message is the content of a campaign send.
+type Message struct {
+	Name         string
+	Subject      string
+	Html_content string
+	From_email   string
+	From_name    string
+}
+
+// Provider syncs audiences to an email marketing platform and triggers sends.
+type Provider interface {
+	// SyncAudience creates or refreshes the named audience with the given
+	// contacts and returns the provider's audience id.
+	SyncAudience(ctx context.Context, name string, contacts []Contact) (string, error)
+	// SendCampaign sends the message to a previously synced audience and
+	// returns the provider's campaign id.
+	SendCampaign(ctx context.Context, audienceId string, message Message) (string, error)
+}
+
+// NewProvider returns the connector for a provider name, configured from
+// environment variables.
+func NewProvider(name string) (Provider, error) {
+	switch name {
+	case "SENDGRID":
+		apiKey := os.Getenv("SENDGRID_API_KEY")
+		if apiKey == "" {
+			return nil, fmt.Errorf("SENDGRID_API_KEY is not set")
+		}
+		senderId, err := strconv.Atoi(os.Getenv("SENDGRID_SENDER_ID"))
+		if err != nil {
+			return nil, fmt.Errorf("SENDGRID_SENDER_ID must be the id of a verified sender")
+		}
+		return &SendGrid{APIKey: apiKey, BaseURL: "https://api.sendgrid.com", SenderId: senderId}, nil
+	case "MAILCHIMP":
+		apiKey := os.Getenv("MAILCHIMP_API_KEY")
+		server := os.Getenv("MAILCHIMP_SERVER_PREFIX")
+		listId := os.Getenv("MAILCHIMP_LIST_ID")
+		if apiKey == "" || server == "" || listId == "" {
+			return nil, fmt.Errorf("MAILCHIMP_API_KEY, MAILCHIMP_SERVER_PREFIX and MAILCHIMP_LIST_ID must be set")
+		}
+		return &Mailchimp{APIKey: apiKey, BaseURL: "https://" + server + ".api.mailchimp.com/3.0", ListId: listId}, nil
+	}
+	return nil, fmt.Errorf("unknown marketing provider %q", name)
+}
+
+var httpClient = &http.Client{Timeout: 30 * time.Second}
+
+// doJSON sends body as JSON and decodes a JSON response into out when set.
+func doJSON(ctx context.Context, method, url string, setAuth func(*http.Request), body, out interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		payload, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(payload)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, url, reader)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	setAuth(req)
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		detail, _ := io.ReadAll(io.LimitReader(resp.Body, 2048))
+		return fmt.Errorf("%s %s returned %d: %s", method, url, resp.StatusCode, detail)
+	}
+	if out != nil {
+		return json.NewDecoder(resp.Body).Decode(out)
+	}
+	return nil
+}
@@ -0,0 +1,101 @@
+package marketing
+
+import (
+	"context"
+	"net/http"
+)
+
+// SendGrid keeps one Marketing Campaigns list per audience and sends
+// through Single Sends.
+type SendGrid struct {
+	APIKey   string
+	BaseURL  string
+	SenderId int
+}
+
+func (s *SendGrid) auth(req *http.Request) {
+	req.Header.Set("Authorization", "Bearer "+s.APIKey)
+}
+
+func (s *SendGrid) SyncAudience(ctx context.Context, name string, contacts []Contact) (string, error) {
+	var lists struct {
+		Result []struct {
+			ID   string `json:"id"`
+			Name string `json:"name"`
+		} `json:"result"`
+	}
+	if err := doJSON(ctx, http.MethodGet, s.BaseURL+"/v3/marketing/lists?page_size=1000", s.auth, nil, &lists); err != nil {
+		return "", err
+	}
+
+	listId := ""
+	for _, list := range lists.Result {
+		if list.Name == name {
+			listId = list.ID
+			break
+		}
+	}
+	if listId == "" {
+		var created struct {
+			ID string `json:"id"`
+		}
+		if err := doJSON(ctx, http.MethodPost, s.BaseURL+"/v3/marketing/lists", s.auth, map[string]string{"name": name}, &created); err != nil {
+			return "", err
+		}
+		listId = created.ID
+	}
+
+	if len(contacts) == 0 {
+		return listId, nil
+	}
+
+	type sendGridContact struct {
+		Email       string `json:"email"`
+		First_name  string `json:"first_name,omitempty"`
+		Last_name   string `json:"last_name,omitempty"`
+		External_id string `json:"external_id,omitempty"`
+	}
+	payload := struct {
+		List_ids []string          `json:"list_ids"`
+		Contacts []sendGridContact `json:"contacts"`
+	}{List_ids: []string{listId}}
+	for _, contact := range contacts {
+		payload.Contacts = append(payload.Contacts, sendGridContact{
+			Email:       contact.Email,
+			First_name:  contact.First_name,
+			Last_name:   contact.Last_name,
+			External_id: contact.Customer_id,
+		})
+	}
+
+	if err := doJSON(ctx, http.MethodPut, s.BaseURL+"/v3/marketing/contacts", s.auth, payload, nil); err != nil {
+		return "", err
+	}
+	return listId, nil
+}
+
+func (s *SendGrid) SendCampaign(ctx context.Context, audienceId string, message Message) (string, error) {
+	singleSend := map[string]interface{}{
+		"name": message.Name,
+		"send_to": map[string]interface{}{
+			"list_ids": []string{audienceId},
+		},
+		"email_config": map[string]interface{}{
+			"subject":      message.Subject,
+			"html_content": message.Html_content,
+			"sender_id":    s.SenderId,
+		},
+	}
+	var created struct {
+		ID string `json:"id"`
+	}
+	if err := doJSON(ctx, http.MethodPost, s.BaseURL+"/v3/marketing/singlesends", s.auth, singleSend, &created); err != nil {
+		return "", err
+	}
+
+	schedule := map[string]string{"send_at": "now"}
+	if err := doJSON(ctx, http.MethodPut, s.BaseURL+"/v3/marketing/singlesends/"+created.ID+"/schedule", s.auth, schedule, nil); err != nil {
+		return "", err
+	}
+	return created.ID, nil
+}
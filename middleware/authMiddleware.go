@@ -0,0 +1,48 @@
+package middleware
+
+import (
+	"restaurant-management/apierror"
+	"restaurant-management/helpers"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Authentication lets through requests carrying a valid access token, as
+// "Authorization: Bearer <token>" or in the token header, and makes the
+// user's email, first_name, last_name, uid and role available on the context.
+// Browsers cannot set headers when opening a WebSocket or an EventSource, so
+// upgrade and event stream requests may pass the token as ?access_token=
+// instead.
+func Authentication() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		clientToken := strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+		if clientToken == "" {
+			clientToken = c.GetHeader("token")
+		}
+		if clientToken == "" && (strings.EqualFold(c.GetHeader("Upgrade"), "websocket") || strings.Contains(c.GetHeader("Accept"), "text/event-stream")) {
+			clientToken = c.Query("access_token")
+		}
+		if clientToken == "" {
+			apierror.Abort(c, apierror.Unauthorized("No authorization header provided"))
+			return
+		}
+
+		claims, err := helpers.ValidateToken(clientToken)
+		if err != nil {
+			apierror.Abort(c, apierror.Unauthorized(err.Error()))
+			return
+		}
+		if claims.Type != "access" {
+			apierror.Abort(c, apierror.Unauthorized("Refresh tokens cannot be used to authenticate"))
+			return
+		}
+
+		c.Set("email", claims.Email)
+		c.Set("first_name", claims.First_name)
+		c.Set("last_name", claims.Last_name)
+		c.Set("uid", claims.Uid)
+		c.Set("role", claims.Role)
+		c.Next()
+	}
+}
@@ -0,0 +1,59 @@
+package middleware
+
+import (
+	"net/http"
+	"restaurant-management/config"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// corsMaxAge is how long browsers may cache a preflight answer, in seconds.
+const corsMaxAge = 10 * 60
+
+// CORS lets browser apps served from the allowed origins, such as the
+// waiter app, call the API. Preflight requests are answered here, before
+// authentication, since browsers send them without a token. Requests from
+// other origins get no CORS headers, so browsers block their responses.
+func CORS(cors config.CORS) gin.HandlerFunc {
+	anyOrigin := false
+	origins := map[string]bool{}
+	for _, origin := range cors.AllowedOrigins {
+		if origin == "*" {
+			anyOrigin = true
+		}
+		origins[origin] = true
+	}
+	methods := strings.Join(append(cors.AllowedMethods, http.MethodOptions), ", ")
+	headers := strings.Join(cors.AllowedHeaders, ", ")
+
+	return func(c *gin.Context) {
+		origin := c.GetHeader("Origin")
+		if origin == "" {
+			c.Next()
+			return
+		}
+		c.Writer.Header().Add("Vary", "Origin")
+		if !anyOrigin && !origins[origin] {
+			c.Next()
+			return
+		}
+
+		if anyOrigin {
+			c.Header("Access-Control-Allow-Origin", "*")
+		} else {
+			c.Header("Access-Control-Allow-Origin", origin)
+		}
+		c.Header("Access-Control-Expose-Headers", "Idempotent-Replayed")
+
+		if c.Request.Method == http.MethodOptions && c.GetHeader("Access-Control-Request-Method") != "" {
+			c.Header("Access-Control-Allow-Methods", methods)
+			c.Header("Access-Control-Allow-Headers", headers)
+			c.Header("Access-Control-Max-Age", strconv.Itoa(corsMaxAge))
+			c.AbortWithStatus(http.StatusNoContent)
+			return
+		}
+		c.Next()
+	}
+}
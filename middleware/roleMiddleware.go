@@ -0,0 +1,26 @@
+package middleware
+
+import (
+	"restaurant-management/apierror"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RequireRole lets through users holding one of roles, as set on the
+// context by Authentication. ADMIN passes every check.
+func RequireRole(roles ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		role := c.GetString("role")
+		if role == "ADMIN" {
+			c.Next()
+			return
+		}
+		for _, allowed := range roles {
+			if role == allowed {
+				c.Next()
+				return
+			}
+		}
+		apierror.Abort(c, apierror.Forbidden("Your role is not allowed to do this").With("role", role).With("allowed_roles", roles))
+	}
+}
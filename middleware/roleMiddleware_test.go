@@ -0,0 +1,45 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestRequireRole(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name  string
+		role  string
+		roles []string
+		want  int
+	}{
+		{"admin passes any check", "ADMIN", []string{"MANAGER"}, http.StatusOK},
+		{"admin passes a check with no roles", "ADMIN", nil, http.StatusOK},
+		{"allowed role passes", "WAITER", []string{"MANAGER", "WAITER"}, http.StatusOK},
+		{"other role is forbidden", "WAITER", []string{"MANAGER"}, http.StatusForbidden},
+		{"missing role is forbidden", "", []string{"MANAGER"}, http.StatusForbidden},
+		{"role is case sensitive", "manager", []string{"MANAGER"}, http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := gin.New()
+			router.GET("/", func(c *gin.Context) {
+				if tt.role != "" {
+					c.Set("role", tt.role)
+				}
+			}, RequireRole(tt.roles...), func(c *gin.Context) {
+				c.Status(http.StatusOK)
+			})
+
+			recorder := httptest.NewRecorder()
+			router.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/", nil))
+			if recorder.Code != tt.want {
+				t.Errorf("status = %d, want %d", recorder.Code, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,35 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type Asset struct {
+	ID               primitive.ObjectID `bson:"_id"`
+	Name             *string            `json:"name" validate:"required,min=2,max=100"`
+	Type             *string            `json:"type" validate:"required,eq=OVEN|eq=FRIDGE|eq=FREEZER|eq=HOT_HOLD|eq=POS|eq=PRINTER|eq=OTHER"`
+	Serial_number    *string            `json:"serial_number" validate:"required"`
+	Manufacturer     *string            `json:"manufacturer"`
+	Model            *string            `json:"model"`
+	Location         *string            `json:"location"`
+	Purchase_date    *time.Time         `json:"purchase_date"`
+	Warranty_expires *time.Time         `json:"warranty_expires"`
+	Status           string             `json:"status"`
+	Created_at       time.Time          `json:"created_at"`
+	Updated_at       time.Time          `json:"updated_at"`
+	Asset_id         string             `json:"asset_id"`
+}
+
+// AssetReminder is raised by the scheduler when an asset's warranty is
+// about to run out.
+type AssetReminder struct {
+	ID               primitive.ObjectID `bson:"_id"`
+	Asset_id         string             `json:"asset_id"`
+	Warranty_expires time.Time          `json:"warranty_expires"`
+	Message          string             `json:"message"`
+	Acknowledged     bool               `json:"acknowledged"`
+	Created_at       time.Time          `json:"created_at"`
+	Reminder_id      string             `json:"reminder_id"`
+}
@@ -0,0 +1,32 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// AuditLog records one write to a resource: who made it, through which
+// endpoint, and the fields it changed. Action is CREATE, UPDATE, DELETE or
+// RESTORE.
+type AuditLog struct {
+	ID           primitive.ObjectID     `bson:"_id"`
+	Actor_id     string                 `json:"actor_id"`
+	Actor_email  string                 `json:"actor_email"`
+	Actor_role   string                 `json:"actor_role"`
+	Method       string                 `json:"method"`
+	Endpoint     string                 `json:"endpoint"`
+	Resource     string                 `json:"resource"`
+	Resource_id  string                 `json:"resource_id"`
+	Action       string                 `json:"action"`
+	Changes      map[string]AuditChange `json:"changes"`
+	Created_at   time.Time              `json:"created_at"`
+	Audit_log_id string                 `json:"audit_log_id"`
+}
+
+// AuditChange is a field's value before and after a write. Before is
+// empty for creates and After for hard deletes.
+type AuditChange struct {
+	Before interface{} `json:"before"`
+	After  interface{} `json:"after"`
+}
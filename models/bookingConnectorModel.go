@@ -0,0 +1,52 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// BookingConnector links a location to a venue on an external booking
+// platform.
+type BookingConnector struct {
+	ID       primitive.ObjectID `bson:"_id"`
+	Name     *string            `json:"name" validate:"required,min=2,max=100"`
+	Platform *string            `json:"platform" validate:"required,eq=OPENTABLE|eq=RESY|eq=OTHER"`
+	Location *string            `json:"location"`
+	Api_url  *string            `json:"api_url" validate:"required,url"`
+	Api_key  string             `json:"api_key,omitempty"`
+	Venue_id *string            `json:"venue_id" validate:"required"`
+	// Conflict_policy decides what happens to an imported booking that no
+	// longer fits: REJECT cancels it on the platform, OVERBOOK keeps it.
+	// Either way the conflict is recorded for staff.
+	Conflict_policy *string    `json:"conflict_policy" validate:"omitempty,eq=REJECT|eq=OVERBOOK"`
+	Sync_days       *int       `json:"sync_days" validate:"omitempty,gt=0,lte=180"`
+	Active          *bool      `json:"active"`
+	Last_synced_at  *time.Time `json:"last_synced_at"`
+	Last_error      string     `json:"last_error"`
+	Created_at      time.Time  `json:"created_at"`
+	Updated_at      time.Time  `json:"updated_at"`
+	Connector_id    string     `json:"connector_id"`
+}
+
+// ReservationConflict records an imported booking that collided with
+// bookings already held for the same slot.
+type ReservationConflict struct {
+	ID             primitive.ObjectID `bson:"_id"`
+	Connector_id   string             `json:"connector_id"`
+	Platform       string             `json:"platform"`
+	Location       string             `json:"location"`
+	External_id    string             `json:"external_id"`
+	Reservation_id string             `json:"reservation_id,omitempty"`
+	Customer_name  string             `json:"customer_name"`
+	Party_size     int                `json:"party_size"`
+	Reserved_at    time.Time          `json:"reserved_at"`
+	Available      int                `json:"available"`
+	// Resolution is REJECTED or OVERBOOKED.
+	Resolution  string     `json:"resolution"`
+	Error       string     `json:"error"`
+	Reviewed_by *string    `json:"reviewed_by"`
+	Reviewed_at *time.Time `json:"reviewed_at"`
+	Created_at  time.Time  `json:"created_at"`
+	Conflict_id string     `json:"conflict_id"`
+}
@@ -0,0 +1,32 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// CalendarFeed is a subscribable iCal feed of a location's reservations or
+// an employee's shifts. The secret token in its URL is the only credential
+// calendar apps can present.
+type CalendarFeed struct {
+	ID          primitive.ObjectID `bson:"_id"`
+	Name        *string            `json:"name" validate:"required,min=2,max=100"`
+	Kind        *string            `json:"kind" validate:"required,eq=RESERVATIONS|eq=SHIFTS"`
+	Location    *string            `json:"location"`
+	Employee_id *string            `json:"employee_id" validate:"required_if=Kind SHIFTS"`
+	Token       string             `json:"token"`
+	Active      *bool              `json:"active"`
+	Google      *GoogleCalendar    `json:"google"`
+	Created_at  time.Time          `json:"created_at"`
+	Updated_at  time.Time          `json:"updated_at"`
+	Feed_id     string             `json:"feed_id"`
+}
+
+// GoogleCalendar is the optional Google Calendar a feed is also pushed to.
+type GoogleCalendar struct {
+	Calendar_id    string     `json:"calendar_id" validate:"required"`
+	Refresh_token  string     `json:"refresh_token,omitempty" validate:"required"`
+	Last_pushed_at *time.Time `json:"last_pushed_at"`
+	Last_error     string     `json:"last_error"`
+}
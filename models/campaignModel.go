@@ -0,0 +1,39 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type Campaign struct {
+	ID           primitive.ObjectID `bson:"_id"`
+	Name         *string            `json:"name" validate:"required,min=2,max=100"`
+	Segment_id   *string            `json:"segment_id" validate:"required"`
+	Provider     *string            `json:"provider" validate:"required,eq=SENDGRID|eq=MAILCHIMP"`
+	Subject      *string            `json:"subject" validate:"required"`
+	Html_content *string            `json:"html_content" validate:"required"`
+	From_email   *string            `json:"from_email" validate:"omitempty,email"`
+	From_name    *string            `json:"from_name"`
+	Coupon_code  *string            `json:"coupon_code"`
+	Status       string             `json:"status"`
+	Audience_id  string             `json:"audience_id"`
+	External_id  string             `json:"external_id"`
+	Recipients   int                `json:"recipients"`
+	Redemptions  int                `json:"redemptions"`
+	Last_error   string             `json:"last_error"`
+	Synced_at    *time.Time         `json:"synced_at"`
+	Sent_at      *time.Time         `json:"sent_at"`
+	Created_at   time.Time          `json:"created_at"`
+	Updated_at   time.Time          `json:"updated_at"`
+	Campaign_id  string             `json:"campaign_id"`
+}
+
+type CampaignRedemption struct {
+	ID          primitive.ObjectID `bson:"_id"`
+	Campaign_id string             `json:"campaign_id"`
+	Coupon_code string             `json:"coupon_code" validate:"required"`
+	Order_id    *string            `json:"order_id" validate:"required"`
+	Customer_id *string            `json:"customer_id"`
+	Created_at  time.Time          `json:"created_at"`
+}
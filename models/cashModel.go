@@ -0,0 +1,54 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// DrawerSession is one employee's shift on a cash drawer, from opening float
+// to the closing count.
+type DrawerSession struct {
+	ID                primitive.ObjectID `bson:"_id"`
+	Drawer_id         *string            `json:"drawer_id" validate:"required"`
+	Employee_id       *string            `json:"employee_id" validate:"required"`
+	Opening_float     *float64           `json:"opening_float" validate:"required,min=0"`
+	Counted_cash      *float64           `json:"counted_cash"`
+	Status            string             `json:"status"`
+	Opened_at         time.Time          `json:"opened_at"`
+	Closed_at         *time.Time         `json:"closed_at"`
+	Created_at        time.Time          `json:"created_at"`
+	Updated_at        time.Time          `json:"updated_at"`
+	Drawer_session_id string             `json:"drawer_session_id"`
+}
+
+// CashMovement is cash put into or taken out of a drawer outside of a sale.
+type CashMovement struct {
+	ID                primitive.ObjectID `bson:"_id"`
+	Drawer_session_id string             `json:"drawer_session_id"`
+	Type              *string            `json:"type" validate:"required,eq=PAID_IN|eq=PAID_OUT|eq=SAFE_DROP"`
+	Amount            *float64           `json:"amount" validate:"required,gt=0"`
+	Reason            *string            `json:"reason" validate:"required_unless=Type SAFE_DROP"`
+	Employee_id       *string            `json:"employee_id" validate:"required"`
+	Created_at        time.Time          `json:"created_at"`
+	Cash_movement_id  string             `json:"cash_movement_id"`
+}
+
+type DrawerReconciliation struct {
+	Drawer_session_id string     `json:"drawer_session_id"`
+	Drawer_id         string     `json:"drawer_id"`
+	Employee_id       string     `json:"employee_id"`
+	Status            string     `json:"status"`
+	Opened_at         time.Time  `json:"opened_at"`
+	Closed_at         *time.Time `json:"closed_at"`
+	Opening_float     float64    `json:"opening_float"`
+	Cash_sales        float64    `json:"cash_sales"`
+	Cash_rounding     float64    `json:"cash_rounding"`
+	Paid_ins          float64    `json:"paid_ins"`
+	Paid_outs         float64    `json:"paid_outs"`
+	Safe_drops        float64    `json:"safe_drops"`
+	Expenses          float64    `json:"expenses"`
+	Expected_cash     float64    `json:"expected_cash"`
+	Counted_cash      *float64   `json:"counted_cash"`
+	Over_short        *float64   `json:"over_short"`
+}
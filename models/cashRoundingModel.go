@@ -0,0 +1,18 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// CashRoundingRule rounds cash payments in a currency to the smallest coin
+// in circulation, e.g. 0.05 for CHF.
+type CashRoundingRule struct {
+	ID         primitive.ObjectID `bson:"_id"`
+	Currency   string             `json:"currency"`
+	Increment  *float64           `json:"increment" validate:"required,gt=0"`
+	Mode       *string            `json:"mode" validate:"omitempty,eq=NEAREST|eq=UP|eq=DOWN"`
+	Created_at time.Time          `json:"created_at"`
+	Updated_at time.Time          `json:"updated_at"`
+}
@@ -0,0 +1,19 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Category groups foods across menus, such as starters or desserts.
+// Position orders categories when a menu is shown by category.
+type Category struct {
+	ID          primitive.ObjectID `bson:"_id"`
+	Name        *string            `json:"name" validate:"required,min=2,max=100"`
+	Description *string            `json:"description" validate:"omitempty,max=500"`
+	Position    *int               `json:"position" validate:"omitempty,min=0"`
+	Created_at  time.Time          `json:"created_at"`
+	Updated_at  time.Time          `json:"updated_at"`
+	Category_id string             `json:"category_id"`
+}
@@ -0,0 +1,17 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type ChannelPolicy struct {
+	ID                    primitive.ObjectID `bson:"_id"`
+	Channel               string             `json:"channel"`
+	Min_order_value       *float64           `json:"min_order_value" validate:"omitempty,gte=0"`
+	Max_quantity_per_item *int               `json:"max_quantity_per_item" validate:"omitempty,gt=0"`
+	Excluded_food_ids     []string           `json:"excluded_food_ids"`
+	Created_at            time.Time          `json:"created_at"`
+	Updated_at            time.Time          `json:"updated_at"`
+}
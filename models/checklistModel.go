@@ -0,0 +1,62 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type ChecklistItem struct {
+	Item_id        string  `json:"item_id"`
+	Text           *string `json:"text" validate:"required,max=300"`
+	Requires_photo bool    `json:"requires_photo"`
+	Critical       bool    `json:"critical"`
+}
+
+// Checklist is a recurring self-audit. OPENING and CLOSING lists are due
+// daily at Due_time; WEEKLY lists are due on Due_day at Due_time.
+type Checklist struct {
+	ID           primitive.ObjectID `bson:"_id"`
+	Name         *string            `json:"name" validate:"required,min=2,max=100"`
+	Type         *string            `json:"type" validate:"required,eq=OPENING|eq=CLOSING|eq=WEEKLY"`
+	Location     *string            `json:"location"`
+	Items        []ChecklistItem    `json:"items" validate:"required,min=1,dive"`
+	Due_time     *string            `json:"due_time" validate:"required,datetime=15:04"`
+	Due_day      *string            `json:"due_day" validate:"required_if=Type WEEKLY,omitempty,eq=MON|eq=TUE|eq=WED|eq=THU|eq=FRI|eq=SAT|eq=SUN"`
+	Active       *bool              `json:"active"`
+	Created_at   time.Time          `json:"created_at"`
+	Updated_at   time.Time          `json:"updated_at"`
+	Checklist_id string             `json:"checklist_id"`
+}
+
+type CompletedItem struct {
+	Item_id   *string `json:"item_id" validate:"required"`
+	Passed    *bool   `json:"passed" validate:"required"`
+	Note      *string `json:"note" validate:"omitempty,max=500"`
+	Photo_url *string `json:"photo_url"`
+}
+
+// ChecklistCompletion is one sign-off of a checklist for a due date.
+// Signature holds the signer's drawn signature as a data URL.
+type ChecklistCompletion struct {
+	ID            primitive.ObjectID `bson:"_id"`
+	Checklist_id  string             `json:"checklist_id"`
+	Due_date      string             `json:"due_date"`
+	Completed_by  *string            `json:"completed_by" validate:"required"`
+	Signature     *string            `json:"signature" validate:"required,startswith=data:image/,max=200000"`
+	Items         []CompletedItem    `json:"items" validate:"required,min=1,dive"`
+	Failed_items  int                `json:"failed_items"`
+	Critical_fail bool               `json:"critical_fail"`
+	Completed_at  time.Time          `json:"completed_at"`
+	Completion_id string             `json:"completion_id"`
+}
+
+type ChecklistAlert struct {
+	ID           primitive.ObjectID `bson:"_id"`
+	Checklist_id string             `json:"checklist_id"`
+	Due_date     string             `json:"due_date"`
+	Message      string             `json:"message"`
+	Resolved     bool               `json:"resolved"`
+	Created_at   time.Time          `json:"created_at"`
+	Alert_id     string             `json:"alert_id"`
+}
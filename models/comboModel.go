@@ -0,0 +1,27 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Combo is a bundle of foods sold together for Price. Ordered, it becomes
+// one order item per component, sharing a Combo_line_id, so each component
+// is prepared and stocked as usual while the bill shows a single line.
+type Combo struct {
+	ID         primitive.ObjectID `bson:"_id"`
+	Name       *string            `json:"name" validate:"required,min=2,max=100"`
+	Items      []ComboItem        `json:"items" validate:"required,min=2,dive"`
+	Price      *float64           `json:"price" validate:"required,gt=0"`
+	Active     *bool              `json:"active"`
+	Created_at time.Time          `json:"created_at"`
+	Updated_at time.Time          `json:"updated_at"`
+	Combo_id   string             `json:"combo_id"`
+}
+
+// ComboItem is a food of a combo, in the size it comes in (M by default).
+type ComboItem struct {
+	Food_id  *string `json:"food_id" validate:"required"`
+	Quantity *string `json:"quantity" validate:"omitempty,eq=S|eq=M|eq=L"`
+}
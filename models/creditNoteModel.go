@@ -0,0 +1,26 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// CreditNote records a refund against a finalized invoice. Its tax lines
+// reverse the refunded part of the invoice's tax lines.
+type CreditNote struct {
+	ID                 primitive.ObjectID `bson:"_id"`
+	Invoice_id         string             `json:"invoice_id"`
+	Invoice_number     string             `json:"invoice_number"`
+	Location           string             `json:"location"`
+	Credit_note_number string             `json:"credit_note_number"`
+	Fiscal_year        int                `json:"fiscal_year"`
+	Order_item_ids     []string           `json:"order_item_ids"`
+	Includes_fees      bool               `json:"includes_fees"`
+	Reason             *string            `json:"reason" validate:"required,min=2,max=500"`
+	Tax_lines          []TaxLine          `json:"tax_lines"`
+	Total              float64            `json:"total"`
+	Issued_by          *string            `json:"issued_by"`
+	Issued_at          time.Time          `json:"issued_at"`
+	Credit_note_id     string             `json:"credit_note_id"`
+}
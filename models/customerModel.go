@@ -0,0 +1,26 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Customer is a guest's profile, so staff can pull up a repeat guest and
+// their orders. Orders point at it with their Customer_id. A guest with an
+// account has their User_id as Customer_id; walk-in guests get a new id.
+// Phone is stored as digits with an optional leading +, and is unique.
+// Loyalty_points is the guest's balance, changed only through the loyalty
+// ledger.
+type Customer struct {
+	ID             primitive.ObjectID `bson:"_id"`
+	Name           *string            `json:"name" validate:"required,min=2,max=100"`
+	Phone          *string            `json:"phone" validate:"required_without=Email,omitempty,min=7,max=20"`
+	Email          *string            `json:"email" validate:"required_without=Phone,omitempty,email"`
+	Notes          *string            `json:"notes" validate:"omitempty,max=1000"`
+	User_id        *string            `json:"user_id"`
+	Loyalty_points int                `json:"loyalty_points"`
+	Created_at     time.Time          `json:"created_at"`
+	Updated_at     time.Time          `json:"updated_at"`
+	Customer_id    string             `json:"customer_id"`
+}
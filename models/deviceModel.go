@@ -0,0 +1,21 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Device is a staff app install that receives push notifications. Provider
+// is FCM or APNS, whichever issued Token. A token belongs to one user; a
+// device that signs in as someone else moves over to them.
+type Device struct {
+	ID         primitive.ObjectID `bson:"_id"`
+	User_id    string             `json:"user_id"`
+	Provider   *string            `json:"provider" validate:"required,oneof=FCM APNS"`
+	Token      *string            `json:"token" validate:"required,max=4096"`
+	Platform   *string            `json:"platform" validate:"omitempty,max=50"`
+	Created_at time.Time          `json:"created_at"`
+	Updated_at time.Time          `json:"updated_at"`
+	Device_id  string             `json:"device_id"`
+}
@@ -0,0 +1,107 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Discount is a promotion or a coupon. PROMOTION discounts apply to every
+// eligible order by themselves; COUPON discounts only once their Code is
+// added to the order. ITEM discounts come off each eligible item, ORDER
+// discounts off the subtotal. A discount that is not Stackable is exclusive
+// and never combines with other discounts at its scope. Max_amount caps what
+// the discount takes off a whole order. Menu_ids limits it to foods on those
+// menus. A coupon can be added to at most Max_uses orders in all and
+// Max_uses_per_customer orders of one customer; Times_used counts the
+// orders it is on.
+type Discount struct {
+	ID                    primitive.ObjectID `bson:"_id"`
+	Name                  *string            `json:"name" validate:"required,min=2,max=100"`
+	Kind                  *string            `json:"kind" validate:"required,eq=PROMOTION|eq=COUPON"`
+	Code                  *string            `json:"code" validate:"required_if=Kind COUPON,omitempty,min=3,max=40"`
+	Scope                 *string            `json:"scope" validate:"required,eq=ITEM|eq=ORDER"`
+	Food_ids              []string           `json:"food_ids"`
+	Category              *string            `json:"category"`
+	Menu_ids              []string           `json:"menu_ids"`
+	Percent_off           *float64           `json:"percent_off" validate:"required_without=Amount_off,excluded_with=Amount_off,omitempty,gt=0,lte=100"`
+	Amount_off            *float64           `json:"amount_off" validate:"omitempty,gt=0"`
+	Max_amount            *float64           `json:"max_amount" validate:"omitempty,gt=0"`
+	Min_subtotal          *float64           `json:"min_subtotal" validate:"omitempty,gte=0"`
+	Stackable             *bool              `json:"stackable"`
+	Priority              *int               `json:"priority"`
+	Max_uses              *int               `json:"max_uses" validate:"omitempty,gt=0"`
+	Max_uses_per_customer *int               `json:"max_uses_per_customer" validate:"omitempty,gt=0"`
+	Times_used            int                `json:"times_used"`
+	Effective_from        *time.Time         `json:"effective_from"`
+	Effective_to          *time.Time         `json:"effective_to"`
+	Active                *bool              `json:"active"`
+	Created_at            time.Time          `json:"created_at"`
+	Updated_at            time.Time          `json:"updated_at"`
+	Discount_id           string             `json:"discount_id"`
+}
+
+// OrderDiscount is a discount staff added to an order: a coupon by its
+// code, or a MANUAL discount, which needs a reason code and the manager who
+// approved it, and a reason when the code is OTHER. A manual ITEM discount
+// names the order item it is for. Applied_by is who added it.
+// UPSELL discounts are added when a guest accepts an upsell offer, and
+// LOYALTY discounts when they redeem loyalty points.
+type OrderDiscount struct {
+	Kind              *string   `json:"kind" validate:"required,eq=COUPON|eq=MANUAL"`
+	Code              *string   `json:"code" validate:"required_if=Kind COUPON"`
+	Scope             *string   `json:"scope" validate:"required_if=Kind MANUAL,omitempty,eq=ITEM|eq=ORDER"`
+	Order_item_id     *string   `json:"order_item_id" validate:"required_if=Scope ITEM"`
+	Percent_off       *float64  `json:"percent_off" validate:"omitempty,gt=0,lte=100"`
+	Amount_off        *float64  `json:"amount_off" validate:"omitempty,gt=0"`
+	Reason_code       *string   `json:"reason_code" validate:"required_if=Kind MANUAL,omitempty,eq=COMP|eq=SERVICE_RECOVERY|eq=STAFF_MEAL|eq=PRICE_MATCH|eq=OTHER"`
+	Reason            *string   `json:"reason" validate:"required_if=Reason_code OTHER,omitempty,max=200"`
+	Approved_by       *string   `json:"approved_by" validate:"required_if=Kind MANUAL"`
+	Applied_by        *string   `json:"applied_by"`
+	Added_at          time.Time `json:"added_at"`
+	Order_discount_id string    `json:"order_discount_id"`
+}
+
+// CouponCheck asks whether a coupon code can be used on an order at
+// checkout.
+type CouponCheck struct {
+	Code     *string `json:"code" validate:"required"`
+	Order_id *string `json:"order_id" validate:"required"`
+}
+
+// DiscountPolicy says how discounts combine at a location. Kinds are applied
+// in Application_order, item discounts before order discounts, each
+// percentage taking its share of what is left. Discounts of a kind in
+// Exclusive_kinds are exclusive whatever their Stackable flag. Where both
+// apply, the guest gets whichever is worth more: the exclusive discount or
+// the stackable ones together.
+// Max_discount_percent caps all discounts of an order together, as a
+// percentage of its undiscounted subtotal. Max_manual_percent is the most a
+// manual discount added by staff of a role may take off the item or order
+// it is for; roles not listed have no limit.
+type DiscountPolicy struct {
+	ID                   primitive.ObjectID `bson:"_id"`
+	Location             string             `json:"location"`
+	Application_order    []string           `json:"application_order" validate:"omitempty,unique,dive,eq=HAPPY_HOUR|eq=PROMOTION|eq=UPSELL|eq=COUPON|eq=MANUAL|eq=LOYALTY"`
+	Exclusive_kinds      []string           `json:"exclusive_kinds" validate:"omitempty,unique,dive,eq=HAPPY_HOUR|eq=PROMOTION|eq=UPSELL|eq=COUPON|eq=MANUAL|eq=LOYALTY"`
+	Max_coupons          *int               `json:"max_coupons" validate:"omitempty,gte=0"`
+	Max_discount_percent *float64           `json:"max_discount_percent" validate:"omitempty,gte=0,lte=100"`
+	Max_manual_percent   map[string]float64 `json:"max_manual_percent" validate:"omitempty,dive,keys,oneof=ADMIN MANAGER WAITER KITCHEN,endkeys,gte=0,lte=100"`
+	Created_at           time.Time          `json:"created_at"`
+	Updated_at           time.Time          `json:"updated_at"`
+}
+
+// OrderPricing itemizes what an order costs. Adjustments lists every
+// discount in the order it was applied, totalled across items. Tax_lines
+// break the tax out per category and rate; Tax_total is all of it, and
+// Total includes the exclusive part on top of the discounted prices.
+type OrderPricing struct {
+	Order_id       string            `json:"order_id"`
+	Subtotal       float64           `json:"subtotal"`
+	Adjustments    []PriceAdjustment `json:"adjustments"`
+	Discount_total float64           `json:"discount_total"`
+	Fees           []PriceAdjustment `json:"fees"`
+	Tax_lines      []TaxLine         `json:"tax_lines"`
+	Tax_total      float64           `json:"tax_total"`
+	Total          float64           `json:"total"`
+}
@@ -0,0 +1,27 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Expense is a small purchase paid in cash, either from petty cash or
+// straight out of a till drawer.
+type Expense struct {
+	ID                primitive.ObjectID `bson:"_id"`
+	Category          *string            `json:"category" validate:"required,eq=SUPPLIES|eq=INGREDIENTS|eq=REPAIRS|eq=CLEANING|eq=DELIVERY|eq=OTHER"`
+	Amount            *float64           `json:"amount" validate:"required,gt=0"`
+	Description       *string            `json:"description" validate:"required,max=500"`
+	Paid_from         *string            `json:"paid_from" validate:"required,eq=PETTY_CASH|eq=DRAWER"`
+	Drawer_session_id *string            `json:"drawer_session_id" validate:"required_if=Paid_from DRAWER"`
+	Submitted_by      *string            `json:"submitted_by" validate:"required"`
+	Receipt_url       *string            `json:"receipt_url"`
+	Status            string             `json:"status"`
+	Approver_id       *string            `json:"approver_id"`
+	Approved_at       *time.Time         `json:"approved_at"`
+	Expense_date      time.Time          `json:"expense_date"`
+	Created_at        time.Time          `json:"created_at"`
+	Updated_at        time.Time          `json:"updated_at"`
+	Expense_id        string             `json:"expense_id"`
+}
@@ -6,13 +6,51 @@ import (
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
+// Food is a dish on a menu. Allergens are the major allergens it contains
+// and Dietary_flags the diets it suits, both in lowercase. A food that is
+// not Available is 86ed; with Sold_out_until set it comes back on its own
+// at that time. Foods with Deleted_at set are soft deleted and can be
+// restored.
 type Food struct {
-	ID         primitive.ObjectID `bson:"_id"`
-	Name       *string            `json:"name" validate:"required,min=2,max=100"`
-	Price      *float64           `json:"price" validate:"required"`
-	Food_image *string            `json:"food_image" validate:"required"`
-	Created_at time.Time          `json:"created_at"`
-	Updated_at time.Time          `json:"updated_at"`
-	Food_id    string             `json:"food_id"`
-	Menu_id    *string            `json:"menu_id" validate:"required"`
+	ID              primitive.ObjectID `bson:"_id"`
+	Name            *string            `json:"name" validate:"required,min=2,max=100"`
+	Description     *string            `json:"description" validate:"omitempty,max=1000"`
+	Price           *float64           `json:"price" validate:"required"`
+	Food_image      *string            `json:"food_image" validate:"required"`
+	Image_variants  *ImageVariants     `json:"image_variants"`
+	Created_at      time.Time          `json:"created_at"`
+	Updated_at      time.Time          `json:"updated_at"`
+	Food_id         string             `json:"food_id"`
+	Menu_id         *string            `json:"menu_id" validate:"required"`
+	Category_id     *string            `json:"category_id"`
+	Tags            []string           `json:"tags" validate:"omitempty,max=20,dive,min=1,max=40"`
+	Available       *bool              `json:"available"`
+	Sold_out_until  *time.Time         `json:"sold_out_until"`
+	Substitutions   []Substitution     `json:"substitutions" validate:"omitempty,dive"`
+	Modifier_groups []string           `json:"modifier_groups" validate:"omitempty,unique"`
+	Allergens       []string           `json:"allergens" validate:"omitempty,dive,oneof=celery crustacean egg fish gluten lupin milk mollusc mustard peanut sesame soy sulphite tree_nut"`
+	Calories        *int               `json:"calories" validate:"omitempty,min=0,max=10000"`
+	Dietary_flags   []string           `json:"dietary_flags" validate:"omitempty,dive,oneof=vegan vegetarian gluten-free halal"`
+	Deleted_at      *time.Time         `json:"deleted_at"`
+}
+
+// Substitution is an approved swap of one recipe ingredient for another,
+// such as oat milk for milk. Quantity_factor converts the replaced
+// ingredient's quantity into the substitute's unit, 1 by default, and
+// Price_delta is added to the item's price.
+type Substitution struct {
+	Substitution_id string   `json:"substitution_id"`
+	Name            *string  `json:"name" validate:"required,min=2,max=100"`
+	Replaces_sku    *string  `json:"replaces_sku" validate:"required"`
+	Sku             *string  `json:"sku" validate:"required,nefield=Replaces_sku"`
+	Quantity_factor *float64 `json:"quantity_factor" validate:"omitempty,gt=0"`
+	Price_delta     *float64 `json:"price_delta"`
+}
+
+// ImageVariants are the URLs of a food's uploaded photo scaled down for
+// lists (Thumb), detail screens (Medium) and full-screen views (Full).
+type ImageVariants struct {
+	Thumb  string `json:"thumb"`
+	Medium string `json:"medium"`
+	Full   string `json:"full"`
 }
@@ -0,0 +1,26 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// IdempotencyRecord is the response to a create request sent with an
+// Idempotency-Key, kept until Expires_at so that retries of the request
+// get the same response instead of creating a duplicate. Keys are scoped
+// to the user sending them. Status is IN_PROGRESS until the first request
+// finishes, then DONE. While IN_PROGRESS, Expires_at is the end of the
+// request's lease rather than of the replay window.
+type IdempotencyRecord struct {
+	ID              primitive.ObjectID `bson:"_id"`
+	Key             string             `json:"key"`
+	User_id         string             `json:"user_id"`
+	Endpoint        string             `json:"endpoint"`
+	Request_hash    string             `json:"request_hash"`
+	Status          string             `json:"status"`
+	Response_status int                `json:"response_status"`
+	Response_body   []byte             `json:"response_body"`
+	Created_at      time.Time          `json:"created_at"`
+	Expires_at      time.Time          `json:"expires_at"`
+}
@@ -6,13 +6,41 @@ import (
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
+// Invoice bills an order; an order has one unless it was split into
+// several, see Split. Payment_due is the order's total as priced by the
+// server, refreshed when the invoice is finalized. Tip is what the guest added on top of it; it is not revenue
+// and is not taxed. At the till it can be given as Tip_percent of the
+// amount due instead. Payment_status is PENDING, PROCESSING while a wallet
+// payment is being charged, or PAID.
 type Invoice struct {
-	ID               primitive.ObjectID `bson:"_id"`
-	Invoice_id       string             `json:"invoice_id"`
-	Order_id         string             `json:"order_id"`
-	Payment_method   *string            `json:"payment_method" validate:"eq=CARD|eq=CASH|eq="`
-	Payment_status   *string            `json:"payment_status" validate:"required,eq=PENDING|eq=PAID"`
-	Payment_due_date time.Time          `json:"payment_due_date"`
-	Created_at       time.Time          `json:"created_at"`
-	Updated_at       time.Time          `json:"updated_at"`
+	ID                primitive.ObjectID `bson:"_id"`
+	Invoice_id        string             `json:"invoice_id"`
+	Order_id          string             `json:"order_id"`
+	Payment_method    *string            `json:"payment_method" validate:"eq=CARD|eq=CASH|eq="`
+	Payment_status    *string            `json:"payment_status" validate:"required,eq=PENDING|eq=PAID"`
+	Payment_due       *float64           `json:"payment_due"`
+	Payment_due_date  time.Time          `json:"payment_due_date"`
+	Drawer_session_id *string            `json:"drawer_session_id"`
+	Location          *string            `json:"location"`
+	Invoice_number    *string            `json:"invoice_number"`
+	Fiscal_year       *int               `json:"fiscal_year"`
+	Finalized_at      *time.Time         `json:"finalized_at"`
+	Tax_lines         []TaxLine          `json:"tax_lines"`
+	Cash_rounding     *float64           `json:"cash_rounding"`
+	Deposit_credit    *float64           `json:"deposit_credit"`
+	Tip               *float64           `json:"tip" validate:"omitempty,gte=0"`
+	Tip_percent       *float64           `json:"tip_percent,omitempty" bson:"-" validate:"omitempty,gte=0,lte=100"`
+	Split             *InvoiceSplit      `json:"split"`
+	Created_at        time.Time          `json:"created_at"`
+	Updated_at        time.Time          `json:"updated_at"`
+}
+
+// InvoiceSplit marks an invoice as part Part of the Parts invoices a check
+// was split into. Order_item_ids are the items it bills; an even split
+// lists none and bills an equal share of every item. The invoice's
+// Payment_due and Tax_lines are fixed when the check is split.
+type InvoiceSplit struct {
+	Part           int      `json:"part"`
+	Parts          int      `json:"parts"`
+	Order_item_ids []string `json:"order_item_ids"`
 }
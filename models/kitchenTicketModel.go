@@ -0,0 +1,52 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// KitchenTicket is what a station sees on the kitchen display for the items
+// of an order it prepares. Status is OPEN until the ticket is bumped. A
+// ticket with an Allergy_alert cannot be bumped before a chef acknowledged
+// it.
+type KitchenTicket struct {
+	ID                primitive.ObjectID  `bson:"_id"`
+	Order_id          string              `json:"order_id"`
+	Table_id          *string             `json:"table_id"`
+	Station           string              `json:"station"`
+	Items             []KitchenTicketItem `json:"items"`
+	Status            string              `json:"status"`
+	Allergy_alert     bool                `json:"allergy_alert"`
+	Allergies         []string            `json:"allergies"`
+	Acknowledged_by   *string             `json:"acknowledged_by"`
+	Acknowledged_at   *time.Time          `json:"acknowledged_at"`
+	Bumped_by         *string             `json:"bumped_by"`
+	Bumped_at         *time.Time          `json:"bumped_at"`
+	Created_at        time.Time           `json:"created_at"`
+	Updated_at        time.Time           `json:"updated_at"`
+	Kitchen_ticket_id string              `json:"kitchen_ticket_id"`
+}
+
+type KitchenTicketItem struct {
+	Order_item_id string   `json:"order_item_id"`
+	Food_id       string   `json:"food_id"`
+	Name          string   `json:"name"`
+	Quantity      string   `json:"quantity"`
+	Allergy_note  string   `json:"allergy_note,omitempty"`
+	Substitutions []string `json:"substitutions,omitempty"`
+	Modifiers     []string `json:"modifiers,omitempty"`
+}
+
+// AllergyAcknowledgment records that a chef saw the allergy alert of a
+// ticket, and what it said at the time.
+type AllergyAcknowledgment struct {
+	ID                primitive.ObjectID `bson:"_id"`
+	Kitchen_ticket_id string             `json:"kitchen_ticket_id"`
+	Order_id          string             `json:"order_id"`
+	Station           string             `json:"station"`
+	Allergies         []string           `json:"allergies"`
+	Allergy_notes     []string           `json:"allergy_notes"`
+	Acknowledged_by   string             `json:"acknowledged_by"`
+	Acknowledged_at   time.Time          `json:"acknowledged_at"`
+}
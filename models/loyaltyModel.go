@@ -0,0 +1,30 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// LoyaltyEntry is one change to a customer's loyalty points, kept for
+// audits: points EARNed on a paid invoice, REDEEMed against an open one or
+// RETURNed when a redemption is taken off the order again. Points is
+// negative for redemptions, and Amount is what they took off the bill.
+type LoyaltyEntry struct {
+	ID               primitive.ObjectID `bson:"_id"`
+	Customer_id      string             `json:"customer_id"`
+	Kind             string             `json:"kind"`
+	Points           int                `json:"points"`
+	Amount           *float64           `json:"amount"`
+	Invoice_id       string             `json:"invoice_id"`
+	Order_id         string             `json:"order_id"`
+	Staff_id         *string            `json:"staff_id"`
+	Created_at       time.Time          `json:"created_at"`
+	Loyalty_entry_id string             `json:"loyalty_entry_id"`
+}
+
+// LoyaltyRedemption asks to spend a customer's points on an open invoice.
+type LoyaltyRedemption struct {
+	Invoice_id *string `json:"invoice_id" validate:"required"`
+	Points     *int    `json:"points" validate:"required,gt=0"`
+}
@@ -0,0 +1,23 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ManagerNote is a timestamped entry in the manager log, used for shift
+// handovers and for recording incidents.
+type ManagerNote struct {
+	ID              primitive.ObjectID `bson:"_id"`
+	Location        *string            `json:"location"`
+	Shift           *string            `json:"shift" validate:"required,eq=OPENING|eq=MID|eq=CLOSING"`
+	Author_id       *string            `json:"author_id" validate:"required"`
+	Body            *string            `json:"body" validate:"required,min=2,max=5000"`
+	Tags            []string           `json:"tags" validate:"dive,eq=EIGHTY_SIXED|eq=CALL_OUT|eq=VIP_VISIT|eq=INCIDENT|eq=MAINTENANCE|eq=GUEST_COMPLAINT|eq=GENERAL"`
+	Food_ids        []string           `json:"food_ids"`
+	Business_date   string             `json:"business_date"`
+	Created_at      time.Time          `json:"created_at"`
+	Updated_at      time.Time          `json:"updated_at"`
+	Manager_note_id string             `json:"manager_note_id"`
+}
@@ -0,0 +1,26 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type BoardSlide struct {
+	Type             string `json:"type" validate:"required,eq=CATEGORY|eq=MENU|eq=SPECIALS"`
+	Title            string `json:"title"`
+	Category         string `json:"category"`
+	Menu_id          string `json:"menu_id"`
+	Duration_seconds int    `json:"duration_seconds" validate:"required,gte=3,lte=600"`
+}
+
+type MenuBoard struct {
+	ID              primitive.ObjectID `bson:"_id"`
+	Name            *string            `json:"name" validate:"required,min=2,max=100"`
+	Slides          []BoardSlide       `json:"slides" validate:"required,min=1,dive"`
+	Currency_symbol *string            `json:"currency_symbol" validate:"omitempty,max=4"`
+	Poll_seconds    *int               `json:"poll_seconds" validate:"omitempty,gte=5,lte=3600"`
+	Created_at      time.Time          `json:"created_at"`
+	Updated_at      time.Time          `json:"updated_at"`
+	Board_id        string             `json:"board_id"`
+}
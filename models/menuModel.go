@@ -6,13 +6,22 @@ import (
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
+// Menu is a set of foods served between Start_Date and End_Date; a menu
+// without dates always runs. Active says whether it is running now and is
+// kept up to date by the menu-activation job. Only PUBLISHED menus run:
+// new menus are DRAFTs until published, and ARCHIVED ones are retired.
+// Version is the latest version published. Deleted menus never run.
 type Menu struct {
 	ID         primitive.ObjectID `bson:"_id"`
-	Name       string             `json:"name" validate:"requried"`
-	Category   string             `json:"category" validate:"requried"`
+	Name       string             `json:"name" validate:"required"`
+	Category   string             `json:"category" validate:"required"`
 	Start_Date *time.Time         `json:"start_date"`
 	End_Date   *time.Time         `json:"end_date"`
+	Active     bool               `json:"active"`
+	Status     string             `json:"status"`
+	Version    int                `json:"version"`
 	Created_at time.Time          `json:"created_at"`
 	Updated_at time.Time          `json:"updated_at"`
-	Menu_id    string             `json:"menu_id" validate:"requried"`
+	Deleted_at *time.Time         `json:"deleted_at"`
+	Menu_id    string             `json:"menu_id"`
 }
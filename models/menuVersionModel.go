@@ -0,0 +1,22 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// MenuVersion is a snapshot of a menu and its foods taken when it was
+// published. Rolled_back_from is set on versions published by rolling back
+// to an earlier one.
+type MenuVersion struct {
+	ID               primitive.ObjectID `bson:"_id"`
+	Menu_id          string             `json:"menu_id"`
+	Version          int                `json:"version"`
+	Menu             Menu               `json:"menu"`
+	Foods            []Food             `json:"foods"`
+	Rolled_back_from *int               `json:"rolled_back_from"`
+	Published_by     string             `json:"published_by"`
+	Published_at     time.Time          `json:"published_at"`
+	Menu_version_id  string             `json:"menu_version_id"`
+}
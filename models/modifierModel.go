@@ -0,0 +1,29 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ModifierGroup is a choice offered on the foods it is attached to, such as
+// a size or add-ons. Guests pick between Min_select and Max_select of its
+// options; a group with Min_select 1 must be answered.
+type ModifierGroup struct {
+	ID                primitive.ObjectID `bson:"_id"`
+	Name              *string            `json:"name" validate:"required,min=2,max=100"`
+	Min_select        *int               `json:"min_select" validate:"omitempty,min=0"`
+	Max_select        *int               `json:"max_select" validate:"omitempty,min=1"`
+	Options           []ModifierOption   `json:"options" validate:"required,min=1,dive"`
+	Created_at        time.Time          `json:"created_at"`
+	Updated_at        time.Time          `json:"updated_at"`
+	Modifier_group_id string             `json:"modifier_group_id"`
+}
+
+// ModifierOption is one answer of a modifier group. Price_delta is added to
+// the item's price when it is picked.
+type ModifierOption struct {
+	Modifier_option_id string   `json:"modifier_option_id"`
+	Name               *string  `json:"name" validate:"required,min=1,max=100"`
+	Price_delta        *float64 `json:"price_delta"`
+}
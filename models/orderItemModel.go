@@ -6,6 +6,13 @@ import (
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
+// OrderItem is an item on an order. Server_id is set when the item is moved
+// to another check, keeping the sale with the server of the order it was
+// rung up on. Allergy_note flags the item's kitchen ticket. Substitutions
+// are the ids of the food's approved substitutions the guest asked for, and
+// Modifiers the ids of the modifier options picked. Items ordered as part of
+// a combo carry its Combo_id and share a Combo_line_id; to order a combo,
+// send an item with only Combo_id and it is expanded into its foods.
 type OrderItem struct {
 	ID            primitive.ObjectID `bson:"_id"`
 	Quantity      *string            `json:"quantity" validate:"required,eq=S|eq=M|eq=L"`
@@ -15,4 +22,12 @@ type OrderItem struct {
 	Food_id       *string            `json:"food_id" validate:"required"`
 	Order_item_id string             `json:"order_item_id"`
 	Order_id      string             `json:"order_id" validate:"required"`
+	Adjustments   []PriceAdjustment  `json:"adjustments"`
+	Client_uuid   *string            `json:"client_uuid" validate:"omitempty,uuid"`
+	Server_id     *string            `json:"server_id"`
+	Allergy_note  *string            `json:"allergy_note" validate:"omitempty,max=200"`
+	Substitutions []string           `json:"substitutions" validate:"omitempty,unique"`
+	Modifiers     []string           `json:"modifiers" validate:"omitempty,unique"`
+	Combo_id      *string            `json:"combo_id"`
+	Combo_line_id string             `json:"combo_line_id,omitempty"`
 }
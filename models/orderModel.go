@@ -6,11 +6,42 @@ import (
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
+// Order is a check. Status is OPEN, or MERGED once the check was merged
+// into Merged_into; orders created before statuses existed have none.
+// Customer_id is the guest's customer profile or account. Customer_phone
+// is texted when a takeout order is ready, falling back to the guest's
+// phone. Opened_by is the staff member who
+// opened the order; it is nil for orders opened by guests, by phone or
+// before it was recorded.
+// Priced_at is set when the invoice is finalized or the check is split,
+// and the discounts were stored on the items for good. Deleted_at is set on orders voided by
+// soft delete.
 type Order struct {
-	ID         primitive.ObjectID `bson:"_id"`
-	Order_Date time.Time          `json:"order_date" validate:"required"`
-	Created_at time.Time          `json:"created_at"`
-	Updated_at time.Time          `json:"updated_at"`
-	Order_id   string             `json:"order_id"`
-	Table_id   *string            `json:"table_id" validate:"required"`
+	ID             primitive.ObjectID `bson:"_id"`
+	Order_Date     time.Time          `json:"order_date" validate:"required"`
+	Created_at     time.Time          `json:"created_at"`
+	Updated_at     time.Time          `json:"updated_at"`
+	Order_id       string             `json:"order_id"`
+	Table_id       *string            `json:"table_id" validate:"required"`
+	Channel        *string            `json:"channel" validate:"omitempty,eq=DINE_IN|eq=TAKEOUT|eq=DELIVERY"`
+	Fees           []PriceAdjustment  `json:"fees"`
+	Customer_id    *string            `json:"customer_id"`
+	Customer_phone *string            `json:"customer_phone" validate:"omitempty,min=7,max=20"`
+	Client_uuid    *string            `json:"client_uuid" validate:"omitempty,uuid"`
+	Terminal_id    *string            `json:"terminal_id"`
+	Server_id      *string            `json:"server_id"`
+	Opened_by      *string            `json:"opened_by"`
+	Party_size     *int               `json:"party_size" validate:"omitempty,gt=0"`
+	Status         string             `json:"status"`
+	Merged_into    *string            `json:"merged_into"`
+	Discounts      []OrderDiscount    `json:"discounts"`
+	Priced_at      *time.Time         `json:"priced_at"`
+	Deleted_at     *time.Time         `json:"deleted_at"`
+}
+
+// OrderSplit divides a check over several invoices, either into Parts
+// equal shares or by Groups of order item ids, one group per invoice.
+type OrderSplit struct {
+	Parts  *int       `json:"parts" validate:"required_without=Groups,excluded_with=Groups,omitempty,min=2,max=20"`
+	Groups [][]string `json:"groups" validate:"required_without=Parts,omitempty,min=2,max=20,dive,min=1,dive,required"`
 }
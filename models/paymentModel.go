@@ -0,0 +1,41 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Payment is money taken through the payment gateway for an order. Wallet
+// is APPLE_PAY or GOOGLE_PAY for network-tokenized wallet payments. Card
+// payments for an invoice start PENDING with the client secret of their
+// payment intent and become PAID or FAILED when the gateway's webhook
+// reports the outcome; refunds reported later mark them REFUNDED. A charge
+// that does not settle its invoice is MISMATCHED, and a pending payment
+// superseded by one of another amount is CANCELLED. Amount includes the
+// guest's Tip.
+type Payment struct {
+	ID              primitive.ObjectID `bson:"_id"`
+	Order_id        string             `json:"order_id"`
+	Invoice_id      *string            `json:"invoice_id"`
+	Method          string             `json:"method"`
+	Wallet          *string            `json:"wallet"`
+	Amount          float64            `json:"amount"`
+	Tip             float64            `json:"tip"`
+	Currency        string             `json:"currency"`
+	Gateway         string             `json:"gateway"`
+	Charge_id       string             `json:"charge_id"`
+	Status          string             `json:"status"`
+	Client_secret   *string            `json:"client_secret,omitempty"`
+	Refunded_amount *float64           `json:"refunded_amount"`
+	Created_at      time.Time          `json:"created_at"`
+	Updated_at      time.Time          `json:"updated_at"`
+	Payment_id      string             `json:"payment_id"`
+}
+
+// TipRequest is the tip a guest adds when paying: a fixed Tip_amount or a
+// Tip_percent of the amount due.
+type TipRequest struct {
+	Tip_amount  *float64 `json:"tip_amount" validate:"omitempty,gte=0,excluded_with=Tip_percent"`
+	Tip_percent *float64 `json:"tip_percent" validate:"omitempty,gte=0,lte=100"`
+}
@@ -0,0 +1,28 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// MenuPersonalization is what the customers of a segment order most, as
+// worked out by the nightly personalization job. Segment_id is empty for
+// the one covering every customer, used for customers in no segment.
+type MenuPersonalization struct {
+	ID               primitive.ObjectID `bson:"_id"`
+	Segment_id       string             `json:"segment_id"`
+	Customers        int                `json:"customers"`
+	Category_order   []string           `json:"category_order"`
+	Popular_food_ids []string           `json:"popular_food_ids"`
+	Computed_at      time.Time          `json:"computed_at"`
+}
+
+// CustomerSegment is the segment whose personalization a customer gets: the
+// first segment, by creation, they were a member of on the last run.
+type CustomerSegment struct {
+	ID          primitive.ObjectID `bson:"_id"`
+	Customer_id string             `json:"customer_id"`
+	Segment_id  string             `json:"segment_id"`
+	Computed_at time.Time          `json:"computed_at"`
+}
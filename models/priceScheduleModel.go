@@ -0,0 +1,31 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type PriceSchedule struct {
+	ID                primitive.ObjectID `bson:"_id"`
+	Name              *string            `json:"name" validate:"required,min=2,max=100"`
+	Food_ids          []string           `json:"food_ids"`
+	Category          *string            `json:"category"`
+	Discount_percent  *float64           `json:"discount_percent" validate:"required,gt=0,lte=100"`
+	Days              []string           `json:"days" validate:"required,min=1,dive,oneof=MON TUE WED THU FRI SAT SUN"`
+	Start_time        *string            `json:"start_time" validate:"required,len=5"`
+	End_time          *string            `json:"end_time" validate:"required,len=5"`
+	Effective_from    *time.Time         `json:"effective_from"`
+	Effective_to      *time.Time         `json:"effective_to"`
+	Active            *bool              `json:"active"`
+	Created_at        time.Time          `json:"created_at"`
+	Updated_at        time.Time          `json:"updated_at"`
+	Price_schedule_id string             `json:"price_schedule_id"`
+}
+
+type PriceAdjustment struct {
+	Type        string  `json:"type"`
+	Source_id   string  `json:"source_id"`
+	Description string  `json:"description"`
+	Amount      float64 `json:"amount"`
+}
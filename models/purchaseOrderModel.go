@@ -0,0 +1,42 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type PurchaseOrderLine struct {
+	Sku         *string  `json:"sku" validate:"required"`
+	Description *string  `json:"description"`
+	Quantity    *float64 `json:"quantity" validate:"required,gt=0"`
+	Unit_price  *float64 `json:"unit_price" validate:"required,min=0"`
+}
+
+type PurchaseOrder struct {
+	ID                primitive.ObjectID  `bson:"_id"`
+	Po_number         *string             `json:"po_number" validate:"required"`
+	Supplier          *string             `json:"supplier" validate:"required"`
+	Lines             []PurchaseOrderLine `json:"lines" validate:"required,min=1,dive"`
+	Expected_at       *time.Time          `json:"expected_at"`
+	Created_at        time.Time           `json:"created_at"`
+	Updated_at        time.Time           `json:"updated_at"`
+	Purchase_order_id string              `json:"purchase_order_id"`
+}
+
+type ReceivedLine struct {
+	Sku      *string  `json:"sku" validate:"required"`
+	Quantity *float64 `json:"quantity" validate:"required,min=0"`
+}
+
+// ReceivingRecord is what was actually counted in at the back door against
+// a purchase order. A PO may be received in several deliveries.
+type ReceivingRecord struct {
+	ID                  primitive.ObjectID `bson:"_id"`
+	Purchase_order_id   string             `json:"purchase_order_id"`
+	Lines               []ReceivedLine     `json:"lines" validate:"required,min=1,dive"`
+	Received_by         *string            `json:"received_by" validate:"required"`
+	Received_at         time.Time          `json:"received_at"`
+	Created_at          time.Time          `json:"created_at"`
+	Receiving_record_id string             `json:"receiving_record_id"`
+}
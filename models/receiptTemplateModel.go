@@ -0,0 +1,26 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ReceiptTemplate customizes printed and PDF receipts. The "default"
+// location is the base template; other locations only store the fields they
+// override.
+type ReceiptTemplate struct {
+	ID            primitive.ObjectID `bson:"_id"`
+	Location      string             `json:"location"`
+	Business_name *string            `json:"business_name" validate:"omitempty,max=100"`
+	Header        *string            `json:"header" validate:"omitempty,max=500"`
+	Footer        *string            `json:"footer" validate:"omitempty,max=500"`
+	Logo_url      *string            `json:"logo_url"`
+	Tax_id        *string            `json:"tax_id" validate:"omitempty,max=50"`
+	Promo_message *string            `json:"promo_message" validate:"omitempty,max=300"`
+	Paper_width   *int               `json:"paper_width" validate:"omitempty,oneof=32 42 48"`
+	Locale        *string            `json:"locale"`
+	Currency      *string            `json:"currency" validate:"omitempty,len=3,uppercase"`
+	Created_at    time.Time          `json:"created_at"`
+	Updated_at    time.Time          `json:"updated_at"`
+}
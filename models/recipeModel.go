@@ -0,0 +1,39 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Recipe says how a food is prepped ahead of service: at which station, in
+// batches of how many units and from which ingredients per batch.
+// Prepped_on_hand is the count of ready units left from earlier prep.
+type Recipe struct {
+	ID              primitive.ObjectID `bson:"_id"`
+	Food_id         string             `json:"food_id"`
+	Station         *string            `json:"station" validate:"required"`
+	Batch_size      *int               `json:"batch_size" validate:"omitempty,gt=0"`
+	Ingredients     []RecipeIngredient `json:"ingredients" validate:"omitempty,dive"`
+	Prepped_on_hand *float64           `json:"prepped_on_hand" validate:"omitempty,gte=0"`
+	Created_at      time.Time          `json:"created_at"`
+	Updated_at      time.Time          `json:"updated_at"`
+}
+
+type RecipeIngredient struct {
+	Sku      *string  `json:"sku" validate:"required"`
+	Quantity *float64 `json:"quantity" validate:"required,gt=0"`
+}
+
+// InventoryItem is the stock of one ingredient, counted in its unit.
+type InventoryItem struct {
+	ID         primitive.ObjectID `bson:"_id"`
+	Sku        string             `json:"sku"`
+	Location   string             `json:"location"`
+	Name       *string            `json:"name" validate:"omitempty,max=150"`
+	Unit       *string            `json:"unit" validate:"omitempty,max=20"`
+	On_hand    *float64           `json:"on_hand" validate:"required,gte=0"`
+	Counted_at time.Time          `json:"counted_at"`
+	Created_at time.Time          `json:"created_at"`
+	Updated_at time.Time          `json:"updated_at"`
+}
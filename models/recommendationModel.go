@@ -0,0 +1,28 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// FoodRecommendations are the foods most often ordered together with a
+// food, worked out by the nightly recommendations job. Orders is how many
+// orders in the window had the food.
+type FoodRecommendations struct {
+	ID          primitive.ObjectID `bson:"_id"`
+	Food_id     string             `json:"food_id"`
+	Orders      int                `json:"orders"`
+	Items       []Recommendation   `json:"items"`
+	Computed_at time.Time          `json:"computed_at"`
+}
+
+// Recommendation is a food bought together with another. Confidence is the
+// share of the other food's orders that also had this one; Lift compares
+// that with how often this food is ordered at all.
+type Recommendation struct {
+	Food_id    string  `json:"food_id"`
+	Co_orders  int     `json:"co_orders"`
+	Confidence float64 `json:"confidence"`
+	Lift       float64 `json:"lift"`
+}
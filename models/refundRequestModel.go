@@ -0,0 +1,23 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// RefundRequest asks a manager to refund an invoice, or some of its items
+// when Order_item_ids is set. Status is PENDING until a credit note is
+// issued for the invoice, which makes it CREDITED.
+type RefundRequest struct {
+	ID                primitive.ObjectID `bson:"_id"`
+	Invoice_id        string             `json:"invoice_id"`
+	Order_item_ids    []string           `json:"order_item_ids"`
+	Reason            *string            `json:"reason" validate:"required,min=2,max=500"`
+	Status            string             `json:"status"`
+	Requested_by      string             `json:"requested_by"`
+	Credit_note_id    *string            `json:"credit_note_id"`
+	Created_at        time.Time          `json:"created_at"`
+	Updated_at        time.Time          `json:"updated_at"`
+	Refund_request_id string             `json:"refund_request_id"`
+}
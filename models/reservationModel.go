@@ -0,0 +1,127 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type Reservation struct {
+	ID               primitive.ObjectID  `bson:"_id"`
+	Location         *string             `json:"location"`
+	Table_id         *string             `json:"table_id"`
+	Customer_name    *string             `json:"customer_name" validate:"required,min=2,max=100"`
+	Customer_phone   *string             `json:"customer_phone" validate:"omitempty,min=7,max=20"`
+	Customer_email   *string             `json:"customer_email" validate:"omitempty,email"`
+	Party_size       *int                `json:"party_size" validate:"required,gt=0,lte=100"`
+	Reserved_at      *time.Time          `json:"reserved_at" validate:"required"`
+	Duration_minutes *int                `json:"duration_minutes" validate:"omitempty,gt=0,lte=720"`
+	Notes            *string             `json:"notes" validate:"omitempty,max=1000"`
+	Status           string              `json:"status"`
+	Deposit          *ReservationDeposit `json:"deposit"`
+	Guarantee        *CardGuarantee      `json:"guarantee"`
+	No_show_fee      *float64            `json:"no_show_fee"`
+	Order_id         *string             `json:"order_id"`
+	// Source is DIRECT for bookings taken here, otherwise the platform
+	// the booking was imported from.
+	Source       string `json:"source"`
+	Connector_id string `json:"connector_id,omitempty"`
+	External_id  string `json:"external_id,omitempty"`
+	// Payment_token is the tokenized card used for the deposit. It is
+	// passed to the gateway and never stored.
+	Payment_token  *string    `json:"payment_token,omitempty" bson:"-"`
+	Token          string     `json:"-"`
+	Reminders_sent []string   `json:"reminders_sent"`
+	Confirmed_at   *time.Time `json:"confirmed_at"`
+	Cancelled_by   *string    `json:"cancelled_by"`
+	Cancelled_at   *time.Time `json:"cancelled_at"`
+	Created_at     time.Time  `json:"created_at"`
+	Updated_at     time.Time  `json:"updated_at"`
+	Reservation_id string     `json:"reservation_id"`
+	// Reminders_opt_out stops reminders for this booking; the guest can
+	// also turn them off from the link in a reminder.
+	Reminders_opt_out *bool `json:"reminders_opt_out"`
+}
+
+// ReservationReminder records a reminder sent, or tried, for a
+// reservation. Offsets are the reminder windows it covered, such as "24h",
+// and Channels those it was delivered by.
+type ReservationReminder struct {
+	ID                      primitive.ObjectID `bson:"_id"`
+	Reservation_id          string             `json:"reservation_id"`
+	Offsets                 []string           `json:"offsets"`
+	Channels                []string           `json:"channels"`
+	Status                  string             `json:"status"`
+	Error                   string             `json:"error,omitempty"`
+	Created_at              time.Time          `json:"created_at"`
+	Reservation_reminder_id string             `json:"reservation_reminder_id"`
+}
+
+// ReservationDeposit is a prepayment collected at booking. It is applied to
+// the invoice of the visit or refunded on cancellation.
+type ReservationDeposit struct {
+	Amount          float64 `json:"amount"`
+	Currency        string  `json:"currency"`
+	Gateway         string  `json:"gateway"`
+	Charge_id       string  `json:"charge_id"`
+	Status          string  `json:"status"`
+	Refunded_amount float64 `json:"refunded_amount"`
+	Invoice_id      string  `json:"invoice_id,omitempty"`
+}
+
+// CardGuarantee is a hold for the no-show fee placed on the guest's card at
+// booking. It is captured on a no-show and released otherwise.
+type CardGuarantee struct {
+	Amount           float64 `json:"amount"`
+	Currency         string  `json:"currency"`
+	Gateway          string  `json:"gateway"`
+	Authorization_id string  `json:"authorization_id"`
+	Status           string  `json:"status"`
+}
+
+// GuestHistory counts the no-shows of a guest, identified by email or
+// phone number.
+type GuestHistory struct {
+	ID              primitive.ObjectID `bson:"_id"`
+	Guest_key       string             `json:"guest_key"`
+	No_shows        int                `json:"no_shows"`
+	Last_no_show_at *time.Time         `json:"last_no_show_at"`
+}
+
+// DepositPolicy decides when a location asks for a deposit, how much of it
+// is refunded on cancellation and what a no-show costs.
+type DepositPolicy struct {
+	ID                     primitive.ObjectID `bson:"_id"`
+	Location               string             `json:"location"`
+	Min_party_size         *int               `json:"min_party_size" validate:"omitempty,gt=0"`
+	Peak_slots             []PeakSlot         `json:"peak_slots" validate:"omitempty,dive"`
+	Amount_per_guest       *float64           `json:"amount_per_guest" validate:"omitempty,gt=0"`
+	Full_refund_hours      *int               `json:"full_refund_hours" validate:"omitempty,gte=0"`
+	Partial_refund_percent *float64           `json:"partial_refund_percent" validate:"omitempty,gte=0,lte=100"`
+	No_show_grace_minutes  *int               `json:"no_show_grace_minutes" validate:"omitempty,gte=0"`
+	No_show_fee_per_guest  *float64           `json:"no_show_fee_per_guest" validate:"omitempty,gt=0"`
+	Card_guarantee         *bool              `json:"card_guarantee"`
+	No_show_threshold      *int               `json:"no_show_threshold" validate:"omitempty,gt=0"`
+	Created_at             time.Time          `json:"created_at"`
+	Updated_at             time.Time          `json:"updated_at"`
+}
+
+// PeakSlot is a weekly time window in which every booking needs a deposit.
+type PeakSlot struct {
+	Days       []string `json:"days" validate:"required,min=1,dive,oneof=MON TUE WED THU FRI SAT SUN"`
+	Start_time string   `json:"start_time" validate:"required,len=5"`
+	End_time   string   `json:"end_time" validate:"required,len=5"`
+}
+
+// ReservationCapacity is how many covers a location seats per time slot
+// while open. Availability offered to booking platforms is derived from it.
+type ReservationCapacity struct {
+	ID           primitive.ObjectID `bson:"_id"`
+	Location     string             `json:"location"`
+	Max_covers   *int               `json:"max_covers" validate:"required,gt=0"`
+	Slot_minutes *int               `json:"slot_minutes" validate:"omitempty,gte=5,lte=240"`
+	Open_time    *string            `json:"open_time" validate:"omitempty,len=5"`
+	Close_time   *string            `json:"close_time" validate:"omitempty,len=5"`
+	Created_at   time.Time          `json:"created_at"`
+	Updated_at   time.Time          `json:"updated_at"`
+}
@@ -0,0 +1,23 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type Review struct {
+	ID          primitive.ObjectID `bson:"_id"`
+	Source      string             `json:"source"`
+	External_id string             `json:"external_id"`
+	Author      string             `json:"author"`
+	Rating      float64            `json:"rating"`
+	Text        string             `json:"text"`
+	Url         string             `json:"url"`
+	Review_time time.Time          `json:"review_time"`
+	Food_ids    []string           `json:"food_ids"`
+	Sentiment   float64            `json:"sentiment"`
+	Created_at  time.Time          `json:"created_at"`
+	Updated_at  time.Time          `json:"updated_at"`
+	Review_id   string             `json:"review_id"`
+}
@@ -0,0 +1,35 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// SegmentRules are combined with AND; unset rules are ignored.
+type SegmentRules struct {
+	Min_days_since_last_order *int     `json:"min_days_since_last_order" validate:"omitempty,gte=0"`
+	Max_days_since_last_order *int     `json:"max_days_since_last_order" validate:"omitempty,gte=0"`
+	Window_days               *int     `json:"window_days" validate:"omitempty,gt=0"`
+	Min_spend                 *float64 `json:"min_spend" validate:"omitempty,gte=0"`
+	Min_orders                *int     `json:"min_orders" validate:"omitempty,gte=0"`
+}
+
+type Segment struct {
+	ID          primitive.ObjectID `bson:"_id"`
+	Name        *string            `json:"name" validate:"required,min=2,max=100"`
+	Description string             `json:"description"`
+	Rules       *SegmentRules      `json:"rules" validate:"required"`
+	Created_at  time.Time          `json:"created_at"`
+	Updated_at  time.Time          `json:"updated_at"`
+	Segment_id  string             `json:"segment_id"`
+}
+
+type SegmentMember struct {
+	Customer_id     string    `json:"customer_id" bson:"_id"`
+	Last_order_at   time.Time `json:"last_order_at" bson:"last_order_at"`
+	Orders          int       `json:"orders" bson:"orders"`
+	Spend           float64   `json:"spend" bson:"spend"`
+	Lifetime_orders int       `json:"lifetime_orders" bson:"lifetime_orders"`
+	Lifetime_spend  float64   `json:"lifetime_spend" bson:"lifetime_spend"`
+}
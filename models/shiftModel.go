@@ -0,0 +1,25 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Shift is a scheduled shift on the staff rota, with the floor section a
+// server covers. Hours actually worked are recorded separately as time clock
+// records.
+type Shift struct {
+	ID          primitive.ObjectID `bson:"_id"`
+	Employee_id *string            `json:"employee_id" validate:"required"`
+	Location    *string            `json:"location"`
+	Role        *string            `json:"role" validate:"omitempty,max=100"`
+	Section     *string            `json:"section" validate:"omitempty,max=50"`
+	Start       *time.Time         `json:"start" validate:"required"`
+	End         *time.Time         `json:"end" validate:"required,gtfield=Start"`
+	Notes       *string            `json:"notes" validate:"omitempty,max=1000"`
+	Status      string             `json:"status"`
+	Created_at  time.Time          `json:"created_at"`
+	Updated_at  time.Time          `json:"updated_at"`
+	Shift_id    string             `json:"shift_id"`
+}
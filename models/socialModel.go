@@ -0,0 +1,33 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type SocialAccount struct {
+	ID                primitive.ObjectID `bson:"_id"`
+	Name              *string            `json:"name" validate:"required,min=2,max=100"`
+	Platform          *string            `json:"platform" validate:"required,eq=FACEBOOK|eq=INSTAGRAM|eq=WEBHOOK"`
+	Page_id           string             `json:"page_id"`
+	Access_token      string             `json:"access_token,omitempty"`
+	Webhook_url       string             `json:"webhook_url" validate:"omitempty,url"`
+	Active            *bool              `json:"active"`
+	Created_at        time.Time          `json:"created_at"`
+	Updated_at        time.Time          `json:"updated_at"`
+	Social_account_id string             `json:"social_account_id"`
+}
+
+type SocialPost struct {
+	ID                primitive.ObjectID `bson:"_id"`
+	Social_account_id string             `json:"social_account_id"`
+	Platform          string             `json:"platform"`
+	Caption           string             `json:"caption"`
+	Image_url         string             `json:"image_url"`
+	External_id       string             `json:"external_id"`
+	Status            string             `json:"status"`
+	Error             string             `json:"error"`
+	Created_at        time.Time          `json:"created_at"`
+	Social_post_id    string             `json:"social_post_id"`
+}
@@ -0,0 +1,43 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type SupplierInvoiceLine struct {
+	Sku         *string  `json:"sku" validate:"required"`
+	Description *string  `json:"description"`
+	Quantity    *float64 `json:"quantity" validate:"required,gt=0"`
+	Unit_price  *float64 `json:"unit_price" validate:"required,min=0"`
+}
+
+// InvoiceDiscrepancy is one line where the supplier invoice disagrees with
+// the purchase order or what was received.
+type InvoiceDiscrepancy struct {
+	Sku      string  `json:"sku"`
+	Type     string  `json:"type"`
+	Expected float64 `json:"expected"`
+	Actual   float64 `json:"actual"`
+	Message  string  `json:"message"`
+}
+
+type SupplierInvoice struct {
+	ID                  primitive.ObjectID    `bson:"_id"`
+	Invoice_number      *string               `json:"invoice_number" validate:"required"`
+	Supplier            *string               `json:"supplier" validate:"required"`
+	Po_number           *string               `json:"po_number" validate:"required"`
+	Invoice_date        *time.Time            `json:"invoice_date"`
+	Lines               []SupplierInvoiceLine `json:"lines" validate:"required,min=1,dive"`
+	Total               float64               `json:"total"`
+	Source              string                `json:"source"`
+	Match_status        string                `json:"match_status"`
+	Discrepancies       []InvoiceDiscrepancy  `json:"discrepancies"`
+	Resolution_note     *string               `json:"resolution_note"`
+	Resolved_by         *string               `json:"resolved_by"`
+	Matched_at          *time.Time            `json:"matched_at"`
+	Created_at          time.Time             `json:"created_at"`
+	Updated_at          time.Time             `json:"updated_at"`
+	Supplier_invoice_id string                `json:"supplier_invoice_id"`
+}
@@ -0,0 +1,23 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type SurgeRule struct {
+	ID             primitive.ObjectID `bson:"_id"`
+	Name           *string            `json:"name" validate:"required,min=2,max=100"`
+	Channel        *string            `json:"channel" validate:"required,eq=DINE_IN|eq=TAKEOUT|eq=DELIVERY"`
+	Multiplier     *float64           `json:"multiplier" validate:"omitempty,gte=1,lte=3"`
+	Demand_fee     *float64           `json:"demand_fee" validate:"omitempty,gte=0"`
+	Trigger        *string            `json:"trigger" validate:"required,eq=MANUAL|eq=KITCHEN_LOAD"`
+	Load_threshold *int               `json:"load_threshold" validate:"omitempty,gt=0"`
+	Active         *bool              `json:"active"`
+	Starts_at      *time.Time         `json:"starts_at"`
+	Ends_at        *time.Time         `json:"ends_at"`
+	Created_at     time.Time          `json:"created_at"`
+	Updated_at     time.Time          `json:"updated_at"`
+	Surge_rule_id  string             `json:"surge_rule_id"`
+}
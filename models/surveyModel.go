@@ -0,0 +1,62 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type SurveyQuestion struct {
+	Key  string `json:"key" validate:"required"`
+	Text string `json:"text" validate:"required"`
+	Type string `json:"type" validate:"required,eq=NPS|eq=RATING|eq=TEXT"`
+}
+
+type Survey struct {
+	ID         primitive.ObjectID `bson:"_id"`
+	Name       *string            `json:"name" validate:"required,min=2,max=100"`
+	Location   *string            `json:"location"`
+	Questions  []SurveyQuestion   `json:"questions" validate:"required,min=1,dive"`
+	Active     *bool              `json:"active"`
+	Created_at time.Time          `json:"created_at"`
+	Updated_at time.Time          `json:"updated_at"`
+	Survey_id  string             `json:"survey_id"`
+}
+
+type SurveyInvitation struct {
+	ID            primitive.ObjectID `bson:"_id"`
+	Survey_id     string             `json:"survey_id"`
+	Order_id      string             `json:"order_id"`
+	Customer_id   *string            `json:"customer_id"`
+	Location      string             `json:"location"`
+	Token         string             `json:"token"`
+	Status        string             `json:"status"`
+	Created_at    time.Time          `json:"created_at"`
+	Responded_at  *time.Time         `json:"responded_at"`
+	Invitation_id string             `json:"invitation_id"`
+}
+
+type SurveyResponse struct {
+	ID            primitive.ObjectID     `bson:"_id"`
+	Survey_id     string                 `json:"survey_id"`
+	Invitation_id string                 `json:"invitation_id"`
+	Order_id      string                 `json:"order_id"`
+	Location      string                 `json:"location"`
+	Nps_score     *int                   `json:"nps_score"`
+	Answers       map[string]interface{} `json:"answers"`
+	Created_at    time.Time              `json:"created_at"`
+	Response_id   string                 `json:"response_id"`
+}
+
+type SurveyAlert struct {
+	ID          primitive.ObjectID `bson:"_id"`
+	Response_id string             `json:"response_id"`
+	Survey_id   string             `json:"survey_id"`
+	Order_id    string             `json:"order_id"`
+	Location    string             `json:"location"`
+	Nps_score   int                `json:"nps_score"`
+	Comment     string             `json:"comment"`
+	Resolved    bool               `json:"resolved"`
+	Created_at  time.Time          `json:"created_at"`
+	Alert_id    string             `json:"alert_id"`
+}
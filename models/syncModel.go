@@ -0,0 +1,43 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// OfflineOrder is an order captured by a POS terminal while offline.
+type OfflineOrder struct {
+	Client_uuid    *string     `json:"client_uuid" validate:"required,uuid"`
+	Table_id       *string     `json:"table_id" validate:"required"`
+	Channel        *string     `json:"channel" validate:"omitempty,eq=DINE_IN|eq=TAKEOUT|eq=DELIVERY"`
+	Customer_id    *string     `json:"customer_id"`
+	Order_date     *time.Time  `json:"order_date" validate:"required"`
+	Base_synced_at *time.Time  `json:"base_synced_at"`
+	Order_items    []OrderItem `json:"order_items"`
+}
+
+type SyncBatch struct {
+	Batch_id string         `json:"batch_id" validate:"required,uuid"`
+	Orders   []OfflineOrder `json:"orders" validate:"required,max=500,dive"`
+}
+
+type SyncResult struct {
+	Client_uuid string   `json:"client_uuid"`
+	Status      string   `json:"status"`
+	Order_id    string   `json:"order_id,omitempty"`
+	Items_added int      `json:"items_added"`
+	Conflicts   []string `json:"conflicts,omitempty"`
+	Error       string   `json:"error,omitempty"`
+}
+
+type TerminalSync struct {
+	ID             primitive.ObjectID `bson:"_id"`
+	Terminal_id    string             `json:"terminal_id"`
+	Last_batch_id  string             `json:"last_batch_id"`
+	Last_results   []SyncResult       `json:"last_results"`
+	Last_synced_at time.Time          `json:"last_synced_at"`
+	Orders_synced  int                `json:"orders_synced"`
+	Created_at     time.Time          `json:"created_at"`
+	Updated_at     time.Time          `json:"updated_at"`
+}
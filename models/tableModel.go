@@ -6,11 +6,25 @@ import (
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
+// Table is a table on the floor. Status is FREE, OCCUPIED, RESERVED or
+// CLEANING; tables are grouped into sections served by one server each.
+// A dine-in order makes its table OCCUPIED, and settling the last open
+// check there makes it CLEANING until staff set it FREE again.
+// Booking_version is bumped by every reservation of the table, so that
+// concurrent bookings of it conflict. Qr_version is the version of the
+// table's QR code; regenerating the code bumps it and voids the old one. Deleted_at is set once the table is
+// taken off the floor.
 type Table struct {
 	ID               primitive.ObjectID `bson:"_id"`
 	Number_of_guests *int               `json:"number_of_guests" validate:"required"`
 	Table_number     *int               `json:"table_number" validate:"required"`
+	Location         *string            `json:"location"`
+	Section          *string            `json:"section"`
+	Status           string             `json:"status"`
+	Booking_version  int                `json:"booking_version"`
+	Qr_version       int                `json:"qr_version"`
 	Created_at       time.Time          `json:"created_at"`
 	Updated_at       time.Time          `json:"updated_at"`
+	Deleted_at       *time.Time         `json:"deleted_at"`
 	Table_id         string             `json:"table_id"`
 }
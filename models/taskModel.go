@@ -0,0 +1,46 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// TaskTemplate is a recurring cleaning or prep job for a station. DAILY
+// templates run every day; WEEKLY templates run on the listed Days.
+type TaskTemplate struct {
+	ID               primitive.ObjectID `bson:"_id"`
+	Name             *string            `json:"name" validate:"required,min=2,max=150"`
+	Kind             *string            `json:"kind" validate:"required,eq=CLEANING|eq=PREP"`
+	Station          *string            `json:"station" validate:"required"`
+	Location         *string            `json:"location"`
+	Recurrence       *string            `json:"recurrence" validate:"required,eq=DAILY|eq=WEEKLY"`
+	Days             []string           `json:"days" validate:"required_if=Recurrence WEEKLY,dive,eq=MON|eq=TUE|eq=WED|eq=THU|eq=FRI|eq=SAT|eq=SUN"`
+	Due_time         *string            `json:"due_time" validate:"required,datetime=15:04"`
+	Instructions     *string            `json:"instructions" validate:"omitempty,max=2000"`
+	Active           *bool              `json:"active"`
+	Created_at       time.Time          `json:"created_at"`
+	Updated_at       time.Time          `json:"updated_at"`
+	Task_template_id string             `json:"task_template_id"`
+}
+
+// Task is one occurrence of a template on a given day. Prep tasks generated
+// from the prep list have no template and name the food and units to prep.
+type Task struct {
+	ID               primitive.ObjectID `bson:"_id"`
+	Task_template_id string             `json:"task_template_id"`
+	Name             string             `json:"name"`
+	Kind             string             `json:"kind"`
+	Station          string             `json:"station"`
+	Location         string             `json:"location"`
+	Task_date        string             `json:"task_date"`
+	Due_at           time.Time          `json:"due_at"`
+	Food_id          string             `json:"food_id,omitempty"`
+	Daypart          string             `json:"daypart,omitempty"`
+	Quantity         int                `json:"quantity,omitempty"`
+	Status           string             `json:"status"`
+	Completed_by     *string            `json:"completed_by"`
+	Completed_at     *time.Time         `json:"completed_at"`
+	Note             *string            `json:"note"`
+	Task_id          string             `json:"task_id"`
+}
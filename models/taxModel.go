@@ -0,0 +1,34 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// TaxRate is the rate charged on a menu category at a location. Empty
+// category or location make the rate apply to all of them; the most specific
+// rate wins. Menu prices include the tax unless the rate is Exclusive, in
+// which case it is added on top of them.
+type TaxRate struct {
+	ID          primitive.ObjectID `bson:"_id"`
+	Category    *string            `json:"category"`
+	Location    *string            `json:"location"`
+	Rate        *float64           `json:"rate" validate:"required,gte=0,lte=100"`
+	Exclusive   *bool              `json:"exclusive"`
+	Created_at  time.Time          `json:"created_at"`
+	Updated_at  time.Time          `json:"updated_at"`
+	Tax_rate_id string             `json:"tax_rate_id"`
+}
+
+// TaxLine is the tax on the sales of one category at one rate, fixed when
+// an invoice is finalized or a credit note is issued. Exclusive tax was
+// added to the menu prices rather than contained in them.
+type TaxLine struct {
+	Category  string  `json:"category"`
+	Rate      float64 `json:"rate"`
+	Exclusive bool    `json:"exclusive"`
+	Net       float64 `json:"net"`
+	Tax       float64 `json:"tax"`
+	Gross     float64 `json:"gross"`
+}
@@ -0,0 +1,52 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// TemperatureUnit is a fridge, freezer or hot-hold unit that must be checked
+// on a schedule. Temperatures are in degrees Celsius.
+type TemperatureUnit struct {
+	ID                     primitive.ObjectID `bson:"_id"`
+	Name                   *string            `json:"name" validate:"required,min=2,max=100"`
+	Type                   *string            `json:"type" validate:"required,eq=FRIDGE|eq=FREEZER|eq=HOT_HOLD"`
+	Location               *string            `json:"location"`
+	Min_temp               *float64           `json:"min_temp" validate:"required"`
+	Max_temp               *float64           `json:"max_temp" validate:"required,gtfield=Min_temp"`
+	Check_interval_minutes *int               `json:"check_interval_minutes" validate:"required,min=5,max=1440"`
+	Active                 *bool              `json:"active"`
+	Ingest_token           string             `json:"-"`
+	Last_reading_at        *time.Time         `json:"last_reading_at"`
+	Created_at             time.Time          `json:"created_at"`
+	Updated_at             time.Time          `json:"updated_at"`
+	Unit_id                string             `json:"unit_id"`
+}
+
+type TemperatureReading struct {
+	ID                primitive.ObjectID `bson:"_id"`
+	Unit_id           string             `json:"unit_id"`
+	Temperature       *float64           `json:"temperature" validate:"required"`
+	Source            string             `json:"source"`
+	Recorded_by       *string            `json:"recorded_by"`
+	Corrective_action *string            `json:"corrective_action" validate:"omitempty,max=500"`
+	In_range          bool               `json:"in_range"`
+	Recorded_at       time.Time          `json:"recorded_at"`
+	Reading_id        string             `json:"reading_id"`
+}
+
+// TemperatureAlert is raised for an out-of-range reading or a missed check.
+type TemperatureAlert struct {
+	ID          primitive.ObjectID `bson:"_id"`
+	Unit_id     string             `json:"unit_id"`
+	Type        string             `json:"type"`
+	Reading_id  *string            `json:"reading_id"`
+	Message     string             `json:"message"`
+	Resolved    bool               `json:"resolved"`
+	Resolved_by *string            `json:"resolved_by"`
+	Resolution  *string            `json:"resolution"`
+	Created_at  time.Time          `json:"created_at"`
+	Resolved_at *time.Time         `json:"resolved_at"`
+	Alert_id    string             `json:"alert_id"`
+}
@@ -0,0 +1,37 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type TicketEvent struct {
+	Status string    `json:"status"`
+	By     string    `json:"by"`
+	Note   string    `json:"note"`
+	At     time.Time `json:"at"`
+}
+
+// MaintenanceTicket tracks a broken piece of equipment or a facility issue.
+// Out_of_service tickets count towards the equipment's downtime from
+// creation until the ticket is resolved.
+type MaintenanceTicket struct {
+	ID             primitive.ObjectID `bson:"_id"`
+	Title          *string            `json:"title" validate:"required,min=3,max=150"`
+	Description    *string            `json:"description" validate:"omitempty,max=2000"`
+	Category       *string            `json:"category" validate:"required,eq=EQUIPMENT|eq=FACILITY"`
+	Equipment_id   *string            `json:"equipment_id" validate:"required_if=Category EQUIPMENT"`
+	Location       *string            `json:"location"`
+	Priority       *string            `json:"priority" validate:"required,eq=LOW|eq=MEDIUM|eq=HIGH|eq=URGENT"`
+	Out_of_service bool               `json:"out_of_service"`
+	Reported_by    *string            `json:"reported_by" validate:"required"`
+	Assignee_id    *string            `json:"assignee_id"`
+	Status         string             `json:"status"`
+	Photos         []string           `json:"photos"`
+	History        []TicketEvent      `json:"history"`
+	Resolved_at    *time.Time         `json:"resolved_at"`
+	Created_at     time.Time          `json:"created_at"`
+	Updated_at     time.Time          `json:"updated_at"`
+	Ticket_id      string             `json:"ticket_id"`
+}
@@ -0,0 +1,45 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type TimeClockRecord struct {
+	ID                   primitive.ObjectID `bson:"_id"`
+	Employee_id          *string            `json:"employee_id" validate:"required"`
+	Location             *string            `json:"location"`
+	Hourly_rate          *float64           `json:"hourly_rate" validate:"required,min=0"`
+	Clock_in             time.Time          `json:"clock_in"`
+	Clock_out            *time.Time         `json:"clock_out"`
+	Status               string             `json:"status"`
+	Approved_by          *string            `json:"approved_by"`
+	Approved_at          *time.Time         `json:"approved_at"`
+	Created_at           time.Time          `json:"created_at"`
+	Updated_at           time.Time          `json:"updated_at"`
+	Time_clock_record_id string             `json:"time_clock_record_id"`
+}
+
+// TipDistribution is the share of the tip pool paid to one employee for a
+// business day.
+type TipDistribution struct {
+	ID                  primitive.ObjectID `bson:"_id"`
+	Employee_id         *string            `json:"employee_id" validate:"required"`
+	Location            *string            `json:"location"`
+	Business_date       *time.Time         `json:"business_date" validate:"required"`
+	Amount              *float64           `json:"amount" validate:"required,min=0"`
+	Paid_in_cash        bool               `json:"paid_in_cash"`
+	Created_at          time.Time          `json:"created_at"`
+	Tip_distribution_id string             `json:"tip_distribution_id"`
+}
+
+// OvertimeRule holds a location's overtime thresholds in hours.
+type OvertimeRule struct {
+	ID               primitive.ObjectID `bson:"_id"`
+	Location         string             `json:"location"`
+	Daily_threshold  *float64           `json:"daily_threshold" validate:"omitempty,gt=0,lte=24"`
+	Weekly_threshold *float64           `json:"weekly_threshold" validate:"omitempty,gt=0,lte=168"`
+	Multiplier       *float64           `json:"multiplier" validate:"required,gte=1,lte=3"`
+	Updated_at       time.Time          `json:"updated_at"`
+}
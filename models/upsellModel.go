@@ -0,0 +1,49 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// UpsellRule offers a food at checkout when the cart has one of the
+// Cart_food_ids or a food of Cart_category, and none of the Missing_food_ids
+// nor anything of Missing_category. The offer is the cheapest available
+// food among Offer_food_ids or in Offer_category, at Percent_off if set.
+// When several rules match, the highest Priority is shown first.
+type UpsellRule struct {
+	ID               primitive.ObjectID `bson:"_id"`
+	Name             *string            `json:"name" validate:"required,min=2,max=100"`
+	Cart_food_ids    []string           `json:"cart_food_ids"`
+	Cart_category    *string            `json:"cart_category"`
+	Missing_food_ids []string           `json:"missing_food_ids"`
+	Missing_category *string            `json:"missing_category"`
+	Offer_food_ids   []string           `json:"offer_food_ids"`
+	Offer_category   *string            `json:"offer_category"`
+	Percent_off      *float64           `json:"percent_off" validate:"omitempty,gt=0,lte=100"`
+	Message          *string            `json:"message" validate:"omitempty,max=200"`
+	Priority         *int               `json:"priority"`
+	Active           *bool              `json:"active"`
+	Created_at       time.Time          `json:"created_at"`
+	Updated_at       time.Time          `json:"updated_at"`
+	Upsell_rule_id   string             `json:"upsell_rule_id"`
+}
+
+// UpsellPrompt is an offer shown at checkout. Status is SHOWN until the
+// guest ACCEPTED or DECLINED it; an accepted prompt names the order item
+// the offered food was rung up as.
+type UpsellPrompt struct {
+	ID               primitive.ObjectID `bson:"_id"`
+	Upsell_rule_id   string             `json:"upsell_rule_id"`
+	Order_id         *string            `json:"order_id"`
+	Food_id          string             `json:"food_id"`
+	Message          string             `json:"message"`
+	Price            float64            `json:"price"`
+	Offer_price      float64            `json:"offer_price"`
+	Percent_off      *float64           `json:"percent_off"`
+	Status           string             `json:"status"`
+	Order_item_id    *string            `json:"order_item_id"`
+	Shown_at         time.Time          `json:"shown_at"`
+	Responded_at     *time.Time         `json:"responded_at"`
+	Upsell_prompt_id string             `json:"upsell_prompt_id"`
+}
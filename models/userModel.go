@@ -10,10 +10,12 @@ type User struct {
 	ID            primitive.ObjectID `bson:"_id"`
 	First_name    *string            `json:"first_name" validate:"required,min=2,max=100"`
 	Last_name     *string            `json:"last_name" validate:"required,min=2,max=100"`
-	Password      *string            `json:"Password" validate:"required,min=6"`
+	Password      *string            `json:"password" validate:"required,min=6"`
 	Email         *string            `json:"email" validate:"email,required"`
 	Avatar        *string            `json:"avatar"`
 	Phone         *string            `json:"phone" validate:"required"`
+	Allergies     []string           `json:"allergies" validate:"omitempty,dive,min=2,max=50"`
+	Role          *string            `json:"role" validate:"omitempty,oneof=ADMIN MANAGER WAITER KITCHEN CUSTOMER"`
 	Token         *string            `json:"token"`
 	Refresh_Token *string            `json:"refresh_token"`
 	Created_at    time.Time          `json:"created_at"`
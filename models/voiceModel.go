@@ -0,0 +1,30 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// VoiceSession is the cart of a phone-bot or smart-speaker conversation,
+// keyed by the platform's session id. Order_id is the last order placed
+// from it; the cart starts over after each checkout.
+type VoiceSession struct {
+	ID          primitive.ObjectID `bson:"_id"`
+	Platform    string             `json:"platform"`
+	Session_id  string             `json:"session_id"`
+	Customer_id *string            `json:"customer_id"`
+	Cart        []VoiceCartLine    `json:"cart"`
+	Order_id    *string            `json:"order_id"`
+	Created_at  time.Time          `json:"created_at"`
+	Updated_at  time.Time          `json:"updated_at"`
+}
+
+// VoiceCartLine is Count of a food in one size.
+type VoiceCartLine struct {
+	Food_id    string  `json:"food_id"`
+	Name       string  `json:"name"`
+	Size       string  `json:"size"`
+	Count      int     `json:"count"`
+	Unit_price float64 `json:"unit_price"`
+}
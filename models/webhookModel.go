@@ -0,0 +1,38 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type Webhook struct {
+	ID         primitive.ObjectID `bson:"_id"`
+	Name       *string            `json:"name" validate:"required,min=2,max=100"`
+	Url        *string            `json:"url" validate:"required,url"`
+	Events     []string           `json:"events" validate:"required,min=1,dive,oneof=order.created order.updated invoice.paid food.created food.updated"`
+	Secret     string             `json:"secret,omitempty"`
+	Active     *bool              `json:"active"`
+	Created_at time.Time          `json:"created_at"`
+	Updated_at time.Time          `json:"updated_at"`
+	Webhook_id string             `json:"webhook_id"`
+}
+
+// WebhookDelivery is one event queued for one webhook. Payload is the JSON
+// body sent, fixed when the event is queued so retries send the same thing.
+type WebhookDelivery struct {
+	ID                  primitive.ObjectID `bson:"_id"`
+	Webhook_id          string             `json:"webhook_id"`
+	Event_id            string             `json:"event_id"`
+	Event_type          string             `json:"event_type"`
+	Payload             string             `json:"payload"`
+	Status              string             `json:"status" validate:"eq=PENDING|eq=DELIVERED|eq=FAILED"`
+	Attempts            int                `json:"attempts"`
+	Next_attempt_at     time.Time          `json:"next_attempt_at"`
+	Last_error          string             `json:"last_error"`
+	Response_status     int                `json:"response_status"`
+	Delivered_at        *time.Time         `json:"delivered_at"`
+	Created_at          time.Time          `json:"created_at"`
+	Updated_at          time.Time          `json:"updated_at"`
+	Webhook_delivery_id string             `json:"webhook_delivery_id"`
+}
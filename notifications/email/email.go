@@ -0,0 +1,92 @@
+// Package email sends the app's emails through SMTP or SendGrid. Messages
+// built from the templates in this package are queued with Queue and
+// delivered by workers, off the request that caused them; jobs that need
+// to know whether a message got through call Send directly.
+//
+// The driver is picked by EMAIL_DRIVER, smtp (the default) or sendgrid:
+//
+//	SMTP_HOST, SMTP_PORT (587), SMTP_USERNAME, SMTP_PASSWORD  the SMTP server
+//	SENDGRID_API_KEY                                          the SendGrid key
+//	EMAIL_FROM                                                sender address;
+//	                                                          SMTP_FROM, then
+//	                                                          SMTP_USERNAME, when unset
+package email
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// ErrNotConfigured is returned when the chosen driver has no credentials.
+var ErrNotConfigured = errors.New("email: no SMTP_HOST or SENDGRID_API_KEY is configured")
+
+// Message is a plain-text email.
+type Message struct {
+	To      []string
+	Subject string
+	Body    string
+}
+
+// Driver delivers messages through one provider.
+type Driver interface {
+	Send(ctx context.Context, message Message) error
+}
+
+// FromEnv returns the driver EMAIL_DRIVER names, configured from the
+// environment.
+func FromEnv() (Driver, error) {
+	from := os.Getenv("EMAIL_FROM")
+	if from == "" {
+		from = os.Getenv("SMTP_FROM")
+	}
+	switch driver := strings.ToLower(os.Getenv("EMAIL_DRIVER")); driver {
+	case "", "smtp":
+		host := os.Getenv("SMTP_HOST")
+		if host == "" {
+			return nil, ErrNotConfigured
+		}
+		port := os.Getenv("SMTP_PORT")
+		if port == "" {
+			port = "587"
+		}
+		username := os.Getenv("SMTP_USERNAME")
+		if from == "" {
+			from = username
+		}
+		return &SMTP{Host: host, Port: port, Username: username, Password: os.Getenv("SMTP_PASSWORD"), From: from}, nil
+	case "sendgrid":
+		key := os.Getenv("SENDGRID_API_KEY")
+		if key == "" {
+			return nil, ErrNotConfigured
+		}
+		return &SendGrid{APIKey: key, From: from}, nil
+	default:
+		return nil, fmt.Errorf("email: unknown EMAIL_DRIVER %q", driver)
+	}
+}
+
+// Send delivers message now through the configured driver.
+func Send(ctx context.Context, message Message) error {
+	if len(message.To) == 0 {
+		return errors.New("email: no recipients")
+	}
+	driver, err := FromEnv()
+	if err != nil {
+		return err
+	}
+	return driver.Send(ctx, message)
+}
+
+// Recipients splits a comma-separated address list from the environment.
+func Recipients(envVar string) []string {
+	addresses := []string{}
+	for _, address := range strings.Split(os.Getenv(envVar), ",") {
+		if address = strings.TrimSpace(address); address != "" {
+			addresses = append(addresses, address)
+		}
+	}
+	return addresses
+}
@@ -0,0 +1,76 @@
+package email
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+const sendGridEndpoint = "https://api.sendgrid.com/v3/mail/send"
+
+var httpClient = &http.Client{Timeout: 30 * time.Second}
+
+// SendGrid delivers through SendGrid's v3 mail API.
+type SendGrid struct {
+	APIKey string
+	From   string
+}
+
+type sendGridAddress struct {
+	Email string `json:"email"`
+}
+
+type sendGridContent struct {
+	Type  string `json:"type"`
+	Value string `json:"value"`
+}
+
+type sendGridPersonalization struct {
+	To []sendGridAddress `json:"to"`
+}
+
+type sendGridMail struct {
+	Personalizations []sendGridPersonalization `json:"personalizations"`
+	From             sendGridAddress           `json:"from"`
+	Subject          string                    `json:"subject"`
+	Content          []sendGridContent         `json:"content"`
+}
+
+func (s *SendGrid) Send(ctx context.Context, message Message) error {
+	to := make([]sendGridAddress, len(message.To))
+	for i, address := range message.To {
+		to[i] = sendGridAddress{Email: address}
+	}
+	body, err := json.Marshal(sendGridMail{
+		Personalizations: []sendGridPersonalization{{To: to}},
+		From:             sendGridAddress{Email: s.From},
+		Subject:          message.Subject,
+		Content:          []sendGridContent{{Type: "text/plain", Value: message.Body}},
+	})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, sendGridEndpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+s.APIKey)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		detail, _ := io.ReadAll(io.LimitReader(resp.Body, 2048))
+		return fmt.Errorf("email: sendgrid returned %d: %s", resp.StatusCode, detail)
+	}
+	return nil
+}
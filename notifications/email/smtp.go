@@ -0,0 +1,41 @@
+package email
+
+import (
+	"context"
+	"fmt"
+	"net/smtp"
+	"strings"
+)
+
+// SMTP delivers through an SMTP server, authenticating when Username is
+// set.
+type SMTP struct {
+	Host     string
+	Port     string
+	Username string
+	Password string
+	From     string
+}
+
+// Send delivers message. The standard library's client cannot be
+// cancelled, so ctx is only checked before connecting.
+func (s *SMTP) Send(ctx context.Context, message Message) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	var auth smtp.Auth
+	if s.Username != "" {
+		auth = smtp.PlainAuth("", s.Username, s.Password, s.Host)
+	}
+
+	var msg strings.Builder
+	fmt.Fprintf(&msg, "From: %s\r\n", s.From)
+	fmt.Fprintf(&msg, "To: %s\r\n", strings.Join(message.To, ", "))
+	fmt.Fprintf(&msg, "Subject: %s\r\n", message.Subject)
+	msg.WriteString("MIME-Version: 1.0\r\n")
+	msg.WriteString("Content-Type: text/plain; charset=UTF-8\r\n\r\n")
+	msg.WriteString(strings.ReplaceAll(message.Body, "\n", "\r\n"))
+
+	return smtp.SendMail(s.Host+":"+s.Port, auth, s.From, message.To, []byte(msg.String()))
+}
@@ -0,0 +1,62 @@
+package email
+
+import (
+	"strings"
+	"text/template"
+)
+
+// templates are the messages the app sends. Each is a pair of templates,
+// NAME.subject and NAME.body.
+var templates = template.Must(template.New("email").Parse(`
+{{define "receipt.subject"}}Your receipt from {{.Venue}}{{end}}
+{{define "receipt.body"}}Hi{{with .Name}} {{.}}{{end}},
+
+Thank you for visiting {{.Venue}}. Your receipt is below.
+
+{{.Receipt}}
+We hope to see you again soon.
+{{end}}
+
+{{define "reservation_confirmation.subject"}}Your reservation at {{.Venue}} is booked{{end}}
+{{define "reservation_confirmation.body"}}Hi {{.Name}},
+
+Your table for {{.Party_size}} at {{.Venue}} is booked for {{.When}}.
+{{- with .Deposit}}
+
+A deposit of {{.}} has been charged and will be taken off your bill.
+{{- end}}
+
+Confirm: {{.Link}}/confirm
+Cancel: {{.Link}}/cancel
+{{end}}
+`))
+
+// ReceiptData fills the receipt message. Receipt is the receipt as text.
+type ReceiptData struct {
+	Venue   string
+	Name    string
+	Receipt string
+}
+
+// ReservationData fills the reservation confirmation. Link is the
+// guest's reservation link, which /confirm and /cancel are added to.
+type ReservationData struct {
+	Venue      string
+	Name       string
+	Party_size int
+	When       string
+	Deposit    string
+	Link       string
+}
+
+// Render builds the message named name, such as "receipt", to to.
+func Render(name string, to []string, data interface{}) (Message, error) {
+	var subject, body strings.Builder
+	if err := templates.ExecuteTemplate(&subject, name+".subject", data); err != nil {
+		return Message{}, err
+	}
+	if err := templates.ExecuteTemplate(&body, name+".body", data); err != nil {
+		return Message{}, err
+	}
+	return Message{To: to, Subject: strings.TrimSpace(subject.String()), Body: body.String()}, nil
+}
@@ -0,0 +1,29 @@
+package email
+
+import (
+	"context"
+	"fmt"
+
+	"restaurant-management/notifications/worker"
+)
+
+var pool = worker.New("Email", Send, func(message Message) string {
+	return fmt.Sprintf("%q to %v", message.Subject, message.To)
+}, ErrNotConfigured)
+
+// Start starts workers that deliver queued messages until ctx is done.
+// Messages still queued then are tried once more before they stop.
+func Start(ctx context.Context, count int) {
+	pool.Start(ctx, count)
+}
+
+// Wait blocks until the workers have stopped, or until ctx is done.
+func Wait(ctx context.Context) error {
+	return pool.Wait(ctx)
+}
+
+// Queue hands message to the workers. When they are too far behind it is
+// dropped, as an email is not worth holding a request up for.
+func Queue(message Message) {
+	pool.Queue(message)
+}
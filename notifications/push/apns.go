@@ -0,0 +1,145 @@
+package push
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// apnsTokenLifetime is how long a provider token is reused. Apple rejects
+// tokens older than an hour and ones refreshed more often than every 20
+// minutes.
+const apnsTokenLifetime = 50 * time.Minute
+
+// APNSSender sends through APNs, authenticating with a signing key.
+type APNSSender struct {
+	Key     *ecdsa.PrivateKey
+	KeyId   string
+	TeamId  string
+	Topic   string
+	Sandbox bool
+
+	mu       sync.Mutex
+	jwt      string
+	issuedAt time.Time
+}
+
+func apnsFromEnv() (Sender, error) {
+	path := os.Getenv("APNS_KEY_FILE")
+	keyId, teamId, topic := os.Getenv("APNS_KEY_ID"), os.Getenv("APNS_TEAM_ID"), os.Getenv("APNS_TOPIC")
+	if path == "" || keyId == "" || teamId == "" || topic == "" {
+		return nil, ErrNotConfigured
+	}
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(raw)
+	if block == nil {
+		return nil, errors.New("push: APNS_KEY_FILE holds no private key")
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("push: parsing APNs key: %w", err)
+	}
+	ecKey, ok := key.(*ecdsa.PrivateKey)
+	if !ok {
+		return nil, errors.New("push: APNs key is not an EC key")
+	}
+	sandbox, _ := strconv.ParseBool(os.Getenv("APNS_SANDBOX"))
+	return &APNSSender{Key: ecKey, KeyId: keyId, TeamId: teamId, Topic: topic, Sandbox: sandbox}, nil
+}
+
+func (a *APNSSender) Send(ctx context.Context, token string, notification Notification) error {
+	jwt, err := a.token()
+	if err != nil {
+		return err
+	}
+	payload := map[string]interface{}{
+		"aps": map[string]interface{}{
+			"alert": map[string]string{"title": notification.Title, "body": notification.Body},
+			"sound": "default",
+		},
+	}
+	for key, value := range notification.Data {
+		payload[key] = value
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	host := "https://api.push.apple.com"
+	if a.Sandbox {
+		host = "https://api.sandbox.push.apple.com"
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, host+"/3/device/"+token, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "bearer "+jwt)
+	req.Header.Set("apns-topic", a.Topic)
+	req.Header.Set("apns-push-type", "alert")
+
+	// APNs only speaks HTTP/2, which the default transport negotiates
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		detail, _ := io.ReadAll(io.LimitReader(resp.Body, 2048))
+		if resp.StatusCode == http.StatusGone || bytes.Contains(detail, []byte("BadDeviceToken")) {
+			return ErrUnregistered
+		}
+		return fmt.Errorf("push: apns returned %d: %s", resp.StatusCode, detail)
+	}
+	return nil
+}
+
+// token returns the provider token, signing a new one once the cached one
+// is apnsTokenLifetime old.
+func (a *APNSSender) token() (string, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.jwt != "" && time.Since(a.issuedAt) < apnsTokenLifetime {
+		return a.jwt, nil
+	}
+
+	now := time.Now()
+	jwt, err := signedJWT(
+		map[string]interface{}{"alg": "ES256", "kid": a.KeyId},
+		map[string]interface{}{"iss": a.TeamId, "iat": now.Unix()},
+		func(unsigned []byte) ([]byte, error) {
+			digest := sha256.Sum256(unsigned)
+			r, s, err := ecdsa.Sign(rand.Reader, a.Key, digest[:])
+			if err != nil {
+				return nil, err
+			}
+			// JWS wants r and s as fixed-width big-endian halves
+			signature := make([]byte, 64)
+			r.FillBytes(signature[:32])
+			s.FillBytes(signature[32:])
+			return signature, nil
+		},
+	)
+	if err != nil {
+		return "", err
+	}
+	a.jwt, a.issuedAt = jwt, now
+	return a.jwt, nil
+}
@@ -0,0 +1,168 @@
+package push
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+var httpClient = &http.Client{Timeout: 30 * time.Second}
+
+const fcmScope = "https://www.googleapis.com/auth/firebase.messaging"
+
+// FCMSender sends through the FCM HTTP v1 API, authenticating as a service
+// account.
+type FCMSender struct {
+	ProjectId   string
+	ClientEmail string
+	PrivateKey  *rsa.PrivateKey
+	TokenURI    string
+
+	mu          sync.Mutex
+	accessToken string
+	expiresAt   time.Time
+}
+
+func fcmFromEnv() (Sender, error) {
+	path := os.Getenv("FCM_CREDENTIALS_FILE")
+	if path == "" {
+		return nil, ErrNotConfigured
+	}
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var account struct {
+		Project_id   string `json:"project_id"`
+		Client_email string `json:"client_email"`
+		Private_key  string `json:"private_key"`
+		Token_uri    string `json:"token_uri"`
+	}
+	if err := json.Unmarshal(raw, &account); err != nil {
+		return nil, fmt.Errorf("push: reading FCM credentials: %w", err)
+	}
+	block, _ := pem.Decode([]byte(account.Private_key))
+	if block == nil {
+		return nil, errors.New("push: FCM credentials hold no private key")
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("push: parsing FCM private key: %w", err)
+	}
+	rsaKey, ok := key.(*rsa.PrivateKey)
+	if !ok {
+		return nil, errors.New("push: FCM private key is not an RSA key")
+	}
+	if account.Token_uri == "" {
+		account.Token_uri = "https://oauth2.googleapis.com/token"
+	}
+	return &FCMSender{ProjectId: account.Project_id, ClientEmail: account.Client_email, PrivateKey: rsaKey, TokenURI: account.Token_uri}, nil
+}
+
+func (f *FCMSender) Send(ctx context.Context, token string, notification Notification) error {
+	accessToken, err := f.token(ctx)
+	if err != nil {
+		return err
+	}
+	body, err := json.Marshal(map[string]interface{}{
+		"message": map[string]interface{}{
+			"token":        token,
+			"notification": map[string]string{"title": notification.Title, "body": notification.Body},
+			"data":         notification.Data,
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	endpoint := "https://fcm.googleapis.com/v1/projects/" + f.ProjectId + "/messages:send"
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		detail, _ := io.ReadAll(io.LimitReader(resp.Body, 2048))
+		if resp.StatusCode == http.StatusNotFound && bytes.Contains(detail, []byte("UNREGISTERED")) {
+			return ErrUnregistered
+		}
+		return fmt.Errorf("push: fcm returned %d: %s", resp.StatusCode, detail)
+	}
+	return nil
+}
+
+// token returns an OAuth access token for the service account, exchanging
+// a freshly signed assertion when the cached one is about to expire.
+func (f *FCMSender) token(ctx context.Context) (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.accessToken != "" && time.Now().Before(f.expiresAt.Add(-time.Minute)) {
+		return f.accessToken, nil
+	}
+
+	now := time.Now()
+	assertion, err := signedJWT(
+		map[string]interface{}{"alg": "RS256", "typ": "JWT"},
+		map[string]interface{}{"iss": f.ClientEmail, "scope": fcmScope, "aud": f.TokenURI, "iat": now.Unix(), "exp": now.Add(time.Hour).Unix()},
+		func(unsigned []byte) ([]byte, error) {
+			digest := sha256.Sum256(unsigned)
+			return rsa.SignPKCS1v15(rand.Reader, f.PrivateKey, crypto.SHA256, digest[:])
+		},
+	)
+	if err != nil {
+		return "", err
+	}
+
+	form := url.Values{}
+	form.Set("grant_type", "urn:ietf:params:oauth:grant-type:jwt-bearer")
+	form.Set("assertion", assertion)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, f.TokenURI, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		detail, _ := io.ReadAll(io.LimitReader(resp.Body, 2048))
+		return "", fmt.Errorf("push: fcm token exchange returned %d: %s", resp.StatusCode, detail)
+	}
+
+	var token struct {
+		Access_token string `json:"access_token"`
+		Expires_in   int    `json:"expires_in"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&token); err != nil {
+		return "", err
+	}
+	f.accessToken = token.Access_token
+	f.expiresAt = now.Add(time.Duration(token.Expires_in) * time.Second)
+	return f.accessToken, nil
+}
@@ -0,0 +1,90 @@
+// Package push sends push notifications to the staff apps through Firebase
+// Cloud Messaging and the Apple Push Notification service. Notifications
+// are queued with Queue and delivered by workers.
+//
+// FCM is configured by FCM_CREDENTIALS_FILE, the path of a Firebase service
+// account key. APNs is configured by APNS_KEY_FILE (the .p8 signing key),
+// APNS_KEY_ID, APNS_TEAM_ID and APNS_TOPIC (the app's bundle id), and talks
+// to the sandbox when APNS_SANDBOX is true.
+package push
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// ErrUnregistered is returned for a token the provider no longer knows;
+// the device it belonged to should be forgotten.
+var ErrUnregistered = errors.New("push: device token is no longer registered")
+
+// ErrNotConfigured is returned when a provider's credentials are not set.
+var ErrNotConfigured = errors.New("push: FCM_CREDENTIALS_FILE or the APNS_* settings are not configured")
+
+// Providers devices register with.
+const (
+	FCM  = "FCM"
+	APNS = "APNS"
+)
+
+// Notification is an alert shown on a device. Data is handed to the app,
+// which uses it to open the right screen.
+type Notification struct {
+	Title string
+	Body  string
+	Data  map[string]string
+}
+
+// Target is a device token and the provider that issued it.
+type Target struct {
+	Provider string
+	Token    string
+}
+
+// Sender delivers notifications through one provider.
+type Sender interface {
+	Send(ctx context.Context, token string, notification Notification) error
+}
+
+var (
+	sendersOnce sync.Once
+	senders     map[string]Sender
+	sendersErr  map[string]error
+)
+
+// senderFor returns the provider's sender, configured from the environment
+// on first use so that its access tokens are reused between sends.
+func senderFor(provider string) (Sender, error) {
+	sendersOnce.Do(func() {
+		senders = map[string]Sender{}
+		sendersErr = map[string]error{}
+		senders[FCM], sendersErr[FCM] = fcmFromEnv()
+		senders[APNS], sendersErr[APNS] = apnsFromEnv()
+	})
+	sender, ok := senders[provider]
+	if !ok {
+		return nil, fmt.Errorf("push: unknown provider %q", provider)
+	}
+	return sender, sendersErr[provider]
+}
+
+// signedJWT joins header and claims into a JWT signed by sign.
+func signedJWT(header, claims map[string]interface{}, sign func(unsigned []byte) ([]byte, error)) (string, error) {
+	encodedHeader, err := json.Marshal(header)
+	if err != nil {
+		return "", err
+	}
+	encodedClaims, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	unsigned := base64.RawURLEncoding.EncodeToString(encodedHeader) + "." + base64.RawURLEncoding.EncodeToString(encodedClaims)
+	signature, err := sign([]byte(unsigned))
+	if err != nil {
+		return "", err
+	}
+	return unsigned + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
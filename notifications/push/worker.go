@@ -0,0 +1,92 @@
+package push
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"time"
+)
+
+// queueSize is how many deliveries may wait for the workers.
+const queueSize = 256
+
+const sendTimeout = 30 * time.Second
+
+type delivery struct {
+	target       Target
+	notification Notification
+}
+
+var (
+	queue   = make(chan delivery, queueSize)
+	workers sync.WaitGroup
+)
+
+// Start starts workers that deliver queued notifications until ctx is
+// done. forget is called with each token a provider reports unregistered,
+// so the device can be dropped. Pushes are only useful while they are
+// fresh, so they are not retried and whatever is still queued at shutdown
+// is dropped.
+func Start(ctx context.Context, count int, forget func(token string)) {
+	for i := 0; i < count; i++ {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			for {
+				select {
+				case d := <-queue:
+					deliver(ctx, d, forget)
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+	}
+}
+
+// Wait blocks until the workers have stopped, or until ctx is done.
+func Wait(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		workers.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Queue hands notification to the workers, once for each target. When
+// they are too far behind the rest is dropped.
+func Queue(targets []Target, notification Notification) {
+	for _, target := range targets {
+		select {
+		case queue <- delivery{target: target, notification: notification}:
+		default:
+			log.Printf("Push queue is full, %q was not sent", notification.Title)
+			return
+		}
+	}
+}
+
+func deliver(ctx context.Context, d delivery, forget func(token string)) {
+	sender, err := senderFor(d.target.Provider)
+	if err != nil {
+		log.Printf("Error sending %q: %v", d.notification.Title, err)
+		return
+	}
+	sendCtx, cancel := context.WithTimeout(ctx, sendTimeout)
+	defer cancel()
+	err = sender.Send(sendCtx, d.target.Token, d.notification)
+	if errors.Is(err, ErrUnregistered) && forget != nil {
+		forget(d.target.Token)
+		return
+	}
+	if err != nil {
+		log.Printf("Error sending %q: %v", d.notification.Title, err)
+	}
+}
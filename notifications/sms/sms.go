@@ -0,0 +1,62 @@
+// Package sms sends text messages through an SMS provider. Texts triggered
+// by requests are queued with Queue and delivered by workers; jobs that
+// need to know whether a text got through call Send directly.
+//
+// The provider is picked by SMS_PROVIDER; twilio, the default, is the only
+// one so far and is configured by TWILIO_ACCOUNT_SID, TWILIO_AUTH_TOKEN and
+// the sender number TWILIO_FROM.
+package sms
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// ErrNotConfigured is returned when the provider's credentials are not set.
+var ErrNotConfigured = errors.New("sms: TWILIO_ACCOUNT_SID, TWILIO_AUTH_TOKEN and TWILIO_FROM are not configured")
+
+// Message is a text to one phone number.
+type Message struct {
+	To   string
+	Body string
+}
+
+// Provider delivers texts through one SMS service.
+type Provider interface {
+	Send(ctx context.Context, message Message) error
+}
+
+// FromEnv returns the provider SMS_PROVIDER names, configured from the
+// environment.
+func FromEnv() (Provider, error) {
+	switch provider := strings.ToLower(os.Getenv("SMS_PROVIDER")); provider {
+	case "", "twilio":
+		twilio := &Twilio{
+			AccountSid: os.Getenv("TWILIO_ACCOUNT_SID"),
+			AuthToken:  os.Getenv("TWILIO_AUTH_TOKEN"),
+			From:       os.Getenv("TWILIO_FROM"),
+		}
+		if twilio.AccountSid == "" || twilio.AuthToken == "" || twilio.From == "" {
+			return nil, ErrNotConfigured
+		}
+		return twilio, nil
+	default:
+		return nil, fmt.Errorf("sms: unknown SMS_PROVIDER %q", provider)
+	}
+}
+
+// Send texts body to the phone number to now.
+func Send(ctx context.Context, to, body string) error {
+	return send(ctx, Message{To: to, Body: body})
+}
+
+func send(ctx context.Context, message Message) error {
+	provider, err := FromEnv()
+	if err != nil {
+		return err
+	}
+	return provider.Send(ctx, message)
+}
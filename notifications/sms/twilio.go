@@ -0,0 +1,48 @@
+package sms
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+var httpClient = &http.Client{Timeout: 30 * time.Second}
+
+// Twilio delivers through Twilio's Messages API, sending from the number
+// From.
+type Twilio struct {
+	AccountSid string
+	AuthToken  string
+	From       string
+}
+
+func (t *Twilio) Send(ctx context.Context, message Message) error {
+	form := url.Values{}
+	form.Set("To", message.To)
+	form.Set("From", t.From)
+	form.Set("Body", message.Body)
+
+	endpoint := "https://api.twilio.com/2010-04-01/Accounts/" + t.AccountSid + "/Messages.json"
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.SetBasicAuth(t.AccountSid, t.AuthToken)
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		detail, _ := io.ReadAll(io.LimitReader(resp.Body, 2048))
+		return fmt.Errorf("sms: twilio returned %d: %s", resp.StatusCode, detail)
+	}
+	return nil
+}
@@ -0,0 +1,28 @@
+package sms
+
+import (
+	"context"
+
+	"restaurant-management/notifications/worker"
+)
+
+var pool = worker.New("SMS", send, func(message Message) string {
+	return "text to " + message.To
+}, ErrNotConfigured)
+
+// Start starts workers that deliver queued texts until ctx is done.
+// Texts still queued then are tried once more before they stop.
+func Start(ctx context.Context, count int) {
+	pool.Start(ctx, count)
+}
+
+// Wait blocks until the workers have stopped, or until ctx is done.
+func Wait(ctx context.Context) error {
+	return pool.Wait(ctx)
+}
+
+// Queue hands message to the workers. When they are too far behind it is
+// dropped, as a text is not worth holding a request up for.
+func Queue(message Message) {
+	pool.Queue(message)
+}
@@ -0,0 +1,120 @@
+// Package worker delivers queued notifications in the background. Each
+// transport brings its send function; the pool owns the queue, the
+// retries and the drain at shutdown.
+package worker
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"time"
+)
+
+// queueSize is how many messages may wait for the workers.
+const queueSize = 256
+
+// sendAttempts is how often delivery is tried, sendRetryDelay the wait
+// before the first retry, doubling after each.
+const (
+	sendAttempts   = 3
+	sendRetryDelay = 2 * time.Second
+	sendTimeout    = 30 * time.Second
+)
+
+// Pool is a queue of messages and the workers that send them.
+type Pool[M any] struct {
+	name      string
+	send      func(ctx context.Context, message M) error
+	describe  func(message M) string
+	unretried error
+	queue     chan M
+	workers   sync.WaitGroup
+}
+
+// New returns a pool that delivers with send. name starts its log lines,
+// describe names a message in them, and a send failing with unretried,
+// such as missing credentials, is not tried again.
+func New[M any](name string, send func(ctx context.Context, message M) error, describe func(message M) string, unretried error) *Pool[M] {
+	return &Pool[M]{
+		name:      name,
+		send:      send,
+		describe:  describe,
+		unretried: unretried,
+		queue:     make(chan M, queueSize),
+	}
+}
+
+// Start starts workers that deliver queued messages until ctx is done.
+// Messages still queued then are tried once more before they stop.
+func (p *Pool[M]) Start(ctx context.Context, count int) {
+	for i := 0; i < count; i++ {
+		p.workers.Add(1)
+		go func() {
+			defer p.workers.Done()
+			for {
+				select {
+				case message := <-p.queue:
+					p.deliver(ctx, message, sendAttempts)
+				case <-ctx.Done():
+					for {
+						select {
+						case message := <-p.queue:
+							p.deliver(context.Background(), message, 1)
+						default:
+							return
+						}
+					}
+				}
+			}
+		}()
+	}
+}
+
+// Wait blocks until the workers have stopped, or until ctx is done.
+func (p *Pool[M]) Wait(ctx context.Context) error {
+	done := make(chan struct{})
+	go func() {
+		p.workers.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Queue hands message to the workers. When they are too far behind it is
+// dropped, as a notification is not worth holding a request up for.
+func (p *Pool[M]) Queue(message M) {
+	select {
+	case p.queue <- message:
+	default:
+		log.Printf("%s queue is full, %s was not sent", p.name, p.describe(message))
+	}
+}
+
+func (p *Pool[M]) deliver(ctx context.Context, message M, attempts int) {
+	delay := sendRetryDelay
+	for attempt := 1; ; attempt++ {
+		sendCtx, cancel := context.WithTimeout(ctx, sendTimeout)
+		err := p.send(sendCtx, message)
+		cancel()
+		if err == nil {
+			return
+		}
+		if (p.unretried != nil && errors.Is(err, p.unretried)) || attempt == attempts {
+			log.Printf("Error sending %s: %v", p.describe(message), err)
+			return
+		}
+		select {
+		case <-time.After(delay):
+			delay *= 2
+		case <-ctx.Done():
+			log.Printf("Error sending %s: %v", p.describe(message), err)
+			return
+		}
+	}
+}
@@ -0,0 +1,123 @@
+package payments
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os"
+	"restaurant-management/config"
+	"strings"
+)
+
+// Sandbox accepts every payment without moving money, for development and
+// testing. Tokens starting with "tok_decline" are declined, as are wallet
+// payments whose payment data contains "decline". Webhooks are JSON
+// WebhookEvents, signed with SANDBOX_WEBHOOK_SECRET in the X-Sandbox-Signature
+// header.
+type Sandbox struct {
+	webhookSecret string
+}
+
+// NewSandbox returns the sandbox gateway. It fails outside development,
+// as it approves every charge, and when SANDBOX_WEBHOOK_SECRET is not set.
+func NewSandbox() (PaymentGateway, error) {
+	if !config.Get().Development() {
+		return nil, errors.New("payments: the SANDBOX gateway is only available when APP_ENV is development")
+	}
+	webhookSecret := os.Getenv("SANDBOX_WEBHOOK_SECRET")
+	if webhookSecret == "" {
+		return nil, errors.New("payments: SANDBOX_WEBHOOK_SECRET is not set")
+	}
+	return &Sandbox{webhookSecret: webhookSecret}, nil
+}
+
+func (s *Sandbox) Name() string {
+	return "SANDBOX"
+}
+
+func (s *Sandbox) Charge(ctx context.Context, amount float64, currency, token, description string) (string, error) {
+	if token == "" {
+		return "", errors.New("payments: payment token is required")
+	}
+	if strings.HasPrefix(token, "tok_decline") {
+		return "", ErrDeclined
+	}
+	return "sandbox_ch_" + randomId(), nil
+}
+
+func (s *Sandbox) Refund(ctx context.Context, chargeId string, amount float64) error {
+	if !strings.HasPrefix(chargeId, "sandbox_") {
+		return errors.New("payments: unknown sandbox charge " + chargeId)
+	}
+	return nil
+}
+
+func (s *Sandbox) Authorize(ctx context.Context, amount float64, currency, token, description string) (string, error) {
+	if token == "" {
+		return "", errors.New("payments: payment token is required")
+	}
+	if strings.HasPrefix(token, "tok_decline") {
+		return "", ErrDeclined
+	}
+	return "sandbox_auth_" + randomId(), nil
+}
+
+func (s *Sandbox) Capture(ctx context.Context, authorizationId string, amount float64) (string, error) {
+	if !strings.HasPrefix(authorizationId, "sandbox_auth_") {
+		return "", errors.New("payments: unknown sandbox authorization " + authorizationId)
+	}
+	return "sandbox_ch_" + randomId(), nil
+}
+
+func (s *Sandbox) Void(ctx context.Context, authorizationId string) error {
+	if !strings.HasPrefix(authorizationId, "sandbox_auth_") && !strings.HasPrefix(authorizationId, "sandbox_pi_") {
+		return errors.New("payments: unknown sandbox authorization " + authorizationId)
+	}
+	return nil
+}
+
+func (s *Sandbox) TokenizeWallet(ctx context.Context, wallet, paymentData string) (string, error) {
+	if paymentData == "" {
+		return "", errors.New("payments: wallet payment data is required")
+	}
+	if strings.Contains(paymentData, "decline") {
+		return "tok_decline_" + randomId(), nil
+	}
+	return "sandbox_wallet_" + strings.ToLower(wallet) + "_" + randomId(), nil
+}
+
+func (s *Sandbox) CreatePaymentIntent(ctx context.Context, amount float64, currency, description string, metadata map[string]string) (PaymentIntent, error) {
+	if amount <= 0 {
+		return PaymentIntent{}, errors.New("payments: amount must be positive")
+	}
+	id := "sandbox_pi_" + randomId()
+	return PaymentIntent{Id: id, Client_secret: id + "_secret_" + randomId()}, nil
+}
+
+func (s *Sandbox) VerifyWebhook(payload []byte, header http.Header) (WebhookEvent, error) {
+	var event WebhookEvent
+	if s.webhookSecret == "" {
+		return event, ErrInvalidSignature
+	}
+	mac := hmac.New(sha256.New, []byte(s.webhookSecret))
+	mac.Write(payload)
+	signature, err := hex.DecodeString(header.Get("X-Sandbox-Signature"))
+	if err != nil || !hmac.Equal(signature, mac.Sum(nil)) {
+		return event, ErrInvalidSignature
+	}
+	if err := json.Unmarshal(payload, &event); err != nil {
+		return event, err
+	}
+	return event, nil
+}
+
+func randomId() string {
+	b := make([]byte, 12)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,320 @@
+package payments
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const stripeAPI = "https://api.stripe.com/v1"
+
+// stripeWebhookTolerance is how old a signed Stripe webhook may be, against
+// replays.
+const stripeWebhookTolerance = 5 * time.Minute
+
+// zeroDecimalCurrencies are charged in whole units by Stripe.
+var zeroDecimalCurrencies = map[string]bool{
+	"BIF": true, "CLP": true, "DJF": true, "GNF": true, "JPY": true, "KMF": true, "KRW": true, "MGA": true,
+	"PYG": true, "RWF": true, "UGX": true, "VND": true, "VUV": true, "XAF": true, "XOF": true, "XPF": true,
+}
+
+// Stripe charges through Stripe PaymentIntents with the secret key in
+// STRIPE_SECRET_KEY. Charge ids and authorization ids are PaymentIntent
+// ids. Webhooks are verified with STRIPE_WEBHOOK_SECRET.
+type Stripe struct {
+	secretKey     string
+	webhookSecret string
+	client        *http.Client
+}
+
+// NewStripe returns the Stripe gateway, failing if STRIPE_SECRET_KEY or
+// STRIPE_WEBHOOK_SECRET is not set.
+func NewStripe() (PaymentGateway, error) {
+	secretKey := os.Getenv("STRIPE_SECRET_KEY")
+	if secretKey == "" {
+		return nil, errors.New("payments: STRIPE_SECRET_KEY is not set")
+	}
+	webhookSecret := os.Getenv("STRIPE_WEBHOOK_SECRET")
+	if webhookSecret == "" {
+		return nil, errors.New("payments: STRIPE_WEBHOOK_SECRET is not set")
+	}
+	return &Stripe{
+		secretKey:     secretKey,
+		webhookSecret: webhookSecret,
+		client:        &http.Client{Timeout: 30 * time.Second},
+	}, nil
+}
+
+func (s *Stripe) Name() string {
+	return "STRIPE"
+}
+
+func (s *Stripe) Charge(ctx context.Context, amount float64, currency, token, description string) (string, error) {
+	return s.createPaymentIntent(ctx, amount, currency, token, description, "automatic")
+}
+
+func (s *Stripe) Authorize(ctx context.Context, amount float64, currency, token, description string) (string, error) {
+	return s.createPaymentIntent(ctx, amount, currency, token, description, "manual")
+}
+
+func (s *Stripe) Capture(ctx context.Context, authorizationId string, amount float64) (string, error) {
+	var intent stripePaymentIntent
+	if err := s.get(ctx, "/payment_intents/"+url.PathEscape(authorizationId), &intent); err != nil {
+		return "", err
+	}
+	form := url.Values{"amount_to_capture": {strconv.FormatInt(minorUnits(amount, intent.Currency), 10)}}
+	if err := s.post(ctx, "/payment_intents/"+url.PathEscape(authorizationId)+"/capture", form, &intent); err != nil {
+		return "", err
+	}
+	return intent.Id, nil
+}
+
+func (s *Stripe) Void(ctx context.Context, authorizationId string) error {
+	return s.post(ctx, "/payment_intents/"+url.PathEscape(authorizationId)+"/cancel", url.Values{}, nil)
+}
+
+func (s *Stripe) Refund(ctx context.Context, chargeId string, amount float64) error {
+	var intent stripePaymentIntent
+	if err := s.get(ctx, "/payment_intents/"+url.PathEscape(chargeId), &intent); err != nil {
+		return err
+	}
+	form := url.Values{
+		"payment_intent": {chargeId},
+		"amount":         {strconv.FormatInt(minorUnits(amount, intent.Currency), 10)},
+	}
+	return s.post(ctx, "/refunds", form, nil)
+}
+
+// CreatePaymentIntent creates an unconfirmed PaymentIntent for Stripe.js to
+// confirm with the client secret, with any payment method the account
+// accepts.
+func (s *Stripe) CreatePaymentIntent(ctx context.Context, amount float64, currency, description string, metadata map[string]string) (PaymentIntent, error) {
+	form := url.Values{
+		"amount":                             {strconv.FormatInt(minorUnits(amount, currency), 10)},
+		"currency":                           {strings.ToLower(currency)},
+		"description":                        {description},
+		"automatic_payment_methods[enabled]": {"true"},
+	}
+	for key, value := range metadata {
+		form.Set("metadata["+key+"]", value)
+	}
+
+	var intent struct {
+		Id            string `json:"id"`
+		Client_secret string `json:"client_secret"`
+	}
+	if err := s.post(ctx, "/payment_intents", form, &intent); err != nil {
+		return PaymentIntent{}, err
+	}
+	return PaymentIntent{Id: intent.Id, Client_secret: intent.Client_secret}, nil
+}
+
+// TokenizeWallet turns an Apple Pay token into a Stripe token. Google Pay
+// set up with Stripe as its gateway already carries a Stripe token, which
+// is taken out of the payment data.
+func (s *Stripe) TokenizeWallet(ctx context.Context, wallet, paymentData string) (string, error) {
+	switch wallet {
+	case "GOOGLE_PAY":
+		var data struct {
+			PaymentMethodData struct {
+				TokenizationData struct {
+					Token string `json:"token"`
+				} `json:"tokenizationData"`
+			} `json:"paymentMethodData"`
+		}
+		if err := json.Unmarshal([]byte(paymentData), &data); err != nil {
+			return "", fmt.Errorf("payments: reading Google Pay payment data: %w", err)
+		}
+		var token struct {
+			Id string `json:"id"`
+		}
+		if err := json.Unmarshal([]byte(data.PaymentMethodData.TokenizationData.Token), &token); err != nil || token.Id == "" {
+			return "", errors.New("payments: Google Pay token was not issued for Stripe")
+		}
+		return token.Id, nil
+	case "APPLE_PAY":
+		var token struct {
+			Id string `json:"id"`
+		}
+		if err := s.post(ctx, "/tokens", url.Values{"pk_token": {paymentData}}, &token); err != nil {
+			return "", err
+		}
+		return token.Id, nil
+	}
+	return "", fmt.Errorf("payments: unsupported wallet %q", wallet)
+}
+
+// VerifyWebhook checks the Stripe-Signature header and reads
+// payment_intent.succeeded, payment_intent.payment_failed and
+// charge.refunded events.
+func (s *Stripe) VerifyWebhook(payload []byte, header http.Header) (WebhookEvent, error) {
+	var event WebhookEvent
+	if s.webhookSecret == "" {
+		return event, errors.New("payments: STRIPE_WEBHOOK_SECRET is not set")
+	}
+
+	var timestamp string
+	var signatures []string
+	for _, part := range strings.Split(header.Get("Stripe-Signature"), ",") {
+		key, value, _ := strings.Cut(part, "=")
+		switch key {
+		case "t":
+			timestamp = value
+		case "v1":
+			signatures = append(signatures, value)
+		}
+	}
+	seconds, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil || time.Since(time.Unix(seconds, 0)).Abs() > stripeWebhookTolerance {
+		return event, ErrInvalidSignature
+	}
+	mac := hmac.New(sha256.New, []byte(s.webhookSecret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(payload)
+	expected := mac.Sum(nil)
+	valid := false
+	for _, signature := range signatures {
+		if decoded, err := hex.DecodeString(signature); err == nil && hmac.Equal(decoded, expected) {
+			valid = true
+		}
+	}
+	if !valid {
+		return event, ErrInvalidSignature
+	}
+
+	var body struct {
+		Id   string `json:"id"`
+		Type string `json:"type"`
+		Data struct {
+			Object struct {
+				Id              string `json:"id"`
+				Payment_intent  string `json:"payment_intent"`
+				Amount          int64  `json:"amount"`
+				Amount_refunded int64  `json:"amount_refunded"`
+				Currency        string `json:"currency"`
+			} `json:"object"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(payload, &body); err != nil {
+		return event, err
+	}
+	object := body.Data.Object
+	event = WebhookEvent{Id: body.Id, Type: "OTHER", Charge_id: object.Id, Currency: strings.ToUpper(object.Currency)}
+	event.Amount = majorUnits(object.Amount, object.Currency)
+	switch body.Type {
+	case "payment_intent.succeeded":
+		event.Type = "CHARGE_SUCCEEDED"
+	case "payment_intent.payment_failed":
+		event.Type = "CHARGE_FAILED"
+	case "charge.refunded":
+		event.Type = "REFUNDED"
+		event.Charge_id = object.Payment_intent
+		event.Amount = majorUnits(object.Amount_refunded, object.Currency)
+	}
+	return event, nil
+}
+
+type stripePaymentIntent struct {
+	Id       string `json:"id"`
+	Status   string `json:"status"`
+	Currency string `json:"currency"`
+}
+
+func (s *Stripe) createPaymentIntent(ctx context.Context, amount float64, currency, token, description, captureMethod string) (string, error) {
+	form := url.Values{
+		"amount":                             {strconv.FormatInt(minorUnits(amount, currency), 10)},
+		"currency":                           {strings.ToLower(currency)},
+		"description":                        {description},
+		"capture_method":                     {captureMethod},
+		"confirm":                            {"true"},
+		"automatic_payment_methods[enabled]": {"true"},
+		"automatic_payment_methods[allow_redirects]": {"never"},
+	}
+	// Tokens from Stripe.js and wallets are card tokens; anything else is a
+	// saved payment method
+	if strings.HasPrefix(token, "tok_") {
+		form.Set("payment_method_data[type]", "card")
+		form.Set("payment_method_data[card][token]", token)
+	} else {
+		form.Set("payment_method", token)
+	}
+
+	var intent stripePaymentIntent
+	if err := s.post(ctx, "/payment_intents", form, &intent); err != nil {
+		return "", err
+	}
+	if intent.Status != "succeeded" && intent.Status != "requires_capture" {
+		return "", fmt.Errorf("payments: Stripe payment is %s", intent.Status)
+	}
+	return intent.Id, nil
+}
+
+func (s *Stripe) get(ctx context.Context, path string, out interface{}) error {
+	return s.do(ctx, http.MethodGet, path, nil, out)
+}
+
+func (s *Stripe) post(ctx context.Context, path string, form url.Values, out interface{}) error {
+	return s.do(ctx, http.MethodPost, path, form, out)
+}
+
+// do sends a request to the Stripe API and decodes the response into out,
+// if given. Card errors are reported as ErrDeclined.
+func (s *Stripe) do(ctx context.Context, method, path string, form url.Values, out interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, method, stripeAPI+path, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.SetBasicAuth(s.secretKey, "")
+	if form != nil {
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		var failure struct {
+			Error struct {
+				Type    string `json:"type"`
+				Message string `json:"message"`
+			} `json:"error"`
+		}
+		json.NewDecoder(resp.Body).Decode(&failure)
+		if failure.Error.Type == "card_error" {
+			return ErrDeclined
+		}
+		return fmt.Errorf("payments: Stripe returned %d: %s", resp.StatusCode, failure.Error.Message)
+	}
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+func minorUnits(amount float64, currency string) int64 {
+	if zeroDecimalCurrencies[strings.ToUpper(currency)] {
+		return int64(math.Round(amount))
+	}
+	return int64(math.Round(amount * 100))
+}
+
+func majorUnits(amount int64, currency string) float64 {
+	if zeroDecimalCurrencies[strings.ToUpper(currency)] {
+		return float64(amount)
+	}
+	return float64(amount) / 100
+}
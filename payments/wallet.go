@@ -0,0 +1,127 @@
+package payments
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// Wallets are the network-tokenized wallets accepted at checkout.
+var Wallets = []string{"APPLE_PAY", "GOOGLE_PAY"}
+
+// ErrWalletNotConfigured is returned when a wallet's merchant settings are
+// missing.
+var ErrWalletNotConfigured = errors.New("payments: wallet is not configured")
+
+// ErrInvalidValidationURL is returned for Apple Pay validation URLs that are
+// not Apple's, so the merchant certificate is never sent anywhere else.
+var ErrInvalidValidationURL = errors.New("payments: validation URL is not an Apple Pay server")
+
+// ApplePayConfig is what the checkout page needs to show the Apple Pay
+// button, read from APPLE_PAY_MERCHANT_ID and APPLE_PAY_DISPLAY_NAME.
+type ApplePayConfig struct {
+	Merchant_id           string   `json:"merchant_id"`
+	Display_name          string   `json:"display_name"`
+	Supported_networks    []string `json:"supported_networks"`
+	Merchant_capabilities []string `json:"merchant_capabilities"`
+}
+
+// GooglePayConfig is the tokenization setup of Google Pay, read from
+// GOOGLE_PAY_MERCHANT_ID, GOOGLE_PAY_GATEWAY and
+// GOOGLE_PAY_GATEWAY_MERCHANT_ID. Environment is GOOGLE_PAY_ENVIRONMENT,
+// TEST unless set to PRODUCTION.
+type GooglePayConfig struct {
+	Environment         string   `json:"environment"`
+	Merchant_id         string   `json:"merchant_id"`
+	Gateway             string   `json:"gateway"`
+	Gateway_merchant_id string   `json:"gateway_merchant_id"`
+	Allowed_networks    []string `json:"allowed_networks"`
+}
+
+// ApplePay returns the Apple Pay settings, or false if it is not set up.
+func ApplePay() (ApplePayConfig, bool) {
+	config := ApplePayConfig{
+		Merchant_id:           os.Getenv("APPLE_PAY_MERCHANT_ID"),
+		Display_name:          os.Getenv("APPLE_PAY_DISPLAY_NAME"),
+		Supported_networks:    []string{"visa", "masterCard", "amex", "discover"},
+		Merchant_capabilities: []string{"supports3DS"},
+	}
+	return config, config.Merchant_id != "" && os.Getenv("APPLE_PAY_CERT_FILE") != "" && os.Getenv("APPLE_PAY_KEY_FILE") != ""
+}
+
+// GooglePay returns the Google Pay settings, or false if it is not set up.
+func GooglePay() (GooglePayConfig, bool) {
+	config := GooglePayConfig{
+		Environment:         "TEST",
+		Merchant_id:         os.Getenv("GOOGLE_PAY_MERCHANT_ID"),
+		Gateway:             os.Getenv("GOOGLE_PAY_GATEWAY"),
+		Gateway_merchant_id: os.Getenv("GOOGLE_PAY_GATEWAY_MERCHANT_ID"),
+		Allowed_networks:    []string{"VISA", "MASTERCARD", "AMEX", "DISCOVER"},
+	}
+	if os.Getenv("GOOGLE_PAY_ENVIRONMENT") == "PRODUCTION" {
+		config.Environment = "PRODUCTION"
+	}
+	return config, config.Gateway != "" && config.Gateway_merchant_id != ""
+}
+
+// ValidateApplePayMerchant asks Apple for a merchant session for the
+// checkout page on domain, authenticating with the merchant identity
+// certificate in APPLE_PAY_CERT_FILE and APPLE_PAY_KEY_FILE. The session is
+// returned as Apple sent it, for the page to complete validation with.
+func ValidateApplePayMerchant(ctx context.Context, validationURL, domain string) (json.RawMessage, error) {
+	config, ok := ApplePay()
+	if !ok {
+		return nil, ErrWalletNotConfigured
+	}
+	parsed, err := url.Parse(validationURL)
+	if err != nil || parsed.Scheme != "https" || !strings.HasSuffix(parsed.Hostname(), ".apple.com") {
+		return nil, ErrInvalidValidationURL
+	}
+
+	certificate, err := tls.LoadX509KeyPair(os.Getenv("APPLE_PAY_CERT_FILE"), os.Getenv("APPLE_PAY_KEY_FILE"))
+	if err != nil {
+		return nil, fmt.Errorf("payments: loading Apple Pay merchant certificate: %w", err)
+	}
+	client := &http.Client{
+		Timeout:   30 * time.Second,
+		Transport: &http.Transport{TLSClientConfig: &tls.Config{Certificates: []tls.Certificate{certificate}}},
+	}
+
+	body, err := json.Marshal(map[string]string{
+		"merchantIdentifier": config.Merchant_id,
+		"displayName":        config.Display_name,
+		"initiative":         "web",
+		"initiativeContext":  domain,
+	})
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, validationURL, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	session, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("payments: Apple Pay merchant validation failed with status %d", resp.StatusCode)
+	}
+	return session, nil
+}
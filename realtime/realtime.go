@@ -0,0 +1,87 @@
+// Package realtime fans out order and kitchen events to connected screens,
+// such as kitchen displays, as they happen. Events only reach subscribers
+// of this process and are not stored; clients load the current state when
+// they connect and apply events from there.
+package realtime
+
+import (
+	"sync"
+	"time"
+)
+
+// subscriberBuffer is how many events a slow subscriber may fall behind
+// before it misses events.
+const subscriberBuffer = 64
+
+// Event is something that happened to an order. Type is order.created,
+// order.updated, orderItems.created, kitchenTicket.created,
+// kitchenTicket.acknowledged, kitchenTicket.bumped or invoice.paid. Station
+// is set for kitchen ticket events.
+type Event struct {
+	Type     string      `json:"type"`
+	Order_id string      `json:"order_id"`
+	Station  string      `json:"station,omitempty"`
+	Data     interface{} `json:"data"`
+	At       time.Time   `json:"at"`
+}
+
+var (
+	mu          sync.RWMutex
+	subscribers = map[chan Event]struct{}{}
+	closed      bool
+)
+
+// Publish sends an event to every subscriber. It never blocks: a subscriber
+// whose buffer is full misses the event.
+func Publish(event Event) {
+	if event.At.IsZero() {
+		event.At = time.Now()
+	}
+	mu.RLock()
+	defer mu.RUnlock()
+	for events := range subscribers {
+		select {
+		case events <- event:
+		default:
+		}
+	}
+}
+
+// Subscribe returns a channel receiving every event published from now on,
+// and the function to call once done with it. The channel is closed when
+// the server shuts down.
+func Subscribe() (<-chan Event, func()) {
+	events := make(chan Event, subscriberBuffer)
+	mu.Lock()
+	if closed {
+		mu.Unlock()
+		close(events)
+		return events, func() {}
+	}
+	subscribers[events] = struct{}{}
+	mu.Unlock()
+
+	var once sync.Once
+	return events, func() {
+		once.Do(func() {
+			mu.Lock()
+			defer mu.Unlock()
+			if _, ok := subscribers[events]; ok {
+				delete(subscribers, events)
+				close(events)
+			}
+		})
+	}
+}
+
+// Close closes every subscriber's channel, so that streams end and their
+// clients reconnect to another instance, and turns away new subscribers.
+func Close() {
+	mu.Lock()
+	defer mu.Unlock()
+	closed = true
+	for events := range subscribers {
+		delete(subscribers, events)
+		close(events)
+	}
+}
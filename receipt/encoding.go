@@ -0,0 +1,30 @@
+package receipt
+
+// cp1252Extras are the Windows-1252 characters outside Latin-1, which both
+// the PDF standard fonts (WinAnsiEncoding) and ESC/POS code page 16 use.
+var cp1252Extras = map[rune]byte{
+	'€': 0x80, '‚': 0x82, 'ƒ': 0x83, '„': 0x84, '…': 0x85, '†': 0x86, '‡': 0x87,
+	'ˆ': 0x88, '‰': 0x89, 'Š': 0x8a, '‹': 0x8b, 'Œ': 0x8c, 'Ž': 0x8e,
+	'‘': 0x91, '’': 0x92, '“': 0x93, '”': 0x94, '•': 0x95, '–': 0x96, '—': 0x97,
+	'˜': 0x98, '™': 0x99, 'š': 0x9a, '›': 0x9b, 'œ': 0x9c, 'ž': 0x9e, 'Ÿ': 0x9f,
+}
+
+// cp1252 encodes a rune as Windows-1252, substituting '?' for characters the
+// code page lacks.
+func cp1252(r rune) byte {
+	if b, ok := cp1252Extras[r]; ok {
+		return b
+	}
+	if r < 0x80 || (r >= 0xa0 && r <= 0xff) {
+		return byte(r)
+	}
+	return '?'
+}
+
+func encodeCP1252(s string) []byte {
+	encoded := make([]byte, 0, len(s))
+	for _, r := range s {
+		encoded = append(encoded, cp1252(r))
+	}
+	return encoded
+}
@@ -0,0 +1,80 @@
+package receipt
+
+import (
+	"bytes"
+	"image"
+)
+
+// ESC/POS command bytes used by the printer output.
+var (
+	escInit      = []byte{0x1b, 0x40}
+	escAlignLeft = []byte{0x1b, 0x61, 0x00}
+	escAlignMid  = []byte{0x1b, 0x61, 0x01}
+	escCut       = []byte{0x1d, 0x56, 0x42, 0x00}
+	// escCodePage selects WPC1252 so currency symbols such as € print.
+	escCodePage = []byte{0x1b, 0x74, 0x10}
+)
+
+// logoDots is the widest logo printed, in dots; 384 fits 58mm paper.
+const logoDots = 384
+
+// ESCPOS renders the receipt as a byte stream for ESC/POS thermal printers,
+// with the logo as a raster image and a paper cut at the end.
+func ESCPOS(t Template, r Receipt) []byte {
+	return escpos(t.Logo, Lines(t, r))
+}
+
+// ESCPOSText prints lines laid out elsewhere, such as prep lists, on the
+// same printers.
+func ESCPOSText(lines []string) []byte {
+	return escpos(nil, lines)
+}
+
+func escpos(logo image.Image, lines []string) []byte {
+	var buf bytes.Buffer
+	buf.Write(escInit)
+	buf.Write(escCodePage)
+	if logo != nil {
+		buf.Write(escAlignMid)
+		buf.Write(rasterImage(logo))
+		buf.WriteByte('\n')
+	}
+	buf.Write(escAlignLeft)
+	for _, line := range lines {
+		buf.Write(encodeCP1252(line))
+		buf.WriteByte('\n')
+	}
+	buf.WriteString("\n\n\n")
+	buf.Write(escCut)
+	return buf.Bytes()
+}
+
+// rasterImage encodes img as a GS v 0 raster bit image, scaled down to fit
+// logoDots and thresholded to black and white.
+func rasterImage(img image.Image) []byte {
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	scale := 1.0
+	if width > logoDots {
+		scale = float64(width) / logoDots
+		width = logoDots
+		height = int(float64(height) / scale)
+	}
+	bytesPerRow := (width + 7) / 8
+
+	var buf bytes.Buffer
+	buf.Write([]byte{0x1d, 0x76, 0x30, 0x00, byte(bytesPerRow), byte(bytesPerRow >> 8), byte(height), byte(height >> 8)})
+	for y := 0; y < height; y++ {
+		row := make([]byte, bytesPerRow)
+		for x := 0; x < width; x++ {
+			src := img.At(bounds.Min.X+int(float64(x)*scale), bounds.Min.Y+int(float64(y)*scale))
+			r, g, b, a := src.RGBA()
+			luminance := (299*r + 587*g + 114*b) / 1000
+			if a > 0x8000 && luminance < 0x8000 {
+				row[x/8] |= 0x80 >> uint(x%8)
+			}
+		}
+		buf.Write(row)
+	}
+	return buf.Bytes()
+}
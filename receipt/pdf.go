@@ -0,0 +1,112 @@
+package receipt
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"strings"
+)
+
+// PDF layout in points. Receipts are printed on an 80mm-wide page that is as
+// tall as the content.
+const (
+	pdfPageWidth = 226.0
+	pdfMargin    = 10.0
+	pdfLeading   = 10.0
+	pdfLogoWidth = 120.0
+)
+
+// PDF renders the receipt as a single-page PDF in a monospaced font so the
+// layout matches the printed receipt.
+func PDF(t Template, r Receipt) ([]byte, error) {
+	return pdf(t.Logo, Lines(t, r), t.Width)
+}
+
+// PDFText renders lines laid out elsewhere, width characters wide, in the
+// receipt's PDF format.
+func PDFText(lines []string, width int) ([]byte, error) {
+	return pdf(nil, lines, width)
+}
+
+func pdf(img image.Image, lines []string, width int) ([]byte, error) {
+	if width <= 0 {
+		width = defaultWidth
+	}
+	fontSize := (pdfPageWidth - 2*pdfMargin) / (0.6 * float64(width))
+
+	var logo []byte
+	logoHeight := 0.0
+	var logoBounds image.Rectangle
+	if img != nil {
+		var encoded bytes.Buffer
+		if err := jpeg.Encode(&encoded, img, &jpeg.Options{Quality: 90}); err != nil {
+			return nil, err
+		}
+		logo = encoded.Bytes()
+		logoBounds = img.Bounds()
+		logoHeight = pdfLogoWidth * float64(logoBounds.Dy()) / float64(logoBounds.Dx())
+	}
+	pageHeight := 2*pdfMargin + logoHeight + float64(len(lines)+1)*pdfLeading
+
+	var content bytes.Buffer
+	if logo != nil {
+		fmt.Fprintf(&content, "q %.2f 0 0 %.2f %.2f %.2f cm /Logo Do Q\n", pdfLogoWidth, logoHeight, (pdfPageWidth-pdfLogoWidth)/2, pageHeight-pdfMargin-logoHeight)
+	}
+	fmt.Fprintf(&content, "BT /F1 %.2f Tf %.2f TL %.2f %.2f Td\n", fontSize, pdfLeading, pdfMargin, pageHeight-pdfMargin-logoHeight-pdfLeading)
+	for _, line := range lines {
+		fmt.Fprintf(&content, "(%s) Tj T*\n", pdfEscape(line))
+	}
+	content.WriteString("ET\n")
+
+	xobjects := ""
+	if logo != nil {
+		xobjects = " /XObject << /Logo 6 0 R >>"
+	}
+	objects := []string{
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R] /Count 1 >>",
+		fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %.2f %.2f] /Resources << /Font << /F1 4 0 R >>%s >> /Contents 5 0 R >>",
+			pdfPageWidth, pageHeight, xobjects),
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Courier /Encoding /WinAnsiEncoding >>",
+		fmt.Sprintf("<< /Length %d >>\nstream\n%s\nendstream", content.Len(), content.String()),
+	}
+	if logo != nil {
+		objects = append(objects, fmt.Sprintf("<< /Type /XObject /Subtype /Image /Width %d /Height %d /ColorSpace /DeviceRGB /BitsPerComponent 8 /Filter /DCTDecode /Length %d >>\nstream\n%s\nendstream",
+			logoBounds.Dx(), logoBounds.Dy(), len(logo), logo))
+	}
+
+	var out bytes.Buffer
+	out.WriteString("%PDF-1.4\n")
+	offsets := make([]int, len(objects))
+	for i, object := range objects {
+		offsets[i] = out.Len()
+		fmt.Fprintf(&out, "%d 0 obj\n%s\nendobj\n", i+1, object)
+	}
+	xref := out.Len()
+	fmt.Fprintf(&out, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&out, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&out, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, xref)
+	return out.Bytes(), nil
+}
+
+// pdfEscape escapes a line for a PDF string literal in WinAnsiEncoding.
+func pdfEscape(s string) string {
+	var b strings.Builder
+	for _, c := range encodeCP1252(s) {
+		switch {
+		case c == '(' || c == ')' || c == '\\':
+			b.WriteByte('\\')
+			b.WriteByte(c)
+		case c < 0x20:
+			b.WriteByte(' ')
+		case c < 0x80:
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "\\%03o", c)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,215 @@
+// Package receipt lays out customer receipts and renders them as plain text,
+// ESC/POS printer commands or PDF from a shared template. Other printouts,
+// such as kitchen prep lists, reuse the ESC/POS and PDF renderers.
+package receipt
+
+import (
+	"image"
+	"restaurant-management/i18n"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Template is the restaurant-specific dressing around the receipt body.
+type Template struct {
+	Business_name string
+	Header        string
+	Footer        string
+	Tax_id        string
+	Promo_message string
+	Logo          image.Image
+	// Width is the paper width in characters: 32 for 58mm, 42 or 48 for 80mm.
+	Width int
+	// Locale formats amounts, dates and labels; Currency overrides the
+	// locale's own currency.
+	Locale   i18n.Locale
+	Currency string
+}
+
+// Line is a priced row on the receipt. Indented rows (adjustments) are
+// printed under the item they belong to.
+type Line struct {
+	Description string
+	Amount      float64
+	Indent      bool
+}
+
+// Tax is a tax total at one rate, labelled with the locale's tax name.
+// Exclusive tax is part of Total; other tax is contained in the prices and
+// only shown.
+type Tax struct {
+	Rate      float64
+	Amount    float64
+	Exclusive bool
+}
+
+// Receipt is the content of one receipt. Total includes the Tip. Deposit
+// was prepaid at booking and is deducted from Total to give the balance
+// due. Split is the part of a split check the receipt is for, such as
+// "2/3"; its Total is that part's share.
+type Receipt struct {
+	Number         string
+	Date           time.Time
+	Table          string
+	Split          string
+	Lines          []Line
+	Subtotal       float64
+	Fees           []Line
+	Taxes          []Tax
+	Cash_rounding  float64
+	Tip            float64
+	Total          float64
+	Deposit        float64
+	Payment_method string
+	Payment_status string
+}
+
+const defaultWidth = 42
+
+// Lines lays the receipt out as fixed-width text lines. It is the common
+// source for every output format.
+func Lines(t Template, r Receipt) []string {
+	width := t.Width
+	if width <= 0 {
+		width = defaultWidth
+	}
+	locale := t.Locale
+	if locale.Code == "" {
+		locale = i18n.Get(i18n.DefaultLocale)
+	}
+	out := []string{}
+	center := func(text string) {
+		for _, line := range wrap(text, width) {
+			pad := (width - len([]rune(line))) / 2
+			out = append(out, strings.Repeat(" ", pad)+line)
+		}
+	}
+	rule := strings.Repeat("-", width)
+	row := func(indent, left string, amount float64) {
+		right := locale.Money(amount, t.Currency)
+		lines := wrap(left, width-len(indent)-len([]rune(right))-1)
+		if len(lines) == 0 {
+			lines = []string{""}
+		}
+		for i := range lines {
+			lines[i] = indent + lines[i]
+		}
+		last := lines[len(lines)-1]
+		out = append(out, lines[:len(lines)-1]...)
+		out = append(out, last+strings.Repeat(" ", width-len([]rune(last))-len([]rune(right)))+right)
+	}
+
+	if t.Business_name != "" {
+		center(t.Business_name)
+	}
+	for _, line := range strings.Split(t.Header, "\n") {
+		if line != "" {
+			center(line)
+		}
+	}
+	if t.Tax_id != "" {
+		center(locale.Tax_id_label + ": " + t.Tax_id)
+	}
+	out = append(out, rule)
+
+	if r.Number != "" {
+		out = append(out, locale.T("receipt")+": "+r.Number)
+	}
+	out = append(out, locale.T("date")+": "+locale.Datetime(r.Date))
+	if r.Table != "" {
+		out = append(out, locale.T("table")+": "+r.Table)
+	}
+	if r.Split != "" {
+		out = append(out, locale.T("split")+": "+r.Split)
+	}
+	out = append(out, rule)
+
+	for _, line := range r.Lines {
+		indent := ""
+		if line.Indent {
+			indent = "  "
+		}
+		row(indent, line.Description, line.Amount)
+	}
+	out = append(out, rule)
+	row("", locale.T("subtotal"), r.Subtotal)
+	for _, fee := range r.Fees {
+		row("", fee.Description, fee.Amount)
+	}
+	for _, tax := range r.Taxes {
+		label := locale.Tax_label + " " + strings.Replace(strconv.FormatFloat(tax.Rate, 'f', -1, 64), ".", locale.Decimal, 1) + "%"
+		if !tax.Exclusive {
+			label += " " + locale.T("included")
+		}
+		row("", label, tax.Amount)
+	}
+	if r.Cash_rounding != 0 {
+		row("", locale.T("cash_rounding"), r.Cash_rounding)
+	}
+	if r.Tip != 0 {
+		row("", locale.T("tip"), r.Tip)
+	}
+	row("", locale.T("total"), r.Total)
+	if r.Deposit != 0 {
+		row("", locale.T("deposit"), -r.Deposit)
+		row("", locale.T("balance_due"), r.Total-r.Deposit)
+	}
+	if r.Payment_method != "" {
+		out = append(out, locale.T("paid_by")+": "+r.Payment_method)
+	}
+	if r.Payment_status != "" && r.Payment_status != "PAID" {
+		out = append(out, locale.T("status")+": "+r.Payment_status)
+	}
+
+	if t.Promo_message != "" {
+		out = append(out, rule)
+		center(t.Promo_message)
+	}
+	if t.Footer != "" {
+		out = append(out, "")
+		for _, line := range strings.Split(t.Footer, "\n") {
+			if line != "" {
+				center(line)
+			}
+		}
+	}
+	return out
+}
+
+// Text renders the receipt as plain text.
+func Text(t Template, r Receipt) string {
+	return strings.Join(Lines(t, r), "\n") + "\n"
+}
+
+func wrap(text string, width int) []string {
+	words := strings.Fields(text)
+	if len(words) == 0 {
+		return nil
+	}
+	lines := []string{}
+	current := ""
+	for _, word := range words {
+		for len([]rune(word)) > width {
+			if current != "" {
+				lines = append(lines, current)
+				current = ""
+			}
+			lines = append(lines, string([]rune(word)[:width]))
+			word = string([]rune(word)[width:])
+		}
+		switch {
+		case current == "":
+			current = word
+		case len([]rune(current))+1+len([]rune(word)) <= width:
+			current += " " + word
+		default:
+			lines = append(lines, current)
+			current = word
+		}
+	}
+	if current != "" {
+		lines = append(lines, current)
+	}
+	return lines
+}
@@ -0,0 +1,59 @@
+package reviews
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"time"
+)
+
+// Google reads the reviews returned by the Places Details API.
+type Google struct {
+	APIKey  string
+	PlaceId string
+}
+
+func (g *Google) Name() string {
+	return "GOOGLE"
+}
+
+func (g *Google) Fetch(ctx context.Context) ([]Review, error) {
+	query := url.Values{}
+	query.Set("place_id", g.PlaceId)
+	query.Set("fields", "reviews")
+	query.Set("reviews_sort", "newest")
+	query.Set("key", g.APIKey)
+
+	var body struct {
+		Status string `json:"status"`
+		Result struct {
+			Reviews []struct {
+				Author_name string  `json:"author_name"`
+				Author_url  string  `json:"author_url"`
+				Rating      float64 `json:"rating"`
+				Text        string  `json:"text"`
+				Time        int64   `json:"time"`
+			} `json:"reviews"`
+		} `json:"result"`
+	}
+	if err := getJSON(ctx, "https://maps.googleapis.com/maps/api/place/details/json?"+query.Encode(), nil, &body); err != nil {
+		return nil, err
+	}
+	if body.Status != "OK" {
+		return nil, fmt.Errorf("google places returned status %s", body.Status)
+	}
+
+	reviews := []Review{}
+	for _, r := range body.Result.Reviews {
+		reviews = append(reviews, Review{
+			// Google does not expose review ids; author and timestamp are stable
+			External_id: fmt.Sprintf("%s:%d", r.Author_url, r.Time),
+			Author:      r.Author_name,
+			Rating:      r.Rating,
+			Text:        r.Text,
+			Url:         r.Author_url,
+			Review_time: time.Unix(r.Time, 0).UTC(),
+		})
+	}
+	return reviews, nil
+}
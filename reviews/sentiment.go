@@ -0,0 +1,58 @@
+package reviews
+
+import (
+	"strings"
+	"unicode"
+)
+
+var positiveWords = map[string]bool{
+	"amazing": true, "awesome": true, "best": true, "delicious": true, "excellent": true,
+	"fantastic": true, "fresh": true, "friendly": true, "good": true, "great": true,
+	"love": true, "loved": true, "perfect": true, "tasty": true, "wonderful": true,
+	"recommend": true, "attentive": true, "nice": true, "yummy": true, "fast": true,
+}
+
+var negativeWords = map[string]bool{
+	"awful": true, "bad": true, "bland": true, "cold": true, "dirty": true,
+	"disappointing": true, "disgusting": true, "horrible": true, "overpriced": true, "poor": true,
+	"rude": true, "slow": true, "stale": true, "terrible": true, "worst": true,
+	"burnt": true, "soggy": true, "raw": true, "undercooked": true, "never": true,
+}
+
+// Sentiment scores a review between -1 (negative) and 1 (positive) by
+// blending a word-list score of the text with the star rating.
+func Sentiment(text string, rating float64) float64 {
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r)
+	})
+
+	positive, negative := 0, 0
+	for _, word := range words {
+		if positiveWords[word] {
+			positive++
+		}
+		if negativeWords[word] {
+			negative++
+		}
+	}
+
+	ratingScore := (rating - 3) / 2
+	if positive+negative == 0 {
+		return ratingScore
+	}
+	textScore := float64(positive-negative) / float64(positive+negative)
+	return (textScore + ratingScore) / 2
+}
+
+// MentionedFoods returns the ids of the foods whose names appear in the text.
+func MentionedFoods(text string, foodNames map[string]string) []string {
+	lower := strings.ToLower(text)
+	mentioned := []string{}
+	for foodId, name := range foodNames {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if len(name) >= 3 && strings.Contains(lower, name) {
+			mentioned = append(mentioned, foodId)
+		}
+	}
+	return mentioned
+}
@@ -0,0 +1,64 @@
+package reviews
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"time"
+)
+
+// Review is a review as fetched from an external platform.
+type Review struct {
+	External_id string
+	Author      string
+	Rating      float64
+	Text        string
+	Url         string
+	Review_time time.Time
+}
+
+// Source pulls recent reviews from one platform.
+type Source interface {
+	Name() string
+	Fetch(ctx context.Context) ([]Review, error)
+}
+
+// ConfiguredSources returns the sources whose credentials are present in
+// the environment.
+func ConfiguredSources() []Source {
+	sources := []Source{}
+	if key, placeId := os.Getenv("GOOGLE_PLACES_API_KEY"), os.Getenv("GOOGLE_PLACE_ID"); key != "" && placeId != "" {
+		sources = append(sources, &Google{APIKey: key, PlaceId: placeId})
+	}
+	if key, businessId := os.Getenv("YELP_API_KEY"), os.Getenv("YELP_BUSINESS_ID"); key != "" && businessId != "" {
+		sources = append(sources, &Yelp{APIKey: key, BusinessId: businessId})
+	}
+	return sources
+}
+
+var httpClient = &http.Client{Timeout: 30 * time.Second}
+
+func getJSON(ctx context.Context, url string, header http.Header, out interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	for key, values := range header {
+		req.Header[key] = values
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		detail, _ := io.ReadAll(io.LimitReader(resp.Body, 2048))
+		return fmt.Errorf("GET %s returned %d: %s", url, resp.StatusCode, detail)
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
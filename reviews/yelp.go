@@ -0,0 +1,52 @@
+package reviews
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// Yelp reads the review excerpts exposed by the Yelp Fusion API.
+type Yelp struct {
+	APIKey     string
+	BusinessId string
+}
+
+func (y *Yelp) Name() string {
+	return "YELP"
+}
+
+func (y *Yelp) Fetch(ctx context.Context) ([]Review, error) {
+	var body struct {
+		Reviews []struct {
+			ID           string  `json:"id"`
+			Url          string  `json:"url"`
+			Text         string  `json:"text"`
+			Rating       float64 `json:"rating"`
+			Time_created string  `json:"time_created"`
+			User         struct {
+				Name string `json:"name"`
+			} `json:"user"`
+		} `json:"reviews"`
+	}
+	header := http.Header{"Authorization": {"Bearer " + y.APIKey}}
+	endpoint := "https://api.yelp.com/v3/businesses/" + url.PathEscape(y.BusinessId) + "/reviews?sort_by=newest"
+	if err := getJSON(ctx, endpoint, header, &body); err != nil {
+		return nil, err
+	}
+
+	reviews := []Review{}
+	for _, r := range body.Reviews {
+		created, _ := time.ParseInLocation("2006-01-02 15:04:05", r.Time_created, time.UTC)
+		reviews = append(reviews, Review{
+			External_id: r.ID,
+			Author:      r.User.Name,
+			Rating:      r.Rating,
+			Text:        r.Text,
+			Url:         r.Url,
+			Review_time: created,
+		})
+	}
+	return reviews, nil
+}
@@ -0,0 +1,18 @@
+package routes
+
+import (
+	controller "restaurant-management/controllers"
+	"restaurant-management/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+func AssetRoutes(incomingRoutes *gin.Engine) {
+	incomingRoutes.GET("/assets", middleware.RequireRole("MANAGER", "WAITER", "KITCHEN"), controller.GetAssets())
+	incomingRoutes.GET("/assets/reminders", middleware.RequireRole("MANAGER", "WAITER", "KITCHEN"), controller.GetAssetReminders())
+	incomingRoutes.PATCH("/assets/reminders/:reminder_id/acknowledge", middleware.RequireRole("MANAGER", "WAITER", "KITCHEN"), controller.AcknowledgeAssetReminder())
+	incomingRoutes.GET("/assets/:asset_id", middleware.RequireRole("MANAGER", "WAITER", "KITCHEN"), controller.GetAsset())
+	incomingRoutes.GET("/assets/:asset_id/history", middleware.RequireRole("MANAGER", "WAITER", "KITCHEN"), controller.GetAssetHistory())
+	incomingRoutes.POST("/assets", middleware.RequireRole("MANAGER"), controller.CreateAsset())
+	incomingRoutes.PATCH("/assets/:asset_id", middleware.RequireRole("MANAGER"), controller.UpdateAsset())
+}
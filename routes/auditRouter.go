@@ -0,0 +1,12 @@
+package routes
+
+import (
+	controller "restaurant-management/controllers"
+	"restaurant-management/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+func AuditRoutes(incomingRoutes *gin.Engine) {
+	incomingRoutes.GET("/audit", middleware.RequireRole("ADMIN"), controller.GetAuditLogs())
+}
@@ -0,0 +1,24 @@
+package routes
+
+import (
+	controller "restaurant-management/controllers"
+	"restaurant-management/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CalendarPublicRoutes serve iCal feeds to calendar apps, which cannot send
+// credentials, and are registered ahead of the authentication middleware.
+func CalendarPublicRoutes(incomingRoutes *gin.Engine) {
+	incomingRoutes.GET("/calendars/:token", controller.GetCalendarFeedIcs())
+}
+
+func CalendarRoutes(incomingRoutes *gin.Engine) {
+	incomingRoutes.GET("/shifts", middleware.RequireRole("MANAGER", "WAITER", "KITCHEN"), controller.GetShifts())
+	incomingRoutes.POST("/shifts", middleware.RequireRole("MANAGER"), controller.CreateShift())
+	incomingRoutes.PATCH("/shifts/:shift_id", middleware.RequireRole("MANAGER"), controller.UpdateShift())
+
+	incomingRoutes.GET("/calendarFeeds", middleware.RequireRole("MANAGER", "WAITER", "KITCHEN"), controller.GetCalendarFeeds())
+	incomingRoutes.POST("/calendarFeeds", middleware.RequireRole("MANAGER", "WAITER", "KITCHEN"), controller.CreateCalendarFeed())
+	incomingRoutes.PATCH("/calendarFeeds/:feed_id", middleware.RequireRole("MANAGER", "WAITER", "KITCHEN"), controller.UpdateCalendarFeed())
+}
@@ -0,0 +1,17 @@
+package routes
+
+import (
+	controller "restaurant-management/controllers"
+	"restaurant-management/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+func CampaignRoutes(incomingRoutes *gin.Engine) {
+	incomingRoutes.GET("/campaigns", middleware.RequireRole("MANAGER"), controller.GetCampaigns())
+	incomingRoutes.GET("/campaigns/:campaign_id", middleware.RequireRole("MANAGER"), controller.GetCampaign())
+	incomingRoutes.POST("/campaigns", middleware.RequireRole("MANAGER"), controller.CreateCampaign())
+	incomingRoutes.POST("/campaigns/:campaign_id/sync", middleware.RequireRole("MANAGER"), controller.SyncCampaignAudience())
+	incomingRoutes.POST("/campaigns/:campaign_id/send", middleware.RequireRole("MANAGER"), controller.SendCampaign())
+	incomingRoutes.POST("/campaigns/redemptions", middleware.RequireRole("MANAGER", "WAITER"), controller.CreateCampaignRedemption())
+}
@@ -0,0 +1,21 @@
+package routes
+
+import (
+	controller "restaurant-management/controllers"
+	"restaurant-management/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+func CashRoutes(incomingRoutes *gin.Engine) {
+	incomingRoutes.GET("/drawerSessions", middleware.RequireRole("MANAGER", "WAITER"), controller.GetDrawerSessions())
+	incomingRoutes.GET("/drawerSessions/report", middleware.RequireRole("MANAGER"), controller.GetOverShortReport())
+	incomingRoutes.GET("/drawerSessions/:drawer_session_id", middleware.RequireRole("MANAGER", "WAITER"), controller.GetDrawerSession())
+	incomingRoutes.POST("/drawerSessions", middleware.RequireRole("MANAGER", "WAITER"), controller.OpenDrawerSession())
+	incomingRoutes.POST("/drawerSessions/:drawer_session_id/close", middleware.RequireRole("MANAGER", "WAITER"), controller.CloseDrawerSession())
+	incomingRoutes.GET("/drawerSessions/:drawer_session_id/movements", middleware.RequireRole("MANAGER", "WAITER"), controller.GetCashMovements())
+	incomingRoutes.POST("/drawerSessions/:drawer_session_id/movements", middleware.RequireRole("MANAGER", "WAITER"), controller.CreateCashMovement())
+	incomingRoutes.GET("/cashRoundingRules", middleware.RequireRole("MANAGER", "WAITER"), controller.GetCashRoundingRules())
+	incomingRoutes.PATCH("/cashRoundingRules/:currency", middleware.RequireRole("MANAGER"), controller.UpdateCashRoundingRule())
+	incomingRoutes.GET("/reports/cash-rounding", middleware.RequireRole("MANAGER"), controller.GetCashRoundingReport())
+}
@@ -0,0 +1,16 @@
+package routes
+
+import (
+	controller "restaurant-management/controllers"
+	"restaurant-management/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+func CategoryRoutes(incomingRoutes *gin.Engine) {
+	incomingRoutes.GET("/categories", controller.GetCategories())
+	incomingRoutes.GET("/categories/:category_id", controller.GetCategory())
+	incomingRoutes.POST("/categories", middleware.RequireRole("MANAGER"), controller.Audit("category"), controller.CreateCategory())
+	incomingRoutes.PATCH("/categories/:category_id", middleware.RequireRole("MANAGER"), controller.Audit("category"), controller.UpdateCategory())
+	incomingRoutes.DELETE("/categories/:category_id", middleware.RequireRole("MANAGER"), controller.Audit("category"), controller.DeleteCategory())
+}
@@ -0,0 +1,15 @@
+package routes
+
+import (
+	controller "restaurant-management/controllers"
+	"restaurant-management/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+func ChannelPolicyRoutes(incomingRoutes *gin.Engine) {
+	incomingRoutes.GET("/channelPolicies", controller.GetChannelPolicies())
+	incomingRoutes.GET("/channelPolicies/:channel", controller.GetChannelPolicy())
+	incomingRoutes.PATCH("/channelPolicies/:channel", middleware.RequireRole("MANAGER"), controller.UpdateChannelPolicy())
+	incomingRoutes.POST("/channelPolicies/check", controller.CheckChannelPolicy())
+}
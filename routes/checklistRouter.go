@@ -0,0 +1,20 @@
+package routes
+
+import (
+	controller "restaurant-management/controllers"
+	"restaurant-management/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+func ChecklistRoutes(incomingRoutes *gin.Engine) {
+	incomingRoutes.GET("/checklists", middleware.RequireRole("MANAGER", "WAITER", "KITCHEN"), controller.GetChecklists())
+	incomingRoutes.GET("/checklists/alerts", middleware.RequireRole("MANAGER", "WAITER", "KITCHEN"), controller.GetChecklistAlerts())
+	incomingRoutes.GET("/checklists/readiness", middleware.RequireRole("MANAGER"), controller.GetInspectionReadiness())
+	incomingRoutes.GET("/checklists/:checklist_id", middleware.RequireRole("MANAGER", "WAITER", "KITCHEN"), controller.GetChecklist())
+	incomingRoutes.POST("/checklists", middleware.RequireRole("MANAGER"), controller.CreateChecklist())
+	incomingRoutes.PATCH("/checklists/:checklist_id", middleware.RequireRole("MANAGER"), controller.UpdateChecklist())
+	incomingRoutes.POST("/checklists/:checklist_id/photos", middleware.RequireRole("MANAGER", "WAITER", "KITCHEN"), controller.UploadChecklistPhoto())
+	incomingRoutes.GET("/checklists/:checklist_id/completions", middleware.RequireRole("MANAGER", "WAITER", "KITCHEN"), controller.GetChecklistCompletions())
+	incomingRoutes.POST("/checklists/:checklist_id/completions", middleware.RequireRole("MANAGER", "WAITER", "KITCHEN"), controller.CompleteChecklist())
+}
@@ -0,0 +1,16 @@
+package routes
+
+import (
+	controller "restaurant-management/controllers"
+	"restaurant-management/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+func ComboRoutes(incomingRoutes *gin.Engine) {
+	incomingRoutes.GET("/combos", controller.GetCombos())
+	incomingRoutes.GET("/combos/:combo_id", controller.GetCombo())
+	incomingRoutes.POST("/combos", middleware.RequireRole("MANAGER"), controller.Audit("combo"), controller.CreateCombo())
+	incomingRoutes.PATCH("/combos/:combo_id", middleware.RequireRole("MANAGER"), controller.Audit("combo"), controller.UpdateCombo())
+	incomingRoutes.DELETE("/combos/:combo_id", middleware.RequireRole("MANAGER"), controller.Audit("combo"), controller.DeleteCombo())
+}
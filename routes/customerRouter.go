@@ -0,0 +1,18 @@
+package routes
+
+import (
+	controller "restaurant-management/controllers"
+	"restaurant-management/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+func CustomerRoutes(incomingRoutes *gin.Engine) {
+	incomingRoutes.GET("/customers", middleware.RequireRole("MANAGER", "WAITER"), controller.GetCustomers())
+	incomingRoutes.GET("/customers/:customer_id", middleware.RequireRole("MANAGER", "WAITER"), controller.GetCustomer())
+	incomingRoutes.POST("/customers", middleware.RequireRole("MANAGER", "WAITER"), controller.Idempotent(), controller.CreateCustomer())
+	incomingRoutes.PATCH("/customers/:customer_id", middleware.RequireRole("MANAGER", "WAITER"), controller.UpdateCustomer())
+	incomingRoutes.GET("/customers/:customer_id/orders", middleware.RequireRole("MANAGER", "WAITER", "CUSTOMER"), controller.GetCustomerOrders())
+	incomingRoutes.GET("/customers/:customer_id/loyalty", middleware.RequireRole("MANAGER", "WAITER", "CUSTOMER"), controller.GetLoyaltyLedger())
+	incomingRoutes.POST("/customers/:customer_id/redeem", middleware.RequireRole("MANAGER", "WAITER"), controller.Idempotent(), controller.RedeemLoyaltyPoints())
+}
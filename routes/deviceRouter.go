@@ -0,0 +1,13 @@
+package routes
+
+import (
+	controller "restaurant-management/controllers"
+	"restaurant-management/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+func DeviceRoutes(incomingRoutes *gin.Engine) {
+	incomingRoutes.POST("/devices", middleware.RequireRole("MANAGER", "WAITER", "KITCHEN"), controller.RegisterDevice())
+	incomingRoutes.DELETE("/devices/:device_id", middleware.RequireRole("MANAGER", "WAITER", "KITCHEN"), controller.DeleteDevice())
+}
@@ -0,0 +1,12 @@
+package routes
+
+import (
+	controller "restaurant-management/controllers"
+	"restaurant-management/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+func DineInRoutes(incomingRoutes *gin.Engine) {
+	incomingRoutes.POST("/dine-in/seat", middleware.RequireRole("MANAGER", "WAITER"), controller.SeatParty())
+}
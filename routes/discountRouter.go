@@ -0,0 +1,19 @@
+package routes
+
+import (
+	controller "restaurant-management/controllers"
+	"restaurant-management/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+func DiscountRoutes(incomingRoutes *gin.Engine) {
+	incomingRoutes.GET("/discounts", middleware.RequireRole("MANAGER", "WAITER"), controller.GetDiscounts())
+	incomingRoutes.GET("/discounts/:discount_id", middleware.RequireRole("MANAGER", "WAITER"), controller.GetDiscount())
+	incomingRoutes.POST("/discounts", middleware.RequireRole("MANAGER"), controller.CreateDiscount())
+	incomingRoutes.PATCH("/discounts/:discount_id", middleware.RequireRole("MANAGER"), controller.UpdateDiscount())
+	incomingRoutes.DELETE("/discounts/:discount_id", middleware.RequireRole("MANAGER"), controller.DeleteDiscount())
+	incomingRoutes.POST("/coupons/check", middleware.RequireRole("MANAGER", "WAITER", "CUSTOMER"), controller.CheckCoupon())
+	incomingRoutes.GET("/discountPolicies/:location", middleware.RequireRole("MANAGER"), controller.GetDiscountPolicy())
+	incomingRoutes.PATCH("/discountPolicies/:location", middleware.RequireRole("MANAGER"), controller.UpdateDiscountPolicy())
+}
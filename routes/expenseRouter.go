@@ -0,0 +1,18 @@
+package routes
+
+import (
+	controller "restaurant-management/controllers"
+	"restaurant-management/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+func ExpenseRoutes(incomingRoutes *gin.Engine) {
+	incomingRoutes.GET("/expenses", middleware.RequireRole("MANAGER"), controller.GetExpenses())
+	incomingRoutes.GET("/expenses/daily-close", middleware.RequireRole("MANAGER"), controller.GetDailyClose())
+	incomingRoutes.GET("/expenses/export", middleware.RequireRole("MANAGER"), controller.ExportExpenses())
+	incomingRoutes.GET("/expenses/:expense_id", middleware.RequireRole("MANAGER"), controller.GetExpense())
+	incomingRoutes.POST("/expenses", middleware.RequireRole("MANAGER", "WAITER", "KITCHEN"), controller.CreateExpense())
+	incomingRoutes.POST("/expenses/:expense_id/receipt", middleware.RequireRole("MANAGER", "WAITER", "KITCHEN"), controller.UploadExpenseReceipt())
+	incomingRoutes.PATCH("/expenses/:expense_id/review", middleware.RequireRole("MANAGER"), controller.ReviewExpense())
+}
@@ -2,13 +2,27 @@ package routes
 
 import (
 	controller "restaurant-management/controllers"
+	"restaurant-management/middleware"
 
 	"github.com/gin-gonic/gin"
 )
 
+// FoodPublicRoutes serve food images, which menus load without a token.
+func FoodPublicRoutes(incomingRoutes *gin.Engine) {
+	incomingRoutes.GET("/images/*key", controller.GetImage())
+}
+
 func FoodRoutes(incomingRoutes *gin.Engine) {
 	incomingRoutes.GET("/foods", controller.GetFoods())
+	incomingRoutes.GET("/foods/search", controller.SearchFoods())
 	incomingRoutes.GET("/foods/:food_id", controller.GetFood())
-	incomingRoutes.POST("/foods", controller.CreateFood())
-	incomingRoutes.PATCH("/foods/:food_id", controller.UpdateFood())
+	incomingRoutes.GET("/foods/:food_id/alternatives", controller.GetFoodAlternatives())
+	incomingRoutes.GET("/foods/:food_id/recommendations", controller.GetFoodRecommendations())
+	incomingRoutes.POST("/foods", middleware.RequireRole("MANAGER"), controller.Idempotent(), controller.Audit("food"), controller.CreateFood())
+	incomingRoutes.PATCH("/foods/:food_id", middleware.RequireRole("MANAGER"), controller.Audit("food"), controller.UpdateFood())
+	incomingRoutes.POST("/foods/:food_id/image", middleware.RequireRole("MANAGER"), controller.Audit("food"), controller.UploadFoodImage())
+	incomingRoutes.PATCH("/foods/:food_id/availability", middleware.RequireRole("MANAGER", "KITCHEN"), controller.Audit("food"), controller.UpdateFoodAvailability())
+	incomingRoutes.PUT("/foods/:food_id/substitutions", middleware.RequireRole("MANAGER"), controller.Audit("food"), controller.UpdateFoodSubstitutions())
+	incomingRoutes.DELETE("/foods/:food_id", middleware.RequireRole("MANAGER"), controller.Audit("food"), controller.DeleteFood())
+	incomingRoutes.POST("/foods/:food_id/restore", middleware.RequireRole("ADMIN"), controller.Audit("food"), controller.RestoreFood())
 }
@@ -0,0 +1,13 @@
+package routes
+
+import (
+	controller "restaurant-management/controllers"
+	"restaurant-management/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+func ForecastRoutes(incomingRoutes *gin.Engine) {
+	incomingRoutes.GET("/forecast", middleware.RequireRole("MANAGER", "KITCHEN"), controller.GetForecast())
+	incomingRoutes.GET("/forecast/prep-list", middleware.RequireRole("MANAGER", "KITCHEN"), controller.GetPrepList())
+}
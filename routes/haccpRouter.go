@@ -0,0 +1,26 @@
+package routes
+
+import (
+	controller "restaurant-management/controllers"
+	"restaurant-management/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+// HaccpIngestRoutes take readings from IoT probes, which authenticate with a
+// per-unit ingest token instead of a user session.
+func HaccpIngestRoutes(incomingRoutes *gin.Engine) {
+	incomingRoutes.POST("/haccp/ingest", controller.IngestSensorReading())
+}
+
+func HaccpRoutes(incomingRoutes *gin.Engine) {
+	incomingRoutes.GET("/haccp/units", middleware.RequireRole("MANAGER", "KITCHEN"), controller.GetTemperatureUnits())
+	incomingRoutes.POST("/haccp/units", middleware.RequireRole("MANAGER"), controller.CreateTemperatureUnit())
+	incomingRoutes.PATCH("/haccp/units/:unit_id", middleware.RequireRole("MANAGER"), controller.UpdateTemperatureUnit())
+	incomingRoutes.POST("/haccp/units/:unit_id/token", middleware.RequireRole("MANAGER"), controller.RotateIngestToken())
+	incomingRoutes.GET("/haccp/units/:unit_id/readings", middleware.RequireRole("MANAGER", "KITCHEN"), controller.GetTemperatureReadings())
+	incomingRoutes.POST("/haccp/units/:unit_id/readings", middleware.RequireRole("MANAGER", "KITCHEN"), controller.RecordTemperature())
+	incomingRoutes.GET("/haccp/alerts", middleware.RequireRole("MANAGER", "KITCHEN"), controller.GetTemperatureAlerts())
+	incomingRoutes.PATCH("/haccp/alerts/:alert_id/resolve", middleware.RequireRole("MANAGER", "KITCHEN"), controller.ResolveTemperatureAlert())
+	incomingRoutes.GET("/haccp/export", middleware.RequireRole("MANAGER", "KITCHEN"), controller.ExportTemperatureLog())
+}
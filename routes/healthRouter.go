@@ -0,0 +1,14 @@
+package routes
+
+import (
+	controller "restaurant-management/controllers"
+
+	"github.com/gin-gonic/gin"
+)
+
+// HealthRoutes are the probes of load balancers and orchestrators, which
+// carry no token.
+func HealthRoutes(incomingRoutes *gin.Engine) {
+	incomingRoutes.GET("/healthz", controller.Healthz())
+	incomingRoutes.GET("/readyz", controller.Readyz())
+}
@@ -2,13 +2,21 @@ package routes
 
 import (
 	controller "restaurant-management/controllers"
+	"restaurant-management/middleware"
 
 	"github.com/gin-gonic/gin"
 )
 
 func InvoiceRoutes(incomingRoutes *gin.Engine) {
-	incomingRoutes.GET("/invoices", controller.GetInvoices())
+	incomingRoutes.GET("/invoices", middleware.RequireRole("MANAGER", "WAITER"), controller.GetInvoices())
 	incomingRoutes.GET("/invoices/:invoice_id", controller.GetInvoice())
-	incomingRoutes.POST("/invoices", controller.CreateInvoice())
-	incomingRoutes.PATCH("/invoice/:invoice_id", controller.UpdateInvoice())
+	incomingRoutes.POST("/invoices", middleware.RequireRole("MANAGER", "WAITER"), controller.Idempotent(), controller.CreateInvoice())
+	incomingRoutes.PATCH("/invoice/:invoice_id", middleware.RequireRole("MANAGER", "WAITER"), controller.UpdateInvoice())
+	incomingRoutes.POST("/invoices/:invoice_id/finalize", middleware.RequireRole("MANAGER", "WAITER"), controller.FinalizeInvoice())
+	incomingRoutes.POST("/invoices/:invoice_id/discounts", middleware.RequireRole("MANAGER", "WAITER"), controller.Audit("invoice"), controller.AddInvoiceDiscount())
+	incomingRoutes.GET("/reports/sales", middleware.RequireRole("MANAGER"), controller.GetSalesReport())
+	incomingRoutes.GET("/reports/top-foods", middleware.RequireRole("MANAGER", "KITCHEN"), controller.GetTopFoodsReport())
+	incomingRoutes.GET("/reports/tables", middleware.RequireRole("MANAGER"), controller.GetTableRevenueReport())
+	incomingRoutes.GET("/reports/staff", middleware.RequireRole("MANAGER"), controller.GetStaffRevenueReport())
+	incomingRoutes.GET("/reports/summary", middleware.RequireRole("MANAGER"), controller.GetDashboardSummary())
 }
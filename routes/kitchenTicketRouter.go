@@ -0,0 +1,16 @@
+package routes
+
+import (
+	controller "restaurant-management/controllers"
+	"restaurant-management/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+func KitchenTicketRoutes(incomingRoutes *gin.Engine) {
+	incomingRoutes.GET("/kitchenTickets", middleware.RequireRole("MANAGER", "WAITER", "KITCHEN"), controller.GetKitchenTickets())
+	incomingRoutes.POST("/kitchenTickets/:kitchen_ticket_id/acknowledge", middleware.RequireRole("MANAGER", "KITCHEN"), controller.AcknowledgeAllergyAlert())
+	incomingRoutes.POST("/kitchenTickets/:kitchen_ticket_id/bump", middleware.RequireRole("MANAGER", "KITCHEN"), controller.BumpKitchenTicket())
+	incomingRoutes.GET("/ws/kitchen", middleware.RequireRole("MANAGER", "KITCHEN", "WAITER"), controller.KitchenFeed())
+	incomingRoutes.GET("/allergyAcknowledgments", middleware.RequireRole("MANAGER", "WAITER", "KITCHEN"), controller.GetAllergyAcknowledgments())
+}
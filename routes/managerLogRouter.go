@@ -0,0 +1,15 @@
+package routes
+
+import (
+	controller "restaurant-management/controllers"
+	"restaurant-management/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+func ManagerLogRoutes(incomingRoutes *gin.Engine) {
+	incomingRoutes.GET("/managerLog", middleware.RequireRole("MANAGER"), controller.GetManagerNotes())
+	incomingRoutes.POST("/managerLog", middleware.RequireRole("MANAGER"), controller.CreateManagerNote())
+	incomingRoutes.PATCH("/managerLog/:manager_note_id", middleware.RequireRole("MANAGER"), controller.UpdateManagerNote())
+	incomingRoutes.GET("/reports/end-of-day", middleware.RequireRole("MANAGER"), controller.GetEndOfDayReport())
+}
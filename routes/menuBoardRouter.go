@@ -0,0 +1,16 @@
+package routes
+
+import (
+	controller "restaurant-management/controllers"
+	"restaurant-management/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+func MenuBoardRoutes(incomingRoutes *gin.Engine) {
+	incomingRoutes.GET("/boards", middleware.RequireRole("MANAGER"), controller.GetMenuBoards())
+	incomingRoutes.POST("/boards", middleware.RequireRole("MANAGER"), controller.CreateMenuBoard())
+	incomingRoutes.PATCH("/boards/:board_id", middleware.RequireRole("MANAGER"), controller.UpdateMenuBoard())
+	incomingRoutes.GET("/boards/:board_id/content", controller.GetMenuBoardContent())
+	incomingRoutes.GET("/boards/:board_id/stream", controller.StreamMenuBoardContent())
+}
@@ -2,13 +2,22 @@ package routes
 
 import (
 	controller "restaurant-management/controllers"
+	"restaurant-management/middleware"
 
 	"github.com/gin-gonic/gin"
 )
 
 func MenuRoutes(incomingRoutes *gin.Engine) {
 	incomingRoutes.GET("/menus", controller.GetMenus())
+	incomingRoutes.GET("/menu/personalized", controller.GetPersonalizedMenu())
 	incomingRoutes.GET("/menus/:menu_id", controller.GetMenu())
-	incomingRoutes.POST("/menus", controller.CreateMenu())
-	incomingRoutes.PATCH("/menus/:menu_id", controller.UpdateMenu())
+	incomingRoutes.POST("/menus", middleware.RequireRole("MANAGER"), controller.Audit("menu"), controller.CreateMenu())
+	incomingRoutes.PATCH("/menus/:menu_id", middleware.RequireRole("MANAGER"), controller.Audit("menu"), controller.UpdateMenu())
+	incomingRoutes.POST("/menus/:menu_id/publish", middleware.RequireRole("MANAGER"), controller.Audit("menu"), controller.PublishMenu())
+	incomingRoutes.POST("/menus/:menu_id/archive", middleware.RequireRole("MANAGER"), controller.Audit("menu"), controller.ArchiveMenu())
+	incomingRoutes.GET("/menus/:menu_id/versions", middleware.RequireRole("MANAGER"), controller.GetMenuVersions())
+	incomingRoutes.GET("/menus/:menu_id/versions/:version", middleware.RequireRole("MANAGER"), controller.GetMenuVersion())
+	incomingRoutes.POST("/menus/:menu_id/versions/:version/rollback", middleware.RequireRole("MANAGER"), controller.Audit("menu"), controller.RollbackMenu())
+	incomingRoutes.DELETE("/menus/:menu_id", middleware.RequireRole("MANAGER"), controller.Audit("menu"), controller.DeleteMenu())
+	incomingRoutes.POST("/menus/:menu_id/restore", middleware.RequireRole("ADMIN"), controller.Audit("menu"), controller.RestoreMenu())
 }
@@ -0,0 +1,16 @@
+package routes
+
+import (
+	controller "restaurant-management/controllers"
+	"restaurant-management/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+func ModifierRoutes(incomingRoutes *gin.Engine) {
+	incomingRoutes.GET("/modifierGroups", controller.GetModifierGroups())
+	incomingRoutes.GET("/modifierGroups/:modifier_group_id", controller.GetModifierGroup())
+	incomingRoutes.POST("/modifierGroups", middleware.RequireRole("MANAGER"), controller.Audit("modifier_group"), controller.CreateModifierGroup())
+	incomingRoutes.PATCH("/modifierGroups/:modifier_group_id", middleware.RequireRole("MANAGER"), controller.Audit("modifier_group"), controller.UpdateModifierGroup())
+	incomingRoutes.DELETE("/modifierGroups/:modifier_group_id", middleware.RequireRole("MANAGER"), controller.Audit("modifier_group"), controller.DeleteModifierGroup())
+}
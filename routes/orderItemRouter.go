@@ -2,14 +2,15 @@ package routes
 
 import (
 	controller "restaurant-management/controllers"
+	"restaurant-management/middleware"
 
 	"github.com/gin-gonic/gin"
 )
 
 func OrderItemRoutes(incomingRoutes *gin.Engine) {
-	incomingRoutes.GET("/orderItems", controller.GetOrderItems())
+	incomingRoutes.GET("/orderItems", middleware.RequireRole("MANAGER", "WAITER", "KITCHEN"), controller.GetOrderItems())
 	incomingRoutes.GET("/orderItems/:orderItem_id", controller.GetOrderItem())
 	incomingRoutes.GET("/orderItems-order/:order_id", controller.GetOrderItemsByOrder())
-	incomingRoutes.POST("/orderItems", controller.CreateOrderItem())
-	incomingRoutes.PATCH("/orderItems/:orderItem_id", controller.UpdateOrderItem())
+	incomingRoutes.POST("/orderItems", middleware.RequireRole("MANAGER", "WAITER", "CUSTOMER"), controller.Idempotent(), controller.CreateOrderItem())
+	incomingRoutes.PATCH("/orderItems/:orderItem_id", middleware.RequireRole("MANAGER", "WAITER"), controller.UpdateOrderItem())
 }
@@ -2,13 +2,26 @@ package routes
 
 import (
 	controller "restaurant-management/controllers"
+	"restaurant-management/middleware"
 
 	"github.com/gin-gonic/gin"
 )
 
 func OrderRoutes(incomingRoutes *gin.Engine) {
-	incomingRoutes.GET("/orders", controller.GetOrders())
+	incomingRoutes.GET("/orders", middleware.RequireRole("MANAGER", "WAITER", "KITCHEN"), controller.GetOrders())
+	incomingRoutes.GET("/orders/export", middleware.RequireRole("MANAGER"), controller.ExportOrders())
 	incomingRoutes.GET("/orders/:order_id", controller.GetOrder())
-	incomingRoutes.POST("/orders", controller.CreateOrder())
-	incomingRoutes.PATCH("/orders/:order_id", controller.UpdateOrder())
+	incomingRoutes.POST("/orders/merge", middleware.RequireRole("MANAGER", "WAITER"), controller.Audit("order"), controller.MergeOrders())
+	incomingRoutes.POST("/orders", middleware.RequireRole("MANAGER", "WAITER", "CUSTOMER"), controller.Idempotent(), controller.Audit("order"), controller.CreateOrder())
+	incomingRoutes.PATCH("/orders/:order_id", middleware.RequireRole("MANAGER", "WAITER"), controller.Audit("order"), controller.UpdateOrder())
+	incomingRoutes.POST("/orders/:order_id/transfer", middleware.RequireRole("MANAGER", "WAITER"), controller.Audit("order"), controller.TransferOrder())
+	incomingRoutes.POST("/orders/:order_id/moveItems", middleware.RequireRole("MANAGER", "WAITER"), controller.Audit("order"), controller.MoveOrderItems())
+	incomingRoutes.POST("/orders/:order_id/merge", middleware.RequireRole("MANAGER", "WAITER"), controller.Audit("order"), controller.MergeOrder())
+	incomingRoutes.POST("/orders/:order_id/split", middleware.RequireRole("MANAGER", "WAITER"), controller.Idempotent(), controller.Audit("order"), controller.SplitOrder())
+	incomingRoutes.GET("/orders/:order_id/events", middleware.RequireRole("MANAGER", "WAITER", "KITCHEN"), controller.GetOrderEvents())
+	incomingRoutes.GET("/orders/:order_id/pricing", controller.GetOrderPricing())
+	incomingRoutes.POST("/orders/:order_id/discounts", middleware.RequireRole("MANAGER", "WAITER"), controller.Audit("order"), controller.AddOrderDiscount())
+	incomingRoutes.DELETE("/orders/:order_id/discounts/:order_discount_id", middleware.RequireRole("MANAGER", "WAITER"), controller.Audit("order"), controller.RemoveOrderDiscount())
+	incomingRoutes.DELETE("/orders/:order_id", middleware.RequireRole("MANAGER"), controller.Audit("order"), controller.DeleteOrder())
+	incomingRoutes.POST("/orders/:order_id/restore", middleware.RequireRole("ADMIN"), controller.Audit("order"), controller.RestoreOrder())
 }
@@ -0,0 +1,22 @@
+package routes
+
+import (
+	controller "restaurant-management/controllers"
+	"restaurant-management/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+// PaymentPublicRoutes take webhooks from payment gateways, which sign them
+// instead of sending credentials, and are registered ahead of the
+// authentication middleware.
+func PaymentPublicRoutes(incomingRoutes *gin.Engine) {
+	incomingRoutes.POST("/payments/webhooks/:gateway", controller.PaymentWebhook())
+}
+
+func PaymentRoutes(incomingRoutes *gin.Engine) {
+	incomingRoutes.GET("/payments/wallets", controller.GetWalletConfig())
+	incomingRoutes.POST("/payments/applePay/session", middleware.RequireRole("MANAGER", "WAITER", "CUSTOMER"), controller.CreateApplePaySession())
+	incomingRoutes.POST("/orders/:order_id/walletPayment", middleware.RequireRole("MANAGER", "WAITER", "CUSTOMER"), controller.PayOrderWithWallet())
+	incomingRoutes.POST("/invoices/:invoice_id/pay", middleware.RequireRole("MANAGER", "WAITER", "CUSTOMER"), controller.PayInvoice())
+}
@@ -0,0 +1,23 @@
+package routes
+
+import (
+	controller "restaurant-management/controllers"
+	"restaurant-management/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+func PayrollRoutes(incomingRoutes *gin.Engine) {
+	incomingRoutes.GET("/timeClock", middleware.RequireRole("MANAGER"), controller.GetTimeClockRecords())
+	incomingRoutes.POST("/timeClock/clock-in", middleware.RequireRole("MANAGER", "WAITER", "KITCHEN"), controller.ClockIn())
+	incomingRoutes.POST("/timeClock/:time_clock_record_id/clock-out", middleware.RequireRole("MANAGER", "WAITER", "KITCHEN"), controller.ClockOut())
+	incomingRoutes.PATCH("/timeClock/:time_clock_record_id/approve", middleware.RequireRole("MANAGER"), controller.ApproveTimeClockRecord())
+
+	incomingRoutes.GET("/tips", middleware.RequireRole("MANAGER"), controller.GetTipDistributions())
+	incomingRoutes.POST("/tips", middleware.RequireRole("MANAGER"), controller.CreateTipDistribution())
+
+	incomingRoutes.GET("/overtimeRules", middleware.RequireRole("MANAGER"), controller.GetOvertimeRules())
+	incomingRoutes.PATCH("/overtimeRules/:location", middleware.RequireRole("MANAGER"), controller.UpdateOvertimeRule())
+
+	incomingRoutes.GET("/payroll/export", middleware.RequireRole("MANAGER"), controller.ExportPayroll())
+}
@@ -0,0 +1,17 @@
+package routes
+
+import (
+	controller "restaurant-management/controllers"
+	"restaurant-management/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+func PrepListRoutes(incomingRoutes *gin.Engine) {
+	incomingRoutes.GET("/recipes", middleware.RequireRole("MANAGER", "KITCHEN"), controller.GetRecipes())
+	incomingRoutes.PATCH("/recipes/:food_id", middleware.RequireRole("MANAGER"), controller.UpdateRecipe())
+	incomingRoutes.GET("/inventory", middleware.RequireRole("MANAGER", "KITCHEN"), controller.GetInventory())
+	incomingRoutes.PATCH("/inventory/:sku", middleware.RequireRole("MANAGER", "KITCHEN"), controller.UpdateInventoryItem())
+	incomingRoutes.GET("/prepLists", middleware.RequireRole("MANAGER", "KITCHEN"), controller.GetStationPrepLists())
+	incomingRoutes.POST("/prepLists/tasks", middleware.RequireRole("MANAGER", "KITCHEN"), controller.CreatePrepTasks())
+}
@@ -0,0 +1,16 @@
+package routes
+
+import (
+	controller "restaurant-management/controllers"
+	"restaurant-management/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+func PriceScheduleRoutes(incomingRoutes *gin.Engine) {
+	incomingRoutes.GET("/priceSchedules", middleware.RequireRole("MANAGER"), controller.GetPriceSchedules())
+	incomingRoutes.GET("/priceSchedules/calendar", middleware.RequireRole("MANAGER"), controller.GetPriceScheduleCalendar())
+	incomingRoutes.GET("/priceSchedules/:price_schedule_id", middleware.RequireRole("MANAGER"), controller.GetPriceSchedule())
+	incomingRoutes.POST("/priceSchedules", middleware.RequireRole("MANAGER"), controller.CreatePriceSchedule())
+	incomingRoutes.PATCH("/priceSchedules/:price_schedule_id", middleware.RequireRole("MANAGER"), controller.UpdatePriceSchedule())
+}
@@ -0,0 +1,23 @@
+package routes
+
+import (
+	controller "restaurant-management/controllers"
+	"restaurant-management/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+func PurchasingRoutes(incomingRoutes *gin.Engine) {
+	incomingRoutes.GET("/purchaseOrders", middleware.RequireRole("MANAGER"), controller.GetPurchaseOrders())
+	incomingRoutes.GET("/purchaseOrders/:purchase_order_id", middleware.RequireRole("MANAGER"), controller.GetPurchaseOrder())
+	incomingRoutes.POST("/purchaseOrders", middleware.RequireRole("MANAGER"), controller.CreatePurchaseOrder())
+	incomingRoutes.GET("/purchaseOrders/:purchase_order_id/receipts", middleware.RequireRole("MANAGER"), controller.GetReceivingRecords())
+	incomingRoutes.POST("/purchaseOrders/:purchase_order_id/receipts", middleware.RequireRole("MANAGER", "KITCHEN"), controller.CreateReceivingRecord())
+
+	incomingRoutes.GET("/supplierInvoices", middleware.RequireRole("MANAGER"), controller.GetSupplierInvoices())
+	incomingRoutes.GET("/supplierInvoices/:supplier_invoice_id", middleware.RequireRole("MANAGER"), controller.GetSupplierInvoice())
+	incomingRoutes.POST("/supplierInvoices", middleware.RequireRole("MANAGER"), controller.CreateSupplierInvoice())
+	incomingRoutes.POST("/supplierInvoices/import", middleware.RequireRole("MANAGER"), controller.ImportSupplierInvoices())
+	incomingRoutes.POST("/supplierInvoices/:supplier_invoice_id/match", middleware.RequireRole("MANAGER"), controller.MatchSupplierInvoice())
+	incomingRoutes.PATCH("/supplierInvoices/:supplier_invoice_id/resolve", middleware.RequireRole("MANAGER"), controller.ResolveSupplierInvoice())
+}
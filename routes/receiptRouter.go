@@ -0,0 +1,17 @@
+package routes
+
+import (
+	controller "restaurant-management/controllers"
+	"restaurant-management/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+func ReceiptRoutes(incomingRoutes *gin.Engine) {
+	incomingRoutes.GET("/receiptTemplates", middleware.RequireRole("MANAGER"), controller.GetReceiptTemplates())
+	incomingRoutes.GET("/receiptTemplates/:location", middleware.RequireRole("MANAGER"), controller.GetReceiptTemplate())
+	incomingRoutes.PATCH("/receiptTemplates/:location", middleware.RequireRole("MANAGER"), controller.UpdateReceiptTemplate())
+	incomingRoutes.POST("/receiptTemplates/:location/logo", middleware.RequireRole("MANAGER"), controller.UploadReceiptLogo())
+	incomingRoutes.POST("/receiptTemplates/:location/preview", middleware.RequireRole("MANAGER"), controller.PreviewReceiptTemplate())
+	incomingRoutes.GET("/invoices/:invoice_id/receipt", controller.GetInvoiceReceipt())
+}
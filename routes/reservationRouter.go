@@ -0,0 +1,46 @@
+package routes
+
+import (
+	controller "restaurant-management/controllers"
+	"restaurant-management/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ReservationPublicRoutes back the confirm, cancel and opt-out links in
+// reservation reminders and are registered ahead of the authentication middleware.
+// Opening a link only shows the booking, as mail scanners and message
+// previews open links too; the change itself is POSTed.
+func ReservationPublicRoutes(incomingRoutes *gin.Engine) {
+	incomingRoutes.GET("/reservations/respond/:token", controller.GetReservationByToken())
+	incomingRoutes.GET("/reservations/respond/:token/confirm", controller.GetReservationByToken())
+	incomingRoutes.POST("/reservations/respond/:token/confirm", controller.ConfirmReservationByToken())
+	incomingRoutes.GET("/reservations/respond/:token/cancel", controller.GetReservationByToken())
+	incomingRoutes.POST("/reservations/respond/:token/cancel", controller.CancelReservationByToken())
+	incomingRoutes.GET("/reservations/respond/:token/stopReminders", controller.GetReservationByToken())
+	incomingRoutes.POST("/reservations/respond/:token/stopReminders", controller.StopRemindersByToken())
+}
+
+func ReservationRoutes(incomingRoutes *gin.Engine) {
+	incomingRoutes.GET("/reservations", middleware.RequireRole("MANAGER", "WAITER"), controller.GetReservations())
+	incomingRoutes.GET("/reservations/deposit", controller.GetDepositQuote())
+	incomingRoutes.GET("/reservations/availability", controller.GetReservationAvailability())
+	incomingRoutes.GET("/reservations/conflicts", middleware.RequireRole("MANAGER", "WAITER"), controller.GetReservationConflicts())
+	incomingRoutes.PATCH("/reservations/conflicts/:conflict_id/review", middleware.RequireRole("MANAGER"), controller.ReviewReservationConflict())
+	incomingRoutes.GET("/reservations/:reservation_id", middleware.RequireRole("MANAGER", "WAITER"), controller.GetReservation())
+	incomingRoutes.GET("/reservations/:reservation_id/reminders", middleware.RequireRole("MANAGER", "WAITER"), controller.GetReservationReminders())
+	incomingRoutes.POST("/reservations", middleware.RequireRole("MANAGER", "WAITER"), controller.CreateReservation())
+	incomingRoutes.PATCH("/reservations/:reservation_id", middleware.RequireRole("MANAGER", "WAITER"), controller.UpdateReservation())
+	incomingRoutes.POST("/reservations/:reservation_id/cancel", middleware.RequireRole("MANAGER", "WAITER"), controller.CancelReservation())
+	incomingRoutes.POST("/reservations/:reservation_id/seat", middleware.RequireRole("MANAGER", "WAITER"), controller.SeatReservation())
+	incomingRoutes.POST("/reservations/:reservation_id/noShow", middleware.RequireRole("MANAGER", "WAITER"), controller.MarkNoShow())
+	incomingRoutes.GET("/depositPolicies/:location", controller.GetDepositPolicy())
+	incomingRoutes.PATCH("/depositPolicies/:location", middleware.RequireRole("MANAGER"), controller.UpdateDepositPolicy())
+	incomingRoutes.GET("/reservationCapacity/:location", middleware.RequireRole("MANAGER", "WAITER"), controller.GetReservationCapacity())
+	incomingRoutes.PATCH("/reservationCapacity/:location", middleware.RequireRole("MANAGER"), controller.UpdateReservationCapacity())
+
+	incomingRoutes.GET("/bookingConnectors", middleware.RequireRole("MANAGER"), controller.GetBookingConnectors())
+	incomingRoutes.POST("/bookingConnectors", middleware.RequireRole("MANAGER"), controller.CreateBookingConnector())
+	incomingRoutes.PATCH("/bookingConnectors/:connector_id", middleware.RequireRole("MANAGER"), controller.UpdateBookingConnector())
+	incomingRoutes.POST("/bookingConnectors/:connector_id/sync", middleware.RequireRole("MANAGER"), controller.SyncBookingConnector())
+}
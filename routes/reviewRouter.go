@@ -0,0 +1,14 @@
+package routes
+
+import (
+	controller "restaurant-management/controllers"
+	"restaurant-management/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+func ReviewRoutes(incomingRoutes *gin.Engine) {
+	incomingRoutes.GET("/reviews", middleware.RequireRole("MANAGER"), controller.GetReviews())
+	incomingRoutes.GET("/reviews/dashboard", middleware.RequireRole("MANAGER"), controller.GetReviewDashboard())
+	incomingRoutes.POST("/reviews/ingest", middleware.RequireRole("MANAGER"), controller.TriggerReviewIngestion())
+}
@@ -0,0 +1,85 @@
+package routes
+
+import (
+	"restaurant-management/config"
+	controller "restaurant-management/controllers"
+	"restaurant-management/helpers"
+	"restaurant-management/middleware"
+	"restaurant-management/services"
+
+	"github.com/gin-gonic/gin"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// NewRouter binds the controllers and services to client and serves their
+// routes: the public ones first, then those behind authentication.
+func NewRouter(cfg *config.Config, client *mongo.Client) *gin.Engine {
+	controller.Bind(client)
+	services.Bind(client)
+
+	engine := gin.New()
+	engine.Use(gin.Logger())
+	engine.Use(middleware.CORS(cfg.CORS))
+
+	HealthRoutes(engine)
+	UserPublicRoutes(engine)
+	SurveyPublicRoutes(engine)
+	HaccpIngestRoutes(engine)
+	ReservationPublicRoutes(engine)
+	CalendarPublicRoutes(engine)
+	VoicePublicRoutes(engine)
+	PaymentPublicRoutes(engine)
+	FoodPublicRoutes(engine)
+	TablePublicRoutes(engine)
+	engine.Use(middleware.Authentication())
+
+	engine.Static("/uploads", helpers.UploadRoot())
+
+	UserRoutes(engine)
+	FoodRoutes(engine)
+	MenuRoutes(engine)
+	CategoryRoutes(engine)
+	ModifierRoutes(engine)
+	ComboRoutes(engine)
+	TableRoutes(engine)
+	OrderRoutes(engine)
+	OrderItemRoutes(engine)
+	KitchenTicketRoutes(engine)
+	InvoiceRoutes(engine)
+	PaymentRoutes(engine)
+	ForecastRoutes(engine)
+	PrepListRoutes(engine)
+	PriceScheduleRoutes(engine)
+	DiscountRoutes(engine)
+	UpsellRoutes(engine)
+	SurgeRuleRoutes(engine)
+	ChannelPolicyRoutes(engine)
+	SegmentRoutes(engine)
+	CampaignRoutes(engine)
+	SurveyRoutes(engine)
+	ReviewRoutes(engine)
+	SocialRoutes(engine)
+	MenuBoardRoutes(engine)
+	SyncRoutes(engine)
+	CashRoutes(engine)
+	ExpenseRoutes(engine)
+	PurchasingRoutes(engine)
+	PayrollRoutes(engine)
+	HaccpRoutes(engine)
+	ChecklistRoutes(engine)
+	TicketRoutes(engine)
+	AssetRoutes(engine)
+	TaskRoutes(engine)
+	ManagerLogRoutes(engine)
+	ReceiptRoutes(engine)
+	TaxRoutes(engine)
+	ReservationRoutes(engine)
+	CalendarRoutes(engine)
+	DineInRoutes(engine)
+	WebhookRoutes(engine)
+	DeviceRoutes(engine)
+	CustomerRoutes(engine)
+	AuditRoutes(engine)
+
+	return engine
+}
@@ -0,0 +1,17 @@
+package routes
+
+import (
+	controller "restaurant-management/controllers"
+	"restaurant-management/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+func SegmentRoutes(incomingRoutes *gin.Engine) {
+	incomingRoutes.GET("/segments", middleware.RequireRole("MANAGER"), controller.GetSegments())
+	incomingRoutes.GET("/segments/:segment_id", middleware.RequireRole("MANAGER"), controller.GetSegment())
+	incomingRoutes.GET("/segments/:segment_id/members", middleware.RequireRole("MANAGER"), controller.GetSegmentMembers())
+	incomingRoutes.GET("/segments/:segment_id/export", middleware.RequireRole("MANAGER"), controller.ExportSegmentMembers())
+	incomingRoutes.POST("/segments", middleware.RequireRole("MANAGER"), controller.CreateSegment())
+	incomingRoutes.PATCH("/segments/:segment_id", middleware.RequireRole("MANAGER"), controller.UpdateSegment())
+}
@@ -0,0 +1,17 @@
+package routes
+
+import (
+	controller "restaurant-management/controllers"
+	"restaurant-management/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+func SocialRoutes(incomingRoutes *gin.Engine) {
+	incomingRoutes.GET("/social/accounts", middleware.RequireRole("MANAGER"), controller.GetSocialAccounts())
+	incomingRoutes.POST("/social/accounts", middleware.RequireRole("MANAGER"), controller.CreateSocialAccount())
+	incomingRoutes.PATCH("/social/accounts/:social_account_id", middleware.RequireRole("MANAGER"), controller.UpdateSocialAccount())
+	incomingRoutes.GET("/social/posts", middleware.RequireRole("MANAGER"), controller.GetSocialPosts())
+	incomingRoutes.GET("/social/specials/preview", middleware.RequireRole("MANAGER"), controller.PreviewSpecialsPost())
+	incomingRoutes.POST("/social/specials/publish", middleware.RequireRole("MANAGER"), controller.PublishSpecialsPost())
+}
@@ -0,0 +1,16 @@
+package routes
+
+import (
+	controller "restaurant-management/controllers"
+	"restaurant-management/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+func SurgeRuleRoutes(incomingRoutes *gin.Engine) {
+	incomingRoutes.GET("/surgeRules", middleware.RequireRole("MANAGER"), controller.GetSurgeRules())
+	incomingRoutes.GET("/surgeRules/report", middleware.RequireRole("MANAGER"), controller.GetSurgeReport())
+	incomingRoutes.POST("/surgeRules", middleware.RequireRole("MANAGER"), controller.CreateSurgeRule())
+	incomingRoutes.PATCH("/surgeRules/:surge_rule_id", middleware.RequireRole("MANAGER"), controller.UpdateSurgeRule())
+	incomingRoutes.PATCH("/surgeRules/:surge_rule_id/toggle", middleware.RequireRole("MANAGER"), controller.ToggleSurgeRule())
+}
@@ -0,0 +1,25 @@
+package routes
+
+import (
+	controller "restaurant-management/controllers"
+	"restaurant-management/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SurveyPublicRoutes are reached from links sent to guests and are
+// registered ahead of the authentication middleware.
+func SurveyPublicRoutes(incomingRoutes *gin.Engine) {
+	incomingRoutes.GET("/surveys/respond/:token", controller.GetSurveyInvitation())
+	incomingRoutes.POST("/surveys/respond/:token", controller.SubmitSurveyResponse())
+}
+
+func SurveyRoutes(incomingRoutes *gin.Engine) {
+	incomingRoutes.GET("/surveys", middleware.RequireRole("MANAGER"), controller.GetSurveys())
+	incomingRoutes.GET("/surveys/nps", middleware.RequireRole("MANAGER"), controller.GetNpsReport())
+	incomingRoutes.GET("/surveys/alerts", middleware.RequireRole("MANAGER"), controller.GetSurveyAlerts())
+	incomingRoutes.PATCH("/surveys/alerts/:alert_id/resolve", middleware.RequireRole("MANAGER"), controller.ResolveSurveyAlert())
+	incomingRoutes.GET("/surveys/:survey_id", middleware.RequireRole("MANAGER"), controller.GetSurvey())
+	incomingRoutes.POST("/surveys", middleware.RequireRole("MANAGER"), controller.CreateSurvey())
+	incomingRoutes.PATCH("/surveys/:survey_id", middleware.RequireRole("MANAGER"), controller.UpdateSurvey())
+}
@@ -0,0 +1,13 @@
+package routes
+
+import (
+	controller "restaurant-management/controllers"
+	"restaurant-management/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+func SyncRoutes(incomingRoutes *gin.Engine) {
+	incomingRoutes.POST("/sync/terminals/:terminal_id/orders", middleware.RequireRole("MANAGER", "WAITER"), controller.SyncTerminalOrders())
+	incomingRoutes.GET("/sync/terminals/:terminal_id/checkpoint", middleware.RequireRole("MANAGER", "WAITER"), controller.GetTerminalCheckpoint())
+}
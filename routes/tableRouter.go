@@ -2,13 +2,28 @@ package routes
 
 import (
 	controller "restaurant-management/controllers"
+	"restaurant-management/middleware"
 
 	"github.com/gin-gonic/gin"
 )
 
+// TablePublicRoutes let guests who scanned a table's QR code see the menus
+// and order onto that table without logging in.
+func TablePublicRoutes(incomingRoutes *gin.Engine) {
+	incomingRoutes.GET("/qr/:token/menu", controller.GetQrMenu())
+	incomingRoutes.GET("/qr/:token/order", controller.GetQrOrder())
+	incomingRoutes.POST("/qr/:token/items", controller.PlaceQrOrder())
+}
+
 func TableRoutes(incomingRoutes *gin.Engine) {
-	incomingRoutes.GET("/tables", controller.GetTables())
-	incomingRoutes.GET("/tables/:table_id", controller.GetTable())
-	incomingRoutes.POST("/tables", controller.CreateTable())
-	incomingRoutes.PATCH("/tables/:table_id", controller.UpdateTable())
+	incomingRoutes.GET("/tables", middleware.RequireRole("MANAGER", "WAITER"), controller.GetTables())
+	incomingRoutes.GET("/tables/:table_id", middleware.RequireRole("MANAGER", "WAITER"), controller.GetTable())
+	incomingRoutes.GET("/tables/:table_id/availability", controller.GetTableAvailability())
+	incomingRoutes.GET("/tables/:table_id/qr", middleware.RequireRole("MANAGER"), controller.GetTableQr())
+	incomingRoutes.POST("/tables/:table_id/qr/regenerate", middleware.RequireRole("MANAGER"), controller.Audit("table"), controller.RegenerateTableQr())
+	incomingRoutes.POST("/tables", middleware.RequireRole("MANAGER"), controller.Audit("table"), controller.CreateTable())
+	incomingRoutes.PATCH("/tables/:table_id", middleware.RequireRole("MANAGER", "WAITER"), controller.Audit("table"), controller.UpdateTable())
+	incomingRoutes.PATCH("/tables/:table_id/status", middleware.RequireRole("MANAGER", "WAITER"), controller.Audit("table"), controller.UpdateTableStatus())
+	incomingRoutes.DELETE("/tables/:table_id", middleware.RequireRole("MANAGER"), controller.Audit("table"), controller.DeleteTable())
+	incomingRoutes.POST("/tables/:table_id/restore", middleware.RequireRole("ADMIN"), controller.Audit("table"), controller.RestoreTable())
 }
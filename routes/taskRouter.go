@@ -0,0 +1,18 @@
+package routes
+
+import (
+	controller "restaurant-management/controllers"
+	"restaurant-management/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TaskRoutes(incomingRoutes *gin.Engine) {
+	incomingRoutes.GET("/taskTemplates", middleware.RequireRole("MANAGER"), controller.GetTaskTemplates())
+	incomingRoutes.POST("/taskTemplates", middleware.RequireRole("MANAGER"), controller.CreateTaskTemplate())
+	incomingRoutes.PATCH("/taskTemplates/:task_template_id", middleware.RequireRole("MANAGER"), controller.UpdateTaskTemplate())
+
+	incomingRoutes.GET("/tasks", middleware.RequireRole("MANAGER", "WAITER", "KITCHEN"), controller.GetTasks())
+	incomingRoutes.GET("/tasks/missed", middleware.RequireRole("MANAGER", "WAITER", "KITCHEN"), controller.GetMissedTasks())
+	incomingRoutes.PATCH("/tasks/:task_id/complete", middleware.RequireRole("MANAGER", "WAITER", "KITCHEN"), controller.CompleteTask())
+}
@@ -0,0 +1,19 @@
+package routes
+
+import (
+	controller "restaurant-management/controllers"
+	"restaurant-management/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TaxRoutes(incomingRoutes *gin.Engine) {
+	incomingRoutes.GET("/taxRates", controller.GetTaxRates())
+	incomingRoutes.POST("/taxRates", middleware.RequireRole("MANAGER"), controller.CreateTaxRate())
+	incomingRoutes.PATCH("/taxRates/:tax_rate_id", middleware.RequireRole("MANAGER"), controller.UpdateTaxRate())
+	incomingRoutes.GET("/creditNotes", middleware.RequireRole("MANAGER"), controller.GetCreditNotes())
+	incomingRoutes.POST("/invoices/:invoice_id/creditNotes", middleware.RequireRole("MANAGER"), controller.CreateCreditNote())
+	incomingRoutes.GET("/refundRequests", middleware.RequireRole("MANAGER"), controller.GetRefundRequests())
+	incomingRoutes.POST("/invoices/:invoice_id/refundRequests", middleware.RequireRole("MANAGER", "WAITER"), controller.CreateRefundRequest())
+	incomingRoutes.GET("/reports/tax", middleware.RequireRole("MANAGER"), controller.GetTaxReport())
+}
@@ -0,0 +1,17 @@
+package routes
+
+import (
+	controller "restaurant-management/controllers"
+	"restaurant-management/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TicketRoutes(incomingRoutes *gin.Engine) {
+	incomingRoutes.GET("/tickets", middleware.RequireRole("MANAGER", "WAITER", "KITCHEN"), controller.GetTickets())
+	incomingRoutes.GET("/tickets/downtime", middleware.RequireRole("MANAGER"), controller.GetDowntimeReport())
+	incomingRoutes.GET("/tickets/:ticket_id", middleware.RequireRole("MANAGER", "WAITER", "KITCHEN"), controller.GetTicket())
+	incomingRoutes.POST("/tickets", middleware.RequireRole("MANAGER", "WAITER", "KITCHEN"), controller.CreateTicket())
+	incomingRoutes.PATCH("/tickets/:ticket_id", middleware.RequireRole("MANAGER"), controller.UpdateTicket())
+	incomingRoutes.POST("/tickets/:ticket_id/photos", middleware.RequireRole("MANAGER", "WAITER", "KITCHEN"), controller.UploadTicketPhoto())
+}
@@ -0,0 +1,18 @@
+package routes
+
+import (
+	controller "restaurant-management/controllers"
+	"restaurant-management/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+func UpsellRoutes(incomingRoutes *gin.Engine) {
+	incomingRoutes.GET("/upsellRules", middleware.RequireRole("MANAGER"), controller.GetUpsellRules())
+	incomingRoutes.GET("/upsellRules/report", middleware.RequireRole("MANAGER"), controller.GetUpsellReport())
+	incomingRoutes.POST("/upsellRules", middleware.RequireRole("MANAGER"), controller.CreateUpsellRule())
+	incomingRoutes.PATCH("/upsellRules/:upsell_rule_id", middleware.RequireRole("MANAGER"), controller.UpdateUpsellRule())
+	incomingRoutes.POST("/upsells/evaluate", middleware.RequireRole("MANAGER", "WAITER", "CUSTOMER"), controller.EvaluateUpsells())
+	incomingRoutes.POST("/upsellPrompts/:upsell_prompt_id/accept", middleware.RequireRole("MANAGER", "WAITER", "CUSTOMER"), controller.AcceptUpsellPrompt())
+	incomingRoutes.POST("/upsellPrompts/:upsell_prompt_id/decline", middleware.RequireRole("MANAGER", "WAITER", "CUSTOMER"), controller.DeclineUpsellPrompt())
+}
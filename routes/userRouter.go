@@ -2,13 +2,21 @@ package routes
 
 import (
 	controller "restaurant-management/controllers"
+	"restaurant-management/middleware"
 
 	"github.com/gin-gonic/gin"
 )
 
-func UserRoutes(incomingRoutes *gin.Engine) {
-	incomingRoutes.GET("/users", controller.GetUsers())
-	incomingRoutes.GET("/users/:user_id", controller.GetUser())
+// UserPublicRoutes are how users get their tokens, so they are served
+// before authentication.
+func UserPublicRoutes(incomingRoutes *gin.Engine) {
 	incomingRoutes.POST("/users/signup", controller.SignUp())
-	incomingRoutes.PATCH("/users/login", controller.Login())
+	incomingRoutes.POST("/users/login", controller.Login())
+	incomingRoutes.POST("/users/refresh", controller.RefreshToken())
+}
+
+func UserRoutes(incomingRoutes *gin.Engine) {
+	incomingRoutes.GET("/users", middleware.RequireRole("MANAGER"), controller.GetUsers())
+	incomingRoutes.GET("/users/:user_id", middleware.RequireRole("MANAGER"), controller.GetUser())
+	incomingRoutes.PATCH("/users/:user_id/role", middleware.RequireRole("ADMIN"), controller.UpdateUserRole())
 }
@@ -0,0 +1,14 @@
+package routes
+
+import (
+	controller "restaurant-management/controllers"
+
+	"github.com/gin-gonic/gin"
+)
+
+// VoicePublicRoutes take fulfillment webhooks from voice platforms, which
+// authenticate with a shared token or skill id instead of a user session.
+func VoicePublicRoutes(incomingRoutes *gin.Engine) {
+	incomingRoutes.POST("/voice/dialogflow", controller.DialogflowWebhook())
+	incomingRoutes.POST("/voice/alexa", controller.AlexaWebhook())
+}
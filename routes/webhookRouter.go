@@ -0,0 +1,16 @@
+package routes
+
+import (
+	controller "restaurant-management/controllers"
+	"restaurant-management/middleware"
+
+	"github.com/gin-gonic/gin"
+)
+
+func WebhookRoutes(incomingRoutes *gin.Engine) {
+	incomingRoutes.GET("/webhooks", middleware.RequireRole("ADMIN"), controller.GetWebhooks())
+	incomingRoutes.POST("/webhooks", middleware.RequireRole("ADMIN"), controller.CreateWebhook())
+	incomingRoutes.PATCH("/webhooks/:webhook_id", middleware.RequireRole("ADMIN"), controller.UpdateWebhook())
+	incomingRoutes.GET("/webhookDeliveries", middleware.RequireRole("ADMIN"), controller.GetWebhookDeliveries())
+	incomingRoutes.POST("/webhookDeliveries/:webhook_delivery_id/retry", middleware.RequireRole("ADMIN"), controller.RetryWebhookDelivery())
+}
@@ -0,0 +1,143 @@
+package scheduler
+
+import (
+	"context"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Job is a background task run on a fixed interval, or once a day at
+// a wall-clock time when At is set.
+type Job struct {
+	Name     string
+	Interval time.Duration
+	At       string
+	Run      func(ctx context.Context) error
+}
+
+var (
+	mu      sync.Mutex
+	jobs    []Job
+	running sync.WaitGroup
+	live    atomic.Int32
+)
+
+// Register adds a job to be run once Start is called.
+func Register(name string, interval time.Duration, run func(ctx context.Context) error) {
+	mu.Lock()
+	defer mu.Unlock()
+	jobs = append(jobs, Job{Name: name, Interval: interval, Run: run})
+}
+
+// RegisterDaily adds a job that runs every day at the given local "HH:MM".
+func RegisterDaily(name string, at string, run func(ctx context.Context) error) error {
+	if _, err := time.Parse("15:04", at); err != nil {
+		return err
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	jobs = append(jobs, Job{Name: name, Interval: 24 * time.Hour, At: at, Run: run})
+	return nil
+}
+
+// Start launches every registered job in its own goroutine. Each job runs
+// once immediately and then on every tick until ctx is cancelled. A run
+// under way when ctx is cancelled is left to finish.
+func Start(ctx context.Context) {
+	mu.Lock()
+	defer mu.Unlock()
+	for _, job := range jobs {
+		running.Add(1)
+		live.Add(1)
+		go func() {
+			defer running.Done()
+			defer live.Add(-1)
+			loop(ctx, job)
+		}()
+	}
+}
+
+// Status reports how many jobs are running and how many are registered.
+// They differ before Start, after the scheduler is stopped, or when a job
+// loop died.
+func Status() (running, registered int) {
+	mu.Lock()
+	defer mu.Unlock()
+	return int(live.Load()), len(jobs)
+}
+
+// Wait blocks until every job started by Start has stopped after its
+// context was cancelled, or until ctx is done.
+func Wait(ctx context.Context) error {
+	stopped := make(chan struct{})
+	go func() {
+		running.Wait()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func loop(ctx context.Context, job Job) {
+	if job.At != "" {
+		dailyLoop(ctx, job)
+		return
+	}
+
+	ticker := time.NewTicker(job.Interval)
+	defer ticker.Stop()
+
+	for {
+		runOnce(ctx, job)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func dailyLoop(ctx context.Context, job Job) {
+	for {
+		timer := time.NewTimer(time.Until(nextDailyRun(job.At, time.Now())))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+			runOnce(ctx, job)
+		}
+	}
+}
+
+// nextDailyRun returns the next time strictly after now at the "HH:MM" clock time.
+func nextDailyRun(at string, now time.Time) time.Time {
+	clock, _ := time.Parse("15:04", at)
+	next := time.Date(now.Year(), now.Month(), now.Day(), clock.Hour(), clock.Minute(), 0, 0, now.Location())
+	if !next.After(now) {
+		next = next.AddDate(0, 0, 1)
+	}
+	return next
+}
+
+func runOnce(ctx context.Context, job Job) {
+	defer func() {
+		if r := recover(); r != nil {
+			log.Printf("scheduler: job %s panicked: %v", job.Name, r)
+		}
+	}()
+
+	// Stopping the scheduler must not cut a run off mid-write
+	runCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), job.Interval)
+	defer cancel()
+
+	if err := job.Run(runCtx); err != nil {
+		log.Printf("scheduler: job %s failed: %v", job.Name, err)
+	}
+}
@@ -0,0 +1,58 @@
+package services
+
+import "errors"
+
+// The kinds of domain error. Entry points match them with errors.Is to
+// answer in their own terms, such as HTTP statuses.
+var (
+	ErrInvalid    = errors.New("invalid request")
+	ErrValidation = errors.New("validation failed")
+	ErrNotFound   = errors.New("not found")
+	ErrConflict   = errors.New("conflict")
+)
+
+// Error is a business rule a call broke: its kind, a message for people,
+// the check that failed when there was one, such as the validator's, and
+// details that explain it, such as the ids it refers to. Errors that are
+// not an *Error are storage failures.
+type Error struct {
+	Kind    error
+	Message string
+	Cause   error
+	Details map[string]interface{}
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// Unwrap lets errors.Is match the kind and errors.As reach the cause.
+func (e *Error) Unwrap() []error {
+	if e.Cause == nil {
+		return []error{e.Kind}
+	}
+	return []error{e.Kind, e.Cause}
+}
+
+// With adds a detail to the error and returns it.
+func (e *Error) With(key string, value interface{}) *Error {
+	if e.Details == nil {
+		e.Details = map[string]interface{}{}
+	}
+	e.Details[key] = value
+	return e
+}
+
+func invalid(message string) *Error {
+	return &Error{Kind: ErrInvalid, Message: message}
+}
+
+func notFound(message string) *Error {
+	return &Error{Kind: ErrNotFound, Message: message}
+}
+
+// validationFailed reports a failed check on a record's fields, by the
+// validator or by hand.
+func validationFailed(err error) *Error {
+	return &Error{Kind: ErrValidation, Message: err.Error(), Cause: err}
+}
@@ -0,0 +1,236 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"restaurant-management/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var foodCollection = db.Collection("food")
+var categoryCollection = db.Collection("category")
+var modifierGroupCollection = db.Collection("modifierGroup")
+
+// CreateFood stores a new food once its menu, category and modifier groups
+// exist and its allergens, diets and substitutions agree. Its ids and
+// timestamps are filled in and its price rounded to the cent.
+func CreateFood(ctx context.Context, food *models.Food) (*mongo.InsertOneResult, error) {
+	food.Allergens = NormalizeTags(food.Allergens)
+	food.Dietary_flags = NormalizeTags(food.Dietary_flags)
+	if err := Validate.Struct(food); err != nil {
+		return nil, validationFailed(err)
+	}
+
+	if !menuExists(ctx, *food.Menu_id) {
+		return nil, notFound("Menu not found")
+	}
+	if food.Category_id != nil && !categoryExists(ctx, *food.Category_id) {
+		return nil, notFound("Category not found")
+	}
+	if err := checkDietaryFlags(food.Allergens, food.Dietary_flags); err != nil {
+		return nil, validationFailed(err)
+	}
+	food.Tags = NormalizeTags(food.Tags)
+	if err := checkModifierGroups(ctx, food.Modifier_groups); err != nil {
+		return nil, err
+	}
+	if err := PrepareSubstitutions(food.Substitutions); err != nil {
+		return nil, validationFailed(err)
+	}
+
+	food.Created_at = Now()
+	food.Updated_at = food.Created_at
+	food.ID = primitive.NewObjectID()
+	food.Food_id = food.ID.Hex()
+	if food.Price != nil {
+		price := ToFixed(*food.Price, 2)
+		food.Price = &price
+	}
+
+	result, err := foodCollection().InsertOne(ctx, food)
+	if err != nil {
+		return nil, fmt.Errorf("Could not create food item: %w", err)
+	}
+	return result, nil
+}
+
+// UpdateFood sets the fields given in changes on a food, upserting it,
+// after the same checks as CreateFood. A food keeps the flags it had when
+// only its allergens change, so they are checked against each other as
+// they will be stored.
+func UpdateFood(ctx context.Context, foodId string, changes models.Food) (*mongo.UpdateResult, error) {
+	var updateObj primitive.D
+	if changes.Name != nil {
+		updateObj = append(updateObj, bson.E{Key: "name", Value: changes.Name})
+	}
+
+	if changes.Description != nil {
+		if err := Validate.StructPartial(changes, "Description"); err != nil {
+			return nil, validationFailed(err)
+		}
+		updateObj = append(updateObj, bson.E{Key: "description", Value: changes.Description})
+	}
+
+	if changes.Price != nil {
+		price := ToFixed(*changes.Price, 2)
+		updateObj = append(updateObj, bson.E{Key: "price", Value: price})
+	}
+
+	if changes.Food_image != nil {
+		updateObj = append(updateObj, bson.E{Key: "food_image", Value: changes.Food_image})
+	}
+
+	if changes.Menu_id != nil {
+		if !menuExists(ctx, *changes.Menu_id) {
+			return nil, notFound("Menu not found")
+		}
+		updateObj = append(updateObj, bson.E{Key: "menu_id", Value: changes.Menu_id})
+	}
+
+	if changes.Category_id != nil {
+		if *changes.Category_id != "" && !categoryExists(ctx, *changes.Category_id) {
+			return nil, notFound("Category not found")
+		}
+		updateObj = append(updateObj, bson.E{Key: "category_id", Value: changes.Category_id})
+	}
+
+	if changes.Tags != nil {
+		if err := Validate.StructPartial(changes, "Tags"); err != nil {
+			return nil, validationFailed(err)
+		}
+		updateObj = append(updateObj, bson.E{Key: "tags", Value: NormalizeTags(changes.Tags)})
+	}
+
+	if changes.Calories != nil {
+		if err := Validate.StructPartial(changes, "Calories"); err != nil {
+			return nil, validationFailed(err)
+		}
+		updateObj = append(updateObj, bson.E{Key: "calories", Value: changes.Calories})
+	}
+
+	if changes.Allergens != nil || changes.Dietary_flags != nil {
+		if changes.Allergens != nil {
+			changes.Allergens = NormalizeTags(changes.Allergens)
+		}
+		if changes.Dietary_flags != nil {
+			changes.Dietary_flags = NormalizeTags(changes.Dietary_flags)
+		}
+		if err := Validate.StructPartial(changes, "Allergens", "Dietary_flags"); err != nil {
+			return nil, validationFailed(err)
+		}
+		var current models.Food
+		if err := foodCollection().FindOne(ctx, bson.M{"food_id": foodId}).Decode(&current); err != nil && err != mongo.ErrNoDocuments {
+			return nil, fmt.Errorf("error occurred while loading food item: %w", err)
+		}
+		allergens, flags := current.Allergens, current.Dietary_flags
+		if changes.Allergens != nil {
+			allergens = changes.Allergens
+			updateObj = append(updateObj, bson.E{Key: "allergens", Value: changes.Allergens})
+		}
+		if changes.Dietary_flags != nil {
+			flags = changes.Dietary_flags
+			updateObj = append(updateObj, bson.E{Key: "dietary_flags", Value: changes.Dietary_flags})
+		}
+		if err := checkDietaryFlags(allergens, flags); err != nil {
+			return nil, validationFailed(err)
+		}
+	}
+
+	if changes.Modifier_groups != nil {
+		if err := Validate.StructPartial(changes, "Modifier_groups"); err != nil {
+			return nil, validationFailed(err)
+		}
+		if err := checkModifierGroups(ctx, changes.Modifier_groups); err != nil {
+			return nil, err
+		}
+		updateObj = append(updateObj, bson.E{Key: "modifier_groups", Value: changes.Modifier_groups})
+	}
+
+	updateObj = append(updateObj, bson.E{Key: "updated_at", Value: Now()})
+
+	upsert := true
+	result, err := foodCollection().UpdateOne(
+		ctx,
+		bson.M{"food_id": foodId},
+		bson.D{{Key: "$set", Value: updateObj}},
+		&options.UpdateOptions{Upsert: &upsert},
+	)
+	if err != nil {
+		return nil, fmt.Errorf("Update failed: %w", err)
+	}
+	return result, nil
+}
+
+// dietaryConflicts are the allergens a food flagged for a diet cannot have.
+var dietaryConflicts = map[string][]string{
+	"vegan":       {"crustacean", "egg", "fish", "milk", "mollusc"},
+	"vegetarian":  {"crustacean", "fish", "mollusc"},
+	"gluten-free": {"gluten"},
+}
+
+// checkDietaryFlags rejects dietary flags contradicted by the allergens.
+func checkDietaryFlags(allergens, flags []string) error {
+	for _, flag := range flags {
+		for _, conflict := range dietaryConflicts[flag] {
+			for _, allergen := range allergens {
+				if allergen == conflict {
+					return fmt.Errorf("a %s food cannot contain %s", flag, allergen)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// PrepareSubstitutions gives new substitutions their ids and checks that no
+// swap is listed twice.
+func PrepareSubstitutions(substitutions []models.Substitution) error {
+	seen := map[string]bool{}
+	for i := range substitutions {
+		key := *substitutions[i].Replaces_sku + "/" + *substitutions[i].Sku
+		if seen[key] {
+			return fmt.Errorf("%s is listed twice as a substitute for %s", *substitutions[i].Sku, *substitutions[i].Replaces_sku)
+		}
+		seen[key] = true
+		if substitutions[i].Substitution_id == "" {
+			substitutions[i].Substitution_id = primitive.NewObjectID().Hex()
+		}
+	}
+	return nil
+}
+
+func categoryExists(ctx context.Context, categoryId string) bool {
+	return categoryCollection().FindOne(ctx, bson.M{"category_id": categoryId}).Err() == nil
+}
+
+// checkModifierGroups rejects modifier group ids that name no group,
+// listing them in the "modifier_groups" detail.
+func checkModifierGroups(ctx context.Context, groupIds []string) error {
+	if len(groupIds) == 0 {
+		return nil
+	}
+	found, err := modifierGroupCollection().Distinct(ctx, "modifier_group_id", bson.M{"modifier_group_id": bson.M{"$in": groupIds}})
+	if err != nil {
+		return fmt.Errorf("error occurred while loading modifier groups: %w", err)
+	}
+	exists := map[string]bool{}
+	for _, id := range found {
+		if id, ok := id.(string); ok {
+			exists[id] = true
+		}
+	}
+	missing := []string{}
+	for _, groupId := range groupIds {
+		if !exists[groupId] {
+			missing = append(missing, groupId)
+		}
+	}
+	if len(missing) > 0 {
+		return invalid("Some modifier groups do not exist").With("modifier_groups", missing)
+	}
+	return nil
+}
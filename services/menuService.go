@@ -0,0 +1,145 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"restaurant-management/models"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var menuCollection = db.Collection("menu")
+var menuVersionCollection = db.Collection("menuVersion")
+
+// CreateMenu stores a new menu as an inactive draft, to be published
+// later. A window it is given must not have closed already.
+func CreateMenu(ctx context.Context, menu *models.Menu) (*mongo.InsertOneResult, error) {
+	if err := Validate.Struct(menu); err != nil {
+		return nil, validationFailed(err)
+	}
+	if menu.Start_Date != nil && menu.End_Date != nil && !inTimeSpan(*menu.Start_Date, *menu.End_Date, time.Now()) {
+		return nil, invalid("end_date must be after start_date and in the future")
+	}
+
+	menu.Created_at = Now()
+	menu.Updated_at = menu.Created_at
+	menu.Status = "DRAFT"
+	menu.Version = 0
+	menu.Active = false
+	menu.ID = primitive.NewObjectID()
+	menu.Menu_id = menu.ID.Hex()
+
+	result, err := menuCollection().InsertOne(ctx, menu)
+	if err != nil {
+		return nil, fmt.Errorf("Could not create menu: %w", err)
+	}
+	return result, nil
+}
+
+// UpdateMenu sets the fields given in changes on a menu, upserting it. A
+// new window must not have closed already, and the menu turns active or
+// inactive with it.
+func UpdateMenu(ctx context.Context, menuId string, changes models.Menu) (*mongo.UpdateResult, error) {
+	filter := bson.M{"menu_id": menuId}
+
+	var updateObj primitive.D
+	if changes.Start_Date != nil && changes.End_Date != nil {
+		if !inTimeSpan(*changes.Start_Date, *changes.End_Date, time.Now()) {
+			return nil, invalid("Kindly correct the time")
+		}
+		updateObj = append(updateObj, bson.E{Key: "start_date", Value: changes.Start_Date})
+		updateObj = append(updateObj, bson.E{Key: "end_date", Value: changes.End_Date})
+		var current models.Menu
+		if err := menuCollection().FindOne(ctx, filter).Decode(&current); err == nil {
+			changes.Status = current.Status
+		}
+		updateObj = append(updateObj, bson.E{Key: "active", Value: MenuRunningAt(changes, time.Now())})
+	}
+
+	if changes.Name != "" {
+		updateObj = append(updateObj, bson.E{Key: "name", Value: changes.Name})
+	}
+
+	if changes.Category != "" {
+		updateObj = append(updateObj, bson.E{Key: "category", Value: changes.Category})
+	}
+
+	updateObj = append(updateObj, bson.E{Key: "updated_at", Value: Now()})
+
+	upsert := true
+	result, err := menuCollection().UpdateOne(
+		ctx,
+		filter,
+		bson.D{{Key: "$set", Value: updateObj}},
+		&options.UpdateOptions{Upsert: &upsert},
+	)
+	if err != nil {
+		return nil, fmt.Errorf("Update failed: %w", err)
+	}
+	return result, nil
+}
+
+// PublishMenuVersion marks a menu PUBLISHED under the next version number
+// and stores the snapshot of it and its foods.
+func PublishMenuVersion(ctx context.Context, menu models.Menu, foods []models.Food, rolledBackFrom *int, publishedBy string) (models.MenuVersion, error) {
+	now := Now()
+	menu.Status = "PUBLISHED"
+	active := MenuRunningAt(menu, time.Now())
+
+	// The version number is taken atomically so concurrent publishes
+	// never share one
+	var published models.Menu
+	err := menuCollection().FindOneAndUpdate(
+		ctx,
+		bson.M{"menu_id": menu.Menu_id},
+		bson.D{
+			{Key: "$inc", Value: bson.D{{Key: "version", Value: 1}}},
+			{Key: "$set", Value: bson.D{
+				{Key: "status", Value: "PUBLISHED"},
+				{Key: "active", Value: active},
+				{Key: "updated_at", Value: now},
+			}},
+		},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&published)
+	if err != nil {
+		return models.MenuVersion{}, err
+	}
+
+	version := models.MenuVersion{
+		ID:               primitive.NewObjectID(),
+		Menu_id:          published.Menu_id,
+		Version:          published.Version,
+		Menu:             published,
+		Foods:            foods,
+		Rolled_back_from: rolledBackFrom,
+		Published_by:     publishedBy,
+		Published_at:     now,
+	}
+	version.Menu_version_id = version.ID.Hex()
+	_, err = menuVersionCollection().InsertOne(ctx, version)
+	return version, err
+}
+
+// MenuRunningAt reports whether menu is published, not deleted and its
+// window is open at t.
+func MenuRunningAt(menu models.Menu, t time.Time) bool {
+	if menu.Status == "DRAFT" || menu.Status == "ARCHIVED" || menu.Deleted_at != nil {
+		return false
+	}
+	return (menu.Start_Date == nil || !t.Before(*menu.Start_Date)) && (menu.End_Date == nil || !t.After(*menu.End_Date))
+}
+
+// inTimeSpan reports whether start to end is a window that has not closed
+// by check.
+func inTimeSpan(start, end, check time.Time) bool {
+	return end.After(start) && end.After(check)
+}
+
+func menuExists(ctx context.Context, menuId string) bool {
+	return menuCollection().FindOne(ctx, bson.M{"menu_id": menuId}).Err() == nil
+}
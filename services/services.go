@@ -0,0 +1,16 @@
+package services
+
+import (
+	"restaurant-management/database"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// db is the client the services' collections are looked up on.
+var db database.Handle
+
+// Bind gives the services the client to work with. It must be called
+// before any of them run.
+func Bind(client *mongo.Client) {
+	db.Bind(client)
+}
@@ -0,0 +1,17 @@
+package services
+
+import (
+	"restaurant-management/apierror"
+
+	"github.com/go-playground/validator/v10"
+)
+
+// Validate checks records against their validate tags. Fields are named
+// by their JSON name in errors, as clients know them.
+var Validate = newValidator()
+
+func newValidator() *validator.Validate {
+	v := validator.New()
+	v.RegisterTagNameFunc(apierror.JSONFieldName)
+	return v
+}
@@ -0,0 +1,40 @@
+package services
+
+import (
+	"math"
+	"strings"
+	"time"
+)
+
+// Now is the time stamped on records: the current time to the second, as
+// it round-trips through RFC 3339.
+func Now() time.Time {
+	now, _ := time.Parse(time.RFC3339, time.Now().Format(time.RFC3339))
+	return now
+}
+
+// ToFixed rounds num half away from zero to precision decimal places.
+// Prices are kept to 2.
+func ToFixed(num float64, precision int) float64 {
+	output := math.Pow(10, float64(precision))
+	return float64(round(num*output)) / output
+}
+
+func round(num float64) int {
+	return int(num + math.Copysign(0.5, num))
+}
+
+// NormalizeTags lowercases and trims tags and drops empty and repeated ones.
+func NormalizeTags(tags []string) []string {
+	normalized := []string{}
+	seen := map[string]bool{}
+	for _, tag := range tags {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag == "" || seen[tag] {
+			continue
+		}
+		seen[tag] = true
+		normalized = append(normalized, tag)
+	}
+	return normalized
+}
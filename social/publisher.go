@@ -0,0 +1,140 @@
+package social
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Post is the rendered content of a social update.
+type Post struct {
+	Caption    string   `json:"caption"`
+	Image_url  string   `json:"image_url"`
+	Image_urls []string `json:"image_urls"`
+}
+
+// Account holds the credentials needed to publish to one destination.
+type Account struct {
+	Platform     string
+	Page_id      string
+	Access_token string
+	Webhook_url  string
+}
+
+var httpClient = &http.Client{Timeout: 30 * time.Second}
+
+const graphURL = "https://graph.facebook.com/v19.0"
+
+// Publish posts to the account's platform and returns the platform's post id.
+func Publish(ctx context.Context, account Account, post Post) (string, error) {
+	switch account.Platform {
+	case "FACEBOOK":
+		return publishFacebook(ctx, account, post)
+	case "INSTAGRAM":
+		return publishInstagram(ctx, account, post)
+	case "WEBHOOK":
+		return "", publishWebhook(ctx, account, post)
+	}
+	return "", fmt.Errorf("unsupported platform %q", account.Platform)
+}
+
+func publishFacebook(ctx context.Context, account Account, post Post) (string, error) {
+	form := url.Values{}
+	form.Set("caption", post.Caption)
+	form.Set("access_token", account.Access_token)
+	endpoint := graphURL + "/" + account.Page_id + "/feed"
+	if post.Image_url != "" {
+		form.Set("url", post.Image_url)
+		endpoint = graphURL + "/" + account.Page_id + "/photos"
+	} else {
+		form.Set("message", post.Caption)
+	}
+
+	var created struct {
+		ID     string `json:"id"`
+		PostId string `json:"post_id"`
+	}
+	if err := postForm(ctx, endpoint, form, &created); err != nil {
+		return "", err
+	}
+	if created.PostId != "" {
+		return created.PostId, nil
+	}
+	return created.ID, nil
+}
+
+// publishInstagram uses the two-step container flow of the Graph API.
+func publishInstagram(ctx context.Context, account Account, post Post) (string, error) {
+	if post.Image_url == "" {
+		return "", fmt.Errorf("instagram posts need an image")
+	}
+
+	form := url.Values{}
+	form.Set("image_url", post.Image_url)
+	form.Set("caption", post.Caption)
+	form.Set("access_token", account.Access_token)
+	var container struct {
+		ID string `json:"id"`
+	}
+	if err := postForm(ctx, graphURL+"/"+account.Page_id+"/media", form, &container); err != nil {
+		return "", err
+	}
+
+	form = url.Values{}
+	form.Set("creation_id", container.ID)
+	form.Set("access_token", account.Access_token)
+	var published struct {
+		ID string `json:"id"`
+	}
+	if err := postForm(ctx, graphURL+"/"+account.Page_id+"/media_publish", form, &published); err != nil {
+		return "", err
+	}
+	return published.ID, nil
+}
+
+// publishWebhook hands the rendered post to an external tool (Zapier,
+// Buffer, ...) which takes care of the actual posting.
+func publishWebhook(ctx context.Context, account Account, post Post) error {
+	payload, err := json.Marshal(post)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, account.Webhook_url, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	return send(req, nil)
+}
+
+func postForm(ctx context.Context, endpoint string, form url.Values, out interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return send(req, out)
+}
+
+func send(req *http.Request, out interface{}) error {
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		detail, _ := io.ReadAll(io.LimitReader(resp.Body, 2048))
+		return fmt.Errorf("%s returned %d: %s", req.URL.Host, resp.StatusCode, detail)
+	}
+	if out != nil {
+		return json.NewDecoder(resp.Body).Decode(out)
+	}
+	return nil
+}
@@ -0,0 +1,69 @@
+// Package spreadsheet writes tables for people who work in spreadsheets,
+// as CSV or as an Excel workbook. Rows go out as they are written, so an
+// export never holds its whole result in memory.
+package spreadsheet
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+)
+
+// Writer writes a table row by row. Cells may be strings, ints, float64s,
+// time.Times or nil for an empty cell. Close finishes the file; nothing
+// written is complete until it is called.
+type Writer interface {
+	WriteRow(cells ...interface{}) error
+	Close() error
+}
+
+// Formats are the file formats there are writers for, by the name clients
+// ask for, with their content type.
+var Formats = map[string]string{
+	"csv":  "text/csv",
+	"xlsx": "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+}
+
+// New returns a writer of format, one of Formats, to w. Workbooks have a
+// single sheet named sheet.
+func New(format string, w io.Writer, sheet string) (Writer, error) {
+	switch format {
+	case "csv":
+		return &csvWriter{csv: csv.NewWriter(w)}, nil
+	case "xlsx":
+		return newXLSX(w, sheet)
+	}
+	return nil, fmt.Errorf("unknown spreadsheet format %q", format)
+}
+
+type csvWriter struct {
+	csv *csv.Writer
+}
+
+// WriteRow writes numbers as Go prints them and times in RFC 3339.
+func (w *csvWriter) WriteRow(cells ...interface{}) error {
+	record := make([]string, len(cells))
+	for i, cell := range cells {
+		switch value := cell.(type) {
+		case nil:
+		case string:
+			record[i] = value
+		case int:
+			record[i] = strconv.Itoa(value)
+		case float64:
+			record[i] = strconv.FormatFloat(value, 'f', -1, 64)
+		case time.Time:
+			record[i] = value.Format(time.RFC3339)
+		default:
+			record[i] = fmt.Sprint(value)
+		}
+	}
+	return w.csv.Write(record)
+}
+
+func (w *csvWriter) Close() error {
+	w.csv.Flush()
+	return w.csv.Error()
+}
@@ -0,0 +1,62 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"io"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/gridfs"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// GridFS stores files in a GridFS bucket of DB. The service serves them
+// itself under Public_path.
+type GridFS struct {
+	DB          *mongo.Database
+	Bucket      string
+	Public_path string
+}
+
+func (g *GridFS) Put(ctx context.Context, key, contentType string, body []byte) (string, error) {
+	bucket, err := gridfs.NewBucket(g.DB, options.GridFSBucket().SetName(g.Bucket))
+	if err != nil {
+		return "", err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		bucket.SetWriteDeadline(deadline)
+	}
+
+	opts := options.GridFSUpload().SetMetadata(bson.M{"content_type": contentType})
+	if _, err := bucket.UploadFromStream(key, bytes.NewReader(body), opts); err != nil {
+		return "", err
+	}
+	return g.Public_path + key, nil
+}
+
+// Open returns the file stored under key and its content type.
+func (g *GridFS) Open(ctx context.Context, key string) (io.ReadCloser, string, error) {
+	bucket, err := gridfs.NewBucket(g.DB, options.GridFSBucket().SetName(g.Bucket))
+	if err != nil {
+		return nil, "", err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		bucket.SetReadDeadline(deadline)
+	}
+
+	stream, err := bucket.OpenDownloadStreamByName(key)
+	if err == gridfs.ErrFileNotFound {
+		return nil, "", ErrNotFound
+	}
+	if err != nil {
+		return nil, "", err
+	}
+	var metadata struct {
+		Content_type string `bson:"content_type"`
+	}
+	if raw := stream.GetFile().Metadata; raw != nil {
+		bson.Unmarshal(raw, &metadata)
+	}
+	return stream, metadata.Content_type, nil
+}
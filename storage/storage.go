@@ -0,0 +1,43 @@
+// Package storage keeps uploaded files, such as food photos, in S3 when a
+// bucket is configured and in MongoDB's GridFS otherwise.
+package storage
+
+import (
+	"context"
+	"errors"
+	"os"
+
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// ErrNotFound is returned when no file is stored under a key.
+var ErrNotFound = errors.New("storage: file not found")
+
+// Store saves files under keys such as "foods/<id>.jpg".
+type Store interface {
+	// Put stores body under key and returns the URL it is served from.
+	Put(ctx context.Context, key, contentType string, body []byte) (string, error)
+}
+
+// FromEnv returns an S3 store when S3_BUCKET is set and a GridFS store in
+// db otherwise.
+func FromEnv(db *mongo.Database) Store {
+	if bucket := os.Getenv("S3_BUCKET"); bucket != "" {
+		return &S3{
+			Bucket:     bucket,
+			Region:     envOr("S3_REGION", "us-east-1"),
+			Endpoint:   os.Getenv("S3_ENDPOINT"),
+			Public_url: os.Getenv("S3_PUBLIC_URL"),
+			Access_key: os.Getenv("AWS_ACCESS_KEY_ID"),
+			Secret_key: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		}
+	}
+	return &GridFS{DB: db, Bucket: "images", Public_path: "/images/"}
+}
+
+func envOr(name, fallback string) string {
+	if value := os.Getenv(name); value != "" {
+		return value
+	}
+	return fallback
+}